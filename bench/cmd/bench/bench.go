@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/url"
@@ -18,9 +19,11 @@ import (
 	"github.com/isucon/isucandar/agent"
 	"github.com/isucon/isucandar/score"
 	"github.com/isucon/isucon13/bench/internal/bencherror"
+	"github.com/isucon/isucon13/bench/internal/benchmodel"
 	"github.com/isucon/isucon13/bench/internal/benchscore"
 	"github.com/isucon/isucon13/bench/internal/config"
 	"github.com/isucon/isucon13/bench/internal/logger"
+	"github.com/isucon/isucon13/bench/internal/manifest"
 	"github.com/isucon/isucon13/bench/internal/resolver"
 	"github.com/isucon/isucon13/bench/isupipe"
 	"github.com/isucon/isucon13/bench/scenario"
@@ -31,12 +34,20 @@ var assetDir string
 var enableSSL bool
 var pretestOnly bool
 
+// 負荷走行中の進捗の出力
+var (
+	quiet      bool
+	statusAddr string
+)
+
 type BenchResult struct {
 	Pass          bool     `json:"pass"`
 	Score         int64    `json:"score"`
 	Messages      []string `json:"messages"`
 	Language      string   `json:"language"`
 	ResolvedCount int64    `json:"resolved_count"`
+	// スコアの内訳。失格のときはnil
+	ScoreBreakdown *benchscore.Breakdown `json:"score_breakdown,omitempty"`
 }
 
 // UniqueMsgs は重複除去したメッセージ配列を返します
@@ -133,6 +144,25 @@ var run = cli.Command{
 			EnvVar:      "BENCH_RESULT_PATH",
 			Value:       "/tmp/result.json",
 		},
+		cli.StringFlag{
+			Name:        "log-level",
+			Usage:       "運営・選手向けログの出力レベル (debug, info, warn, error)",
+			Value:       config.LogLevel,
+			Destination: &config.LogLevel,
+			EnvVar:      "BENCH_LOG_LEVEL",
+		},
+		cli.StringFlag{
+			Name:        "result-json",
+			Usage:       "エンドポイントごとのレスポンスタイムの集計を書き出すJSONファイルのパス。空なら書き出さない",
+			Destination: &config.LatencyReportPath,
+			EnvVar:      "BENCH_RESULT_JSON",
+		},
+		cli.StringFlag{
+			Name:        "report-path",
+			Usage:       "走行結果のレポートを書き出すパス。拡張子が .md ならMarkdown、.json ならJSON、それ以外はHTMLにする。空なら書き出さない",
+			Destination: &config.ReportPath,
+			EnvVar:      "BENCH_REPORT_PATH",
+		},
 		cli.BoolFlag{
 			Name:        "enable-ssl",
 			Destination: &enableSSL,
@@ -143,6 +173,110 @@ var run = cli.Command{
 			Destination: &pretestOnly,
 			EnvVar:      "BENCH_PRETEST_ONLY",
 		},
+		cli.Float64Flag{
+			Name:        "error-rate-threshold",
+			Usage:       "一般エラー・タイムアウトの割合 (%) がこれを超えると失格にする。0以下なら失格にしない",
+			Value:       config.ErrorRateThresholdPercentage,
+			Destination: &config.ErrorRateThresholdPercentage,
+			EnvVar:      "BENCH_ERROR_RATE_THRESHOLD",
+		},
+		cli.IntFlag{
+			Name:        "parallelism",
+			Usage:       "負荷走行でシナリオを走らせるワーカーの数",
+			Value:       config.LoadParallelism,
+			Destination: &config.LoadParallelism,
+			EnvVar:      "BENCH_PARALLELISM",
+		},
+		cli.DurationFlag{
+			Name:        "duration",
+			Usage:       "負荷走行を続ける時間",
+			Value:       config.BenchmarkDuration,
+			Destination: &config.BenchmarkDuration,
+			EnvVar:      "BENCH_DURATION",
+		},
+		cli.DurationFlag{
+			Name:        "rampup",
+			Usage:       "負荷走行の開始から、この時間をかけてワーカーを線形に増やす",
+			Value:       config.LoadRampUpDuration,
+			Destination: &config.LoadRampUpDuration,
+			EnvVar:      "BENCH_RAMPUP",
+		},
+		cli.DurationFlag{
+			Name:        "request-timeout",
+			Usage:       "負荷走行でのリクエスト1回あたりのタイムアウト",
+			Value:       config.DefaultAgentTimeout,
+			Destination: &config.DefaultAgentTimeout,
+			EnvVar:      "BENCH_REQUEST_TIMEOUT",
+		},
+		cli.BoolFlag{
+			Name:        "retry-get",
+			Usage:       "GETなど冪等なリクエストがタイムアウトしたとき、1回だけ送り直す。POSTなどは送り直さない",
+			Destination: &config.RetryIdempotentRequests,
+			EnvVar:      "BENCH_RETRY_GET",
+		},
+		cli.DurationFlag{
+			Name:        "slow-response-threshold",
+			Usage:       "レスポンスにこれより長くかかったリクエストを減点する。0以下なら減点しない",
+			Value:       config.SlowResponseThreshold,
+			Destination: &config.SlowResponseThreshold,
+			EnvVar:      "BENCH_SLOW_RESPONSE_THRESHOLD",
+		},
+		cli.IntFlag{
+			Name:        "slow-response-penalty",
+			Usage:       "遅すぎたリクエスト1件あたりの減点",
+			Value:       config.SlowResponsePenalty,
+			Destination: &config.SlowResponsePenalty,
+			EnvVar:      "BENCH_SLOW_RESPONSE_PENALTY",
+		},
+		cli.IntFlag{
+			Name:        "max-idle-conns-per-host",
+			Usage:       "仮想ユーザ全体で、ホストごとに持っておく暇な接続の数",
+			Value:       config.MaxIdleConnsPerHost,
+			Destination: &config.MaxIdleConnsPerHost,
+			EnvVar:      "BENCH_MAX_IDLE_CONNS_PER_HOST",
+		},
+		cli.BoolFlag{
+			Name:        "disable-keepalives",
+			Usage:       "接続を再利用せず、リクエストのたびに接続し直す",
+			Destination: &config.DisableKeepAlives,
+			EnvVar:      "BENCH_DISABLE_KEEPALIVES",
+		},
+		cli.BoolTFlag{
+			Name:        "http2",
+			Usage:       "webappがHTTP/2に対応していればHTTP/2で接続する。--http2=false でHTTP/1.1に固定する",
+			Destination: &config.EnableHTTP2,
+			EnvVar:      "BENCH_HTTP2",
+		},
+		cli.DurationFlag{
+			Name:        "dns-cache-ttl",
+			Usage:       "名前解決の結果をキャッシュする時間。0ならレコードのTTLに従い、負ならキャッシュしない",
+			Value:       config.DNSCacheTTL,
+			Destination: &config.DNSCacheTTL,
+			EnvVar:      "BENCH_DNS_CACHE_TTL",
+		},
+		cli.BoolFlag{
+			Name:        "quiet",
+			Usage:       "負荷走行中に、1秒ごとの進捗を出力しない",
+			Destination: &quiet,
+			EnvVar:      "BENCH_QUIET",
+		},
+		cli.StringFlag{
+			Name:        "status-addr",
+			Usage:       "走行中の進捗をJSONで返すHTTPサーバのアドレス (例: :9009 なら http://localhost:9009/status)。空なら立ち上げない",
+			Destination: &statusAddr,
+			EnvVar:      "BENCH_STATUS_ADDR",
+		},
+		cli.BoolFlag{
+			Name:        "enable-ws-viewer",
+			Destination: &config.EnableWebSocketViewer,
+			EnvVar:      "BENCH_ENABLE_WS_VIEWER",
+		},
+		cli.StringFlag{
+			Name:        "initial-data-manifest",
+			Usage:       "datagenで生成した初期データのマニフェスト (manifest.json) のパス。指定すると、整合性チェックで初期データが書き換えられていないかを確かめる",
+			Destination: &config.InitialDataManifestPath,
+			EnvVar:      "BENCH_INITIAL_DATA_MANIFEST",
+		},
 	},
 	Action: func(cliCtx *cli.Context) error {
 		ctx := context.Background()
@@ -152,6 +286,17 @@ var run = cli.Command{
 		if err != nil {
 			return cli.NewExitError(err, 1)
 		}
+		if config.LoadParallelism < 1 {
+			return cli.NewExitError("--parallelism には1以上を指定してください", 1)
+		}
+		var initialData *manifest.Manifest
+		if config.InitialDataManifestPath != "" {
+			initialData, err = manifest.Load(config.InitialDataManifestPath)
+			if err != nil {
+				return cli.NewExitError(fmt.Errorf("初期データのマニフェストを読み込めません: %w", err), 1)
+			}
+			lgr.Infof("初期データのマニフェストを読み込みました: seed=%d, users=%d, livestreams=%d", initialData.Seed, initialData.Counts.Users, initialData.Counts.Livestreams)
+		}
 
 		contestantLogger, err := logger.InitContestantLogger()
 		if err != nil {
@@ -197,6 +342,12 @@ var run = cli.Command{
 		contestantLogger.Info("静的ファイルチェックを行います")
 		contestantLogger.Info("静的ファイルチェックが完了しました")
 
+		if statusAddr != "" {
+			statusServer := startStatusServer(statusAddr)
+			defer statusServer.Close()
+			lgr.Infof("進捗を http://%s/status で返します", statusAddr)
+		}
+
 		contestantLogger.Info("webappの初期化を行います")
 		initClient, err := isupipe.NewClient(contestantLogger,
 			agent.WithBaseURL(config.TargetBaseURL),
@@ -221,12 +372,24 @@ var run = cli.Command{
 		}
 		config.Language = initializeResp.Language
 
+		// webappがOpenAPIのドキュメントを提供していれば、以降のレスポンスをそのスキーマでも検証する
+		if err := initClient.LoadOpenAPISchemas(ctx); err != nil {
+			if errors.Is(err, isupipe.ErrOpenAPIUnavailable) {
+				lgr.Info("OpenAPIのドキュメントが提供されていないため、スキーマによるレスポンスの検証を行いません")
+			} else {
+				lgr.Warnf("OpenAPIのドキュメントの読み込みに失敗したため、スキーマによるレスポンスの検証を行いません: %s", err.Error())
+			}
+		} else {
+			lgr.Info("OpenAPIのドキュメントのスキーマによるレスポンスの検証を行います")
+		}
+
 		contestantLogger.Info("ベンチマーク走行前のデータ整合性チェックを行います")
+		progress.setPhase(phasePretest)
 
 		// NOTE: pretestにはこれら初期化が必要
 		benchscore.InitCounter(ctx)
 		bencherror.InitErrors(ctx)
-		if err := scenario.Pretest(ctx, contestantLogger, pretestDNSResolver); err != nil {
+		if err := scenario.Pretest(ctx, contestantLogger, pretestDNSResolver, initialData); err != nil {
 			bencherror.Done()
 			dumpFailedResult([]string{"整合性チェックに失敗しました", err.Error()})
 			return nil
@@ -239,17 +402,22 @@ var run = cli.Command{
 		}
 
 		contestantLogger.Info("ベンチマーク走行を開始します")
+		progress.setPhase(phaseLoad)
 		benchStartAt := time.Now()
 
 		// NOTE: benchmarkにはこれら初期化が必要
 		benchscore.InitCounter(ctx)
 		bencherror.InitErrors(ctx)
+		benchmodel.Reset()
 
-		benchCtx, cancelBench := context.WithTimeout(ctx, config.DefaultBenchmarkTimeout)
+		benchCtx, cancelBench := context.WithTimeout(ctx, config.BenchmarkDuration)
 		defer cancelBench()
+		// 走行の終了後も、送信済みのリクエストはLoadDrainTimeoutまでレスポンスを待つので、クライアントなどのプールはそれまで動かしておく
+		drainCtx, cancelDrain := context.WithTimeout(ctx, config.BenchmarkDuration+config.LoadDrainTimeout)
+		defer cancelDrain()
 
-		benchmarker := newBenchmarker(benchCtx, contestantLogger)
-		if err := benchmarker.run(benchCtx); err != nil {
+		benchmarker := newBenchmarker(drainCtx, contestantLogger)
+		if err := benchmarker.run(benchCtx, drainCtx); err != nil {
 			lgr.Warnf("ベンチマーク中断: %s", err.Error())
 			bencherror.Done()
 			dumpFailedResult([]string{"ベンチマーク走行が中断されました", err.Error()})
@@ -259,11 +427,26 @@ var run = cli.Command{
 		benchElapsed := time.Since(benchStartAt)
 		lgr.Infof("ベンチマーク走行時間: %s", benchElapsed.String())
 
+		// 走行後のチェックのリクエストが混ざらないよう、ここで集計しておく
+		latencyReport := newLatencyReport(benchElapsed)
+
+		contestantLogger.Info("ベンチマーク走行後のデータ整合性チェックを行います")
+		progress.setPhase(phaseConsistency)
+		consistencyDNSResolver := resolver.NewDNSResolver()
+		consistencyDNSResolver.ResolveAttempts = 10
+		if err := scenario.ConsistencyScenario(ctx, contestantLogger, consistencyDNSResolver); err != nil {
+			bencherror.Done()
+			dumpFailedResult([]string{"ベンチマーク走行後のデータ整合性チェックに失敗しました", err.Error()})
+			return nil
+		}
+		contestantLogger.Info("ベンチマーク走行後のデータ整合性チェックが成功しました")
+
 		benchscore.DoneCounter()
 		bencherror.Done()
 		contestantLogger.Info("ベンチマーク走行終了")
 
 		contestantLogger.Info("最終チェックを実施します")
+		progress.setPhase(phaseFinalcheck)
 		finalcheckDNSResolver := resolver.NewDNSResolver()
 		finalcheckDNSResolver.ResolveAttempts = 10
 		if err := scenario.FinalcheckScenario(ctx, contestantLogger, finalcheckDNSResolver); err != nil {
@@ -272,6 +455,7 @@ var run = cli.Command{
 		}
 		contestantLogger.Info("最終チェックが成功しました")
 		contestantLogger.Info("重複排除したログを以下に出力します")
+		progress.setPhase(phaseFinished)
 
 		// ベンチマーク処理のエラー収集
 		lgr.Info("ベンチエラーを収集します")
@@ -297,6 +481,21 @@ var run = cli.Command{
 			contestantLogger.Warn("システム内部エラーが発生しました。運営にジョブIDとともに連絡お願いいたします")
 		}
 
+		errorSummary := bencherror.GetSummary()
+		lgr.Infof("エラー: %s", errorSummary)
+		contestantLogger.Info("エラー件数",
+			zap.Int64("critical", errorSummary.Critical),
+			zap.Int64("application", errorSummary.Application),
+			zap.Int64("timeout", errorSummary.Timeout),
+			zap.Int64("internal", errorSummary.Internal),
+			zap.Int64("requests", errorSummary.Requests),
+		)
+		if err := errorSummary.CheckFailureBudget(config.ErrorRateThresholdPercentage); err != nil {
+			lgr.Warnf("エラー率による失格: %s", err.Error())
+			dumpFailedResult([]string{"エラーが多すぎるため失格となりました", err.Error()})
+			return nil
+		}
+
 		var msgs []string
 		lgr.Info("シナリオカウンタを出力します")
 		scenarioCounter := benchmarker.ScenarioCounter()
@@ -329,21 +528,70 @@ var run = cli.Command{
 		lgr.Infof("DNSAttacker並列数: %d", benchmarker.attackParallelis)
 		lgr.Infof("名前解決成功数: %d", numResolves)
 		lgr.Infof("名前解決失敗数: %d", numDNSFailed)
+		if p50, ok := benchscore.DNSLatencyPercentile(50); ok {
+			p99, _ := benchscore.DNSLatencyPercentile(99)
+			msgs = append(msgs, fmt.Sprintf("名前解決応答時間 p50=%s p99=%s", p50, p99))
+			lgr.Infof("名前解決応答時間: p50=%s p99=%s", p50, p99)
+		}
+		numNXDomain := benchscore.GetByTag(benchscore.DNSNXDomain)
+		numDNSWrongAnswer := benchscore.GetByTag(benchscore.DNSWrongAnswer)
+		lgr.Infof("存在しない名前への応答: NXDOMAIN %d, 誤答 %d", numNXDomain, numDNSWrongAnswer)
+		if numDNSWrongAnswer > 0 {
+			msgs = append(msgs, fmt.Sprintf("存在しない名前に対して、NXDOMAIN以外の応答が %d 件ありました", numDNSWrongAnswer))
+		}
 
-		profit := benchscore.GetTotalProfit()
-		msgs = append(msgs, fmt.Sprintf("売上: %d", profit))
-		lgr.Infof("スコア: %d", profit)
+		if config.EnableWebSocketViewer {
+			numDelivered := benchscore.GetByTag(benchscore.LiveEventDelivered)
+			numMissed := benchscore.GetByTag(benchscore.LiveEventMissed)
+			lgr.Infof("WebSocketで届いたライブコメント: 成功 %d, 失敗 %d", numDelivered, numMissed)
+			if numMissed > 0 {
+				msgs = append(msgs, fmt.Sprintf("投稿したライブコメントがWebSocketで届かなかったことが %d 回ありました", numMissed))
+			}
+		}
+
+		breakdown := benchscore.CalculateScore(errorSummary.Deductions()*config.ErrorPenalty, int64(config.SlowResponsePenalty))
+		msgs = append(msgs, fmt.Sprintf("売上: %d", breakdown.Profit))
+		msgs = append(msgs, fmt.Sprintf("リクエストによる加点: %d", breakdown.Points))
+		if breakdown.Deduction > 0 {
+			msgs = append(msgs, fmt.Sprintf("エラーによる減点: %d (一般エラー %d件, タイムアウト %d件)", breakdown.Deduction, errorSummary.Application, errorSummary.Timeout))
+		}
+		if breakdown.LatencyPenalty > 0 {
+			msgs = append(msgs, fmt.Sprintf("遅いレスポンスによる減点: %d (%s を超えたリクエスト %d件)", breakdown.LatencyPenalty, config.SlowResponseThreshold, breakdown.SlowResponses))
+		}
+		actionNames := make([]string, 0, len(breakdown.Actions))
+		for name := range breakdown.Actions {
+			actionNames = append(actionNames, name)
+		}
+		slices.Sort(actionNames)
+		for _, name := range actionNames {
+			lgr.Infof("[加点 %s] %d 回成功", name, breakdown.Actions[name])
+		}
+		latencyReport.log(lgr)
+		if config.LatencyReportPath != "" {
+			if err := latencyReport.write(config.LatencyReportPath); err != nil {
+				lgr.Warnf("レスポンスタイムの集計の書き出しに失敗しました: %s", err.Error())
+			}
+		}
+		lgr.Infof("スコア: %d (加点 %d, 売上 %d, 減点 %d, 遅延による減点 %d)", breakdown.Total, breakdown.Points, breakdown.Profit, breakdown.Deduction, breakdown.LatencyPenalty)
+
+		messages := append(benchErrors, msgs...)
+		runReport := newRunReport(&breakdown, errorSummary, messages, latencyReport, benchmarker.ScoreTimeline(), scenarioCounter)
+		if err := writeRunReport(runReport); err != nil {
+			lgr.Warn(err.Error())
+		}
 
 		b, err := json.Marshal(&BenchResult{
-			Pass:          true,
-			Score:         int64(profit),
-			Messages:      append(benchErrors, msgs...),
-			Language:      config.Language,
-			ResolvedCount: numResolves,
+			Pass:           true,
+			Score:          breakdown.Total,
+			Messages:       messages,
+			Language:       config.Language,
+			ResolvedCount:  numResolves,
+			ScoreBreakdown: &breakdown,
 		})
 		if err != nil {
 			return cli.NewExitError(err, 1)
 		}
+		fmt.Println(string(b))
 
 		if err := os.WriteFile(config.ResultPath, b, os.ModePerm); err != nil {
 			return cli.NewExitError(err, 1)
@@ -352,3 +600,14 @@ var run = cli.Command{
 		return nil
 	},
 }
+
+// pretest は、runと同じフラグで初期化処理と整合性チェックのみを行います
+var pretest = cli.Command{
+	Name:  "pretest",
+	Usage: "初期化処理と整合性チェックのみ実行 (run --pretest-only と同じ)",
+	Flags: run.Flags,
+	Action: func(cliCtx *cli.Context) error {
+		pretestOnly = true
+		return cli.HandleAction(run.Action, cliCtx)
+	},
+}
@@ -6,13 +6,16 @@ import (
 	"math"
 	"net"
 	"net/http"
+	"slices"
 	"sync"
 	"time"
 
-	"github.com/isucon/isucandar/agent"
 	"github.com/isucon/isucandar/score"
+	"github.com/isucon/isucon13/bench/internal/bencherror"
 	"github.com/isucon/isucon13/bench/internal/benchscore"
 	"github.com/isucon/isucon13/bench/internal/config"
+	"github.com/isucon/isucon13/bench/internal/report"
+	"github.com/isucon/isucon13/bench/internal/resolver"
 	"github.com/isucon/isucon13/bench/internal/scheduler"
 	"github.com/isucon/isucon13/bench/isupipe"
 	"github.com/isucon/isucon13/bench/scenario"
@@ -21,20 +24,10 @@ import (
 	"golang.org/x/time/rate"
 )
 
+// 負荷走行のシナリオのタグは、scenario.Registerした名前を使います
 var (
-	DnsWaterTortureAttackScenario          score.ScoreTag = "dns-watertorture-attack"
-	BasicStreamerColdReserve               score.ScoreTag = "streamer-cold-reserve"
-	BasicStreamerColdReserveFail           score.ScoreTag = "streamer-cold-reserve-fail"
-	BasicStreamerModerateScenario          score.ScoreTag = "streamer-moderate"
-	BasicStreamerModerateScenarioFail      score.ScoreTag = "streamer-moderate-fail"
-	BasicViewerScenario                    score.ScoreTag = "viewer"
-	BasicViewerScenarioFail                score.ScoreTag = "viewer-fail"
-	BasicViewerReportScenario              score.ScoreTag = "viewer-report"
-	BasicViewerReportScenarioFail          score.ScoreTag = "viewer-report-fail"
-	ViewerSpamScenario                     score.ScoreTag = "viewer-spam"
-	ViewerSpamScenarioFail                 score.ScoreTag = "viewer-spam-fail"
-	AggressiveStreamerModerateScenario     score.ScoreTag = "aggressive-streamer-moderate"
-	AggressiveStreamerModerateScenarioFail score.ScoreTag = "aggressive-streamer-moderate-fail"
+	DnsWaterTortureAttackScenario score.ScoreTag = "dns-watertorture-attack"
+	BasicViewerScenario           score.ScoreTag = "viewer"
 )
 
 type LoginCounter struct {
@@ -67,11 +60,6 @@ func (c *LoginCounter) WaitUntil(threshold uint64) chan struct{} {
 type benchmarker struct {
 	contestantLogger *zap.Logger
 
-	streamerSem      *semaphore.Weighted
-	moderatorSem     *semaphore.Weighted
-	viewerSem        *semaphore.Weighted
-	viewerReportSem  *semaphore.Weighted
-	spammerSem       *semaphore.Weighted
 	attackSem        *semaphore.Weighted
 	attackParallelis int
 
@@ -89,11 +77,26 @@ type benchmarker struct {
 
 	spamPool *isupipe.LivecommentPool
 
+	// 仮想ユーザごとにセッションを分けつつ、接続は全員で共有する
+	agentFactory       *isupipe.AgentFactory
+	viewerIdentityPool *isupipe.IdentityPool
+
+	scenarioEnv     *scenario.Env
 	scenarioCounter *score.Score
 
+	// レポートに載せるスコアの推移
+	timelineMu sync.Mutex
+	timeline   []report.TimelinePoint
+
 	startAt time.Time
 }
 
+// スコアの推移を記録する間隔
+const timelineInterval = 1 * time.Second
+
+// 走らせられるシナリオがないときに、ワーカーが待つ間隔
+const loadIdleInterval = 100 * time.Millisecond
+
 func powWeightSize(m int) int64 {
 	return int64(math.Pow(2, float64(m)))
 }
@@ -111,23 +114,12 @@ func newBenchmarker(ctx context.Context, contestantLogger *zap.Logger) *benchmar
 
 	spamPool := isupipe.NewLivecommentPool(ctx)
 
-	counter := score.NewScore(ctx)
-	counter.Set(DnsWaterTortureAttackScenario, 1)
-	counter.Set(BasicStreamerColdReserve, 1)
-	counter.Set(BasicStreamerModerateScenario, 1)
-	counter.Set(BasicViewerScenario, 1)
-	counter.Set(BasicViewerReportScenario, 1)
-	counter.Set(ViewerSpamScenario, 1)
-	counter.Set(AggressiveStreamerModerateScenario, 1)
+	agentFactory := isupipe.NewAgentFactory(contestantLogger, resolver.NewDNSResolver())
+	viewerIdentityPool := isupipe.NewIdentityPool(ctx)
 
 	return &benchmarker{
 		contestantLogger:       contestantLogger,
-		streamerSem:            semaphore.NewWeighted(weight),
-		moderatorSem:           semaphore.NewWeighted(weight),
-		viewerSem:              semaphore.NewWeighted(weight * 10), // 配信者の10倍視聴者トラフィックがある
-		viewerReportSem:        semaphore.NewWeighted(weight),
-		spammerSem:             semaphore.NewWeighted(weight * 2), // 視聴者の２倍はスパム投稿者が潜んでいる
-		attackSem:              semaphore.NewWeighted(512),        // 攻撃を段階的に大きくする最大値
+		attackSem:              semaphore.NewWeighted(512), // 攻撃を段階的に大きくする最大値
 		attackParallelis:       2,
 		streamerLoginSem:       semaphore.NewWeighted(weight),
 		streamerLoginCounter:   new(LoginCounter),
@@ -138,8 +130,20 @@ func newBenchmarker(ctx context.Context, contestantLogger *zap.Logger) *benchmar
 		viewerClientPool:       viewerClientPool,
 		livestreamPool:         livestreamPool,
 		spamPool:               spamPool,
+		agentFactory:           agentFactory,
+		viewerIdentityPool:     viewerIdentityPool,
 		startAt:                time.Now(),
-		scenarioCounter:        score.NewScore(ctx),
+		scenarioEnv: &scenario.Env{
+			ContestantLogger: contestantLogger,
+			StreamerPool:     streamerClientPool,
+			ViewerPool:       viewerClientPool,
+			LivestreamPool:   livestreamPool,
+			SpamPool:         spamPool,
+
+			AgentFactory:       agentFactory,
+			ViewerIdentityPool: viewerIdentityPool,
+		},
+		scenarioCounter: score.NewScore(ctx),
 	}
 }
 
@@ -147,8 +151,53 @@ func (b *benchmarker) ScenarioCounter() score.ScoreTable {
 	return b.scenarioCounter.Breakdown()
 }
 
+// ScoreTimeline は、負荷走行中に記録したスコアの推移を返します
+func (b *benchmarker) ScoreTimeline() []report.TimelinePoint {
+	b.timelineMu.Lock()
+	defer b.timelineMu.Unlock()
+	return slices.Clone(b.timeline)
+}
+
+// recordTimeline は、ctxがキャンセルされるまで、timelineIntervalごとにその時点のスコアを記録し、進捗を更新します
+func (b *benchmarker) recordTimeline(ctx context.Context, startAt time.Time) {
+	ticker := time.NewTicker(timelineInterval)
+	defer ticker.Stop()
+	var prevRequests int64
+	prevAt := startAt
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			errorSummary := bencherror.GetSummary()
+			breakdown := benchscore.CalculateScore(errorSummary.Deductions()*config.ErrorPenalty, int64(config.SlowResponsePenalty))
+			elapsed := now.Sub(startAt).Seconds()
+			b.timelineMu.Lock()
+			b.timeline = append(b.timeline, report.TimelinePoint{
+				ElapsedSeconds: elapsed,
+				Score:          breakdown.Total,
+				Requests:       errorSummary.Requests,
+				Errors:         errorSummary.Deductions(),
+			})
+			b.timelineMu.Unlock()
+
+			progress.update(Progress{
+				ElapsedSeconds:    elapsed,
+				Score:             breakdown.Total,
+				Requests:          errorSummary.Requests,
+				RequestsPerSecond: float64(errorSummary.Requests-prevRequests) / now.Sub(prevAt).Seconds(),
+				Critical:          errorSummary.Critical,
+				Application:       errorSummary.Application,
+				Timeout:           errorSummary.Timeout,
+			})
+			prevRequests, prevAt = errorSummary.Requests, now
+		}
+	}
+}
+
 func (b *benchmarker) runClientProviders(ctx context.Context) {
-	loginFn := func(p *isupipe.ClientPool, sem *semaphore.Weighted, cnt *LoginCounter) func(u *scheduler.User) {
+	// identityPoolがnilでなければ、登録が済んだユーザを新しいセッションでログインし直せるように供給する
+	loginFn := func(p *isupipe.ClientPool, identityPool *isupipe.IdentityPool, sem *semaphore.Weighted, cnt *LoginCounter) func(u *scheduler.User) {
 		return func(u *scheduler.User) {
 			go func() {
 				if err := sem.Acquire(ctx, 1); err != nil {
@@ -156,47 +205,39 @@ func (b *benchmarker) runClientProviders(ctx context.Context) {
 				}
 				defer sem.Release(1)
 
-				client, err := isupipe.NewClient(b.contestantLogger,
-					agent.WithBaseURL(config.TargetBaseURL),
-				)
-				if err != nil {
-					return
-				}
-
-				if _, err := client.Register(ctx, &isupipe.RegisterRequest{
+				a, err := b.agentFactory.NewAgent(isupipe.Identity{
 					Name:        u.Name,
 					DisplayName: u.DisplayName,
 					Description: u.Description,
 					Password:    u.RawPassword,
-					Theme: isupipe.Theme{
-						DarkMode: true,
-					},
-				}); err != nil {
+					DarkMode:    true,
+				})
+				if err != nil {
 					return
 				}
 
-				if err := client.Login(ctx, &isupipe.LoginRequest{
-					Username: u.Name,
-					Password: u.RawPassword,
-				}); err != nil {
+				if err := a.SignUp(ctx); err != nil {
 					return
 				}
 
 				icon := scheduler.IconSched.GetRandomIcon()
-				if _, err := client.PostIcon(ctx, &isupipe.PostIconRequest{
+				if _, err := a.PostIcon(ctx, &isupipe.PostIconRequest{
 					Image: icon.Image,
 				}); err != nil {
 					return
 				}
 
-				p.Put(ctx, client)
+				p.Put(ctx, a.Client)
+				if identityPool != nil {
+					identityPool.Put(ctx, a.Identity)
+				}
 				cnt.Inc()
 			}()
 		}
 	}
 
-	scheduler.UserScheduler.RangeStreamer(loginFn(b.streamerClientPool, b.streamerLoginSem, b.streamerLoginCounter))
-	scheduler.UserScheduler.RangeViewer(loginFn(b.viewerClientPool, b.viewerLoginSem, b.viewerLoginCounter))
+	scheduler.UserScheduler.RangeStreamer(loginFn(b.streamerClientPool, nil, b.streamerLoginSem, b.streamerLoginCounter))
+	scheduler.UserScheduler.RangeViewer(loginFn(b.viewerClientPool, b.viewerIdentityPool, b.viewerLoginSem, b.viewerLoginCounter))
 
 	<-b.streamerLoginCounter.WaitUntil(config.NumMustTryLogins)
 	<-b.viewerLoginCounter.WaitUntil(config.NumMustTryLogins)
@@ -265,84 +306,87 @@ loop:
 	}
 }
 
-func (b *benchmarker) loadStreamer(ctx context.Context) error {
-	defer b.streamerSem.Release(1)
-
-	if err := scenario.BasicStreamerColdReserveScenario(ctx, b.contestantLogger, b.streamerClientPool, b.livestreamPool); err != nil {
-		b.scenarioCounter.Add(BasicStreamerColdReserveFail)
+// runLoadScenario は、シナリオを1回走らせ、成否をシナリオカウンタに数えます
+func (b *benchmarker) runLoadScenario(ctx context.Context, def scenario.Definition) error {
+	if err := def.Run(ctx, b.scenarioEnv); err != nil {
+		b.scenarioCounter.Add(score.ScoreTag(def.Name + "-fail"))
 		return err
 	}
-	b.scenarioCounter.Add(BasicStreamerColdReserve)
-
+	b.scenarioCounter.Add(score.ScoreTag(def.Name))
 	return nil
 }
 
-// moderateが成功するなら可能な限り高速にmoderationしなければならない
-func (b *benchmarker) loadModerator(ctx context.Context) error {
-	defer b.moderatorSem.Release(1)
-
-	if err := scenario.BasicStreamerModerateScenario(ctx, b.contestantLogger, b.streamerClientPool); err != nil {
-		b.scenarioCounter.Add(BasicStreamerModerateScenarioFail)
-		return err
-	}
-	b.scenarioCounter.Add(BasicStreamerModerateScenario)
-	return nil
-}
+// load は、parallelism個のワーカーで、registryから重みに応じて選んだシナリオを繰り返し走らせます
+// ワーカーはrampUpをかけて線形に増やします。ctxがキャンセルされたら新しくシナリオもリクエストも始めず、走っているシナリオが終わるのを待って返ります
+// 送信済みのリクエストは、drainCtxが終わるまでレスポンスを待ちます
+func (b *benchmarker) load(ctx context.Context, drainCtx context.Context, registry *scenario.Registry, parallelism int, rampUp time.Duration) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
 
-func (b *benchmarker) loadViewer(ctx context.Context) error {
-	defer b.viewerSem.Release(1)
+	for i := 0; i < parallelism; i++ {
+		delay := rampUp * time.Duration(i) / time.Duration(parallelism)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			timer := time.NewTimer(delay)
+			defer timer.Stop()
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
 
-	if err := scenario.BasicViewerScenario(ctx, b.contestantLogger, b.viewerClientPool, b.livestreamPool); err != nil {
-		b.scenarioCounter.Add(BasicViewerScenarioFail)
-		return err
+			// 加点はワーカーごとに割り当てたシャードで数える
+			ctx := isupipe.WithDrainContext(benchscore.WithShard(ctx), drainCtx)
+			for ctx.Err() == nil {
+				def, ok := registry.Pick(b.scenarioEnv)
+				if !ok {
+					// ログインや配信の予約が済むまで、走らせられるシナリオがない
+					select {
+					case <-ctx.Done():
+					case <-time.After(loadIdleInterval):
+					}
+					continue
+				}
+				b.runLoadScenario(ctx, def)
+			}
+		}()
 	}
-	b.scenarioCounter.Add(BasicViewerScenario)
-	return nil
 }
 
-func (b *benchmarker) loadViewerReport(ctx context.Context) error {
-	defer b.viewerReportSem.Release(1)
-
-	time.Sleep(1 * time.Second) // XXX: report回りすぎ抑止
-	if err := scenario.BasicViewerReportScenario(ctx, b.contestantLogger, b.viewerClientPool, b.spamPool); err != nil {
-		b.scenarioCounter.Add(BasicViewerReportScenarioFail)
-		return err
-	}
-	b.scenarioCounter.Add(BasicViewerReportScenario)
-	return nil
+// drain は、送信済みのリクエストを待って走っているシナリオが終わるのを待ち、その間に仕様違反が起きていないかを確かめます
+func (b *benchmarker) drain(wg *sync.WaitGroup) error {
+	b.contestantLogger.Info("ベンチマーク走行を停止します。送信済みのリクエストが終わるのを待ちます", zap.Duration("timeout", config.LoadDrainTimeout))
+	progress.setPhase(phaseDrain)
+	wg.Wait()
+	return bencherror.CheckViolation()
 }
 
-func (b *benchmarker) loadSpammer(ctx context.Context) error {
-	defer b.spammerSem.Release(1)
-
-	var spammerGrp sync.WaitGroup
-
-	spammerGrp.Add(1)
-	go func() {
-		defer spammerGrp.Done()
-		if err := scenario.ViewerSpamScenario(ctx, b.contestantLogger, b.viewerClientPool, b.livestreamPool, b.spamPool); err != nil {
-			b.scenarioCounter.Add(ViewerSpamScenarioFail)
-			return
-		}
-		b.scenarioCounter.Add(ViewerSpamScenario)
-	}()
+// runAttackers は、DNS水責めの並列数だけ攻撃を走らせ続けます
+func (b *benchmarker) runAttackers(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
 
-	spammerGrp.Add(1)
-	go func() {
-		defer spammerGrp.Done()
-		if err := scenario.AggressiveStreamerModerateScenario(ctx, b.contestantLogger, b.streamerClientPool); err != nil {
-			b.scenarioCounter.Add(AggressiveStreamerModerateScenarioFail)
+	loadAttackHTTPClient := b.loadAttackHTTPClient()
+	loadAttackLimiter := rate.NewLimiter(rate.Limit(3000), 1)
+	for {
+		asize := int64(512.0 / float64(b.attackParallelis))
+		if err := b.attackSem.Acquire(ctx, asize); err != nil {
 			return
 		}
-		b.scenarioCounter.Add(AggressiveStreamerModerateScenario)
-	}()
-
-	spammerGrp.Wait()
-
-	return nil
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.loadAttack(ctx, asize, loadAttackHTTPClient, loadAttackLimiter)
+		}()
+	}
 }
 
-func (b *benchmarker) run(ctx context.Context) error {
+// run は、ctxがキャンセルされるまで負荷走行を行います
+// ctxがキャンセルされるまでに送ったリクエストは、drainCtxの締切までレスポンスを待ち、成功したものを加点します
+// 仕様違反で打ち切るときは、送信済みのリクエストも待たずに止めます
+func (b *benchmarker) run(ctx context.Context, drainCtx context.Context) error {
 	lgr := zap.S()
 
 	var wg sync.WaitGroup
@@ -350,69 +394,36 @@ func (b *benchmarker) run(ctx context.Context) error {
 
 	childCtx, cancelChildCtx := context.WithCancel(ctx)
 	defer cancelChildCtx()
+	drainCtx, cancelDrain := context.WithCancel(drainCtx)
+	defer cancelDrain()
 
 	b.runClientProviders(ctx)
 
-	violateCh := make(chan error) // とめておく bencherror.RunViolationChecker(ctx)
+	// 仕様違反・ベンチ本体のエラーが起きたら、その時点で走行を打ち切る
+	violateCh := bencherror.RunViolationChecker(ctx)
 
-	loadAttackHTTPClient := b.loadAttackHTTPClient()
-	loadAttackLimiter := rate.NewLimiter(rate.Limit(3000), 1)
 	go func() { b.loadAttackCoordinator(ctx) }()
+	go func() { b.recordTimeline(ctx, time.Now()) }()
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		b.runAttackers(childCtx)
+	}()
+	go func() {
+		defer wg.Done()
+		b.load(childCtx, drainCtx, scenario.DefaultRegistry, config.LoadParallelism, config.LoadRampUpDuration)
+	}()
 
-	for {
-		select {
-		case <-ctx.Done():
-			b.contestantLogger.Info("ベンチマーク走行を停止します")
-			return nil
-		case err := <-violateCh:
-			b.contestantLogger.Warn("仕様違反が検出されたため、ベンチマーク走行を中断します")
-			lgr.Warnf("仕様違反エラー: %s", err.Error())
-			return err
-		default:
-			if ok := b.streamerSem.TryAcquire(1); ok {
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					b.loadStreamer(childCtx)
-				}()
-			}
-			if ok := b.viewerSem.TryAcquire(1); ok {
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					b.loadViewer(childCtx)
-				}()
-			}
-			if ok := b.viewerReportSem.TryAcquire(1); ok {
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					b.loadViewerReport(childCtx)
-				}()
-			}
-			if ok := b.moderatorSem.TryAcquire(1); ok {
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					b.loadModerator(childCtx)
-				}()
-			}
-			if ok := b.spammerSem.TryAcquire(1); ok {
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					b.loadSpammer(childCtx)
-				}()
-			}
-			asize := int64(512.0 / float64(b.attackParallelis))
-			if ok := b.attackSem.TryAcquire(asize); ok {
-				wg.Add(1)
-				asize := asize
-				go func() {
-					defer wg.Done()
-					b.loadAttack(childCtx, asize, loadAttackHTTPClient, loadAttackLimiter)
-				}()
-			}
+	select {
+	case <-ctx.Done():
+		return b.drain(&wg)
+	case err := <-violateCh:
+		if err == nil {
+			// 走行の終了とともにチェッカーが止まった
+			return b.drain(&wg)
 		}
+		b.contestantLogger.Warn("仕様違反が検出されたため、ベンチマーク走行を中断します")
+		lgr.Warnf("仕様違反エラー: %s", err.Error())
+		return err
 	}
 }
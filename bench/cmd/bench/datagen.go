@@ -0,0 +1,24 @@
+package main
+
+import (
+	"github.com/isucon/isucon13/bench/internal/datagen"
+	"github.com/urfave/cli"
+)
+
+var (
+	datagenOpts   datagen.Options
+	datagenOutDir string
+)
+
+// datagenCommand は、isupipedatagen と同じ初期データを生成します
+var datagenCommand = cli.Command{
+	Name:  "datagen",
+	Usage: "初期データをSQLダンプとマニフェストとして生成 (isupipedatagen と同じ)",
+	Flags: datagen.Flags(&datagenOpts, &datagenOutDir),
+	Action: func(cliCtx *cli.Context) error {
+		if err := datagen.Run(datagenOpts, datagenOutDir); err != nil {
+			return cli.NewExitError(err, 1)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/isucon/isucon13/bench/internal/benchscore"
+	"go.uber.org/zap"
+)
+
+// LatencyReport は、負荷走行でのエンドポイントごとのレスポンスタイムの集計です
+// --result-json で指定したパスに書き出します
+type LatencyReport struct {
+	DurationSeconds float64                      `json:"duration_seconds"`
+	Endpoints       []benchscore.EndpointLatency `json:"endpoints"`
+}
+
+func newLatencyReport(elapsed time.Duration) *LatencyReport {
+	return &LatencyReport{
+		DurationSeconds: elapsed.Seconds(),
+		Endpoints:       benchscore.EndpointLatencies(elapsed),
+	}
+}
+
+// log は、エンドポイントごとのレスポンスタイムを1行ずつ出力します
+func (r *LatencyReport) log(lgr *zap.SugaredLogger) {
+	for _, e := range r.Endpoints {
+		lgr.Infof("[レスポンスタイム %s] %d 件 (%.1f req/s, 失敗 %d 件) p50=%.1fms p95=%.1fms p99=%.1fms max=%.1fms",
+			e.Endpoint, e.Count, e.Throughput, e.Failures, e.P50Millis, e.P95Millis, e.P99Millis, e.MaxMillis)
+	}
+}
+
+func (r *LatencyReport) write(path string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, os.ModePerm)
+}
@@ -27,6 +27,9 @@ func cliMain() int {
 
 	app.Commands = []cli.Command{
 		run,
+		pretest,
+		datagenCommand,
+		reportCommand,
 		supervise,
 	}
 
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 走行の段階
+const (
+	phaseInitialize  = "initialize"
+	phasePretest     = "pretest"
+	phaseLoad        = "load"
+	phaseDrain       = "drain"
+	phaseConsistency = "consistency"
+	phaseFinalcheck  = "finalcheck"
+	phaseFinished    = "finished"
+)
+
+// Progress は、走行中の進捗です
+// --status-addr を指定すると、/status でJSONとして取得できます
+type Progress struct {
+	Phase             string  `json:"phase"`
+	ElapsedSeconds    float64 `json:"elapsed_seconds"`
+	Score             int64   `json:"score"`
+	Requests          int64   `json:"requests"`
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Critical          int64   `json:"critical"`
+	Application       int64   `json:"application"`
+	Timeout           int64   `json:"timeout"`
+}
+
+type progressReporter struct {
+	mu      sync.RWMutex
+	current Progress
+}
+
+var progress = &progressReporter{current: Progress{Phase: phaseInitialize}}
+
+func (p *progressReporter) setPhase(phase string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current.Phase = phase
+}
+
+// update は、負荷走行中の進捗を更新し、--quiet でなければ1行出力します
+func (p *progressReporter) update(next Progress) {
+	p.mu.Lock()
+	next.Phase = p.current.Phase
+	p.current = next
+	p.mu.Unlock()
+
+	if !quiet {
+		zap.S().Infof("[進捗] %.0f秒経過 スコア %d, %.1f req/s, 仕様違反 %d件, 一般エラー %d件, タイムアウト %d件",
+			next.ElapsedSeconds, next.Score, next.RequestsPerSecond, next.Critical, next.Application, next.Timeout)
+	}
+}
+
+func (p *progressReporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.RLock()
+	current := p.current
+	p.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(&current)
+}
+
+// startStatusServer は、addrで進捗を返すHTTPサーバを立ち上げます
+// ダッシュボードなどから走行中にポーリングするためのもので、失敗してもベンチマーク走行は続けます
+func startStatusServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/status", progress)
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			zap.S().Warnf("進捗を返すHTTPサーバを起動できません: %s", err.Error())
+		}
+	}()
+	return server
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/isucon/isucandar/score"
+	"github.com/isucon/isucon13/bench/internal/bencherror"
+	"github.com/isucon/isucon13/bench/internal/benchscore"
+	"github.com/isucon/isucon13/bench/internal/config"
+	"github.com/isucon/isucon13/bench/internal/report"
+	"github.com/urfave/cli"
+)
+
+var (
+	reportInputPath  string
+	reportOutputPath string
+)
+
+// reportCommand は、--report-path でJSONとして保存したレポートを、HTMLやMarkdownに描き直します
+var reportCommand = cli.Command{
+	Name:  "report",
+	Usage: "保存したレポート (JSON) をHTML・Markdownに変換",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:        "input",
+			Usage:       "run --report-path で書き出したJSONのレポートのパス",
+			Destination: &reportInputPath,
+		},
+		cli.StringFlag{
+			Name:        "output",
+			Value:       "report.html",
+			Usage:       "書き出すパス。拡張子が .md ならMarkdown、それ以外はHTMLにする",
+			Destination: &reportOutputPath,
+		},
+	},
+	Action: func(cliCtx *cli.Context) error {
+		if reportInputPath == "" {
+			return cli.NewExitError("--input を指定してください", 1)
+		}
+		r, err := report.Load(reportInputPath)
+		if err != nil {
+			return cli.NewExitError(err, 1)
+		}
+		if err := r.WriteFile(reportOutputPath); err != nil {
+			return cli.NewExitError(err, 1)
+		}
+		return nil
+	},
+}
+
+// newRunReport は、負荷走行の結果からレポートを組み立てます
+func newRunReport(
+	breakdown *benchscore.Breakdown,
+	errorSummary bencherror.Summary,
+	messages []string,
+	latencyReport *LatencyReport,
+	timeline []report.TimelinePoint,
+	scenarioCounter score.ScoreTable,
+) *report.Report {
+	return &report.Report{
+		GeneratedAt:     time.Now(),
+		Target:          config.TargetBaseURL,
+		Language:        config.Language,
+		Pass:            true,
+		Score:           breakdown.Total,
+		DurationSeconds: latencyReport.DurationSeconds,
+		Breakdown:       breakdown,
+		Timeline:        timeline,
+		Errors: report.ErrorSummary{
+			Critical:    errorSummary.Critical,
+			Application: errorSummary.Application,
+			Timeout:     errorSummary.Timeout,
+			Internal:    errorSummary.Internal,
+			Requests:    errorSummary.Requests,
+			ErrorRate:   errorSummary.ErrorRate(),
+		},
+		Messages:  messages,
+		Latencies: latencyReport.Endpoints,
+		Scenarios: scenarioCounts(scenarioCounter),
+	}
+}
+
+// scenarioCounts は、シナリオカウンタを成功・失敗の組にして名前順に返します
+func scenarioCounts(scenarioCounter score.ScoreTable) []report.ScenarioCount {
+	counts := map[string]*report.ScenarioCount{}
+	for tag, count := range scenarioCounter {
+		name, failed := strings.CutSuffix(string(tag), "-fail")
+		c, ok := counts[name]
+		if !ok {
+			c = &report.ScenarioCount{Name: name}
+			counts[name] = c
+		}
+		if failed {
+			c.Fail += count
+		} else {
+			c.Success += count
+		}
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	results := make([]report.ScenarioCount, 0, len(names))
+	for _, name := range names {
+		results = append(results, *counts[name])
+	}
+	return results
+}
+
+// writeRunReport は、--report-path が指定されていればレポートを書き出します
+func writeRunReport(r *report.Report) error {
+	if config.ReportPath == "" {
+		return nil
+	}
+	if err := r.WriteFile(config.ReportPath); err != nil {
+		return fmt.Errorf("レポートを書き出せません: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+// isupipedatagen は、isupipeの初期データを生成します
+// 生成の中身は internal/datagen にあり、isupipebench datagen からも同じように生成できます
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/isucon/isucon13/bench/internal/datagen"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	time.Local = time.UTC
+}
+
+func main() {
+	os.Exit(cliMain())
+}
+
+func cliMain() int {
+	var (
+		outDir string
+		opts   datagen.Options
+	)
+
+	app := cli.NewApp()
+	app.Name = "isupipedatagen"
+	app.Usage = "isupipe 初期データ生成"
+	app.Description = "isupipeの初期データをSQLダンプとマニフェストとして生成"
+	app.HelpName = "isupipedatagen"
+
+	app.Flags = datagen.Flags(&opts, &outDir)
+
+	app.Action = func(cliCtx *cli.Context) error {
+		if err := datagen.Run(opts, outDir); err != nil {
+			return cli.NewExitError(err, 1)
+		}
+		return nil
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		exitErr := err.(*cli.ExitError)
+		log.Println(exitErr.Error())
+		return exitErr.ExitCode()
+	}
+
+	return 0
+}
@@ -7,6 +7,7 @@ require (
 	github.com/biogo/store v0.0.0-20201120204734-aad293a2328f
 	github.com/eapache/go-resiliency v1.4.0
 	github.com/go-playground/validator/v10 v10.16.0
+	github.com/gorilla/websocket v1.2.0
 	github.com/hashicorp/golang-lru/v2 v2.0.7
 	github.com/isucon/isucandar v0.0.0-20220322062028-6dd56dc57d72
 	github.com/miekg/dns v1.1.56
@@ -29,7 +30,6 @@ require (
 	github.com/gabriel-vasile/mimetype v1.4.2 // indirect
 	github.com/go-playground/locales v0.14.1 // indirect
 	github.com/go-playground/universal-translator v0.18.1 // indirect
-	github.com/gorilla/websocket v1.2.0 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	github.com/kr/pretty v0.2.1 // indirect
 	github.com/leodido/go-urn v1.2.4 // indirect
@@ -147,7 +147,7 @@ func (a *DnsWaterTortureAttacker) lookup(ctx context.Context, name string) net.I
 	msg.RecursionDesired = false
 
 	a.numRequestPerConnection++
-	in, _, err := a.dnsClient.ExchangeWithConn(msg, a.dnsConn)
+	in, rtt, err := a.dnsClient.ExchangeWithConn(msg, a.dnsConn)
 	if err != nil {
 		a.dnsConn.Close()
 		a.connected = false
@@ -161,6 +161,16 @@ func (a *DnsWaterTortureAttacker) lookup(ctx context.Context, name string) net.I
 	}
 	// プロトコル上成功をカウントする
 	benchscore.IncResolves()
+	benchscore.ObserveDNSLatency(rtt)
+
+	// ランダムな名前は存在しないので、NXDOMAINが正しい応答
+	// NOERRORはワイルドカードレコードなどで存在しない名前を解決してしまっている
+	switch in.Rcode {
+	case dns.RcodeNameError:
+		benchscore.IncNXDomain()
+	case dns.RcodeSuccess:
+		benchscore.IncDNSWrongAnswer()
+	}
 
 	for _, ans := range in.Answer {
 		if record, ok := ans.(*dns.A); ok {
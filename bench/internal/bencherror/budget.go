@@ -0,0 +1,79 @@
+package bencherror
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+var ErrFailureBudgetExceeded = errors.New("エラー率が許容範囲を超えました")
+
+// 送ったリクエストの数。エラー率の分母に使います
+var numRequests int64
+
+// IncRequests は、webappへリクエストを1回送ったことを記録します
+func IncRequests() {
+	atomic.AddInt64(&numRequests, 1)
+}
+
+// NumRequests は、記録したリクエストの数を返します
+func NumRequests() int64 {
+	return atomic.LoadInt64(&numRequests)
+}
+
+// Summary は、エラーを分類ごとに数えたものです
+type Summary struct {
+	// 仕様違反 (fail)
+	Critical int64
+	// 一般エラー (減点)
+	Application int64
+	// タイムアウト (減点)
+	Timeout int64
+	// ベンチマーカー内部のエラー (fail)
+	Internal int64
+
+	Requests int64
+}
+
+// GetSummary は、これまでに記録したエラーを分類ごとに数えます
+func GetSummary() Summary {
+	benchCounts := benchErrors.Count()
+	systemCounts := systemErrors.Count()
+	return Summary{
+		Critical:    benchCounts[string(BenchmarkViolationError)],
+		Application: benchCounts[string(BenchmarkApplicationError)],
+		Timeout:     benchCounts[string(BenchmarkTimeoutError)],
+		Internal:    systemCounts[string(SystemError)],
+		Requests:    NumRequests(),
+	}
+}
+
+// Deductions は、減点の対象となるエラーの数を返します
+func (s Summary) Deductions() int64 {
+	return s.Application + s.Timeout
+}
+
+// ErrorRate は、リクエストのうち減点の対象となるエラーになったものの割合 (%) を返します
+func (s Summary) ErrorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Deductions()) / float64(s.Requests) * 100
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("仕様違反 %d件, 一般エラー %d件, タイムアウト %d件, ベンチ本体のエラー %d件 (リクエスト %d回, エラー率 %.2f%%)",
+		s.Critical, s.Application, s.Timeout, s.Internal, s.Requests, s.ErrorRate())
+}
+
+// CheckFailureBudget は、エラー率がthresholdPercentage (%) を超えていればエラーを返します
+// thresholdPercentageが0以下なら、エラー率では失格にしません
+func (s Summary) CheckFailureBudget(thresholdPercentage float64) error {
+	if thresholdPercentage <= 0 {
+		return nil
+	}
+	if rate := s.ErrorRate(); rate > thresholdPercentage {
+		return fmt.Errorf("エラー率 %.2f%% (許容 %.2f%%): %w", rate, thresholdPercentage, ErrFailureBudgetExceeded)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/isucon/isucandar/failure"
@@ -36,6 +37,7 @@ var (
 func InitErrors(ctx context.Context) {
 	benchErrors = failure.NewErrors(ctx)
 	systemErrors = failure.NewErrors(ctx)
+	atomic.StoreInt64(&numRequests, 0)
 }
 
 func WrapError(code failure.StringCode, err error) error {
@@ -123,6 +125,7 @@ func RunViolationChecker(ctx context.Context) chan error {
 		for {
 			select {
 			case <-ctx.Done():
+				return
 			case <-t.C:
 				if err := CheckViolation(); err != nil {
 					violate <- err
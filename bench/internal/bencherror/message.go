@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 )
 
 // NOTE: Goのhttp.Clientがcontext.DeadlineExceededをラップして返してくれないので、暫定対応
@@ -41,12 +40,6 @@ func NewHttpError(err error, req *http.Request, msg string, args ...interface{})
 	return WrapError(BenchmarkApplicationError, err)
 }
 
-func NewHttpStatusError(req *http.Request, expected int, actual int) error {
-	endpoint := fmt.Sprintf("%s %s", req.Method, req.URL.EscapedPath())
-	err := fmt.Errorf("[一般エラー] %s へのリクエストに対して、期待されたHTTPステータスコードが確認できませんでした (expected:%d, actual:%d)", endpoint, expected, actual)
-	return WrapError(BenchmarkApplicationError, err)
-}
-
 func NewHttpResponseError(err error, req *http.Request) error {
 	endpoint := fmt.Sprintf("%s %s", req.Method, req.URL.EscapedPath())
 	err = fmt.Errorf("[一般エラー] %s へのリクエストに対して、レスポンスボディの形式が不正です: %w", endpoint, err)
@@ -67,8 +60,9 @@ func NewAssertionError(err error, msg string, args ...interface{}) error {
 	return WrapError(BenchmarkViolationError, err)
 }
 
-func NewEmptyHttpResponseError(errorFields []string, req *http.Request) error {
+func NewHttpViolationError(err error, req *http.Request, msg string, args ...interface{}) error {
 	endpoint := fmt.Sprintf("%s %s", req.Method, req.URL.EscapedPath())
-	err := fmt.Errorf("[仕様違反] %s へのリクエストに対して、レスポンスボディに必要なフィールドがありません: %s", endpoint, strings.Join(errorFields, ","))
+	message := fmt.Sprintf(msg, args...)
+	err = fmt.Errorf("[仕様違反] %s へのリクエストに対して、%s: %w", endpoint, message, err)
 	return WrapError(BenchmarkViolationError, err)
 }
@@ -0,0 +1,225 @@
+package benchmodel
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// 負荷走行中にベンチマーカーが行った操作から、webappが持っているはずの状態を組み立てます
+// 走行後の整合性の検証で、webappの統計情報などと突き合わせるのに使います
+// 初期データの影響を受けないよう、負荷走行中に登録したユーザと、そのユーザが予約した配信についてのみ記録します
+
+// Quantity は、webappが持っているはずの値です
+// タイムアウトしたリクエストは反映されたかどうか分からないので、Uncertainだけ値がずれていても構いません
+type Quantity struct {
+	// 成功したリクエストによる値
+	Confirmed int64
+	// タイムアウトしたリクエストによる値の幅
+	Uncertain int64
+}
+
+// Add は、qとotherを足した値を返します
+func (q Quantity) Add(other Quantity) Quantity {
+	return Quantity{
+		Confirmed: q.Confirmed + other.Confirmed,
+		Uncertain: q.Uncertain + other.Uncertain,
+	}
+}
+
+// Min は、webappの値として許容する下限を返します
+// tolerance (割合) とslack (絶対値) は、集計の遅れなどによる誤差として許容する幅です
+func (q Quantity) Min(tolerance float64, slack int64) int64 {
+	return q.Confirmed - q.Uncertain - q.margin(tolerance, slack)
+}
+
+// Max は、webappの値として許容する上限を返します
+func (q Quantity) Max(tolerance float64, slack int64) int64 {
+	return q.Confirmed + q.Uncertain + q.margin(tolerance, slack)
+}
+
+// Contains は、actualが許容する範囲に収まっているかを返します
+func (q Quantity) Contains(actual int64, tolerance float64, slack int64) bool {
+	return q.Min(tolerance, slack) <= actual && actual <= q.Max(tolerance, slack)
+}
+
+func (q Quantity) margin(tolerance float64, slack int64) int64 {
+	return int64(math.Ceil(float64(q.Confirmed)*tolerance)) + slack
+}
+
+type user struct {
+	password    string
+	livestreams []int64
+}
+
+type livestream struct {
+	owner     string
+	reactions Quantity
+	tips      Quantity
+	// 入室中の視聴者ごとの入室回数。退室すると入室した回数によらず消えます
+	viewers map[string]int64
+	// 入室・退室がタイムアウトした回数
+	viewersUncertain int64
+}
+
+var (
+	mu          sync.Mutex
+	users       = make(map[string]*user)
+	livestreams = make(map[int64]*livestream)
+)
+
+// Reset は、記録した状態を破棄します
+// 負荷走行を始める前に呼び出し、整合性チェックで作ったユーザなどを含めないようにします
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	users = make(map[string]*user)
+	livestreams = make(map[int64]*livestream)
+}
+
+// AddUser は、ユーザを登録したことを記録します
+func AddUser(name, password string) {
+	mu.Lock()
+	defer mu.Unlock()
+	users[name] = &user{password: password}
+}
+
+// AddLivestream は、配信を予約したことを記録します
+// 記録していないユーザ (初期データのユーザなど) の配信は記録しません
+func AddLivestream(livestreamID int64, owner string) {
+	mu.Lock()
+	defer mu.Unlock()
+	u, ok := users[owner]
+	if !ok {
+		return
+	}
+	u.livestreams = append(u.livestreams, livestreamID)
+	livestreams[livestreamID] = &livestream{
+		owner:   owner,
+		viewers: make(map[string]int64),
+	}
+}
+
+// AddReaction は、配信にリアクションを投稿したことを記録します
+// confirmedは、リクエストが成功したか (falseならタイムアウトして反映されたか分からない) です
+func AddReaction(livestreamID int64, confirmed bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	if l, ok := livestreams[livestreamID]; ok {
+		l.reactions = l.reactions.Add(quantity(1, confirmed))
+	}
+}
+
+// AddTip は、配信にチップ付きのライブコメントを投稿したことを記録します
+func AddTip(livestreamID int64, tip int64, confirmed bool) {
+	if tip <= 0 {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if l, ok := livestreams[livestreamID]; ok {
+		l.tips = l.tips.Add(quantity(tip, confirmed))
+	}
+}
+
+// Enter は、視聴者が配信に入室したことを記録します
+func Enter(livestreamID int64, viewer string, confirmed bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	l, ok := livestreams[livestreamID]
+	if !ok {
+		return
+	}
+	if !confirmed {
+		l.viewersUncertain++
+		return
+	}
+	l.viewers[viewer]++
+}
+
+// Exit は、視聴者が配信から退室したことを記録します
+func Exit(livestreamID int64, viewer string, confirmed bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	l, ok := livestreams[livestreamID]
+	if !ok {
+		return
+	}
+	if !confirmed {
+		// 退室が反映されていれば、入室した回数だけ視聴者が減る
+		l.viewersUncertain += l.viewers[viewer]
+	}
+	delete(l.viewers, viewer)
+}
+
+func quantity(v int64, confirmed bool) Quantity {
+	if confirmed {
+		return Quantity{Confirmed: v}
+	}
+	return Quantity{Uncertain: v}
+}
+
+// Livestream は、webappが持っているはずの配信の状態です
+type Livestream struct {
+	ID        int64
+	Owner     string
+	Reactions Quantity
+	Tips      Quantity
+	Viewers   Quantity
+}
+
+// User は、webappが持っているはずのユーザの状態です
+// 配信に関する値は、ユーザが予約したすべての配信の合計です
+type User struct {
+	Name        string
+	Password    string
+	Livestreams []Livestream
+	Reactions   Quantity
+	Tips        Quantity
+	Viewers     Quantity
+}
+
+// Score は、ユーザのランキングに使われるスコア (リアクション数とチップの合計) です
+func (u *User) Score() Quantity {
+	return u.Reactions.Add(u.Tips)
+}
+
+// Users は、記録したユーザの状態を名前の順に返します
+func Users() []*User {
+	mu.Lock()
+	defer mu.Unlock()
+
+	result := make([]*User, 0, len(users))
+	for name, u := range users {
+		snapshot := &User{
+			Name:     name,
+			Password: u.password,
+		}
+		for _, livestreamID := range u.livestreams {
+			l := livestreams[livestreamID].snapshot(livestreamID)
+			snapshot.Livestreams = append(snapshot.Livestreams, l)
+			snapshot.Reactions = snapshot.Reactions.Add(l.Reactions)
+			snapshot.Tips = snapshot.Tips.Add(l.Tips)
+			snapshot.Viewers = snapshot.Viewers.Add(l.Viewers)
+		}
+		result = append(result, snapshot)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func (l *livestream) snapshot(livestreamID int64) Livestream {
+	var viewers int64
+	for _, count := range l.viewers {
+		viewers += count
+	}
+	return Livestream{
+		ID:        livestreamID,
+		Owner:     l.owner,
+		Reactions: l.reactions,
+		Tips:      l.tips,
+		Viewers:   Quantity{Confirmed: viewers, Uncertain: l.viewersUncertain},
+	}
+}
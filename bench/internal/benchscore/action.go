@@ -0,0 +1,201 @@
+package benchscore
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Action は、加点の対象となるwebappへのリクエストの種類です
+type Action int
+
+const (
+	// 下記以外の参照系 (GET)
+	ActionGet Action = iota
+	// 下記以外の更新系 (POST, PUT, DELETE)
+	ActionPost
+	// 画像の取得
+	ActionIcon
+	// 配信への入室・退室
+	ActionEnter
+	// ライブコメントの投稿
+	ActionLivecomment
+	// スーパーチャット (チップ付きのライブコメント) の投稿。ActionLivecommentに上乗せして加点します
+	ActionSuperchat
+	// リアクションの投稿
+	ActionReaction
+	// 配信の予約
+	ActionReservation
+	// 報告・NGワードの登録といったモデレーション
+	ActionModerate
+	// スパムの排除 (NGワードを含む投稿の拒否、NGワードの登録による連投の削除)
+	// 拒否はエラーのレスポンスなので、シナリオで確かめてから記録します
+	ActionSpamBlocked
+
+	numActions
+)
+
+var actionNames = [numActions]string{
+	ActionGet:         "get",
+	ActionPost:        "post",
+	ActionIcon:        "icon",
+	ActionEnter:       "enter",
+	ActionLivecomment: "livecomment",
+	ActionSuperchat:   "superchat",
+	ActionReaction:    "reaction",
+	ActionReservation: "reservation",
+	ActionModerate:    "moderate",
+	ActionSpamBlocked: "spam-blocked",
+}
+
+// 1回成功するごとの加点
+var actionWeights = [numActions]int64{
+	ActionGet:         1,
+	ActionPost:        2,
+	ActionIcon:        1,
+	ActionEnter:       2,
+	ActionLivecomment: 3,
+	ActionSuperchat:   10,
+	ActionReaction:    2,
+	ActionReservation: 5,
+	ActionModerate:    5,
+	ActionSpamBlocked: 5,
+}
+
+func (a Action) String() string {
+	return actionNames[a]
+}
+
+// Weight は、1回成功するごとの加点を返します
+func (a Action) Weight() int64 {
+	return actionWeights[a]
+}
+
+// 加点を数えるシャードの数
+// リクエストのたびに1つのカウンタを奪い合うとベンチマーカー自身がボトルネックになるので、走行するシナリオごとにシャードを割り当てます
+const numShards = 64
+
+// Shard は、加点の対象となったリクエストを種類ごとに数えます
+// 応答が遅すぎたリクエストの数も、減点のために数えます
+type Shard struct {
+	counts [numActions]int64
+	slow   int64
+	// 隣のシャードとキャッシュラインを共有しないようにする
+	_ [64]byte
+}
+
+var (
+	shards    [numShards]Shard
+	nextShard uint32
+)
+
+// Add は、actionが1回成功したことを記録します
+func (s *Shard) Add(action Action) {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.counts[action], 1)
+}
+
+// AddSlowResponse は、応答が遅すぎたリクエストがあったことを記録します
+func (s *Shard) AddSlowResponse() {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.slow, 1)
+}
+
+type shardContextKey struct{}
+
+// WithShard は、加点を数えるシャードを割り当てたcontextを返します
+// シナリオを走らせるgoroutineごとに呼び出してください
+// シャードが割り当てられていないcontextでのリクエスト (整合性チェックなど) は加点しません
+func WithShard(ctx context.Context) context.Context {
+	i := atomic.AddUint32(&nextShard, 1)
+	return context.WithValue(ctx, shardContextKey{}, &shards[i%numShards])
+}
+
+// ShardFromContext は、ctxに割り当てられたシャードを返します
+// 割り当てられていなければnilを返しますが、nilのシャードへのAddは何もしません
+func ShardFromContext(ctx context.Context) *Shard {
+	s, _ := ctx.Value(shardContextKey{}).(*Shard)
+	return s
+}
+
+// AddAction は、ctxに割り当てられたシャードにactionが1回成功したことを記録します
+func AddAction(ctx context.Context, action Action) {
+	ShardFromContext(ctx).Add(action)
+}
+
+// AddSlowResponse は、ctxに割り当てられたシャードに応答が遅すぎたリクエストがあったことを記録します
+func AddSlowResponse(ctx context.Context) {
+	ShardFromContext(ctx).AddSlowResponse()
+}
+
+func resetActions() {
+	for i := range shards {
+		for action := range shards[i].counts {
+			atomic.StoreInt64(&shards[i].counts[action], 0)
+		}
+		atomic.StoreInt64(&shards[i].slow, 0)
+	}
+}
+
+// NumActions は、種類ごとの成功数をすべてのシャードで合計して返します
+func NumActions() map[Action]int64 {
+	var totals [numActions]int64
+	for i := range shards {
+		for action := range shards[i].counts {
+			totals[action] += atomic.LoadInt64(&shards[i].counts[action])
+		}
+	}
+	counts := make(map[Action]int64, numActions)
+	for action, total := range totals {
+		counts[Action(action)] = total
+	}
+	return counts
+}
+
+// NumSlowResponses は、応答が遅すぎたリクエストの数をすべてのシャードで合計して返します
+func NumSlowResponses() int64 {
+	var total int64
+	for i := range shards {
+		total += atomic.LoadInt64(&shards[i].slow)
+	}
+	return total
+}
+
+// Breakdown は、最終的なスコアの内訳です
+type Breakdown struct {
+	// 種類ごとの成功数
+	Actions map[string]int64 `json:"actions"`
+	// 成功したリクエストによる加点の合計
+	Points int64 `json:"points"`
+	// 売上 (スーパーチャットのチップの合計)
+	Profit int64 `json:"profit"`
+	// エラーによる減点
+	Deduction int64 `json:"deduction"`
+	// 応答が遅すぎたリクエストの数と、それによる減点
+	SlowResponses  int64 `json:"slow_responses"`
+	LatencyPenalty int64 `json:"latency_penalty"`
+	// 最終的なスコア (0未満にはなりません)
+	Total int64 `json:"total"`
+}
+
+// CalculateScore は、これまでの加点・売上とdeductionから最終的なスコアを計算します
+// 応答が遅すぎたリクエストは、1回ごとにslowResponsePenaltyを減点します
+func CalculateScore(deduction int64, slowResponsePenalty int64) Breakdown {
+	slowResponses := NumSlowResponses()
+	breakdown := Breakdown{
+		Actions:        make(map[string]int64, numActions),
+		Profit:         int64(GetTotalProfit()),
+		Deduction:      deduction,
+		SlowResponses:  slowResponses,
+		LatencyPenalty: slowResponses * slowResponsePenalty,
+	}
+	for action, count := range NumActions() {
+		breakdown.Actions[action.String()] = count
+		breakdown.Points += count * action.Weight()
+	}
+	breakdown.Total = max(breakdown.Points+breakdown.Profit-breakdown.Deduction-breakdown.LatencyPenalty, 0)
+	return breakdown
+}
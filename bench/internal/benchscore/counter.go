@@ -10,9 +10,16 @@ import (
 const (
 	DNSResolve score.ScoreTag = "dns-resolve"
 	DNSFailed  score.ScoreTag = "dns-failed"
+	// 水責めで問い合わせた、存在しない名前に対する応答の正誤
+	DNSNXDomain    score.ScoreTag = "dns-nxdomain"
+	DNSWrongAnswer score.ScoreTag = "dns-wrong-answer"
 
 	TooSlow     score.ScoreTag = "too-slow-left"
 	TooManySpam score.ScoreTag = "too-many-spam"
+
+	// WebSocketで、投稿したライブコメントが届いたか
+	LiveEventDelivered score.ScoreTag = "live-event-delivered"
+	LiveEventMissed    score.ScoreTag = "live-event-missed"
 )
 
 var (
@@ -24,8 +31,14 @@ func InitCounter(ctx context.Context) {
 	counter = score.NewScore(ctx)
 	counter.Set(DNSResolve, 1)
 	counter.Set(DNSFailed, 1)
+	counter.Set(DNSNXDomain, 1)
+	counter.Set(DNSWrongAnswer, 1)
 	counter.Set(TooSlow, 1)
 	counter.Set(TooManySpam, 1)
+	counter.Set(LiveEventDelivered, 1)
+	counter.Set(LiveEventMissed, 1)
+	resetActions()
+	resetLatencies()
 }
 
 func IncResolves() {
@@ -46,6 +59,14 @@ func NumDNSFailed() int64 {
 	return table[DNSFailed]
 }
 
+func IncLiveEventDelivered() {
+	counter.Add(LiveEventDelivered)
+}
+
+func IncLiveEventMissed() {
+	counter.Add(LiveEventMissed)
+}
+
 func GetByTag(tag score.ScoreTag) int64 {
 	return counter.Breakdown()[tag]
 }
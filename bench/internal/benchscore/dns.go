@@ -0,0 +1,61 @@
+package benchscore
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 名前解決の応答時間を記録する上限 (これ以上は上限の値として扱う)
+const maxDNSLatencyMillis = 2000
+
+// 応答時間をミリ秒ごとに数える
+var dnsLatencyBuckets [maxDNSLatencyMillis + 1]uint64
+
+// ObserveDNSLatency は、名前解決の応答時間を記録します
+func ObserveDNSLatency(d time.Duration) {
+	ms := d.Milliseconds()
+	if ms < 0 {
+		ms = 0
+	}
+	if ms > maxDNSLatencyMillis {
+		ms = maxDNSLatencyMillis
+	}
+	atomic.AddUint64(&dnsLatencyBuckets[ms], 1)
+}
+
+// DNSLatencyPercentile は、記録した応答時間のpパーセンタイル (0 < p <= 100) をミリ秒単位で返します
+// 記録がなければfalseを返します
+func DNSLatencyPercentile(p float64) (time.Duration, bool) {
+	var counts [maxDNSLatencyMillis + 1]uint64
+	var total uint64
+	for i := range dnsLatencyBuckets {
+		counts[i] = atomic.LoadUint64(&dnsLatencyBuckets[i])
+		total += counts[i]
+	}
+	if total == 0 {
+		return 0, false
+	}
+
+	threshold := uint64(float64(total) * p / 100)
+	if threshold == 0 {
+		threshold = 1
+	}
+	var acc uint64
+	for ms, count := range counts {
+		acc += count
+		if acc >= threshold {
+			return time.Duration(ms) * time.Millisecond, true
+		}
+	}
+	return maxDNSLatencyMillis * time.Millisecond, true
+}
+
+// IncNXDomain は、存在しない名前に対してNXDOMAINが正しく返ったことを記録します
+func IncNXDomain() {
+	counter.Add(DNSNXDomain)
+}
+
+// IncDNSWrongAnswer は、存在しない名前に対してレコードが返るなど、誤った応答を記録します
+func IncDNSWrongAnswer() {
+	counter.Add(DNSWrongAnswer)
+}
@@ -0,0 +1,166 @@
+package benchscore
+
+import (
+	"cmp"
+	"context"
+	"math/bits"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// レスポンスタイムのヒストグラムは、HDR Histogramと同じく2のべきごとの区間をさらに等分したバケットで数えます
+// マイクロ秒単位で、どの区間でも1/latencySubBuckets (約1.6%) の精度で記録できます
+const (
+	latencySubBucketBits = 6
+	latencySubBuckets    = 1 << latencySubBucketBits
+	// 2^26マイクロ秒 (約67秒) までを区別し、これ以上は上限の値として扱う
+	maxLatencyBits    = 26
+	numLatencyBuckets = (maxLatencyBits - latencySubBucketBits + 1) * latencySubBuckets
+)
+
+// latencyHistogram は、エンドポイント1つ分のレスポンスタイムのヒストグラムです
+type latencyHistogram struct {
+	buckets  [numLatencyBuckets]uint64
+	count    uint64
+	failures uint64
+	max      int64
+}
+
+func latencyBucket(us uint64) int {
+	if us < latencySubBuckets {
+		return int(us)
+	}
+	shift := bits.Len64(us) - latencySubBucketBits - 1
+	idx := shift*latencySubBuckets + int(us>>shift)
+	if idx >= numLatencyBuckets {
+		return numLatencyBuckets - 1
+	}
+	return idx
+}
+
+// latencyBucketValue は、バケットに数えたレスポンスタイムの下限を返します
+func latencyBucketValue(idx int) time.Duration {
+	if idx < latencySubBuckets {
+		return time.Duration(idx) * time.Microsecond
+	}
+	shift := idx/latencySubBuckets - 1
+	us := uint64(idx%latencySubBuckets+latencySubBuckets) << shift
+	return time.Duration(us) * time.Microsecond
+}
+
+func (h *latencyHistogram) observe(d time.Duration, failed bool) {
+	us := d.Microseconds()
+	if us < 0 {
+		us = 0
+	}
+	atomic.AddUint64(&h.buckets[latencyBucket(uint64(us))], 1)
+	atomic.AddUint64(&h.count, 1)
+	if failed {
+		atomic.AddUint64(&h.failures, 1)
+	}
+	for {
+		cur := atomic.LoadInt64(&h.max)
+		if int64(d) <= cur || atomic.CompareAndSwapInt64(&h.max, cur, int64(d)) {
+			break
+		}
+	}
+}
+
+// percentiles は、psのパーセンタイル (0 < p <= 100) を順に返します
+func (h *latencyHistogram) percentiles(ps ...float64) []time.Duration {
+	var counts [numLatencyBuckets]uint64
+	var total uint64
+	for i := range h.buckets {
+		counts[i] = atomic.LoadUint64(&h.buckets[i])
+		total += counts[i]
+	}
+
+	results := make([]time.Duration, len(ps))
+	if total == 0 {
+		return results
+	}
+	for i, p := range ps {
+		threshold := max(uint64(float64(total)*p/100), 1)
+		var acc uint64
+		for idx, count := range counts {
+			acc += count
+			if acc >= threshold {
+				results[i] = latencyBucketValue(idx)
+				break
+			}
+		}
+	}
+	return results
+}
+
+var endpointLatencies sync.Map // map[string]*latencyHistogram
+
+// ObserveLatency は、endpointへのリクエストのレスポンスタイムを記録します
+// failedは、タイムアウトなどでレスポンスが得られなかったことを表します
+// 加点と同じく、シャードを割り当てた負荷走行のリクエストだけを記録します
+func ObserveLatency(ctx context.Context, endpoint string, d time.Duration, failed bool) {
+	if ShardFromContext(ctx) == nil {
+		return
+	}
+	h, ok := endpointLatencies.Load(endpoint)
+	if !ok {
+		h, _ = endpointLatencies.LoadOrStore(endpoint, &latencyHistogram{})
+	}
+	h.(*latencyHistogram).observe(d, failed)
+}
+
+func resetLatencies() {
+	endpointLatencies.Range(func(key, _ any) bool {
+		endpointLatencies.Delete(key)
+		return true
+	})
+}
+
+// EndpointLatency は、エンドポイントごとのレスポンスタイムの集計です
+type EndpointLatency struct {
+	Endpoint string `json:"endpoint"`
+	Count    uint64 `json:"count"`
+	// タイムアウトなどでレスポンスが得られなかった数
+	Failures uint64 `json:"failures"`
+	// 1秒あたりのリクエスト数
+	Throughput float64 `json:"throughput"`
+	// レスポンスタイム (ミリ秒)
+	P50Millis float64 `json:"p50_ms"`
+	P95Millis float64 `json:"p95_ms"`
+	P99Millis float64 `json:"p99_ms"`
+	MaxMillis float64 `json:"max_ms"`
+}
+
+// EndpointLatencies は、記録したレスポンスタイムをエンドポイントごとに集計し、エンドポイントの名前順に返します
+// スループットは、elapsedを負荷走行の時間として計算します
+func EndpointLatencies(elapsed time.Duration) []EndpointLatency {
+	var latencies []EndpointLatency
+	endpointLatencies.Range(func(key, value any) bool {
+		h := value.(*latencyHistogram)
+		ps := h.percentiles(50, 95, 99)
+		latency := EndpointLatency{
+			Endpoint:  key.(string),
+			Count:     atomic.LoadUint64(&h.count),
+			Failures:  atomic.LoadUint64(&h.failures),
+			P50Millis: durationMillis(ps[0]),
+			P95Millis: durationMillis(ps[1]),
+			P99Millis: durationMillis(ps[2]),
+			MaxMillis: durationMillis(time.Duration(atomic.LoadInt64(&h.max))),
+		}
+		if elapsed > 0 {
+			latency.Throughput = float64(latency.Count) / elapsed.Seconds()
+		}
+		latencies = append(latencies, latency)
+		return true
+	})
+	slices.SortFunc(latencies, func(a, b EndpointLatency) int {
+		return cmp.Compare(a.Endpoint, b.Endpoint)
+	})
+	return latencies
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -10,8 +10,8 @@ func AddTip(tip uint64) {
 	atomic.AddUint64(&profit, tip)
 }
 
-// GetFinalProfit は、最終売上を返します
+// GetTotalProfit は、最終売上を返します
 // FIXME: finalcheck後にprofitをスコアに加算しないと駄目
 func GetTotalProfit() uint64 {
-	return profit
+	return atomic.LoadUint64(&profit)
 }
@@ -5,9 +5,32 @@ import "time"
 // ベンチマーク走行時間タイムアウト
 const DefaultBenchmarkTimeout = 60 * time.Second
 
+// NOTE: --duration オプションによって変更されます
+// 負荷走行を続ける時間
+var BenchmarkDuration = DefaultBenchmarkTimeout
+
+// NOTE: --parallelism オプションによって変更されます
+// 負荷走行でシナリオを走らせるワーカーの数
+var LoadParallelism = 15
+
+// NOTE: --rampup オプションによって変更されます
+// 負荷走行の開始からこの時間をかけて、ワーカーを線形にLoadParallelismまで増やします
+var LoadRampUpDuration = 5 * time.Second
+
+// 負荷走行の終了後、それまでに送ったリクエストのレスポンスを待つ時間
+// この間に成功したリクエストも加点します
+const LoadDrainTimeout = 5 * time.Second
+
 // スパム離脱割合
 const TooManySpamThresholdPercentage = 30.0
 
+// NOTE: --error-rate-threshold オプションによって変更されます
+// リクエストのうち、一般エラー・タイムアウトになったものの割合 (%) がこれを超えると失格。0以下なら失格にしない
+var ErrorRateThresholdPercentage = 1.0
+
+// 一般エラー・タイムアウト1件あたりの減点
+const ErrorPenalty = 100
+
 // 基本となる並列性
 // セマフォの重みに使われます
 const BaseParallelism = 1
@@ -20,8 +43,22 @@ const ChangableParallelism = 100
 // NOTE: このような保証がないと、登録が一切できず、ベンチ走行までシナリオが全く実行されないケースが出てしまいます
 const NumMustTryLogins = 10
 
-// HTTPクライアント(isucandar/agent) のタイムアウト
-const DefaultAgentTimeout = 20 * time.Second
+// NOTE: --request-timeout オプションによって変更されます
+// HTTPクライアント(isucandar/agent) の、リクエスト1回あたりのタイムアウト
+var DefaultAgentTimeout = 3 * time.Second
+
+// NOTE: --retry-get オプションによって変更されます
+// 有効なとき、GETなど冪等なリクエストがタイムアウトしたら1回だけ送り直します
+// POSTなど冪等でないリクエストは、webappに反映されたかわからないので送り直しません
+var RetryIdempotentRequests = false
+
+// NOTE: --slow-response-threshold オプションによって変更されます
+// レスポンスが返るまでにこれより長くかかったリクエストは、遅すぎるものとして減点します。0以下なら減点しない
+var SlowResponseThreshold = 1 * time.Second
+
+// NOTE: --slow-response-penalty オプションによって変更されます
+// 遅すぎたリクエスト1件あたりの減点
+var SlowResponsePenalty = 1
 
 // POST /api/initialize 時のタイムアウト
 const InitializeAgentTimeout = 42 * time.Second
@@ -42,3 +79,17 @@ const BaseDomain = "u.isucon.dev"
 const ClientIdleConnTimeout = 5 * time.Second
 
 const AttackHTTPClientContextKey = "dns-attack-http-realip"
+
+// NOTE: --enable-ws-viewer オプションによって変更されます
+// 有効なとき、視聴者はWebSocketに接続し、投稿したライブコメントが届くことを確かめます
+var EnableWebSocketViewer = false
+
+// 投稿したライブコメントがWebSocketで届くまで待つ時間
+const LiveEventDeliveryTimeout = 3 * time.Second
+
+// 視聴者が次の操作に移るまで考える時間の目安
+// 実際にはこの0.5倍から1.5倍の間でばらつかせます
+const ViewerThinkTime = 300 * time.Millisecond
+
+// 視聴者がライブコメントを投稿するとき、チップを添えてスーパーチャットにする確率
+const ViewerSuperchatProbability = 0.2
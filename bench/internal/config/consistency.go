@@ -0,0 +1,20 @@
+package config
+
+import "time"
+
+// 走行後の整合性チェックのリクエストのタイムアウト
+const ConsistencyCheckTimeout = 10 * time.Second
+
+// 走行後の整合性チェックを始める前に待つ時間
+// webappがまとめて書き込む入室・退室などが反映されるのを待ちます
+const ConsistencySettleTime = 1 * time.Second
+
+// 走行後の整合性チェックで確かめる、走行中に登録したユーザの数の上限
+const NumConsistencyCheckUsers = 10
+
+// 走行後の整合性チェックで、webappの値とベンチマーカーが記録した値の差として許容する割合と絶対値
+// 割合は記録した値に対するもので、絶対値と合わせた分だけずれていても構いません
+const (
+	ConsistencyTolerance = 0.01
+	ConsistencySlack     = 1
+)
@@ -2,3 +2,13 @@ package config
 
 const InitialReactionCount = 1001
 const InitialNgWords = 14337
+
+// NOTE: --initial-data-manifest オプションによって変更されます
+// datagenで生成した初期データのマニフェストのパス。空なら、マニフェストによる初期データのチェックを行いません
+var InitialDataManifestPath = ""
+
+// 整合性チェックで、マニフェストと突き合わせるユーザ・ライブ配信の数
+const (
+	NumSeededUserChecks       = 10
+	NumSeededLivestreamChecks = 10
+)
@@ -7,3 +7,15 @@ var StaffLogPath string = "/tmp/result.json"
 var ContestantLogPath string = "/tmp/staff.log"
 var ResultPath string = "/tmp/contestant.log"
 var FinalcheckPath string = "/tmp/finalcheck.json"
+
+// NOTE: --log-level オプションによって変更されます
+// 運営・選手向けログの出力レベル (debug, info, warn, error)
+var LogLevel string = "info"
+
+// NOTE: --result-json オプションによって変更されます
+// 空でなければ、エンドポイントごとのレスポンスタイムの集計をJSONで書き出す
+var LatencyReportPath string = ""
+
+// NOTE: --report-path オプションによって変更されます
+// 空でなければ、走行結果のレポートを書き出す。拡張子が .md ならMarkdown、.json ならJSON、それ以外はHTMLにする
+var ReportPath string = ""
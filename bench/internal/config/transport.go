@@ -0,0 +1,20 @@
+package config
+
+import "time"
+
+// NOTE: --max-idle-conns-per-host オプションによって変更されます
+// 仮想ユーザ全体で、ホストごとに持っておく暇な接続の数
+// 仮想ユーザが何千人いても、同時に送るリクエストの数を超えて接続を持っておく必要はない
+var MaxIdleConnsPerHost = 1024
+
+// NOTE: --disable-keepalives オプションによって変更されます
+// 有効なとき、リクエストのたびに接続し直します
+var DisableKeepAlives = false
+
+// NOTE: --http2 オプションによって変更されます
+// 無効なとき、webappがHTTP/2に対応していてもHTTP/1.1で接続します
+var EnableHTTP2 = true
+
+// NOTE: --dns-cache-ttl オプションによって変更されます
+// 名前解決の結果をキャッシュする時間。0ならレコードのTTLに従い、負ならキャッシュしない
+var DNSCacheTTL time.Duration = 0
@@ -0,0 +1,100 @@
+// Package datagen は、isupipeの初期データを生成します
+// 同じシードからは同じデータが生成されるため、webappの初期化とベンチマーカーの整合性検証で同じ前提を共有できます
+//
+// 出力するファイル
+//   - dump.sql: webapp/sql/init.sh で読み込むINSERT文 (users, themes, livestreams, livestream_tags, livecomments, reactions)
+//   - manifest.json: 生成したユーザの認証情報と、ライブ配信・ユーザごとの集計値
+//   - u.isucon.dev.zone: ユーザごとのサブドメインを含むゾーンファイル
+//
+// NOTE: isupipeにはチャンネルや購読の概念がないため、配信者 (ユーザ) とライブ配信をその代わりとして生成します
+// スーパーチャットはチップ付きのライブコメントとして生成します
+package datagen
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli"
+)
+
+// Flags は、初期データ生成のオプションをoptsとoutDirに受け取るフラグを返します
+// isupipedatagen と isupipebench datagen で同じフラグを使います
+func Flags(opts *Options, outDir *string) []cli.Flag {
+	return []cli.Flag{
+		cli.Int64Flag{
+			Name:        "seed",
+			Value:       1,
+			Destination: &opts.Seed,
+		},
+		cli.IntFlag{
+			Name:        "users",
+			Value:       5000,
+			Destination: &opts.Users,
+		},
+		cli.IntFlag{
+			Name:        "streamers",
+			Value:       1000,
+			Usage:       "ライブ配信を持つユーザ数 (users以下)",
+			Destination: &opts.Streamers,
+		},
+		cli.IntFlag{
+			Name:        "livestreams",
+			Value:       7000,
+			Destination: &opts.Livestreams,
+		},
+		cli.IntFlag{
+			Name:        "livecomments",
+			Value:       20000,
+			Usage:       "チップなしのライブコメント数",
+			Destination: &opts.Livecomments,
+		},
+		cli.IntFlag{
+			Name:        "superchats",
+			Value:       5000,
+			Usage:       "チップ付きのライブコメント数",
+			Destination: &opts.Superchats,
+		},
+		cli.IntFlag{
+			Name:        "reactions",
+			Value:       20000,
+			Destination: &opts.Reactions,
+		},
+		cli.StringFlag{
+			Name:        "out",
+			Value:       ".",
+			Usage:       "出力先ディレクトリ",
+			Destination: outDir,
+		},
+	}
+}
+
+// Run は、optsに従って初期データを生成し、outDirに書き出します
+func Run(opts Options, outDir string) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	dataset, err := generate(opts)
+	if err != nil {
+		return fmt.Errorf("初期データの生成に失敗しました: %w", err)
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+	if err := writeSQLDump(filepath.Join(outDir, "dump.sql"), dataset); err != nil {
+		return fmt.Errorf("SQLダンプの書き出しに失敗しました: %w", err)
+	}
+	if err := writeManifest(filepath.Join(outDir, "manifest.json"), dataset); err != nil {
+		return fmt.Errorf("マニフェストの書き出しに失敗しました: %w", err)
+	}
+	if err := writeZone(filepath.Join(outDir, "u.isucon.dev.zone"), dataset); err != nil {
+		return fmt.Errorf("ゾーンファイルの書き出しに失敗しました: %w", err)
+	}
+
+	log.Printf("seed=%d users=%d livestreams=%d livecomments=%d reactions=%d を %s に書き出しました",
+		dataset.Seed, len(dataset.Users), len(dataset.Livestreams), len(dataset.Livecomments), len(dataset.Reactions), outDir)
+	return nil
+}
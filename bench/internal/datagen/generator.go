@@ -0,0 +1,228 @@
+package datagen
+
+import (
+	"fmt"
+	"math/rand"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// 初期データの配信期間 (2023-08-01 00:00:00 UTC 〜 2023-11-25 01:00:00 UTC)
+	// 予約枠 (initial_reservation_slots.sql) の開始より前に収め、予約枠を消費しないようにする
+	livestreamPeriodStart int64 = 1690851600
+	livestreamPeriodEnd   int64 = 1700874000
+
+	// initial_tags.sql に含まれるタグ数
+	tagCount = 103
+	// ライブ配信ひとつあたりの最大タグ数
+	maxTagsPerLivestream = 5
+	// ライブ配信の最大時間 (時間)
+	maxLivestreamHours = 4
+
+	minTip = 1
+	maxTip = 10000
+
+	passwordLength = 16
+)
+
+// Options は、生成する初期データの件数とシードです
+type Options struct {
+	Seed         int64
+	Users        int
+	Streamers    int
+	Livestreams  int
+	Livecomments int
+	Superchats   int
+	Reactions    int
+}
+
+func (o Options) validate() error {
+	if o.Users <= 0 {
+		return fmt.Errorf("users は1以上を指定してください")
+	}
+	if o.Streamers <= 0 || o.Streamers > o.Users {
+		return fmt.Errorf("streamers は1以上、users以下を指定してください")
+	}
+	if o.Livestreams <= 0 {
+		return fmt.Errorf("livestreams は1以上を指定してください")
+	}
+	if o.Livecomments < 0 || o.Superchats < 0 || o.Reactions < 0 {
+		return fmt.Errorf("livecomments, superchats, reactions には0以上を指定してください")
+	}
+	return nil
+}
+
+type generatedUser struct {
+	ID             int64
+	Name           string
+	DisplayName    string
+	Description    string
+	Password       string
+	HashedPassword string
+	DarkMode       bool
+}
+
+type generatedLivestream struct {
+	ID           int64
+	UserID       int64
+	Title        string
+	Description  string
+	PlaylistURL  string
+	ThumbnailURL string
+	StartAt      int64
+	EndAt        int64
+	TagIDs       []int64
+}
+
+type generatedLivecomment struct {
+	ID           int64
+	UserID       int64
+	LivestreamID int64
+	Comment      string
+	Tip          int64
+	CreatedAt    int64
+}
+
+type generatedReaction struct {
+	ID           int64
+	UserID       int64
+	LivestreamID int64
+	EmojiName    string
+	CreatedAt    int64
+}
+
+type dataset struct {
+	Seed         int64
+	Users        []*generatedUser
+	Livestreams  []*generatedLivestream
+	Livecomments []*generatedLivecomment
+	Reactions    []*generatedReaction
+}
+
+// generate は、optsに従って初期データを生成します
+// IDは1からの連番で、TRUNCATE直後のテーブルに投入した場合のAUTO_INCREMENTと一致します
+// NOTE: パスワードハッシュのソルトは乱数で決まるため、ハッシュ値のみシードによらず毎回異なります
+func generate(opts Options) (*dataset, error) {
+	rng := rand.New(rand.NewSource(opts.Seed))
+	d := &dataset{
+		Seed:         opts.Seed,
+		Users:        make([]*generatedUser, 0, opts.Users),
+		Livestreams:  make([]*generatedLivestream, 0, opts.Livestreams),
+		Livecomments: make([]*generatedLivecomment, 0, opts.Livecomments+opts.Superchats),
+		Reactions:    make([]*generatedReaction, 0, opts.Reactions),
+	}
+
+	for i := 0; i < opts.Users; i++ {
+		user, err := generateUser(rng, int64(i+1))
+		if err != nil {
+			return nil, err
+		}
+		d.Users = append(d.Users, user)
+	}
+
+	// 先頭のstreamers人を配信者とする
+	for i := 0; i < opts.Livestreams; i++ {
+		d.Livestreams = append(d.Livestreams, generateLivestream(rng, int64(i+1), int64(rng.Intn(opts.Streamers)+1)))
+	}
+
+	// ライブコメントに続けて、チップ付きのライブコメント (スーパーチャット) を生成する
+	var livecommentID int64 = 1
+	for i := 0; i < opts.Livecomments; i++ {
+		d.Livecomments = append(d.Livecomments, generateLivecomment(rng, livecommentID, d, 0))
+		livecommentID++
+	}
+	for i := 0; i < opts.Superchats; i++ {
+		d.Livecomments = append(d.Livecomments, generateLivecomment(rng, livecommentID, d, int64(minTip+rng.Intn(maxTip-minTip+1))))
+		livecommentID++
+	}
+
+	for i := 0; i < opts.Reactions; i++ {
+		livestream := d.Livestreams[rng.Intn(len(d.Livestreams))]
+		d.Reactions = append(d.Reactions, &generatedReaction{
+			ID:           int64(i + 1),
+			UserID:       int64(rng.Intn(len(d.Users)) + 1),
+			LivestreamID: livestream.ID,
+			EmojiName:    emojiNames[rng.Intn(len(emojiNames))],
+			CreatedAt:    randomTimeIn(rng, livestream),
+		})
+	}
+
+	return d, nil
+}
+
+func generateUser(rng *rand.Rand, id int64) (*generatedUser, error) {
+	family := familyNames[rng.Intn(len(familyNames))]
+	given := givenNames[rng.Intn(len(givenNames))]
+	password := randomString(rng, passwordLength)
+	// 負荷を抑えるため、初期データのパスワードハッシュは最小コストで生成する
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		return nil, err
+	}
+
+	return &generatedUser{
+		ID: id,
+		// サブドメインとしても使うため、英小文字と数字に限る
+		Name:           fmt.Sprintf("%s%s%d", given.roman, family.roman, id),
+		DisplayName:    family.kanji + given.kanji,
+		Description:    descriptions[rng.Intn(len(descriptions))],
+		Password:       password,
+		HashedPassword: string(hashed),
+		DarkMode:       rng.Intn(2) == 0,
+	}, nil
+}
+
+func generateLivestream(rng *rand.Rand, id int64, userID int64) *generatedLivestream {
+	hours := int64(rng.Intn(maxLivestreamHours) + 1)
+	slots := (livestreamPeriodEnd-livestreamPeriodStart)/3600 - hours
+	startAt := livestreamPeriodStart + rng.Int63n(slots+1)*3600
+
+	tagIDs := make([]int64, 0, maxTagsPerLivestream)
+	for _, idx := range rng.Perm(tagCount)[:rng.Intn(maxTagsPerLivestream+1)] {
+		tagIDs = append(tagIDs, int64(idx+1))
+	}
+
+	return &generatedLivestream{
+		ID:           id,
+		UserID:       userID,
+		Title:        livestreamTitles[rng.Intn(len(livestreamTitles))],
+		Description:  livestreamDescriptions[rng.Intn(len(livestreamDescriptions))],
+		PlaylistURL:  fmt.Sprintf("https://media.xiii.isucon.dev/api/%d/playlist.m3u8", rng.Intn(10)+1),
+		ThumbnailURL: thumbnailURLs[rng.Intn(len(thumbnailURLs))],
+		StartAt:      startAt,
+		EndAt:        startAt + hours*3600,
+		TagIDs:       tagIDs,
+	}
+}
+
+func generateLivecomment(rng *rand.Rand, id int64, d *dataset, tip int64) *generatedLivecomment {
+	livestream := d.Livestreams[rng.Intn(len(d.Livestreams))]
+	comments := livecommentTexts
+	if tip > 0 {
+		comments = superchatTexts
+	}
+	return &generatedLivecomment{
+		ID:           id,
+		UserID:       int64(rng.Intn(len(d.Users)) + 1),
+		LivestreamID: livestream.ID,
+		Comment:      comments[rng.Intn(len(comments))],
+		Tip:          tip,
+		CreatedAt:    randomTimeIn(rng, livestream),
+	}
+}
+
+// randomTimeIn は、ライブ配信の期間内のUNIX時間を返します
+func randomTimeIn(rng *rand.Rand, livestream *generatedLivestream) int64 {
+	return livestream.StartAt + rng.Int63n(livestream.EndAt-livestream.StartAt)
+}
+
+const passwordLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func randomString(rng *rand.Rand, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = passwordLetters[rng.Intn(len(passwordLetters))]
+	}
+	return string(b)
+}
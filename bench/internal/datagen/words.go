@@ -0,0 +1,129 @@
+package datagen
+
+type personName struct {
+	kanji string
+	roman string
+}
+
+var familyNames = []personName{
+	{"佐藤", "sato"},
+	{"鈴木", "suzuki"},
+	{"高橋", "takahashi"},
+	{"田中", "tanaka"},
+	{"伊藤", "ito"},
+	{"渡辺", "watanabe"},
+	{"山本", "yamamoto"},
+	{"中村", "nakamura"},
+	{"小林", "kobayashi"},
+	{"加藤", "kato"},
+	{"吉田", "yoshida"},
+	{"山田", "yamada"},
+	{"佐々木", "sasaki"},
+	{"山口", "yamaguchi"},
+	{"松本", "matsumoto"},
+	{"井上", "inoue"},
+	{"木村", "kimura"},
+	{"林", "hayashi"},
+	{"斎藤", "saito"},
+	{"清水", "shimizu"},
+}
+
+var givenNames = []personName{
+	{"翔太", "shota"},
+	{"陽菜", "hina"},
+	{"蓮", "ren"},
+	{"結衣", "yui"},
+	{"大翔", "hiroto"},
+	{"葵", "aoi"},
+	{"悠真", "yuma"},
+	{"美咲", "misaki"},
+	{"湊", "minato"},
+	{"さくら", "sakura"},
+	{"健太", "kenta"},
+	{"千尋", "chihiro"},
+	{"拓海", "takumi"},
+	{"彩", "aya"},
+	{"直樹", "naoki"},
+	{"舞", "mai"},
+	{"亮", "ryo"},
+	{"真由", "mayu"},
+	{"和也", "kazuya"},
+	{"七海", "nanami"},
+}
+
+var descriptions = []string{
+	"普段はエンジニアをしています。\nよろしくおねがいします！",
+	"ゲームと音楽が好きです。",
+	"週末に配信しています。気軽に遊びに来てください。",
+	"料理配信をメインにやっています。",
+	"学生です。色々なジャンルの配信を見ています。",
+	"イラストを描きながら雑談します。",
+	"ISUCONに向けて練習中です。",
+	"旅行先から配信することがあります。",
+}
+
+var livestreamTitles = []string{
+	"まったり雑談配信",
+	"歌ってみたライブ！リクエスト募集中",
+	"初見プレイ！ホラーゲーム実況",
+	"深夜の作業配信",
+	"料理しながらお話しします",
+	"プログラミング入門講座",
+	"お絵描き配信",
+	"朝活ラジオ",
+	"ボードゲーム対戦会",
+	"筋トレ一緒にやろう",
+}
+
+var livestreamDescriptions = []string{
+	"気軽にコメントしてください！",
+	"のんびりやっていきます。",
+	"リクエストがあればどうぞ。",
+	"初めての方も大歓迎です。",
+	"今日も一日お疲れさまでした。",
+}
+
+var thumbnailURLs = []string{
+	"https://media.xiii.isucon.dev/yoru.webp",
+	"https://media.xiii.isucon.dev/isucon12_final.webp",
+	"https://media.xiii.isucon.dev/isucon11_final.webp",
+}
+
+var livecommentTexts = []string{
+	"こんばんは！",
+	"初見です",
+	"今日も楽しみにしてました",
+	"音量ちょうどいいです",
+	"それめっちゃわかる",
+	"草",
+	"おつかれさまです",
+	"次の配信も期待してます",
+	"いまのところもう一回見たい",
+	"BGMいいですね",
+}
+
+var superchatTexts = []string{
+	"いつも楽しい配信をありがとう！",
+	"応援してます！",
+	"少ないですがどうぞ",
+	"誕生日おめでとう！",
+	"最高の配信でした",
+}
+
+var emojiNames = []string{
+	"100",
+	"grinning",
+	"smile",
+	"joy",
+	"heart_eyes",
+	"star-struck",
+	"thinking_face",
+	"clap",
+	"tada",
+	"fire",
+	"heart",
+	"sparkles",
+	"thumbsup",
+	"pray",
+	"eyes",
+}
@@ -0,0 +1,195 @@
+package datagen
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/isucon/isucon13/bench/internal/manifest"
+)
+
+// INSERT文ひとつあたりの行数
+const insertBatchSize = 1000
+
+// newManifest は、生成した初期データの前提をベンチマーカーと共有するためのマニフェストを作ります
+func newManifest(d *dataset) *manifest.Manifest {
+	m := &manifest.Manifest{
+		Seed:        d.Seed,
+		Users:       make([]manifest.User, len(d.Users)),
+		Livestreams: make([]manifest.Livestream, len(d.Livestreams)),
+	}
+
+	for i, user := range d.Users {
+		m.Users[i] = manifest.User{
+			ID:          user.ID,
+			Name:        user.Name,
+			DisplayName: user.DisplayName,
+			Description: user.Description,
+			Password:    user.Password,
+			DarkMode:    user.DarkMode,
+		}
+	}
+	for i, livestream := range d.Livestreams {
+		m.Livestreams[i] = manifest.Livestream{
+			ID:      livestream.ID,
+			UserID:  livestream.UserID,
+			Title:   livestream.Title,
+			TagIDs:  livestream.TagIDs,
+			StartAt: livestream.StartAt,
+			EndAt:   livestream.EndAt,
+		}
+	}
+
+	// IDは1からの連番なので、添字でひける
+	for _, livecomment := range d.Livecomments {
+		livestream := &m.Livestreams[livecomment.LivestreamID-1]
+		livestream.TotalLivecomments++
+		livestream.TotalTip += livecomment.Tip
+		if livecomment.Tip > livestream.MaxTip {
+			livestream.MaxTip = livecomment.Tip
+		}
+
+		streamer := &m.Users[livestream.UserID-1]
+		streamer.TotalLivecomments++
+		streamer.TotalTip += livecomment.Tip
+
+		m.TotalTip += livecomment.Tip
+		if livecomment.Tip > 0 {
+			m.Counts.Superchats++
+		}
+	}
+	for _, reaction := range d.Reactions {
+		livestream := &m.Livestreams[reaction.LivestreamID-1]
+		livestream.TotalReactions++
+		m.Users[livestream.UserID-1].TotalReactions++
+	}
+
+	m.Counts.Users = len(d.Users)
+	m.Counts.Livestreams = len(d.Livestreams)
+	m.Counts.Livecomments = len(d.Livecomments) - m.Counts.Superchats
+	m.Counts.Reactions = len(d.Reactions)
+
+	return m
+}
+
+func writeManifest(path string, d *dataset) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(newManifest(d)); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writeSQLDump は、init.sql でTRUNCATEした後のテーブルに投入するINSERT文を書き出します
+// タグ (initial_tags.sql) と予約枠 (initial_reservation_slots.sql) は既存の初期データをそのまま使います
+func writeSQLDump(path string, d *dataset) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "-- isupipe datagen seed=%d\n", d.Seed)
+
+	writeInserts(w, "users (id, name, display_name, description, password)", len(d.Users), func(i int) string {
+		user := d.Users[i]
+		return fmt.Sprintf("(%d, %s, %s, %s, %s)", user.ID, quote(user.Name), quote(user.DisplayName), quote(user.Description), quote(user.HashedPassword))
+	})
+	writeInserts(w, "themes (user_id, dark_mode)", len(d.Users), func(i int) string {
+		user := d.Users[i]
+		return fmt.Sprintf("(%d, %t)", user.ID, user.DarkMode)
+	})
+	writeInserts(w, "livestreams (id, user_id, title, description, playlist_url, thumbnail_url, start_at, end_at)", len(d.Livestreams), func(i int) string {
+		livestream := d.Livestreams[i]
+		return fmt.Sprintf("(%d, %d, %s, %s, %s, %s, %d, %d)", livestream.ID, livestream.UserID, quote(livestream.Title), quote(livestream.Description), quote(livestream.PlaylistURL), quote(livestream.ThumbnailURL), livestream.StartAt, livestream.EndAt)
+	})
+
+	var livestreamTags []string
+	for _, livestream := range d.Livestreams {
+		for _, tagID := range livestream.TagIDs {
+			livestreamTags = append(livestreamTags, fmt.Sprintf("(%d, %d)", livestream.ID, tagID))
+		}
+	}
+	writeInserts(w, "livestream_tags (livestream_id, tag_id)", len(livestreamTags), func(i int) string {
+		return livestreamTags[i]
+	})
+
+	writeInserts(w, "livecomments (id, user_id, livestream_id, comment, tip, created_at)", len(d.Livecomments), func(i int) string {
+		livecomment := d.Livecomments[i]
+		return fmt.Sprintf("(%d, %d, %d, %s, %d, %d)", livecomment.ID, livecomment.UserID, livecomment.LivestreamID, quote(livecomment.Comment), livecomment.Tip, livecomment.CreatedAt)
+	})
+	writeInserts(w, "reactions (id, emoji_name, user_id, livestream_id, created_at)", len(d.Reactions), func(i int) string {
+		reaction := d.Reactions[i]
+		return fmt.Sprintf("(%d, %s, %d, %d, %d)", reaction.ID, quote(reaction.EmojiName), reaction.UserID, reaction.LivestreamID, reaction.CreatedAt)
+	})
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func writeInserts(w *bufio.Writer, table string, n int, row func(i int) string) {
+	for start := 0; start < n; start += insertBatchSize {
+		end := min(start+insertBatchSize, n)
+		fmt.Fprintf(w, "INSERT INTO %s\nVALUES\n", table)
+		for i := start; i < end; i++ {
+			sep := ","
+			if i == end-1 {
+				sep = ";"
+			}
+			fmt.Fprintf(w, "\t%s%s\n", row(i), sep)
+		}
+	}
+}
+
+var sqlStringEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`)
+
+func quote(s string) string {
+	return "'" + sqlStringEscaper.Replace(s) + "'"
+}
+
+// writeZone は、webapp/pdns/u.isucon.dev.zone と同じ形式で、生成したユーザのサブドメインを含むゾーンファイルを書き出します
+// アドレスは init_zone.sh で置換されます
+func writeZone(path string, d *dataset) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	w.WriteString(`$TTL 3600
+@   SOA  ns1 hostmaster.u.isucon.dev. (
+    0      ; serial
+    10800  ; refresh
+    3600   ; retry
+    604800 ; xxpire
+    3600   ; ncache
+)
+
+@        0 IN NS ns1.u.isucon.dev.
+@        0 IN A  <ISUCON_SUBDOMAIN_ADDRESS>
+ns1      0 IN A  <ISUCON_SUBDOMAIN_ADDRESS>
+pipe     0 IN A  <ISUCON_SUBDOMAIN_ADDRESS>
+
+`)
+	for _, user := range d.Users {
+		fmt.Fprintf(w, "%-20s 0 IN A  <ISUCON_SUBDOMAIN_ADDRESS>\n", user.Name)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
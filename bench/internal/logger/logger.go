@@ -10,11 +10,16 @@ const loggerName = "isupipe-benchmarker"
 
 // InitZapLogger はzapロガーを初期化します
 func InitStaffLogger() (*zap.SugaredLogger, error) {
+	level, err := zapcore.ParseLevel(config.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
 	c := zap.NewProductionConfig()
 	c.Encoding = "console"
 	c.DisableCaller = false
 	c.DisableStacktrace = true
-	c.Level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	c.Level = zap.NewAtomicLevelAt(level)
 	c.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	c.OutputPaths = []string{config.StaffLogPath, "stderr"}
 	c.ErrorOutputPaths = []string{"stderr"}
@@ -51,11 +56,16 @@ func InitTestLogger() (*zap.Logger, error) {
 }
 
 func InitContestantLogger() (*zap.Logger, error) {
+	level, err := zapcore.ParseLevel(config.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
 	c := zap.NewProductionConfig()
 	c.Encoding = "console"
 	c.DisableCaller = true
 	c.DisableStacktrace = true
-	c.Level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	c.Level = zap.NewAtomicLevelAt(level)
 	c.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	c.OutputPaths = []string{config.ContestantLogPath, "stdout"}
 	c.ErrorOutputPaths = []string{"stdout"}
@@ -0,0 +1,76 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Manifest は、datagenで生成した初期データの前提をベンチマーカーと共有するための情報です
+// datagenが書き出し、ベンチマーカーは整合性チェックで初期データが消されたり書き換えられたりしていないかを確かめるのに使います
+type Manifest struct {
+	Seed        int64        `json:"seed"`
+	Counts      Counts       `json:"counts"`
+	TotalTip    int64        `json:"total_tip"`
+	Users       []User       `json:"users"`
+	Livestreams []Livestream `json:"livestreams"`
+}
+
+type Counts struct {
+	Users        int `json:"users"`
+	Livestreams  int `json:"livestreams"`
+	Livecomments int `json:"livecomments"`
+	Superchats   int `json:"superchats"`
+	Reactions    int `json:"reactions"`
+}
+
+type User struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Description string `json:"description"`
+	Password    string `json:"password"`
+	DarkMode    bool   `json:"dark_mode"`
+	// 配信者としての集計値 (ユーザ統計情報APIと同じ定義)
+	TotalReactions    int64 `json:"total_reactions"`
+	TotalLivecomments int64 `json:"total_livecomments"`
+	TotalTip          int64 `json:"total_tip"`
+}
+
+type Livestream struct {
+	ID                int64   `json:"id"`
+	UserID            int64   `json:"user_id"`
+	Title             string  `json:"title"`
+	TagIDs            []int64 `json:"tag_ids"`
+	StartAt           int64   `json:"start_at"`
+	EndAt             int64   `json:"end_at"`
+	TotalReactions    int64   `json:"total_reactions"`
+	TotalLivecomments int64   `json:"total_livecomments"`
+	TotalTip          int64   `json:"total_tip"`
+	MaxTip            int64   `json:"max_tip"`
+}
+
+// Load は、pathのマニフェストを読み込みます
+func Load(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("マニフェストの形式が不正です: %w", err)
+	}
+	if len(m.Users) != m.Counts.Users || len(m.Livestreams) != m.Counts.Livestreams {
+		return nil, fmt.Errorf("マニフェストのユーザ・ライブ配信の数がcountsと一致しません")
+	}
+	return &m, nil
+}
+
+// User は、IDのユーザを返します
+// IDは1からの連番なので、添字でひけます
+func (m *Manifest) User(id int64) (User, bool) {
+	if id < 1 || int(id) > len(m.Users) {
+		return User{}, false
+	}
+	return m.Users[id-1], true
+}
@@ -50,6 +50,12 @@ func (p *PubSub) Subscribe(ctx context.Context) (interface{}, error) {
 	}
 }
 
+// Len は、Subscriberに分配されるのを待っているアイテムの数を返します
+// 他のgoroutineがPublish・Subscribeしている間は目安にしかなりません
+func (p *PubSub) Len() int {
+	return len(p.itemCh)
+}
+
 // Run は、公平にアイテムをSubscriberへ分配します。PublisherやSubScriber動作前に実行しておく必要があります
 func (p *PubSub) Run(ctx context.Context) {
 	go func() {
@@ -24,3 +24,17 @@ func TestPubSub(t *testing.T) {
 	assert.NoError(t, err)
 	fmt.Println(v)
 }
+
+func TestPubSubLen(t *testing.T) {
+	pool := NewPubSub(10)
+	assert.Equal(t, 0, pool.Len())
+
+	pool.Publish(context.TODO(), &Item{})
+	pool.Publish(context.TODO(), &Item{})
+	assert.Equal(t, 2, pool.Len())
+
+	pool.Run(context.TODO())
+	_, err := pool.Subscribe(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pool.Len())
+}
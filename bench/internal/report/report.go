@@ -0,0 +1,195 @@
+package report
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/isucon/isucon13/bench/internal/benchscore"
+)
+
+//go:embed templates/*
+var templateFS embed.FS
+
+// Report は、ベンチマーク走行1回分の結果をまとめたもので、チューニングのたびにチームで共有するためのものです
+// JSONとして保存しておけば、あとからHTMLやMarkdownに描き直せます
+type Report struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Target      string    `json:"target"`
+	Language    string    `json:"language"`
+	Pass        bool      `json:"pass"`
+	Score       int64     `json:"score"`
+	// 負荷走行の時間 (秒)
+	DurationSeconds float64                      `json:"duration_seconds"`
+	Breakdown       *benchscore.Breakdown        `json:"score_breakdown,omitempty"`
+	Timeline        []TimelinePoint              `json:"timeline"`
+	Errors          ErrorSummary                 `json:"errors"`
+	Messages        []string                     `json:"messages"`
+	Latencies       []benchscore.EndpointLatency `json:"latencies"`
+	Scenarios       []ScenarioCount              `json:"scenarios"`
+}
+
+// TimelinePoint は、負荷走行の途中のある時点でのスコアです
+type TimelinePoint struct {
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	Score          int64   `json:"score"`
+	Requests       int64   `json:"requests"`
+	Errors         int64   `json:"errors"`
+}
+
+// ErrorSummary は、エラーを分類ごとに数えたものです
+type ErrorSummary struct {
+	Critical    int64   `json:"critical"`
+	Application int64   `json:"application"`
+	Timeout     int64   `json:"timeout"`
+	Internal    int64   `json:"internal"`
+	Requests    int64   `json:"requests"`
+	ErrorRate   float64 `json:"error_rate"`
+}
+
+// ScenarioCount は、シナリオごとの成功・失敗の回数です
+type ScenarioCount struct {
+	Name    string `json:"name"`
+	Success int64  `json:"success"`
+	Fail    int64  `json:"fail"`
+}
+
+// Format は、レポートの書き出し形式です
+type Format string
+
+const (
+	FormatHTML     Format = "html"
+	FormatMarkdown Format = "markdown"
+	FormatJSON     Format = "json"
+)
+
+// FormatFromPath は、書き出し先のファイルの拡張子から形式を決めます
+// .md または .markdown ならMarkdown、.json ならJSON、それ以外はHTMLにします
+func FormatFromPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return FormatMarkdown
+	case ".json":
+		return FormatJSON
+	default:
+		return FormatHTML
+	}
+}
+
+// Render は、レポートをformatの形式で描きます
+// HTMLは外部のファイルを参照しない1ファイルで完結したものにします
+func (r *Report) Render(format Format) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case FormatJSON:
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	case FormatMarkdown:
+		tmpl, err := texttemplate.New("report.md.tmpl").Funcs(texttemplate.FuncMap(templateFuncs)).ParseFS(templateFS, "templates/report.md.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		if err := tmpl.Execute(&buf, r); err != nil {
+			return nil, err
+		}
+	case FormatHTML:
+		tmpl, err := htmltemplate.New("report.html.tmpl").Funcs(htmltemplate.FuncMap(templateFuncs)).ParseFS(templateFS, "templates/report.html.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		if err := tmpl.Execute(&buf, r); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("不明なレポートの形式です: %s", format)
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFile は、レポートをpathの拡張子に応じた形式で書き出します
+func (r *Report) WriteFile(path string) error {
+	b, err := r.Render(FormatFromPath(path))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, os.ModePerm)
+}
+
+// Load は、JSONで保存したレポートを読み込みます
+func Load(path string) (*Report, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("レポートの形式が不正です: %w", err)
+	}
+	return &r, nil
+}
+
+// ScorePolyline は、スコアの推移をwidth x heightの領域に描くSVGのpolylineの座標列を返します
+func (r *Report) ScorePolyline(width, height float64) string {
+	if len(r.Timeline) == 0 {
+		return ""
+	}
+	maxElapsed := max(r.DurationSeconds, r.Timeline[len(r.Timeline)-1].ElapsedSeconds, 1)
+	maxScore := max(r.MaxTimelineScore(), 1)
+
+	points := make([]string, 0, len(r.Timeline))
+	for _, p := range r.Timeline {
+		x := p.ElapsedSeconds / maxElapsed * width
+		y := height - float64(p.Score)/float64(maxScore)*height
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+	return strings.Join(points, " ")
+}
+
+// MaxTimelineScore は、スコアの推移のうち最も高いスコアを返します
+func (r *Report) MaxTimelineScore() int64 {
+	var maxScore int64
+	for _, p := range r.Timeline {
+		maxScore = max(maxScore, p.Score)
+	}
+	return maxScore
+}
+
+// ActionNames は、スコアの内訳の加点の種類を名前順に返します
+func (r *Report) ActionNames() []string {
+	if r.Breakdown == nil {
+		return nil
+	}
+	names := make([]string, 0, len(r.Breakdown.Actions))
+	for name := range r.Breakdown.Actions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+var templateFuncs = map[string]any{
+	"datetime": func(t time.Time) string {
+		return t.Format(time.DateTime)
+	},
+	"passLabel": func(pass bool) string {
+		if pass {
+			return "成功"
+		}
+		return "失格"
+	},
+	// Markdownの表のセルに入れられるよう、区切りと改行をエスケープします
+	"cell": func(s string) string {
+		return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+	},
+}
@@ -55,7 +55,7 @@ func NewDNSResolver() *DNSResolver {
 		Nameserver:      net.JoinHostPort(config.TargetNameserver, strconv.Itoa(config.DNSPort)),
 		Timeout:         2 * time.Second,
 		ResolveAttempts: 1,
-		UseCache:        true,
+		UseCache:        config.DNSCacheTTL >= 0,
 	}
 }
 
@@ -77,10 +77,11 @@ func (r *DNSResolver) Lookup(ctx context.Context, network, addr string) (net.IP,
 	client := new(dns.Client)
 
 	var in *dns.Msg
+	var rtt time.Duration
 	var err error
 
 	for i := uint(0); i < r.ResolveAttempts; i++ {
-		in, _, err = client.ExchangeContext(ctx, msg, r.Nameserver)
+		in, rtt, err = client.ExchangeContext(ctx, msg, r.Nameserver)
 		if err != nil {
 			continue
 		}
@@ -93,6 +94,7 @@ func (r *DNSResolver) Lookup(ctx context.Context, network, addr string) (net.IP,
 
 	// プロトコル上成功をカウントする
 	benchscore.IncResolves()
+	benchscore.ObserveDNSLatency(rtt)
 
 	if in.Rcode != dns.RcodeSuccess {
 		return nil, fmt.Errorf("「%s」の名前解決に失敗しました (rcode=%d)", addr, in.Rcode)
@@ -110,10 +112,14 @@ func (r *DNSResolver) Lookup(ctx context.Context, network, addr string) (net.IP,
 
 	for _, ans := range in.Answer {
 		if record, ok := ans.(*dns.A); ok {
-			if r.UseCache && ans.Header().Ttl > 0 {
+			ttl := time.Duration(ans.Header().Ttl) * time.Second
+			if config.DNSCacheTTL > 0 {
+				ttl = config.DNSCacheTTL
+			}
+			if r.UseCache && ttl > 0 {
 				cache.Add(addr, cacheEntry{
 					IP:      record.A,
-					Expires: time.Now().Add(time.Duration(ans.Header().Ttl) * time.Second),
+					Expires: time.Now().Add(ttl),
 				})
 			}
 			return record.A, nil
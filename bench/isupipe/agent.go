@@ -0,0 +1,136 @@
+package isupipe
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+
+	"github.com/isucon/isucandar/agent"
+	"github.com/isucon/isucon13/bench/internal/bencherror"
+	"github.com/isucon/isucon13/bench/internal/config"
+	"github.com/isucon/isucon13/bench/internal/resolver"
+	"go.uber.org/zap"
+)
+
+// Identity は、仮想ユーザとして登録・ログインするユーザの情報です
+type Identity struct {
+	Name        string
+	DisplayName string
+	Description string
+	// Password is non-hashed password.
+	Password string
+	DarkMode bool
+}
+
+// Agent は、ベンチマーカーが動かす仮想ユーザ1人分のクライアントです
+// 仮想ユーザごとにhttp.Clientとcookie jarを持つので、セッションが他の仮想ユーザと混ざりません
+// NOTE: Clientと同じくスレッドセーフではありません
+type Agent struct {
+	*Client
+
+	Identity Identity
+	// ログインしたユーザ。SignUp・SignInするまではnil
+	User *User
+}
+
+// AgentFactory は、接続を共有するAgentを作ります
+// Agentを1人作るたびにTransportを作ると、仮想ユーザの数だけコネクションプールができてしまうので、
+// Transportはファクトリで1つだけ作り、cookie jarとベースURLだけを仮想ユーザごとに持たせます
+type AgentFactory struct {
+	contestantLogger *zap.Logger
+	transport        *http.Transport
+	customOpts       []agent.AgentOption
+}
+
+// NewAgentFactory は、dnsResolverで名前を解決するAgentFactoryを作ります
+// customOptsはNewCustomResolverClientと同じく、タイムアウトやURLなどの振る舞いでないパラメータの指定に用いてください
+func NewAgentFactory(contestantLogger *zap.Logger, dnsResolver *resolver.DNSResolver, customOpts ...agent.AgentOption) *AgentFactory {
+	if contestantLogger == nil {
+		contestantLogger = zap.NewNop()
+	}
+	return &AgentFactory{
+		contestantLogger: contestantLogger,
+		transport:        newTransport(dnsResolver),
+		customOpts:       customOpts,
+	}
+}
+
+// NewAgent は、identityとして振る舞う、まだログインしていないAgentを作ります
+func (f *AgentFactory) NewAgent(identity Identity) (*Agent, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{})
+	if err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+	// 配信者のサブドメインにもセッションを送るよう、ベースURL・配信者・画像のagentで1つのhttp.Clientを共有する
+	httpClient := &http.Client{
+		Transport: f.transport,
+		Jar:       jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	newAgent := func(opts ...agent.AgentOption) (*agent.Agent, error) {
+		opts = append([]agent.AgentOption{
+			withClient(httpClient),
+			agent.WithTimeout(config.DefaultAgentTimeout),
+			agent.WithNoCache(),
+		}, opts...)
+		opts = append(opts, f.customOpts...)
+		return agent.NewAgent(opts...)
+	}
+	baseAgent, err := newAgent(agent.WithBaseURL(config.TargetBaseURL))
+	if err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+	themeAgent, err := newAgent()
+	if err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+	assetAgent, err := newAgent(agent.WithBaseURL(config.TargetBaseURL))
+	if err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+
+	return &Agent{
+		Client: &Client{
+			agent:            baseAgent,
+			themeAgent:       themeAgent,
+			assetAgent:       assetAgent,
+			contestantLogger: f.contestantLogger,
+		},
+		Identity: identity,
+	}, nil
+}
+
+// SignUp は、Identityのユーザを登録してログインします
+func (a *Agent) SignUp(ctx context.Context, opts ...ClientOption) error {
+	if _, err := a.Register(ctx, &RegisterRequest{
+		Name:        a.Identity.Name,
+		DisplayName: a.Identity.DisplayName,
+		Description: a.Identity.Description,
+		Password:    a.Identity.Password,
+		Theme: Theme{
+			DarkMode: a.Identity.DarkMode,
+		},
+	}, opts...); err != nil {
+		return err
+	}
+	return a.SignIn(ctx)
+}
+
+// SignIn は、登録済みのIdentityのユーザでログインし、ログインしたユーザを取得します
+func (a *Agent) SignIn(ctx context.Context, opts ...ClientOption) error {
+	if err := a.Login(ctx, &LoginRequest{
+		Username: a.Identity.Name,
+		Password: a.Identity.Password,
+	}, opts...); err != nil {
+		return err
+	}
+	user, err := a.GetMe(ctx)
+	if err != nil {
+		return err
+	}
+	a.User = user
+	return nil
+}
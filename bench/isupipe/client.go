@@ -1,16 +1,22 @@
 package isupipe
 
 import (
+	"bytes"
 	"context"
-	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
+	"reflect"
+	"strconv"
+	"time"
 
 	"github.com/isucon/isucandar/agent"
 	"github.com/isucon/isucon13/bench/internal/bencherror"
+	"github.com/isucon/isucon13/bench/internal/benchscore"
 	"github.com/isucon/isucon13/bench/internal/config"
 	"github.com/isucon/isucon13/bench/internal/resolver"
 	"go.uber.org/zap"
@@ -50,14 +56,7 @@ func NewClient(contestantLogger *zap.Logger, customOpts ...agent.AgentOption) (*
 func NewCustomResolverClient(contestantLogger *zap.Logger, dnsResolver *resolver.DNSResolver, customOpts ...agent.AgentOption) (*Client, error) {
 	opts := []agent.AgentOption{
 		agent.WithBaseURL(config.TargetBaseURL),
-		agent.WithCloneTransport(&http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: config.InsecureSkipVerify,
-			},
-			DialContext:       dnsResolver.DialContext,
-			IdleConnTimeout:   config.ClientIdleConnTimeout,
-			ForceAttemptHTTP2: true,
-		}),
+		agent.WithCloneTransport(newTransport(dnsResolver)),
 		agent.WithTimeout(config.DefaultAgentTimeout),
 		agent.WithNoCache(),
 	}
@@ -72,15 +71,8 @@ func NewCustomResolverClient(contestantLogger *zap.Logger, dnsResolver *resolver
 
 	themeOpts := []agent.AgentOption{
 		withClient(baseAgent.HttpClient),
-		agent.WithCloneTransport(&http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: config.InsecureSkipVerify,
-			},
-			// Custom DNS Resolver
-			DialContext:       dnsResolver.DialContext,
-			IdleConnTimeout:   config.ClientIdleConnTimeout,
-			ForceAttemptHTTP2: true,
-		}),
+		// Custom DNS Resolver
+		agent.WithCloneTransport(newTransport(dnsResolver)),
 		agent.WithTimeout(config.DefaultAgentTimeout),
 		agent.WithNoCache(),
 	}
@@ -91,13 +83,7 @@ func NewCustomResolverClient(contestantLogger *zap.Logger, dnsResolver *resolver
 	assetOpts := []agent.AgentOption{
 		agent.WithBaseURL(config.TargetBaseURL),
 		withClient(baseAgent.HttpClient),
-		agent.WithCloneTransport(&http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: config.InsecureSkipVerify,
-			},
-			DialContext:     dnsResolver.DialContext,
-			IdleConnTimeout: config.ClientIdleConnTimeout,
-		}),
+		agent.WithCloneTransport(newAssetTransport(dnsResolver)),
 		agent.WithTimeout(config.DefaultAgentTimeout),
 		agent.WithNoCache(),
 	}
@@ -142,10 +128,31 @@ func (c *Client) setStreamerURL(streamerName string) error {
 
 // sendRequestはagent.Doをラップしたリクエスト送信関数
 // bencherror.WrapErrorはここで実行しているので、呼び出し側ではwrapしない
+// 加点はしないので、呼び出し側でステータスコードとボディを検証した後にaddScoreを呼ぶ
+// NOTE: config.RetryIdempotentRequestsが有効なら、冪等なリクエストがタイムアウトしたときに1回だけ送り直します
+// POSTなどはwebappに反映されたかわからないので、送り直しません
+// ctxがキャンセルされていれば送らずにErrCancelRequestを返し、WithDrainContextのctxなら送信済みのリクエストはdrainCtxまで待ちます
 func sendRequest(ctx context.Context, agent *agent.Agent, req *http.Request) (*http.Response, error) {
+	if ctx.Err() != nil {
+		return nil, ErrCancelRequest
+	}
 	endpoint := fmt.Sprintf("%s %s", req.Method, req.URL.EscapedPath())
-	resp, err := agent.Do(ctx, req)
+	reqCtx, release := requestContext(ctx)
+	bencherror.IncRequests()
+	startAt := time.Now()
+	resp, err := agent.Do(reqCtx, req)
+	if err != nil && shouldRetry(ctx, req, err) {
+		bencherror.IncRequests()
+		startAt = time.Now()
+		resp, err = agent.Do(reqCtx, req)
+	}
+	latency := time.Since(startAt)
+	benchscore.ObserveLatency(ctx, latencyEndpoint(req), latency, err != nil)
+	if config.SlowResponseThreshold > 0 && latency > config.SlowResponseThreshold {
+		benchscore.AddSlowResponse(ctx)
+	}
 	if err != nil {
+		release()
 		var (
 			netErr net.Error
 		)
@@ -164,5 +171,126 @@ func sendRequest(ctx context.Context, agent *agent.Agent, req *http.Request) (*h
 		}
 	}
 
+	// 個々のクライアントの検証とは別に、返してはいけないフィールドとwebappのOpenAPIのドキュメントのスキーマを確かめる
+	if err := validateResponseBody(req, resp); err != nil {
+		release()
+		return resp, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest && errorResponseCheckEnabled(ctx) {
+		if err := validateErrorResponse(req, resp); err != nil {
+			release()
+			return resp, err
+		}
+	}
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+
 	return resp, nil
 }
+
+// shouldRetry は、agent.Doが返したerrのリクエストを送り直すかを返します
+// 送り直すのは、冪等なリクエストが走行の締切前にタイムアウトしたときだけです
+func shouldRetry(ctx context.Context, req *http.Request, err error) bool {
+	if !config.RetryIdempotentRequests || ctx.Err() != nil {
+		return false
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// mayBeApplied は、sendRequestが返したエラーのうち、webappには反映されたかもしれないものかを返します
+// タイムアウトしたリクエストや、走行の終了で打ち切ったリクエストが当たります
+func mayBeApplied(err error) bool {
+	return errors.Is(err, bencherror.ErrTimeout) || errors.Is(err, ErrCancelRequest)
+}
+
+// sendJSONRequest は、payloadをJSONにしてリクエストを送り、ステータスコードを確かめます
+// 正常系 (defaultStatusCode) のレスポンスは、ボディをresponseにデコードしてタグで検証し、
+// responseがinvariantCheckerを実装していれば、並び順などの条件も検証します
+// payloadがnilならボディなしで送り、responseがnilならレスポンスのボディは読み捨てます
+func sendJSONRequest(
+	ctx context.Context,
+	agent *agent.Agent,
+	method string,
+	urlPath string,
+	payload interface{},
+	defaultStatusCode int,
+	response interface{},
+	opts ...ClientOption,
+) error {
+	o := newClientOptions(defaultStatusCode, opts...)
+
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return bencherror.NewInternalError(err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := agent.NewRequest(method, urlPath, body)
+	if err != nil {
+		return bencherror.NewInternalError(err)
+	}
+	if payload != nil {
+		req.Header.Add("Content-Type", "application/json;charset=utf-8")
+	}
+	if o.limitParam != nil {
+		query := req.URL.Query()
+		query.Add("limit", strconv.Itoa(o.limitParam.Limit))
+		req.URL.RawQuery = query.Encode()
+	}
+
+	resp, err := sendRequest(ctx, agent, req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != o.wantStatusCode {
+		return newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
+	}
+	if response == nil || resp.StatusCode != defaultStatusCode {
+		addScore(ctx, req, resp)
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return newDecodeError(req, err)
+	}
+	// *Tの変数のポインタ (**T) でデコードしたときは、*Tを検証する
+	v := reflect.ValueOf(response)
+	for v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.IsNil() {
+		return newDecodeError(req, errors.New("レスポンスボディがnullです"))
+	}
+	if v.Elem().Kind() == reflect.Slice {
+		if err := ValidateSlice(req, v.Elem().Interface()); err != nil {
+			return err
+		}
+	} else if err := ValidateResponse(req, v.Interface()); err != nil {
+		return err
+	}
+	if checker, ok := v.Interface().(invariantChecker); ok {
+		if err := checker.checkInvariants(); err != nil {
+			return newInvariantError(req, err)
+		}
+	}
+	for _, invariant := range o.invariants {
+		if err := invariant(); err != nil {
+			return newInvariantError(req, err)
+		}
+	}
+	addScore(ctx, req, resp)
+	return nil
+}
@@ -0,0 +1,61 @@
+package isupipe
+
+import (
+	"context"
+	"net/http"
+)
+
+type FeatureFlag struct {
+	Name        string `json:"name" validate:"required"`
+	Enabled     bool   `json:"enabled"`
+	Description string `json:"description"`
+}
+
+type AuditLog struct {
+	ID      int64  `json:"id" validate:"id"`
+	Kind    string `json:"kind" validate:"required"`
+	ActorID int64  `json:"actor_id"`
+	// 操作の対象に応じて返される
+	LivestreamID  int64  `json:"livestream_id"`
+	LivecommentID int64  `json:"livecomment_id"`
+	TargetUserID  int64  `json:"target_user_id"`
+	Detail        string `json:"detail"`
+	CreatedAt     int64  `json:"created_at" validate:"required,unixtime"`
+}
+
+// 新しい順 (IDの大きい順)
+type auditLogs []*AuditLog
+
+func (logs auditLogs) checkInvariants() error {
+	return checkOrder("監査ログ", len(logs), func(i int) bool {
+		return logs[i-1].ID > logs[i].ID
+	})
+}
+
+// 管理者による機能フラグの一覧の取得
+func (c *Client) GetFeatureFlags(ctx context.Context, opts ...ClientOption) ([]*FeatureFlag, error) {
+	var flags []*FeatureFlag
+	if err := sendJSONRequest(ctx, c.agent, http.MethodGet, "/admin/flags", nil, http.StatusOK, &flags, opts...); err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+// 管理者による機能フラグの切り替え
+// 指定しなかったフラグはそのまま
+func (c *Client) PutFeatureFlags(ctx context.Context, flags map[string]bool, opts ...ClientOption) ([]*FeatureFlag, error) {
+	var updated []*FeatureFlag
+	if err := sendJSONRequest(ctx, c.agent, http.MethodPut, "/admin/flags", flags, http.StatusOK, &updated, opts...); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// 管理者による監査ログの取得 (新しい順)
+func (c *Client) GetAuditLogs(ctx context.Context, opts ...ClientOption) ([]*AuditLog, error) {
+	var logs auditLogs
+	if err := sendJSONRequest(ctx, c.agent, http.MethodGet, "/admin/audit_logs", nil, http.StatusOK, &logs, opts...); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
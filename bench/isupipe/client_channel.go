@@ -0,0 +1,38 @@
+package isupipe
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/isucon/isucon13/bench/internal/bencherror"
+)
+
+type ChannelBan struct {
+	ChannelID int64 `json:"channel_id" validate:"id"`
+	User      User  `json:"user" validate:"required"`
+	CreatedAt int64 `json:"created_at" validate:"required,unixtime"`
+}
+
+// 配信者によるチャンネルからのユーザのBAN
+// チャンネルのIDは配信者のユーザIDと同じ
+func (c *Client) BanChannelUser(ctx context.Context, channelID, userID int64, streamerName string, opts ...ClientOption) (*ChannelBan, error) {
+	if err := c.setStreamerURL(streamerName); err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+	var ban *ChannelBan
+	urlPath := fmt.Sprintf("/api/channel/%d/ban/%d", channelID, userID)
+	if err := sendJSONRequest(ctx, c.themeAgent, http.MethodPost, urlPath, nil, http.StatusCreated, &ban, opts...); err != nil {
+		return nil, err
+	}
+	return ban, nil
+}
+
+// 配信者によるチャンネルのBANの解除
+func (c *Client) UnbanChannelUser(ctx context.Context, channelID, userID int64, streamerName string, opts ...ClientOption) error {
+	if err := c.setStreamerURL(streamerName); err != nil {
+		return bencherror.NewInternalError(err)
+	}
+	urlPath := fmt.Sprintf("/api/channel/%d/ban/%d", channelID, userID)
+	return sendJSONRequest(ctx, c.themeAgent, http.MethodDelete, urlPath, nil, http.StatusNoContent, nil, opts...)
+}
@@ -29,16 +29,16 @@ func (c *Client) Initialize(ctx context.Context) (*InitializeResponse, error) {
 		c.contestantLogger.Warn("POST /api/initialize のリクエストが失敗しました", zap.Error(err))
 		return nil, fmt.Errorf("initializeのリクエストに失敗しました %v", err)
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("initialize へのリクエストに対して、期待されたHTTPステータスコードが確認できませんでした (expected:%d, actual:%d)", http.StatusOK, resp.StatusCode)
-	}
 	defer func() {
 		io.Copy(io.Discard, resp.Body)
 		resp.Body.Close()
 	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("initialize へのリクエストに対して、期待されたHTTPステータスコードが確認できませんでした (expected:%d, actual:%d)", http.StatusOK, resp.StatusCode)
+	}
 
 	var initializeResp *InitializeResponse
-	if json.NewDecoder(resp.Body).Decode(&initializeResp); err != nil {
+	if err := json.NewDecoder(resp.Body).Decode(&initializeResp); err != nil {
 		return nil, fmt.Errorf("initializeのJSONのdecodeに失敗しました %v", err)
 	}
 	if err := ValidateResponse(req, initializeResp); err != nil {
@@ -10,25 +10,26 @@ import (
 	"strconv"
 
 	"github.com/isucon/isucon13/bench/internal/bencherror"
+	"github.com/isucon/isucon13/bench/internal/benchmodel"
 	"github.com/isucon/isucon13/bench/internal/benchscore"
 	"github.com/isucon/isucon13/bench/internal/scheduler"
 )
 
 type Livecomment struct {
-	ID         int64      `json:"id" validate:"required"`
+	ID         int64      `json:"id" validate:"id"`
 	User       User       `json:"user" validate:"required"`
 	Livestream Livestream `json:"livestream" validate:"required"`
 	Comment    string     `json:"comment" validate:"required"`
 	// NOTE: Tipがない場合が許容される(tip=0)
 	Tip       int `json:"tip"`
-	CreatedAt int `json:"created_at" validate:"required"`
+	CreatedAt int `json:"created_at" validate:"required,unixtime"`
 }
 
 type LivecommentReport struct {
-	ID          int64       `json:"id" validate:"required"`
+	ID          int64       `json:"id" validate:"id"`
 	Reporter    User        `json:"reporter" validate:"required"`
 	Livecomment Livecomment `json:"livecomment" validate:"required"`
-	CreatedAt   int64       `json:"created_at" validate:"required"`
+	CreatedAt   int64       `json:"created_at" validate:"required,unixtime"`
 }
 
 type (
@@ -37,12 +38,12 @@ type (
 		Tip     int64  `json:"tip"`
 	}
 	PostLivecommentResponse struct {
-		ID         int64      `json:"id" validate:"required"`
+		ID         int64      `json:"id" validate:"id"`
 		User       User       `json:"user" validate:"required"`
 		Livestream Livestream `json:"livestream" validate:"required"`
 		Comment    string     `json:"comment" validate:"required"`
 		Tip        int64      `json:"tip"`
-		CreatedAt  int64      `json:"created_at" validate:"required"`
+		CreatedAt  int64      `json:"created_at" validate:"required,unixtime"`
 	}
 )
 
@@ -52,16 +53,16 @@ type (
 	}
 
 	ModerateResponse struct {
-		WordID int64 `json:"word_id" validate:"required"`
+		WordID int64 `json:"word_id" validate:"id"`
 	}
 )
 
 type NGWord struct {
-	ID           int64  `json:"id" validate:"required"`
-	UserID       int64  `json:"user_id" validate:"required"`
-	LivestreamID int64  `json:"livestream_id" validate:"required"`
+	ID           int64  `json:"id" validate:"id"`
+	UserID       int64  `json:"user_id" validate:"id"`
+	LivestreamID int64  `json:"livestream_id" validate:"id"`
 	Word         string `json:"word" validate:"required"`
-	CreatedAt    int64  `json:"created_at" validate:"required"`
+	CreatedAt    int64  `json:"created_at" validate:"required,unixtime"`
 }
 
 func (c *Client) GetLivecomments(ctx context.Context, livestreamID int64, streamerName string, opts ...ClientOption) ([]*Livecomment, error) {
@@ -95,13 +96,13 @@ func (c *Client) GetLivecomments(ctx context.Context, livestreamID int64, stream
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	livecomments := []*Livecomment{}
 	if resp.StatusCode == defaultStatusCode {
 		if err := json.NewDecoder(resp.Body).Decode(&livecomments); err != nil {
-			return livecomments, bencherror.NewHttpResponseError(err, req)
+			return livecomments, newDecodeError(req, err)
 		}
 
 		if err := ValidateSlice(req, livecomments); err != nil {
@@ -109,6 +110,7 @@ func (c *Client) GetLivecomments(ctx context.Context, livestreamID int64, stream
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return livecomments, nil
 }
 
@@ -138,13 +140,13 @@ func (c *Client) GetLivecommentReports(ctx context.Context, livestreamID int64,
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	reports := []LivecommentReport{}
 	if resp.StatusCode == defaultStatusCode {
 		if err := json.NewDecoder(resp.Body).Decode(&reports); err != nil {
-			return reports, bencherror.NewHttpResponseError(err, req)
+			return reports, newDecodeError(req, err)
 		}
 
 		if err := ValidateSlice(req, reports); err != nil {
@@ -152,6 +154,7 @@ func (c *Client) GetLivecommentReports(ctx context.Context, livestreamID int64,
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return reports, nil
 }
 
@@ -181,13 +184,13 @@ func (c *Client) GetNgwords(ctx context.Context, livestreamID int64, streamerNam
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var ngwords []*NGWord
 	if resp.StatusCode == defaultStatusCode {
 		if err := json.NewDecoder(resp.Body).Decode(&ngwords); err != nil {
-			return nil, bencherror.NewHttpResponseError(err, req)
+			return nil, newDecodeError(req, err)
 		}
 
 		if err := ValidateSlice(req, ngwords); err != nil {
@@ -195,6 +198,7 @@ func (c *Client) GetNgwords(ctx context.Context, livestreamID int64, streamerNam
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return ngwords, nil
 }
 
@@ -225,6 +229,9 @@ func (c *Client) PostLivecomment(ctx context.Context, livestreamID int64, stream
 
 	resp, err := sendRequest(ctx, c.themeAgent, req)
 	if err != nil {
+		if mayBeApplied(err) {
+			benchmodel.AddTip(livestreamID, int64(tip.Tip), false)
+		}
 		return nil, 0, err
 	}
 	defer func() {
@@ -233,13 +240,15 @@ func (c *Client) PostLivecomment(ctx context.Context, livestreamID int64, stream
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, 0, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, 0, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var livecommentResponse *PostLivecommentResponse
 	if resp.StatusCode == defaultStatusCode {
+		benchmodel.AddTip(livestreamID, int64(tip.Tip), true)
+
 		if err := json.NewDecoder(resp.Body).Decode(&livecommentResponse); err != nil {
-			return nil, 0, bencherror.NewHttpResponseError(err, req)
+			return nil, 0, newDecodeError(req, err)
 		}
 
 		if err := ValidateResponse(req, livecommentResponse); err != nil {
@@ -247,8 +256,12 @@ func (c *Client) PostLivecomment(ctx context.Context, livestreamID int64, stream
 		}
 
 		benchscore.AddTip(uint64(tip.Tip))
+		if tip.Tip > 0 {
+			benchscore.AddAction(ctx, benchscore.ActionSuperchat)
+		}
 	}
 
+	addScore(ctx, req, resp)
 	return livecommentResponse, tip.Tip, nil
 }
 
@@ -278,13 +291,13 @@ func (c *Client) ReportLivecomment(ctx context.Context, livestreamID int64, stre
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var livecommentReport *LivecommentReport
 	if resp.StatusCode == defaultStatusCode {
 		if err := json.NewDecoder(resp.Body).Decode(&livecommentReport); err != nil {
-			return bencherror.NewHttpResponseError(err, req)
+			return newDecodeError(req, err)
 		}
 
 		if o.validateReportLivecomment {
@@ -294,6 +307,7 @@ func (c *Client) ReportLivecomment(ctx context.Context, livestreamID int64, stre
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return nil
 }
 
@@ -331,13 +345,13 @@ func (c *Client) Moderate(ctx context.Context, livestreamID int64, streamerName
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var moderateResp *ModerateResponse
 	if resp.StatusCode == defaultStatusCode {
 		if err := json.NewDecoder(resp.Body).Decode(&moderateResp); err != nil {
-			return bencherror.NewHttpResponseError(err, req)
+			return newDecodeError(req, err)
 		}
 
 		if err := ValidateResponse(req, moderateResp); err != nil {
@@ -345,5 +359,60 @@ func (c *Client) Moderate(ctx context.Context, livestreamID int64, streamerName
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return nil
 }
+
+type ModerationLivecomment struct {
+	Livecomment
+	SpamScore float64 `json:"spam_score"`
+	// スパムらしさが閾値を超えて、自動で非表示にされたか
+	Hidden bool `json:"hidden"`
+}
+
+// スパムらしさの高い順 (同じならIDの大きい順)
+type moderationLivecomments []*ModerationLivecomment
+
+func (ls moderationLivecomments) checkInvariants() error {
+	return checkOrder("モデレーション用のライブコメント一覧", len(ls), func(i int) bool {
+		if ls[i-1].SpamScore != ls[i].SpamScore {
+			return ls[i-1].SpamScore > ls[i].SpamScore
+		}
+		return ls[i-1].ID > ls[i].ID
+	})
+}
+
+// 配信者・共同モデレーターによる、スパムらしさの高い順のライブコメント一覧の取得
+func (c *Client) GetModerationLivecomments(ctx context.Context, livestreamID int64, streamerName string, opts ...ClientOption) ([]*ModerationLivecomment, error) {
+	if err := c.setStreamerURL(streamerName); err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+	var livecomments moderationLivecomments
+	urlPath := fmt.Sprintf("/api/livestream/%d/moderation/livecomment", livestreamID)
+	if err := sendJSONRequest(ctx, c.themeAgent, http.MethodGet, urlPath, nil, http.StatusOK, &livecomments, opts...); err != nil {
+		return nil, err
+	}
+	return livecomments, nil
+}
+
+// 配信者によるライブコメントのピン留め
+func (c *Client) PinLivecomment(ctx context.Context, livestreamID, livecommentID int64, streamerName string, opts ...ClientOption) (*Livecomment, error) {
+	if err := c.setStreamerURL(streamerName); err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+	var livecomment *Livecomment
+	urlPath := fmt.Sprintf("/api/livestream/%d/livecomment/%d/pin", livestreamID, livecommentID)
+	if err := sendJSONRequest(ctx, c.themeAgent, http.MethodPost, urlPath, nil, http.StatusOK, &livecomment, opts...); err != nil {
+		return nil, err
+	}
+	return livecomment, nil
+}
+
+// 投稿者・配信者によるスーパーチャットの削除
+func (c *Client) DeleteSuperchat(ctx context.Context, livestreamID, superchatID int64, streamerName string, opts ...ClientOption) error {
+	if err := c.setStreamerURL(streamerName); err != nil {
+		return bencherror.NewInternalError(err)
+	}
+	urlPath := fmt.Sprintf("/api/livestream/%d/superchat/%d", livestreamID, superchatID)
+	return sendJSONRequest(ctx, c.themeAgent, http.MethodDelete, urlPath, nil, http.StatusNoContent, nil, opts...)
+}
@@ -11,18 +11,58 @@ import (
 	"time"
 
 	"github.com/isucon/isucon13/bench/internal/bencherror"
+	"github.com/isucon/isucon13/bench/internal/benchmodel"
 )
 
 type Livestream struct {
-	ID           int64  `json:"id" validate:"required"`
+	ID           int64  `json:"id" validate:"id"`
 	Owner        User   `json:"owner" validate:"required"`
 	Tags         []Tag  `json:"tags" validate:"required,dive,required"`
 	Title        string `json:"title" validate:"required"`
 	Description  string `json:"description" validate:"required"`
 	PlaylistUrl  string `json:"playlist_url" validate:"required"`
 	ThumbnailUrl string `json:"thumbnail_url" validate:"required"`
-	StartAt      int64  `json:"start_at" validate:"required"`
-	EndAt        int64  `json:"end_at" validate:"required"`
+	StartAt      int64  `json:"start_at" validate:"required,unixtime"`
+	EndAt        int64  `json:"end_at" validate:"required,unixtime"`
+	// NOTE: 公開範囲を返さない実装もあり得るので、validate対象外
+	PrivacyStatus string `json:"privacy_status"`
+	// 配信予約のレスポンスでのみ返される
+	IngestKey string `json:"ingest_key"`
+	// 配信者の配信一覧でのみ返される (upcoming, live, archived)
+	Status string `json:"status"`
+}
+
+// 配信者の配信一覧で返される配信の状態
+const (
+	LivestreamStatusUpcoming = "upcoming"
+	LivestreamStatusLive     = "live"
+	LivestreamStatusArchived = "archived"
+)
+
+type TrendingLivestream struct {
+	Livestream
+	Score float64 `json:"score"`
+}
+
+type LivestreamRankingEntry struct {
+	Rank       int64      `json:"rank" validate:"required"`
+	Score      int64      `json:"score"`
+	Livestream Livestream `json:"livestream" validate:"required"`
+}
+
+type (
+	PutLivestreamTagsRequest struct {
+		Tags []int64 `json:"tags"`
+	}
+
+	PostLivestreamCollaboratorRequest struct {
+		UserID int64 `json:"user_id"`
+	}
+)
+
+type LivestreamIngest struct {
+	LivestreamID int64  `json:"livestream_id" validate:"id"`
+	IngestKey    string `json:"ingest_key" validate:"required"`
 }
 
 func (l *Livestream) Hours() int {
@@ -39,6 +79,8 @@ type (
 		ThumbnailUrl string  `json:"thumbnail_url"`
 		StartAt      int64   `json:"start_at"`
 		EndAt        int64   `json:"end_at"`
+		// NOTE: 省略時はpublic扱い
+		PrivacyStatus string `json:"privacy_status,omitempty"`
 	}
 )
 
@@ -72,13 +114,13 @@ func (c *Client) GetLivestream(
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var livestream *Livestream
 	if resp.StatusCode == defaultStatusCode {
 		if err := json.NewDecoder(resp.Body).Decode(&livestream); err != nil {
-			return nil, bencherror.NewHttpResponseError(err, req)
+			return nil, newDecodeError(req, err)
 		}
 
 		if err := ValidateResponse(req, livestream); err != nil {
@@ -86,9 +128,78 @@ func (c *Client) GetLivestream(
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return livestream, nil
 }
 
+// 配信者によるストリームキーの取得
+func (c *Client) GetLivestreamIngest(
+	ctx context.Context,
+	livestreamID int64,
+	streamerName string,
+	opts ...ClientOption,
+) (*LivestreamIngest, error) {
+	return c.requestLivestreamIngest(ctx, http.MethodGet, fmt.Sprintf("/api/livestream/%d/ingest", livestreamID), streamerName, opts...)
+}
+
+// 配信者によるストリームキーの再発行
+func (c *Client) RotateLivestreamIngest(
+	ctx context.Context,
+	livestreamID int64,
+	streamerName string,
+	opts ...ClientOption,
+) (*LivestreamIngest, error) {
+	return c.requestLivestreamIngest(ctx, http.MethodPost, fmt.Sprintf("/api/livestream/%d/ingest/rotate", livestreamID), streamerName, opts...)
+}
+
+func (c *Client) requestLivestreamIngest(
+	ctx context.Context,
+	method string,
+	urlPath string,
+	streamerName string,
+	opts ...ClientOption,
+) (*LivestreamIngest, error) {
+	var (
+		defaultStatusCode = http.StatusOK
+		o                 = newClientOptions(defaultStatusCode, opts...)
+	)
+
+	if err := c.setStreamerURL(streamerName); err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+	req, err := c.themeAgent.NewRequest(method, urlPath, nil)
+	if err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+
+	resp, err := sendRequest(ctx, c.themeAgent, req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != o.wantStatusCode {
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
+	}
+
+	var ingest *LivestreamIngest
+	if resp.StatusCode == defaultStatusCode {
+		if err := json.NewDecoder(resp.Body).Decode(&ingest); err != nil {
+			return nil, newDecodeError(req, err)
+		}
+
+		if err := ValidateResponse(req, ingest); err != nil {
+			return nil, err
+		}
+	}
+
+	addScore(ctx, req, resp)
+	return ingest, nil
+}
+
 func (c *Client) SearchLivestreams(
 	ctx context.Context,
 	opts ...ClientOption,
@@ -107,6 +218,14 @@ func (c *Client) SearchLivestreams(
 		query.Add("tag", o.searchTag.Tag)
 		req.URL.RawQuery = query.Encode()
 	}
+	if o.searchTags != nil {
+		query := req.URL.Query()
+		for _, tag := range o.searchTags.Tags {
+			query.Add("tag", tag)
+		}
+		query.Add("tag_mode", o.searchTags.Mode)
+		req.URL.RawQuery = query.Encode()
+	}
 
 	if o.limitParam != nil {
 		query := req.URL.Query()
@@ -124,7 +243,7 @@ func (c *Client) SearchLivestreams(
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var livestreams []*Livestream
@@ -138,6 +257,61 @@ func (c *Client) SearchLivestreams(
 		}
 	}
 
+	addScore(ctx, req, resp)
+	return livestreams, nil
+}
+
+// 配信開始前の予約済みライブ配信一覧取得
+func (c *Client) GetUpcomingLivestreams(
+	ctx context.Context,
+	opts ...ClientOption,
+) ([]*Livestream, error) {
+	var (
+		defaultStatusCode = http.StatusOK
+		o                 = newClientOptions(defaultStatusCode, opts...)
+	)
+
+	req, err := c.agent.NewRequest(http.MethodGet, "/api/livestream/upcoming", nil)
+	if err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+	if o.searchTag != nil {
+		query := req.URL.Query()
+		query.Add("tag", o.searchTag.Tag)
+		req.URL.RawQuery = query.Encode()
+	}
+
+	if o.limitParam != nil {
+		query := req.URL.Query()
+		query.Add("limit", strconv.Itoa(o.limitParam.Limit))
+		req.URL.RawQuery = query.Encode()
+	}
+
+	resp, err := sendRequest(ctx, c.agent, req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != o.wantStatusCode {
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
+	}
+
+	var livestreams []*Livestream
+	if resp.StatusCode == defaultStatusCode {
+		if err := json.NewDecoder(resp.Body).Decode(&livestreams); err != nil {
+			return nil, newDecodeError(req, err)
+		}
+
+		if err := ValidateSlice(req, livestreams); err != nil {
+			return nil, err
+		}
+	}
+
+	addScore(ctx, req, resp)
 	return livestreams, nil
 }
 
@@ -163,13 +337,13 @@ func (c *Client) GetMyLivestreams(ctx context.Context, opts ...ClientOption) ([]
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var livestreams []*Livestream
 	if resp.StatusCode == defaultStatusCode {
 		if err := json.NewDecoder(resp.Body).Decode(&livestreams); err != nil {
-			return nil, bencherror.NewHttpResponseError(err, req)
+			return nil, newDecodeError(req, err)
 		}
 
 		if err := ValidateSlice(req, livestreams); err != nil {
@@ -177,6 +351,7 @@ func (c *Client) GetMyLivestreams(ctx context.Context, opts ...ClientOption) ([]
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return livestreams, nil
 }
 
@@ -202,13 +377,13 @@ func (c *Client) GetUserLivestreams(ctx context.Context, username string, opts .
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var livestreams []*Livestream
 	if resp.StatusCode == defaultStatusCode {
 		if err := json.NewDecoder(resp.Body).Decode(&livestreams); err != nil {
-			return nil, bencherror.NewHttpResponseError(err, req)
+			return nil, newDecodeError(req, err)
 		}
 
 		if err := ValidateSlice(req, livestreams); err != nil {
@@ -216,6 +391,7 @@ func (c *Client) GetUserLivestreams(ctx context.Context, username string, opts .
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return livestreams, nil
 }
 
@@ -249,20 +425,23 @@ func (c *Client) ReserveLivestream(ctx context.Context, streamerName string, r *
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var livestream *Livestream
 	if resp.StatusCode == defaultStatusCode {
 		if err := json.NewDecoder(resp.Body).Decode(&livestream); err != nil {
-			return nil, bencherror.NewHttpResponseError(err, req)
+			return nil, newDecodeError(req, err)
 		}
 
 		if err := ValidateResponse(req, livestream); err != nil {
 			return nil, err
 		}
+
+		benchmodel.AddLivestream(livestream.ID, livestream.Owner.Name)
 	}
 
+	addScore(ctx, req, resp)
 	return livestream, nil
 }
 
@@ -284,6 +463,9 @@ func (c *Client) EnterLivestream(ctx context.Context, livestreamID int64, stream
 
 	resp, err := sendRequest(ctx, c.themeAgent, req)
 	if err != nil {
+		if mayBeApplied(err) {
+			benchmodel.Enter(livestreamID, c.username, false)
+		}
 		return err
 	}
 	defer func() {
@@ -292,9 +474,13 @@ func (c *Client) EnterLivestream(ctx context.Context, livestreamID int64, stream
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
+	}
+	if resp.StatusCode == defaultStatusCode {
+		benchmodel.Enter(livestreamID, c.username, true)
 	}
 
+	addScore(ctx, req, resp)
 	return nil
 }
 
@@ -315,6 +501,9 @@ func (c *Client) ExitLivestream(ctx context.Context, livestreamID int64, streame
 
 	resp, err := sendRequest(ctx, c.themeAgent, req)
 	if err != nil {
+		if mayBeApplied(err) {
+			benchmodel.Exit(livestreamID, c.username, false)
+		}
 		return err
 	}
 	defer func() {
@@ -323,8 +512,178 @@ func (c *Client) ExitLivestream(ctx context.Context, livestreamID int64, streame
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
+	}
+	if resp.StatusCode == defaultStatusCode {
+		benchmodel.Exit(livestreamID, c.username, true)
 	}
 
+	addScore(ctx, req, resp)
 	return nil
 }
+
+type (
+	PutLivestreamSettingsRequest struct {
+		SlowModeSeconds   int64  `json:"slow_mode_seconds"`
+		SuperchatNGPolicy string `json:"superchat_ng_policy,omitempty"`
+		// NOTE: 指定した場合、この版のときのみ更新される
+		Version *int64 `json:"version,omitempty"`
+	}
+
+	LivestreamSettings struct {
+		LivestreamID      int64  `json:"livestream_id" validate:"id"`
+		SlowModeSeconds   int64  `json:"slow_mode_seconds"`
+		SuperchatNGPolicy string `json:"superchat_ng_policy" validate:"required"`
+		Version           int64  `json:"version" validate:"required"`
+	}
+)
+
+func (c *Client) PutLivestreamSettings(ctx context.Context, livestreamID int64, streamerName string, r *PutLivestreamSettingsRequest, opts ...ClientOption) (*LivestreamSettings, error) {
+	var (
+		defaultStatusCode = http.StatusOK
+		o                 = newClientOptions(defaultStatusCode, opts...)
+	)
+
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+
+	if err := c.setStreamerURL(streamerName); err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+	urlPath := fmt.Sprintf("/api/livestream/%d/settings", livestreamID)
+	req, err := c.themeAgent.NewRequest(http.MethodPut, urlPath, bytes.NewReader(payload))
+	if err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+	req.Header.Add("Content-Type", "application/json;charset=utf-8")
+
+	resp, err := sendRequest(ctx, c.themeAgent, req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != o.wantStatusCode {
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
+	}
+
+	var settings *LivestreamSettings
+	if resp.StatusCode == defaultStatusCode {
+		if err := json.NewDecoder(resp.Body).Decode(&settings); err != nil {
+			return nil, newDecodeError(req, err)
+		}
+
+		if err := ValidateResponse(req, settings); err != nil {
+			return nil, err
+		}
+	}
+
+	addScore(ctx, req, resp)
+	return settings, nil
+}
+
+// スコアの高い順 (同点はIDの小さい順)
+type trendingLivestreams []*TrendingLivestream
+
+func (ls trendingLivestreams) checkInvariants() error {
+	return checkOrder("トレンド配信", len(ls), func(i int) bool {
+		if ls[i-1].Score != ls[i].Score {
+			return ls[i-1].Score > ls[i].Score
+		}
+		return ls[i-1].ID < ls[i].ID
+	})
+}
+
+// 配信中の公開配信を、直近の盛り上がりの順に取得
+func (c *Client) GetTrendingLivestreams(ctx context.Context, opts ...ClientOption) ([]*TrendingLivestream, error) {
+	var livestreams trendingLivestreams
+	if err := sendJSONRequest(ctx, c.agent, http.MethodGet, "/api/livestream/trending", nil, http.StatusOK, &livestreams, opts...); err != nil {
+		return nil, err
+	}
+	return livestreams, nil
+}
+
+// 順位の高い順
+type livestreamRanking []*LivestreamRankingEntry
+
+func (r livestreamRanking) checkInvariants() error {
+	return checkOrder("ライブ配信ランキング", len(r), func(i int) bool {
+		return r[i-1].Rank <= r[i].Rank
+	})
+}
+
+// ライブ配信ランキングのoffset件目からを取得
+// 件数はWithLimitQueryParamで指定し、指定しなければ全件を取得する
+func (c *Client) GetLivestreamRanking(ctx context.Context, offset int, opts ...ClientOption) ([]*LivestreamRankingEntry, error) {
+	var ranking livestreamRanking
+	urlPath := fmt.Sprintf("/api/livestream/ranking?offset=%d", offset)
+	if err := sendJSONRequest(ctx, c.agent, http.MethodGet, urlPath, nil, http.StatusOK, &ranking, opts...); err != nil {
+		return nil, err
+	}
+	return ranking, nil
+}
+
+// 開始日時の新しい順 (同時刻はIDの大きい順)
+type userLivestreams []*Livestream
+
+func (ls userLivestreams) checkInvariants() error {
+	return checkOrder("配信者の配信一覧", len(ls), func(i int) bool {
+		if ls[i-1].StartAt != ls[i].StartAt {
+			return ls[i-1].StartAt > ls[i].StartAt
+		}
+		return ls[i-1].ID > ls[i].ID
+	})
+}
+
+// 配信者の配信一覧を、状態で絞り込んで取得
+// statusが空のときは絞り込まない
+func (c *Client) GetUserLivestreamsByStatus(ctx context.Context, username string, status string, opts ...ClientOption) ([]*Livestream, error) {
+	var livestreams userLivestreams
+	urlPath := fmt.Sprintf("/api/user/%s/livestream", username)
+	if status != "" {
+		urlPath += "?status=" + status
+	}
+	opts = append(opts, withInvariant(func() error {
+		for _, livestream := range livestreams {
+			if status != "" && livestream.Status != status {
+				return fmt.Errorf("%sではない配信が含まれています (livestream_id=%d, status=%s)", status, livestream.ID, livestream.Status)
+			}
+		}
+		return nil
+	}))
+	if err := sendJSONRequest(ctx, c.agent, http.MethodGet, urlPath, nil, http.StatusOK, &livestreams, opts...); err != nil {
+		return nil, err
+	}
+	return livestreams, nil
+}
+
+// 配信者による、配信に付けるタグの付け替え
+func (c *Client) PutLivestreamTags(ctx context.Context, livestreamID int64, streamerName string, tagIDs []int64, opts ...ClientOption) (*Livestream, error) {
+	if err := c.setStreamerURL(streamerName); err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+	var livestream *Livestream
+	urlPath := fmt.Sprintf("/api/livestream/%d/tags", livestreamID)
+	if err := sendJSONRequest(ctx, c.themeAgent, http.MethodPut, urlPath, &PutLivestreamTagsRequest{Tags: tagIDs}, http.StatusOK, &livestream, opts...); err != nil {
+		return nil, err
+	}
+	return livestream, nil
+}
+
+// 配信者による共同配信者の追加
+func (c *Client) AddLivestreamCollaborator(ctx context.Context, livestreamID int64, streamerName string, userID int64, opts ...ClientOption) (*User, error) {
+	if err := c.setStreamerURL(streamerName); err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+	var user *User
+	urlPath := fmt.Sprintf("/api/livestream/%d/collaborator", livestreamID)
+	if err := sendJSONRequest(ctx, c.themeAgent, http.MethodPost, urlPath, &PostLivestreamCollaboratorRequest{UserID: userID}, http.StatusCreated, &user, opts...); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -10,14 +10,31 @@ type SearchTagParam struct {
 	Tag string
 }
 
+const (
+	// 指定したタグをすべて含むライブ配信を検索する
+	SearchTagModeAnd = "and"
+	// 指定したタグのいずれかを含むライブ配信を検索する
+	SearchTagModeOr = "or"
+)
+
+type SearchTagsParam struct {
+	Tags []string
+	Mode string
+}
+
 type ClientOptions struct {
 	wantStatusCode int
 	limitParam     *LimitParam
 	searchTag      *SearchTagParam
+	searchTags     *SearchTagsParam
 	eTag           string
+	// 統計情報を、集計済みの結果を使わずにその場で算出させる
+	fresh bool
 	// NOTE: スパム報告は、ベンチ走行中は粛清されたライブコメントを期待する場合が有り、エラーになることがある
 	// Pretestでのみスパム報告のバリデーションを行うための対応
 	validateReportLivecomment bool
+	// sendJSONRequestで、デコードした後に検証する、リクエストの内容に依る条件
+	invariants []func() error
 }
 
 func newClientOptions(defaultStatusCode int, opts ...ClientOption) *ClientOptions {
@@ -55,14 +72,41 @@ func WithSearchTagQueryParam(tag string) ClientOption {
 	}
 }
 
+// WithSearchTagsQueryParam は、複数タグによる検索条件を指定します
+// modeにはSearchTagModeAndかSearchTagModeOrを指定します
+func WithSearchTagsQueryParam(mode string, tags ...string) ClientOption {
+	return func(o *ClientOptions) {
+		o.searchTags = &SearchTagsParam{
+			Tags: tags,
+			Mode: mode,
+		}
+	}
+}
+
 func WithETag(eTag string) ClientOption {
 	return func(o *ClientOptions) {
 		o.eTag = eTag
 	}
 }
 
+// WithFreshQueryParam は、統計情報を集計済みの結果を使わずにその場で算出させます
+// 走行後の整合性の検証など、集計の遅れを許容できないときに使います
+func WithFreshQueryParam() ClientOption {
+	return func(o *ClientOptions) {
+		o.fresh = true
+	}
+}
+
 func WithValidateReportLivecomment() ClientOption {
 	return func(o *ClientOptions) {
 		o.validateReportLivecomment = true
 	}
 }
+
+// withInvariant は、リクエストの内容に依る条件 (絞り込んだ条件に合うかなど) を、デコードした後に検証させます
+// レスポンスの型だけで決まる条件は、invariantCheckerとして実装してください
+func withInvariant(fn func() error) ClientOption {
+	return func(o *ClientOptions) {
+		o.invariants = append(o.invariants, fn)
+	}
+}
@@ -7,11 +7,29 @@ import (
 	"net/http"
 )
 
+// 配信者の売上の集計期間
+const (
+	EarningsPeriodDay = "day"
+	EarningsPeriodAll = "all"
+)
+
 type PaymentResult struct {
 	// NOTE: 売上0を許容
 	TotalTip int64 `json:"total_tip"`
 }
 
+type LivestreamEarning struct {
+	LivestreamID int64  `json:"livestream_id" validate:"id"`
+	Title        string `json:"title" validate:"required"`
+	TotalTip     int64  `json:"total_tip"`
+}
+
+type EarningsReport struct {
+	Period      string              `json:"period" validate:"required"`
+	TotalTip    int64               `json:"total_tip"`
+	Livestreams []LivestreamEarning `json:"livestreams" validate:"dive"`
+}
+
 func (c *Client) GetPaymentResult(ctx context.Context) (*PaymentResult, error) {
 	req, err := c.agent.NewRequest(http.MethodGet, "/api/payment", nil)
 	if err != nil {
@@ -38,3 +56,13 @@ func (c *Client) GetPaymentResult(ctx context.Context) (*PaymentResult, error) {
 
 	return paymentResp, nil
 }
+
+// 配信者の、期間内のチップの売上の取得
+func (c *Client) GetMyEarnings(ctx context.Context, period string, opts ...ClientOption) (*EarningsReport, error) {
+	var report *EarningsReport
+	urlPath := "/api/user/me/earnings?period=" + period
+	if err := sendJSONRequest(ctx, c.agent, http.MethodGet, urlPath, nil, http.StatusOK, &report, opts...); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -7,9 +7,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"github.com/isucon/isucon13/bench/internal/bencherror"
+	"github.com/isucon/isucon13/bench/internal/benchmodel"
 )
 
 type PostReactionRequest struct {
@@ -17,11 +19,11 @@ type PostReactionRequest struct {
 }
 
 type Reaction struct {
-	ID         int64      `json:"id" validate:"required"`
+	ID         int64      `json:"id" validate:"id"`
 	EmojiName  string     `json:"emoji_name" validate:"required"`
 	User       User       `json:"user" validate:"required"`
 	Livestream Livestream `json:"livestream" validate:"required"`
-	CreatedAt  int64      `json:"created_at" validate:"required"`
+	CreatedAt  int64      `json:"created_at" validate:"required,unixtime"`
 }
 
 func (c *Client) GetReactions(ctx context.Context, livestreamID int64, streamerName string, opts ...ClientOption) ([]Reaction, error) {
@@ -55,13 +57,13 @@ func (c *Client) GetReactions(ctx context.Context, livestreamID int64, streamerN
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	reactions := []Reaction{}
 	if resp.StatusCode == defaultStatusCode {
 		if err := json.NewDecoder(resp.Body).Decode(&reactions); err != nil {
-			return nil, bencherror.NewHttpResponseError(err, req)
+			return nil, newDecodeError(req, err)
 		}
 
 		if err := ValidateSlice(req, reactions); err != nil {
@@ -69,6 +71,7 @@ func (c *Client) GetReactions(ctx context.Context, livestreamID int64, streamerN
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return reactions, nil
 }
 
@@ -95,6 +98,9 @@ func (c *Client) PostReaction(ctx context.Context, livestreamID int64, streamerN
 
 	resp, err := sendRequest(ctx, c.themeAgent, req)
 	if err != nil {
+		if mayBeApplied(err) {
+			benchmodel.AddReaction(livestreamID, false)
+		}
 		return nil, err
 	}
 	defer func() {
@@ -103,13 +109,15 @@ func (c *Client) PostReaction(ctx context.Context, livestreamID int64, streamerN
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	reaction := &Reaction{}
 	if resp.StatusCode == defaultStatusCode {
+		benchmodel.AddReaction(livestreamID, true)
+
 		if err := json.NewDecoder(resp.Body).Decode(&reaction); err != nil {
-			return nil, bencherror.NewHttpResponseError(err, req)
+			return nil, newDecodeError(req, err)
 		}
 
 		if err := ValidateResponse(req, reaction); err != nil {
@@ -117,5 +125,68 @@ func (c *Client) PostReaction(ctx context.Context, livestreamID int64, streamerN
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return reaction, nil
 }
+
+type ReactionCount struct {
+	EmojiName string `json:"emoji_name" validate:"required"`
+	Count     int64  `json:"count" validate:"required"`
+}
+
+type ReactionEmojisResponse struct {
+	Emojis []string `json:"emojis"`
+}
+
+type PostReactionEmojiRequest struct {
+	Name string `json:"name"`
+}
+
+// 件数の多い順 (同じなら絵文字の名前の順)
+type reactionCounts []*ReactionCount
+
+func (cs reactionCounts) checkInvariants() error {
+	return checkOrder("リアクションの集計", len(cs), func(i int) bool {
+		if cs[i-1].Count != cs[i].Count {
+			return cs[i-1].Count > cs[i].Count
+		}
+		return cs[i-1].EmojiName < cs[i].EmojiName
+	})
+}
+
+// 配信のリアクションの絵文字ごとの件数の取得
+func (c *Client) GetReactionSummary(ctx context.Context, livestreamID int64, streamerName string, opts ...ClientOption) ([]*ReactionCount, error) {
+	if err := c.setStreamerURL(streamerName); err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+	var counts reactionCounts
+	urlPath := fmt.Sprintf("/api/livestream/%d/reaction/summary", livestreamID)
+	if err := sendJSONRequest(ctx, c.themeAgent, http.MethodGet, urlPath, nil, http.StatusOK, &counts, opts...); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// リアクションに使える絵文字の一覧の取得
+func (c *Client) GetReactionEmojis(ctx context.Context, opts ...ClientOption) (*ReactionEmojisResponse, error) {
+	var emojis *ReactionEmojisResponse
+	if err := sendJSONRequest(ctx, c.agent, http.MethodGet, "/api/reaction/emojis", nil, http.StatusOK, &emojis, opts...); err != nil {
+		return nil, err
+	}
+	return emojis, nil
+}
+
+// 管理者による、リアクションに使える絵文字の追加
+func (c *Client) AddReactionEmoji(ctx context.Context, name string, opts ...ClientOption) (*ReactionEmojisResponse, error) {
+	var emojis *ReactionEmojisResponse
+	if err := sendJSONRequest(ctx, c.agent, http.MethodPost, "/admin/reaction/emojis", &PostReactionEmojiRequest{Name: name}, http.StatusCreated, &emojis, opts...); err != nil {
+		return nil, err
+	}
+	return emojis, nil
+}
+
+// 管理者による、リアクションに使える絵文字の削除
+func (c *Client) DeleteReactionEmoji(ctx context.Context, name string, opts ...ClientOption) error {
+	urlPath := "/admin/reaction/emojis/" + url.PathEscape(name)
+	return sendJSONRequest(ctx, c.agent, http.MethodDelete, urlPath, nil, http.StatusNoContent, nil, opts...)
+}
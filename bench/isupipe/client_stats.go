@@ -39,6 +39,11 @@ func (c *Client) GetUserStatistics(ctx context.Context, username string, opts ..
 	if err != nil {
 		return nil, bencherror.NewInternalError(err)
 	}
+	if o.fresh {
+		query := req.URL.Query()
+		query.Add("fresh", "true")
+		req.URL.RawQuery = query.Encode()
+	}
 
 	resp, err := sendRequest(ctx, c.agent, req)
 	if err != nil {
@@ -50,7 +55,7 @@ func (c *Client) GetUserStatistics(ctx context.Context, username string, opts ..
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var stats *UserStatistics
@@ -64,6 +69,7 @@ func (c *Client) GetUserStatistics(ctx context.Context, username string, opts ..
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return stats, nil
 }
 
@@ -81,6 +87,11 @@ func (c *Client) GetLivestreamStatistics(ctx context.Context, livestreamID int64
 	if err != nil {
 		return nil, bencherror.NewInternalError(err)
 	}
+	if o.fresh {
+		query := req.URL.Query()
+		query.Add("fresh", "true")
+		req.URL.RawQuery = query.Encode()
+	}
 
 	resp, err := sendRequest(ctx, c.agent, req)
 	if err != nil {
@@ -92,7 +103,7 @@ func (c *Client) GetLivestreamStatistics(ctx context.Context, livestreamID int64
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var stats *LivestreamStatistics
@@ -106,5 +117,47 @@ func (c *Client) GetLivestreamStatistics(ctx context.Context, livestreamID int64
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return stats, nil
 }
+
+type TipperLeaderboardEntry struct {
+	Rank     int64 `json:"rank" validate:"required"`
+	User     User  `json:"user" validate:"required"`
+	TotalTip int64 `json:"total_tip" validate:"required"`
+}
+
+// 1位から順に、チップの合計の多い順
+type tipperLeaderboard []*TipperLeaderboardEntry
+
+func (entries tipperLeaderboard) checkInvariants() error {
+	if err := checkRanks("リーダーボード", 1, len(entries), func(i int) int64 { return entries[i].Rank }); err != nil {
+		return err
+	}
+	return checkOrder("リーダーボード", len(entries), func(i int) bool {
+		return entries[i-1].TotalTip >= entries[i].TotalTip
+	})
+}
+
+// 配信のチップのリーダーボードの取得
+// 件数はWithLimitQueryParamで指定し、指定しなければサーバの既定の件数を取得する
+func (c *Client) GetLivestreamTipLeaderboard(ctx context.Context, livestreamID int64, streamerName string, opts ...ClientOption) ([]*TipperLeaderboardEntry, error) {
+	if err := c.setStreamerURL(streamerName); err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+	var entries tipperLeaderboard
+	urlPath := fmt.Sprintf("/api/livestream/%d/leaderboard", livestreamID)
+	if err := sendJSONRequest(ctx, c.themeAgent, http.MethodGet, urlPath, nil, http.StatusOK, &entries, opts...); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// 全配信のチップのリーダーボードの取得
+func (c *Client) GetTipperLeaderboard(ctx context.Context, opts ...ClientOption) ([]*TipperLeaderboardEntry, error) {
+	var entries tipperLeaderboard
+	if err := sendJSONRequest(ctx, c.agent, http.MethodGet, "/api/leaderboard/tippers", nil, http.StatusOK, &entries, opts...); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -12,14 +12,29 @@ import (
 )
 
 type Tag struct {
-	ID   int64  `json:"id" validate:"required"`
+	ID   int64  `json:"id" validate:"id"`
 	Name string `json:"name" validate:"required"`
+	// GET /api/tag でのみ返される
+	LivestreamCount int64 `json:"livestream_count"`
 }
 
 type TagsResponse struct {
 	Tags []*Tag `json:"tags" validate:"required,dive,required"`
 }
 
+type PostTagRequest struct {
+	Name string `json:"name"`
+}
+
+// 管理者によるタグの追加
+func (c *Client) PostTag(ctx context.Context, name string, opts ...ClientOption) (*Tag, error) {
+	var tag *Tag
+	if err := sendJSONRequest(ctx, c.agent, http.MethodPost, "/api/tag", &PostTagRequest{Name: name}, http.StatusCreated, &tag, opts...); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
 func (c *Client) GetTagsWithUser(ctx context.Context, streamerName string, opts ...ClientOption) (*TagsResponse, error) {
 	var (
 		defaultStatusCode = http.StatusOK
@@ -45,7 +60,7 @@ func (c *Client) GetTagsWithUser(ctx context.Context, streamerName string, opts
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var tags *TagsResponse
@@ -59,6 +74,7 @@ func (c *Client) GetTagsWithUser(ctx context.Context, streamerName string, opts
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return tags, nil
 }
 
@@ -83,7 +99,7 @@ func (c *Client) GetTags(ctx context.Context, opts ...ClientOption) (*TagsRespon
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var tags *TagsResponse
@@ -97,6 +113,7 @@ func (c *Client) GetTags(ctx context.Context, opts ...ClientOption) (*TagsRespon
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return tags, nil
 }
 
@@ -10,10 +10,11 @@ import (
 
 	"github.com/isucon/isucandar/agent"
 	"github.com/isucon/isucon13/bench/internal/bencherror"
+	"github.com/isucon/isucon13/bench/internal/benchmodel"
 )
 
 type User struct {
-	ID          int64  `json:"id" validate:"required"`
+	ID          int64  `json:"id" validate:"id"`
 	Name        string `json:"name" validate:"required"`
 	DisplayName string `json:"display_name" validate:"required"`
 	Description string `json:"description" validate:"required"`
@@ -47,7 +48,7 @@ type PostIconRequest struct {
 }
 
 type PostIconResponse struct {
-	ID int64 `json:"id" validate:"required"`
+	ID int64 `json:"id" validate:"id"`
 }
 
 func (c *Client) GetStreamerTheme(ctx context.Context, streamer *User, opts ...ClientOption) (*Theme, error) {
@@ -71,7 +72,7 @@ func (c *Client) GetStreamerTheme(ctx context.Context, streamer *User, opts ...C
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var theme *Theme
@@ -81,6 +82,7 @@ func (c *Client) GetStreamerTheme(ctx context.Context, streamer *User, opts ...C
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return theme, nil
 }
 
@@ -109,7 +111,7 @@ func (c *Client) GetIcon(ctx context.Context, username string, opts ...ClientOpt
 	}()
 
 	if resp.StatusCode != http.StatusNotModified && resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var imageBytes []byte
@@ -121,10 +123,11 @@ func (c *Client) GetIcon(ctx context.Context, username string, opts ...ClientOpt
 	case defaultStatusCode:
 		imageBytes, err = io.ReadAll(resp.Body)
 		if err != nil {
-			return nil, bencherror.NewHttpResponseError(err, req)
+			return nil, newDecodeError(req, err)
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return imageBytes, nil
 }
 
@@ -162,13 +165,13 @@ func (c *Client) PostIcon(ctx context.Context, r *PostIconRequest, opts ...Clien
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var iconResp *PostIconResponse
 	if resp.StatusCode == defaultStatusCode {
 		if err := json.NewDecoder(resp.Body).Decode(&iconResp); err != nil {
-			return nil, bencherror.NewHttpResponseError(err, req)
+			return nil, newDecodeError(req, err)
 		}
 
 		if err := ValidateResponse(req, iconResp); err != nil {
@@ -176,6 +179,7 @@ func (c *Client) PostIcon(ctx context.Context, r *PostIconRequest, opts ...Clien
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return iconResp, nil
 }
 
@@ -201,13 +205,13 @@ func (c *Client) GetUser(ctx context.Context, username string, opts ...ClientOpt
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var user *User
 	if resp.StatusCode == defaultStatusCode {
 		if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-			return nil, bencherror.NewHttpResponseError(err, req)
+			return nil, newDecodeError(req, err)
 		}
 
 		if err := ValidateResponse(req, user); err != nil {
@@ -215,6 +219,7 @@ func (c *Client) GetUser(ctx context.Context, username string, opts ...ClientOpt
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return user, nil
 }
 
@@ -239,13 +244,13 @@ func (c *Client) GetMe(ctx context.Context, opts ...ClientOption) (*User, error)
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var user *User
 	if resp.StatusCode == defaultStatusCode {
 		if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-			return nil, bencherror.NewHttpResponseError(err, req)
+			return nil, newDecodeError(req, err)
 		}
 
 		if err := ValidateResponse(req, user); err != nil {
@@ -253,6 +258,7 @@ func (c *Client) GetMe(ctx context.Context, opts ...ClientOption) (*User, error)
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return user, nil
 }
 
@@ -284,13 +290,15 @@ func (c *Client) Register(ctx context.Context, r *RegisterRequest, opts ...Clien
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return nil, bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return nil, newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	var user *User
 	if resp.StatusCode == defaultStatusCode {
+		benchmodel.AddUser(r.Name, r.Password)
+
 		if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-			return nil, bencherror.NewHttpResponseError(err, req)
+			return nil, newDecodeError(req, err)
 		}
 
 		if err := ValidateResponse(req, user); err != nil {
@@ -298,6 +306,7 @@ func (c *Client) Register(ctx context.Context, r *RegisterRequest, opts ...Clien
 		}
 	}
 
+	addScore(ctx, req, resp)
 	return user, nil
 }
 
@@ -334,22 +343,133 @@ func (c *Client) Login(ctx context.Context, r *LoginRequest, opts ...ClientOptio
 	}()
 
 	if resp.StatusCode != o.wantStatusCode {
-		return bencherror.NewHttpStatusError(req, o.wantStatusCode, resp.StatusCode)
+		return newStatusCodeError(req, o.wantStatusCode, resp.StatusCode)
 	}
 
 	c.username = r.Username
 
-	// FIXME: appendに何も入れてない。原因調査
-	c.themeOptions = append(c.themeOptions)
-	c.themeAgent, err = agent.NewAgent(c.themeOptions...)
-	if err != nil {
-		return bencherror.NewInternalError(err)
+	// AgentFactoryで作ったクライアントは、配信者・画像のagentを作成済み
+	if c.themeAgent == nil {
+		c.themeAgent, err = agent.NewAgent(c.themeOptions...)
+		if err != nil {
+			return bencherror.NewInternalError(err)
+		}
+	}
+	if c.assetAgent == nil {
+		c.assetAgent, err = agent.NewAgent(c.assetOptions...)
+		if err != nil {
+			return bencherror.NewInternalError(err)
+		}
 	}
 
-	c.assetAgent, err = agent.NewAgent(c.assetOptions...)
-	if err != nil {
-		return bencherror.NewInternalError(err)
+	addScore(ctx, req, resp)
+	return nil
+}
+
+type WatchHistoryEntry struct {
+	Livestream Livestream `json:"livestream" validate:"required"`
+	WatchedAt  int64      `json:"watched_at" validate:"required,unixtime"`
+}
+
+type Notification struct {
+	ID         int64      `json:"id" validate:"id"`
+	Kind       string     `json:"kind" validate:"required"`
+	Livestream Livestream `json:"livestream" validate:"required"`
+	// ピン留めの通知でのみ返される
+	LivecommentID int64 `json:"livecomment_id"`
+	CreatedAt     int64 `json:"created_at" validate:"required,unixtime"`
+	Read          bool  `json:"read"`
+}
+
+type NotificationsResponse struct {
+	UnreadCount   int64          `json:"unread_count"`
+	Notifications []Notification `json:"notifications" validate:"dive"`
+}
+
+type (
+	PostNotificationsReadRequest struct {
+		// 空のときはすべて既読にする
+		IDs []int64 `json:"ids"`
+	}
+	NotificationsReadResponse struct {
+		UnreadCount int64 `json:"unread_count"`
 	}
+)
 
+type HomeResponse struct {
+	User      User                 `json:"user" validate:"required"`
+	Streamers []User               `json:"streamers" validate:"dive"`
+	Upcoming  []Livestream         `json:"upcoming" validate:"dive"`
+	Trending  []TrendingLivestream `json:"trending" validate:"dive"`
+}
+
+// 通知は新しい順 (IDの大きい順) で、未読の件数は一覧に含まれる未読の通知の数以上
+func (r *NotificationsResponse) checkInvariants() error {
+	if err := checkOrder("通知", len(r.Notifications), func(i int) bool {
+		return r.Notifications[i-1].ID > r.Notifications[i].ID
+	}); err != nil {
+		return err
+	}
+	var unread int64
+	for _, notification := range r.Notifications {
+		if !notification.Read {
+			unread++
+		}
+	}
+	if r.UnreadCount < unread {
+		return fmt.Errorf("未読の件数が一覧の未読の通知より少なくなっています (unread_count=%d, unread notifications=%d)", r.UnreadCount, unread)
+	}
 	return nil
 }
+
+// 自分のユーザの削除
+// 削除した後のセッションは使えなくなる
+func (c *Client) DeleteMe(ctx context.Context, opts ...ClientOption) error {
+	return sendJSONRequest(ctx, c.agent, http.MethodDelete, "/api/user/me", nil, http.StatusNoContent, nil, opts...)
+}
+
+// 最後に視聴した日時の新しい順
+type watchHistory []*WatchHistoryEntry
+
+func (h watchHistory) checkInvariants() error {
+	return checkOrder("視聴履歴", len(h), func(i int) bool {
+		return h[i-1].WatchedAt >= h[i].WatchedAt
+	})
+}
+
+// 視聴履歴の取得
+func (c *Client) GetMyWatchHistory(ctx context.Context, opts ...ClientOption) ([]*WatchHistoryEntry, error) {
+	var history watchHistory
+	if err := sendJSONRequest(ctx, c.agent, http.MethodGet, "/api/user/me/history", nil, http.StatusOK, &history, opts...); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// 通知の一覧と未読の件数の取得
+func (c *Client) GetMyNotifications(ctx context.Context, opts ...ClientOption) (*NotificationsResponse, error) {
+	var notifications *NotificationsResponse
+	if err := sendJSONRequest(ctx, c.agent, http.MethodGet, "/api/user/me/notifications", nil, http.StatusOK, &notifications, opts...); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// 通知を既読にする
+// idsが空のときはすべての通知を既読にする
+func (c *Client) ReadNotifications(ctx context.Context, ids []int64, opts ...ClientOption) (*NotificationsReadResponse, error) {
+	var resp *NotificationsReadResponse
+	if err := sendJSONRequest(ctx, c.agent, http.MethodPost, "/api/user/me/notifications/read", &PostNotificationsReadRequest{IDs: ids}, http.StatusOK, &resp, opts...); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ホーム画面の取得
+func (c *Client) GetHome(ctx context.Context, opts ...ClientOption) (*HomeResponse, error) {
+	var home *HomeResponse
+	if err := sendJSONRequest(ctx, c.agent, http.MethodGet, "/api/home", nil, http.StatusOK, &home, opts...); err != nil {
+		return nil, err
+	}
+	return home, nil
+}
@@ -0,0 +1,118 @@
+package isupipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/isucon/isucon13/bench/internal/bencherror"
+	"github.com/isucon/isucon13/bench/internal/config"
+)
+
+const (
+	LiveEventLivecomment = "livecomment"
+	LiveEventSuperchat   = "superchat"
+	LiveEventReaction    = "reaction"
+)
+
+// LiveEvent は、WebSocketで配信から届くメッセージです
+type LiveEvent struct {
+	Type        string       `json:"type"`
+	Livecomment *Livecomment `json:"livecomment"`
+	Reaction    *Reaction    `json:"reaction"`
+}
+
+// LiveEventStream は、配信のWebSocketの接続です
+// NOTE: スレッドセーフではありません
+type LiveEventStream struct {
+	conn     *websocket.Conn
+	endpoint string
+}
+
+// ConnectLiveEvents は、配信のライブコメントなどを受け取るWebSocketに接続します
+func (c *Client) ConnectLiveEvents(ctx context.Context, livestreamID int64, streamerName string) (*LiveEventStream, error) {
+	if err := c.setStreamerURL(streamerName); err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+	u := *c.themeAgent.BaseURL
+	u.Scheme = "ws"
+	if config.HTTPScheme == "https" {
+		u.Scheme = "wss"
+	}
+	u.Path = fmt.Sprintf("/api/livestream/%d/ws", livestreamID)
+	endpoint := fmt.Sprintf("GET %s", u.Path)
+
+	dialer := websocket.Dialer{
+		HandshakeTimeout: config.DefaultAgentTimeout,
+		Jar:              c.themeAgent.HttpClient.Jar,
+	}
+	if transport, ok := c.themeAgent.HttpClient.Transport.(*http.Transport); ok {
+		dialer.TLSClientConfig = transport.TLSClientConfig
+		if transport.DialContext != nil {
+			dialer.NetDial = func(network, addr string) (net.Conn, error) {
+				return transport.DialContext(ctx, network, addr)
+			}
+		}
+	}
+
+	conn, resp, err := dialer.Dial(u.String(), nil)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrCancelRequest
+		}
+		if resp != nil {
+			return nil, bencherror.NewApplicationError(err, "%s のWebSocketへの接続が失敗しました (status %d)", endpoint, resp.StatusCode)
+		}
+		return nil, bencherror.NewApplicationError(err, "%s のWebSocketへの接続が失敗しました", endpoint)
+	}
+
+	return &LiveEventStream{conn: conn, endpoint: endpoint}, nil
+}
+
+// Next は、次に届くメッセージを待ちます。timeoutの間に届かなければタイムアウトのエラーを返します
+func (s *LiveEventStream) Next(ctx context.Context, timeout time.Duration) (*LiveEvent, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := s.conn.SetReadDeadline(deadline); err != nil {
+		return nil, bencherror.NewInternalError(err)
+	}
+
+	var ev LiveEvent
+	if err := s.conn.ReadJSON(&ev); err != nil {
+		if ctx.Err() != nil {
+			return nil, ErrCancelRequest
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, bencherror.NewTimeoutError(err, "%s", s.endpoint)
+		}
+		return nil, bencherror.NewApplicationError(err, "%s のWebSocketからの受信が失敗しました", s.endpoint)
+	}
+
+	switch ev.Type {
+	case LiveEventLivecomment, LiveEventSuperchat:
+		if ev.Livecomment == nil {
+			return nil, bencherror.NewApplicationError(fmt.Errorf("livecomment is empty"), "%s から届いた%sが不正です", s.endpoint, ev.Type)
+		}
+	case LiveEventReaction:
+		if ev.Reaction == nil {
+			return nil, bencherror.NewApplicationError(fmt.Errorf("reaction is empty"), "%s から届いた%sが不正です", s.endpoint, ev.Type)
+		}
+	default:
+		return nil, bencherror.NewApplicationError(fmt.Errorf("unknown type %q", ev.Type), "%s から不明なメッセージが届きました", s.endpoint)
+	}
+
+	return &ev, nil
+}
+
+func (s *LiveEventStream) Close() error {
+	message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	s.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+	return s.conn.Close()
+}
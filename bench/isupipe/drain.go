@@ -0,0 +1,41 @@
+package isupipe
+
+import (
+	"context"
+	"io"
+)
+
+type drainContextKey struct{}
+
+// WithDrainContext は、負荷走行の終了後も、送信済みのリクエストのレスポンスをdrainCtxが終わるまで待つcontextを返します
+// ctxがキャンセルされた後は新しいリクエストを送らず、ErrCancelRequestを返します
+func WithDrainContext(ctx, drainCtx context.Context) context.Context {
+	return context.WithValue(ctx, drainContextKey{}, drainCtx)
+}
+
+// requestContext は、ctxで送るリクエストに使うcontextと、レスポンスを読み終えたら呼ぶ関数を返します
+// WithDrainContextで作ったctxなら、ctxがキャンセルされてもdrainCtxが終わるまではキャンセルされません
+func requestContext(ctx context.Context) (context.Context, func()) {
+	drainCtx, ok := ctx.Value(drainContextKey{}).(context.Context)
+	if !ok {
+		return ctx, func() {}
+	}
+	reqCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(drainCtx, cancel)
+	return reqCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// releaseOnClose は、レスポンスのボディを閉じたときに、requestContextで作ったcontextを解放します
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
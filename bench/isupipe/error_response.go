@@ -0,0 +1,79 @@
+package isupipe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/isucon/isucon13/bench/internal/bencherror"
+)
+
+// ErrorResponse は、webappがエラーのときに返すボディです
+type ErrorResponse struct {
+	Error string `json:"error" validate:"required"`
+}
+
+type errorResponseCheckKey struct{}
+
+// WithErrorResponseCheck は、エラーのレスポンス (4xx, 5xx) のボディがErrorResponseの形をしているかも確かめるcontextを返します
+// 異常系のPretestで、ステータスコードと合わせてエラーの内容を確かめるのに使います
+func WithErrorResponseCheck(ctx context.Context) context.Context {
+	return context.WithValue(ctx, errorResponseCheckKey{}, true)
+}
+
+func errorResponseCheckEnabled(ctx context.Context) bool {
+	enabled, _ := ctx.Value(errorResponseCheckKey{}).(bool)
+	return enabled
+}
+
+// validateErrorResponse は、エラーのレスポンスのボディがErrorResponseの形をしているかを確かめます
+// 読んだボディは、呼び出し側でも読めるように戻しておきます
+func validateErrorResponse(req *http.Request, resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return newDecodeError(req, err)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return newDecodeError(req, errors.New("エラーのレスポンスがJSONではありません"))
+	}
+	var errResp *ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil {
+		return newDecodeError(req, err)
+	}
+	if errResp == nil {
+		return newDecodeError(req, errors.New("レスポンスボディがnullです"))
+	}
+	return ValidateResponse(req, errResp)
+}
+
+// SendRawRequest は、bodyをそのままリクエストのボディにして送り、ステータスコードを返します
+// 不正なJSONを送るなど、他のメソッドでは送れないリクエストを異常系のPretestで送るのに使います
+// ステータスコードは呼び出し側で確かめてください
+func (c *Client) SendRawRequest(ctx context.Context, method string, urlPath string, contentType string, body []byte) (int, error) {
+	req, err := c.agent.NewRequest(method, urlPath, bytes.NewReader(body))
+	if err != nil {
+		return 0, bencherror.NewInternalError(err)
+	}
+	if contentType != "" {
+		req.Header.Add("Content-Type", contentType)
+	}
+
+	resp, err := sendRequest(ctx, c.agent, req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,287 @@
+package isupipe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrOpenAPIUnavailable は、webappがOpenAPIのドキュメントを提供していないことを示します
+// 参考実装以外の言語の実装は提供しないので、その場合はスキーマによる検証を行いません
+var ErrOpenAPIUnavailable = errors.New("OpenAPIのドキュメントが提供されていません")
+
+// responseSchemas は、webappのOpenAPIのドキュメントから読み込んだレスポンスのスキーマです
+// 読み込んでいなければnilで、レスポンスをスキーマで検証しません
+var responseSchemas atomic.Pointer[openAPISchemas]
+
+type openAPIDocument struct {
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]*openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	Responses map[string]struct {
+		Content map[string]struct {
+			Schema *openAPISchema `json:"schema"`
+		} `json:"content"`
+	} `json:"responses"`
+}
+
+// openAPISchema は、webappが生成するスキーマのうち、検証に使う部分です
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref"`
+	AllOf                []*openAPISchema          `json:"allOf"`
+	Type                 string                    `json:"type"`
+	Nullable             bool                      `json:"nullable"`
+	Properties           map[string]*openAPISchema `json:"properties"`
+	Required             []string                  `json:"required"`
+	Items                *openAPISchema            `json:"items"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties"`
+}
+
+type openAPIRoute struct {
+	method string
+	// パスを/で区切ったもの。パスパラメータは空文字列
+	segments []string
+	// ステータスコードごとのJSONの本文のスキーマ
+	responses map[int]*openAPISchema
+}
+
+type openAPISchemas struct {
+	routes     []openAPIRoute
+	components map[string]*openAPISchema
+}
+
+// LoadOpenAPISchemas は、webappのOpenAPIのドキュメントを読み込み、以降のレスポンスをそのスキーマで検証します
+// ドキュメントが提供されていなければErrOpenAPIUnavailableを返します
+func (c *Client) LoadOpenAPISchemas(ctx context.Context) error {
+	// 初期化と同じく走行前に行うので、失敗してもベンチマークのエラーには数えない
+	req, err := c.agent.NewRequest(http.MethodGet, "/openapi.json", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.agent.Do(ctx, req)
+	if err != nil {
+		return fmt.Errorf("GET /openapi.json のリクエストに失敗しました %v", err)
+	}
+	defer func() {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrOpenAPIUnavailable
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET /openapi.json へのリクエストに対して、期待されたHTTPステータスコードが確認できませんでした (expected:%d, actual:%d)", http.StatusOK, resp.StatusCode)
+	}
+
+	var doc openAPIDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return fmt.Errorf("GET /openapi.json のJSONのdecodeに失敗しました %v", err)
+	}
+
+	schemas := &openAPISchemas{components: doc.Components.Schemas}
+	for path, operations := range doc.Paths {
+		segments := strings.Split(path, "/")
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+				segments[i] = ""
+			}
+		}
+		for method, op := range operations {
+			route := openAPIRoute{
+				method:    strings.ToUpper(method),
+				segments:  segments,
+				responses: map[int]*openAPISchema{},
+			}
+			for status, res := range op.Responses {
+				code, err := strconv.Atoi(status)
+				if err != nil {
+					// defaultなど。エラーのレスポンスは検証しない
+					continue
+				}
+				if content, ok := res.Content["application/json"]; ok && content.Schema != nil {
+					route.responses[code] = content.Schema
+				}
+			}
+			schemas.routes = append(schemas.routes, route)
+		}
+	}
+	responseSchemas.Store(schemas)
+	return nil
+}
+
+// match は、リクエストに対応するstatusのレスポンスのスキーマを返します
+// 固定のパスのエンドポイントを、パスパラメータのあるエンドポイントより優先します
+func (s *openAPISchemas) match(method, path string, status int) (*openAPISchema, bool) {
+	segments := strings.Split(path, "/")
+	var (
+		matched *openAPIRoute
+		params  int
+	)
+	for i := range s.routes {
+		route := &s.routes[i]
+		if route.method != method || len(route.segments) != len(segments) {
+			continue
+		}
+		n, ok := 0, true
+		for j, segment := range route.segments {
+			if segment == "" && segments[j] != "" {
+				n++
+				continue
+			}
+			if segment != segments[j] {
+				ok = false
+				break
+			}
+		}
+		if ok && (matched == nil || n < params) {
+			matched, params = route, n
+		}
+	}
+	if matched == nil {
+		return nil, false
+	}
+	schema, ok := matched.responses[status]
+	return schema, ok
+}
+
+// validateResponseBody は、JSONのレスポンスの本文に、パスワードなど返してはいけないフィールドが含まれていないか、
+// OpenAPIのドキュメントを読み込んでいれば、そのスキーマに沿っているかを検証します
+// 検証のために読んだ本文は、呼び出し側が改めて読めるよう差し戻します
+func validateResponseBody(req *http.Request, resp *http.Response) error {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		// 本文の読み込みの失敗は、呼び出し側でデコードするときに扱う
+		return nil
+	}
+
+	var (
+		schemas = responseSchemas.Load()
+		schema  *openAPISchema
+		ok      bool
+	)
+	if schemas != nil {
+		schema, ok = schemas.match(req.Method, req.URL.Path, resp.StatusCode)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		if !ok {
+			// スキーマのないレスポンスのデコードの失敗は、呼び出し側でデコードするときに扱う
+			return nil
+		}
+		return newDecodeError(req, err)
+	}
+	if fields := findSecretFields(v, "$"); len(fields) > 0 {
+		return newSecretFieldError(req, fields)
+	}
+
+	if !ok {
+		return nil
+	}
+	if err := schemas.validate(v, schema, "$"); err != nil {
+		return newDecodeError(req, err)
+	}
+	return nil
+}
+
+// validate は、JSONをデコードした値vがschemaに沿っているかを検証します
+// pathはエラーのメッセージに使う、vの位置です
+func (s *openAPISchemas) validate(v interface{}, schema *openAPISchema, path string) error {
+	if schema.Ref != "" {
+		ref, ok := s.components[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			// ドキュメントの不備なので検証しない
+			return nil
+		}
+		return s.validate(v, ref, path)
+	}
+	if v == nil {
+		if schema.Nullable || (schema.Type == "" && schema.AllOf == nil) {
+			return nil
+		}
+		return fmt.Errorf("%s がnullです", path)
+	}
+	for _, sub := range schema.AllOf {
+		if err := s.validate(v, sub, path); err != nil {
+			return err
+		}
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s がobjectではありません", path)
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s.%s がありません", path, name)
+			}
+		}
+		for name, value := range obj {
+			if prop, ok := schema.Properties[name]; ok {
+				if err := s.validate(value, prop, path+"."+name); err != nil {
+					return err
+				}
+			} else if schema.AdditionalProperties != nil {
+				if err := s.validate(value, schema.AdditionalProperties, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s がarrayではありません", path)
+		}
+		if schema.Items != nil {
+			for i, item := range arr {
+				if err := s.validate(item, schema.Items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s がstringではありません", path)
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s がintegerではありません", path)
+		}
+		if _, err := n.Int64(); err != nil {
+			return fmt.Errorf("%s がintegerではありません", path)
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return fmt.Errorf("%s がnumberではありません", path)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s がbooleanではありません", path)
+		}
+	}
+	return nil
+}
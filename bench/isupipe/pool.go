@@ -38,6 +38,11 @@ func (p *ClientPool) Put(ctx context.Context, c *Client) {
 	p.pool.Publish(ctx, c)
 }
 
+// Len は、プールから取り出せる数の目安を返します
+func (p *ClientPool) Len() int {
+	return p.pool.Len()
+}
+
 // LivestreamPool は、予約後のライブ配信プールです
 type LivestreamPool struct {
 	pool *pubsub.PubSub
@@ -69,6 +74,11 @@ func (p *LivestreamPool) Put(ctx context.Context, livestream *Livestream) {
 	p.pool.Publish(ctx, livestream)
 }
 
+// Len は、プールから取り出せる数の目安を返します
+func (p *LivestreamPool) Len() int {
+	return p.pool.Len()
+}
+
 type LivecommentPool struct {
 	pool *pubsub.PubSub
 }
@@ -98,3 +108,45 @@ func (p *LivecommentPool) Get(ctx context.Context) (*Livecomment, error) {
 func (p *LivecommentPool) Put(ctx context.Context, livecomment *Livecomment) {
 	p.pool.Publish(ctx, livecomment)
 }
+
+// Len は、プールから取り出せる数の目安を返します
+func (p *LivecommentPool) Len() int {
+	return p.pool.Len()
+}
+
+// IdentityPool は、登録が済んだ仮想ユーザのプールです
+// 新しいセッションでログインし直すシナリオが使います
+type IdentityPool struct {
+	pool *pubsub.PubSub
+}
+
+func NewIdentityPool(ctx context.Context) *IdentityPool {
+	pool := pubsub.NewPubSub(10000)
+	pool.Run(ctx)
+	return &IdentityPool{
+		pool: pool,
+	}
+}
+
+func (p *IdentityPool) Get(ctx context.Context) (Identity, error) {
+	v, err := p.pool.Subscribe(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	identity, ok := v.(Identity)
+	if !ok {
+		return Identity{}, fmt.Errorf("got invalid identity from pool")
+	}
+
+	return identity, nil
+}
+
+func (p *IdentityPool) Put(ctx context.Context, identity Identity) {
+	p.pool.Publish(ctx, identity)
+}
+
+// Len は、プールから取り出せる数の目安を返します
+func (p *IdentityPool) Len() int {
+	return p.pool.Len()
+}
@@ -0,0 +1,59 @@
+package isupipe
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/isucon/isucon13/bench/internal/benchscore"
+)
+
+// addScore は、ステータスコードとボディの検証を通ったレスポンスを加点します
+// エラーのステータスコードを期待したリクエストは加点しません
+func addScore(ctx context.Context, req *http.Request, resp *http.Response) {
+	if resp.StatusCode < http.StatusBadRequest {
+		benchscore.AddAction(ctx, scoreAction(req))
+	}
+}
+
+// scoreAction は、リクエストが加点のどの種類に当たるかを返します
+func scoreAction(req *http.Request) benchscore.Action {
+	path := req.URL.Path
+	if req.Method == http.MethodGet {
+		if strings.HasSuffix(path, "/icon") {
+			return benchscore.ActionIcon
+		}
+		return benchscore.ActionGet
+	}
+
+	switch {
+	case strings.HasSuffix(path, "/enter"), strings.HasSuffix(path, "/exit"):
+		return benchscore.ActionEnter
+	case req.Method == http.MethodPost && strings.HasSuffix(path, "/livecomment"):
+		return benchscore.ActionLivecomment
+	case req.Method == http.MethodPost && strings.HasSuffix(path, "/reaction"):
+		return benchscore.ActionReaction
+	case path == "/api/livestream/reservation":
+		return benchscore.ActionReservation
+	case strings.HasSuffix(path, "/report"), strings.HasSuffix(path, "/moderate"):
+		return benchscore.ActionModerate
+	default:
+		return benchscore.ActionPost
+	}
+}
+
+// latencyEndpoint は、レスポンスタイムを集計するためのエンドポイントの名前を返します
+// ユーザ名やIDなどのパスパラメータは、webappのルーティングと同じく :username や :id にまとめます
+func latencyEndpoint(req *http.Request) string {
+	segments := strings.Split(req.URL.Path, "/")
+	for i, segment := range segments {
+		if i == 3 && segments[2] == "user" && segment != "me" {
+			segments[i] = ":username"
+		} else if _, err := strconv.ParseInt(segment, 10, 64); err == nil {
+			segments[i] = ":id"
+		}
+	}
+	return fmt.Sprintf("%s %s", req.Method, strings.Join(segments, "/"))
+}
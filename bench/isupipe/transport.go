@@ -0,0 +1,36 @@
+package isupipe
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/isucon/isucon13/bench/internal/config"
+	"github.com/isucon/isucon13/bench/internal/resolver"
+)
+
+// newTransport は、dnsResolverで名前を解決するTransportを作ります
+// 接続の再利用やHTTP/2の有無は、ベンチマーカー自身がボトルネックにならないようフラグで調整できます
+func newTransport(dnsResolver *resolver.DNSResolver) *http.Transport {
+	return &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: config.InsecureSkipVerify,
+		},
+		DialContext:         dnsResolver.DialContext,
+		MaxIdleConnsPerHost: config.MaxIdleConnsPerHost,
+		IdleConnTimeout:     config.ClientIdleConnTimeout,
+		DisableKeepAlives:   config.DisableKeepAlives,
+		ForceAttemptHTTP2:   config.EnableHTTP2,
+	}
+}
+
+// newAssetTransport は、画像などのアセットを取得するagentのTransportを作ります
+// アセットの取得はこれまでどおりHTTP/1.1で行い、接続数やHTTP/2のフラグは適用しません
+func newAssetTransport(dnsResolver *resolver.DNSResolver) *http.Transport {
+	return &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: config.InsecureSkipVerify,
+		},
+		DialContext:     dnsResolver.DialContext,
+		IdleConnTimeout: config.ClientIdleConnTimeout,
+	}
+}
@@ -12,6 +12,7 @@ var validate *validator.Validate
 
 func init() {
 	validate = validator.New(validator.WithRequiredStructEnabled())
+	registerValidations(validate)
 }
 
 func ValidateResponse(req *http.Request, response interface{}) error {
@@ -27,7 +28,7 @@ func ValidateResponse(req *http.Request, response interface{}) error {
 			errorFields = append(errorFields, err.Namespace())
 		}
 
-		return bencherror.NewEmptyHttpResponseError(errorFields, req)
+		return newFieldError(req, errorFields)
 	}
 
 	return nil
@@ -46,7 +47,7 @@ func ValidateSlice(req *http.Request, slice interface{}) error {
 			errorFields = append(errorFields, err.Namespace())
 		}
 
-		return bencherror.NewEmptyHttpResponseError(errorFields, req)
+		return newFieldError(req, errorFields)
 	}
 
 	return nil
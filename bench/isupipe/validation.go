@@ -0,0 +1,159 @@
+package isupipe
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/isucon/isucon13/bench/internal/bencherror"
+)
+
+// ValidationErrorKind は、レスポンスの検証に失敗した理由の種類です
+type ValidationErrorKind string
+
+const (
+	// 期待したステータスコードではない (一般エラー)
+	ValidationStatusCode ValidationErrorKind = "status-code"
+	// ボディをデコードできない (一般エラー)
+	ValidationDecode ValidationErrorKind = "decode"
+	// 必要なフィールドがない、または値が不正 (仕様違反)
+	ValidationField ValidationErrorKind = "field"
+	// 返してはいけないフィールドが含まれている (仕様違反)
+	ValidationSecretField ValidationErrorKind = "secret-field"
+	// 並び順など、レスポンス全体として満たすべき条件を満たさない (仕様違反)
+	ValidationInvariant ValidationErrorKind = "invariant"
+)
+
+// ValidationError は、レスポンスの検証に失敗した理由です
+// bencherrorで記録したエラーにラップして返すので、呼び出し側はerrors.Asで取り出して種類ごとに扱えます
+type ValidationError struct {
+	Kind ValidationErrorKind
+	// 不正なフィールド。フィールドに依らないときは空
+	Fields []string
+	// ValidationStatusCodeのときのみ
+	ExpectedStatusCode int
+	ActualStatusCode   int
+
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	switch {
+	case e.Kind == ValidationStatusCode:
+		return fmt.Sprintf("expected:%d, actual:%d", e.ExpectedStatusCode, e.ActualStatusCode)
+	case len(e.Fields) > 0 && e.Err != nil:
+		return fmt.Sprintf("%s: %s", strings.Join(e.Fields, ","), e.Err.Error())
+	case len(e.Fields) > 0:
+		return strings.Join(e.Fields, ",")
+	case e.Err != nil:
+		return e.Err.Error()
+	default:
+		return string(e.Kind)
+	}
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// UNIX時間 (秒) として妥当な範囲
+// ミリ秒で返したり、0のまま返したりしたものを弾く
+var (
+	minSaneUnixTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	maxSaneUnixTime = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+)
+
+// registerValidations は、レスポンスの構造体のタグで使う検証を登録します
+//
+//	id:       0より大きいID
+//	unixtime: UNIX時間 (秒) として妥当な日時
+func registerValidations(v *validator.Validate) {
+	v.RegisterAlias("id", "required,gt=0")
+	v.RegisterValidation("unixtime", func(fl validator.FieldLevel) bool {
+		var t int64
+		switch fl.Field().Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			t = fl.Field().Int()
+		default:
+			return false
+		}
+		return minSaneUnixTime <= t && t < maxSaneUnixTime
+	})
+}
+
+// invariantChecker は、タグでは表せない、レスポンス全体として満たすべき条件 (並び順など) を検証する型が実装します
+// sendJSONRequestは、デコードした後にresponseがこれを実装していれば検証します
+type invariantChecker interface {
+	checkInvariants() error
+}
+
+func newStatusCodeError(req *http.Request, expected int, actual int) error {
+	err := &ValidationError{
+		Kind:               ValidationStatusCode,
+		ExpectedStatusCode: expected,
+		ActualStatusCode:   actual,
+	}
+	return bencherror.NewHttpError(err, req, "期待されたHTTPステータスコードが確認できませんでした")
+}
+
+func newDecodeError(req *http.Request, err error) error {
+	return bencherror.NewHttpResponseError(&ValidationError{Kind: ValidationDecode, Err: err}, req)
+}
+
+func newFieldError(req *http.Request, fields []string) error {
+	err := &ValidationError{Kind: ValidationField, Fields: fields}
+	return bencherror.NewHttpViolationError(err, req, "レスポンスボディに必要なフィールドがないか、値が不正です")
+}
+
+func newSecretFieldError(req *http.Request, fields []string) error {
+	err := &ValidationError{Kind: ValidationSecretField, Fields: fields}
+	return bencherror.NewHttpViolationError(err, req, "レスポンスボディに返してはいけないフィールドが含まれています")
+}
+
+func newInvariantError(req *http.Request, err error) error {
+	return bencherror.NewHttpViolationError(&ValidationError{Kind: ValidationInvariant, Err: err}, req, "レスポンスボディが満たすべき条件を満たしていません")
+}
+
+// findSecretFields は、JSONをデコードした値vから、パスワードなど返してはいけないフィールドを探してその位置を返します
+func findSecretFields(v interface{}, path string) []string {
+	var found []string
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if strings.Contains(strings.ToLower(key), "password") {
+				found = append(found, path+"."+key)
+				continue
+			}
+			found = append(found, findSecretFields(value, path+"."+key)...)
+		}
+	case []interface{}:
+		for i, value := range v {
+			found = append(found, findSecretFields(value, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return found
+}
+
+// checkOrder は、n件の要素が並び順を満たしているかを検証します
+// inOrder(i)は、i-1番目とi番目の要素が正しい順に並んでいるかを返してください
+func checkOrder(name string, n int, inOrder func(i int) bool) error {
+	for i := 1; i < n; i++ {
+		if !inOrder(i) {
+			return fmt.Errorf("%sの%d番目と%d番目の並び順が不正です", name, i-1, i)
+		}
+	}
+	return nil
+}
+
+// checkRanks は、順位が1つずつ増えていくかを検証します
+func checkRanks(name string, first int64, n int, rank func(i int) int64) error {
+	for i := 0; i < n; i++ {
+		if want := first + int64(i); rank(i) != want {
+			return fmt.Errorf("%sの%d番目の順位が不正です (expected:%d, actual:%d)", name, i, want, rank(i))
+		}
+	}
+	return nil
+}
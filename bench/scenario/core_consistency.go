@@ -0,0 +1,144 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/isucon/isucandar/agent"
+	"github.com/isucon/isucon13/bench/internal/bencherror"
+	"github.com/isucon/isucon13/bench/internal/benchmodel"
+	"github.com/isucon/isucon13/bench/internal/config"
+	"github.com/isucon/isucon13/bench/internal/resolver"
+	"github.com/isucon/isucon13/bench/isupipe"
+	"go.uber.org/zap"
+)
+
+// ConsistencyScenario は、負荷走行中に記録した状態 (benchmodel) とwebappの状態を突き合わせます
+// 走行中に登録したユーザからいくつか選び、統計情報・売上・視聴者数・ランキングの順位を確かめます
+func ConsistencyScenario(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *resolver.DNSResolver) error {
+	users := benchmodel.Users()
+	if len(users) == 0 {
+		return nil
+	}
+	rand.Shuffle(len(users), func(i, j int) {
+		users[i], users[j] = users[j], users[i]
+	})
+	users = users[:min(len(users), config.NumConsistencyCheckUsers)]
+
+	// まとめて書き込まれる操作が反映されるのを待つ
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(config.ConsistencySettleTime):
+	}
+
+	ranks := make(map[string]int64, len(users))
+	for _, user := range users {
+		rank, err := assertUserConsistency(ctx, contestantLogger, dnsResolver, user)
+		if err != nil {
+			return err
+		}
+		ranks[user.Name] = rank
+	}
+
+	return assertRankingConsistency(users, ranks)
+}
+
+// assertUserConsistency は、ユーザとその配信の統計情報・売上が記録した状態と食い違っていないかを確かめ、ユーザのランキングの順位を返します
+func assertUserConsistency(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *resolver.DNSResolver, user *benchmodel.User) (int64, error) {
+	client, err := isupipe.NewCustomResolverClient(
+		contestantLogger,
+		dnsResolver,
+		agent.WithTimeout(config.ConsistencyCheckTimeout),
+	)
+	if err != nil {
+		return 0, err
+	}
+	if err := client.Login(ctx, &isupipe.LoginRequest{
+		Username: user.Name,
+		Password: user.Password,
+	}); err != nil {
+		return 0, bencherror.NewViolationError(err, "走行中に登録したユーザでログインできません (username=%s)", user.Name)
+	}
+
+	for _, livestream := range user.Livestreams {
+		stats, err := client.GetLivestreamStatistics(ctx, livestream.ID, user.Name, isupipe.WithFreshQueryParam())
+		if err != nil {
+			return 0, err
+		}
+		target := fmt.Sprintf("livestream_id=%d", livestream.ID)
+		if err := assertQuantity(target, "配信の統計情報のリアクション数", livestream.Reactions, stats.TotalReactions); err != nil {
+			return 0, err
+		}
+		if err := assertQuantity(target, "配信の統計情報の視聴者数", livestream.Viewers, stats.ViewersCount); err != nil {
+			return 0, err
+		}
+		if stats.MaxTip > livestream.Tips.Max(config.ConsistencyTolerance, config.ConsistencySlack) {
+			return 0, bencherror.NewViolationError(fmt.Errorf("%s, max_tip=%d, total_tip=%d", target, stats.MaxTip, livestream.Tips.Confirmed), "配信の統計情報の最大チップが、投稿されたチップの合計を超えています")
+		}
+	}
+
+	target := fmt.Sprintf("username=%s", user.Name)
+	stats, err := client.GetUserStatistics(ctx, user.Name, isupipe.WithFreshQueryParam())
+	if err != nil {
+		return 0, err
+	}
+	if err := assertQuantity(target, "ユーザの統計情報のリアクション数", user.Reactions, stats.TotalReactions); err != nil {
+		return 0, err
+	}
+	if err := assertQuantity(target, "ユーザの統計情報のチップの合計", user.Tips, stats.TotalTip); err != nil {
+		return 0, err
+	}
+	if err := assertQuantity(target, "ユーザの統計情報の視聴者数", user.Viewers, stats.ViewersCount); err != nil {
+		return 0, err
+	}
+
+	earnings, err := client.GetMyEarnings(ctx, isupipe.EarningsPeriodAll)
+	if err != nil {
+		return 0, err
+	}
+	if err := assertQuantity(target, "売上の合計", user.Tips, earnings.TotalTip); err != nil {
+		return 0, err
+	}
+	for _, earning := range earnings.Livestreams {
+		for _, livestream := range user.Livestreams {
+			if livestream.ID != earning.LivestreamID {
+				continue
+			}
+			if err := assertQuantity(fmt.Sprintf("livestream_id=%d", livestream.ID), "配信ごとの売上", livestream.Tips, earning.TotalTip); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return stats.Rank, nil
+}
+
+// assertRankingConsistency は、記録したスコアが明らかに高いユーザほど、ランキングの順位が上になっているかを確かめます
+// スコアの差が誤差の範囲に収まるユーザ同士は、順位を比べません
+func assertRankingConsistency(users []*benchmodel.User, ranks map[string]int64) error {
+	for _, higher := range users {
+		for _, lower := range users {
+			if higher.Score().Min(config.ConsistencyTolerance, config.ConsistencySlack) <= lower.Score().Max(config.ConsistencyTolerance, config.ConsistencySlack) {
+				continue
+			}
+			if ranks[higher.Name] >= ranks[lower.Name] {
+				return bencherror.NewViolationError(
+					fmt.Errorf("%s (score=%d, rank=%d), %s (score=%d, rank=%d)", higher.Name, higher.Score().Confirmed, ranks[higher.Name], lower.Name, lower.Score().Confirmed, ranks[lower.Name]),
+					"ユーザのランキングの順位が、スコアの順になっていません")
+			}
+		}
+	}
+	return nil
+}
+
+func assertQuantity(target string, name string, expected benchmodel.Quantity, actual int64) error {
+	if expected.Contains(actual, config.ConsistencyTolerance, config.ConsistencySlack) {
+		return nil
+	}
+	return bencherror.NewViolationError(
+		fmt.Errorf("%s, expected:%d..%d, actual:%d", target, expected.Min(config.ConsistencyTolerance, config.ConsistencySlack), expected.Max(config.ConsistencyTolerance, config.ConsistencySlack), actual),
+		"%sが、ベンチマーカーの操作と食い違っています", name)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/isucon/isucandar/agent"
 	"github.com/isucon/isucon13/bench/internal/config"
+	"github.com/isucon/isucon13/bench/internal/manifest"
 	"github.com/isucon/isucon13/bench/internal/resolver"
 	"github.com/isucon/isucon13/bench/isupipe"
 	"github.com/najeira/randstr"
@@ -63,7 +64,8 @@ func setupTestUser(ctx context.Context, contestantLogger *zap.Logger, dnsResolve
 }
 
 // 初期データチェック -> 基本的なエンドポイントの機能テスト -> 前後比較テスト
-func Pretest(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *resolver.DNSResolver) error {
+// initialDataには、datagenで生成した初期データのマニフェストを渡します。nilなら、マニフェストによる初期データのチェックを行いません
+func Pretest(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *resolver.DNSResolver, initialData *manifest.Manifest) error {
 	// dns 初期レコード
 	if err := dnsRecordPretest(ctx, dnsResolver); err != nil {
 		return err
@@ -75,6 +77,12 @@ func Pretest(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *res
 	if err := normalInitialPaymentPretest(ctx, contestantLogger, dnsResolver); err != nil {
 		return err
 	}
+	if initialData != nil {
+		// 他のチェックで書き込む前に確かめる
+		if err := seededInitialDataPretest(ctx, contestantLogger, dnsResolver, initialData); err != nil {
+			return err
+		}
+	}
 
 	// 統計情報
 	if err := normalStatsCalcPretest(ctx, contestantLogger, dnsResolver); err != nil {
@@ -107,13 +115,27 @@ func Pretest(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *res
 	}
 
 	// 異常系
-	if err := assertBadLogin(ctx, contestantLogger, dnsResolver); err != nil {
+	// ステータスコードに加えて、エラーのレスポンスのボディも確かめる
+	errCtx := isupipe.WithErrorResponseCheck(ctx)
+	if err := assertBadLogin(errCtx, contestantLogger, dnsResolver); err != nil {
+		return err
+	}
+	if err := assertPipeUserRegistration(errCtx, contestantLogger, dnsResolver); err != nil {
+		return err
+	}
+	if err := assertUserUniqueConstraint(errCtx, contestantLogger, dnsResolver); err != nil {
+		return err
+	}
+	if err := assertUnauthenticatedAccess(errCtx, contestantLogger, dnsResolver); err != nil {
 		return err
 	}
-	if err := assertPipeUserRegistration(ctx, contestantLogger, dnsResolver); err != nil {
+	if err := assertNotFound(errCtx, contestantLogger, dnsResolver); err != nil {
 		return err
 	}
-	if err := assertUserUniqueConstraint(ctx, contestantLogger, dnsResolver); err != nil {
+	if err := assertMalformedJSON(errCtx, contestantLogger, dnsResolver); err != nil {
+		return err
+	}
+	if err := assertOversizedIcon(errCtx, contestantLogger, dnsResolver); err != nil {
 		return err
 	}
 	if err := assertReserveOverflowPretest(ctx, contestantLogger, dnsResolver); err != nil {
@@ -122,6 +144,15 @@ func Pretest(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *res
 	if err := assertReserveOutOfTerm(ctx, contestantLogger, testUser, dnsResolver); err != nil {
 		return err
 	}
+	if err := assertPrivateLivestreamHidden(ctx, contestantLogger, dnsResolver); err != nil {
+		return err
+	}
+	if err := assertIngestKeyOwnerOnly(ctx, contestantLogger, dnsResolver); err != nil {
+		return err
+	}
+	if err := assertLivestreamSettingsConflict(ctx, contestantLogger, dnsResolver); err != nil {
+		return err
+	}
 	if err := assertMultipleEnterLivestream(ctx, dnsResolver); err != nil {
 		return err
 	}
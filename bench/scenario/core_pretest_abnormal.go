@@ -2,8 +2,11 @@ package scenario
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/isucon/isucandar/agent"
@@ -105,7 +108,7 @@ func assertUserUniqueConstraint(ctx context.Context, contestantLogger *zap.Logge
 		return err
 	}
 
-	if _, err := client.Register(ctx, &testDupReq, isupipe.WithStatusCode(http.StatusInternalServerError)); err != nil {
+	if _, err := client.Register(ctx, &testDupReq, isupipe.WithStatusCode(http.StatusConflict)); err != nil {
 		return fmt.Errorf("重複したユーザ名を含むリクエストはエラーを返さなければなりません: %w", err)
 	}
 
@@ -231,6 +234,316 @@ func assertReserveOutOfTerm(ctx context.Context, contestantLogger *zap.Logger, t
 	return nil
 }
 
+// registerPretestUser は、異常系の検証用にユーザを登録してログインしたクライアントを返します
+func registerPretestUser(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *resolver.DNSResolver, description string) (*isupipe.Client, *isupipe.User, error) {
+	client, err := isupipe.NewCustomResolverClient(
+		contestantLogger,
+		dnsResolver,
+		agent.WithTimeout(config.PretestTimeout),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name := randstr.String(12)
+	password := randstr.String(10)
+	user, err := client.Register(ctx, &isupipe.RegisterRequest{
+		Name:        name,
+		DisplayName: randDisplayName(),
+		Description: description,
+		Password:    password,
+		Theme: isupipe.Theme{
+			DarkMode: true,
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := client.Login(ctx, &isupipe.LoginRequest{
+		Username: user.Name,
+		Password: password,
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	return client, user, nil
+}
+
+func assertPrivateLivestreamHidden(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *resolver.DNSResolver) error {
+	// 非公開配信は配信者本人以外から見えてはならない
+	streamerClient, streamer, err := registerPretestUser(ctx, contestantLogger, dnsResolver, "非公開配信の検証をしています")
+	if err != nil {
+		return err
+	}
+
+	var (
+		startAt = time.Date(2024, 8, 1, 0, 0, 0, 0, time.Local)
+		endAt   = time.Date(2024, 8, 1, 1, 0, 0, 0, time.Local)
+	)
+	livestream, err := streamerClient.ReserveLivestream(ctx, streamer.Name, &isupipe.ReserveLivestreamRequest{
+		Title:         "private",
+		Description:   "private",
+		PlaylistUrl:   "https://media.xiii.isucon.dev/api/4/playlist.m3u8",
+		ThumbnailUrl:  "https://media.xiii.isucon.dev/isucon12_final.webp",
+		StartAt:       startAt.Unix(),
+		EndAt:         endAt.Unix(),
+		Tags:          []int64{},
+		PrivacyStatus: "private",
+	})
+	if err != nil {
+		return err
+	}
+
+	// 配信者本人は閲覧できる
+	if _, err := streamerClient.GetLivestream(ctx, livestream.ID, streamer.Name); err != nil {
+		return err
+	}
+
+	viewerClient, _, err := registerPretestUser(ctx, contestantLogger, dnsResolver, "非公開配信の検証をしています")
+	if err != nil {
+		return err
+	}
+
+	if _, err := viewerClient.GetLivestream(ctx, livestream.ID, streamer.Name, isupipe.WithStatusCode(http.StatusNotFound)); err != nil {
+		return fmt.Errorf("非公開配信が配信者以外から閲覧できてしまいます: %w", err)
+	}
+
+	livestreams, err := viewerClient.GetUserLivestreams(ctx, streamer.Name)
+	if err != nil {
+		return err
+	}
+	for _, ls := range livestreams {
+		if ls.ID == livestream.ID {
+			return fmt.Errorf("非公開配信が配信者以外の配信一覧に含まれています (livestream_id=%d)", livestream.ID)
+		}
+	}
+
+	return nil
+}
+
+func assertIngestKeyOwnerOnly(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *resolver.DNSResolver) error {
+	// ストリームキーは配信者本人にのみ返されなければならない
+	streamerClient, streamer, err := registerPretestUser(ctx, contestantLogger, dnsResolver, "ストリームキーの検証をしています")
+	if err != nil {
+		return err
+	}
+
+	var (
+		startAt = time.Date(2024, 8, 2, 0, 0, 0, 0, time.Local)
+		endAt   = time.Date(2024, 8, 2, 1, 0, 0, 0, time.Local)
+	)
+	livestream, err := streamerClient.ReserveLivestream(ctx, streamer.Name, &isupipe.ReserveLivestreamRequest{
+		Title:        "ingest",
+		Description:  "ingest",
+		PlaylistUrl:  "https://media.xiii.isucon.dev/api/4/playlist.m3u8",
+		ThumbnailUrl: "https://media.xiii.isucon.dev/isucon12_final.webp",
+		StartAt:      startAt.Unix(),
+		EndAt:        endAt.Unix(),
+		Tags:         []int64{},
+	})
+	if err != nil {
+		return err
+	}
+
+	ingest, err := streamerClient.GetLivestreamIngest(ctx, livestream.ID, streamer.Name)
+	if err != nil {
+		return err
+	}
+	if livestream.IngestKey != "" && ingest.IngestKey != livestream.IngestKey {
+		return fmt.Errorf("配信予約時と異なるストリームキーが返されています (livestream_id=%d)", livestream.ID)
+	}
+
+	rotated, err := streamerClient.RotateLivestreamIngest(ctx, livestream.ID, streamer.Name)
+	if err != nil {
+		return err
+	}
+	if rotated.IngestKey == ingest.IngestKey {
+		return fmt.Errorf("ストリームキーが再発行されていません (livestream_id=%d)", livestream.ID)
+	}
+
+	otherClient, _, err := registerPretestUser(ctx, contestantLogger, dnsResolver, "ストリームキーの検証をしています")
+	if err != nil {
+		return err
+	}
+	if _, err := otherClient.GetLivestreamIngest(ctx, livestream.ID, streamer.Name, isupipe.WithStatusCode(http.StatusForbidden)); err != nil {
+		return fmt.Errorf("ストリームキーが配信者以外から取得できてしまいます: %w", err)
+	}
+	if _, err := otherClient.RotateLivestreamIngest(ctx, livestream.ID, streamer.Name, isupipe.WithStatusCode(http.StatusForbidden)); err != nil {
+		return fmt.Errorf("ストリームキーが配信者以外から再発行できてしまいます: %w", err)
+	}
+
+	return nil
+}
+
 func assertMultipleEnterLivestream(ctx context.Context, dnsResolver *resolver.DNSResolver) error {
 	return nil
 }
+
+func assertLivestreamSettingsConflict(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *resolver.DNSResolver) error {
+	// 同じ版を前提とした配信設定の更新は、一方のみが成功しなければならない
+	streamerClient, streamer, err := registerPretestUser(ctx, contestantLogger, dnsResolver, "配信設定の同時更新の検証をしています")
+	if err != nil {
+		return err
+	}
+
+	var (
+		startAt = time.Date(2024, 8, 3, 0, 0, 0, 0, time.Local)
+		endAt   = time.Date(2024, 8, 3, 1, 0, 0, 0, time.Local)
+	)
+	livestream, err := streamerClient.ReserveLivestream(ctx, streamer.Name, &isupipe.ReserveLivestreamRequest{
+		Title:        "settings",
+		Description:  "settings",
+		PlaylistUrl:  "https://media.xiii.isucon.dev/api/4/playlist.m3u8",
+		ThumbnailUrl: "https://media.xiii.isucon.dev/isucon12_final.webp",
+		StartAt:      startAt.Unix(),
+		EndAt:        endAt.Unix(),
+		Tags:         []int64{},
+	})
+	if err != nil {
+		return err
+	}
+
+	first, err := streamerClient.PutLivestreamSettings(ctx, livestream.ID, streamer.Name, &isupipe.PutLivestreamSettingsRequest{
+		SlowModeSeconds: 1,
+	})
+	if err != nil {
+		return err
+	}
+
+	// 同じ版を前提に2つの更新を同時に送る
+	var (
+		wg      sync.WaitGroup
+		results = make([]*isupipe.LivestreamSettings, 2)
+		errs    = make([]error, 2)
+		version = first.Version
+	)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = streamerClient.PutLivestreamSettings(ctx, livestream.ID, streamer.Name, &isupipe.PutLivestreamSettingsRequest{
+				SlowModeSeconds: int64(i + 2),
+				Version:         &version,
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	var succeeded *isupipe.LivestreamSettings
+	for i, err := range errs {
+		if err == nil {
+			if succeeded != nil {
+				return fmt.Errorf("同じ版を前提とした配信設定の更新が両方とも成功しています (livestream_id=%d)", livestream.ID)
+			}
+			succeeded = results[i]
+		}
+	}
+	if succeeded == nil {
+		return fmt.Errorf("同じ版を前提とした配信設定の更新が両方とも失敗しています (livestream_id=%d): %w", livestream.ID, errors.Join(errs...))
+	}
+	if succeeded.Version == first.Version {
+		return fmt.Errorf("配信設定を更新しても版が変わっていません (livestream_id=%d)", livestream.ID)
+	}
+
+	// 古い版を前提とした更新は拒否される
+	if _, err := streamerClient.PutLivestreamSettings(ctx, livestream.ID, streamer.Name, &isupipe.PutLivestreamSettingsRequest{
+		SlowModeSeconds: 4,
+		Version:         &version,
+	}, isupipe.WithStatusCode(http.StatusPreconditionFailed)); err != nil {
+		return fmt.Errorf("古い版を前提とした配信設定の更新が拒否されていません: %w", err)
+	}
+
+	return nil
+}
+
+func assertUnauthenticatedAccess(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *resolver.DNSResolver) error {
+	// ログインしていないクライアントは、ログインが必要なエンドポイントを使えない
+	client, err := isupipe.NewCustomResolverClient(
+		contestantLogger,
+		dnsResolver,
+		agent.WithTimeout(config.PretestTimeout),
+	)
+	if err != nil {
+		return bencherror.NewInternalError(err)
+	}
+	// セッションが無いのか不正なのかで401と403のどちらも返しうるので、どちらも認める
+	statusCode, err := client.SendRawRequest(ctx, http.MethodGet, "/api/user/me", "", nil)
+	if err != nil {
+		return err
+	}
+	if statusCode != http.StatusUnauthorized && statusCode != http.StatusForbidden {
+		return bencherror.NewViolationError(fmt.Errorf("expected:401 or 403, actual:%d", statusCode), "ログインしていないユーザが、自分の情報を取得できてしまいます")
+	}
+
+	// 管理者以外は、管理者用のエンドポイントを使えない
+	userClient, _, err := registerPretestUser(ctx, contestantLogger, dnsResolver, "認可の検証をしています")
+	if err != nil {
+		return err
+	}
+	if _, err := userClient.GetFeatureFlags(ctx, isupipe.WithStatusCode(http.StatusForbidden)); err != nil {
+		return bencherror.NewViolationError(err, "管理者ではないユーザが、機能フラグを取得できてしまいます")
+	}
+
+	return nil
+}
+
+func assertNotFound(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *resolver.DNSResolver) error {
+	// 存在しないユーザ・配信は404を返さなければならない
+	client, user, err := registerPretestUser(ctx, contestantLogger, dnsResolver, "存在しないリソースの検証をしています")
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.GetUser(ctx, "unknownUser4328904823", isupipe.WithStatusCode(http.StatusNotFound)); err != nil {
+		return bencherror.NewViolationError(err, "存在しないユーザの情報が返されています")
+	}
+	if _, err := client.GetLivestream(ctx, math.MaxInt32, user.Name, isupipe.WithStatusCode(http.StatusNotFound)); err != nil {
+		return bencherror.NewViolationError(err, "存在しない配信の情報が返されています")
+	}
+
+	return nil
+}
+
+func assertMalformedJSON(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *resolver.DNSResolver) error {
+	// JSONとして読めないリクエストボディは400を返さなければならない
+	client, err := isupipe.NewCustomResolverClient(
+		contestantLogger,
+		dnsResolver,
+		agent.WithTimeout(config.PretestTimeout),
+	)
+	if err != nil {
+		return bencherror.NewInternalError(err)
+	}
+
+	malformed := []byte(`{"name": "malformed", "password": `)
+	for _, urlPath := range []string{"/api/register", "/api/login"} {
+		statusCode, err := client.SendRawRequest(ctx, http.MethodPost, urlPath, "application/json;charset=utf-8", malformed)
+		if err != nil {
+			return err
+		}
+		if statusCode != http.StatusBadRequest {
+			return bencherror.NewViolationError(fmt.Errorf("POST %s: expected:%d, actual:%d", urlPath, http.StatusBadRequest, statusCode), "JSONとして不正なリクエストボディが拒否されていません")
+		}
+	}
+
+	return nil
+}
+
+// webappのアイコン画像の上限 (デフォルトで1MiB) を確実に超える大きさ
+const oversizedIconSize = 2 << 20
+
+func assertOversizedIcon(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *resolver.DNSResolver) error {
+	// 大きすぎるアイコン画像は413を返さなければならない
+	client, _, err := registerPretestUser(ctx, contestantLogger, dnsResolver, "アイコン画像の上限の検証をしています")
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.PostIcon(ctx, &isupipe.PostIconRequest{
+		Image: make([]byte, oversizedIconSize),
+	}, isupipe.WithStatusCode(http.StatusRequestEntityTooLarge)); err != nil {
+		return bencherror.NewViolationError(err, "大きすぎるアイコン画像が拒否されていません")
+	}
+
+	return nil
+}
@@ -3,9 +3,13 @@ package scenario
 import (
 	"context"
 	"fmt"
+	"math/rand"
+	"slices"
 
 	"github.com/isucon/isucandar/agent"
+	"github.com/isucon/isucon13/bench/internal/bencherror"
 	"github.com/isucon/isucon13/bench/internal/config"
+	"github.com/isucon/isucon13/bench/internal/manifest"
 	"github.com/isucon/isucon13/bench/internal/resolver"
 	"github.com/isucon/isucon13/bench/isupipe"
 	"go.uber.org/zap"
@@ -35,3 +39,161 @@ func normalInitialPaymentPretest(ctx context.Context, contestantLogger *zap.Logg
 
 	return nil
 }
+
+// seededInitialDataPretest は、マニフェストに記載された初期データのユーザ・ライブ配信が、記載どおりの値で存在するかを確かめます
+// 初期データを消したり書き換えたりしていないかを、いくつか選んだものについて確かめます
+func seededInitialDataPretest(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *resolver.DNSResolver, m *manifest.Manifest) error {
+	for _, idx := range sampleSeededIndexes(len(m.Users), config.NumSeededUserChecks) {
+		if err := assertSeededUser(ctx, contestantLogger, dnsResolver, m.Users[idx]); err != nil {
+			return err
+		}
+	}
+
+	if len(m.Users) == 0 {
+		return nil
+	}
+	client, err := loginSeededUser(ctx, contestantLogger, dnsResolver, m.Users[0])
+	if err != nil {
+		return err
+	}
+	for _, idx := range sampleSeededIndexes(len(m.Livestreams), config.NumSeededLivestreamChecks) {
+		if err := assertSeededLivestream(ctx, client, m, m.Livestreams[idx]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sampleSeededIndexes は、n件のうち確かめるもののインデックスを、最大limit件返します
+// 末尾が消されたことにも気づけるよう、最初と最後は必ず含めます
+func sampleSeededIndexes(n, limit int) []int {
+	if n <= limit {
+		indexes := make([]int, n)
+		for i := range indexes {
+			indexes[i] = i
+		}
+		return indexes
+	}
+	indexes := []int{0, n - 1}
+	for _, i := range rand.Perm(n - 2)[:max(limit-2, 0)] {
+		indexes = append(indexes, i+1)
+	}
+	return indexes
+}
+
+// loginSeededUser は、初期データのユーザでログインしたクライアントを返します
+// ログインできることが、パスワードが書き換えられていないことの確認になります
+func loginSeededUser(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *resolver.DNSResolver, user manifest.User) (*isupipe.Client, error) {
+	client, err := isupipe.NewCustomResolverClient(
+		contestantLogger,
+		dnsResolver,
+		agent.WithTimeout(config.PretestTimeout),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Login(ctx, &isupipe.LoginRequest{
+		Username: user.Name,
+		Password: user.Password,
+	}); err != nil {
+		return nil, bencherror.NewViolationError(err, "初期データのユーザでログインできません (user_id=%d)", user.ID)
+	}
+	return client, nil
+}
+
+func assertSeededUser(ctx context.Context, contestantLogger *zap.Logger, dnsResolver *resolver.DNSResolver, expected manifest.User) error {
+	client, err := loginSeededUser(ctx, contestantLogger, dnsResolver, expected)
+	if err != nil {
+		return err
+	}
+
+	user, err := client.GetUser(ctx, expected.Name)
+	if err != nil {
+		return bencherror.NewViolationError(err, "初期データのユーザを取得できません (user_id=%d)", expected.ID)
+	}
+	target := fmt.Sprintf("user_id=%d", expected.ID)
+	if err := assertSeededField(target, "id", expected.ID, user.ID); err != nil {
+		return err
+	}
+	if err := assertSeededField(target, "display_name", expected.DisplayName, user.DisplayName); err != nil {
+		return err
+	}
+	if err := assertSeededField(target, "description", expected.Description, user.Description); err != nil {
+		return err
+	}
+	if err := assertSeededField(target, "theme.dark_mode", expected.DarkMode, user.Theme.DarkMode); err != nil {
+		return err
+	}
+
+	stats, err := client.GetUserStatistics(ctx, expected.Name, isupipe.WithFreshQueryParam())
+	if err != nil {
+		return err
+	}
+	if err := assertSeededField(target, "total_reactions", expected.TotalReactions, stats.TotalReactions); err != nil {
+		return err
+	}
+	if err := assertSeededField(target, "total_livecomments", expected.TotalLivecomments, stats.TotalLivecomments); err != nil {
+		return err
+	}
+	if err := assertSeededField(target, "total_tip", expected.TotalTip, stats.TotalTip); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func assertSeededLivestream(ctx context.Context, client *isupipe.Client, m *manifest.Manifest, expected manifest.Livestream) error {
+	owner, ok := m.User(expected.UserID)
+	if !ok {
+		return bencherror.NewInternalError(fmt.Errorf("マニフェストに配信者が含まれていません (livestream_id=%d, user_id=%d)", expected.ID, expected.UserID))
+	}
+
+	livestream, err := client.GetLivestream(ctx, expected.ID, owner.Name)
+	if err != nil {
+		return bencherror.NewViolationError(err, "初期データのライブ配信を取得できません (livestream_id=%d)", expected.ID)
+	}
+	target := fmt.Sprintf("livestream_id=%d", expected.ID)
+	if err := assertSeededField(target, "owner.id", expected.UserID, livestream.Owner.ID); err != nil {
+		return err
+	}
+	if err := assertSeededField(target, "title", expected.Title, livestream.Title); err != nil {
+		return err
+	}
+	if err := assertSeededField(target, "start_at", expected.StartAt, livestream.StartAt); err != nil {
+		return err
+	}
+	if err := assertSeededField(target, "end_at", expected.EndAt, livestream.EndAt); err != nil {
+		return err
+	}
+	var tagIDs []int64
+	for _, tag := range livestream.Tags {
+		tagIDs = append(tagIDs, tag.ID)
+	}
+	expectedTagIDs := slices.Clone(expected.TagIDs)
+	slices.Sort(tagIDs)
+	slices.Sort(expectedTagIDs)
+	if !slices.Equal(expectedTagIDs, tagIDs) {
+		return bencherror.NewViolationError(fmt.Errorf("%s, field=tags, expected:%v, actual:%v", target, expectedTagIDs, tagIDs), "初期データが書き換えられています")
+	}
+
+	stats, err := client.GetLivestreamStatistics(ctx, expected.ID, owner.Name, isupipe.WithFreshQueryParam())
+	if err != nil {
+		return err
+	}
+	if err := assertSeededField(target, "total_reactions", expected.TotalReactions, stats.TotalReactions); err != nil {
+		return err
+	}
+	if err := assertSeededField(target, "max_tip", expected.MaxTip, stats.MaxTip); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func assertSeededField[T comparable](target string, field string, expected T, actual T) error {
+	if expected == actual {
+		return nil
+	}
+	return bencherror.NewViolationError(fmt.Errorf("%s, field=%s, expected:%v, actual:%v", target, field, expected, actual), "初期データが書き換えられています")
+}
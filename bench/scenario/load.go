@@ -0,0 +1,91 @@
+package scenario
+
+import (
+	"context"
+	"time"
+)
+
+// 負荷走行で走らせるシナリオ
+// 新しいシナリオは、ここか各シナリオのファイルのinitでRegisterしてください
+func init() {
+	Register(Definition{
+		Name:     "streamer-cold-reserve",
+		Weight:   1,
+		Requires: RequireChannel,
+		Run: func(ctx context.Context, env *Env) error {
+			return BasicStreamerColdReserveScenario(ctx, env.ContestantLogger, env.StreamerPool, env.LivestreamPool)
+		},
+	})
+	// 新しい配信者がチャンネルを開設する。予約した配信は他のシナリオの対象にもなる
+	Register(Definition{
+		Name:   "streamer-owner",
+		Weight: 1,
+		Run: func(ctx context.Context, env *Env) error {
+			return OwnerStreamerScenario(ctx, env.ContestantLogger, env.AgentFactory, env.StreamerPool, env.LivestreamPool)
+		},
+	})
+	// moderateが成功するなら可能な限り高速にmoderationしなければならない
+	Register(Definition{
+		Name:     "streamer-moderate",
+		Weight:   1,
+		Requires: RequireChannel,
+		Run: func(ctx context.Context, env *Env) error {
+			return BasicStreamerModerateScenario(ctx, env.ContestantLogger, env.StreamerPool)
+		},
+	})
+	Register(Definition{
+		Name:     "viewer",
+		Weight:   10, // 配信者の10倍視聴者トラフィックがある
+		Requires: RequireViewer | RequireLivestream,
+		Run: func(ctx context.Context, env *Env) error {
+			return BasicViewerScenario(ctx, env.ContestantLogger, env.ViewerPool, env.LivestreamPool)
+		},
+	})
+	Register(Definition{
+		Name:     "viewer-watch",
+		Weight:   5,
+		Requires: RequireViewerAccount | RequireLivestream,
+		Run: func(ctx context.Context, env *Env) error {
+			return WatchingViewerScenario(ctx, env.ContestantLogger, env.AgentFactory, env.ViewerIdentityPool, env.LivestreamPool)
+		},
+	})
+	Register(Definition{
+		Name:     "viewer-report",
+		Weight:   1,
+		Requires: RequireViewer | RequireSpam,
+		Run: func(ctx context.Context, env *Env) error {
+			// XXX: report回りすぎ抑止
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(1 * time.Second):
+			}
+			return BasicViewerReportScenario(ctx, env.ContestantLogger, env.ViewerPool, env.SpamPool)
+		},
+	})
+	// 視聴者の２倍はスパム投稿者が潜んでおり、同じだけ配信者がスパムを見張っている
+	Register(Definition{
+		Name:     "viewer-spam",
+		Weight:   2,
+		Requires: RequireViewer | RequireLivestream,
+		Run: func(ctx context.Context, env *Env) error {
+			return ViewerSpamScenario(ctx, env.ContestantLogger, env.ViewerPool, env.LivestreamPool, env.SpamPool)
+		},
+	})
+	Register(Definition{
+		Name:     "spam-abuse",
+		Weight:   2,
+		Requires: RequireChannel | RequireViewer,
+		Run: func(ctx context.Context, env *Env) error {
+			return SpamAbuseScenario(ctx, env.ContestantLogger, env.StreamerPool, env.ViewerPool)
+		},
+	})
+	Register(Definition{
+		Name:     "aggressive-streamer-moderate",
+		Weight:   2,
+		Requires: RequireChannel,
+		Run: func(ctx context.Context, env *Env) error {
+			return AggressiveStreamerModerateScenario(ctx, env.ContestantLogger, env.StreamerPool)
+		},
+	})
+}
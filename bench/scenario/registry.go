@@ -0,0 +1,142 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/isucon/isucon13/bench/isupipe"
+	"go.uber.org/zap"
+)
+
+// Precondition は、負荷走行のシナリオを走らせる前に満たしているべき条件です
+// 複数の条件はORで組み合わせて指定します
+type Precondition uint
+
+const (
+	// ログイン済みの視聴者がいる
+	RequireViewer Precondition = 1 << iota
+	// チャンネルを持つ、ログイン済みの配信者がいる
+	RequireChannel
+	// 予約済みの配信がある
+	RequireLivestream
+	// 報告できるスパムがある
+	RequireSpam
+	// 新しいセッションでログインし直せる、登録済みの視聴者がいる
+	RequireViewerAccount
+)
+
+// Env は、負荷走行のシナリオが共有するロガーとプールです
+type Env struct {
+	ContestantLogger *zap.Logger
+
+	StreamerPool   *isupipe.ClientPool
+	ViewerPool     *isupipe.ClientPool
+	LivestreamPool *isupipe.LivestreamPool
+	SpamPool       *isupipe.LivecommentPool
+
+	AgentFactory       *isupipe.AgentFactory
+	ViewerIdentityPool *isupipe.IdentityPool
+}
+
+// Satisfies は、preconditionの条件をすべて満たしているかを返します
+func (e *Env) Satisfies(precondition Precondition) bool {
+	if precondition&RequireViewer != 0 && e.ViewerPool.Len() == 0 {
+		return false
+	}
+	if precondition&RequireChannel != 0 && e.StreamerPool.Len() == 0 {
+		return false
+	}
+	if precondition&RequireLivestream != 0 && e.LivestreamPool.Len() == 0 {
+		return false
+	}
+	if precondition&RequireSpam != 0 && e.SpamPool.Len() == 0 {
+		return false
+	}
+	if precondition&RequireViewerAccount != 0 && e.ViewerIdentityPool.Len() == 0 {
+		return false
+	}
+	return true
+}
+
+// Definition は、負荷走行で走らせるシナリオです
+type Definition struct {
+	// シナリオカウンタのタグに使います。失敗は "<Name>-fail" で数えます
+	Name string
+	// 選ばれやすさ。条件を満たすシナリオの中から、重みに比例する確率で選びます
+	Weight int
+	// 走らせる前に満たしているべき条件
+	Requires Precondition
+
+	Run func(ctx context.Context, env *Env) error
+}
+
+// Registry は、負荷走行で走らせるシナリオの一覧です
+// NOTE: Registerは負荷走行を始める前に済ませてください。走行中のRegisterはスレッドセーフではありません
+type Registry struct {
+	definitions []Definition
+}
+
+// DefaultRegistry は、ベンチマーカーが負荷走行で使うシナリオの一覧です
+var DefaultRegistry = &Registry{}
+
+// Register は、DefaultRegistryにシナリオを追加します
+func Register(def Definition) {
+	DefaultRegistry.Register(def)
+}
+
+// Register は、シナリオを追加します
+// 名前の重複や0以下の重みは登録の誤りなので、panicします
+func (r *Registry) Register(def Definition) {
+	if def.Weight <= 0 {
+		panic(fmt.Sprintf("scenario %s: weight must be positive", def.Name))
+	}
+	if def.Run == nil {
+		panic(fmt.Sprintf("scenario %s: run must be set", def.Name))
+	}
+	for _, registered := range r.definitions {
+		if registered.Name == def.Name {
+			panic(fmt.Sprintf("scenario %s: already registered", def.Name))
+		}
+	}
+	r.definitions = append(r.definitions, def)
+}
+
+// Definitions は、登録されたシナリオを登録順に返します
+func (r *Registry) Definitions() []Definition {
+	return r.definitions
+}
+
+// Pick は、envで条件を満たすシナリオから、重みに比例する確率で1つ選びます
+// 条件を満たすシナリオがなければfalseを返します
+func (r *Registry) Pick(env *Env) (Definition, bool) {
+	var (
+		candidates  = make([]Definition, 0, len(r.definitions))
+		totalWeight int
+	)
+	// 同じ条件を何度も確かめないようにする
+	satisfied := make(map[Precondition]bool)
+	for _, def := range r.definitions {
+		ok, checked := satisfied[def.Requires]
+		if !checked {
+			ok = env.Satisfies(def.Requires)
+			satisfied[def.Requires] = ok
+		}
+		if ok {
+			candidates = append(candidates, def)
+			totalWeight += def.Weight
+		}
+	}
+	if len(candidates) == 0 {
+		return Definition{}, false
+	}
+
+	n := rand.Intn(totalWeight)
+	for _, def := range candidates {
+		if n < def.Weight {
+			return def, true
+		}
+		n -= def.Weight
+	}
+	return candidates[len(candidates)-1], true
+}
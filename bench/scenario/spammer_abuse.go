@@ -0,0 +1,202 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"slices"
+	"sync/atomic"
+
+	"github.com/isucon/isucon13/bench/internal/bencherror"
+	"github.com/isucon/isucon13/bench/internal/benchscore"
+	"github.com/isucon/isucon13/bench/internal/scheduler"
+	"github.com/isucon/isucon13/bench/isupipe"
+	"go.uber.org/zap"
+)
+
+const (
+	// NGワードを含むライブコメントを続けて投稿する数
+	abuseNgWordPosts = 3
+	// 同じ内容のライブコメントを続けて投稿する数
+	abuseDuplicatePosts = 5
+)
+
+// 連投するスパムに埋め込む、他と被らない印の通し番号
+var abuseSpamSeq int64
+
+// SpamAbuseScenario は、スパム投稿者が配信を荒らし、配信者がモデレーションで対処する一連の行動です
+//
+//   - 配信者が登録したNGワードを含むライブコメントは、投稿を拒否されなければならない
+//   - 同じ内容の連投は受け付けられるが、スパムらしさが高く見積もられ、NGワードを登録すれば削除されなければならない
+//
+// 拒否・削除できたスパムは加点し、すり抜けたスパムは一般エラーとして減点します
+func SpamAbuseScenario(
+	ctx context.Context,
+	contestantLogger *zap.Logger,
+	streamerPool *isupipe.ClientPool,
+	viewerPool *isupipe.ClientPool,
+) error {
+	lgr := zap.S()
+
+	streamer, err := streamerPool.Get(ctx)
+	if err != nil {
+		lgr.Warnf("abuse: failed to get streamer from pool: %s\n", err.Error())
+		return err
+	}
+	streamerPool.Put(ctx, streamer) // 他のシナリオも使えるようにプールにすぐもどす
+
+	livestreams, err := streamer.GetMyLivestreams(ctx)
+	if err != nil {
+		lgr.Warnf("abuse: failed to get my livestreams: %s\n", err.Error())
+		return err
+	}
+	if len(livestreams) == 0 {
+		// まだ予約していない配信者は荒らされない
+		return nil
+	}
+	livestream := livestreams[rand.Intn(len(livestreams))]
+
+	spammer, err := viewerPool.Get(ctx)
+	if err != nil {
+		lgr.Warnf("abuse: failed to get spammer from pool: %s\n", err.Error())
+		return err
+	}
+	defer viewerPool.Put(ctx, spammer)
+
+	// 登録済みのNGワードを含むライブコメントは、拒否されなければならない
+	ngWord := scheduler.LivecommentScheduler.GetDummyNgWord()
+	if err := streamer.Moderate(ctx, livestream.ID, livestream.Owner.Name, ngWord.Word); err != nil {
+		lgr.Warnf("abuse: failed to register ngword: %s\n", err.Error())
+		return err
+	}
+	scheduler.LivecommentScheduler.ModerateNgWord(ngWord.Word)
+	for i := 0; i < abuseNgWordPosts; i++ {
+		comment := fmt.Sprintf("%s %s", scheduler.LivecommentScheduler.GetLongPositiveComment().Comment, ngWord.Word)
+		_, _, err := spammer.PostLivecomment(ctx, livestream.ID, livestream.Owner.Name, comment, &scheduler.Tip{}, isupipe.WithStatusCode(http.StatusBadRequest))
+		if err != nil {
+			var validationErr *isupipe.ValidationError
+			if errors.As(err, &validationErr) && validationErr.Kind == isupipe.ValidationStatusCode && validationErr.ActualStatusCode == http.StatusCreated {
+				// ステータスコードの誤りとして減点済み
+				lgr.Warnf("abuse: livecomment with ngword was accepted: livestream_id=%d\n", livestream.ID)
+				continue
+			}
+			if errors.Is(err, bencherror.ErrTimeout) {
+				continue
+			}
+			return err
+		}
+		benchscore.AddAction(ctx, benchscore.ActionSpamBlocked)
+	}
+
+	// 同じ内容の連投は、後の投稿ほどスパムらしいと見積もられなければならない
+	mark := fmt.Sprintf("spam%d", atomic.AddInt64(&abuseSpamSeq, 1))
+	comment := fmt.Sprintf("%s %s", scheduler.LivecommentScheduler.GetLongPositiveComment().Comment, mark)
+	var posted []int64
+	for i := 0; i < abuseDuplicatePosts; i++ {
+		resp, _, err := spammer.PostLivecomment(ctx, livestream.ID, livestream.Owner.Name, comment, &scheduler.Tip{})
+		if err != nil {
+			if errors.Is(err, bencherror.ErrTimeout) {
+				continue
+			}
+			lgr.Warnf("abuse: failed to post duplicate spam: %s\n", err.Error())
+			return err
+		}
+		posted = append(posted, resp.ID)
+	}
+	if len(posted) == 0 {
+		return nil
+	}
+
+	moderation, err := streamer.GetModerationLivecomments(ctx, livestream.ID, livestream.Owner.Name)
+	if err != nil && !errors.Is(err, bencherror.ErrTimeout) {
+		lgr.Warnf("abuse: failed to get moderation livecomments: %s\n", err.Error())
+		return err
+	}
+	if err == nil {
+		if err := checkDuplicateSpamScores(moderation, posted); err != nil {
+			return err
+		}
+	}
+
+	// 削除されたかは視聴者向けの一覧で確かめる。自動で非表示になった投稿は一覧に載らず確かめられないので、登録前に一覧に載っていた投稿だけを対象にする
+	visible, err := visibleLivecommentIDs(ctx, spammer, livestream.ID, livestream.Owner.Name, posted)
+	if err != nil {
+		if errors.Is(err, bencherror.ErrTimeout) {
+			return nil
+		}
+		lgr.Warnf("abuse: failed to get livecomments: %s\n", err.Error())
+		return err
+	}
+
+	// 連投に含まれる印をNGワードに登録すると、既に投稿された連投は削除されなければならない
+	if err := streamer.Moderate(ctx, livestream.ID, livestream.Owner.Name, mark); err != nil {
+		if errors.Is(err, bencherror.ErrTimeout) {
+			return nil
+		}
+		lgr.Warnf("abuse: failed to moderate duplicate spam: %s\n", err.Error())
+		return err
+	}
+	remaining, err := visibleLivecommentIDs(ctx, spammer, livestream.ID, livestream.Owner.Name, visible)
+	if err != nil {
+		if errors.Is(err, bencherror.ErrTimeout) {
+			return nil
+		}
+		lgr.Warnf("abuse: failed to get livecomments: %s\n", err.Error())
+		return err
+	}
+	var missed int
+	for _, id := range visible {
+		if slices.Contains(remaining, id) {
+			missed++
+			continue
+		}
+		benchscore.AddAction(ctx, benchscore.ActionSpamBlocked)
+	}
+	if missed > 0 {
+		return bencherror.NewApplicationError(fmt.Errorf("livestream_id=%d, ngword=%s, missed=%d", livestream.ID, mark, missed), "NGワードを登録しても、そのNGワードを含むスパムが削除されていません")
+	}
+
+	return nil
+}
+
+// visibleLivecommentIDs は、idsのうち、視聴者向けのライブコメントの一覧に載っているものを返します
+func visibleLivecommentIDs(ctx context.Context, client *isupipe.Client, livestreamID int64, streamerName string, ids []int64) ([]int64, error) {
+	livecomments, err := client.GetLivecomments(ctx, livestreamID, streamerName)
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[int64]struct{}, len(livecomments))
+	for _, livecomment := range livecomments {
+		listed[livecomment.ID] = struct{}{}
+	}
+	var visible []int64
+	for _, id := range ids {
+		if _, ok := listed[id]; ok {
+			visible = append(visible, id)
+		}
+	}
+	return visible, nil
+}
+
+// checkDuplicateSpamScores は、同じ内容の連投のうち、最後の投稿が最初の投稿よりスパムらしいと見積もられているかを確かめます
+// モデレーション用の一覧に載っていない投稿は確かめません
+func checkDuplicateSpamScores(moderation []*isupipe.ModerationLivecomment, posted []int64) error {
+	if len(posted) < 2 {
+		return nil
+	}
+	scores := make(map[int64]float64, len(moderation))
+	for _, livecomment := range moderation {
+		scores[livecomment.ID] = livecomment.SpamScore
+	}
+	first, firstOk := scores[posted[0]]
+	last, lastOk := scores[posted[len(posted)-1]]
+	if !firstOk || !lastOk {
+		return nil
+	}
+	if last <= first {
+		return bencherror.NewAssertionError(fmt.Errorf("first=%f, last=%f", first, last), "同じ内容の連投が、スパムらしいと見積もられていません")
+	}
+	return nil
+}
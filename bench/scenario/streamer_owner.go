@@ -0,0 +1,205 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/isucon/isucon13/bench/internal/bencherror"
+	"github.com/isucon/isucon13/bench/internal/scheduler"
+	"github.com/isucon/isucon13/bench/isupipe"
+	"go.uber.org/zap"
+)
+
+// 新しく開設したチャンネルで予約する配信の数
+const ownerStreamerReservations = 2
+
+// 新しく登録する配信者の名前の通し番号
+var ownerStreamerSeq int64
+
+// OwnerStreamerScenario は、新しい配信者がチャンネルを開設し、配信を予約して運営するまでの一連の行動です
+// 予約・NGワードの登録・モデレーションといった、配信者だけが使う書き込みの多い操作を行います
+// この配信者と予約した配信はプールに加えるので、以降は他のシナリオの視聴者・配信者の対象にもなります
+func OwnerStreamerScenario(
+	ctx context.Context,
+	contestantLogger *zap.Logger,
+	agentFactory *isupipe.AgentFactory,
+	streamerPool *isupipe.ClientPool,
+	livestreamPool *isupipe.LivestreamPool,
+) error {
+	lgr := zap.S()
+
+	// この webapp では、配信者のユーザそのものがチャンネルになる
+	// 登録してアイコンを設定すれば、チャンネルを開設したことになる
+	seq := atomic.AddInt64(&ownerStreamerSeq, 1)
+	name := fmt.Sprintf("newstreamer%d", seq)
+	streamer, err := agentFactory.NewAgent(isupipe.Identity{
+		Name:        name,
+		DisplayName: randDisplayName(),
+		Description: "新しくチャンネルを開設しました",
+		Password:    defaultPasswordOrPretest(name),
+		DarkMode:    seq%2 == 0,
+	})
+	if err != nil {
+		return err
+	}
+	if err := streamer.SignUp(ctx); err != nil {
+		lgr.Warnf("owner: failed to sign up: %s\n", err.Error())
+		return err
+	}
+	icon := scheduler.IconSched.GetRandomIcon()
+	if _, err := streamer.PostIcon(ctx, &isupipe.PostIconRequest{
+		Image: icon.Image,
+	}); err != nil {
+		lgr.Warnf("owner: failed to post icon: %s\n", err.Error())
+		return err
+	}
+
+	reserved := make(map[int64]*isupipe.Livestream, ownerStreamerReservations)
+	for i := 0; i < ownerStreamerReservations; i++ {
+		livestream, err := reserveOwnerLivestream(ctx, streamer)
+		if err != nil {
+			lgr.Warnf("owner: failed to reserve: %s\n", err.Error())
+			return err
+		}
+		reserved[livestream.ID] = livestream
+	}
+
+	// 予約した配信が、自分の配信の一覧に載っているか
+	myLivestreams, err := streamer.GetMyLivestreams(ctx)
+	if err != nil {
+		lgr.Warnf("owner: failed to get my livestreams: %s\n", err.Error())
+		return err
+	}
+	found := 0
+	for _, livestream := range myLivestreams {
+		if livestream.Owner.Name != streamer.Identity.Name {
+			return bencherror.NewAssertionError(fmt.Errorf("expected:%s, actual:%s", streamer.Identity.Name, livestream.Owner.Name), "自分の配信の一覧に、他の配信者の配信が含まれています")
+		}
+		if _, ok := reserved[livestream.ID]; ok {
+			found++
+		}
+	}
+	if found != len(reserved) {
+		return bencherror.NewAssertionError(fmt.Errorf("expected:%d, actual:%d", len(reserved), found), "予約した配信が、自分の配信の一覧に含まれていません")
+	}
+
+	for _, livestream := range reserved {
+		// 配信を始める前に、よくあるNGワードを登録しておく
+		ngWord := scheduler.LivecommentScheduler.GetDummyNgWord()
+		if err := streamer.Moderate(ctx, livestream.ID, livestream.Owner.Name, ngWord.Word); err != nil && !errors.Is(err, bencherror.ErrTimeout) {
+			lgr.Warnf("owner: failed to register ngword: %s\n", err.Error())
+			return err
+		}
+		scheduler.LivecommentScheduler.ModerateNgWord(ngWord.Word)
+
+		ngWords, err := streamer.GetNgwords(ctx, livestream.ID, livestream.Owner.Name)
+		if err != nil && !errors.Is(err, bencherror.ErrTimeout) {
+			lgr.Warnf("owner: failed to get ngwords: %s\n", err.Error())
+			return err
+		}
+		if err == nil && !hasNgWord(ngWords, ngWord.Word) {
+			return bencherror.NewAssertionError(fmt.Errorf("livestream_id=%d, ngword=%s", livestream.ID, ngWord.Word), "登録したNGワードが、NGワードの一覧に含まれていません")
+		}
+
+		livestreamPool.Put(ctx, livestream)
+	}
+	streamerPool.Put(ctx, streamer.Client)
+
+	for _, livestream := range reserved {
+		if err := moderateReportedLivecomments(ctx, streamer, livestream); err != nil {
+			lgr.Warnf("owner: failed to moderate reported livecomments: %s\n", err.Error())
+			return err
+		}
+		if _, err := streamer.GetLivestreamStatistics(ctx, livestream.ID, livestream.Owner.Name); err != nil && !errors.Is(err, bencherror.ErrTimeout) {
+			lgr.Warnf("owner: failed to get livestream statistics: %s\n", err.Error())
+			return err
+		}
+	}
+
+	if _, err := streamer.GetUserStatistics(ctx, streamer.Identity.Name); err != nil && !errors.Is(err, bencherror.ErrTimeout) {
+		lgr.Warnf("owner: failed to get user statistics: %s\n", err.Error())
+		return err
+	}
+	earnings, err := streamer.GetMyEarnings(ctx, isupipe.EarningsPeriodAll)
+	if err != nil && !errors.Is(err, bencherror.ErrTimeout) {
+		lgr.Warnf("owner: failed to get earnings: %s\n", err.Error())
+		return err
+	}
+	if err == nil {
+		for _, earning := range earnings.Livestreams {
+			if _, ok := reserved[earning.LivestreamID]; !ok {
+				return bencherror.NewAssertionError(fmt.Errorf("livestream_id=%d", earning.LivestreamID), "売上の内訳に、自分の配信ではない配信が含まれています")
+			}
+		}
+	}
+
+	return nil
+}
+
+// reserveOwnerLivestream は、他の予約と枠が衝突しない時間帯を選んで配信を予約します
+func reserveOwnerLivestream(ctx context.Context, streamer *isupipe.Agent) (*isupipe.Livestream, error) {
+	reservation, err := scheduler.ReservationSched.GetColdShortReservation()
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := streamer.GetRandomLivestreamTags(ctx, 5)
+	if err != nil {
+		scheduler.ReservationSched.AbortReservation(reservation)
+		return nil, err
+	}
+
+	livestream, err := streamer.ReserveLivestream(ctx, streamer.Identity.Name, &isupipe.ReserveLivestreamRequest{
+		Tags:         tags,
+		Title:        reservation.Title,
+		Description:  reservation.Description,
+		PlaylistUrl:  reservation.PlaylistUrl,
+		ThumbnailUrl: reservation.ThumbnailUrl,
+		StartAt:      reservation.StartAt,
+		EndAt:        reservation.EndAt,
+	})
+	if err != nil {
+		scheduler.ReservationSched.AbortReservation(reservation)
+		return nil, err
+	}
+	scheduler.ReservationSched.CommitReservation(reservation)
+
+	return livestream, nil
+}
+
+// moderateReportedLivecomments は、報告されたライブコメントを、そのNGワードを登録して削除します
+func moderateReportedLivecomments(ctx context.Context, streamer *isupipe.Agent, livestream *isupipe.Livestream) error {
+	reports, err := streamer.GetLivecommentReports(ctx, livestream.ID, livestream.Owner.Name)
+	if err != nil {
+		if errors.Is(err, bencherror.ErrTimeout) {
+			return nil
+		}
+		return err
+	}
+
+	for _, report := range reports {
+		ngword, err := scheduler.LivecommentScheduler.GetNgWord(report.Livecomment.Comment)
+		if err != nil {
+			return err
+		}
+		if err := streamer.Moderate(ctx, livestream.ID, livestream.Owner.Name, ngword); err != nil {
+			if errors.Is(err, bencherror.ErrTimeout) {
+				continue
+			}
+			return err
+		}
+		scheduler.LivecommentScheduler.Moderate(report.Livecomment.Comment)
+	}
+	return nil
+}
+
+func hasNgWord(ngWords []*isupipe.NGWord, word string) bool {
+	for _, ngWord := range ngWords {
+		if ngWord.Word == word {
+			return true
+		}
+	}
+	return false
+}
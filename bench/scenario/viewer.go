@@ -6,8 +6,11 @@ import (
 	"math/rand"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/isucon/isucon13/bench/internal/bencherror"
+	"github.com/isucon/isucon13/bench/internal/benchscore"
+	"github.com/isucon/isucon13/bench/internal/config"
 	"github.com/isucon/isucon13/bench/internal/scheduler"
 	"github.com/isucon/isucon13/bench/isupipe"
 	"go.uber.org/zap"
@@ -94,6 +97,21 @@ func BasicViewerScenario(
 		}
 	}
 
+	// NOTE: 有効なときは、投稿したライブコメントがWebSocketで届くことを確かめる
+	var liveEvents *isupipe.LiveEventStream
+	if config.EnableWebSocketViewer {
+		liveEvents, err = client.ConnectLiveEvents(ctx, livestream.ID, livestream.Owner.Name)
+		if err != nil {
+			lgr.Warnf("view: failed to connect live events: %s\n", err.Error())
+			benchscore.IncLiveEventMissed()
+		}
+	}
+	defer func() {
+		if liveEvents != nil {
+			liveEvents.Close()
+		}
+	}()
+
 	// ログ削減
 	// contestantLogger.Info("視聴を開始しました", zap.String("username", username), zap.Int("duration_hours", livestream.Hours()))
 	for hour := 1; hour <= livestream.Hours(); hour++ {
@@ -116,11 +134,23 @@ func BasicViewerScenario(
 			lgr.Warnf("view: failed to get tips for stream: %s\n", err.Error())
 			return err
 		}
-		if _, _, err := client.PostLivecomment(ctx, livestream.ID, livestream.Owner.Name, livecomment.Comment, tip); err != nil && !errors.Is(err, bencherror.ErrTimeout) {
+		posted, _, err := client.PostLivecomment(ctx, livestream.ID, livestream.Owner.Name, livecomment.Comment, tip)
+		if err != nil && !errors.Is(err, bencherror.ErrTimeout) {
 			contestantLogger.Warn("ライブコメントを配信に投稿できないため、視聴者が離脱します", zap.String("viewer", username), zap.Int64("livestream_id", livestream.ID), zap.Error(err))
 			lgr.Warnf("view: failed to post livecomment: %s\n", err.Error())
 			return err
 		}
+		if err == nil && liveEvents != nil {
+			if err := waitLivecommentDelivered(ctx, liveEvents, posted.ID); err != nil {
+				lgr.Warnf("view: livecomment is not delivered via websocket: %s\n", err.Error())
+				benchscore.IncLiveEventMissed()
+				// 取りこぼした後の受信は当てにならないので、以降は確かめない
+				liveEvents.Close()
+				liveEvents = nil
+			} else {
+				benchscore.IncLiveEventDelivered()
+			}
+		}
 
 		if _, err := client.GetReactions(ctx, livestream.ID, livestream.Owner.Name); err != nil && !errors.Is(err, bencherror.ErrTimeout) {
 			lgr.Warnf("view: failed to get reactions: %s\n", err.Error())
@@ -146,6 +176,21 @@ func BasicViewerScenario(
 	return nil
 }
 
+// waitLivecommentDelivered は、投稿したライブコメントがWebSocketで届くのを待ちます
+// 他の視聴者の投稿やリアクションは読み飛ばします
+func waitLivecommentDelivered(ctx context.Context, liveEvents *isupipe.LiveEventStream, livecommentID int64) error {
+	deadline := time.Now().Add(config.LiveEventDeliveryTimeout)
+	for {
+		ev, err := liveEvents.Next(ctx, time.Until(deadline))
+		if err != nil {
+			return err
+		}
+		if ev.Livecomment != nil && ev.Livecomment.ID == livecommentID {
+			return nil
+		}
+	}
+}
+
 func ViewerSpamScenario(
 	ctx context.Context,
 	contestantLogger *zap.Logger,
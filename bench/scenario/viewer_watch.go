@@ -0,0 +1,195 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/isucon/isucon13/bench/internal/bencherror"
+	"github.com/isucon/isucon13/bench/internal/config"
+	"github.com/isucon/isucon13/bench/internal/scheduler"
+	"github.com/isucon/isucon13/bench/isupipe"
+	"go.uber.org/zap"
+)
+
+// 視聴者が配信を見ながら、ライブコメントを読んでリアクションする回数
+const watchingViewerRounds = 3
+
+// thinkTime は、視聴者が次の操作に移るまで考える時間だけ待ちます
+func thinkTime(ctx context.Context) error {
+	d := time.Duration(float64(config.ViewerThinkTime) * (0.5 + rand.Float64()))
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// WatchingViewerScenario は、視聴者がログインしてから配信を探し、視聴して退室するまでの一連の行動です
+// 各操作のレスポンスが、操作した内容と食い違っていないかも確かめます
+func WatchingViewerScenario(
+	ctx context.Context,
+	contestantLogger *zap.Logger,
+	agentFactory *isupipe.AgentFactory,
+	identityPool *isupipe.IdentityPool,
+	livestreamPool *isupipe.LivestreamPool,
+) error {
+	lgr := zap.S()
+
+	identity, err := identityPool.Get(ctx)
+	if err != nil {
+		lgr.Warnf("watch: failed to get identity from pool: %s\n", err.Error())
+		return err
+	}
+	defer identityPool.Put(ctx, identity)
+
+	// ブラウザを開き直した視聴者として、新しいセッションでログインする
+	viewer, err := agentFactory.NewAgent(identity)
+	if err != nil {
+		return err
+	}
+	if err := viewer.SignIn(ctx); err != nil {
+		lgr.Warnf("watch: failed to sign in: %s\n", err.Error())
+		return err
+	}
+	if viewer.User.Name != identity.Name {
+		return bencherror.NewAssertionError(fmt.Errorf("expected:%s, actual:%s", identity.Name, viewer.User.Name), "ログインしたユーザとは異なるユーザの情報が返されました")
+	}
+
+	// トップページで、人気の配信とタグから配信を探す
+	if err := thinkTime(ctx); err != nil {
+		return err
+	}
+	if _, err := viewer.GetTrendingLivestreams(ctx); err != nil && !errors.Is(err, bencherror.ErrTimeout) {
+		lgr.Warnf("watch: failed to get trending livestreams: %s\n", err.Error())
+		return err
+	}
+	tags, err := viewer.GetTags(ctx)
+	if err != nil && !errors.Is(err, bencherror.ErrTimeout) {
+		lgr.Warnf("watch: failed to get tags: %s\n", err.Error())
+		return err
+	}
+	if err == nil && len(tags.Tags) > 0 {
+		tag := tags.Tags[rand.Intn(len(tags.Tags))]
+		livestreams, err := viewer.SearchLivestreams(ctx, isupipe.WithSearchTagQueryParam(tag.Name), isupipe.WithLimitQueryParam(config.NumSearchLivestreams))
+		if err != nil && !errors.Is(err, bencherror.ErrTimeout) {
+			lgr.Warnf("watch: failed to search livestreams by tag: %s\n", err.Error())
+			return err
+		}
+		for _, livestream := range livestreams {
+			if !hasTag(livestream, tag.Name) {
+				return bencherror.NewAssertionError(fmt.Errorf("livestream_id=%d, tag=%s", livestream.ID, tag.Name), "タグで検索した配信に、そのタグが付いていません")
+			}
+		}
+	}
+
+	livestream, err := livestreamPool.Get(ctx)
+	if err != nil {
+		lgr.Warnf("watch: failed to get livestream from pool: %s\n", err.Error())
+		return err
+	}
+	livestreamPool.Put(ctx, livestream) // 他の視聴者も入れるようにプールにすぐ戻す
+
+	// 配信を開いて入室する
+	if err := thinkTime(ctx); err != nil {
+		return err
+	}
+	opened, err := viewer.GetLivestream(ctx, livestream.ID, livestream.Owner.Name)
+	if err != nil {
+		lgr.Warnf("watch: failed to get livestream: %s\n", err.Error())
+		return err
+	}
+	if opened.ID != livestream.ID || opened.Owner.Name != livestream.Owner.Name {
+		return bencherror.NewAssertionError(fmt.Errorf("expected:%d (%s), actual:%d (%s)", livestream.ID, livestream.Owner.Name, opened.ID, opened.Owner.Name), "開いた配信とは異なる配信の情報が返されました")
+	}
+	if err := viewer.EnterLivestream(ctx, livestream.ID, livestream.Owner.Name); err != nil {
+		lgr.Warnf("watch: failed to enter livestream: %s\n", err.Error())
+		return err
+	}
+
+	for round := 0; round < watchingViewerRounds; round++ {
+		if err := thinkTime(ctx); err != nil {
+			return err
+		}
+
+		livecomments, err := viewer.GetLivecomments(ctx, livestream.ID, livestream.Owner.Name, isupipe.WithLimitQueryParam(10))
+		if err != nil && !errors.Is(err, bencherror.ErrTimeout) {
+			lgr.Warnf("watch: failed to get livecomments: %s\n", err.Error())
+			return err
+		}
+		for _, livecomment := range livecomments {
+			if livecomment.Livestream.ID != livestream.ID {
+				return bencherror.NewAssertionError(fmt.Errorf("expected:%d, actual:%d", livestream.ID, livecomment.Livestream.ID), "配信のライブコメントに、他の配信のライブコメントが含まれています")
+			}
+		}
+
+		emojiName := scheduler.GetReaction()
+		reaction, err := viewer.PostReaction(ctx, livestream.ID, livestream.Owner.Name, &isupipe.PostReactionRequest{
+			EmojiName: emojiName,
+		})
+		if err != nil && !errors.Is(err, bencherror.ErrTimeout) {
+			lgr.Warnf("watch: failed to post reaction: %s\n", err.Error())
+			return err
+		}
+		if err == nil && (reaction.EmojiName != emojiName || reaction.Livestream.ID != livestream.ID) {
+			return bencherror.NewAssertionError(fmt.Errorf("expected:%s (livestream_id=%d), actual:%s (livestream_id=%d)", emojiName, livestream.ID, reaction.EmojiName, reaction.Livestream.ID), "投稿したリアクションとは異なる内容が返されました")
+		}
+
+		// ライブコメントはたまにしか投稿しない
+		if rand.Intn(2) != 0 {
+			continue
+		}
+		if err := postWatchingLivecomment(ctx, viewer, livestream); err != nil {
+			if errors.Is(err, bencherror.ErrTimeout) {
+				continue
+			}
+			contestantLogger.Warn("ライブコメントを配信に投稿できないため、視聴者が離脱します", zap.String("viewer", identity.Name), zap.Int64("livestream_id", livestream.ID), zap.Error(err))
+			lgr.Warnf("watch: failed to post livecomment: %s\n", err.Error())
+			return err
+		}
+	}
+
+	if err := viewer.ExitLivestream(ctx, livestream.ID, livestream.Owner.Name); err != nil && !errors.Is(err, bencherror.ErrTimeout) {
+		lgr.Warnf("watch: failed to exit livestream: %s\n", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// postWatchingLivecomment は、ライブコメントを投稿します
+// config.ViewerSuperchatProbabilityの確率で、チップを添えたスーパーチャットにします
+func postWatchingLivecomment(ctx context.Context, viewer *isupipe.Agent, livestream *isupipe.Livestream) error {
+	livecomment := scheduler.LivecommentScheduler.GetLongPositiveComment()
+	tip := &scheduler.Tip{}
+	if hours := livestream.Hours(); hours > 0 && rand.Float64() < config.ViewerSuperchatProbability {
+		t, err := scheduler.LivecommentScheduler.GetTipsForStream(hours, rand.Intn(hours)+1)
+		if err != nil {
+			return err
+		}
+		tip = t
+	}
+
+	posted, _, err := viewer.PostLivecomment(ctx, livestream.ID, livestream.Owner.Name, livecomment.Comment, tip)
+	if err != nil {
+		return err
+	}
+	if posted.Comment != livecomment.Comment || posted.Tip != int64(tip.Tip) || posted.Livestream.ID != livestream.ID {
+		return bencherror.NewAssertionError(fmt.Errorf("expected:tip=%d (livestream_id=%d), actual:tip=%d (livestream_id=%d)", tip.Tip, livestream.ID, posted.Tip, posted.Livestream.ID), "投稿したライブコメントとは異なる内容が返されました")
+	}
+	return nil
+}
+
+func hasTag(livestream *isupipe.Livestream, tagName string) bool {
+	for _, tag := range livestream.Tags {
+		if tag.Name == tagName {
+			return true
+		}
+	}
+	return false
+}
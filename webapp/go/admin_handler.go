@@ -0,0 +1,168 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// この期間内にセッションで認証したユーザを、ログイン中として数える
+	activeSessionWindow = 5 * time.Minute
+	// 覚えておくサーバエラーの件数
+	recentErrorLogSize = 100
+	// ダッシュボードに載せるランキング上位の配信の数
+	adminTopLivestreamsLimit = 10
+)
+
+// sessionActivity は、ユーザごとに最後にセッションで認証した時刻を記録します
+// 記録はアプリケーションサーバごとなので、複数台で動かしているときは各サーバに問い合わせてください
+type sessionActivity struct {
+	mu       sync.Mutex
+	lastSeen map[int64]int64
+}
+
+var activeSessions = &sessionActivity{lastSeen: map[int64]int64{}}
+
+func (a *sessionActivity) touch(userID int64, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastSeen[userID] = now.Unix()
+}
+
+// count は、activeSessionWindowの間に認証したユーザの数を返します。それより古い記録は捨てます
+func (a *sessionActivity) count(now time.Time) int {
+	threshold := now.Add(-activeSessionWindow).Unix()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for userID, lastSeen := range a.lastSeen {
+		if lastSeen < threshold {
+			delete(a.lastSeen, userID)
+		}
+	}
+	return len(a.lastSeen)
+}
+
+func (a *sessionActivity) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastSeen = map[int64]int64{}
+}
+
+type RecentError struct {
+	Time      int64  `json:"time"`
+	Method    string `json:"method"`
+	Route     string `json:"route"`
+	Status    int    `json:"status"`
+	RequestID string `json:"request_id,omitempty"`
+	Error     string `json:"error"`
+}
+
+// errorLog は、直近のサーバエラーを決まった件数だけ覚えておきます
+type errorLog struct {
+	mu      sync.Mutex
+	entries []RecentError
+	next    int
+	full    bool
+}
+
+var recentErrors = &errorLog{entries: make([]RecentError, recentErrorLogSize)}
+
+func (l *errorLog) record(c echo.Context, status int, err error) {
+	entry := RecentError{
+		Time:   time.Now().Unix(),
+		Method: c.Request().Method,
+		Route:  c.Path(),
+		Status: status,
+		Error:  err.Error(),
+	}
+	if requestID, ok := c.Get(requestIDKey).(string); ok {
+		entry.RequestID = requestID
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// list は、覚えているエラーを新しい順に返します
+func (l *errorLog) list() []RecentError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := l.next
+	if l.full {
+		n = len(l.entries)
+	}
+	list := make([]RecentError, 0, n)
+	for i := 1; i <= n; i++ {
+		list = append(list, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+	return list
+}
+
+func (l *errorLog) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = make([]RecentError, len(l.entries))
+	l.next = 0
+	l.full = false
+}
+
+type AdminDashboard struct {
+	// 削除していないユーザの数
+	Users int64 `json:"users"`
+	// このアプリケーションサーバで、直近にセッションで認証したユーザの数
+	ActiveSessions int `json:"active_sessions"`
+	// スーパーチャットの件数と、チップの合計 (削除したものを除く)
+	Superchats     int64 `json:"superchats"`
+	SuperchatTotal int64 `json:"superchat_total"`
+	// ライブ配信ランキングの上位
+	TopLivestreams []LivestreamRankingResponseEntry `json:"top_livestreams"`
+	// キャッシュの名前 => ヒット率
+	Caches map[string]cacheStatsSnapshot `json:"caches"`
+	// このアプリケーションサーバで起きた直近のサーバエラー。新しい順
+	RecentErrors []RecentError `json:"recent_errors"`
+}
+
+// 競技中にシステムの状態を確かめるための、サービス全体の集計 (管理者のみ)
+// GET /admin/dashboard
+func getAdminDashboardHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := verifyAdminSession(c); err != nil {
+		return err
+	}
+
+	db := readDB(c)
+
+	dashboard := AdminDashboard{
+		ActiveSessions: activeSessions.count(time.Now()),
+		Caches:         collectCacheStats(),
+		RecentErrors:   recentErrors.list(),
+	}
+	if err := db.GetContext(ctx, &dashboard.Users, excludeDeleted("SELECT COUNT(*) FROM users", "")); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count users: "+err.Error())
+	}
+	if err := db.GetContext(ctx, &dashboard.Superchats, excludeDeleted("SELECT COUNT(*) FROM livecomments WHERE tip > 0", "")); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count superchats: "+err.Error())
+	}
+	if err := db.GetContext(ctx, &dashboard.SuperchatTotal, "SELECT total_tip FROM payment_totals WHERE id = ?", paymentTotalsID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get total tip: "+err.Error())
+	}
+
+	topLivestreams, err := buildLivestreamRanking(ctx, db, 0, adminTopLivestreamsLimit)
+	if err != nil {
+		return err
+	}
+	dashboard.TopLivestreams = topLivestreams
+
+	return c.JSON(http.StatusOK, &dashboard)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/labstack/echo/v4"
+)
+
+const defaultAuditLogsLimit = 100
+
+// auditedEventKinds は、監査ログに残すイベントの種類です
+// 監査ログに残すイベントでは、UserIDは操作したユーザです
+var auditedEventKinds = []eventKind{
+	eventLivecommentDeleted,
+	eventNGWordAdded,
+	eventFeatureFlagsChanged,
+	eventChannelBanned,
+	eventChannelUnbanned,
+	eventReactionEmojiAdded,
+	eventReactionEmojiRemoved,
+}
+
+// AuditLogModel は、管理・モデレーションの操作の記録です
+// 監査ログは追記のみで、更新・削除はしません
+type AuditLogModel struct {
+	ID            int64  `db:"id"`
+	Kind          string `db:"kind"`
+	ActorID       int64  `db:"actor_id"`
+	LivestreamID  int64  `db:"livestream_id"`
+	LivecommentID int64  `db:"livecomment_id"`
+	TargetUserID  int64  `db:"target_user_id"`
+	Detail        string `db:"detail"`
+	CreatedAt     int64  `db:"created_at"`
+}
+
+type AuditLog struct {
+	ID            int64  `json:"id"`
+	Kind          string `json:"kind"`
+	ActorID       int64  `json:"actor_id"`
+	LivestreamID  int64  `json:"livestream_id,omitempty"`
+	LivecommentID int64  `json:"livecomment_id,omitempty"`
+	TargetUserID  int64  `json:"target_user_id,omitempty"`
+	Detail        string `json:"detail,omitempty"`
+	CreatedAt     int64  `json:"created_at"`
+}
+
+// recordAuditLog は、evを監査ログに書き込みます
+// 操作と監査ログのどちらかだけが残らないよう、同期の購読者として登録し、ev.Txがあればそのトランザクションに書き込みます
+func recordAuditLog(ctx context.Context, ev event) error {
+	_, err := ev.db().ExecContext(ctx, "INSERT INTO audit_logs (kind, actor_id, livestream_id, livecomment_id, target_user_id, detail, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		string(ev.Kind), ev.UserID, ev.LivestreamID, ev.LivecommentID, ev.TargetUserID, ev.Detail, ev.At.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to insert audit log: %w", err)
+	}
+	return nil
+}
+
+// 監査ログの一覧 (管理者のみ)
+// since以上until未満の日時 (UNIX時間) で絞り込め、新しい順に返す
+// GET /admin/audit_logs
+func getAuditLogsHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := verifyAdminSession(c); err != nil {
+		return err
+	}
+
+	limit := defaultAuditLogsLimit
+	if c.QueryParam("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(c.QueryParam("limit"))
+		if err != nil || limit < 0 {
+			return apperror.BadRequest("limit query parameter must be non-negative integer")
+		}
+	}
+
+	query := "SELECT * FROM audit_logs WHERE 1 = 1"
+	params := []interface{}{}
+	if v := c.QueryParam("since"); v != "" {
+		since, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return apperror.BadRequest("since query parameter must be integer")
+		}
+		query += " AND created_at >= ?"
+		params = append(params, since)
+	}
+	if v := c.QueryParam("until"); v != "" {
+		until, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return apperror.BadRequest("until query parameter must be integer")
+		}
+		query += " AND created_at < ?"
+		params = append(params, until)
+	}
+	if kind := c.QueryParam("kind"); kind != "" {
+		query += " AND kind = ?"
+		params = append(params, kind)
+	}
+	cur, ok, err := cursorParam(c)
+	if err != nil {
+		return err
+	}
+	if ok {
+		cond, args := cur.condition("", "id", true)
+		query += " AND " + cond
+		params = append(params, args...)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	params = append(params, limit)
+
+	// 操作した直後に確かめられるよう、プライマリから読む
+	var auditLogModels []AuditLogModel
+	if err := dbConn.SelectContext(ctx, &auditLogModels, query, params...); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get audit logs: "+err.Error())
+	}
+	setNextCursor(c, len(auditLogModels), limit, func() cursor {
+		return cursor{id: auditLogModels[len(auditLogModels)-1].ID}
+	})
+
+	auditLogs := make([]AuditLog, len(auditLogModels))
+	for i, m := range auditLogModels {
+		auditLogs[i] = AuditLog{
+			ID:            m.ID,
+			Kind:          m.Kind,
+			ActorID:       m.ActorID,
+			LivestreamID:  m.LivestreamID,
+			LivecommentID: m.LivecommentID,
+			TargetUserID:  m.TargetUserID,
+			Detail:        m.Detail,
+			CreatedAt:     m.CreatedAt,
+		}
+	}
+	return c.JSON(http.StatusOK, auditLogs)
+}
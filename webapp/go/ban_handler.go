@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+// チャンネルは配信者ごとにあり、チャンネルのIDは配信者のユーザIDとする
+
+type ChannelBanModel struct {
+	StreamerID int64 `db:"streamer_id"`
+	UserID     int64 `db:"user_id"`
+	CreatedAt  int64 `db:"created_at"`
+}
+
+type ChannelBan struct {
+	ChannelID int64 `json:"channel_id"`
+	User      User  `json:"user"`
+	CreatedAt int64 `json:"created_at"`
+}
+
+type channelBanKey struct {
+	streamerID int64
+	userID     int64
+}
+
+// channelBanSet は、チャンネルから締め出したユーザをすべてメモリ上に持ちます
+// ライブコメントやリアクションの投稿のたびに引くので、DBを読まずに判定できるようにします
+// 他のアプリケーションサーバでの変更は、peersからの通知で反映します
+type channelBanSet struct {
+	mu   sync.RWMutex
+	bans map[channelBanKey]struct{}
+}
+
+var channelBans = &channelBanSet{bans: map[channelBanKey]struct{}{}}
+
+// isBanned は、userIDのユーザがstreamerIDの配信者のチャンネルから締め出されているかを返します
+func (s *channelBanSet) isBanned(streamerID, userID int64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.bans[channelBanKey{streamerID: streamerID, userID: userID}]
+	return ok
+}
+
+func (s *channelBanSet) set(streamerID, userID int64, banned bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := channelBanKey{streamerID: streamerID, userID: userID}
+	if banned {
+		s.bans[key] = struct{}{}
+	} else {
+		delete(s.bans, key)
+	}
+}
+
+// load は、DBに保存された締め出しでメモリ上の状態を置き換えます
+func (s *channelBanSet) load(ctx context.Context, db *sqlx.DB) error {
+	var banModels []ChannelBanModel
+	if err := db.SelectContext(ctx, &banModels, "SELECT * FROM channel_bans"); err != nil {
+		return err
+	}
+	bans := make(map[channelBanKey]struct{}, len(banModels))
+	for _, ban := range banModels {
+		bans[channelBanKey{streamerID: ban.StreamerID, userID: ban.UserID}] = struct{}{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bans = bans
+	return nil
+}
+
+func (s *channelBanSet) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bans = map[channelBanKey]struct{}{}
+}
+
+// checkChannelBan は、ユーザがライブ配信のチャンネルから締め出されていれば403のエラーを返します
+func checkChannelBan(livestreamModel LivestreamModel, userID int64) error {
+	if channelBans.isBanned(livestreamModel.UserID, userID) {
+		return apperror.Forbidden("you are banned from this channel")
+	}
+	return nil
+}
+
+// 配信者による、自分のチャンネルからのユーザの締め出し
+// 締め出したユーザは、チャンネルのすべての配信にライブコメント・スーパーチャット・リアクションを投稿できない
+// POST /api/channel/:channel_id/ban/:user_id
+func postChannelBanHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	streamerID, bannedUserID, err := channelBanParams(c)
+	if err != nil {
+		return err
+	}
+
+	banModel := ChannelBanModel{
+		StreamerID: streamerID,
+		UserID:     bannedUserID,
+		CreatedAt:  time.Now().Unix(),
+	}
+	var ban ChannelBan
+	err = withTx(ctx, func(tx *sqlx.Tx) error {
+		var userModel UserModel
+		if err := preparedGet(ctx, tx, &userModel, queryUserByID, bannedUserID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return apperror.NotFound("user not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user: "+err.Error())
+		}
+
+		// 締め出し済みなら、最初に締め出した日時のままにする
+		if _, err := tx.ExecContext(ctx, "INSERT IGNORE INTO channel_bans (streamer_id, user_id, created_at) VALUES (?, ?, ?)", banModel.StreamerID, banModel.UserID, banModel.CreatedAt); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert channel ban: "+err.Error())
+		}
+		if err := tx.GetContext(ctx, &banModel, "SELECT * FROM channel_bans WHERE streamer_id = ? AND user_id = ?", banModel.StreamerID, banModel.UserID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get channel ban: "+err.Error())
+		}
+		if err := events.publishInTx(ctx, event{Kind: eventChannelBanned, UserID: streamerID, TargetUserID: bannedUserID, Tx: tx}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to record audit log: "+err.Error())
+		}
+
+		user, err := userProfiles.fill(ctx, tx, userModel)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill user: "+err.Error())
+		}
+		ban = ChannelBan{
+			ChannelID: banModel.StreamerID,
+			User:      user,
+			CreatedAt: banModel.CreatedAt,
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// コミットできてから反映する
+	channelBans.set(streamerID, bannedUserID, true)
+	peers.broadcast(peerMessage{Kind: peerMessageChannelBan, ChannelID: streamerID, UserID: bannedUserID, Banned: true})
+
+	return c.JSON(http.StatusCreated, ban)
+}
+
+// 配信者による、チャンネルからの締め出しの解除
+// DELETE /api/channel/:channel_id/ban/:user_id
+func deleteChannelBanHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	streamerID, bannedUserID, err := channelBanParams(c)
+	if err != nil {
+		return err
+	}
+
+	err = withTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM channel_bans WHERE streamer_id = ? AND user_id = ?", streamerID, bannedUserID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete channel ban: "+err.Error())
+		}
+		if err := events.publishInTx(ctx, event{Kind: eventChannelUnbanned, UserID: streamerID, TargetUserID: bannedUserID, Tx: tx}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to record audit log: "+err.Error())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	channelBans.set(streamerID, bannedUserID, false)
+	peers.broadcast(peerMessage{Kind: peerMessageChannelBan, ChannelID: streamerID, UserID: bannedUserID, Banned: false})
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// channelBanParams は、セッションを確かめ、パスのチャンネルのIDと締め出すユーザのIDを返します
+// 締め出しを変更できるのは、チャンネルの配信者本人のみです
+func channelBanParams(c echo.Context) (int64, int64, error) {
+	if err := verifyUserSession(c); err != nil {
+		// echo.NewHTTPErrorが返っているのでそのまま出力
+		return 0, 0, err
+	}
+
+	streamerID, err := strconv.ParseInt(c.Param("channel_id"), 10, 64)
+	if err != nil {
+		return 0, 0, apperror.BadRequest("channel_id in path must be integer")
+	}
+	bannedUserID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		return 0, 0, apperror.BadRequest("user_id in path must be integer")
+	}
+
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	if streamerID != userID {
+		return 0, 0, apperror.Forbidden("can't change bans of other streamer's channel")
+	}
+	if bannedUserID == streamerID {
+		return 0, 0, apperror.BadRequest("can't ban yourself")
+	}
+	return streamerID, bannedUserID, nil
+}
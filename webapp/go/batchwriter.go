@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// 書き込みをまとめる間隔
+	batchWriteInterval = 50 * time.Millisecond
+	// これだけ溜まったら間隔を待たずに書き込む
+	batchWriteMaxItems = 500
+)
+
+// batchWriter は、リクエストごとのINSERTを溜めておき、まとめて書き込みます
+// 書き込みはbatchWriteIntervalごと、またはbatchWriteMaxItems件溜まったときに行います
+// 書き込みは1つずつ順番に行うため、追加した順に反映されます
+type batchWriter[T any] struct {
+	name  string
+	write func(ctx context.Context, items []T) error
+
+	mu      sync.Mutex
+	pending []T
+	kick    chan struct{}
+
+	// 書き込みを直列にする
+	writeMu sync.Mutex
+}
+
+func newBatchWriter[T any](name string, write func(ctx context.Context, items []T) error) *batchWriter[T] {
+	return &batchWriter[T]{
+		name:  name,
+		write: write,
+		kick:  make(chan struct{}, 1),
+	}
+}
+
+// add は、itemを書き込み待ちに積みます
+// flagWriteBehindが無効なときは、溜まっている分と一緒にその場で書き込みます
+func (w *batchWriter[T]) add(item T) {
+	w.mu.Lock()
+	w.pending = append(w.pending, item)
+	full := len(w.pending) >= batchWriteMaxItems
+	w.mu.Unlock()
+
+	if !flagWriteBehind.enabled() {
+		if err := w.flush(context.Background()); err != nil {
+			appLogger.Error("failed to write "+w.name, slog.Any("error", err))
+		}
+		return
+	}
+	if full {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// flush は、溜まっている分をすべて書き込みます
+func (w *batchWriter[T]) flush(ctx context.Context) error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+
+	for {
+		w.mu.Lock()
+		items := w.pending
+		if len(items) > batchWriteMaxItems {
+			items = items[:batchWriteMaxItems]
+			w.pending = w.pending[batchWriteMaxItems:]
+		} else {
+			w.pending = nil
+		}
+		w.mu.Unlock()
+
+		if len(items) == 0 {
+			return nil
+		}
+		if err := w.write(ctx, items); err != nil {
+			return err
+		}
+	}
+}
+
+// run は、定期的にflushします
+// 書き込みに失敗した分は捨てるので、呼び出し側に結果を返す必要があればwriteの中で通知してください
+func (w *batchWriter[T]) run(logger echo.Logger) {
+	ticker := time.NewTicker(batchWriteInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.kick:
+		}
+		if err := w.flush(context.Background()); err != nil {
+			logger.Errorf("failed to write %s: %v", w.name, err)
+		}
+	}
+}
+
+// flushBatchWriters は、すべてのbatchWriterに溜まっている分を書き込みます
+// 初期化の前やシャットダウンのときに呼び出します
+func flushBatchWriters(ctx context.Context) error {
+	if err := reactionWriter.flush(ctx); err != nil {
+		return err
+	}
+	if err := viewerEventWriter.flush(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+// runBatchWriters は、すべてのbatchWriterを定期的にflushします
+func runBatchWriters(logger echo.Logger) {
+	go reactionWriter.run(logger)
+	go viewerEventWriter.run(logger)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/isucon/isucon13/webapp/go/internal/config"
+	"github.com/labstack/echo/v4"
+)
+
+// アイコンの投稿のみ、画像を含むので大きなボディを受け付ける
+const iconUploadPath = "/api/icon"
+
+// アイコン画像の幅・高さの上限 (ピクセル)
+var iconMaxDimension = 4096
+
+// newBodyLimitMiddleware は、ルートごとにリクエストボディの大きさを制限するミドルウェアを返します
+// 上限を超えたリクエストは、ハンドラがボディをどう扱ったかにかかわらず413を返します
+func newBodyLimitMiddleware(c config.Limits) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			if req.Body == nil || req.Body == http.NoBody {
+				return next(ctx)
+			}
+
+			limit := int64(c.JSONBody)
+			if ctx.Path() == iconUploadPath {
+				limit = int64(c.IconBody)
+			}
+			// Content-Lengthで分かるときは、読まずに断る
+			if req.ContentLength > limit {
+				return bodyTooLarge(limit)
+			}
+
+			body := &limitedBody{ReadCloser: http.MaxBytesReader(ctx.Response(), req.Body, limit)}
+			req.Body = body
+			err := next(ctx)
+			// ボディを読み切れなかったハンドラはデコードの失敗として400を返すので、413に置き換える
+			if body.exceeded && !ctx.Response().Committed {
+				return bodyTooLarge(limit)
+			}
+			return err
+		}
+	}
+}
+
+func bodyTooLarge(limit int64) error {
+	return apperror.TooLarge(fmt.Sprintf("request body must be at most %d bytes", limit))
+}
+
+// limitedBody は、上限を超えて読もうとしたかを記録します
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// validateIconImage は、アイコン画像がJPEGまたはPNGで、大きさが上限以内であることを確かめます
+// ヘッダのみを読むので、画像全体はデコードしません
+// PNGでもContent-Typeはimage/jpegで返すが、ブラウザは中身から判別して表示する
+func validateIconImage(img []byte) error {
+	if len(img) == 0 {
+		return apperror.BadRequest("image is required")
+	}
+	conf, format, err := image.DecodeConfig(bytes.NewReader(img))
+	if err != nil {
+		return apperror.BadRequest("image must be a JPEG or PNG")
+	}
+	if format != "jpeg" && format != "png" {
+		return apperror.BadRequest("image must be a JPEG or PNG")
+	}
+	if conf.Width <= 0 || conf.Height <= 0 {
+		return apperror.BadRequest("image has no pixels")
+	}
+	if conf.Width > iconMaxDimension || conf.Height > iconMaxDimension {
+		return apperror.BadRequest(fmt.Sprintf("image must be at most %dx%d pixels", iconMaxDimension, iconMaxDimension))
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"compress/gzip"
+	"strings"
+
+	"github.com/isucon/isucon13/webapp/go/internal/config"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// newCompressMiddleware は、Accept-Encodingでgzipを受け付けるクライアントに対して、JSONのレスポンスを圧縮するミドルウェアを返します
+// アイコン画像はすでに圧縮された形式なので、圧縮しません
+// 圧縮しない設定のときはnilを返します
+// 小さいレスポンスは、圧縮しても通信量がほとんど減らないのでc.MinLengthより小さければそのまま返します
+func newCompressMiddleware(c config.Gzip) echo.MiddlewareFunc {
+	if c.Level == gzip.NoCompression {
+		return nil
+	}
+	return middleware.GzipWithConfig(middleware.GzipConfig{
+		Skipper:   skipCompression,
+		Level:     c.Level,
+		MinLength: c.MinLength,
+	})
+}
+
+func skipCompression(c echo.Context) bool {
+	if !strings.HasPrefix(c.Path(), "/api/") {
+		return true
+	}
+	// WebSocketは接続を乗っ取り、SSEは届いたものから書き出すので、圧縮しない
+	switch c.Path() {
+	case "/api/user/:username/icon", "/api/livestream/:livestream_id/ws", "/api/livestream/:livestream_id/events":
+		return true
+	}
+	return false
+}
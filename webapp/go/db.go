@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// レプリカの死活を確認する間隔
+	replicaHealthCheckInterval = 1 * time.Second
+
+	// どのDBがリクエストを処理したかを返すデバッグ用のヘッダ
+	servedByHeader = "X-Isupipe-Served-By"
+	primaryDBName  = "primary"
+)
+
+// replica は、読み取り用のレプリカへの接続です
+type replica struct {
+	name    string
+	db      *sqlx.DB
+	healthy atomic.Bool
+}
+
+// replicaSet は、正常なレプリカにラウンドロビンで読み取りを振り分けます
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+var replicas = &replicaSet{}
+
+// readDB は、読み取りのみのリクエストで使うDB接続を返します
+// 正常なレプリカがなければプライマリ (dbConn) を返します
+// レプリカは更新の反映が遅れうるため、書き込み直後の値を読む必要がある処理ではdbConnを使ってください
+func readDB(c echo.Context) *sqlx.DB {
+	if r, ok := replicas.pick(); ok {
+		c.Response().Header().Set(servedByHeader, r.name)
+		return r.db
+	}
+	c.Response().Header().Set(servedByHeader, primaryDBName)
+	return dbConn
+}
+
+func (s *replicaSet) pick() (*replica, bool) {
+	n := uint64(len(s.replicas))
+	if n == 0 {
+		return nil, false
+	}
+	start := s.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if r := s.replicas[(start+i)%n]; r.healthy.Load() {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// checkHealth は、各レプリカにpingして到達できるかを記録します
+func (s *replicaSet) checkHealth(ctx context.Context, logger echo.Logger) {
+	for _, r := range s.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, replicaHealthCheckInterval)
+		err := r.db.PingContext(pingCtx)
+		cancel()
+
+		healthy := err == nil
+		if r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				logger.Infof("replica %s is available", r.name)
+			} else {
+				logger.Warnf("replica %s is unavailable, falling back: %v", r.name, err)
+			}
+		}
+	}
+}
+
+// runHealthChecker は、checkHealthを定期的に実行します
+func (s *replicaSet) runHealthChecker(logger echo.Logger) {
+	if len(s.replicas) == 0 {
+		return
+	}
+	ticker := time.NewTicker(replicaHealthCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.checkHealth(context.Background(), logger)
+	}
+}
+
+func (s *replicaSet) close() {
+	for _, r := range s.replicas {
+		r.db.Close()
+	}
+}
+
+// connectReplicas は、hosts (host:port) のレプリカに接続します
+// ユーザ名やパスワードなど、アドレス以外の接続設定はプライマリのconfと同じものを使います
+// 起動時に到達できないレプリカがあってもエラーにはせず、ヘルスチェックで復帰するまで使いません
+func connectReplicas(conf *mysql.Config, pool *dbPoolConfig, hosts []string, logger echo.Logger) (*replicaSet, error) {
+	set := &replicaSet{}
+	if len(hosts) == 0 {
+		return set, nil
+	}
+
+	for _, host := range hosts {
+		replicaConf := conf.Clone()
+		replicaConf.Addr = host
+		db, err := openDB(replicaConf)
+		if err != nil {
+			set.close()
+			return nil, err
+		}
+		pool.apply(db)
+		set.replicas = append(set.replicas, &replica{name: host, db: db})
+	}
+
+	set.checkHealth(context.Background(), logger)
+	return set, nil
+}
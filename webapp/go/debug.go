@@ -0,0 +1,182 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/isucon/isucon13/webapp/go/internal/config"
+	"github.com/labstack/echo/v4"
+)
+
+// cacheStats は、キャッシュのヒット数とミス数を数えます
+type cacheStats struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+var (
+	cacheStatsMu sync.Mutex
+	// キャッシュの名前 => 統計
+	cacheStatsByName = map[string]*cacheStats{}
+)
+
+// newCacheStats は、/debug/varsで公開するキャッシュの統計を登録します
+func newCacheStats(name string) *cacheStats {
+	cacheStatsMu.Lock()
+	defer cacheStatsMu.Unlock()
+	s, ok := cacheStatsByName[name]
+	if !ok {
+		s = &cacheStats{}
+		cacheStatsByName[name] = s
+	}
+	return s
+}
+
+func (s *cacheStats) record(hit bool) {
+	if hit {
+		s.hits.Add(1)
+	} else {
+		s.misses.Add(1)
+	}
+}
+
+type cacheStatsSnapshot struct {
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+func (s *cacheStats) snapshot() cacheStatsSnapshot {
+	hits, misses := s.hits.Load(), s.misses.Load()
+	snapshot := cacheStatsSnapshot{Hits: hits, Misses: misses}
+	if total := hits + misses; total > 0 {
+		snapshot.HitRatio = float64(hits) / float64(total)
+	}
+	return snapshot
+}
+
+type runtimeStats struct {
+	Goroutines   int     `json:"goroutines"`
+	NumGC        uint32  `json:"num_gc"`
+	PauseTotalNs uint64  `json:"pause_total_ns"`
+	LastPauseNs  uint64  `json:"last_pause_ns"`
+	HeapAlloc    uint64  `json:"heap_alloc"`
+	HeapObjects  uint64  `json:"heap_objects"`
+	GCCPUFrac    float64 `json:"gc_cpu_fraction"`
+}
+
+type dbStats struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`
+	WaitDurationMs     int64 `json:"wait_duration_ms"`
+	MaxIdleClosed      int64 `json:"max_idle_closed"`
+	MaxLifetimeClosed  int64 `json:"max_lifetime_closed"`
+}
+
+func collectRuntimeStats() runtimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return runtimeStats{
+		Goroutines:   runtime.NumGoroutine(),
+		NumGC:        m.NumGC,
+		PauseTotalNs: m.PauseTotalNs,
+		LastPauseNs:  m.PauseNs[(m.NumGC+255)%256],
+		HeapAlloc:    m.HeapAlloc,
+		HeapObjects:  m.HeapObjects,
+		GCCPUFrac:    m.GCCPUFraction,
+	}
+}
+
+func newDBStats(s sql.DBStats) dbStats {
+	return dbStats{
+		MaxOpenConnections: s.MaxOpenConnections,
+		OpenConnections:    s.OpenConnections,
+		InUse:              s.InUse,
+		Idle:               s.Idle,
+		WaitCount:          s.WaitCount,
+		WaitDurationMs:     s.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      s.MaxIdleClosed,
+		MaxLifetimeClosed:  s.MaxLifetimeClosed,
+	}
+}
+
+// collectDBStats は、プライマリと各レプリカのコネクションプールの状態を返します
+func collectDBStats() map[string]dbStats {
+	stats := map[string]dbStats{}
+	if dbConn != nil {
+		stats[primaryDBName] = newDBStats(dbConn.Stats())
+	}
+	for _, r := range replicas.replicas {
+		stats[r.name] = newDBStats(r.db.Stats())
+	}
+	return stats
+}
+
+func collectCacheStats() map[string]cacheStatsSnapshot {
+	cacheStatsMu.Lock()
+	defer cacheStatsMu.Unlock()
+	snapshots := make(map[string]cacheStatsSnapshot, len(cacheStatsByName))
+	for name, s := range cacheStatsByName {
+		snapshots[name] = s.snapshot()
+	}
+	return snapshots
+}
+
+func init() {
+	expvar.Publish("runtime", expvar.Func(func() any { return collectRuntimeStats() }))
+	expvar.Publish("db", expvar.Func(func() any { return collectDBStats() }))
+	expvar.Publish("caches", expvar.Func(func() any { return collectCacheStats() }))
+	expvar.Publish("rate_limits", expvar.Func(func() any { return rateLimits.snapshot() }))
+}
+
+// newDebugHandler は、pprofと/debug/vars、ルートごとのレイテンシやクエリごとの実行時間の集計を提供するハンドラを返します
+// /debug/varsでは、expvarの既定の値に加えて、ゴルーチン数やGC、コネクションプール、キャッシュのヒット率、レート制限の件数を返します
+func newDebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	// アプリケーションの集計はechoのハンドラなので、echoで受けてから渡す
+	// 本番のポートには登録せず、DEBUG=1 のときだけこのポートで公開する
+	stats := echo.New()
+	stats.HTTPErrorHandler = errorResponseHandler
+	stats.GET("/debug/route_stats", getRouteStatsHandler)
+	// クエリごとの実行時間の集計 (ISUCON13_QUERY_STATS=1 のとき)
+	stats.GET("/debug/queries", getQueryStatsHandler)
+	mux.Handle("/debug/route_stats", stats)
+	mux.Handle("/debug/queries", stats)
+	return mux
+}
+
+// runDebugServer は、DEBUG=1 のときにデバッグ用のHTTPサーバを別のポートで起動します
+func runDebugServer(c config.Debug, logger echo.Logger) {
+	if !c.Enabled {
+		return
+	}
+	addr := c.Addr
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           newDebugHandler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		logger.Infof("debug server listening on %s", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("failed to start debug server: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/isucon/isucon13/webapp/go/internal/config"
+)
+
+const (
+	// サブドメインのレコードを管理するゾーン
+	powerDNSZone    = "u.isucon.dev"
+	powerDNSAPIPort = 8081
+)
+
+// subdomainProvisioner は、ユーザごとのサブドメイン (<name>.u.isucon.dev) のAレコードを管理します
+type subdomainProvisioner interface {
+	addRecord(ctx context.Context, name string) error
+	deleteRecord(ctx context.Context, name string) error
+}
+
+var subdomains subdomainProvisioner = noopProvisioner{}
+
+// newSubdomainProvisioner は、設定に応じたバックエンドを返します
+// APIキーが指定されていれば、pdnsutilの代わりにPowerDNSのHTTP APIを使います
+func newSubdomainProvisioner(c config.PowerDNS) subdomainProvisioner {
+	if c.Disabled {
+		return noopProvisioner{}
+	}
+	if c.APIKey == "" {
+		return &pdnsutilProvisioner{address: c.SubdomainAddress}
+	}
+	return &powerDNSAPIProvisioner{
+		baseURL: "http://" + net.JoinHostPort(c.Host, strconv.Itoa(powerDNSAPIPort)),
+		apiKey:  c.APIKey,
+		address: c.SubdomainAddress,
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// pdnsutilProvisioner は、同じホストのpdnsutilコマンドでレコードを操作します
+type pdnsutilProvisioner struct {
+	address string
+}
+
+func (p *pdnsutilProvisioner) addRecord(ctx context.Context, name string) error {
+	if out, err := exec.CommandContext(ctx, "pdnsutil", "add-record", powerDNSZone, name, "A", "0", p.address).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w", string(out), err)
+	}
+	return nil
+}
+
+func (p *pdnsutilProvisioner) deleteRecord(ctx context.Context, name string) error {
+	if out, err := exec.CommandContext(ctx, "pdnsutil", "delete-rrset", powerDNSZone, name, "A").CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w", string(out), err)
+	}
+	return nil
+}
+
+// powerDNSAPIProvisioner は、PowerDNSのHTTP APIでレコードを操作します
+type powerDNSAPIProvisioner struct {
+	baseURL string
+	apiKey  string
+	address string
+	client  *http.Client
+}
+
+type powerDNSRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+type powerDNSRRSet struct {
+	Name       string           `json:"name"`
+	Type       string           `json:"type"`
+	TTL        int              `json:"ttl"`
+	ChangeType string           `json:"changetype"`
+	Records    []powerDNSRecord `json:"records,omitempty"`
+}
+
+func (p *powerDNSAPIProvisioner) addRecord(ctx context.Context, name string) error {
+	return p.patchRRSet(ctx, powerDNSRRSet{
+		Name:       name + "." + powerDNSZone + ".",
+		Type:       "A",
+		ChangeType: "REPLACE",
+		Records:    []powerDNSRecord{{Content: p.address}},
+	})
+}
+
+func (p *powerDNSAPIProvisioner) deleteRecord(ctx context.Context, name string) error {
+	return p.patchRRSet(ctx, powerDNSRRSet{
+		Name:       name + "." + powerDNSZone + ".",
+		Type:       "A",
+		ChangeType: "DELETE",
+	})
+}
+
+func (p *powerDNSAPIProvisioner) patchRRSet(ctx context.Context, rrset powerDNSRRSet) error {
+	body, err := json.Marshal(map[string][]powerDNSRRSet{"rrsets": {rrset}})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/api/v1/servers/localhost/zones/%s.", p.baseURL, powerDNSZone)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", p.apiKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status code from PowerDNS API: %d: %s", resp.StatusCode, string(msg))
+	}
+	return nil
+}
+
+type noopProvisioner struct{}
+
+func (noopProvisioner) addRecord(ctx context.Context, name string) error {
+	return nil
+}
+
+func (noopProvisioner) deleteRecord(ctx context.Context, name string) error {
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+// 絵文字の名前の最大の長さ (reaction_emojis.nameの長さ)
+const maxReactionEmojiNameLength = 255
+
+type ReactionEmojiModel struct {
+	Name      string `db:"name"`
+	CreatedAt int64  `db:"created_at"`
+}
+
+// reactionEmojiSet は、リアクションに使える絵文字をすべてメモリ上に持ちます
+// リアクションの投稿のたびに引くので、DBを読まずに判定できるようにします
+// 他のアプリケーションサーバでの変更は、peersからの通知で読み込み直します
+type reactionEmojiSet struct {
+	mu    sync.RWMutex
+	names map[string]struct{}
+}
+
+var reactionEmojis = &reactionEmojiSet{names: map[string]struct{}{}}
+
+// load は、DBに保存された絵文字でメモリ上の状態を置き換えます
+func (s *reactionEmojiSet) load(ctx context.Context, db *sqlx.DB) error {
+	var emojiModels []ReactionEmojiModel
+	if err := db.SelectContext(ctx, &emojiModels, "SELECT * FROM reaction_emojis"); err != nil {
+		return err
+	}
+	names := make(map[string]struct{}, len(emojiModels))
+	for _, emoji := range emojiModels {
+		names[emoji.Name] = struct{}{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.names = names
+	return nil
+}
+
+func (s *reactionEmojiSet) allowed(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.names[name]
+	return ok
+}
+
+func (s *reactionEmojiSet) set(name string, allowed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if allowed {
+		s.names[name] = struct{}{}
+	} else {
+		delete(s.names, name)
+	}
+}
+
+// list は、すべての絵文字を名前の順に返します
+func (s *reactionEmojiSet) list() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.names))
+	for name := range s.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type ReactionEmojisResponse struct {
+	Emojis []string `json:"emojis"`
+}
+
+type PostReactionEmojiRequest struct {
+	Name string `json:"name"`
+}
+
+// リアクションに使える絵文字の一覧
+// GET /api/reaction/emojis
+func getReactionEmojisHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, &ReactionEmojisResponse{Emojis: reactionEmojis.list()})
+}
+
+// 管理者による、リアクションに使える絵文字の追加
+// POST /admin/reaction/emojis
+func postReactionEmojiHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := verifyAdminSession(c); err != nil {
+		return err
+	}
+
+	var req PostReactionEmojiRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return apperror.BadRequest("failed to decode the request body as json")
+	}
+	if req.Name == "" || len(req.Name) > maxReactionEmojiNameLength {
+		return apperror.BadRequest("name must be between 1 and 255 bytes")
+	}
+
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	err := withTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO reaction_emojis (name, created_at) VALUES (?, ?)", req.Name, time.Now().Unix()); err != nil {
+			var mysqlErr *mysql.MySQLError
+			if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+				return apperror.Conflict("the emoji already exists")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert emoji: "+err.Error())
+		}
+		if err := events.publishInTx(ctx, event{Kind: eventReactionEmojiAdded, UserID: userID, Detail: req.Name, Tx: tx}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to record audit log: "+err.Error())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	reactionEmojis.set(req.Name, true)
+	peers.broadcast(peerMessage{Kind: peerMessageReactionEmojis})
+
+	return c.JSON(http.StatusCreated, &ReactionEmojisResponse{Emojis: reactionEmojis.list()})
+}
+
+// 管理者による、リアクションに使える絵文字の削除
+// 既に付けられたリアクションはそのまま残す
+// DELETE /admin/reaction/emojis/:name
+func deleteReactionEmojiHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := verifyAdminSession(c); err != nil {
+		return err
+	}
+
+	name := c.Param("name")
+
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	err := withTx(ctx, func(tx *sqlx.Tx) error {
+		rs, err := tx.ExecContext(ctx, "DELETE FROM reaction_emojis WHERE name = ?", name)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete emoji: "+err.Error())
+		}
+		if n, err := rs.RowsAffected(); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete emoji: "+err.Error())
+		} else if n == 0 {
+			return apperror.NotFound("emoji not found")
+		}
+		if err := events.publishInTx(ctx, event{Kind: eventReactionEmojiRemoved, UserID: userID, Detail: name, Tx: tx}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to record audit log: "+err.Error())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	reactionEmojis.set(name, false)
+	peers.broadcast(peerMessage{Kind: peerMessageReactionEmojis})
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+type eventKind string
+
+const (
+	eventUserRegistered     eventKind = "user.registered"
+	eventUserDeleted        eventKind = "user.deleted"
+	eventSuperchatPosted    eventKind = "superchat.posted"
+	eventLivestreamReserved eventKind = "livestream.reserved"
+	eventLivestreamStarted  eventKind = "livestream.started"
+	eventLivecommentPinned  eventKind = "livecomment.pinned"
+
+	// 管理・モデレーションの操作。監査ログに残す
+	eventLivecommentDeleted   eventKind = "livecomment.deleted"
+	eventNGWordAdded          eventKind = "ngword.added"
+	eventFeatureFlagsChanged  eventKind = "feature_flags.changed"
+	eventChannelBanned        eventKind = "channel.banned"
+	eventChannelUnbanned      eventKind = "channel.unbanned"
+	eventReactionEmojiAdded   eventKind = "reaction_emoji.added"
+	eventReactionEmojiRemoved eventKind = "reaction_emoji.removed"
+)
+
+// 非同期の購読者に届ける前に溜めておけるイベントの数
+// 溢れたイベントは捨てるので、購読者は取りこぼしても後から辻褄を合わせられるようにしてください
+const eventQueueSize = 4096
+
+// event は、書き込みが起きたことを購読者に伝えます
+// 種類によって使わないフィールドはゼロ値のままです
+type event struct {
+	Kind eventKind
+	At   time.Time
+
+	UserID        int64
+	Username      string
+	LivestreamID  int64
+	LivecommentID int64
+	// スーパーチャットのチップ
+	Tip int64
+	// 操作の対象のユーザ (チャンネルから締め出したユーザなど)
+	TargetUserID int64
+	// 操作の内容 (登録したNGワード、切り替えた機能フラグなど)
+	Detail string
+
+	// publishInTxをトランザクションの中で呼び出したときの、そのトランザクション
+	// 同期の購読者はこれに書き込めば、呼び出し側と一緒にコミット・ロールバックされます
+	Tx *sqlx.Tx
+}
+
+// db は、同期の購読者が書き込む先を返します
+// トランザクションの外で発行されたときは、dbConnに直接書き込みます
+func (ev event) db() sqlx.ExecerContext {
+	if ev.Tx != nil {
+		return ev.Tx
+	}
+	return dbConn
+}
+
+type eventHandler func(ctx context.Context, ev event) error
+
+// eventBus は、ハンドラから各機能 (統計情報の集計、通知、DNSのレコードなど) に書き込みを伝えます
+// ハンドラは購読者を知らずにイベントを発行し、購読者はmainで登録します
+//
+// 同期の購読者はpublishInTxの中で順に呼び出し、失敗すればエラーを返します
+// トランザクションの中で呼び出せば、購読者の失敗でロールバックできます
+// 非同期の購読者には、コミットした後にpublishで発行したイベントを、別のゴルーチンから届けます
+// イベントは発行したアプリケーションサーバの中でのみ届き、他のサーバには届きません
+type eventBus struct {
+	mu    sync.RWMutex
+	sync  map[eventKind][]eventHandler
+	async map[eventKind][]eventHandler
+
+	queue chan event
+}
+
+var events = newEventBus()
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		sync:  map[eventKind][]eventHandler{},
+		async: map[eventKind][]eventHandler{},
+		queue: make(chan event, eventQueueSize),
+	}
+}
+
+// subscribe は、kindのイベントを同期で受け取る購読者を登録します
+func (b *eventBus) subscribe(kind eventKind, handler eventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sync[kind] = append(b.sync[kind], handler)
+}
+
+// subscribeAsync は、kindのイベントを非同期で受け取る購読者を登録します
+func (b *eventBus) subscribeAsync(kind eventKind, handler eventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.async[kind] = append(b.async[kind], handler)
+}
+
+// publishInTx は、同期の購読者にevを届けます
+// 購読者が失敗すれば残りの購読者は呼び出さずにエラーを返すので、呼び出し側はロールバックしてください
+// 非同期の購読者には届けないので、コミットした後にpublishも呼び出してください
+func (b *eventBus) publishInTx(ctx context.Context, ev event) error {
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+	b.mu.RLock()
+	handlers := b.sync[ev.Kind]
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(ctx, ev); err != nil {
+			return fmt.Errorf("%s: %w", ev.Kind, err)
+		}
+	}
+	return nil
+}
+
+// publish は、非同期の購読者にevを届けます。書き込みをコミットした後に呼び出してください
+// 待ち行列が溢れているときは、ハンドラを待たせないよう捨てます
+func (b *eventBus) publish(ev event) {
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+	b.mu.RLock()
+	subscribed := len(b.async[ev.Kind]) > 0
+	b.mu.RUnlock()
+	if !subscribed {
+		return
+	}
+
+	select {
+	case b.queue <- ev:
+	default:
+		appLogger.Warn("event queue is full, dropping event", slog.String("kind", string(ev.Kind)))
+	}
+}
+
+// subscribeEventHandlers は、各機能をイベントの購読者として登録します
+// subdomainsなどの初期化を終えてから呼び出してください
+func subscribeEventHandlers(b *eventBus) {
+	// DNSのレコードは、登録した直後からサブドメインで使われるので、ユーザの登録・削除と同じトランザクションの最後にすぐ更新する
+	// 失敗したときはジョブとして同じトランザクションに積み、workerにやり直させる
+	b.subscribe(eventUserRegistered, func(ctx context.Context, ev event) error {
+		return jobs.runNowOrEnqueue(ctx, ev.db(), jobAddSubdomain, subdomainJobPayload{Name: ev.Username})
+	})
+	b.subscribe(eventUserDeleted, func(ctx context.Context, ev event) error {
+		return jobs.runNowOrEnqueue(ctx, ev.db(), jobDeleteSubdomain, subdomainJobPayload{Name: ev.Username})
+	})
+
+	// 統計情報の集計は、定期集計を待たずに反映させるためのきっかけとして使う
+	b.subscribeAsync(eventSuperchatPosted, requestStatisticsAggregation)
+	b.subscribeAsync(eventLivestreamReserved, requestStatisticsAggregation)
+
+	// 通知は、ハンドラを待たせず、書き込みに失敗しても取りこぼさないよう、ジョブとして積んでworkerに書き込ませる
+	// ピン留めは操作と同じトランザクションで積み、コミットした後にworkerに知らせる
+	b.subscribeAsync(eventLivestreamStarted, func(ctx context.Context, ev event) error {
+		if err := jobs.enqueue(ctx, dbConn, jobNotifyLivestreamStarted, notificationJobPayload{UserID: ev.UserID, LivestreamID: ev.LivestreamID, At: ev.At.Unix()}); err != nil {
+			return err
+		}
+		jobs.notify()
+		return nil
+	})
+	b.subscribe(eventLivecommentPinned, func(ctx context.Context, ev event) error {
+		return jobs.enqueue(ctx, ev.db(), jobNotifyLivecommentPinned, notificationJobPayload{UserID: ev.UserID, LivestreamID: ev.LivestreamID, LivecommentID: ev.LivecommentID, At: ev.At.Unix()})
+	})
+	b.subscribeAsync(eventLivecommentPinned, func(ctx context.Context, ev event) error {
+		jobs.notify()
+		return nil
+	})
+
+	// 監査ログは取りこぼさないよう、操作と同じトランザクションで同期に書き込む
+	for _, kind := range auditedEventKinds {
+		b.subscribe(kind, recordAuditLog)
+	}
+}
+
+// run は、非同期の購読者にイベントを届け続けます
+// 購読者は1つのゴルーチンから順に呼び出すので、時間のかかる処理は購読者の中で別のゴルーチンに移してください
+func (b *eventBus) run(logger echo.Logger) {
+	for ev := range b.queue {
+		b.mu.RLock()
+		handlers := b.async[ev.Kind]
+		b.mu.RUnlock()
+
+		for _, handler := range handlers {
+			if err := handler(context.Background(), ev); err != nil {
+				logger.Warnf("failed to handle %s event: %v", ev.Kind, err)
+			}
+		}
+	}
+}
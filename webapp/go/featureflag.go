@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync/atomic"
+
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+// featureFlag は、ベンチマークの合間に実装を切り替えて比べるためのフラグです
+// 再起動せずに PUT /admin/flags で切り替えられます
+type featureFlag struct {
+	name        string
+	description string
+	value       atomic.Bool
+}
+
+// featureFlags は、名前 => フラグです
+// 起動時に登録した後は読み取りのみなので、ロックは取りません
+var featureFlags = map[string]*featureFlag{}
+
+func newFeatureFlag(name, description string, defaultValue bool) *featureFlag {
+	f := &featureFlag{name: name, description: description}
+	f.value.Store(defaultValue)
+	featureFlags[name] = f
+	return f
+}
+
+func (f *featureFlag) enabled() bool {
+	return f.value.Load()
+}
+
+func (f *featureFlag) set(enabled bool) {
+	f.value.Store(enabled)
+}
+
+var (
+	// セッションの中身をクッキーではなくsharedCacheに保存する
+	// 既定ではキャッシュのバックエンドに合わせ、Redisのときのみ有効にする
+	flagServerSessions = newFeatureFlag("server_sessions", "store session values in the shared cache instead of cookies", false)
+	// 配信・配信者のスコアを集計カラムから求める。無効にすると都度集計する
+	// 集計カラムの更新は無効にしても続けるので、有効に戻してもずれない
+	flagDenormalizedScores = newFeatureFlag("denormalized_scores", "read scores from the denormalized columns instead of aggregating", true)
+	// リアクションや入退室の書き込みをまとめて後から行う。無効にするとリクエストの中で書き込む
+	flagWriteBehind = newFeatureFlag("write_behind", "batch reaction and viewer writes in the background", true)
+	// 統計情報を、バックグラウンドで毎秒集計した結果から返す。最大でstatisticsMaxStaleness古い値を返すことがある
+	// 書き込んだ直後の値を確かめる整合性チェックに通らないので、既定では無効にする
+	flagPrecomputedStatistics = newFeatureFlag("precomputed_statistics", "serve statistics from the tables refreshed by the background aggregator", false)
+)
+
+// setFeatureFlags は、valuesのフラグをまとめて切り替えます
+// 知らない名前が含まれていれば、何も切り替えずにエラーを返します
+func setFeatureFlags(values map[string]bool) error {
+	for name := range values {
+		if _, ok := featureFlags[name]; !ok {
+			return fmt.Errorf("unknown feature flag: %s", name)
+		}
+	}
+	for name, enabled := range values {
+		f := featureFlags[name]
+		if f.value.Swap(enabled) != enabled {
+			appLogger.Info("feature flag changed", slog.String("flag", name), slog.Bool("enabled", enabled))
+		}
+	}
+	return nil
+}
+
+type FeatureFlag struct {
+	Name        string `json:"name"`
+	Enabled     bool   `json:"enabled"`
+	Description string `json:"description"`
+}
+
+func featureFlagResponses() []FeatureFlag {
+	flags := make([]FeatureFlag, 0, len(featureFlags))
+	for _, f := range featureFlags {
+		flags = append(flags, FeatureFlag{
+			Name:        f.name,
+			Enabled:     f.enabled(),
+			Description: f.description,
+		})
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags
+}
+
+func getFeatureFlagsHandler(c echo.Context) error {
+	if err := verifyAdminSession(c); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, featureFlagResponses())
+}
+
+// putFeatureFlagsHandler は、{"フラグ名": true} の形で指定したフラグを切り替えます
+// 指定しなかったフラグはそのままです
+func putFeatureFlagsHandler(c echo.Context) error {
+	if err := verifyAdminSession(c); err != nil {
+		return err
+	}
+
+	var req map[string]bool
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return apperror.BadRequest("failed to decode the request body as json")
+	}
+	if err := setFeatureFlags(req); err != nil {
+		return apperror.BadRequest(err.Error())
+	}
+
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+	detail, err := json.Marshal(req)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to encode feature flags: "+err.Error())
+	}
+	if err := events.publishInTx(c.Request().Context(), event{Kind: eventFeatureFlagsChanged, UserID: userID, Detail: string(detail)}); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to record audit log: "+err.Error())
+	}
+	// nginxの後ろのどのアプリケーションサーバでも同じ実装で動くようにする
+	peers.broadcast(peerMessage{Kind: peerMessageFeatureFlags, FeatureFlags: req})
+
+	return c.JSON(http.StatusOK, featureFlagResponses())
+}
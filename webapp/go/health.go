@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// readyzでDBの応答を待つ時間
+const readinessDBPingTimeout = 100 * time.Millisecond
+
+// notReadyReason は、リクエストを処理できない理由です。nilのときは処理できます
+// 起動時のキャッシュの読み込みが終わるまでと、初期化APIの処理中は理由が入ります
+var notReadyReason atomic.Pointer[string]
+
+func init() {
+	setNotReady("warming up")
+}
+
+func setReady() {
+	notReadyReason.Store(nil)
+}
+
+func setNotReady(reason string) {
+	notReadyReason.Store(&reason)
+}
+
+// 生存確認
+// GET /healthz
+func getHealthzHandler(c echo.Context) error {
+	return c.String(http.StatusOK, "ok")
+}
+
+// 準備完了の確認
+// GET /readyz
+func getReadyzHandler(c echo.Context) error {
+	if reason := notReadyReason.Load(); reason != nil {
+		return c.String(http.StatusServiceUnavailable, "not ready: "+*reason)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), readinessDBPingTimeout)
+	defer cancel()
+	if err := dbConn.PingContext(ctx); err != nil {
+		return c.String(http.StatusServiceUnavailable, "not ready: db ping failed: "+err.Error())
+	}
+	return c.String(http.StatusOK, "ok")
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// ホーム画面に表示する、最近視聴した配信者の数
+	homeStreamersLimit = 20
+	// ホーム画面に表示する、予約済みの配信の数
+	homeUpcomingLimit = 20
+)
+
+type HomeResponse struct {
+	User User `json:"user"`
+	// 最近視聴した配信者。視聴した日時の新しい順
+	Streamers []User `json:"streamers"`
+	// Streamersの予約済みの公開配信。開始日時の早い順
+	Upcoming []Livestream `json:"upcoming"`
+	// 配信中のトレンド配信。スコアの高い順
+	Trending []TrendingLivestream `json:"trending"`
+}
+
+// ホーム画面に必要な情報をまとめて返すAPI
+// GET /api/home
+// NOTE: 配信者の購読の仕組みはないので、視聴履歴のある配信者を購読しているものとして扱います
+func getHomeHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+	now := time.Now()
+
+	if err := verifyUserSession(c); err != nil {
+		// echo.NewHTTPErrorが返っているのでそのまま出力
+		return err
+	}
+
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	db := readDB(c)
+
+	user, err := userProfiles.getByID(ctx, db, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperror.NotFound("not found user that has the userid in session")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user: "+err.Error())
+	}
+
+	var streamerIDs []int64
+	if err := db.SelectContext(ctx, &streamerIDs, `
+	SELECT l.user_id
+	FROM watch_history h
+	INNER JOIN livestreams l ON l.id = h.livestream_id
+	WHERE h.user_id = ?
+	GROUP BY l.user_id
+	ORDER BY MAX(h.created_at) DESC, l.user_id DESC
+	LIMIT ?`, userID, homeStreamersLimit); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get watched streamers: "+err.Error())
+	}
+
+	res := HomeResponse{
+		User:      user,
+		Streamers: []User{},
+		Upcoming:  []Livestream{},
+	}
+	if len(streamerIDs) > 0 {
+		users, err := loadUsers(ctx, db, streamerIDs)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get streamers: "+err.Error())
+		}
+		for _, id := range streamerIDs {
+			// 削除されたユーザは除く
+			if streamer, ok := users[id]; ok {
+				res.Streamers = append(res.Streamers, streamer)
+			}
+		}
+
+		query, params, err := sqlx.In(`
+		SELECT * FROM livestreams
+		WHERE user_id IN (?) AND start_at > ? AND privacy_status = ?
+		ORDER BY start_at ASC, id ASC
+		LIMIT ?`, streamerIDs, now.Unix(), livestreamPrivacyPublic, homeUpcomingLimit)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to construct IN query: "+err.Error())
+		}
+		var livestreamModels []*LivestreamModel
+		if err := db.SelectContext(ctx, &livestreamModels, query, params...); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get upcoming livestreams: "+err.Error())
+		}
+		upcoming, err := fillLivestreamResponses(ctx, db, livestreamModels)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livestream: "+err.Error())
+		}
+		res.Upcoming = append(res.Upcoming, upcoming...)
+	}
+
+	res.Trending, err = listTrendingLivestreams(ctx, db, now, defaultTrendingLimit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
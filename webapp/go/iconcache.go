@@ -0,0 +1,129 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/sync/singleflight"
+)
+
+// iconLRU は、最近返したアイコン画像をハッシュごとにメモリ上に持ちます
+// 画像はハッシュで識別するので、アイコンが変更されても古いハッシュの画像が使われなくなるだけで、無効化は要りません
+// 合計の大きさがmaxBytesを超えたら、最も長く使われていないものから捨てます
+type iconLRU struct {
+	mu       sync.Mutex
+	maxBytes int
+	bytes    int
+	// 先頭ほど最近使われた
+	order   *list.List
+	entries map[string]*list.Element
+	stats   *cacheStats
+}
+
+type iconLRUEntry struct {
+	hash  string
+	image []byte
+}
+
+var iconCache = newIconLRU(64 << 20)
+
+func newIconLRU(maxBytes int) *iconLRU {
+	return &iconLRU{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		stats:    newCacheStats("icons"),
+	}
+}
+
+// get は、hashの画像を返します
+// 返した画像は他のリクエストと共有するので、書き換えないでください
+func (l *iconLRU) get(hash string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elem, ok := l.entries[hash]
+	l.stats.record(ok)
+	if !ok {
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+	return elem.Value.(*iconLRUEntry).image, true
+}
+
+func (l *iconLRU) add(hash string, image []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// 1枚で上限を超える画像は持たない
+	if len(image) > l.maxBytes {
+		return
+	}
+	if elem, ok := l.entries[hash]; ok {
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.entries[hash] = l.order.PushFront(&iconLRUEntry{hash: hash, image: image})
+	l.bytes += len(image)
+	for l.bytes > l.maxBytes {
+		oldest := l.order.Back()
+		entry := oldest.Value.(*iconLRUEntry)
+		l.order.Remove(oldest)
+		delete(l.entries, entry.hash)
+		l.bytes -= len(entry.image)
+	}
+}
+
+func (l *iconLRU) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bytes = 0
+	l.order.Init()
+	l.entries = make(map[string]*list.Element)
+}
+
+// fetchedIcon は、ユーザのアイコンを読み込んだ結果です
+type fetchedIcon struct {
+	// falseのときは、アイコンを設定していない
+	found bool
+	icon  IconModel
+	// 画像の中身。保存先が中身を読まずに返すときはnil
+	image []byte
+}
+
+// iconFetches は、同じユーザのアイコンを同時に読み込むリクエストを1回の読み込みにまとめます
+var iconFetches singleflight.Group
+
+// fetchIcon は、ユーザのアイコンを読み込み、画像の中身をiconCacheに入れます
+// 配信者のアイコンは多数の視聴者から同時に要求されるので、読み込みはユーザごとに同時に1回だけ行います
+// 読み込みは最初のリクエストのctxのキャンセルに巻き込まれないよう、キャンセルを切り離したctxで実行します
+func fetchIcon(ctx context.Context, db *sqlx.DB, userID int64) (fetchedIcon, error) {
+	v, err, _ := iconFetches.Do(strconv.FormatInt(userID, 10), func() (interface{}, error) {
+		ctx := context.WithoutCancel(ctx)
+		var icon IconModel
+		if err := db.GetContext(ctx, &icon, "SELECT * FROM icons WHERE user_id = ?", userID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fetchedIcon{}, nil
+			}
+			return fetchedIcon{}, err
+		}
+		image, ok, err := icons.read(icon)
+		if err != nil {
+			return fetchedIcon{}, err
+		}
+		if !ok {
+			return fetchedIcon{found: true, icon: icon}, nil
+		}
+		iconCache.add(iconHashOf(icon), image)
+		// 画像はiconCacheに持つので、行の中身は捨てる
+		icon.Image = nil
+		return fetchedIcon{found: true, icon: icon, image: image}, nil
+	})
+	if err != nil {
+		return fetchedIcon{}, err
+	}
+	return v.(fetchedIcon), nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/isucon/isucon13/webapp/go/internal/config"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+type IconModel struct {
+	ID     int64  `db:"id"`
+	UserID int64  `db:"user_id"`
+	Image  []byte `db:"image"`
+	Hash   string `db:"hash"`
+}
+
+// アイコンのハッシュは投稿時に求めてhashカラムに保存する
+// 記録する前に保存されたアイコンのみ、画像を転送せずにMySQLで求める
+const iconHashColumn = "IF(hash = '', SHA2(image, 256), hash)"
+
+const selectIconHashes = "SELECT " + iconHashColumn + " FROM icons"
+
+var (
+	fallbackIconHashOnce  sync.Once
+	fallbackIconHashValue string
+	fallbackIconHashErr   error
+)
+
+// fallbackIconHash は、アイコンを設定していないユーザのicon_hashを返します
+// 画像は変わらないので、一度だけ求めます
+func fallbackIconHash() (string, error) {
+	fallbackIconHashOnce.Do(func() {
+		image, err := os.ReadFile(fallbackImage)
+		if err != nil {
+			fallbackIconHashErr = err
+			return
+		}
+		fallbackIconHashValue = fmt.Sprintf("%x", sha256.Sum256(image))
+	})
+	return fallbackIconHashValue, fallbackIconHashErr
+}
+
+// ifNoneMatch は、If-None-Matchヘッダの値にetagが含まれるかを返します
+func ifNoneMatch(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// iconHashOf は、アイコンのハッシュを返します
+// ハッシュを記録する前に保存されたアイコンは、画像から求めます
+func iconHashOf(icon IconModel) string {
+	if icon.Hash != "" {
+		return icon.Hash
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(icon.Image))
+}
+
+// iconStorage は、アイコン画像の中身の保存先です
+// 画像はSHA-256のハッシュで識別し、iconsテーブルにはユーザとハッシュの対応を記録します
+type iconStorage interface {
+	// column は、iconsテーブルのimageカラムに書き込む値を返します
+	column(image []byte) []byte
+	// put は、imageをhashで保存します。iconsの行を書き込む前に呼び出してください
+	put(hash string, image []byte) error
+	// read は、iconの画像の中身を返します
+	// nginxに返させるなど、中身を読まずにserveで返す保存先ではfalseを返します
+	read(icon IconModel) ([]byte, bool, error)
+	// serve は、iconの画像をレスポンスとして返します
+	serve(c echo.Context, icon IconModel) error
+}
+
+var icons iconStorage = dbIconStorage{}
+
+// newIconStorage は、設定に応じた保存先を返します
+func newIconStorage(c config.Icons) (iconStorage, error) {
+	if c.Storage != config.IconStorageDisk {
+		return dbIconStorage{}, nil
+	}
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create icon directory: %w", err)
+	}
+	return &diskIconStorage{dir: c.Dir, accelRedirectPrefix: c.AccelRedirectPrefix}, nil
+}
+
+// dbIconStorage は、画像をiconsテーブルのBLOBに保存します
+type dbIconStorage struct{}
+
+func (dbIconStorage) column(image []byte) []byte {
+	return image
+}
+
+func (dbIconStorage) put(hash string, image []byte) error {
+	return nil
+}
+
+func (dbIconStorage) read(icon IconModel) ([]byte, bool, error) {
+	return icon.Image, true, nil
+}
+
+func (dbIconStorage) serve(c echo.Context, icon IconModel) error {
+	return c.Blob(http.StatusOK, "image/jpeg", icon.Image)
+}
+
+// diskIconStorage は、画像をハッシュをファイル名として保存します
+// 同じ画像は1つのファイルを共有するので、ユーザやアイコンを消してもファイルは残します
+type diskIconStorage struct {
+	dir                 string
+	accelRedirectPrefix string
+}
+
+func iconFileName(hash string) string {
+	return hash + ".jpg"
+}
+
+func (s *diskIconStorage) column(image []byte) []byte {
+	return []byte{}
+}
+
+func (s *diskIconStorage) put(hash string, image []byte) error {
+	name := filepath.Join(s.dir, iconFileName(hash))
+	if _, err := os.Stat(name); err == nil {
+		return nil
+	}
+	// 書きかけのファイルを返さないよう、別の名前で書いてから置き換える
+	f, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(image); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+func (s *diskIconStorage) read(icon IconModel) ([]byte, bool, error) {
+	// ファイルに移す前のアイコンは、BLOBにある
+	if len(icon.Image) > 0 || icon.Hash == "" {
+		return icon.Image, true, nil
+	}
+	if s.accelRedirectPrefix != "" {
+		return nil, false, nil
+	}
+	image, err := os.ReadFile(filepath.Join(s.dir, iconFileName(icon.Hash)))
+	if err != nil {
+		return nil, false, err
+	}
+	return image, true, nil
+}
+
+func (s *diskIconStorage) serve(c echo.Context, icon IconModel) error {
+	// ファイルに移す前のアイコンは、BLOBから返す
+	if len(icon.Image) > 0 || icon.Hash == "" {
+		return c.Blob(http.StatusOK, "image/jpeg", icon.Image)
+	}
+	if s.accelRedirectPrefix != "" {
+		c.Response().Header().Set("X-Accel-Redirect", path.Join(s.accelRedirectPrefix, iconFileName(icon.Hash)))
+		c.Response().Header().Set(echo.HeaderContentType, "image/jpeg")
+		return c.NoContent(http.StatusOK)
+	}
+	return c.File(filepath.Join(s.dir, iconFileName(icon.Hash)))
+}
+
+// migrateIconsToDisk は、iconsテーブルのBLOBをファイルに移します
+// 1件ずつファイルを書いてからBLOBを消すので、途中で止まってもやり直せます
+func migrateIconsToDisk(ctx context.Context, db *sqlx.DB, storage *diskIconStorage, progress io.Writer) (int, error) {
+	var ids []int64
+	if err := db.SelectContext(ctx, &ids, "SELECT id FROM icons WHERE LENGTH(image) > 0 ORDER BY id"); err != nil {
+		return 0, fmt.Errorf("failed to list icons: %w", err)
+	}
+
+	for i, id := range ids {
+		var icon IconModel
+		if err := db.GetContext(ctx, &icon, "SELECT * FROM icons WHERE id = ?", id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				// 移している間にアイコンが変更された
+				continue
+			}
+			return i, fmt.Errorf("failed to get icon %d: %w", id, err)
+		}
+		hash := iconHashOf(icon)
+		if err := storage.put(hash, icon.Image); err != nil {
+			return i, fmt.Errorf("failed to write icon %d: %w", id, err)
+		}
+		if _, err := db.ExecContext(ctx, "UPDATE icons SET image = '', hash = ? WHERE id = ?", hash, id); err != nil {
+			return i, fmt.Errorf("failed to update icon %d: %w", id, err)
+		}
+		if (i+1)%1000 == 0 {
+			fmt.Fprintf(progress, "migrated %d/%d icons\n", i+1, len(ids))
+		}
+	}
+	return len(ids), nil
+}
+
+// runMigrateIconsCommand は、migrate-iconsサブコマンドの本体です
+// アプリケーションと同じ環境変数 (ISUCON13_ICON_DIRなど) で接続し、既存のアイコンをファイルに移します
+// 終了コードは、成功すれば0、失敗すれば2です
+func runMigrateIconsCommand(stdout, stderr io.Writer) int {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid configuration:\n%v\n", err)
+		return 2
+	}
+	if cfg.Icons.Storage != config.IconStorageDisk {
+		fmt.Fprintf(stderr, "ISUCON13_ICON_STORAGE must be %s to migrate icons\n", config.IconStorageDisk)
+		return 2
+	}
+	storage, err := newIconStorage(cfg.Icons)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 2
+	}
+	db, err := connectDB(newDBConfig(cfg.DB), newDBPoolConfig(cfg.DB))
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to connect db: %v\n", err)
+		return 2
+	}
+	defer db.Close()
+
+	n, err := migrateIconsToDisk(context.Background(), db, storage.(*diskIconStorage), stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v (migrated %d icons before the failure)\n", err, n)
+		return 2
+	}
+	fmt.Fprintf(stdout, "migrated %d icons to %s\n", n, cfg.Icons.Dir)
+	return 0
+}
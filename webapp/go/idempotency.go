@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// 指定されていれば、同じキーで再送されたリクエストは新たに作らず、最初に作ったものを返す
+	// タイムアウト後の再送でチップが二重に計上されないようにするために使う
+	idempotencyKeyHeader = "Idempotency-Key"
+	idempotencyKeyMaxLen = 255
+	// 再送を受け付ける期間
+	idempotencyKeyTTL = 10 * time.Minute
+)
+
+// 同じキーのリクエストが同時に届いたときに、後から来た方を最初のリクエストが終わるまで待たせる
+// キーごとにロックを作ると消すタイミングが難しいので、キーのハッシュで分けた固定数のロックを使う
+// 待たせられるのは同じアプリケーションサーバに届いたリクエスト同士のみ
+var idempotencyLocks [64]sync.Mutex
+
+// idempotencyRecord は、キーで作ったリソースの記録です
+type idempotencyRecord struct {
+	// 同じキーが別の内容のリクエストに使い回されていないかを確かめる
+	Fingerprint string `json:"fingerprint"`
+	ResourceID  int64  `json:"resource_id"`
+}
+
+// idempotentRequest は、Idempotency-Keyを指定したリクエストの状態です
+// ヘッダがなければ何もしません
+type idempotentRequest struct {
+	cacheKey    string
+	fingerprint string
+	lock        *sync.Mutex
+
+	replay     bool
+	resourceID int64
+}
+
+// beginIdempotentRequest は、Idempotency-Keyを確認し、再送であれば最初に作ったリソースのIDを読み込みます
+// キーはユーザとscopeごとに区別します。reqはリクエストの内容で、同じキーで内容が異なればエラーにします
+// 呼び出し側は、処理を終えたら必ずendを呼び出してください
+func beginIdempotentRequest(c echo.Context, scope string, userID int64, req any) (*idempotentRequest, error) {
+	key := c.Request().Header.Get(idempotencyKeyHeader)
+	if key == "" {
+		return &idempotentRequest{}, nil
+	}
+	if len(key) > idempotencyKeyMaxLen {
+		return nil, apperror.BadRequest("Idempotency-Key is too long")
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to encode request: "+err.Error())
+	}
+	sum := sha256.Sum256(body)
+	r := &idempotentRequest{
+		cacheKey:    "idempotency:" + scope + ":" + strconv.FormatInt(userID, 10) + ":" + key,
+		fingerprint: hex.EncodeToString(sum[:]),
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(r.cacheKey))
+	r.lock = &idempotencyLocks[h.Sum32()%uint32(len(idempotencyLocks))]
+	r.lock.Lock()
+
+	v, ok, err := sharedCache.Get(c.Request().Context(), r.cacheKey)
+	if err != nil {
+		r.end()
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to get idempotency key: "+err.Error())
+	}
+	if !ok {
+		return r, nil
+	}
+	var record idempotencyRecord
+	if err := json.Unmarshal(v, &record); err != nil {
+		r.end()
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to decode idempotency key: "+err.Error())
+	}
+	if record.Fingerprint != r.fingerprint {
+		r.end()
+		return nil, apperror.Conflict("Idempotency-Key is already used for a different request")
+	}
+	r.replay = true
+	r.resourceID = record.ResourceID
+	return r, nil
+}
+
+// replayed は、再送であれば最初に作ったリソースのIDを返します
+func (r *idempotentRequest) replayed() (int64, bool) {
+	return r.resourceID, r.replay
+}
+
+// complete は、作ったリソースのIDをキーに紐づけて記録します
+// 記録に失敗しても、リソースは作れているのでエラーにはせず、再送を受け付けられないだけにします
+func (r *idempotentRequest) complete(ctx context.Context, resourceID int64) {
+	if r.cacheKey == "" || r.replay {
+		return
+	}
+	v, err := json.Marshal(idempotencyRecord{Fingerprint: r.fingerprint, ResourceID: resourceID})
+	if err != nil {
+		return
+	}
+	// 再送はクライアントがタイムアウトしたときに起きるので、リクエストがキャンセルされていても記録する
+	_ = sharedCache.Set(context.WithoutCancel(ctx), r.cacheKey, v, idempotencyKeyTTL)
+}
+
+func (r *idempotentRequest) end() {
+	if r.lock != nil {
+		r.lock.Unlock()
+		r.lock = nil
+	}
+}
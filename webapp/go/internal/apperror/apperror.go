@@ -0,0 +1,84 @@
+// Package apperror は、ハンドラが返すドメインエラーと、そのHTTPステータスコードへの対応を定義します
+package apperror
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrBadRequest = errors.New("bad request")
+	ErrNotFound   = errors.New("not found")
+	ErrForbidden  = errors.New("forbidden")
+	ErrConflict   = errors.New("conflict")
+	ErrTooLarge   = errors.New("too large")
+	// 更新の前提とした版が最新でない
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// ステータスコードへの対応はここでのみ行う
+var statusCodes = []struct {
+	kind error
+	code int
+}{
+	{ErrBadRequest, http.StatusBadRequest},
+	{ErrNotFound, http.StatusNotFound},
+	{ErrForbidden, http.StatusForbidden},
+	{ErrConflict, http.StatusConflict},
+	{ErrTooLarge, http.StatusRequestEntityTooLarge},
+	{ErrPreconditionFailed, http.StatusPreconditionFailed},
+}
+
+// Error は、種類を表すセンチネルエラーにメッセージを添えたエラーです
+// errors.Is で種類を判定できます
+type Error struct {
+	kind    error
+	message string
+}
+
+func (e *Error) Error() string {
+	return e.message
+}
+
+func (e *Error) Unwrap() error {
+	return e.kind
+}
+
+func newError(kind error, message string) error {
+	return &Error{kind: kind, message: message}
+}
+
+func BadRequest(message string) error {
+	return newError(ErrBadRequest, message)
+}
+
+func NotFound(message string) error {
+	return newError(ErrNotFound, message)
+}
+
+func Forbidden(message string) error {
+	return newError(ErrForbidden, message)
+}
+
+func Conflict(message string) error {
+	return newError(ErrConflict, message)
+}
+
+func TooLarge(message string) error {
+	return newError(ErrTooLarge, message)
+}
+
+func PreconditionFailed(message string) error {
+	return newError(ErrPreconditionFailed, message)
+}
+
+// HTTPStatus は、errに対応するHTTPステータスコードを返します
+// ドメインエラーでなければfalseを返します
+func HTTPStatus(err error) (int, bool) {
+	for _, sc := range statusCodes {
+		if errors.Is(err, sc.kind) {
+			return sc.code, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,18 @@
+// Package cache は、複数のアプリケーションサーバで共有できるキャッシュのインターフェースと、その実装を提供します
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache は、バイト列を期限付きで保持するキャッシュです
+// ttlが0以下のときは期限なしで保持します
+type Cache interface {
+	// Get は、keyに対応する値を返します。なければfalseを返します
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	// Flush は、このキャッシュに入れたすべての値を消します
+	Flush(ctx context.Context) error
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Flushで1回のSCANで調べるキーの数
+const redisScanCount = 1000
+
+// Redis は、Redisに値を保持するCacheです
+// 複数のアプリケーションサーバでキャッシュを共有するときに使います
+// キーにはprefixを付け、Flushではprefixが付いたキーのみを消します
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedis(addr, prefix string, maxIdleConns int) *Redis {
+	return &Redis{
+		client: redis.NewClient(&redis.Options{
+			Addr: addr,
+			// 使い終わった接続を使い回す
+			MaxIdleConns: maxIdleConns,
+		}),
+		prefix: prefix,
+	}
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	// go-redisでは0が期限なしで、負の値は既存の期限を引き継ぐ (KEEPTTL) 意味になるので、0にそろえる
+	if ttl <= 0 {
+		ttl = 0
+	} else if ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+	return r.client.Set(ctx, r.prefix+key, value, ttl).Err()
+}
+
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, r.prefix+key).Err()
+}
+
+func (r *Redis) Flush(ctx context.Context) error {
+	var cursor uint64
+	for {
+		keys, next, err := r.client.Scan(ctx, cursor, r.prefix+"*", redisScanCount).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := r.client.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// Close は、使い回している接続を閉じます
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,760 @@
+// Package config は、アプリケーションのすべての設定を環境変数から読み込みます
+// 設定は起動時に一度だけ読み込み、各機能には読み込んだ値を渡します
+// 環境変数を追加するときは、ここにフィールドと読み込み処理、Summaryへの出力を追加してください
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CacheBackendMemory = "memory"
+	CacheBackendRedis  = "redis"
+
+	IconStorageDB   = "db"
+	IconStorageDisk = "disk"
+
+	RoleWeb    = "web"
+	RoleWorker = "worker"
+	RoleAll    = "all"
+)
+
+// ISUCON13_SESSION_SECRETKEYを指定しなかったときの秘密鍵。公開されているので、他の用途には使わせない
+const defaultSessionSecret = "isucon13_session_cookiestore_defaultsecret"
+
+type Config struct {
+	Server     Server
+	Session    Session
+	DB         DB
+	Cache      Cache
+	PowerDNS   PowerDNS
+	Log        Log
+	Debug      Debug
+	Tracing    Tracing
+	Gzip       Gzip
+	CORS       CORS
+	Timeouts   Timeouts
+	RateLimits RateLimits
+	Warmup     Warmup
+	Peers      Peers
+	Icons      Icons
+	Limits     Limits
+	SoftDelete SoftDelete
+
+	// 起動時の機能フラグの値 (フラグ名 => 有効か)
+	// 指定しなかったフラグは既定値のままです
+	FeatureFlags map[string]bool
+}
+
+type Server struct {
+	// 待ち受けるTCPのポート
+	Port int
+	// 0以上のとき、親プロセスから受け継いだこのファイルディスクリプタで待ち受ける
+	// 再起動のあいだも接続を受け付け続けられるよう、ソケットを保持したまま再起動するプロセスマネージャと組み合わせて使う
+	ListenFD int
+	// 空でなければ、TCPのポートの代わりにこのUnixドメインソケットで待ち受ける
+	// 同じホストのnginxから proxy_pass http://unix:/run/isupipe.sock; のようにつなぐと、TCPのオーバーヘッドを省ける
+	ListenSock string
+	// ソケットファイルのパーミッション。nginxのユーザから書き込めるようにする
+	ListenSockMode fs.FileMode
+	// 終了時に処理中のリクエストを待つ時間
+	ShutdownTimeout time.Duration
+	// trueのとき、HTTP/1.1に加えてTLSなしのHTTP/2 (h2c) も受け付ける
+	// 前段のプロキシからHTTP/2でつなぐと、1つの接続で多重化できるので、高い並列度でも接続の張り直しを減らせる
+	H2C bool
+	// RoleWeb, RoleWorker または RoleAll
+	// webはHTTPのリクエストを処理し、workerは統計情報の集計や通知などのバックグラウンドの処理を行う
+	// 重い処理をベンチマーカーからのリクエストを受けるサーバから外したいときは、別のサーバをworkerとして起動する
+	Role string
+}
+
+type Session struct {
+	Secret []byte
+	// 管理者として扱うユーザのID
+	// ユーザ名は、アカウントを削除した後に別のユーザが登録し直せるため、変わらないIDで指定する
+	AdminUserIDs []int64
+}
+
+type DB struct {
+	Net      string
+	Addr     string
+	User     string
+	Password string
+	Name     string
+
+	ParseTime bool
+	// trueのとき、プレースホルダをクライアント側で展開してプリペアのための往復を省く
+	InterpolateParams bool
+	// trueのとき、ホットパスのクエリを起動時にプリペアして使い回す
+	// InterpolateParamsと組み合わせて、どちらが速いかを比べるときに使う
+	StmtCache bool
+
+	MaxOpenConns int
+	MaxIdleConns int
+	// 0以下は無期限
+	ConnMaxLifetime time.Duration
+
+	// trueのとき、起動時に埋め込んだマイグレーションを適用する
+	Migrate bool
+
+	// 読み取り用のレプリカ (host:port)
+	// ユーザ名やパスワードなど、アドレス以外の接続設定はプライマリと同じものを使う
+	ReplicaHosts []string
+}
+
+type Cache struct {
+	// CacheBackendMemory または CacheBackendRedis
+	// アプリケーションサーバを複数台にするときは、キャッシュを共有するためにredisを使う
+	Backend   string
+	RedisAddr string
+}
+
+type PowerDNS struct {
+	// trueのとき、ローカル開発用にレコードの登録をスキップする
+	Disabled bool
+	// サブドメインのAレコードに登録するアドレス
+	SubdomainAddress string
+	// 空でなければ、pdnsutilの代わりにPowerDNSのHTTP APIを使う
+	APIKey string
+	Host   string
+}
+
+type Log struct {
+	// debug, info, warn, error, off
+	// ベンチマーク中にログの出力を止めたいときはoffを指定する
+	Level string
+	// text, json
+	Format string
+}
+
+type Debug struct {
+	// trueのとき、pprofや実行時の統計情報を別のポートで公開する
+	Enabled bool
+	Addr    string
+	// trueのとき、クエリごとの実行時間を集計し、GET /debug/queries で返す (Enabledのときのみ公開する)
+	QueryStats bool
+	// これより遅いクエリはログに出力する。0のときは出力しない
+	SlowQueryThreshold time.Duration
+}
+
+type Tracing struct {
+	// 空のときはトレースを送信しない
+	OTLPEndpoint string
+	ServiceName  string
+	// 記録するリクエストの割合 (0〜1)
+	SampleRatio float64
+}
+
+type Gzip struct {
+	// 1〜9 (-1は標準, -2はハフマン符号化のみ)。0のときは圧縮しない
+	Level int
+	// これより小さいレスポンスは圧縮しない
+	MinLength int
+}
+
+type CORS struct {
+	// 別のオリジンから配信するフロントエンドのオリジン (例: http://localhost:5173)
+	// 空のときはCORSのヘッダを返さない
+	AllowOrigins []string
+	// プリフライトの結果をブラウザにキャッシュさせる時間
+	MaxAge time.Duration
+}
+
+type Timeouts struct {
+	// ルートごとに指定しなかったAPIのリクエストを打ち切るまでの時間。0のときは打ち切らない
+	// WebSocketやSSEなど、接続し続けるエンドポイントには適用しない
+	Default time.Duration
+	// ルート (echoのパス: /api/user/:username/statistics) ごとの、リクエストを打ち切るまでの時間
+	// Defaultより優先する。0を指定したルートは打ち切らない
+	Routes map[string]time.Duration
+}
+
+type RateLimits struct {
+	// falseのときは制限しない
+	// ベンチマーカーは1つのIPアドレスから多数のユーザとしてアクセスするので、既定では制限しない
+	Enabled bool
+	// ルート ("POST /api/register" のようにメソッドとechoのパス) ごとの上限
+	// ログインしていればユーザごと、していなければIPアドレスごとに数える
+	Routes map[string]RateLimit
+}
+
+// RateLimit は、トークンバケットの設定です
+type RateLimit struct {
+	// 1秒あたりに補充するトークンの数
+	Rate float64
+	// バケットに貯められるトークンの数。連続して受け付けられるリクエストの数になる
+	Burst int
+}
+
+type Warmup struct {
+	// 初期化の後、よく使うキャッシュを読み込んでおくのに使える時間。0のときは読み込まない
+	// 初期化の制限時間を超えないよう、過ぎたら読み込みの途中でも初期化のレスポンスを返す
+	Budget time.Duration
+	// 読み込んでおく、スコアの高い配信者の人数
+	TopUsers int
+}
+
+type Icons struct {
+	// IconStorageDB または IconStorageDisk
+	// diskのときは、画像をMySQLのBLOBではなくDirにハッシュをファイル名として保存する
+	Storage string
+	Dir     string
+	// 空でなければ、画像を返す代わりにX-Accel-Redirectでnginxに返させる (例: /_icons/)
+	// nginxにはこのパスでDirを返すinternalなlocationを用意する
+	AccelRedirectPrefix string
+	// 最近返した画像をメモリに持っておく上限 (バイト)。0のときは持たない
+	CacheBytes int
+}
+
+type Limits struct {
+	// リクエストボディの上限 (バイト)。超えると413を返す
+	// アイコンはJSONにbase64で埋め込まれるので、画像の大きさの4/3倍程度になる
+	JSONBody int
+	IconBody int
+	// アイコン画像の幅・高さの上限 (ピクセル)
+	IconMaxDimension int
+}
+
+type SoftDelete struct {
+	// 論理削除した行を物理削除するまでの期間。0のときは物理削除しない
+	Retention time.Duration
+	// 保持期間を過ぎた行を探して物理削除する間隔
+	PurgeInterval time.Duration
+}
+
+type Peers struct {
+	// メモリ上のキャッシュの無効化を伝える、他のアプリケーションサーバのアドレス (host:port)
+	Addrs []string
+	// 通知を送り合うアプリケーションサーバで共有する秘密鍵。Addrsを指定するときは必須
+	Secret []byte
+}
+
+// Load は、環境変数から設定を読み込みます
+// 不正な値がひとつでもあれば、すべての問題をまとめたエラーを返します
+func Load() (*Config, error) {
+	return LoadFrom(os.LookupEnv)
+}
+
+// LoadFrom は、lookupから設定を読み込みます
+func LoadFrom(lookup func(string) (string, bool)) (*Config, error) {
+	p := &parser{lookup: lookup}
+	c := &Config{}
+
+	c.Server.Port = p.int("ISUCON13_LISTEN_PORT", 8080)
+	c.Server.ListenFD = p.int("ISUCON13_LISTEN_FD", -1)
+	c.Server.ListenSock = p.string("LISTEN_SOCK", "")
+	c.Server.ListenSockMode = p.fileMode("LISTEN_SOCK_MODE", 0o666)
+	c.Server.ShutdownTimeout = p.duration("ISUCON13_SHUTDOWN_TIMEOUT", 10*time.Second)
+	c.Server.H2C = p.bool("ISUCON13_H2C", false)
+	c.Server.Role = strings.ToLower(p.string("APP_ROLE", RoleAll))
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		p.errorf("ISUCON13_LISTEN_PORT must be between 1 and 65535: %d", c.Server.Port)
+	}
+	if c.Server.ShutdownTimeout < 0 {
+		p.errorf("ISUCON13_SHUTDOWN_TIMEOUT must not be negative: %s", c.Server.ShutdownTimeout)
+	}
+	switch c.Server.Role {
+	case RoleWeb, RoleWorker, RoleAll:
+	default:
+		p.errorf("APP_ROLE must be one of %s, %s, %s: %s", RoleWeb, RoleWorker, RoleAll, c.Server.Role)
+	}
+
+	c.Session.Secret = []byte(p.string("ISUCON13_SESSION_SECRETKEY", defaultSessionSecret))
+	c.Session.AdminUserIDs = p.ids("ISUCON13_ADMIN_USER_IDS")
+
+	// 環境変数がセットされていなかった場合でも一旦動かせるように、デフォルト値を入れておく
+	c.DB.Net = p.string("ISUCON13_MYSQL_DIALCONFIG_NET", "tcp")
+	dbHost := p.string("ISUCON13_MYSQL_DIALCONFIG_ADDRESS", "127.0.0.1")
+	dbPort := p.string("ISUCON13_MYSQL_DIALCONFIG_PORT", "3306")
+	c.DB.Addr = net.JoinHostPort(dbHost, dbPort)
+	c.DB.User = p.string("ISUCON13_MYSQL_DIALCONFIG_USER", "isucon")
+	c.DB.Password = p.string("ISUCON13_MYSQL_DIALCONFIG_PASSWORD", "isucon")
+	c.DB.Name = p.string("ISUCON13_MYSQL_DIALCONFIG_DATABASE", "isupipe")
+	c.DB.ParseTime = p.bool("ISUCON13_MYSQL_DIALCONFIG_PARSETIME", true)
+	c.DB.InterpolateParams = p.bool("ISUCON13_MYSQL_INTERPOLATE_PARAMS", false)
+	c.DB.StmtCache = p.bool("ISUCON13_MYSQL_STMT_CACHE", true)
+	c.DB.MaxOpenConns = p.int("ISUCON13_MYSQL_MAX_OPEN_CONNS", 10)
+	c.DB.MaxIdleConns = p.int("ISUCON13_MYSQL_MAX_IDLE_CONNS", 10)
+	c.DB.ConnMaxLifetime = p.duration("ISUCON13_MYSQL_CONN_MAX_LIFETIME", 0)
+	c.DB.Migrate = p.bool("ISUCON13_MYSQL_MIGRATE", true)
+	for _, host := range p.list("DB_REPLICA_HOSTS") {
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, "3306")
+		}
+		c.DB.ReplicaHosts = append(c.DB.ReplicaHosts, host)
+	}
+
+	c.Cache.Backend = p.string("ISUCON13_CACHE_BACKEND", CacheBackendMemory)
+	c.Cache.RedisAddr = p.string("ISUCON13_REDIS_ADDR", "127.0.0.1:6379")
+	if c.Cache.Backend != CacheBackendMemory && c.Cache.Backend != CacheBackendRedis {
+		p.errorf("ISUCON13_CACHE_BACKEND must be %s or %s: %s", CacheBackendMemory, CacheBackendRedis, c.Cache.Backend)
+	}
+
+	c.PowerDNS.Disabled = p.string("ISUCON13_POWERDNS_DISABLED", "") == "true"
+	c.PowerDNS.SubdomainAddress = p.string("ISUCON13_POWERDNS_SUBDOMAIN_ADDRESS", "")
+	c.PowerDNS.APIKey = p.string("ISUCON13_POWERDNS_API_KEY", "")
+	c.PowerDNS.Host = p.string("ISUCON13_POWERDNS_HOST", "127.0.0.1")
+	if !c.PowerDNS.Disabled && c.PowerDNS.SubdomainAddress == "" {
+		p.errorf("ISUCON13_POWERDNS_SUBDOMAIN_ADDRESS must be provided")
+	}
+
+	c.Log.Level = strings.ToLower(p.string("ISUCON13_LOG_LEVEL", "info"))
+	c.Log.Format = strings.ToLower(p.string("ISUCON13_LOG_FORMAT", "text"))
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error", "off":
+	default:
+		p.errorf("ISUCON13_LOG_LEVEL must be one of debug, info, warn, error, off: %s", c.Log.Level)
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		p.errorf("ISUCON13_LOG_FORMAT must be text or json: %s", c.Log.Format)
+	}
+
+	c.Debug.Enabled = p.string("DEBUG", "") == "1"
+	// ベンチマーカーから見えないよう、既定ではループバックのみで待ち受ける
+	c.Debug.Addr = p.string("ISUCON13_DEBUG_ADDR", "127.0.0.1:6060")
+	c.Debug.QueryStats = p.bool("ISUCON13_QUERY_STATS", false)
+	c.Debug.SlowQueryThreshold = p.duration("ISUCON13_SLOW_QUERY_THRESHOLD", 0)
+	if c.Debug.SlowQueryThreshold < 0 {
+		p.errorf("ISUCON13_SLOW_QUERY_THRESHOLD must not be negative: %s", c.Debug.SlowQueryThreshold)
+	}
+
+	c.Tracing.OTLPEndpoint = p.string("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	c.Tracing.ServiceName = p.string("OTEL_SERVICE_NAME", "isupipe")
+	c.Tracing.SampleRatio = p.float("ISUCON13_TRACE_SAMPLE_RATIO", 1)
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		p.errorf("ISUCON13_TRACE_SAMPLE_RATIO must be between 0 and 1: %v", c.Tracing.SampleRatio)
+	}
+
+	c.Gzip.Level = p.int("ISUCON13_GZIP_LEVEL", 1)
+	c.Gzip.MinLength = p.int("ISUCON13_GZIP_MIN_LENGTH", 1024)
+	if c.Gzip.Level < -2 || c.Gzip.Level > 9 {
+		p.errorf("ISUCON13_GZIP_LEVEL must be between -2 and 9: %d", c.Gzip.Level)
+	}
+	if c.Gzip.MinLength < 0 {
+		p.errorf("ISUCON13_GZIP_MIN_LENGTH must not be negative: %d", c.Gzip.MinLength)
+	}
+
+	// セッションのクッキーを送らせるので、*は受け付けない
+	c.CORS.AllowOrigins = p.list("ISUCON13_CORS_ALLOW_ORIGINS")
+	c.CORS.MaxAge = p.duration("ISUCON13_CORS_MAX_AGE", 10*time.Minute)
+	for _, origin := range c.CORS.AllowOrigins {
+		if origin == "*" {
+			p.errorf("ISUCON13_CORS_ALLOW_ORIGINS must list origins explicitly because credentials are allowed")
+		}
+	}
+	if c.CORS.MaxAge < 0 {
+		p.errorf("ISUCON13_CORS_MAX_AGE must not be negative: %s", c.CORS.MaxAge)
+	}
+
+	// 統計情報の集計は重いので、負荷が高まったときにワーカーを使い切らないよう既定で打ち切る
+	c.Timeouts.Default = p.duration("ISUCON13_REQUEST_TIMEOUT", 0)
+	c.Timeouts.Routes = p.durations("ISUCON13_ROUTE_TIMEOUTS", map[string]time.Duration{
+		"/api/user/:username/statistics":            5 * time.Second,
+		"/api/livestream/:livestream_id/statistics": 5 * time.Second,
+	})
+	if c.Timeouts.Default < 0 {
+		p.errorf("ISUCON13_REQUEST_TIMEOUT must not be negative: %s", c.Timeouts.Default)
+	}
+	for route, timeout := range c.Timeouts.Routes {
+		if !strings.HasPrefix(route, "/") {
+			p.errorf("ISUCON13_ROUTE_TIMEOUTS must be a list of path=duration: %s", route)
+		}
+		if timeout < 0 {
+			p.errorf("ISUCON13_ROUTE_TIMEOUTS must not be negative: %s=%s", route, timeout)
+		}
+	}
+
+	c.RateLimits.Enabled = p.bool("ISUCON13_RATE_LIMIT", false)
+	c.RateLimits.Routes = p.rateLimits("ISUCON13_RATE_LIMITS", map[string]RateLimit{
+		"POST /api/register":                              {Rate: 1, Burst: 10},
+		"POST /api/login":                                 {Rate: 2, Burst: 20},
+		"POST /api/icon":                                  {Rate: 1, Burst: 5},
+		"POST /api/livestream/reservation":                {Rate: 2, Burst: 10},
+		"POST /api/livestream/:livestream_id/livecomment": {Rate: 5, Burst: 20},
+		"POST /api/livestream/:livestream_id/reaction":    {Rate: 10, Burst: 40},
+		"GET /api/user/:username/statistics":              {Rate: 2, Burst: 10},
+		"GET /api/livestream/:livestream_id/statistics":   {Rate: 2, Burst: 10},
+	})
+	for route, limit := range c.RateLimits.Routes {
+		method, path, ok := strings.Cut(route, " ")
+		if !ok || method == "" || !strings.HasPrefix(path, "/") {
+			p.errorf("ISUCON13_RATE_LIMITS must be a list of \"METHOD path=rate:burst\": %s", route)
+		}
+		if limit.Rate <= 0 || limit.Burst <= 0 {
+			p.errorf("ISUCON13_RATE_LIMITS must have positive rate and burst: %s", route)
+		}
+	}
+
+	c.Warmup.Budget = p.duration("ISUCON13_WARMUP_BUDGET", 5*time.Second)
+	c.Warmup.TopUsers = p.int("ISUCON13_WARMUP_TOP_USERS", 1000)
+	if c.Warmup.Budget < 0 {
+		p.errorf("ISUCON13_WARMUP_BUDGET must not be negative: %s", c.Warmup.Budget)
+	}
+	if c.Warmup.TopUsers < 0 {
+		p.errorf("ISUCON13_WARMUP_TOP_USERS must not be negative: %d", c.Warmup.TopUsers)
+	}
+
+	// 自分自身は含めない
+	c.Peers.Addrs = p.list("ISUCON13_PEERS")
+	for _, addr := range c.Peers.Addrs {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			p.errorf("ISUCON13_PEERS must be a list of host:port: %s", addr)
+		}
+	}
+	// 通知を受け付けるエンドポイントは外から叩けるので、公開されているデフォルトの秘密鍵では受け付けない
+	c.Peers.Secret = []byte(p.string("ISUCON13_PEER_SECRET", ""))
+	if len(c.Peers.Addrs) > 0 && (len(c.Peers.Secret) == 0 || string(c.Peers.Secret) == defaultSessionSecret) {
+		p.errorf("ISUCON13_PEER_SECRET must be set to a non-default value when ISUCON13_PEERS is set")
+	}
+
+	c.Icons.Storage = p.string("ISUCON13_ICON_STORAGE", IconStorageDB)
+	c.Icons.Dir = p.string("ISUCON13_ICON_DIR", "../icons")
+	c.Icons.AccelRedirectPrefix = p.string("ISUCON13_ICON_ACCEL_REDIRECT_PREFIX", "")
+	c.Icons.CacheBytes = p.int("ISUCON13_ICON_CACHE_BYTES", 64<<20)
+	if c.Icons.Storage != IconStorageDB && c.Icons.Storage != IconStorageDisk {
+		p.errorf("ISUCON13_ICON_STORAGE must be %s or %s: %s", IconStorageDB, IconStorageDisk, c.Icons.Storage)
+	}
+
+	c.Limits.JSONBody = p.int("ISUCON13_JSON_BODY_LIMIT", 64<<10)
+	c.Limits.IconBody = p.int("ISUCON13_ICON_BODY_LIMIT", 1<<20)
+	c.Limits.IconMaxDimension = p.int("ISUCON13_ICON_MAX_DIMENSION", 4096)
+	if c.Icons.CacheBytes < 0 {
+		p.errorf("ISUCON13_ICON_CACHE_BYTES must not be negative: %d", c.Icons.CacheBytes)
+	}
+
+	if c.Limits.JSONBody <= 0 {
+		p.errorf("ISUCON13_JSON_BODY_LIMIT must be positive: %d", c.Limits.JSONBody)
+	}
+	if c.Limits.IconBody <= 0 {
+		p.errorf("ISUCON13_ICON_BODY_LIMIT must be positive: %d", c.Limits.IconBody)
+	}
+	if c.Limits.IconMaxDimension <= 0 {
+		p.errorf("ISUCON13_ICON_MAX_DIMENSION must be positive: %d", c.Limits.IconMaxDimension)
+	}
+
+	c.SoftDelete.Retention = p.duration("ISUCON13_SOFT_DELETE_RETENTION", 24*time.Hour)
+	c.SoftDelete.PurgeInterval = p.duration("ISUCON13_SOFT_DELETE_PURGE_INTERVAL", 10*time.Minute)
+	if c.SoftDelete.Retention < 0 {
+		p.errorf("ISUCON13_SOFT_DELETE_RETENTION must not be negative: %s", c.SoftDelete.Retention)
+	}
+	if c.SoftDelete.PurgeInterval <= 0 {
+		p.errorf("ISUCON13_SOFT_DELETE_PURGE_INTERVAL must be positive: %s", c.SoftDelete.PurgeInterval)
+	}
+
+	// name=true,name=false のように指定する。値を省略すると有効にする
+	c.FeatureFlags = p.flags("ISUCON13_FEATURE_FLAGS")
+
+	if err := errors.Join(p.errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Summary は、起動時にログへ出すための設定の一覧を返します
+// パスワードなどの秘密の値は伏せます
+func (c *Config) Summary() []string {
+	entries := map[string]string{
+		"server.port":             strconv.Itoa(c.Server.Port),
+		"server.listen_fd":        strconv.Itoa(c.Server.ListenFD),
+		"server.listen_sock":      c.Server.ListenSock,
+		"server.listen_sock_mode": fmt.Sprintf("%04o", c.Server.ListenSockMode),
+		"server.shutdown_timeout": c.Server.ShutdownTimeout.String(),
+		"server.h2c":              strconv.FormatBool(c.Server.H2C),
+		"server.role":             c.Server.Role,
+		"session.secret":          mask(string(c.Session.Secret)),
+		"session.admin_user_ids":  formatIDs(c.Session.AdminUserIDs),
+		"db.net":                  c.DB.Net,
+		"db.addr":                 c.DB.Addr,
+		"db.user":                 c.DB.User,
+		"db.password":             mask(c.DB.Password),
+		"db.name":                 c.DB.Name,
+		"db.parse_time":           strconv.FormatBool(c.DB.ParseTime),
+		"db.interpolate_params":   strconv.FormatBool(c.DB.InterpolateParams),
+		"db.stmt_cache":           strconv.FormatBool(c.DB.StmtCache),
+		"db.max_open_conns":       strconv.Itoa(c.DB.MaxOpenConns),
+		"db.max_idle_conns":       strconv.Itoa(c.DB.MaxIdleConns),
+		"db.conn_max_lifetime":    c.DB.ConnMaxLifetime.String(),
+		"db.migrate":              strconv.FormatBool(c.DB.Migrate),
+		"db.replica_hosts":        strings.Join(c.DB.ReplicaHosts, ","),
+		"cache.backend":           c.Cache.Backend,
+		"cache.redis_addr":        c.Cache.RedisAddr,
+		"powerdns.disabled":       strconv.FormatBool(c.PowerDNS.Disabled),
+		"powerdns.address":        c.PowerDNS.SubdomainAddress,
+		"powerdns.api_key":        mask(c.PowerDNS.APIKey),
+		"powerdns.host":           c.PowerDNS.Host,
+		"log.level":               c.Log.Level,
+		"log.format":              c.Log.Format,
+		"debug.enabled":           strconv.FormatBool(c.Debug.Enabled),
+		"debug.addr":              c.Debug.Addr,
+		"debug.query_stats":       strconv.FormatBool(c.Debug.QueryStats),
+		"debug.slow_query":        c.Debug.SlowQueryThreshold.String(),
+		"tracing.otlp_endpoint":   c.Tracing.OTLPEndpoint,
+		"tracing.service_name":    c.Tracing.ServiceName,
+		"tracing.sample_ratio":    strconv.FormatFloat(c.Tracing.SampleRatio, 'g', -1, 64),
+		"gzip.level":              strconv.Itoa(c.Gzip.Level),
+		"gzip.min_length":         strconv.Itoa(c.Gzip.MinLength),
+		"cors.allow_origins":      strings.Join(c.CORS.AllowOrigins, ","),
+		"cors.max_age":            c.CORS.MaxAge.String(),
+		"timeouts.default":        c.Timeouts.Default.String(),
+		"timeouts.routes":         formatDurations(c.Timeouts.Routes),
+		"rate_limits.enabled":     strconv.FormatBool(c.RateLimits.Enabled),
+		"rate_limits.routes":      formatRateLimits(c.RateLimits.Routes),
+		"warmup.budget":           c.Warmup.Budget.String(),
+		"warmup.top_users":        strconv.Itoa(c.Warmup.TopUsers),
+		"peers.addrs":             strings.Join(c.Peers.Addrs, ","),
+		"peers.secret":            mask(string(c.Peers.Secret)),
+		"icons.storage":           c.Icons.Storage,
+		"icons.dir":               c.Icons.Dir,
+		"icons.accel_redirect":    c.Icons.AccelRedirectPrefix,
+		"icons.cache_bytes":       strconv.Itoa(c.Icons.CacheBytes),
+		"limits.json_body":        strconv.Itoa(c.Limits.JSONBody),
+		"limits.icon_body":        strconv.Itoa(c.Limits.IconBody),
+		"limits.icon_dimension":   strconv.Itoa(c.Limits.IconMaxDimension),
+		"soft_delete.retention":   c.SoftDelete.Retention.String(),
+		"soft_delete.purge":       c.SoftDelete.PurgeInterval.String(),
+	}
+	for name, enabled := range c.FeatureFlags {
+		entries["feature_flags."+name] = strconv.FormatBool(enabled)
+	}
+	lines := make([]string, 0, len(entries))
+	for key, value := range entries {
+		lines = append(lines, key+"="+value)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// formatDurations は、キーの順に key=duration をカンマ区切りで並べます
+func formatDurations(m map[string]time.Duration) string {
+	items := make([]string, 0, len(m))
+	for key, d := range m {
+		items = append(items, key+"="+d.String())
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// formatRateLimits は、ルートの順に route=rate:burst をカンマ区切りで並べます
+func formatRateLimits(m map[string]RateLimit) string {
+	items := make([]string, 0, len(m))
+	for route, limit := range m {
+		items = append(items, fmt.Sprintf("%s=%s:%d", route, strconv.FormatFloat(limit.Rate, 'g', -1, 64), limit.Burst))
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "****"
+}
+
+// parser は、環境変数を型ごとに読み込み、不正な値をまとめて記録します
+type parser struct {
+	lookup func(string) (string, bool)
+	errs   []error
+}
+
+func (p *parser) errorf(format string, args ...any) {
+	p.errs = append(p.errs, fmt.Errorf(format, args...))
+}
+
+func (p *parser) string(key, def string) string {
+	if v, ok := p.lookup(key); ok {
+		return v
+	}
+	return def
+}
+
+// list は、カンマ区切りの値を空の要素を除いて返します
+func (p *parser) list(key string) []string {
+	v, ok := p.lookup(key)
+	if !ok {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ids は、カンマ区切りのIDを読み込みます
+func (p *parser) ids(key string) []int64 {
+	var ids []int64
+	for _, item := range p.list(key) {
+		id, err := strconv.ParseInt(item, 10, 64)
+		if err != nil {
+			p.errorf("failed to parse environment variable '%s' as comma-separated ids: %+v", key, err)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func formatIDs(ids []int64) string {
+	items := make([]string, len(ids))
+	for i, id := range ids {
+		items[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(items, ",")
+}
+
+// flags は、name=bool をカンマ区切りで並べた値を読み込みます
+func (p *parser) flags(key string) map[string]bool {
+	items := p.list(key)
+	if len(items) == 0 {
+		return nil
+	}
+	flags := make(map[string]bool, len(items))
+	for _, item := range items {
+		name, v, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok {
+			flags[name] = true
+			continue
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			p.errorf("failed to parse feature flag '%s' in environment variable '%s' as bool: %+v", name, key, err)
+			continue
+		}
+		flags[name] = enabled
+	}
+	return flags
+}
+
+// durations は、key=duration をカンマ区切りで並べた値を読み込みます
+// 環境変数がなければdefを返し、あればdefを使わずに置き換えます
+func (p *parser) durations(key string, def map[string]time.Duration) map[string]time.Duration {
+	if _, ok := p.lookup(key); !ok {
+		return def
+	}
+	items := p.list(key)
+	durations := make(map[string]time.Duration, len(items))
+	for _, item := range items {
+		name, v, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok {
+			p.errorf("environment variable '%s' must be a list of key=duration: %s", key, item)
+			continue
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			p.errorf("failed to parse '%s' in environment variable '%s' as duration: %+v", name, key, err)
+			continue
+		}
+		durations[name] = d
+	}
+	return durations
+}
+
+// rateLimits は、route=rate:burst をカンマ区切りで並べた値を読み込みます
+// 環境変数がなければdefを返し、あればdefを使わずに置き換えます
+func (p *parser) rateLimits(key string, def map[string]RateLimit) map[string]RateLimit {
+	if _, ok := p.lookup(key); !ok {
+		return def
+	}
+	items := p.list(key)
+	limits := make(map[string]RateLimit, len(items))
+	for _, item := range items {
+		route, v, ok := strings.Cut(item, "=")
+		route = strings.TrimSpace(route)
+		rate, burst, ok2 := strings.Cut(strings.TrimSpace(v), ":")
+		if !ok || !ok2 {
+			p.errorf("environment variable '%s' must be a list of route=rate:burst: %s", key, item)
+			continue
+		}
+		r, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			p.errorf("failed to parse rate of '%s' in environment variable '%s' as float: %+v", route, key, err)
+			continue
+		}
+		b, err := strconv.Atoi(burst)
+		if err != nil {
+			p.errorf("failed to parse burst of '%s' in environment variable '%s' as int: %+v", route, key, err)
+			continue
+		}
+		limits[route] = RateLimit{Rate: r, Burst: b}
+	}
+	return limits
+}
+
+func (p *parser) int(key string, def int) int {
+	v, ok := p.lookup(key)
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		p.errorf("failed to parse environment variable '%s' as int: %+v", key, err)
+		return def
+	}
+	return n
+}
+
+func (p *parser) float(key string, def float64) float64 {
+	v, ok := p.lookup(key)
+	if !ok {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		p.errorf("failed to parse environment variable '%s' as float: %+v", key, err)
+		return def
+	}
+	return f
+}
+
+func (p *parser) bool(key string, def bool) bool {
+	v, ok := p.lookup(key)
+	if !ok {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		p.errorf("failed to parse environment variable '%s' as bool: %+v", key, err)
+		return def
+	}
+	return b
+}
+
+func (p *parser) duration(key string, def time.Duration) time.Duration {
+	v, ok := p.lookup(key)
+	if !ok {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		p.errorf("failed to parse environment variable '%s' as duration: %+v", key, err)
+		return def
+	}
+	return d
+}
+
+// fileMode は、8進数で書かれたパーミッションを読み込みます
+func (p *parser) fileMode(key string, def fs.FileMode) fs.FileMode {
+	v, ok := p.lookup(key)
+	if !ok {
+		return def
+	}
+	m, err := strconv.ParseUint(v, 8, 32)
+	if err != nil || m > 0o777 {
+		p.errorf("environment variable '%s' must be an octal permission: %s", key, v)
+		return def
+	}
+	return fs.FileMode(m)
+}
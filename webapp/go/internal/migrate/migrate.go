@@ -0,0 +1,160 @@
+// Package migrate は、アプリケーションに埋め込んだSQLファイルでMySQLのスキーマを更新します
+//
+// SQLファイルの名前は 0001_add_foo.sql のように、番号と説明をアンダースコアでつなぎます
+// 番号の順に、まだ適用していないものだけを適用し、適用したものをschema_migrationsテーブルに記録します
+// 複数のアプリケーションサーバが同時に起動しても二重に適用しないよう、適用のあいだはMySQLのロックを取ります
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	lockName    = "isupipe_schema_migrations"
+	lockTimeout = 60 * time.Second
+)
+
+type Migration struct {
+	Version int64
+	Name    string
+	// ;で終わる行を区切りとした、1つ以上の文
+	Statements []string
+}
+
+// Load は、fsysのdirにあるSQLファイルを番号の順に読み込みます
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	var migrations []Migration
+	versions := map[int64]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		number, _, ok := strings.Cut(name, "_")
+		version, err := strconv.ParseInt(number, 10, 64)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration file name must start with a positive number and an underscore: %s", entry.Name())
+		}
+		if other, ok := versions[version]; ok {
+			return nil, fmt.Errorf("migration version %d is duplicated: %s and %s", version, other, name)
+		}
+		versions[version] = name
+
+		b, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		statements := splitStatements(string(b))
+		if len(statements) == 0 {
+			return nil, fmt.Errorf("migration %s has no statements", name)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, Statements: statements})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// splitStatements は、;で終わる行で区切って文に分けます
+// 接続でmultiStatementsを有効にしないので、1文ずつ実行します
+// --で始まる行はコメントとして除きます
+func splitStatements(s string) []string {
+	var (
+		statements []string
+		current    []string
+	)
+	for _, line := range strings.Split(s, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current = append(current, line)
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(strings.Join(current, "\n")), ";"))
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		statements = append(statements, strings.TrimSpace(strings.Join(current, "\n")))
+	}
+	return statements
+}
+
+// Apply は、まだ適用していないmigrationsを番号の順に適用し、適用したものを返します
+// MySQLのDDLはトランザクションで巻き戻せないので、途中で失敗したときは、そのファイルの残りの文は適用されません
+// 失敗したファイルは記録しないので、直してから起動し直すと、そのファイルの最初から適用し直します
+// 何度適用しても壊れないよう、CREATE TABLE IF NOT EXISTS などで書いてください
+func Apply(ctx context.Context, db *sql.DB, migrations []Migration) ([]Migration, error) {
+	// ロックは接続に結びつくので、同じ接続で適用する
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(lockTimeout.Seconds())).Scan(&locked); err != nil {
+		return nil, fmt.Errorf("failed to get migration lock: %w", err)
+	}
+	if locked.Int64 != 1 {
+		return nil, errors.New("failed to get migration lock: timed out")
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), "SELECT RELEASE_LOCK(?)", lockName)
+
+	if _, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS `schema_migrations` ("+
+		"`version` BIGINT NOT NULL PRIMARY KEY, "+
+		"`name` VARCHAR(255) NOT NULL, "+
+		"`applied_at` BIGINT NOT NULL"+
+		") ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin"); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied := map[int64]struct{}{}
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+		}
+		applied[version] = struct{}{}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+
+	var done []Migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		for i, statement := range m.Statements {
+			if _, err := conn.ExecContext(ctx, statement); err != nil {
+				return done, fmt.Errorf("failed to apply migration %s (statement %d): %w", m.Name, i+1, err)
+			}
+		}
+		if _, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", m.Version, m.Name, time.Now().Unix()); err != nil {
+			return done, fmt.Errorf("failed to record migration %s: %w", m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
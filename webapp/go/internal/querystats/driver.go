@@ -0,0 +1,152 @@
+package querystats
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"time"
+)
+
+// WrapConnector は、クエリの実行時間をrに記録するdriver.Connectorを返します
+// QueryContextの時間は最初の結果が返るまでで、行を読み切るまでの時間は含みません
+// 元のドライバが実装しているインターフェース (ExecerContextなど) はそのまま委譲します
+func WrapConnector(connector driver.Connector, r *Recorder) driver.Connector {
+	if r == nil {
+		return connector
+	}
+	return &recordedConnector{Connector: connector, recorder: r}
+}
+
+type recordedConnector struct {
+	driver.Connector
+	recorder *Recorder
+}
+
+func (c *recordedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &recordedConn{Conn: conn, recorder: c.recorder}, nil
+}
+
+func (r *Recorder) record(query string, start time.Time, err error) {
+	// ErrSkipは、database/sqlがプリペアドステートメントで実行し直すための合図なので、記録しない
+	if errors.Is(err, driver.ErrSkip) {
+		return
+	}
+	r.Record(query, time.Since(start), err)
+}
+
+type recordedConn struct {
+	driver.Conn
+	recorder *Recorder
+}
+
+func (c *recordedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	c.recorder.record(query, start, err)
+	return result, err
+}
+
+func (c *recordedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	c.recorder.record(query, start, err)
+	return rows, err
+}
+
+func (c *recordedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &recordedStmt{Stmt: stmt, recorder: c.recorder, query: query}, nil
+}
+
+func (c *recordedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *recordedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *recordedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *recordedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *recordedConn) CheckNamedValue(v *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+type recordedStmt struct {
+	driver.Stmt
+	recorder *Recorder
+	query    string
+}
+
+func (s *recordedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := s.Stmt.(driver.StmtExecContext)
+	if !ok {
+		return nil, errors.New("querystats: driver statement does not implement StmtExecContext")
+	}
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, args)
+	s.recorder.record(s.query, start, err)
+	return result, err
+}
+
+func (s *recordedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		return nil, errors.New("querystats: driver statement does not implement StmtQueryContext")
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, args)
+	s.recorder.record(s.query, start, err)
+	return rows, err
+}
+
+func (s *recordedStmt) CheckNamedValue(v *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
@@ -0,0 +1,217 @@
+// Package querystats は、SQLのクエリを正規化した文ごとに、実行回数と実行時間を集計します
+// DBのホストでpt-query-digestを実行しなくても、アプリケーションサーバからどのクエリが重いかを確かめられます
+// Recorderがnilのときは何も記録しないので、無効化したときの呼び出し側の分岐は不要です
+package querystats
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 正規化した結果を覚えておくクエリの数の上限
+// sqlx.Inで組み立てたクエリはプレースホルダの数ごとに別の文字列になるので、上限を超えたら都度正規化する
+const maxNormalizedCacheSize = 10000
+
+// 並べ替えのキー
+const (
+	SortByTotal = "total"
+	SortByCount = "count"
+)
+
+type stat struct {
+	count  int64
+	errors int64
+	slow   int64
+	total  time.Duration
+	max    time.Duration
+}
+
+// Recorder は、クエリごとの実行時間をメモリ上に集計します
+type Recorder struct {
+	slowThreshold time.Duration
+	onSlow        func(query string, elapsed time.Duration)
+
+	mu         sync.Mutex
+	stats      map[string]*stat
+	normalized map[string]string
+}
+
+// NewRecorder は、Recorderを作ります
+// slowThresholdが正のとき、それより遅いクエリは正規化した文と実行時間でonSlowを呼び出します
+func NewRecorder(slowThreshold time.Duration, onSlow func(query string, elapsed time.Duration)) *Recorder {
+	return &Recorder{
+		slowThreshold: slowThreshold,
+		onSlow:        onSlow,
+		stats:         make(map[string]*stat),
+		normalized:    make(map[string]string),
+	}
+}
+
+// Record は、queryの実行にかかった時間を記録します
+func (r *Recorder) Record(query string, elapsed time.Duration, err error) {
+	if r == nil {
+		return
+	}
+	slow := r.slowThreshold > 0 && elapsed >= r.slowThreshold
+
+	r.mu.Lock()
+	normalized, ok := r.normalized[query]
+	if !ok {
+		normalized = Normalize(query)
+		if len(r.normalized) < maxNormalizedCacheSize {
+			r.normalized[query] = normalized
+		}
+	}
+	s, ok := r.stats[normalized]
+	if !ok {
+		s = &stat{}
+		r.stats[normalized] = s
+	}
+	s.count++
+	s.total += elapsed
+	if elapsed > s.max {
+		s.max = elapsed
+	}
+	if err != nil {
+		s.errors++
+	}
+	if slow {
+		s.slow++
+	}
+	r.mu.Unlock()
+
+	if slow && r.onSlow != nil {
+		r.onSlow(normalized, elapsed)
+	}
+}
+
+// Reset は、集計を捨てます
+func (r *Recorder) Reset() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats = make(map[string]*stat)
+}
+
+type QueryStat struct {
+	Query   string  `json:"query"`
+	Count   int64   `json:"count"`
+	Errors  int64   `json:"errors"`
+	Slow    int64   `json:"slow"`
+	TotalMs float64 `json:"total_ms"`
+	AvgMs   float64 `json:"avg_ms"`
+	MaxMs   float64 `json:"max_ms"`
+}
+
+// Top は、byの大きい順に最大n件の集計を返します。nが0以下のときはすべて返します
+// byはSortByTotalまたはSortByCountで、それ以外はSortByTotalとして扱います
+func (r *Recorder) Top(n int, by string) []QueryStat {
+	if r == nil {
+		return []QueryStat{}
+	}
+	r.mu.Lock()
+	result := make([]QueryStat, 0, len(r.stats))
+	for query, s := range r.stats {
+		totalMs := float64(s.total) / float64(time.Millisecond)
+		result = append(result, QueryStat{
+			Query:   query,
+			Count:   s.count,
+			Errors:  s.errors,
+			Slow:    s.slow,
+			TotalMs: totalMs,
+			AvgMs:   totalMs / float64(s.count),
+			MaxMs:   float64(s.max) / float64(time.Millisecond),
+		})
+	}
+	r.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if by == SortByCount && result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		if result[i].TotalMs != result[j].TotalMs {
+			return result[i].TotalMs > result[j].TotalMs
+		}
+		return result[i].Query < result[j].Query
+	})
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+var (
+	// IN (?, ?, ?) や VALUES (?, ?), (?, ?) のように、プレースホルダの数だけが違うクエリをまとめる
+	placeholderListPattern = regexp.MustCompile(`\(\?(?:, \?)*\)`)
+	repeatedListPattern    = regexp.MustCompile(`\(\.\.\.\)(?:, \(\.\.\.\))+`)
+)
+
+// Normalize は、リテラルをプレースホルダに置き換え、空白をまとめたクエリを返します
+// 値だけが違うクエリを同じ文として集計するために使います
+func Normalize(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	space := false
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case isSpace(ch):
+			space = true
+			continue
+		case ch == '\'' || ch == '"':
+			// 文字列リテラルは閉じる引用符まで読み飛ばす。引用符を2つ重ねたものは閉じる引用符ではない
+			for i++; i < len(query); i++ {
+				if query[i] == '\\' {
+					i++
+				} else if query[i] == ch {
+					if i+1 < len(query) && query[i+1] == ch {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			ch = '?'
+		case isDigit(ch) && (i == 0 || !isIdentifier(query[i-1])):
+			for i+1 < len(query) && (isDigit(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			ch = '?'
+		}
+		if space {
+			if b.Len() > 0 && ch != ',' && ch != ')' {
+				b.WriteByte(' ')
+			}
+			space = false
+		}
+		b.WriteByte(ch)
+		if ch == ',' {
+			space = true
+		}
+		if ch == '(' {
+			// 括弧の直後の空白は取り除く
+			for i+1 < len(query) && isSpace(query[i+1]) {
+				i++
+			}
+		}
+	}
+	normalized := placeholderListPattern.ReplaceAllString(b.String(), "(...)")
+	return repeatedListPattern.ReplaceAllString(normalized, "(...)")
+}
+
+func isDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
+func isIdentifier(ch byte) bool {
+	return isDigit(ch) || ch == '_' || ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z')
+}
@@ -0,0 +1,173 @@
+// Package spamscore は、ライブコメントのスパムらしさを0から1の値で見積もります
+//
+// スパムらしさは、次の3つの指標の重み付きの和です
+//   - 同じ内容の繰り返し: 直近の投稿のうち、ほぼ同じ内容の投稿の割合
+//   - 投稿の頻度: 直近の一定時間内の投稿の数
+//   - NGワードへの近さ: 空白や記号を挟むなどして、NGワードとの照合をすり抜けようとしているか
+//
+// DBやHTTPには依存しないので、呼び出し側で直近の投稿とNGワードを集めて渡してください
+package spamscore
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	duplicateWeight = 0.4
+	frequencyWeight = 0.3
+	ngWordWeight    = 0.3
+
+	// 文字のbigramの一致度がこれ以上の投稿を、同じ内容とみなす
+	duplicateSimilarity = 0.8
+	// この秒数内の投稿を、頻度の計算に使う
+	FrequencyWindowSeconds = 60
+	// FrequencyWindowSecondsの間にこの数だけ投稿していれば、頻度の指標を1とする
+	frequencyLimit = 10
+
+	// 呼び出し側が集める直近の投稿の数
+	RecentLimit = 20
+)
+
+// Comment は、同じユーザが同じ配信に投稿した過去のライブコメントです
+type Comment struct {
+	Comment   string
+	CreatedAt int64
+}
+
+type Input struct {
+	Comment string
+	// 投稿した日時 (UNIX時間)
+	Now int64
+	// 同じユーザが同じ配信に直近に投稿したライブコメント。順序は問わない
+	Recent []Comment
+	// 配信のNGワード
+	NGWords []string
+}
+
+// Result は、スパムらしさと、その内訳です。いずれも0から1の値です
+type Result struct {
+	Score     float64
+	Duplicate float64
+	Frequency float64
+	NGWord    float64
+}
+
+// Compute は、in.Commentのスパムらしさを計算します
+func Compute(in Input) Result {
+	text := normalize(in.Comment)
+	r := Result{
+		Duplicate: duplicateRatio(text, in.Recent),
+		Frequency: frequency(in.Now, in.Recent),
+		NGWord:    ngWordProximity(text, in.NGWords),
+	}
+	r.Score = duplicateWeight*r.Duplicate + frequencyWeight*r.Frequency + ngWordWeight*r.NGWord
+	return r
+}
+
+// duplicateRatio は、recentのうちtextとほぼ同じ内容の投稿の割合を返します
+func duplicateRatio(text string, recent []Comment) float64 {
+	if len(recent) == 0 {
+		return 0
+	}
+	grams := bigrams(text)
+	n := 0
+	for _, c := range recent {
+		other := normalize(c.Comment)
+		if other == text || dice(grams, bigrams(other)) >= duplicateSimilarity {
+			n++
+		}
+	}
+	return float64(n) / float64(len(recent))
+}
+
+// frequency は、nowまでのFrequencyWindowSecondsの間の投稿の多さを返します
+// これから投稿するものも1件と数えます
+func frequency(now int64, recent []Comment) float64 {
+	n := 1
+	for _, c := range recent {
+		if c.CreatedAt > now-FrequencyWindowSeconds && c.CreatedAt <= now {
+			n++
+		}
+	}
+	if n >= frequencyLimit {
+		return 1
+	}
+	// 1件だけなら0になるようにする
+	return float64(n-1) / float64(frequencyLimit-1)
+}
+
+// ngWordProximity は、textがいずれかのNGワードにどれだけ近いかを返します
+// 正規化した後にNGワードを含めば1、そうでなければNGワードのbigramのうちtextに含まれるものの割合の最大値です
+func ngWordProximity(text string, ngWords []string) float64 {
+	grams := bigrams(text)
+	var max float64
+	for _, word := range ngWords {
+		word = normalize(word)
+		if word == "" {
+			continue
+		}
+		if strings.Contains(text, word) {
+			return 1
+		}
+		wordGrams := bigrams(word)
+		if len(wordGrams) == 0 {
+			continue
+		}
+		hit := 0
+		for g := range wordGrams {
+			if _, ok := grams[g]; ok {
+				hit++
+			}
+		}
+		if v := float64(hit) / float64(len(wordGrams)); v > max {
+			max = v
+		}
+	}
+	return max
+}
+
+// normalize は、照合のすり抜けに使われやすい違いをなくします
+// 英字を小文字に、全角の英数字・記号を半角にそろえ、空白・記号・句読点を取り除きます
+func normalize(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if r >= '！' && r <= '～' {
+			r -= '！' - '!'
+		}
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
+
+type bigram [2]rune
+
+// bigrams は、sの文字のbigramの集合を返します。1文字の場合は、その文字だけのbigramを返します
+func bigrams(s string) map[bigram]struct{} {
+	runes := []rune(s)
+	grams := make(map[bigram]struct{}, len(runes))
+	if len(runes) == 1 {
+		grams[bigram{runes[0]}] = struct{}{}
+	}
+	for i := 1; i < len(runes); i++ {
+		grams[bigram{runes[i-1], runes[i]}] = struct{}{}
+	}
+	return grams
+}
+
+// dice は、2つのbigramの集合のDice係数を返します
+func dice(a, b map[bigram]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	n := 0
+	for g := range a {
+		if _, ok := b[g]; ok {
+			n++
+		}
+	}
+	return 2 * float64(n) / float64(len(a)+len(b))
+}
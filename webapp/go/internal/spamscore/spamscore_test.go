@@ -0,0 +1,94 @@
+package spamscore
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fixtureCase は、testdataのコメント集の1件です
+type fixtureCase struct {
+	Name    string   `json:"name"`
+	NGWords []string `json:"ng_words"`
+	History []struct {
+		Comment    string `json:"comment"`
+		SecondsAgo int64  `json:"seconds_ago"`
+	} `json:"history"`
+	Comment string `json:"comment"`
+	// 省略したときは、それぞれ0と1
+	MinScore *float64 `json:"min_score"`
+	MaxScore *float64 `json:"max_score"`
+}
+
+func loadFixture(t *testing.T, name string) []fixtureCase {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []fixtureCase
+	if err := json.Unmarshal(b, &cases); err != nil {
+		t.Fatal(err)
+	}
+	return cases
+}
+
+func TestComputeFixtures(t *testing.T) {
+	const now = 1700000000
+	for _, fixture := range []string{"normal.json", "spam.json"} {
+		for _, tc := range loadFixture(t, fixture) {
+			t.Run(fixture+"/"+tc.Name, func(t *testing.T) {
+				in := Input{Comment: tc.Comment, Now: now, NGWords: tc.NGWords}
+				for _, h := range tc.History {
+					in.Recent = append(in.Recent, Comment{Comment: h.Comment, CreatedAt: now - h.SecondsAgo})
+				}
+				r := Compute(in)
+				if tc.MinScore != nil && r.Score < *tc.MinScore {
+					t.Errorf("score = %.3f (%+v), want >= %.3f", r.Score, r, *tc.MinScore)
+				}
+				if tc.MaxScore != nil && r.Score > *tc.MaxScore {
+					t.Errorf("score = %.3f (%+v), want <= %.3f", r.Score, r, *tc.MaxScore)
+				}
+				if r.Score < 0 || r.Score > 1 {
+					t.Errorf("score = %.3f, want in [0, 1]", r.Score)
+				}
+			})
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"Hello, World!", "helloworld"},
+		{"ＦＲＥＥ　ＭＯＮＥＹ！", "freemoney"},
+		{"ス パ ム", "スパム"},
+		{"副.業", "副業"},
+		{"", ""},
+	} {
+		if got := normalize(tc.in); got != tc.want {
+			t.Errorf("normalize(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestFrequency(t *testing.T) {
+	const now = 1000
+	if got := frequency(now, nil); got != 0 {
+		t.Errorf("frequency without history = %v, want 0", got)
+	}
+	var recent []Comment
+	for i := 0; i < frequencyLimit; i++ {
+		recent = append(recent, Comment{CreatedAt: now - int64(i)})
+	}
+	if got := frequency(now, recent); got != 1 {
+		t.Errorf("frequency with %d recent comments = %v, want 1", len(recent), got)
+	}
+	// 時間枠の外の投稿は数えない
+	old := []Comment{{CreatedAt: now - FrequencyWindowSeconds}, {CreatedAt: now - 3600}}
+	if got := frequency(now, old); got != 0 {
+		t.Errorf("frequency with old comments = %v, want 0", got)
+	}
+}
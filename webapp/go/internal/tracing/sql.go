@@ -0,0 +1,173 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+)
+
+// クエリの文字列をスパンに載せるときの上限
+const maxStatementLength = 1024
+
+// WrapConnector は、クエリの実行をスパンとして記録するdriver.Connectorを返します
+// リクエストのスパンがctxに載っているときのみ記録し、バックグラウンド処理のクエリは記録しません
+// 元のドライバが実装しているインターフェース (ExecerContextなど) はそのまま委譲します
+func WrapConnector(connector driver.Connector, tracer *Tracer, system string) driver.Connector {
+	if tracer == nil {
+		return connector
+	}
+	return &tracedConnector{Connector: connector, tracer: tracer, system: system}
+}
+
+type tracedConnector struct {
+	driver.Connector
+	tracer *Tracer
+	system string
+}
+
+func (c *tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: conn, tracer: c.tracer, system: c.system}, nil
+}
+
+func startQuerySpan(ctx context.Context, tracer *Tracer, system, op, query string) *Span {
+	if !tracer.Enabled(ctx) {
+		return nil
+	}
+	if len(query) > maxStatementLength {
+		query = query[:maxStatementLength]
+	}
+	_, span := tracer.Start(ctx, "sql."+op, SpanKindClient,
+		String("db.system", system),
+		String("db.statement", query),
+	)
+	return span
+}
+
+func endQuerySpan(span *Span, err error) {
+	// ErrSkipは、database/sqlがプリペアドステートメントで実行し直すための合図なので、スパンは残さない
+	if errors.Is(err, driver.ErrSkip) {
+		span.discard()
+		return
+	}
+	span.RecordError(err)
+	span.End()
+}
+
+type tracedConn struct {
+	driver.Conn
+	tracer *Tracer
+	system string
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	span := startQuerySpan(ctx, c.tracer, c.system, "exec", query)
+	result, err := execer.ExecContext(ctx, query, args)
+	endQuerySpan(span, err)
+	return result, err
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	span := startQuerySpan(ctx, c.tracer, c.system, "query", query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	endQuerySpan(span, err)
+	return rows, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tracedStmt{Stmt: stmt, tracer: c.tracer, system: c.system, query: query}, nil
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *tracedConn) CheckNamedValue(v *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+type tracedStmt struct {
+	driver.Stmt
+	tracer *Tracer
+	system string
+	query  string
+}
+
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := s.Stmt.(driver.StmtExecContext)
+	if !ok {
+		return nil, errors.New("tracing: driver statement does not implement StmtExecContext")
+	}
+	span := startQuerySpan(ctx, s.tracer, s.system, "exec", s.query)
+	result, err := execer.ExecContext(ctx, args)
+	endQuerySpan(span, err)
+	return result, err
+}
+
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		return nil, errors.New("tracing: driver statement does not implement StmtQueryContext")
+	}
+	span := startQuerySpan(ctx, s.tracer, s.system, "query", s.query)
+	rows, err := queryer.QueryContext(ctx, args)
+	endQuerySpan(span, err)
+	return rows, err
+}
+
+func (s *tracedStmt) CheckNamedValue(v *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
@@ -0,0 +1,208 @@
+// Package tracing は、リクエストの処理やSQLの実行をスパンとして記録し、OTLPで送信するための最小限のトレーサを提供します
+// スパンの親子関係はcontext.Contextで引き継ぎます
+// Tracerがnilのときは何も記録しないので、無効化したときの呼び出し側の分岐は不要です
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// SpanKind は、OTLPのSpan.SpanKindに対応します
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// Attribute は、スパンに付ける属性です
+// 値はstring, int, int64, bool, float64のいずれかです
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Attribute      { return Attribute{Key: key, Value: value} }
+func Int(key string, value int) Attribute     { return Attribute{Key: key, Value: int64(value)} }
+func Int64(key string, value int64) Attribute { return Attribute{Key: key, Value: value} }
+func Bool(key string, value bool) Attribute   { return Attribute{Key: key, Value: value} }
+
+// Span は、記録中の1つの処理です
+// nilのSpanに対するメソッド呼び出しは何もしません
+type Span struct {
+	tracer   *Tracer
+	traceID  TraceID
+	spanID   SpanID
+	parentID SpanID
+	name     string
+	kind     SpanKind
+	start    time.Time
+
+	mu    sync.Mutex
+	end   time.Time
+	attrs []Attribute
+	err   error
+	ended bool
+}
+
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attrs...)
+	s.mu.Unlock()
+}
+
+// RecordError は、スパンをエラーとして記録します
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// End は、スパンを終了してエクスポータに渡します
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.exporter.export(s)
+}
+
+// discard は、スパンを送信せずに終了します
+func (s *Span) discard() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.ended = true
+	s.mu.Unlock()
+}
+
+type spanContextKey struct{}
+
+type remoteParent struct {
+	traceID TraceID
+	spanID  SpanID
+}
+
+type remoteParentKey struct{}
+
+func spanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanContextKey{}).(*Span)
+	return s
+}
+
+// ContextWithTraceParent は、traceparentヘッダで渡された親スパンをctxに載せます
+// 不正な値は無視します
+func ContextWithTraceParent(ctx context.Context, traceParent string) context.Context {
+	parts := strings.Split(traceParent, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ctx
+	}
+	var parent remoteParent
+	if _, err := hex.Decode(parent.traceID[:], []byte(parts[1])); err != nil {
+		return ctx
+	}
+	if _, err := hex.Decode(parent.spanID[:], []byte(parts[2])); err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteParentKey{}, parent)
+}
+
+// Tracer は、スパンを作ってエクスポータに渡します
+type Tracer struct {
+	exporter *Exporter
+	// 親のないスパンを記録する割合 (0〜1)
+	sampleRatio float64
+}
+
+func NewTracer(exporter *Exporter, sampleRatio float64) *Tracer {
+	return &Tracer{exporter: exporter, sampleRatio: sampleRatio}
+}
+
+// Start は、ctxに載っているスパンを親として新しいスパンを始めます
+// 記録しないときはnilのSpanを返します
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+		attrs:  attrs,
+	}
+	if parent := spanFromContext(ctx); parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else if parent, ok := ctx.Value(remoteParentKey{}).(remoteParent); ok {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		// 親のないスパンのみ間引き、トレースの途中のスパンは親に従う
+		if !t.sampled() {
+			return ctx, nil
+		}
+		span.traceID = newTraceID()
+	}
+	span.spanID = newSpanID()
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// Enabled は、ctxのトレースを記録しているかを返します
+func (t *Tracer) Enabled(ctx context.Context) bool {
+	return t != nil && spanFromContext(ctx) != nil
+}
+
+func (t *Tracer) sampled() bool {
+	if t.sampleRatio >= 1 {
+		return true
+	}
+	if t.sampleRatio <= 0 {
+		return false
+	}
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return float64(binary.BigEndian.Uint64(b[:])>>11)/(1<<53) < t.sampleRatio
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	_, _ = rand.Read(id[:])
+	return id
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+type jobKind string
+
+const (
+	jobAddSubdomain            jobKind = "subdomain.add"
+	jobDeleteSubdomain         jobKind = "subdomain.delete"
+	jobNotifyLivestreamStarted jobKind = "notification.livestream_started"
+	jobNotifyLivecommentPinned jobKind = "notification.livecomment_pinned"
+)
+
+const (
+	// 待っているジョブを探す間隔
+	jobPollInterval = time.Second
+	// 一度に取り出すジョブの数
+	jobClaimBatch = 16
+	// 取り出したジョブを他から取り出されないようにしておく時間
+	// 実行中にプロセスが落ちても、過ぎれば他のworkerが取り出してやり直す
+	jobLease = 30 * time.Second
+	// 失敗したジョブをやり直すまでの時間。失敗するたびに倍にする
+	jobRetryBase = time.Second
+	jobRetryMax  = 5 * time.Minute
+	// これだけ失敗したジョブは諦め、調べられるよう行を残す
+	jobMaxAttempts = 10
+)
+
+type JobModel struct {
+	ID       int64  `db:"id"`
+	Kind     string `db:"kind"`
+	Payload  []byte `db:"payload"`
+	Attempts int64  `db:"attempts"`
+	// UNIX時間 (ミリ秒)
+	RunAt       int64  `db:"run_at"`
+	LockedUntil int64  `db:"locked_until"`
+	LastError   string `db:"last_error"`
+	FailedAt    *int64 `db:"failed_at"`
+	CreatedAt   int64  `db:"created_at"`
+}
+
+type jobHandler func(ctx context.Context, payload []byte) error
+
+// jobQueue は、MySQLのjobsテーブルに積んだ副作用 (DNSのレコードや通知の書き込み) を実行します
+// ジョブは取り出して実行し、成功したら消すので、プロセスが途中で落ちても失われず、少なくとも1回は実行されます
+// 2回以上実行されることがあるので、ハンドラは何度実行しても同じ結果になるようにしてください
+type jobQueue struct {
+	handlers map[jobKind]jobHandler
+	// 積んだことをこのプロセスのrunに知らせ、次に探すのを待たずに実行させる
+	kick chan struct{}
+}
+
+var jobs = &jobQueue{
+	handlers: map[jobKind]jobHandler{},
+	kick:     make(chan struct{}, 1),
+}
+
+// handle は、kindのジョブのハンドラを登録します。runより前に呼び出してください
+func (q *jobQueue) handle(kind jobKind, handler jobHandler) {
+	q.handlers[kind] = handler
+}
+
+// enqueue は、payloadをJSONにしたkindのジョブを積みます
+// トランザクションの中で積めば、書き込みと一緒にコミット・ロールバックされます
+// このプロセスでrunしていれば、コミットを待ってから実行されるよう、kickはコミットした後に呼び出してください
+func (q *jobQueue) enqueue(ctx context.Context, db sqlx.ExecerContext, kind jobKind, payload any) error {
+	return q.enqueueAt(ctx, db, kind, payload, 0, time.Now(), "")
+}
+
+func (q *jobQueue) enqueueAt(ctx context.Context, db sqlx.ExecerContext, kind jobKind, payload any, attempts int64, runAt time.Time, lastError string) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO jobs (kind, payload, attempts, run_at, locked_until, last_error, created_at) VALUES (?, ?, ?, ?, 0, ?, ?)",
+		string(kind), b, attempts, runAt.UnixMilli(), lastError, time.Now().Unix()); err != nil {
+		return fmt.Errorf("failed to enqueue %s job: %w", kind, err)
+	}
+	return nil
+}
+
+// runNowOrEnqueue は、kindのジョブをすぐに実行し、失敗したときだけジョブとして積んで後でやり直させます
+// DNSのレコードのように、レスポンスを返すまでに済ませたいが、失敗しても諦めたくない副作用に使います
+func (q *jobQueue) runNowOrEnqueue(ctx context.Context, db sqlx.ExecerContext, kind jobKind, payload any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	runErr := q.handlers[kind](ctx, b)
+	if runErr == nil {
+		return nil
+	}
+	return q.enqueueAt(ctx, db, kind, payload, 1, time.Now().Add(jobRetryDelay(1)), runErr.Error())
+}
+
+// notify は、ジョブを積んだことをこのプロセスのrunに知らせます
+func (q *jobQueue) notify() {
+	select {
+	case q.kick <- struct{}{}:
+	default:
+	}
+}
+
+// run は、積まれたジョブを取り出して実行し続けます
+// 複数のプロセスで動かしても、1つのジョブを同時に実行するのは1つのプロセスのみです
+func (q *jobQueue) run(db *sqlx.DB, logger echo.Logger) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-q.kick:
+		}
+		for {
+			claimed, err := q.claim(context.Background())
+			if err != nil {
+				logger.Warnf("failed to claim jobs: %v", err)
+				break
+			}
+			for _, job := range claimed {
+				q.execute(context.Background(), db, job, logger)
+			}
+			if len(claimed) < jobClaimBatch {
+				break
+			}
+		}
+	}
+}
+
+// claim は、実行する時刻を過ぎたジョブを取り出し、jobLeaseの間は他から取り出されないようにします
+// 他のプロセスが取り出している行は飛ばすので、複数のworkerで待ち合わせずに分け合えます
+func (q *jobQueue) claim(ctx context.Context) ([]JobModel, error) {
+	var claimed []JobModel
+	err := withTx(ctx, func(tx *sqlx.Tx) error {
+		now := time.Now().UnixMilli()
+		if err := tx.SelectContext(ctx, &claimed, "SELECT * FROM jobs WHERE failed_at IS NULL AND run_at <= ? AND locked_until <= ? ORDER BY run_at LIMIT ? FOR UPDATE SKIP LOCKED", now, now, jobClaimBatch); err != nil {
+			return err
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+		ids := make([]int64, len(claimed))
+		for i, job := range claimed {
+			ids[i] = job.ID
+		}
+		query, params, err := sqlx.In("UPDATE jobs SET locked_until = ?, attempts = attempts + 1 WHERE id IN (?)", now+jobLease.Milliseconds(), ids)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, query, params...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range claimed {
+		claimed[i].Attempts++
+	}
+	return claimed, nil
+}
+
+// execute は、jobを実行し、成功すれば消し、失敗すれば時間を空けてやり直させます
+func (q *jobQueue) execute(ctx context.Context, db *sqlx.DB, job JobModel, logger echo.Logger) {
+	handler, ok := q.handlers[jobKind(job.Kind)]
+	var err error
+	if !ok {
+		err = fmt.Errorf("unknown job kind: %s", job.Kind)
+	} else {
+		runCtx, cancel := context.WithTimeout(ctx, jobLease)
+		err = handler(runCtx, job.Payload)
+		cancel()
+	}
+
+	if err == nil {
+		if _, err := db.ExecContext(ctx, "DELETE FROM jobs WHERE id = ?", job.ID); err != nil {
+			// 消せなければ、期限が過ぎてからもう一度実行される
+			logger.Warnf("failed to ack job %d: %v", job.ID, err)
+		}
+		return
+	}
+
+	if job.Attempts >= jobMaxAttempts {
+		logger.Errorf("giving up %s job %d after %d attempts: %v", job.Kind, job.ID, job.Attempts, err)
+		if _, err := db.ExecContext(ctx, "UPDATE jobs SET locked_until = 0, last_error = ?, failed_at = ? WHERE id = ?", err.Error(), time.Now().Unix(), job.ID); err != nil {
+			logger.Warnf("failed to mark job %d as failed: %v", job.ID, err)
+		}
+		return
+	}
+	logger.Warnf("failed to run %s job %d (attempt %d): %v", job.Kind, job.ID, job.Attempts, err)
+	runAt := time.Now().Add(jobRetryDelay(job.Attempts)).UnixMilli()
+	if _, err := db.ExecContext(ctx, "UPDATE jobs SET run_at = ?, locked_until = 0, last_error = ? WHERE id = ?", runAt, err.Error(), job.ID); err != nil {
+		logger.Warnf("failed to reschedule job %d: %v", job.ID, err)
+	}
+}
+
+// clear は、積まれているジョブをすべて消します。初期化のときに呼び出します
+func (q *jobQueue) clear(ctx context.Context) error {
+	_, err := dbConn.ExecContext(ctx, "TRUNCATE TABLE jobs")
+	return err
+}
+
+// jobRetryDelay は、attempts回失敗したジョブをやり直すまでの時間を返します
+func jobRetryDelay(attempts int64) time.Duration {
+	delay := jobRetryBase
+	for i := int64(1); i < attempts && delay < jobRetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, jobRetryMax)
+}
+
+// registerJobHandlers は、各機能のジョブのハンドラを登録します
+// subdomainsなどの初期化を終えてから呼び出してください
+func registerJobHandlers(q *jobQueue) {
+	q.handle(jobAddSubdomain, func(ctx context.Context, payload []byte) error {
+		var p subdomainJobPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		return subdomains.addRecord(ctx, p.Name)
+	})
+	q.handle(jobDeleteSubdomain, func(ctx context.Context, payload []byte) error {
+		var p subdomainJobPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		return subdomains.deleteRecord(ctx, p.Name)
+	})
+	q.handle(jobNotifyLivestreamStarted, func(ctx context.Context, payload []byte) error {
+		var p notificationJobPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		return notifyLivestreamStarted(ctx, p.UserID, p.LivestreamID, time.Unix(p.At, 0))
+	})
+	q.handle(jobNotifyLivecommentPinned, func(ctx context.Context, payload []byte) error {
+		var p notificationJobPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		return notifyLivecommentPinned(ctx, p.UserID, p.LivestreamID, p.LivecommentID, time.Unix(p.At, 0))
+	})
+}
+
+type subdomainJobPayload struct {
+	Name string `json:"name"`
+}
+
+type notificationJobPayload struct {
+	UserID        int64 `json:"user_id"`
+	LivestreamID  int64 `json:"livestream_id"`
+	LivecommentID int64 `json:"livecomment_id,omitempty"`
+	At            int64 `json:"at"`
+}
@@ -0,0 +1,11 @@
+//go:build !fastjson
+
+package main
+
+import "github.com/labstack/echo/v4"
+
+// newJSONSerializer は、レスポンスのJSONのエンコーダを返します
+// fastjsonタグを付けてビルドすると、一覧のレスポンスを手書きのエンコーダで書き出します (jsonserializer_fast.go)
+func newJSONSerializer() echo.JSONSerializer {
+	return echo.DefaultJSONSerializer{}
+}
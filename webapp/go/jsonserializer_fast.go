@@ -0,0 +1,273 @@
+//go:build fastjson
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newJSONSerializer は、一覧のレスポンスに多く含まれる型を、encoding/jsonのリフレクションを使わずに書き出すエンコーダを返します
+// 出力はencoding/jsonと同じになるようにしているので、タグの有無で性能を比べられます
+// 対応していない型はencoding/jsonで書き出します
+func newJSONSerializer() echo.JSONSerializer {
+	return fastJSONSerializer{}
+}
+
+type fastJSONSerializer struct {
+	echo.DefaultJSONSerializer
+}
+
+var fastJSONBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 16<<10)
+		return &b
+	},
+}
+
+func (s fastJSONSerializer) Serialize(c echo.Context, i interface{}, indent string) error {
+	appendJSON := fastJSONAppender(i)
+	if appendJSON == nil {
+		return s.DefaultJSONSerializer.Serialize(c, i, indent)
+	}
+
+	bp := fastJSONBufferPool.Get().(*[]byte)
+	defer fastJSONBufferPool.Put(bp)
+	b := appendJSON((*bp)[:0])
+	*bp = b
+
+	// json.Encoderと同じく、インデントは詰めて書き出した後に付け、最後に改行を付ける
+	if indent != "" {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, b, "", indent); err != nil {
+			return err
+		}
+		indented.WriteByte('\n')
+		_, err := c.Response().Write(indented.Bytes())
+		return err
+	}
+	_, err := c.Response().Write(append(b, '\n'))
+	return err
+}
+
+// fastJSONAppender は、iを書き出す関数を返します。対応していない型ならnilを返します
+func fastJSONAppender(i interface{}) func([]byte) []byte {
+	switch v := i.(type) {
+	case User:
+		return v.appendJSON
+	case *User:
+		if v != nil {
+			return v.appendJSON
+		}
+	case Livestream:
+		return v.appendJSON
+	case *Livestream:
+		if v != nil {
+			return v.appendJSON
+		}
+	case Livecomment:
+		return v.appendJSON
+	case *Livecomment:
+		if v != nil {
+			return v.appendJSON
+		}
+	case Reaction:
+		return v.appendJSON
+	case *Reaction:
+		if v != nil {
+			return v.appendJSON
+		}
+	case []User:
+		return func(b []byte) []byte { return appendJSONArray(b, v) }
+	case []Livestream:
+		return func(b []byte) []byte { return appendJSONArray(b, v) }
+	case []Livecomment:
+		return func(b []byte) []byte { return appendJSONArray(b, v) }
+	case []Reaction:
+		return func(b []byte) []byte { return appendJSONArray(b, v) }
+	}
+	return nil
+}
+
+// 他の型のフィールドとしてencoding/jsonで書き出すときも、手書きのエンコーダを使う
+
+func (u User) MarshalJSON() ([]byte, error)        { return u.appendJSON(nil), nil }
+func (l Livestream) MarshalJSON() ([]byte, error)  { return l.appendJSON(nil), nil }
+func (l Livecomment) MarshalJSON() ([]byte, error) { return l.appendJSON(nil), nil }
+func (r Reaction) MarshalJSON() ([]byte, error)    { return r.appendJSON(nil), nil }
+
+func (u User) appendJSON(b []byte) []byte {
+	b = append(b, `{"id":`...)
+	b = strconv.AppendInt(b, u.ID, 10)
+	b = append(b, `,"name":`...)
+	b = appendJSONString(b, u.Name)
+	if u.DisplayName != "" {
+		b = append(b, `,"display_name":`...)
+		b = appendJSONString(b, u.DisplayName)
+	}
+	if u.Description != "" {
+		b = append(b, `,"description":`...)
+		b = appendJSONString(b, u.Description)
+	}
+	// 構造体にはomitemptyが効かないので、テーマは常に書き出す
+	b = append(b, `,"theme":{"id":`...)
+	b = strconv.AppendInt(b, u.Theme.ID, 10)
+	b = append(b, `,"dark_mode":`...)
+	b = strconv.AppendBool(b, u.Theme.DarkMode)
+	b = append(b, '}')
+	if u.IconHash != "" {
+		b = append(b, `,"icon_hash":`...)
+		b = appendJSONString(b, u.IconHash)
+	}
+	return append(b, '}')
+}
+
+func (t Tag) appendJSON(b []byte) []byte {
+	b = append(b, `{"id":`...)
+	b = strconv.AppendInt(b, t.ID, 10)
+	b = append(b, `,"name":`...)
+	b = appendJSONString(b, t.Name)
+	return append(b, '}')
+}
+
+func (l Livestream) appendJSON(b []byte) []byte {
+	b = append(b, `{"id":`...)
+	b = strconv.AppendInt(b, l.ID, 10)
+	b = append(b, `,"owner":`...)
+	b = l.Owner.appendJSON(b)
+	b = append(b, `,"title":`...)
+	b = appendJSONString(b, l.Title)
+	b = append(b, `,"description":`...)
+	b = appendJSONString(b, l.Description)
+	b = append(b, `,"playlist_url":`...)
+	b = appendJSONString(b, l.PlaylistUrl)
+	b = append(b, `,"thumbnail_url":`...)
+	b = appendJSONString(b, l.ThumbnailUrl)
+	b = append(b, `,"tags":`...)
+	b = appendJSONArray(b, l.Tags)
+	b = append(b, `,"start_at":`...)
+	b = strconv.AppendInt(b, l.StartAt, 10)
+	b = append(b, `,"end_at":`...)
+	b = strconv.AppendInt(b, l.EndAt, 10)
+	b = append(b, `,"privacy_status":`...)
+	b = appendJSONString(b, l.PrivacyStatus)
+	if l.PinnedLivecomment != nil {
+		b = append(b, `,"pinned_livecomment":`...)
+		b = l.PinnedLivecomment.appendJSON(b)
+	}
+	if len(l.Collaborators) > 0 {
+		b = append(b, `,"collaborators":`...)
+		b = appendJSONArray(b, l.Collaborators)
+	}
+	if l.IngestKey != "" {
+		b = append(b, `,"ingest_key":`...)
+		b = appendJSONString(b, l.IngestKey)
+	}
+	return append(b, '}')
+}
+
+func (l Livecomment) appendJSON(b []byte) []byte {
+	b = append(b, `{"id":`...)
+	b = strconv.AppendInt(b, l.ID, 10)
+	b = append(b, `,"user":`...)
+	b = l.User.appendJSON(b)
+	b = append(b, `,"livestream":`...)
+	b = l.Livestream.appendJSON(b)
+	b = append(b, `,"comment":`...)
+	b = appendJSONString(b, l.Comment)
+	b = append(b, `,"tip":`...)
+	b = strconv.AppendInt(b, l.Tip, 10)
+	b = append(b, `,"created_at":`...)
+	b = strconv.AppendInt(b, l.CreatedAt, 10)
+	return append(b, '}')
+}
+
+func (r Reaction) appendJSON(b []byte) []byte {
+	b = append(b, `{"id":`...)
+	b = strconv.AppendInt(b, r.ID, 10)
+	b = append(b, `,"emoji_name":`...)
+	b = appendJSONString(b, r.EmojiName)
+	b = append(b, `,"user":`...)
+	b = r.User.appendJSON(b)
+	b = append(b, `,"livestream":`...)
+	b = r.Livestream.appendJSON(b)
+	b = append(b, `,"created_at":`...)
+	b = strconv.AppendInt(b, r.CreatedAt, 10)
+	return append(b, '}')
+}
+
+// appendJSONArray は、encoding/jsonと同じくnilのスライスをnullとして書き出します
+func appendJSONArray[T interface{ appendJSON([]byte) []byte }](b []byte, values []T) []byte {
+	if values == nil {
+		return append(b, "null"...)
+	}
+	b = append(b, '[')
+	for i, v := range values {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = v.appendJSON(b)
+	}
+	return append(b, ']')
+}
+
+const jsonHex = "0123456789abcdef"
+
+// appendJSONString は、encoding/jsonのHTMLエスケープを有効にしたときと同じ規則でsを文字列として書き出します
+func appendJSONString(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '\\', '"':
+				b = append(b, '\\', c)
+			case '\b':
+				b = append(b, '\\', 'b')
+			case '\f':
+				b = append(b, '\\', 'f')
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', jsonHex[c>>4], jsonHex[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			// 不正なUTF-8は、encoding/jsonと同じくU+FFFDに置き換える
+			b = append(b, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028, U+2029はJavaScriptの文字列に含められないので、エスケープする
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', jsonHex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// チップの合計の増減をDBへ書き出す間隔
+	tipLeaderboardPersistInterval = 5 * time.Second
+	// リーダーボードをlimitの指定なしで取得したときの件数と、指定できる最大の件数
+	defaultTipLeaderboardLimit = 10
+	maxTipLeaderboardLimit     = 100
+)
+
+type TipperTotalModel struct {
+	LivestreamID int64 `db:"livestream_id"`
+	UserID       int64 `db:"user_id"`
+	TotalTip     int64 `db:"total_tip"`
+}
+
+type tipperKey struct {
+	livestreamID int64
+	userID       int64
+}
+
+// tipper は、リーダーボードに載るユーザと、そのチップの合計です
+type tipper struct {
+	UserID   int64
+	TotalTip int64
+}
+
+// tipLeaderboardTracker は、配信ごと・全体での、ユーザごとのスーパーチャットのチップの合計をメモリ上で管理します
+// スーパーチャットの投稿・削除のたびに増減させ、増減した分を定期的にlivestream_tipper_totalsへ書き出します
+// 書き出しは増減した分を足すので、アプリケーションサーバを複数台にしても合計は正しく保たれますが、
+// メモリ上の合計には、起動してから他のサーバで投稿されたスーパーチャットは含まれません
+type tipLeaderboardTracker struct {
+	mu     sync.Mutex
+	totals map[tipperKey]int64
+	// user_id => 全配信のチップの合計
+	global map[int64]int64
+	// 前回の書き出し以降に増減した分
+	pending map[tipperKey]int64
+}
+
+var tipLeaderboard = newTipLeaderboardTracker()
+
+func newTipLeaderboardTracker() *tipLeaderboardTracker {
+	return &tipLeaderboardTracker{
+		totals:  make(map[tipperKey]int64),
+		global:  make(map[int64]int64),
+		pending: make(map[tipperKey]int64),
+	}
+}
+
+// reset は、メモリ上の状態をすべて破棄します
+func (t *tipLeaderboardTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.totals = make(map[tipperKey]int64)
+	t.global = make(map[int64]int64)
+	t.pending = make(map[tipperKey]int64)
+}
+
+// load は、DBに保存された合計でメモリ上の状態を置き換えます
+func (t *tipLeaderboardTracker) load(ctx context.Context, db *sqlx.DB) error {
+	var models []TipperTotalModel
+	if err := db.SelectContext(ctx, &models, "SELECT * FROM livestream_tipper_totals WHERE total_tip > 0"); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.totals = make(map[tipperKey]int64, len(models))
+	t.global = make(map[int64]int64)
+	for _, model := range models {
+		t.totals[tipperKey{livestreamID: model.LivestreamID, userID: model.UserID}] = model.TotalTip
+		t.global[model.UserID] += model.TotalTip
+	}
+	t.pending = make(map[tipperKey]int64)
+
+	return nil
+}
+
+// rebuild は、livecommentsからチップの合計を数え直してlivestream_tipper_totalsを置き換え、読み込み直します
+// livestream_tipper_totalsは他の言語の実装にはないので、init.sqlではなく初期化のときにここで作り直します
+func (t *tipLeaderboardTracker) rebuild(ctx context.Context, db *sqlx.DB) error {
+	if _, err := db.ExecContext(ctx, "TRUNCATE TABLE livestream_tipper_totals"); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, excludeDeleted("INSERT INTO livestream_tipper_totals (livestream_id, user_id, total_tip) SELECT livestream_id, user_id, SUM(tip) FROM livecomments WHERE tip > 0 GROUP BY livestream_id, user_id", "")); err != nil {
+		return err
+	}
+	return t.load(ctx, db)
+}
+
+// add は、ユーザが配信に送ったチップの合計をdeltaだけ増減させます
+// 元となるライブコメントの投稿・削除をコミットした後に呼び出してください
+func (t *tipLeaderboardTracker) add(livestreamID int64, userID int64, delta int64) {
+	if delta == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := tipperKey{livestreamID: livestreamID, userID: userID}
+	t.totals[key] += delta
+	if t.totals[key] <= 0 {
+		delete(t.totals, key)
+	}
+	t.global[userID] += delta
+	if t.global[userID] <= 0 {
+		delete(t.global, userID)
+	}
+	t.pending[key] += delta
+}
+
+// livestream は、配信にチップを送ったユーザを、合計の多い順に返します
+func (t *tipLeaderboardTracker) livestream(livestreamID int64) []tipper {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var tippers []tipper
+	for key, total := range t.totals {
+		if key.livestreamID == livestreamID {
+			tippers = append(tippers, tipper{UserID: key.userID, TotalTip: total})
+		}
+	}
+	sortTippers(tippers)
+	return tippers
+}
+
+// all は、全配信でチップを送ったユーザを、合計の多い順に返します
+func (t *tipLeaderboardTracker) all() []tipper {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tippers := make([]tipper, 0, len(t.global))
+	for userID, total := range t.global {
+		tippers = append(tippers, tipper{UserID: userID, TotalTip: total})
+	}
+	sortTippers(tippers)
+	return tippers
+}
+
+// sortTippers は、チップの合計の降順に並べます。同額の場合はIDの小さい方 (先に登録したユーザ) を上位とします
+func sortTippers(tippers []tipper) {
+	sort.Slice(tippers, func(i, j int) bool {
+		if tippers[i].TotalTip != tippers[j].TotalTip {
+			return tippers[i].TotalTip > tippers[j].TotalTip
+		}
+		return tippers[i].UserID < tippers[j].UserID
+	})
+}
+
+// persist は、前回の書き出し以降に増減した分をDBへ書き出します
+func (t *tipLeaderboardTracker) persist(ctx context.Context, db *sqlx.DB) error {
+	t.mu.Lock()
+	deltas := make([]TipperTotalModel, 0, len(t.pending))
+	for key, delta := range t.pending {
+		if delta == 0 {
+			continue
+		}
+		deltas = append(deltas, TipperTotalModel{
+			LivestreamID: key.livestreamID,
+			UserID:       key.userID,
+			TotalTip:     delta,
+		})
+	}
+	t.pending = make(map[tipperKey]int64)
+	t.mu.Unlock()
+
+	if len(deltas) == 0 {
+		return nil
+	}
+	if _, err := db.NamedExecContext(ctx, "INSERT INTO livestream_tipper_totals (livestream_id, user_id, total_tip) VALUES (:livestream_id, :user_id, :total_tip) ON DUPLICATE KEY UPDATE total_tip = total_tip + VALUES(total_tip)", deltas); err != nil {
+		// 書き出せなかった分は次に回す
+		t.mu.Lock()
+		for _, delta := range deltas {
+			t.pending[tipperKey{livestreamID: delta.LivestreamID, userID: delta.UserID}] += delta.TotalTip
+		}
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// runPersister は、persistを定期的に実行します
+func (t *tipLeaderboardTracker) runPersister(db *sqlx.DB, logger echo.Logger) {
+	ticker := time.NewTicker(tipLeaderboardPersistInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := t.persist(context.Background(), db); err != nil {
+			logger.Warnf("failed to persist tipper totals: %v", err)
+		}
+	}
+}
+
+type TipperLeaderboardEntry struct {
+	Rank     int64 `json:"rank"`
+	User     User  `json:"user"`
+	TotalTip int64 `json:"total_tip"`
+}
+
+// 配信のチップのリーダーボード
+// GET /api/livestream/:livestream_id/leaderboard?limit=
+func getLivestreamTipLeaderboardHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := verifyUserSession(c); err != nil {
+		return err
+	}
+
+	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
+	if err != nil {
+		return apperror.BadRequest("livestream_id in path must be integer")
+	}
+	limit, err := tipLeaderboardLimitParam(c)
+	if err != nil {
+		return err
+	}
+
+	db := readDB(c)
+
+	var livestreamModel LivestreamModel
+	if err := preparedGet(ctx, db, &livestreamModel, queryLivestreamByID, livestreamID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperror.NotFound("not found livestream that has the given id")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
+	}
+
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	// 非公開配信は存在自体を隠す
+	if !isLivestreamVisible(livestreamModel, userID) {
+		return apperror.NotFound("not found livestream that has the given id")
+	}
+
+	entries, err := buildTipLeaderboard(ctx, db, tipLeaderboard.livestream(livestreamModel.ID), limit)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, entries)
+}
+
+// 全配信のチップのリーダーボード
+// GET /api/leaderboard/tippers?limit=
+func getTipperLeaderboardHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := verifyUserSession(c); err != nil {
+		return err
+	}
+
+	limit, err := tipLeaderboardLimitParam(c)
+	if err != nil {
+		return err
+	}
+
+	entries, err := buildTipLeaderboard(ctx, readDB(c), tipLeaderboard.all(), limit)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, entries)
+}
+
+func tipLeaderboardLimitParam(c echo.Context) (int, error) {
+	if c.QueryParam("limit") == "" {
+		return defaultTipLeaderboardLimit, nil
+	}
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit < 0 || limit > maxTipLeaderboardLimit {
+		return 0, apperror.BadRequest("limit query parameter must be an integer between 0 and " + strconv.Itoa(maxTipLeaderboardLimit))
+	}
+	return limit, nil
+}
+
+// buildTipLeaderboard は、合計の多い順に並んだtippersのうち、上位limit人をレスポンスの形にします
+// 削除済みのユーザは載せず、その分だけ下位のユーザを繰り上げます
+func buildTipLeaderboard(ctx context.Context, db *sqlx.DB, tippers []tipper, limit int) ([]TipperLeaderboardEntry, error) {
+	entries := make([]TipperLeaderboardEntry, 0, min(limit, len(tippers)))
+	for len(tippers) > 0 && len(entries) < limit {
+		chunk := tippers[:min(len(tippers), limit-len(entries))]
+		tippers = tippers[len(chunk):]
+
+		userIDs := make([]int64, len(chunk))
+		for i, t := range chunk {
+			userIDs[i] = t.UserID
+		}
+		users, err := loadUsers(ctx, db, userIDs)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to get users: "+err.Error())
+		}
+		for _, t := range chunk {
+			user, ok := users[t.UserID]
+			if !ok {
+				continue
+			}
+			entries = append(entries, TipperLeaderboardEntry{
+				Rank:     int64(len(entries) + 1),
+				User:     user,
+				TotalTip: t.TotalTip,
+			})
+		}
+	}
+	return entries, nil
+}
@@ -6,27 +6,40 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/isucon/isucon13/webapp/go/internal/spamscore"
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	// ライブコメントの投稿を待つ時間の上限
+	livecommentMaxWait = 30 * time.Second
+	// モデレーション画面のライブコメント一覧のデフォルトの件数
+	defaultModerationLivecommentsLimit = 100
+)
+
 type PostLivecommentRequest struct {
 	Comment string `json:"comment"`
 	Tip     int64  `json:"tip"`
 }
 
 type LivecommentModel struct {
-	ID           int64  `db:"id"`
-	UserID       int64  `db:"user_id"`
-	LivestreamID int64  `db:"livestream_id"`
-	Comment      string `db:"comment"`
-	Tip          int64  `db:"tip"`
-	CreatedAt    int64  `db:"created_at"`
+	ID           int64         `db:"id"`
+	UserID       int64         `db:"user_id"`
+	LivestreamID int64         `db:"livestream_id"`
+	Comment      string        `db:"comment"`
+	Tip          int64         `db:"tip"`
+	CreatedAt    int64         `db:"created_at"`
+	DeletedAt    sql.NullInt64 `db:"deleted_at"`
+	SpamScore    float64       `db:"spam_score"`
+	HiddenAt     sql.NullInt64 `db:"hidden_at"`
 }
 
 type Livecomment struct {
@@ -57,6 +70,10 @@ type ModerateRequest struct {
 	NGWord string `json:"ng_word"`
 }
 
+type ModerateResponse struct {
+	WordID int64 `json:"word_id"`
+}
+
 type NGWord struct {
 	ID           int64  `json:"id" db:"id"`
 	UserID       int64  `json:"user_id" db:"user_id"`
@@ -75,50 +92,122 @@ func getLivecommentsHandler(c echo.Context) error {
 
 	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "livestream_id in path must be integer")
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
+	db := readDB(c)
+
+	// afterを指定したときは、そのIDより後に投稿されたものを古い順に返す
+	// waitも指定すると、まだ投稿されていなければ、投稿されるまでwaitの間待つ
+	var (
+		after int64
+		wait  time.Duration
+	)
+	if c.QueryParam("after") != "" {
+		after, err = strconv.ParseInt(c.QueryParam("after"), 10, 64)
+		if err != nil || after < 0 {
+			return apperror.BadRequest("after query parameter must be non-negative integer")
+		}
+		if c.QueryParam(cursorQueryParam) != "" {
+			return apperror.BadRequest("after and cursor query parameters cannot be used together")
+		}
+		if c.QueryParam("wait") != "" {
+			wait, err = time.ParseDuration(c.QueryParam("wait"))
+			if err != nil || wait < 0 {
+				return apperror.BadRequest("wait query parameter must be non-negative duration")
+			}
+			if wait > livecommentMaxWait {
+				wait = livecommentMaxWait
+			}
+		}
+	} else if c.QueryParam("wait") != "" {
+		return apperror.BadRequest("wait query parameter requires after query parameter")
 	}
-	defer tx.Rollback()
 
-	query := "SELECT * FROM livecomments WHERE livestream_id = ? ORDER BY created_at DESC"
+	query := "SELECT * FROM livecomments WHERE livestream_id = ? AND deleted_at IS NULL AND hidden_at IS NULL"
+	params := []interface{}{livestreamID}
+	if c.QueryParam("after") != "" {
+		query += " AND id > ?"
+		params = append(params, after)
+	}
+	cur, ok, err := cursorParam(c)
+	if err != nil {
+		return err
+	}
+	if ok {
+		cond, args := cur.condition("created_at", "id", true)
+		query += " AND " + cond
+		params = append(params, args...)
+	}
+	if c.QueryParam("after") != "" {
+		query += " ORDER BY id ASC"
+	} else {
+		query += " ORDER BY created_at DESC, id DESC"
+	}
+	limit := -1
 	if c.QueryParam("limit") != "" {
-		limit, err := strconv.Atoi(c.QueryParam("limit"))
+		limit, err = strconv.Atoi(c.QueryParam("limit"))
 		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, "limit query parameter must be integer")
+			return apperror.BadRequest("limit query parameter must be integer")
 		}
 		query += fmt.Sprintf(" LIMIT %d", limit)
 	}
 
 	livecommentModels := []LivecommentModel{}
-	err = tx.SelectContext(ctx, &livecommentModels, query, livestreamID)
+	if wait > 0 {
+		livecommentModels, err = waitLivecomments(ctx, int64(livestreamID), wait, query, params)
+	} else {
+		err = db.SelectContext(ctx, &livecommentModels, query, params...)
+	}
 	if errors.Is(err, sql.ErrNoRows) {
-		return c.JSON(http.StatusOK, []*Livecomment{})
+		return c.JSON(http.StatusOK, []Livecomment{})
 	}
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livecomments: "+err.Error())
 	}
 
-	livecomments := make([]Livecomment, len(livecommentModels))
-	for i := range livecommentModels {
-		livecomment, err := fillLivecommentResponse(ctx, tx, livecommentModels[i])
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fil livecomments: "+err.Error())
-		}
-
-		livecomments[i] = livecomment
+	livecomments, err := fillLivecommentResponses(ctx, db, livecommentModels)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fil livecomments: "+err.Error())
 	}
-
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+	// afterで読み進めるときは、最後のIDを次のafterにすればよいのでカーソルは返さない
+	if c.QueryParam("after") == "" {
+		setNextCursor(c, len(livecommentModels), limit, func() cursor {
+			last := livecommentModels[len(livecommentModels)-1]
+			return cursor{sortKey: last.CreatedAt, id: last.ID}
+		})
 	}
 
 	return c.JSON(http.StatusOK, livecomments)
 }
 
+// waitLivecomments は、queryで読めるライブコメントがなければ、配信にライブコメントが投稿されるかwaitが経つまで待ってから読み直します
+// 投稿を知らせた直後のものも読めるよう、プライマリから読みます
+func waitLivecomments(ctx context.Context, livestreamID int64, wait time.Duration, query string, params []interface{}) ([]LivecommentModel, error) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		// 読んでから待つまでの間の投稿を取りこぼさないよう、読む前に待ち始める
+		posted := livecommentWaiters.wait(livestreamID)
+
+		livecommentModels := []LivecommentModel{}
+		if err := dbConn.SelectContext(ctx, &livecommentModels, query, params...); err != nil {
+			return nil, err
+		}
+		if len(livecommentModels) > 0 {
+			return livecommentModels, nil
+		}
+
+		select {
+		case <-posted:
+		case <-timer.C:
+			return livecommentModels, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
 func getNgwords(c echo.Context) error {
 	ctx := c.Request().Context()
 
@@ -133,17 +222,28 @@ func getNgwords(c echo.Context) error {
 
 	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "livestream_id in path must be integer")
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
+	db := readDB(c)
+
+	// 共同配信者には配信者のNGワードを返す
+	var livestreamModel LivestreamModel
+	if err := preparedGet(ctx, db, &livestreamModel, queryLivestreamByID, livestreamID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
+	}
+	if livestreamModel.ID != 0 && livestreamModel.UserID != userID {
+		canModerate, err := canModerateLivestream(ctx, db, livestreamModel, userID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get collaborators: "+err.Error())
+		}
+		if canModerate {
+			userID = livestreamModel.UserID
+		}
 	}
-	defer tx.Rollback()
 
 	var ngWords []*NGWord
-	if err := tx.SelectContext(ctx, &ngWords, "SELECT * FROM ng_words WHERE user_id = ? AND livestream_id = ? ORDER BY created_at DESC", userID, livestreamID); err != nil {
+	if err := db.SelectContext(ctx, &ngWords, "SELECT * FROM ng_words WHERE user_id = ? AND livestream_id = ? ORDER BY created_at DESC", userID, livestreamID); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return c.JSON(http.StatusOK, []*NGWord{})
 		} else {
@@ -151,10 +251,6 @@ func getNgwords(c echo.Context) error {
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
-	}
-
 	return c.JSON(http.StatusOK, ngWords)
 }
 
@@ -168,7 +264,7 @@ func postLivecommentHandler(c echo.Context) error {
 
 	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "livestream_id in path must be integer")
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 
 	// error already checked
@@ -178,76 +274,141 @@ func postLivecommentHandler(c echo.Context) error {
 
 	var req *PostLivecommentRequest
 	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "failed to decode the request body as json")
+		return apperror.BadRequest("failed to decode the request body as json")
 	}
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
+	// 再送されたリクエストでは、チップを二重に計上せずに最初に投稿したライブコメントを返す
+	idem, err := beginIdempotentRequest(c, "livecomment:"+strconv.Itoa(livestreamID), userID, req)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
+		return err
+	}
+	defer idem.end()
+	if id, ok := idem.replayed(); ok {
+		var livecommentModel LivecommentModel
+		if err := dbConn.GetContext(ctx, &livecommentModel, "SELECT * FROM livecomments WHERE id = ? AND deleted_at IS NULL", id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return apperror.NotFound("the livecomment posted with this Idempotency-Key has been deleted")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livecomment: "+err.Error())
+		}
+		livecomment, err := fillLivecommentResponse(ctx, dbConn, livecommentModel)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livecomment: "+err.Error())
+		}
+		return c.JSON(http.StatusCreated, livecomment)
 	}
-	defer tx.Rollback()
 
+	// スパム判定
+	// 登録直後のNGワードも反映されるよう、プライマリから読む (書き込みはないのでトランザクションは張らない)
 	var livestreamModel LivestreamModel
-	if err := tx.GetContext(ctx, &livestreamModel, "SELECT * FROM livestreams WHERE id = ?", livestreamID); err != nil {
+	if err := preparedGet(ctx, dbConn, &livestreamModel, queryLivestreamByID, livestreamID); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return echo.NewHTTPError(http.StatusNotFound, "livestream not found")
+			return apperror.NotFound("livestream not found")
 		} else {
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
 		}
 	}
+	// チャンネルから締め出されたユーザは、ライブコメントもスーパーチャットも投稿できない
+	if err := checkChannelBan(livestreamModel, userID); err != nil {
+		return err
+	}
 
-	// スパム判定
-	var ngwords []*NGWord
-	if err := tx.SelectContext(ctx, &ngwords, "SELECT id, user_id, livestream_id, word FROM ng_words WHERE user_id = ? AND livestream_id = ?", livestreamModel.UserID, livestreamModel.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+	matcher, err := ngWordMatchers.get(ctx, dbConn, livestreamModel.ID)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get NG words: "+err.Error())
 	}
-
-	var hitSpam int
-	for _, ngword := range ngwords {
-		query := `
-		SELECT COUNT(*)
-		FROM
-		(SELECT ? AS text) AS texts
-		INNER JOIN
-		(SELECT CONCAT('%', ?, '%')	AS pattern) AS patterns
-		ON texts.text LIKE patterns.pattern;
-		`
-		if err := tx.GetContext(ctx, &hitSpam, query, req.Comment, ngword.Word); err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get hitspam: "+err.Error())
+	// スパムらしさは、伏せ字にする前の本文で計算する
+	originalComment := req.Comment
+	if matcher.Match(req.Comment) {
+		requestLogger(c).Info("hitSpam", slog.String("comment", req.Comment))
+		// スーパーチャットは配信ごとの設定により、チップを受け付けつつ伏せ字にできる
+		policy := superchatNGPolicyReject
+		if req.Tip > 0 {
+			policy, err = getSuperchatNGPolicy(ctx, dbConn, livestreamModel.ID)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get superchat NG policy: "+err.Error())
+			}
 		}
-		c.Logger().Infof("[hitSpam=%d] comment = %s", hitSpam, req.Comment)
-		if hitSpam >= 1 {
-			return echo.NewHTTPError(http.StatusBadRequest, "このコメントがスパム判定されました")
+		if policy != superchatNGPolicyMask {
+			return apperror.BadRequest("このコメントがスパム判定されました")
 		}
+		req.Comment = matcher.Mask(req.Comment)
 	}
 
 	now := time.Now().Unix()
+	// 低速モード中は、一定時間内の連続投稿を拒否する
+	if ok, retryAfter := slowMode.allow(livestreamModel.ID, userID, now); !ok {
+		c.Response().Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+		return echo.NewHTTPError(http.StatusTooManyRequests, "slow mode is enabled on this livestream")
+	}
+
+	spamScore, err := computeSpamScore(ctx, dbConn, livestreamModel.ID, userID, originalComment, matcher, now)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to compute spam score: "+err.Error())
+	}
+	hideThreshold, err := getSpamHideThreshold(ctx, dbConn, livestreamModel.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get spam hide threshold: "+err.Error())
+	}
+
 	livecommentModel := LivecommentModel{
 		UserID:       userID,
 		LivestreamID: int64(livestreamID),
 		Comment:      req.Comment,
 		Tip:          req.Tip,
 		CreatedAt:    now,
+		SpamScore:    spamScore,
 	}
-
-	rs, err := tx.NamedExecContext(ctx, "INSERT INTO livecomments (user_id, livestream_id, comment, tip, created_at) VALUES (:user_id, :livestream_id, :comment, :tip, :created_at)", livecommentModel)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert livecomment: "+err.Error())
+	// 非表示にしても投稿は受け付け、チップも計上する
+	if hideThreshold.Valid && spamScore >= hideThreshold.Float64 {
+		livecommentModel.HiddenAt = sql.NullInt64{Int64: now, Valid: true}
 	}
 
-	livecommentID, err := rs.LastInsertId()
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get last inserted livecomment id: "+err.Error())
-	}
-	livecommentModel.ID = livecommentID
+	var livecomment Livecomment
+	err = withTx(ctx, func(tx *sqlx.Tx) error {
+		rs, err := preparedExec(ctx, tx, queryInsertLivecomment, livecommentModel.UserID, livecommentModel.LivestreamID, livecommentModel.Comment, livecommentModel.Tip, livecommentModel.CreatedAt, livecommentModel.SpamScore, livecommentModel.HiddenAt)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert livecomment: "+err.Error())
+		}
+
+		livecommentID, err := rs.LastInsertId()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get last inserted livecomment id: "+err.Error())
+		}
+		livecommentModel.ID = livecommentID
 
-	livecomment, err := fillLivecommentResponse(ctx, tx, livecommentModel)
+		if err := addTip(ctx, tx, livestreamModel.UserID, livecommentModel, livecommentModel.Tip); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update total tip: "+err.Error())
+		}
+		if err := addLivestreamScore(ctx, tx, livecommentModel.LivestreamID, scoreDelta{totalTip: livecommentModel.Tip, commentCount: 1}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update livestream score: "+err.Error())
+		}
+
+		livecomment, err = fillLivecommentResponse(ctx, tx, livecommentModel)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livecomment: "+err.Error())
+		}
+		return nil
+	})
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livecomment: "+err.Error())
+		return err
 	}
-
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+	idem.complete(ctx, livecommentModel.ID)
+	trending.addLivecomment(livecommentModel.LivestreamID, livecommentModel.Tip, time.Unix(livecommentModel.CreatedAt, 0))
+	tipLeaderboard.add(livecommentModel.LivestreamID, livecommentModel.UserID, livecommentModel.Tip)
+	// 非表示にしたものは、視聴者には届けない
+	if !livecommentModel.HiddenAt.Valid {
+		liveHubs.publishLivecomment(livecomment)
+		livecommentWaiters.notify(livecommentModel.LivestreamID)
+	}
+	if livecommentModel.Tip > 0 {
+		events.publish(event{
+			Kind:          eventSuperchatPosted,
+			UserID:        livecommentModel.UserID,
+			LivestreamID:  livecommentModel.LivestreamID,
+			LivecommentID: livecommentModel.ID,
+			Tip:           livecommentModel.Tip,
+		})
 	}
 
 	return c.JSON(http.StatusCreated, livecomment)
@@ -262,12 +423,12 @@ func reportLivecommentHandler(c echo.Context) error {
 
 	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "livestream_id in path must be integer")
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 
 	livecommentID, err := strconv.Atoi(c.Param("livecomment_id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "livecomment_id in path must be integer")
+		return apperror.BadRequest("livecomment_id in path must be integer")
 	}
 
 	// error already checked
@@ -275,53 +436,51 @@ func reportLivecommentHandler(c echo.Context) error {
 	// existence already checked
 	userID := sess.Values[defaultUserIDKey].(int64)
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
-	}
-	defer tx.Rollback()
-
-	var livestreamModel LivestreamModel
-	if err := tx.GetContext(ctx, &livestreamModel, "SELECT * FROM livestreams WHERE id = ?", livestreamID); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return echo.NewHTTPError(http.StatusNotFound, "livestream not found")
-		} else {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
+	var report LivecommentReport
+	err = withTx(ctx, func(tx *sqlx.Tx) error {
+		var livestreamModel LivestreamModel
+		if err := preparedGet(ctx, tx, &livestreamModel, queryLivestreamByID, livestreamID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return apperror.NotFound("livestream not found")
+			} else {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
+			}
 		}
-	}
 
-	var livecommentModel LivecommentModel
-	if err := tx.GetContext(ctx, &livecommentModel, "SELECT * FROM livecomments WHERE id = ?", livecommentID); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return echo.NewHTTPError(http.StatusNotFound, "livecomment not found")
-		} else {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livecomment: "+err.Error())
+		var livecommentModel LivecommentModel
+		if err := tx.GetContext(ctx, &livecommentModel, "SELECT * FROM livecomments WHERE id = ? AND deleted_at IS NULL", livecommentID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return apperror.NotFound("livecomment not found")
+			} else {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livecomment: "+err.Error())
+			}
 		}
-	}
 
-	now := time.Now().Unix()
-	reportModel := LivecommentReportModel{
-		UserID:        int64(userID),
-		LivestreamID:  int64(livestreamID),
-		LivecommentID: int64(livecommentID),
-		CreatedAt:     now,
-	}
-	rs, err := tx.NamedExecContext(ctx, "INSERT INTO livecomment_reports(user_id, livestream_id, livecomment_id, created_at) VALUES (:user_id, :livestream_id, :livecomment_id, :created_at)", &reportModel)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert livecomment report: "+err.Error())
-	}
-	reportID, err := rs.LastInsertId()
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get last inserted livecomment report id: "+err.Error())
-	}
-	reportModel.ID = reportID
+		now := time.Now().Unix()
+		reportModel := LivecommentReportModel{
+			UserID:        int64(userID),
+			LivestreamID:  int64(livestreamID),
+			LivecommentID: int64(livecommentID),
+			CreatedAt:     now,
+		}
+		rs, err := tx.NamedExecContext(ctx, "INSERT INTO livecomment_reports(user_id, livestream_id, livecomment_id, created_at) VALUES (:user_id, :livestream_id, :livecomment_id, :created_at)", &reportModel)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert livecomment report: "+err.Error())
+		}
+		reportID, err := rs.LastInsertId()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get last inserted livecomment report id: "+err.Error())
+		}
+		reportModel.ID = reportID
 
-	report, err := fillLivecommentReportResponse(ctx, tx, reportModel)
+		report, err = fillLivecommentReportResponse(ctx, tx, reportModel)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livecomment report: "+err.Error())
+		}
+		return nil
+	})
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livecomment report: "+err.Error())
-	}
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+		return err
 	}
 
 	return c.JSON(http.StatusCreated, report)
@@ -338,7 +497,7 @@ func moderateHandler(c echo.Context) error {
 
 	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "livestream_id in path must be integer")
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 
 	// error already checked
@@ -348,135 +507,471 @@ func moderateHandler(c echo.Context) error {
 
 	var req *ModerateRequest
 	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "failed to decode the request body as json")
-	}
+		return apperror.BadRequest("failed to decode the request body as json")
+	}
+
+	var wordID int64
+	// 削除したスーパーチャット。コミットした後にリーダーボードから差し引く
+	var deletedSuperchats []*LivecommentModel
+	err = withTx(ctx, func(tx *sqlx.Tx) error {
+		deletedSuperchats = nil
+		// 配信者 (または共同配信者) の配信に対するmoderateなのかを検証
+		var livestreamModel LivestreamModel
+		if err := preparedGet(ctx, tx, &livestreamModel, queryLivestreamByID, livestreamID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return apperror.BadRequest("A streamer can't moderate livestreams that other streamers own")
+			} else {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestreams: "+err.Error())
+			}
+		}
+		canModerate, err := canModerateLivestream(ctx, tx, livestreamModel, userID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get collaborators: "+err.Error())
+		}
+		if !canModerate {
+			return apperror.BadRequest("A streamer can't moderate livestreams that other streamers own")
+		}
+
+		// 共同配信者が登録した場合も、NGワードは配信者のものとして扱う
+		rs, err := tx.NamedExecContext(ctx, "INSERT INTO ng_words(user_id, livestream_id, word, created_at) VALUES (:user_id, :livestream_id, :word, :created_at)", &NGWord{
+			UserID:       livestreamModel.UserID,
+			LivestreamID: int64(livestreamID),
+			Word:         req.NGWord,
+			CreatedAt:    time.Now().Unix(),
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert new NG word: "+err.Error())
+		}
+
+		wordID, err = rs.LastInsertId()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get last inserted NG word id: "+err.Error())
+		}
+		if err := events.publishInTx(ctx, event{Kind: eventNGWordAdded, UserID: userID, LivestreamID: int64(livestreamID), Detail: req.NGWord, Tx: tx}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to record audit log: "+err.Error())
+		}
+
+		// 追加したNGワードを含めて照合器を構築し直す
+		matcher, err := loadNGWordMatcher(ctx, tx, int64(livestreamID))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get NG words: "+err.Error())
+		}
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
+		superchatNGPolicy, err := getSuperchatNGPolicy(ctx, tx, int64(livestreamID))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get superchat NG policy: "+err.Error())
+		}
+
+		// NGワードにヒットする過去の投稿も全削除する
+		// ただし伏せ字にする設定の場合、スーパーチャットは伏せ字にして残す
+		var livecomments []*LivecommentModel
+		if err := tx.SelectContext(ctx, &livecomments, excludeDeleted("SELECT * FROM livecomments WHERE livestream_id = ?", ""), livestreamID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livecomments: "+err.Error())
+		}
+		now := time.Now().Unix()
+		for _, livecomment := range livecomments {
+			if !matcher.Match(livecomment.Comment) {
+				continue
+			}
+			if livecomment.Tip > 0 && superchatNGPolicy == superchatNGPolicyMask {
+				if _, err := tx.ExecContext(ctx, "UPDATE livecomments SET comment = ? WHERE id = ?", matcher.Mask(livecomment.Comment), livecomment.ID); err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, "failed to mask old superchats that hit spams: "+err.Error())
+				}
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, "UPDATE livecomments SET deleted_at = ? WHERE id = ?", now, livecomment.ID); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete old livecomments that hit spams: "+err.Error())
+			}
+			// 削除されたスーパーチャットのチップは売上から差し引く
+			if err := addTip(ctx, tx, livestreamModel.UserID, *livecomment, -livecomment.Tip); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to update total tip: "+err.Error())
+			}
+			if livecomment.Tip > 0 {
+				deletedSuperchats = append(deletedSuperchats, livecomment)
+			}
+			if err := addLivestreamScore(ctx, tx, livecomment.LivestreamID, scoreDelta{totalTip: -livecomment.Tip, commentCount: -1}); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to update livestream score: "+err.Error())
+			}
+			// NGワードによる削除も、NGワードを登録したユーザによる削除として残す
+			if err := events.publishInTx(ctx, event{
+				Kind:          eventLivecommentDeleted,
+				UserID:        userID,
+				LivestreamID:  livecomment.LivestreamID,
+				LivecommentID: livecomment.ID,
+				TargetUserID:  livecomment.UserID,
+				Detail:        "ngword: " + req.NGWord,
+				Tx:            tx,
+			}); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to record audit log: "+err.Error())
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
+		return err
+	}
+	ngWordMatchers.invalidate(int64(livestreamID))
+	peers.broadcast(peerMessage{Kind: peerMessageNGWords, LivestreamID: int64(livestreamID)})
+	for _, livecomment := range deletedSuperchats {
+		tipLeaderboard.add(livecomment.LivestreamID, livecomment.UserID, -livecomment.Tip)
 	}
-	defer tx.Rollback()
 
-	// 配信者自身の配信に対するmoderateなのかを検証
-	var ownedLivestreams []LivestreamModel
-	if err := tx.SelectContext(ctx, &ownedLivestreams, "SELECT * FROM livestreams WHERE id = ? AND user_id = ?", livestreamID, userID); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestreams: "+err.Error())
+	return c.JSON(http.StatusCreated, &ModerateResponse{WordID: wordID})
+}
+
+// 配信者によるスーパーチャット削除
+// DELETE /api/livestream/:livestream_id/superchat/:superchat_id
+func deleteSuperchatHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := verifyUserSession(c); err != nil {
+		return err
 	}
-	if len(ownedLivestreams) == 0 {
-		return echo.NewHTTPError(http.StatusBadRequest, "A streamer can't moderate livestreams that other streamers own")
+
+	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
+	if err != nil {
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 
-	rs, err := tx.NamedExecContext(ctx, "INSERT INTO ng_words(user_id, livestream_id, word, created_at) VALUES (:user_id, :livestream_id, :word, :created_at)", &NGWord{
-		UserID:       int64(userID),
-		LivestreamID: int64(livestreamID),
-		Word:         req.NGWord,
-		CreatedAt:    time.Now().Unix(),
+	superchatID, err := strconv.Atoi(c.Param("superchat_id"))
+	if err != nil {
+		return apperror.BadRequest("superchat_id in path must be integer")
+	}
+
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	var livecommentModel LivecommentModel
+	err = withTx(ctx, func(tx *sqlx.Tx) error {
+		var livestreamModel LivestreamModel
+		if err := preparedGet(ctx, tx, &livestreamModel, queryLivestreamByID, livestreamID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return apperror.NotFound("livestream not found")
+			} else {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
+			}
+		}
+
+		canModerate, err := canModerateLivestream(ctx, tx, livestreamModel, userID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get collaborators: "+err.Error())
+		}
+		if !canModerate {
+			return apperror.Forbidden("can't delete superchats of other streamer's livestream")
+		}
+
+		if err := tx.GetContext(ctx, &livecommentModel, "SELECT * FROM livecomments WHERE id = ? AND livestream_id = ? AND deleted_at IS NULL FOR UPDATE", superchatID, livestreamID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return apperror.NotFound("superchat not found")
+			} else {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get superchat: "+err.Error())
+			}
+		}
+		// チップが付いていないライブコメントはスーパーチャットではない
+		if livecommentModel.Tip <= 0 {
+			return apperror.BadRequest("the livecomment is not a superchat")
+		}
+
+		// 統計情報や売上の整合性を保つため、論理削除とする
+		if _, err := tx.ExecContext(ctx, "UPDATE livecomments SET deleted_at = ? WHERE id = ?", time.Now().Unix(), superchatID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete superchat: "+err.Error())
+		}
+		if err := addTip(ctx, tx, livestreamModel.UserID, livecommentModel, -livecommentModel.Tip); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update total tip: "+err.Error())
+		}
+		if err := addLivestreamScore(ctx, tx, livecommentModel.LivestreamID, scoreDelta{totalTip: -livecommentModel.Tip, commentCount: -1}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update livestream score: "+err.Error())
+		}
+		if err := events.publishInTx(ctx, event{
+			Kind:          eventLivecommentDeleted,
+			UserID:        userID,
+			LivestreamID:  livecommentModel.LivestreamID,
+			LivecommentID: livecommentModel.ID,
+			TargetUserID:  livecommentModel.UserID,
+			Tx:            tx,
+		}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to record audit log: "+err.Error())
+		}
+		return nil
 	})
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert new NG word: "+err.Error())
+		return err
 	}
+	tipLeaderboard.add(livecommentModel.LivestreamID, livecommentModel.UserID, -livecommentModel.Tip)
+
+	return c.NoContent(http.StatusNoContent)
+}
 
-	wordID, err := rs.LastInsertId()
+// 配信者によるライブコメントのピン留め
+// POST /api/livestream/:livestream_id/livecomment/:livecomment_id/pin
+func pinLivecommentHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := verifyUserSession(c); err != nil {
+		return err
+	}
+
+	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get last inserted NG word id: "+err.Error())
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 
-	var ngwords []*NGWord
-	if err := tx.SelectContext(ctx, &ngwords, "SELECT * FROM ng_words WHERE livestream_id = ?", livestreamID); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get NG words: "+err.Error())
+	livecommentID, err := strconv.Atoi(c.Param("livecomment_id"))
+	if err != nil {
+		return apperror.BadRequest("livecomment_id in path must be integer")
 	}
 
-	// NGワードにヒットする過去の投稿も全削除する
-	for _, ngword := range ngwords {
-		// ライブコメント一覧取得
-		var livecomments []*LivecommentModel
-		if err := tx.SelectContext(ctx, &livecomments, "SELECT * FROM livecomments"); err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livecomments: "+err.Error())
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	var livecomment Livecomment
+	err = withTx(ctx, func(tx *sqlx.Tx) error {
+		var livestreamModel LivestreamModel
+		if err := preparedGet(ctx, tx, &livestreamModel, queryLivestreamByID, livestreamID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return apperror.NotFound("livestream not found")
+			} else {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
+			}
 		}
 
-		for _, livecomment := range livecomments {
-			query := `
-			DELETE FROM livecomments
-			WHERE
-			id = ? AND
-			livestream_id = ? AND
-			(SELECT COUNT(*)
-			FROM
-			(SELECT ? AS text) AS texts
-			INNER JOIN
-			(SELECT CONCAT('%', ?, '%')	AS pattern) AS patterns
-			ON texts.text LIKE patterns.pattern) >= 1;
-			`
-			if _, err := tx.ExecContext(ctx, query, livecomment.ID, livestreamID, livecomment.Comment, ngword.Word); err != nil {
-				return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete old livecomments that hit spams: "+err.Error())
+		canModerate, err := canModerateLivestream(ctx, tx, livestreamModel, userID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get collaborators: "+err.Error())
+		}
+		if !canModerate {
+			return apperror.Forbidden("can't pin livecomments of other streamer's livestream")
+		}
+
+		var livecommentModel LivecommentModel
+		if err := tx.GetContext(ctx, &livecommentModel, "SELECT * FROM livecomments WHERE id = ? AND livestream_id = ? AND deleted_at IS NULL", livecommentID, livestreamID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return apperror.NotFound("livecomment not found")
+			} else {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livecomment: "+err.Error())
 			}
 		}
-	}
 
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
-	}
+		// 1配信につき1件のみなので、既存のピン留めを置き換える
+		if _, err := tx.ExecContext(ctx, "INSERT INTO livestream_pins (livestream_id, livecomment_id, created_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE livecomment_id = VALUES(livecomment_id), created_at = VALUES(created_at)", livestreamID, livecommentID, time.Now().Unix()); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to pin livecomment: "+err.Error())
+		}
+
+		livecomment, err = fillLivecommentResponse(ctx, tx, livecommentModel)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livecomment: "+err.Error())
+		}
 
-	return c.JSON(http.StatusCreated, map[string]interface{}{
-		"word_id": wordID,
+		if livecomment.User.ID != userID {
+			if err := events.publishInTx(ctx, event{
+				Kind:          eventLivecommentPinned,
+				UserID:        livecomment.User.ID,
+				LivestreamID:  int64(livestreamID),
+				LivecommentID: livecomment.ID,
+				Tx:            tx,
+			}); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to enqueue notification: "+err.Error())
+			}
+		}
+		return nil
 	})
+	if err != nil {
+		return err
+	}
+	if livecomment.User.ID != userID {
+		events.publish(event{
+			Kind:          eventLivecommentPinned,
+			UserID:        livecomment.User.ID,
+			LivestreamID:  int64(livestreamID),
+			LivecommentID: livecomment.ID,
+		})
+	}
+
+	return c.JSON(http.StatusOK, livecomment)
 }
 
-func fillLivecommentResponse(ctx context.Context, tx *sqlx.Tx, livecommentModel LivecommentModel) (Livecomment, error) {
-	commentOwnerModel := UserModel{}
-	if err := tx.GetContext(ctx, &commentOwnerModel, "SELECT * FROM users WHERE id = ?", livecommentModel.UserID); err != nil {
-		return Livecomment{}, err
+// ModerationLivecomment は、配信者のモデレーション画面に表示するライブコメントです
+type ModerationLivecomment struct {
+	Livecomment
+	SpamScore float64 `json:"spam_score"`
+	// スパムらしさが閾値を超えて、自動で非表示にしたか
+	Hidden bool `json:"hidden"`
+}
+
+// 配信者・共同モデレーターによる、スパムらしさの高い順のライブコメント一覧 (非表示にしたものを含む)
+// GET /api/livestream/:livestream_id/moderation/livecomment
+func getModerationLivecommentsHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := verifyUserSession(c); err != nil {
+		return err
 	}
-	commentOwner, err := fillUserResponse(ctx, tx, commentOwnerModel)
+
+	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return Livecomment{}, err
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 
-	livestreamModel := LivestreamModel{}
-	if err := tx.GetContext(ctx, &livestreamModel, "SELECT * FROM livestreams WHERE id = ?", livecommentModel.LivestreamID); err != nil {
-		return Livecomment{}, err
+	// error already check
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already check
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	limit := defaultModerationLivecommentsLimit
+	if c.QueryParam("limit") != "" {
+		limit, err = strconv.Atoi(c.QueryParam("limit"))
+		if err != nil || limit < 0 {
+			return apperror.BadRequest("limit query parameter must be non-negative integer")
+		}
+	}
+
+	// NGワードの登録でスパムを削除した直後も反映されるよう、プライマリから読む
+	var livestreamModel LivestreamModel
+	if err := preparedGet(ctx, dbConn, &livestreamModel, queryLivestreamByID, livestreamID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperror.NotFound("livestream not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
 	}
-	livestream, err := fillLivestreamResponse(ctx, tx, livestreamModel)
+	canModerate, err := canModerateLivestream(ctx, dbConn, livestreamModel, userID)
 	if err != nil {
-		return Livecomment{}, err
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get collaborators: "+err.Error())
+	}
+	if !canModerate {
+		return apperror.Forbidden("can't moderate other streamer's livecomments")
 	}
 
-	livecomment := Livecomment{
-		ID:         livecommentModel.ID,
-		User:       commentOwner,
-		Livestream: livestream,
-		Comment:    livecommentModel.Comment,
-		Tip:        livecommentModel.Tip,
-		CreatedAt:  livecommentModel.CreatedAt,
+	var livecommentModels []LivecommentModel
+	if err := dbConn.SelectContext(ctx, &livecommentModels, "SELECT * FROM livecomments WHERE livestream_id = ? AND deleted_at IS NULL ORDER BY spam_score DESC, id DESC LIMIT ?", livestreamID, limit); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livecomments: "+err.Error())
+	}
+	livecomments, err := fillLivecommentResponses(ctx, dbConn, livecommentModels)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livecomments: "+err.Error())
 	}
 
-	return livecomment, nil
+	res := make([]ModerationLivecomment, len(livecommentModels))
+	for i, livecommentModel := range livecommentModels {
+		res[i] = ModerationLivecomment{
+			Livecomment: livecomments[i],
+			SpamScore:   livecommentModel.SpamScore,
+			Hidden:      livecommentModel.HiddenAt.Valid,
+		}
+	}
+	return c.JSON(http.StatusOK, res)
 }
 
-func fillLivecommentReportResponse(ctx context.Context, tx *sqlx.Tx, reportModel LivecommentReportModel) (LivecommentReport, error) {
-	reporterModel := UserModel{}
-	if err := tx.GetContext(ctx, &reporterModel, "SELECT * FROM users WHERE id = ?", reportModel.UserID); err != nil {
-		return LivecommentReport{}, err
+// computeSpamScore は、ユーザが配信に直近に投稿したライブコメントと配信のNGワードから、commentのスパムらしさを計算します
+func computeSpamScore(ctx context.Context, q sqlx.QueryerContext, livestreamID, userID int64, comment string, matcher *ngWordMatcher, now int64) (float64, error) {
+	var livecommentModels []LivecommentModel
+	if err := sqlx.SelectContext(ctx, q, &livecommentModels, "SELECT * FROM livecomments WHERE livestream_id = ? AND user_id = ? ORDER BY id DESC LIMIT ?", livestreamID, userID, spamscore.RecentLimit); err != nil {
+		return 0, err
+	}
+	recent := make([]spamscore.Comment, len(livecommentModels))
+	for i, livecommentModel := range livecommentModels {
+		recent[i] = spamscore.Comment{Comment: livecommentModel.Comment, CreatedAt: livecommentModel.CreatedAt}
+	}
+	result := spamscore.Compute(spamscore.Input{
+		Comment: comment,
+		Now:     now,
+		Recent:  recent,
+		NGWords: matcher.words,
+	})
+	return result.Score, nil
+}
+
+func fillLivecommentResponse(ctx context.Context, q sqlx.QueryerContext, livecommentModel LivecommentModel) (Livecomment, error) {
+	livecomments, err := fillLivecommentResponses(ctx, q, []LivecommentModel{livecommentModel})
+	if err != nil {
+		return Livecomment{}, err
+	}
+	return livecomments[0], nil
+}
+
+// fillLivecommentResponses は、投稿者と配信をまとめて引いて、ライブコメントの一覧をレスポンスの形にします
+func fillLivecommentResponses(ctx context.Context, q sqlx.QueryerContext, livecommentModels []LivecommentModel) ([]Livecomment, error) {
+	userIDs := make([]int64, len(livecommentModels))
+	livestreamIDs := make([]int64, len(livecommentModels))
+	for i, livecommentModel := range livecommentModels {
+		userIDs[i] = livecommentModel.UserID
+		livestreamIDs[i] = livecommentModel.LivestreamID
 	}
-	reporter, err := fillUserResponse(ctx, tx, reporterModel)
+	users, err := loadUsers(ctx, q, userIDs)
 	if err != nil {
-		return LivecommentReport{}, err
+		return nil, err
+	}
+	livestreams, err := loadLivestreams(ctx, q, livestreamIDs)
+	if err != nil {
+		return nil, err
 	}
 
-	livecommentModel := LivecommentModel{}
-	if err := tx.GetContext(ctx, &livecommentModel, "SELECT * FROM livecomments WHERE id = ?", reportModel.LivecommentID); err != nil {
-		return LivecommentReport{}, err
+	livecomments := make([]Livecomment, len(livecommentModels))
+	for i, livecommentModel := range livecommentModels {
+		commentOwner, ok := users[livecommentModel.UserID]
+		if !ok {
+			return nil, sql.ErrNoRows
+		}
+		livestream, ok := livestreams[livecommentModel.LivestreamID]
+		if !ok {
+			return nil, sql.ErrNoRows
+		}
+		livecomments[i] = Livecomment{
+			ID:         livecommentModel.ID,
+			User:       commentOwner,
+			Livestream: livestream,
+			Comment:    livecommentModel.Comment,
+			Tip:        livecommentModel.Tip,
+			CreatedAt:  livecommentModel.CreatedAt,
+		}
 	}
-	livecomment, err := fillLivecommentResponse(ctx, tx, livecommentModel)
+	return livecomments, nil
+}
+
+func fillLivecommentReportResponse(ctx context.Context, q sqlx.QueryerContext, reportModel LivecommentReportModel) (LivecommentReport, error) {
+	reports, err := fillLivecommentReportResponses(ctx, q, []*LivecommentReportModel{&reportModel})
 	if err != nil {
 		return LivecommentReport{}, err
 	}
+	return reports[0], nil
+}
 
-	report := LivecommentReport{
-		ID:          reportModel.ID,
-		Reporter:    reporter,
-		Livecomment: livecomment,
-		CreatedAt:   reportModel.CreatedAt,
+// fillLivecommentReportResponses は、報告者とライブコメントをまとめて引いて、報告の一覧をレスポンスの形にします
+func fillLivecommentReportResponses(ctx context.Context, q sqlx.QueryerContext, reportModels []*LivecommentReportModel) ([]LivecommentReport, error) {
+	reporterIDs := make([]int64, len(reportModels))
+	livecommentIDs := make([]int64, len(reportModels))
+	for i, reportModel := range reportModels {
+		reporterIDs[i] = reportModel.UserID
+		livecommentIDs[i] = reportModel.LivecommentID
+	}
+	reporters, err := loadUsers(ctx, q, reporterIDs)
+	if err != nil {
+		return nil, err
+	}
+	livecomments, err := loadLivecomments(ctx, q, livecommentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]LivecommentReport, len(reportModels))
+	for i, reportModel := range reportModels {
+		reporter, ok := reporters[reportModel.UserID]
+		if !ok {
+			return nil, sql.ErrNoRows
+		}
+		livecomment, ok := livecomments[reportModel.LivecommentID]
+		if !ok {
+			return nil, sql.ErrNoRows
+		}
+		reports[i] = LivecommentReport{
+			ID:          reportModel.ID,
+			Reporter:    reporter,
+			Livecomment: livecomment,
+			CreatedAt:   reportModel.CreatedAt,
+		}
 	}
-	return report, nil
+	return reports, nil
 }
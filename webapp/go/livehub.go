@@ -0,0 +1,261 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	liveEventLivecomment = "livecomment"
+	liveEventSuperchat   = "superchat"
+	liveEventReaction    = "reaction"
+
+	// 視聴者ごとに、送信を待たせておけるメッセージの数
+	// 溢れた視聴者は送信が追いついていないとみなして切断する
+	liveSubscriberBufferSize = 256
+	// 1つのメッセージの書き込みを待つ時間
+	liveWriteWait = 10 * time.Second
+	// この時間内にPongが返らなければ切断する
+	livePongWait = 60 * time.Second
+	// Pingを送る間隔。Pongを待つ時間より短くする
+	livePingPeriod = livePongWait * 9 / 10
+	// 視聴者から受け取るメッセージの大きさの上限。視聴者からはPong以外は送られない想定
+	liveMaxMessageSize = 512
+)
+
+// LiveEvent は、WebSocketで視聴者に届けるメッセージです
+// Typeによって、LivecommentかReactionのどちらかが入ります
+type LiveEvent struct {
+	Type        string       `json:"type"`
+	Livecomment *Livecomment `json:"livecomment,omitempty"`
+	Reaction    *Reaction    `json:"reaction,omitempty"`
+}
+
+// newLivecommentEvent は、チップがあればスーパーチャットとしてライブコメントのLiveEventを作ります
+func newLivecommentEvent(livecomment *Livecomment) LiveEvent {
+	ev := LiveEvent{Type: liveEventLivecomment, Livecomment: livecomment}
+	if livecomment.Tip > 0 {
+		ev.Type = liveEventSuperchat
+	}
+	return ev
+}
+
+// liveMessage は、接続に届けるLiveEventです
+type liveMessage struct {
+	event LiveEvent
+	// eventをJSONにエンコードしたもの
+	data []byte
+}
+
+// liveSubscriber は、配信を視聴しているWebSocketやSSEの接続1つです
+type liveSubscriber struct {
+	messages chan liveMessage
+	// 送信が追いつかないときや初期化したときなど、こちらから切断するときに閉じる
+	evicted   chan struct{}
+	evictOnce sync.Once
+}
+
+func (s *liveSubscriber) evict() {
+	s.evictOnce.Do(func() { close(s.evicted) })
+}
+
+// liveHub は、配信ごとにWebSocket・SSEの接続を束ね、投稿されたライブコメントなどを届けます
+// 届くのは同じアプリケーションサーバで投稿されたものだけなので、複数台で動かすときは取りこぼしを一覧のAPIで補ってください
+type liveHub struct {
+	mu       sync.RWMutex
+	channels map[int64]map[*liveSubscriber]struct{}
+}
+
+var liveHubs = &liveHub{channels: map[int64]map[*liveSubscriber]struct{}{}}
+
+func (h *liveHub) subscribe(livestreamID int64) *liveSubscriber {
+	s := &liveSubscriber{
+		messages: make(chan liveMessage, liveSubscriberBufferSize),
+		evicted:  make(chan struct{}),
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	subscribers, ok := h.channels[livestreamID]
+	if !ok {
+		subscribers = map[*liveSubscriber]struct{}{}
+		h.channels[livestreamID] = subscribers
+	}
+	subscribers[s] = struct{}{}
+	return s
+}
+
+func (h *liveHub) unsubscribe(livestreamID int64, s *liveSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	subscribers := h.channels[livestreamID]
+	delete(subscribers, s)
+	if len(subscribers) == 0 {
+		delete(h.channels, livestreamID)
+	}
+}
+
+// publish は、配信を視聴している接続にevを届けます。書き込みをコミットした後に呼び出してください
+// 投稿したハンドラを待たせないよう、送信が追いついていない接続には届けずに切断します
+func (h *liveHub) publish(livestreamID int64, ev LiveEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	subscribers := h.channels[livestreamID]
+	if len(subscribers) == 0 {
+		return
+	}
+
+	// 視聴者ごとにエンコードしないよう、先に一度だけエンコードする
+	b, err := json.Marshal(ev)
+	if err != nil {
+		appLogger.Warn("failed to encode live event", slog.String("type", ev.Type), slog.String("error", err.Error()))
+		return
+	}
+	message := liveMessage{event: ev, data: b}
+	for s := range subscribers {
+		select {
+		case s.messages <- message:
+		default:
+			s.evict()
+		}
+	}
+}
+
+func (h *liveHub) publishLivecomment(livecomment Livecomment) {
+	h.publish(livecomment.Livestream.ID, newLivecommentEvent(&livecomment))
+}
+
+func (h *liveHub) publishReaction(reaction Reaction) {
+	h.publish(reaction.Livestream.ID, LiveEvent{Type: liveEventReaction, Reaction: &reaction})
+}
+
+// reset は、すべての接続を切断します
+// 初期化の前に投稿されたものが届いたままにならないよう、視聴者には繋ぎ直してもらう
+func (h *liveHub) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, subscribers := range h.channels {
+		for s := range subscribers {
+			s.evict()
+		}
+	}
+	h.channels = map[int64]map[*liveSubscriber]struct{}{}
+}
+
+// livecommentWaiter は、配信ごとに、ライブコメントが投稿されるのを待つリクエストを束ねます
+// 配信ごとの条件変数のように使い、待っている間はDBを読まないようにします
+// 知らせるのは同じアプリケーションサーバで投稿されたものだけなので、他のサーバの投稿は待つ時間が過ぎてから読みます
+type livecommentWaiter struct {
+	mu      sync.Mutex
+	waiters map[int64]chan struct{}
+}
+
+var livecommentWaiters = &livecommentWaiter{waiters: map[int64]chan struct{}{}}
+
+// wait は、配信に次にライブコメントが投稿されたときに閉じるチャネルを返します
+func (w *livecommentWaiter) wait(livestreamID int64) <-chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ch, ok := w.waiters[livestreamID]
+	if !ok {
+		ch = make(chan struct{})
+		w.waiters[livestreamID] = ch
+	}
+	return ch
+}
+
+// notify は、配信にライブコメントが投稿されたことを、待っているすべてのリクエストに知らせます
+func (w *livecommentWaiter) notify(livestreamID int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if ch, ok := w.waiters[livestreamID]; ok {
+		close(ch)
+		delete(w.waiters, livestreamID)
+	}
+}
+
+var liveUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// 配信に投稿されたライブコメント、スーパーチャット、リアクションをWebSocketで受け取る
+// GET /api/livestream/:livestream_id/ws
+func getLivestreamWebSocketHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := verifyUserSession(c); err != nil {
+		// echo.NewHTTPErrorが返っているのでそのまま出力
+		return err
+	}
+
+	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
+	if err != nil {
+		return apperror.BadRequest("livestream_id in path must be integer")
+	}
+
+	var livestreamModel LivestreamModel
+	if err := preparedGet(ctx, readDB(c), &livestreamModel, queryLivestreamByID, livestreamID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperror.NotFound("livestream not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
+	}
+
+	conn, err := liveUpgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		// Upgradeがエラーのレスポンスを書き込んでいる
+		return nil
+	}
+	defer conn.Close()
+
+	s := liveHubs.subscribe(livestreamModel.ID)
+	defer liveHubs.unsubscribe(livestreamModel.ID, s)
+
+	// 視聴者からのメッセージは読み捨てるが、Pongと切断を受け取るために読み続ける
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(liveMaxMessageSize)
+		conn.SetReadDeadline(time.Now().Add(livePongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(livePongWait))
+		})
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(livePingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case message := <-s.messages:
+			conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+			if err := conn.WriteMessage(websocket.TextMessage, message.data); err != nil {
+				return nil
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteWait)); err != nil {
+				return nil
+			}
+		case <-s.evicted:
+			message := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "please reconnect")
+			conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(liveWriteWait))
+			return nil
+		case <-closed:
+			return nil
+		}
+	}
+}
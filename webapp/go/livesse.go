@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// 途中から受け取り直すときに、まとめて送り直すライブコメント・リアクションのそれぞれの数の上限
+	// それより多く取りこぼしたときは、一覧のAPIで取り直してもらう
+	liveReplayLimit = 1000
+	// 接続を保つために、コメント行を送る間隔
+	liveKeepAlivePeriod = 30 * time.Second
+)
+
+// liveEventID は、SSEで送ったライブコメントとリアクションのIDの最大値です
+// Last-Event-IDで受け取り、その続きから送り直します
+// 他のアプリケーションサーバで投稿されたものも送り直せるよう、DBのIDを使います
+type liveEventID struct {
+	livecommentID int64
+	reactionID    int64
+}
+
+func (id liveEventID) String() string {
+	return fmt.Sprintf("%d-%d", id.livecommentID, id.reactionID)
+}
+
+func parseLiveEventID(s string) (liveEventID, error) {
+	var id liveEventID
+	if _, err := fmt.Sscanf(s, "%d-%d", &id.livecommentID, &id.reactionID); err != nil {
+		return liveEventID{}, err
+	}
+	if id.livecommentID < 0 || id.reactionID < 0 {
+		return liveEventID{}, fmt.Errorf("negative id: %s", s)
+	}
+	return id, nil
+}
+
+// covers は、evがidまでに送ったものに含まれるかを返します
+func (id liveEventID) covers(ev LiveEvent) bool {
+	if ev.Livecomment != nil {
+		return ev.Livecomment.ID <= id.livecommentID
+	}
+	if ev.Reaction != nil {
+		return ev.Reaction.ID <= id.reactionID
+	}
+	return false
+}
+
+// advance は、evを送った後のIDを返します
+func (id liveEventID) advance(ev LiveEvent) liveEventID {
+	if ev.Livecomment != nil && ev.Livecomment.ID > id.livecommentID {
+		id.livecommentID = ev.Livecomment.ID
+	}
+	if ev.Reaction != nil && ev.Reaction.ID > id.reactionID {
+		id.reactionID = ev.Reaction.ID
+	}
+	return id
+}
+
+// 配信に投稿されたライブコメント、スーパーチャット、リアクションをSSEで受け取る
+// Last-Event-IDを付けて繋ぎ直すと、その続きから送り直す
+// GET /api/livestream/:livestream_id/events
+func getLivestreamEventsHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := verifyUserSession(c); err != nil {
+		// echo.NewHTTPErrorが返っているのでそのまま出力
+		return err
+	}
+
+	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
+	if err != nil {
+		return apperror.BadRequest("livestream_id in path must be integer")
+	}
+
+	var (
+		lastEventID liveEventID
+		resumed     bool
+	)
+	if h := c.Request().Header.Get("Last-Event-ID"); h != "" {
+		lastEventID, err = parseLiveEventID(h)
+		if err != nil {
+			return apperror.BadRequest("Last-Event-ID header is invalid")
+		}
+		resumed = true
+	}
+
+	var livestreamModel LivestreamModel
+	if err := preparedGet(ctx, readDB(c), &livestreamModel, queryLivestreamByID, livestreamID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperror.NotFound("livestream not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
+	}
+
+	// 送り直す分を読んでいる間に投稿されたものを取りこぼさないよう、先に購読する
+	s := liveHubs.subscribe(livestreamModel.ID)
+	defer liveHubs.unsubscribe(livestreamModel.ID, s)
+
+	// 投稿された直後のものも送り直せるよう、プライマリから読む
+	var replay []LiveEvent
+	if resumed {
+		replay, err = loadLiveEventsSince(ctx, dbConn, livestreamModel.ID, lastEventID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get live events: "+err.Error())
+		}
+	} else {
+		// 初めて繋いだときは、繋いだ後に投稿されたものから送る
+		if err := dbConn.GetContext(ctx, &lastEventID.livecommentID, "SELECT COALESCE(MAX(id), 0) FROM livecomments WHERE livestream_id = ?", livestreamModel.ID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get last livecomment id: "+err.Error())
+		}
+		if err := dbConn.GetContext(ctx, &lastEventID.reactionID, "SELECT COALESCE(MAX(id), 0) FROM reactions WHERE livestream_id = ?", livestreamModel.ID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get last reaction id: "+err.Error())
+		}
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	// nginxがレスポンスを溜め込まないようにする
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	// 送り直したものが購読した分にも届いたときは、二重に送らない
+	sent := lastEventID
+	for _, ev := range replay {
+		sent = sent.advance(ev)
+		if err := writeLiveEvent(res, sent, ev, nil); err != nil {
+			return nil
+		}
+	}
+	replayed := sent
+	res.Flush()
+
+	ticker := time.NewTicker(liveKeepAlivePeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case message := <-s.messages:
+			if replayed.covers(message.event) {
+				continue
+			}
+			sent = sent.advance(message.event)
+			if err := writeLiveEvent(res, sent, message.event, message.data); err != nil {
+				return nil
+			}
+			res.Flush()
+		case <-ticker.C:
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case <-s.evicted:
+			// 送り直せるので、クライアントには繋ぎ直してもらう
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// writeLiveEvent は、evをSSEのイベントとして書き込みます
+// dataが空のときはevをエンコードします
+func writeLiveEvent(res *echo.Response, id liveEventID, ev LiveEvent, data []byte) error {
+	if data == nil {
+		var err error
+		data, err = json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(res, "id: %s\nevent: %s\ndata: %s\n\n", id, ev.Type, data)
+	return err
+}
+
+// loadLiveEventsSince は、配信にsinceより後に投稿されたライブコメントとリアクションを、投稿された順に返します
+func loadLiveEventsSince(ctx context.Context, q sqlx.QueryerContext, livestreamID int64, since liveEventID) ([]LiveEvent, error) {
+	var livecommentModels []LivecommentModel
+	if err := sqlx.SelectContext(ctx, q, &livecommentModels, excludeDeleted("SELECT * FROM livecomments WHERE livestream_id = ? AND id > ? AND hidden_at IS NULL ORDER BY id LIMIT ?", ""), livestreamID, since.livecommentID, liveReplayLimit); err != nil {
+		return nil, err
+	}
+	livecomments, err := fillLivecommentResponses(ctx, q, livecommentModels)
+	if err != nil {
+		return nil, err
+	}
+
+	var reactionModels []ReactionModel
+	if err := sqlx.SelectContext(ctx, q, &reactionModels, "SELECT * FROM reactions WHERE livestream_id = ? AND id > ? ORDER BY id LIMIT ?", livestreamID, since.reactionID, liveReplayLimit); err != nil {
+		return nil, err
+	}
+	reactions, err := fillReactionResponses(ctx, q, reactionModels)
+	if err != nil {
+		return nil, err
+	}
+
+	evs := make([]LiveEvent, 0, len(livecomments)+len(reactions))
+	for i := range livecomments {
+		evs = append(evs, newLivecommentEvent(&livecomments[i]))
+	}
+	for i := range reactions {
+		evs = append(evs, LiveEvent{Type: liveEventReaction, Reaction: &reactions[i]})
+	}
+	sort.SliceStable(evs, func(i, j int) bool {
+		return liveEventCreatedAt(evs[i]) < liveEventCreatedAt(evs[j])
+	})
+	return evs, nil
+}
+
+func liveEventCreatedAt(ev LiveEvent) int64 {
+	if ev.Livecomment != nil {
+		return ev.Livecomment.CreatedAt
+	}
+	return ev.Reaction.CreatedAt
+}
@@ -2,19 +2,48 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
 )
 
+// ライブ配信検索でのタグの組み合わせ方
+const (
+	searchTagModeAnd = "and"
+	searchTagModeOr  = "or"
+)
+
+// ライブ配信の公開範囲
+const (
+	// 一覧・検索・詳細すべてで公開
+	livestreamPrivacyPublic = "public"
+	// 配信者本人のみ閲覧可能
+	livestreamPrivacyPrivate = "private"
+	// 一覧・検索には出さないが、IDを知っていれば閲覧可能
+	livestreamPrivacyUnlisted = "unlisted"
+)
+
+// 配信者の配信一覧で返す、配信の状態
+const (
+	livestreamStatusUpcoming = "upcoming"
+	livestreamStatusLive     = "live"
+	livestreamStatusArchived = "archived"
+)
+
 type ReserveLivestreamRequest struct {
 	Tags         []int64 `json:"tags"`
 	Title        string  `json:"title"`
@@ -23,8 +52,17 @@ type ReserveLivestreamRequest struct {
 	ThumbnailUrl string  `json:"thumbnail_url"`
 	StartAt      int64   `json:"start_at"`
 	EndAt        int64   `json:"end_at"`
+	// 省略時はpublic
+	PrivacyStatus string `json:"privacy_status"`
+}
+
+type PutLivestreamTagsRequest struct {
+	Tags []int64 `json:"tags"`
 }
 
+// ライブ配信に付与できるタグの最大数
+const maxLivestreamTags = 5
+
 type LivestreamViewerModel struct {
 	UserID       int64 `db:"user_id" json:"user_id"`
 	LivestreamID int64 `db:"livestream_id" json:"livestream_id"`
@@ -32,26 +70,56 @@ type LivestreamViewerModel struct {
 }
 
 type LivestreamModel struct {
-	ID           int64  `db:"id" json:"id"`
-	UserID       int64  `db:"user_id" json:"user_id"`
-	Title        string `db:"title" json:"title"`
-	Description  string `db:"description" json:"description"`
-	PlaylistUrl  string `db:"playlist_url" json:"playlist_url"`
-	ThumbnailUrl string `db:"thumbnail_url" json:"thumbnail_url"`
-	StartAt      int64  `db:"start_at" json:"start_at"`
-	EndAt        int64  `db:"end_at" json:"end_at"`
+	ID            int64  `db:"id" json:"id"`
+	UserID        int64  `db:"user_id" json:"user_id"`
+	Title         string `db:"title" json:"title"`
+	Description   string `db:"description" json:"description"`
+	PlaylistUrl   string `db:"playlist_url" json:"playlist_url"`
+	ThumbnailUrl  string `db:"thumbnail_url" json:"thumbnail_url"`
+	StartAt       int64  `db:"start_at" json:"start_at"`
+	EndAt         int64  `db:"end_at" json:"end_at"`
+	PrivacyStatus string `db:"privacy_status" json:"privacy_status"`
+	// スコアの集計
+	TotalTip      int64 `db:"total_tip" json:"total_tip"`
+	ReactionCount int64 `db:"reaction_count" json:"reaction_count"`
+	CommentCount  int64 `db:"comment_count" json:"comment_count"`
 }
 
 type Livestream struct {
-	ID           int64  `json:"id"`
-	Owner        User   `json:"owner"`
-	Title        string `json:"title"`
-	Description  string `json:"description"`
-	PlaylistUrl  string `json:"playlist_url"`
-	ThumbnailUrl string `json:"thumbnail_url"`
-	Tags         []Tag  `json:"tags"`
-	StartAt      int64  `json:"start_at"`
-	EndAt        int64  `json:"end_at"`
+	ID            int64  `json:"id"`
+	Owner         User   `json:"owner"`
+	Title         string `json:"title"`
+	Description   string `json:"description"`
+	PlaylistUrl   string `json:"playlist_url"`
+	ThumbnailUrl  string `json:"thumbnail_url"`
+	Tags          []Tag  `json:"tags"`
+	StartAt       int64  `json:"start_at"`
+	EndAt         int64  `json:"end_at"`
+	PrivacyStatus string `json:"privacy_status"`
+	// 配信詳細でのみ返す
+	PinnedLivecomment *Livecomment `json:"pinned_livecomment,omitempty"`
+	Collaborators     []User       `json:"collaborators,omitempty"`
+	// 配信予約のレスポンスでのみ、配信者本人に返す
+	IngestKey string `json:"ingest_key,omitempty"`
+}
+
+// UserLivestream は、配信者の配信一覧に載せる配信です
+type UserLivestream struct {
+	Livestream
+	// upcoming, live, archivedのいずれか
+	Status string `json:"status"`
+}
+
+// livestreamStatus は、nowの時点での配信の状態を返します
+func livestreamStatus(livestream Livestream, now int64) string {
+	switch {
+	case now < livestream.StartAt:
+		return livestreamStatusUpcoming
+	case now < livestream.EndAt:
+		return livestreamStatusLive
+	default:
+		return livestreamStatusArchived
+	}
 }
 
 type LivestreamTagModel struct {
@@ -83,192 +151,741 @@ func reserveLivestreamHandler(c echo.Context) error {
 
 	var req *ReserveLivestreamRequest
 	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "failed to decode the request body as json")
+		return apperror.BadRequest("failed to decode the request body as json")
 	}
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
+	switch req.PrivacyStatus {
+	case "":
+		req.PrivacyStatus = livestreamPrivacyPublic
+	case livestreamPrivacyPublic, livestreamPrivacyPrivate, livestreamPrivacyUnlisted:
+	default:
+		return apperror.BadRequest("privacy_status must be one of public, private, unlisted")
 	}
-	defer tx.Rollback()
 
-	// 2023/11/25 10:00からの１年間の期間内であるかチェック
 	var (
-		termStartAt    = time.Date(2023, 11, 25, 1, 0, 0, 0, time.UTC)
-		termEndAt      = time.Date(2024, 11, 25, 1, 0, 0, 0, time.UTC)
-		reserveStartAt = time.Unix(req.StartAt, 0)
-		reserveEndAt   = time.Unix(req.EndAt, 0)
+		livestream Livestream
+		// コミット後にタグのキャッシュへ反映する配信数の増減
+		tagCountDeltas map[int64]int64
 	)
-	if (reserveStartAt.Equal(termEndAt) || reserveStartAt.After(termEndAt)) || (reserveEndAt.Equal(termStartAt) || reserveEndAt.Before(termStartAt)) {
-		return echo.NewHTTPError(http.StatusBadRequest, "bad reservation time range")
-	}
+	err := withTx(ctx, func(tx *sqlx.Tx) error {
+		// 2023/11/25 10:00からの１年間の期間内であるかチェック
+		var (
+			termStartAt    = time.Date(2023, 11, 25, 1, 0, 0, 0, time.UTC)
+			termEndAt      = time.Date(2024, 11, 25, 1, 0, 0, 0, time.UTC)
+			reserveStartAt = time.Unix(req.StartAt, 0)
+			reserveEndAt   = time.Unix(req.EndAt, 0)
+		)
+		if (reserveStartAt.Equal(termEndAt) || reserveStartAt.After(termEndAt)) || (reserveEndAt.Equal(termStartAt) || reserveEndAt.Before(termStartAt)) {
+			return apperror.BadRequest("bad reservation time range")
+		}
 
-	// 予約枠をみて、予約が可能か調べる
-	// NOTE: 並列な予約のoverbooking防止にFOR UPDATEが必要
-	var slots []*ReservationSlotModel
-	if err := tx.SelectContext(ctx, &slots, "SELECT * FROM reservation_slots WHERE start_at >= ? AND end_at <= ? FOR UPDATE", req.StartAt, req.EndAt); err != nil {
-		c.Logger().Warnf("予約枠一覧取得でエラー発生: %+v", err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get reservation_slots: "+err.Error())
-	}
-	for _, slot := range slots {
-		var count int
-		if err := tx.GetContext(ctx, &count, "SELECT slot FROM reservation_slots WHERE start_at = ? AND end_at = ?", slot.StartAt, slot.EndAt); err != nil {
+		// 予約枠をみて、予約が可能か調べる
+		// NOTE: 並列な予約のoverbooking防止にFOR UPDATEが必要
+		var slots []*ReservationSlotModel
+		if err := tx.SelectContext(ctx, &slots, "SELECT * FROM reservation_slots WHERE start_at >= ? AND end_at <= ? FOR UPDATE", req.StartAt, req.EndAt); err != nil {
+			requestLogger(c).Warn("予約枠一覧取得でエラー発生", slog.Any("error", err))
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get reservation_slots: "+err.Error())
 		}
-		c.Logger().Infof("%d ~ %d予約枠の残数 = %d\n", slot.StartAt, slot.EndAt, slot.Slot)
-		if count < 1 {
-			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("予約期間 %d ~ %dに対して、予約区間 %d ~ %dが予約できません", termStartAt.Unix(), termEndAt.Unix(), req.StartAt, req.EndAt))
+		for _, slot := range slots {
+			var count int
+			if err := tx.GetContext(ctx, &count, "SELECT slot FROM reservation_slots WHERE start_at = ? AND end_at = ?", slot.StartAt, slot.EndAt); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get reservation_slots: "+err.Error())
+			}
+			requestLogger(c).Debug("予約枠の残数", slog.Int64("start_at", slot.StartAt), slog.Int64("end_at", slot.EndAt), slog.Int64("slot", slot.Slot))
+			if count < 1 {
+				return apperror.BadRequest(fmt.Sprintf("予約期間 %d ~ %dに対して、予約区間 %d ~ %dが予約できません", termStartAt.Unix(), termEndAt.Unix(), req.StartAt, req.EndAt))
+			}
 		}
-	}
 
-	var (
-		livestreamModel = &LivestreamModel{
-			UserID:       int64(userID),
-			Title:        req.Title,
-			Description:  req.Description,
-			PlaylistUrl:  req.PlaylistUrl,
-			ThumbnailUrl: req.ThumbnailUrl,
-			StartAt:      req.StartAt,
-			EndAt:        req.EndAt,
+		var (
+			livestreamModel = &LivestreamModel{
+				UserID:        int64(userID),
+				Title:         req.Title,
+				Description:   req.Description,
+				PlaylistUrl:   req.PlaylistUrl,
+				ThumbnailUrl:  req.ThumbnailUrl,
+				StartAt:       req.StartAt,
+				EndAt:         req.EndAt,
+				PrivacyStatus: req.PrivacyStatus,
+			}
+		)
+
+		if _, err := tx.ExecContext(ctx, "UPDATE reservation_slots SET slot = slot - 1 WHERE start_at >= ? AND end_at <= ?", req.StartAt, req.EndAt); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update reservation_slot: "+err.Error())
 		}
-	)
 
-	if _, err := tx.ExecContext(ctx, "UPDATE reservation_slots SET slot = slot - 1 WHERE start_at >= ? AND end_at <= ?", req.StartAt, req.EndAt); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update reservation_slot: "+err.Error())
+		rs, err := tx.NamedExecContext(ctx, "INSERT INTO livestreams (user_id, title, description, playlist_url, thumbnail_url, start_at, end_at, privacy_status) VALUES(:user_id, :title, :description, :playlist_url, :thumbnail_url, :start_at, :end_at, :privacy_status)", livestreamModel)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert livestream: "+err.Error())
+		}
+
+		livestreamID, err := rs.LastInsertId()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get last inserted livestream id: "+err.Error())
+		}
+		livestreamModel.ID = livestreamID
+
+		// タグ追加
+		tagCountDeltas = map[int64]int64{}
+		for _, tagID := range req.Tags {
+			if _, err := tx.NamedExecContext(ctx, "INSERT INTO livestream_tags (livestream_id, tag_id) VALUES (:livestream_id, :tag_id)", &LivestreamTagModel{
+				LivestreamID: livestreamID,
+				TagID:        tagID,
+			}); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert livestream tag: "+err.Error())
+			}
+			if err := addTagLivestreamCount(ctx, tx, tagID, 1); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to update tag livestream count: "+err.Error())
+			}
+			tagCountDeltas[tagID]++
+		}
+
+		ingestKey, err := rotateIngestKey(ctx, tx, livestreamID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate ingest key: "+err.Error())
+		}
+
+		livestream, err = fillLivestreamResponse(ctx, tx, *livestreamModel)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livestream: "+err.Error())
+		}
+		livestream.IngestKey = ingestKey
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	tagMaster.addLivestreamCounts(tagCountDeltas)
+	events.publish(event{Kind: eventLivestreamReserved, UserID: userID, LivestreamID: livestream.ID})
+
+	return c.JSON(http.StatusCreated, livestream)
+}
+
+// NGワードを含むスーパーチャットの扱い
+const (
+	// 投稿を拒否する
+	superchatNGPolicyReject = "reject"
+	// NGワードを伏せ字にして受け付ける
+	superchatNGPolicyMask = "mask"
+)
+
+type PutLivestreamSettingsRequest struct {
+	// 0で低速モードを解除する
+	SlowModeSeconds int64 `json:"slow_mode_seconds"`
+	// 空の場合はreject
+	SuperchatNGPolicy string `json:"superchat_ng_policy"`
+	// スパムらしさ (0より大きく1以下) がこれ以上のライブコメントを自動で非表示にする。省略すると非表示にしない
+	SpamHideThreshold *float64 `json:"spam_hide_threshold"`
+	// 指定した場合、設定の版がこの値のときのみ更新する。一度も設定していない配信は0
+	// If-Matchヘッダでも指定でき、両方あればヘッダを優先する
+	Version *int64 `json:"version,omitempty"`
+}
+
+type LivestreamSettings struct {
+	LivestreamID      int64    `json:"livestream_id"`
+	SlowModeSeconds   int64    `json:"slow_mode_seconds"`
+	SuperchatNGPolicy string   `json:"superchat_ng_policy"`
+	SpamHideThreshold *float64 `json:"spam_hide_threshold"`
+	// 更新するたびに1増える
+	Version int64 `json:"version"`
+}
+
+// settingsVersionETag は、配信設定の版をETagの形式で返します
+func settingsVersionETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// expectedSettingsVersion は、更新の前提とする配信設定の版を返します。指定がなければnilを返します
+func expectedSettingsVersion(c echo.Context, req *PutLivestreamSettingsRequest) (*int64, error) {
+	header := c.Request().Header.Get("If-Match")
+	if header == "" {
+		return req.Version, nil
+	}
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(strings.TrimSpace(header), "W/"), `"`), 10, 64)
+	if err != nil {
+		return nil, apperror.BadRequest("If-Match header must be a settings version")
+	}
+	return &version, nil
+}
+
+// 配信者による配信設定の更新
+// PUT /api/livestream/:livestream_id/settings
+func putLivestreamSettingsHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+	defer c.Request().Body.Close()
+
+	if err := verifyUserSession(c); err != nil {
+		return err
 	}
 
-	rs, err := tx.NamedExecContext(ctx, "INSERT INTO livestreams (user_id, title, description, playlist_url, thumbnail_url, start_at, end_at) VALUES(:user_id, :title, :description, :playlist_url, :thumbnail_url, :start_at, :end_at)", livestreamModel)
+	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert livestream: "+err.Error())
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 
-	livestreamID, err := rs.LastInsertId()
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	var req *PutLivestreamSettingsRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return apperror.BadRequest("failed to decode the request body as json")
+	}
+	if req.SlowModeSeconds < 0 {
+		return apperror.BadRequest("slow_mode_seconds must not be negative")
+	}
+	switch req.SuperchatNGPolicy {
+	case "":
+		req.SuperchatNGPolicy = superchatNGPolicyReject
+	case superchatNGPolicyReject, superchatNGPolicyMask:
+	default:
+		return apperror.BadRequest("superchat_ng_policy must be reject or mask")
+	}
+	if req.SpamHideThreshold != nil && (*req.SpamHideThreshold <= 0 || *req.SpamHideThreshold > 1) {
+		return apperror.BadRequest("spam_hide_threshold must be greater than 0 and at most 1")
+	}
+	expected, err := expectedSettingsVersion(c, req)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get last inserted livestream id: "+err.Error())
+		return err
 	}
-	livestreamModel.ID = livestreamID
 
-	// タグ追加
-	for _, tagID := range req.Tags {
-		if _, err := tx.NamedExecContext(ctx, "INSERT INTO livestream_tags (livestream_id, tag_id) VALUES (:livestream_id, :tag_id)", &LivestreamTagModel{
-			LivestreamID: livestreamID,
-			TagID:        tagID,
-		}); err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert livestream tag: "+err.Error())
+	var version int64
+	err = withTx(ctx, func(tx *sqlx.Tx) error {
+		var livestreamModel LivestreamModel
+		if err := preparedGet(ctx, tx, &livestreamModel, queryLivestreamByID, livestreamID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return apperror.NotFound("livestream not found")
+			} else {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
+			}
 		}
-	}
 
-	livestream, err := fillLivestreamResponse(ctx, tx, *livestreamModel)
+		if livestreamModel.UserID != userID {
+			return apperror.Forbidden("can't edit settings of other streamer's livestream")
+		}
+
+		// 同時に更新されても版を飛ばさないよう、行をロックしてから読む
+		// まだ行がなければ、同時に挿入しようとした一方はデッドロックになり、やり直したときに版の不一致を検出する
+		var current int64
+		if err := tx.GetContext(ctx, &current, "SELECT version FROM livestream_settings WHERE livestream_id = ? FOR UPDATE", livestreamID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream settings: "+err.Error())
+		}
+		if expected != nil && *expected != current {
+			return apperror.PreconditionFailed(fmt.Sprintf("livestream settings have been updated (current version is %d)", current))
+		}
+
+		if _, err := tx.ExecContext(ctx, "INSERT INTO livestream_settings (livestream_id, slow_mode_seconds, superchat_ng_policy, spam_hide_threshold, version) VALUES (?, ?, ?, ?, 1) ON DUPLICATE KEY UPDATE slow_mode_seconds = VALUES(slow_mode_seconds), superchat_ng_policy = VALUES(superchat_ng_policy), spam_hide_threshold = VALUES(spam_hide_threshold), version = version + 1", livestreamID, req.SlowModeSeconds, req.SuperchatNGPolicy, req.SpamHideThreshold); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update livestream settings: "+err.Error())
+		}
+		version = current + 1
+		return nil
+	})
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livestream: "+err.Error())
+		return err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+	// コミットできてから反映する
+	slowMode.setInterval(int64(livestreamID), req.SlowModeSeconds)
+
+	c.Response().Header().Set("ETag", settingsVersionETag(version))
+	return c.JSON(http.StatusOK, &LivestreamSettings{
+		LivestreamID:      int64(livestreamID),
+		SlowModeSeconds:   req.SlowModeSeconds,
+		SuperchatNGPolicy: req.SuperchatNGPolicy,
+		SpamHideThreshold: req.SpamHideThreshold,
+		Version:           version,
+	})
+}
+
+// getSuperchatNGPolicy は、配信のNGワードを含むスーパーチャットの扱いを返します
+func getSuperchatNGPolicy(ctx context.Context, q sqlx.QueryerContext, livestreamID int64) (string, error) {
+	var policy string
+	if err := sqlx.GetContext(ctx, q, &policy, "SELECT superchat_ng_policy FROM livestream_settings WHERE livestream_id = ?", livestreamID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return superchatNGPolicyReject, nil
+		}
+		return "", err
 	}
+	return policy, nil
+}
 
-	return c.JSON(http.StatusCreated, livestream)
+// getSpamHideThreshold は、ライブコメントを自動で非表示にするスパムらしさの閾値を返します
+// 設定していなければ、Validがfalseです
+func getSpamHideThreshold(ctx context.Context, q sqlx.QueryerContext, livestreamID int64) (sql.NullFloat64, error) {
+	var threshold sql.NullFloat64
+	if err := sqlx.GetContext(ctx, q, &threshold, "SELECT spam_hide_threshold FROM livestream_settings WHERE livestream_id = ?", livestreamID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return sql.NullFloat64{}, nil
+		}
+		return sql.NullFloat64{}, err
+	}
+	return threshold, nil
 }
 
-func searchLivestreamsHandler(c echo.Context) error {
+// 配信者によるライブ配信タグの付け替え
+// PUT /api/livestream/:livestream_id/tags
+func putLivestreamTagsHandler(c echo.Context) error {
 	ctx := c.Request().Context()
-	keyTagName := c.QueryParam("tag")
+	defer c.Request().Body.Close()
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
+	if err := verifyUserSession(c); err != nil {
+		return err
+	}
+
+	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
-	defer tx.Rollback()
 
-	var livestreamModels []*LivestreamModel
-	if c.QueryParam("tag") != "" {
-		// タグによる取得
-		var tagIDList []int
-		if err := tx.SelectContext(ctx, &tagIDList, "SELECT id FROM tags WHERE name = ?", keyTagName); err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get tags: "+err.Error())
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	var req *PutLivestreamTagsRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return apperror.BadRequest("failed to decode the request body as json")
+	}
+
+	// 重複指定は一つにまとめる
+	tagIDs := make([]int64, 0, len(req.Tags))
+	seen := make(map[int64]struct{}, len(req.Tags))
+	for _, tagID := range req.Tags {
+		if _, ok := seen[tagID]; ok {
+			continue
+		}
+		seen[tagID] = struct{}{}
+		tagIDs = append(tagIDs, tagID)
+	}
+	if len(tagIDs) > maxLivestreamTags {
+		return apperror.BadRequest(fmt.Sprintf("the number of tags must be less than or equal to %d", maxLivestreamTags))
+	}
+
+	var (
+		livestream Livestream
+		// コミット後にタグのキャッシュへ反映する配信数の増減
+		tagCountDeltas map[int64]int64
+	)
+	err = withTx(ctx, func(tx *sqlx.Tx) error {
+		var livestreamModel LivestreamModel
+		if err := tx.GetContext(ctx, &livestreamModel, "SELECT * FROM livestreams WHERE id = ? FOR UPDATE", livestreamID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return apperror.NotFound("livestream not found")
+			} else {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
+			}
+		}
+
+		if livestreamModel.UserID != userID {
+			return apperror.Forbidden("can't edit tags of other streamer's livestream")
+		}
+
+		// マスタに存在するタグかを検証
+		for _, tagID := range tagIDs {
+			if _, err := tagMaster.getByID(ctx, tx, tagID); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return apperror.BadRequest("tags contain an unknown tag id")
+				}
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get tag: "+err.Error())
+			}
+		}
+
+		tagCountDeltas = map[int64]int64{}
+
+		var oldLivestreamTagModels []*LivestreamTagModel
+		if err := tx.SelectContext(ctx, &oldLivestreamTagModels, "SELECT * FROM livestream_tags WHERE livestream_id = ?", livestreamID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get old livestream tags: "+err.Error())
+		}
+		for _, livestreamTagModel := range oldLivestreamTagModels {
+			if err := addTagLivestreamCount(ctx, tx, livestreamTagModel.TagID, -1); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to update tag livestream count: "+err.Error())
+			}
+			tagCountDeltas[livestreamTagModel.TagID]--
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM livestream_tags WHERE livestream_id = ?", livestreamID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete old livestream tags: "+err.Error())
+		}
+		for _, tagID := range tagIDs {
+			if _, err := tx.NamedExecContext(ctx, "INSERT INTO livestream_tags (livestream_id, tag_id) VALUES (:livestream_id, :tag_id)", &LivestreamTagModel{
+				LivestreamID: int64(livestreamID),
+				TagID:        tagID,
+			}); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert livestream tag: "+err.Error())
+			}
+			if err := addTagLivestreamCount(ctx, tx, tagID, 1); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to update tag livestream count: "+err.Error())
+			}
+			tagCountDeltas[tagID]++
 		}
 
-		query, params, err := sqlx.In("SELECT * FROM livestream_tags WHERE tag_id IN (?) ORDER BY livestream_id DESC", tagIDList)
+		var err error
+		livestream, err = fillLivestreamResponse(ctx, tx, livestreamModel)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to construct IN query: "+err.Error())
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livestream: "+err.Error())
 		}
-		var keyTaggedLivestreams []*LivestreamTagModel
-		if err := tx.SelectContext(ctx, &keyTaggedLivestreams, query, params...); err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get keyTaggedLivestreams: "+err.Error())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	tagMaster.addLivestreamCounts(tagCountDeltas)
+
+	return c.JSON(http.StatusOK, livestream)
+}
+
+func searchLivestreamsHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	// ?tag=a&tag=b のように複数指定でき、tag_modeで組み合わせ方を指定する
+	var keyTagNames []string
+	seen := make(map[string]struct{})
+	for _, tagName := range c.QueryParams()["tag"] {
+		if _, ok := seen[tagName]; tagName == "" || ok {
+			continue
 		}
+		seen[tagName] = struct{}{}
+		keyTagNames = append(keyTagNames, tagName)
+	}
+	tagMode := c.QueryParam("tag_mode")
+	if tagMode == "" {
+		tagMode = searchTagModeOr
+	}
+	if tagMode != searchTagModeAnd && tagMode != searchTagModeOr {
+		return apperror.BadRequest("tag_mode query parameter must be and or or")
+	}
 
-		for _, keyTaggedLivestream := range keyTaggedLivestreams {
-			ls := LivestreamModel{}
-			if err := tx.GetContext(ctx, &ls, "SELECT * FROM livestreams WHERE id = ?", keyTaggedLivestream.LivestreamID); err != nil {
-				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestreams: "+err.Error())
+	db := readDB(c)
+
+	var livestreamModels []*LivestreamModel
+	if len(keyTagNames) > 0 {
+		// タグによる取得
+		// タグ名はキャッシュしているマスタからIDに解決し、tagsテーブルとのJOINを省く
+		tagIDs := make([]int64, 0, len(keyTagNames))
+		for _, tagName := range keyTagNames {
+			tag, ok, err := tagMaster.getByName(ctx, db, tagName)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get tag: "+err.Error())
 			}
+			if ok {
+				tagIDs = append(tagIDs, tag.ID)
+			}
+		}
+		// 存在しないタグが条件を満たすことはない
+		if len(tagIDs) == 0 || (tagMode == searchTagModeAnd && len(tagIDs) != len(keyTagNames)) {
+			return c.JSON(http.StatusOK, []Livestream{})
+		}
 
-			livestreamModels = append(livestreamModels, &ls)
+		var (
+			query  string
+			params []interface{}
+			err    error
+		)
+		if tagMode == searchTagModeAnd {
+			// タグごとにJOINして、すべてのタグを持つ配信に絞り込む
+			query = "SELECT l.* FROM livestreams l"
+			for i, tagID := range tagIDs {
+				query += fmt.Sprintf(" INNER JOIN livestream_tags lt%[1]d ON lt%[1]d.livestream_id = l.id AND lt%[1]d.tag_id = ?", i)
+				params = append(params, tagID)
+			}
+			// 検索結果には公開配信のみ含める
+			query += " WHERE l.privacy_status = ? GROUP BY l.id ORDER BY l.id DESC"
+			params = append(params, livestreamPrivacyPublic)
+		} else {
+			query, params, err = sqlx.In(`
+			SELECT DISTINCT l.* FROM livestreams l
+			INNER JOIN livestream_tags lt ON lt.livestream_id = l.id
+			WHERE lt.tag_id IN (?) AND l.privacy_status = ?
+			ORDER BY l.id DESC`, tagIDs, livestreamPrivacyPublic)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to construct IN query: "+err.Error())
+			}
+		}
+
+		if err := db.SelectContext(ctx, &livestreamModels, query, params...); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestreams: "+err.Error())
 		}
 	} else {
 		// 検索条件なし
-		query := `SELECT * FROM livestreams ORDER BY id DESC`
+		query := `SELECT * FROM livestreams WHERE privacy_status = ?`
+		params := []interface{}{livestreamPrivacyPublic}
+		cur, ok, err := cursorParam(c)
+		if err != nil {
+			return err
+		}
+		if ok {
+			cond, args := cur.condition("", "id", true)
+			query += " AND " + cond
+			params = append(params, args...)
+		}
+		query += " ORDER BY id DESC"
+		limit := -1
 		if c.QueryParam("limit") != "" {
-			limit, err := strconv.Atoi(c.QueryParam("limit"))
+			limit, err = strconv.Atoi(c.QueryParam("limit"))
 			if err != nil {
-				return echo.NewHTTPError(http.StatusBadRequest, "limit query parameter must be integer")
+				return apperror.BadRequest("limit query parameter must be integer")
 			}
 			query += fmt.Sprintf(" LIMIT %d", limit)
 		}
 
-		if err := tx.SelectContext(ctx, &livestreamModels, query); err != nil {
+		if err := db.SelectContext(ctx, &livestreamModels, query, params...); err != nil {
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestreams: "+err.Error())
 		}
+		setNextCursor(c, len(livestreamModels), limit, func() cursor {
+			return cursor{id: livestreamModels[len(livestreamModels)-1].ID}
+		})
 	}
 
-	livestreams := make([]Livestream, len(livestreamModels))
-	for i := range livestreamModels {
-		livestream, err := fillLivestreamResponse(ctx, tx, *livestreamModels[i])
+	livestreams, err := fillLivestreamResponses(ctx, db, livestreamModels)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livestream: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, livestreams)
+}
+
+// 配信開始前の予約済みライブ配信一覧取得API
+// GET /api/livestream/upcoming
+func getUpcomingLivestreamsHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+	keyTagName := c.QueryParam("tag")
+	now := time.Now().Unix()
+
+	db := readDB(c)
+
+	var (
+		query  string
+		params []interface{}
+	)
+	if keyTagName != "" {
+		// タグによる絞り込み
+		tag, ok, err := tagMaster.getByName(ctx, db, keyTagName)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livestream: "+err.Error())
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get tag: "+err.Error())
+		}
+		if !ok {
+			return c.JSON(http.StatusOK, []Livestream{})
+		}
+		query = `
+		SELECT l.* FROM livestreams l
+		INNER JOIN livestream_tags lt ON lt.livestream_id = l.id
+		WHERE lt.tag_id = ? AND l.start_at > ? AND l.privacy_status = ?`
+		params = []interface{}{tag.ID, now, livestreamPrivacyPublic}
+	} else {
+		query = "SELECT l.* FROM livestreams l WHERE l.start_at > ? AND l.privacy_status = ?"
+		params = []interface{}{now, livestreamPrivacyPublic}
+	}
+	cur, ok, err := cursorParam(c)
+	if err != nil {
+		return err
+	}
+	if ok {
+		cond, args := cur.condition("l.start_at", "l.id", false)
+		query += " AND " + cond
+		params = append(params, args...)
+	}
+	query += " ORDER BY l.start_at ASC, l.id ASC"
+	limit := -1
+	if c.QueryParam("limit") != "" {
+		limit, err = strconv.Atoi(c.QueryParam("limit"))
+		if err != nil {
+			return apperror.BadRequest("limit query parameter must be integer")
 		}
-		livestreams[i] = livestream
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	var livestreamModels []*LivestreamModel
+	if err := db.SelectContext(ctx, &livestreamModels, query, params...); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get upcoming livestreams: "+err.Error())
 	}
+	setNextCursor(c, len(livestreamModels), limit, func() cursor {
+		last := livestreamModels[len(livestreamModels)-1]
+		return cursor{sortKey: last.StartAt, id: last.ID}
+	})
 
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+	livestreams, err := fillLivestreamResponses(ctx, db, livestreamModels)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livestream: "+err.Error())
 	}
 
 	return c.JSON(http.StatusOK, livestreams)
 }
 
-func getMyLivestreamsHandler(c echo.Context) error {
+// トレンドの配信一覧のデフォルトの件数
+const defaultTrendingLimit = 10
+
+type TrendingLivestream struct {
+	Livestream
+	Score float64 `json:"score"`
+}
+
+// 直近の盛り上がりによるトレンド配信一覧
+// GET /api/livestream/trending
+func getTrendingLivestreamsHandler(c echo.Context) error {
 	ctx := c.Request().Context()
-	if err := verifyUserSession(c); err != nil {
+	now := time.Now()
+
+	limit := defaultTrendingLimit
+	if c.QueryParam("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(c.QueryParam("limit"))
+		if err != nil {
+			return apperror.BadRequest("limit query parameter must be integer")
+		}
+	}
+
+	livestreams, err := listTrendingLivestreams(ctx, readDB(c), now, limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, livestreams)
+}
+
+// listTrendingLivestreams は、配信中の公開配信をスコアの高い順に最大limit件返します。limitが負のときはすべて返します
+func listTrendingLivestreams(ctx context.Context, db sqlx.QueryerContext, now time.Time, limit int) ([]TrendingLivestream, error) {
+	// スコアはメモリ上で集計済みなので、スコアが付いている配信のみを取得する
+	scores := trending.scores(now)
+	livestreams := []TrendingLivestream{}
+	if len(scores) == 0 {
+		return livestreams, nil
+	}
+	livestreamIDs := make([]int64, 0, len(scores))
+	for livestreamID := range scores {
+		livestreamIDs = append(livestreamIDs, livestreamID)
+	}
+
+	// 配信中のもののみ
+	query, params, err := sqlx.In("SELECT * FROM livestreams WHERE id IN (?) AND start_at <= ? AND ? < end_at AND privacy_status = ?", livestreamIDs, now.Unix(), now.Unix(), livestreamPrivacyPublic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct IN query: %w", err)
+	}
+	var livestreamModels []*LivestreamModel
+	if err := sqlx.SelectContext(ctx, db, &livestreamModels, query, params...); err != nil {
+		return nil, fmt.Errorf("failed to get livestreams: %w", err)
+	}
+
+	sort.Slice(livestreamModels, func(i, j int) bool {
+		si, sj := scores[livestreamModels[i].ID], scores[livestreamModels[j].ID]
+		if si != sj {
+			return si > sj
+		}
+		return livestreamModels[i].ID < livestreamModels[j].ID
+	})
+	if limit >= 0 && len(livestreamModels) > limit {
+		livestreamModels = livestreamModels[:limit]
+	}
+
+	filled, err := fillLivestreamResponses(ctx, db, livestreamModels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fill livestream: %w", err)
+	}
+	for _, livestream := range filled {
+		livestreams = append(livestreams, TrendingLivestream{
+			Livestream: livestream,
+			Score:      scores[livestream.ID],
+		})
+	}
+	return livestreams, nil
+}
+
+// ランキングのレスポンスを使い回す期間
+// 順位自体はバックグラウンドで定期的に算出しているので、その間隔より短くする
+const livestreamRankingMemoTTL = 500 * time.Millisecond
+
+// offset:limit => ランキングのレスポンス
+var livestreamRankingMemo = newMemo[[]LivestreamRankingResponseEntry]("livestream_ranking", livestreamRankingMemoTTL)
+
+type LivestreamRankingResponseEntry struct {
+	Rank       int64      `json:"rank"`
+	Score      int64      `json:"score"`
+	Livestream Livestream `json:"livestream"`
+}
+
+// ライブ配信ランキング
+// GET /api/livestream/ranking?limit=&offset=
+func getLivestreamRankingHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	// 指定がなければ全件返す
+	limit := -1
+	if c.QueryParam("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(c.QueryParam("limit"))
+		if err != nil || limit < 0 {
+			return apperror.BadRequest("limit query parameter must be non-negative integer")
+		}
+	}
+	offset := 0
+	if c.QueryParam("offset") != "" {
+		var err error
+		offset, err = strconv.Atoi(c.QueryParam("offset"))
+		if err != nil || offset < 0 {
+			return apperror.BadRequest("offset query parameter must be non-negative integer")
+		}
+	}
+
+	db := readDB(c)
+
+	ranking, err := livestreamRankingMemo.do(ctx, fmt.Sprintf("%d:%d", offset, limit), func(ctx context.Context) ([]LivestreamRankingResponseEntry, error) {
+		return buildLivestreamRanking(ctx, db, offset, limit)
+	})
+	if err != nil {
 		return err
 	}
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
+	return c.JSON(http.StatusOK, ranking)
+}
+
+// buildLivestreamRanking は、ランキングの指定された範囲をレスポンスの形にします
+func buildLivestreamRanking(ctx context.Context, db *sqlx.DB, offset, limit int) ([]LivestreamRankingResponseEntry, error) {
+	// 順位はバックグラウンドで定期的に算出したものを使う
+	entries := livestreamRanking.page(offset, limit)
+
+	livestreamIDs := make([]int64, len(entries))
+	for i, entry := range entries {
+		livestreamIDs[i] = entry.LivestreamID
+	}
+	livestreams, err := loadLivestreams(ctx, db, livestreamIDs)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestreams: "+err.Error())
+	}
+
+	ranking := make([]LivestreamRankingResponseEntry, len(entries))
+	for i, entry := range entries {
+		livestream, ok := livestreams[entry.LivestreamID]
+		if !ok {
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+sql.ErrNoRows.Error())
+		}
+		ranking[i] = LivestreamRankingResponseEntry{
+			Rank:       entry.Rank,
+			Score:      entry.Score,
+			Livestream: livestream,
+		}
+	}
+	return ranking, nil
+}
+
+func getMyLivestreamsHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+	if err := verifyUserSession(c); err != nil {
+		return err
 	}
-	defer tx.Rollback()
 
 	// error already checked
 	sess, _ := session.Get(defaultSessionIDKey, c)
 	// existence already checked
 	userID := sess.Values[defaultUserIDKey].(int64)
 
+	db := readDB(c)
+
 	var livestreamModels []*LivestreamModel
-	if err := tx.SelectContext(ctx, &livestreamModels, "SELECT * FROM livestreams WHERE user_id = ?", userID); err != nil {
+	if err := db.SelectContext(ctx, &livestreamModels, "SELECT * FROM livestreams WHERE user_id = ?", userID); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestreams: "+err.Error())
 	}
-	livestreams := make([]Livestream, len(livestreamModels))
-	for i := range livestreamModels {
-		livestream, err := fillLivestreamResponse(ctx, tx, *livestreamModels[i])
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livestream: "+err.Error())
-		}
-		livestreams[i] = livestream
-	}
-
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+	livestreams, err := fillLivestreamResponses(ctx, db, livestreamModels)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livestream: "+err.Error())
 	}
 
 	return c.JSON(http.StatusOK, livestreams)
@@ -282,44 +899,87 @@ func getUserLivestreamsHandler(c echo.Context) error {
 
 	username := c.Param("username")
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
-	}
-	defer tx.Rollback()
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	db := readDB(c)
 
-	var user UserModel
-	if err := tx.GetContext(ctx, &user, "SELECT * FROM users WHERE name = ?", username); err != nil {
+	user, err := userProfiles.getByName(ctx, db, username)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return echo.NewHTTPError(http.StatusNotFound, "user not found")
+			return apperror.NotFound("user not found")
 		} else {
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user: "+err.Error())
 		}
 	}
 
-	var livestreamModels []*LivestreamModel
-	if err := tx.SelectContext(ctx, &livestreamModels, "SELECT * FROM livestreams WHERE user_id = ?", user.ID); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestreams: "+err.Error())
+	// 配信者本人以外には公開配信のみ見せる
+	now := time.Now().Unix()
+	query := "SELECT * FROM livestreams WHERE user_id = ?"
+	params := []interface{}{user.ID}
+	if user.ID != userID {
+		query += " AND privacy_status = ?"
+		params = append(params, livestreamPrivacyPublic)
 	}
-	livestreams := make([]Livestream, len(livestreamModels))
-	for i := range livestreamModels {
-		livestream, err := fillLivestreamResponse(ctx, tx, *livestreamModels[i])
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livestream: "+err.Error())
+	switch c.QueryParam("status") {
+	case "":
+	case livestreamStatusUpcoming:
+		query += " AND ? < start_at"
+		params = append(params, now)
+	case livestreamStatusLive:
+		query += " AND start_at <= ? AND ? < end_at"
+		params = append(params, now, now)
+	case livestreamStatusArchived:
+		query += " AND end_at <= ?"
+		params = append(params, now)
+	default:
+		return apperror.BadRequest("status query parameter must be one of upcoming, live and archived")
+	}
+	// 新しい配信から順に返す
+	cur, ok, err := cursorParam(c)
+	if err != nil {
+		return err
+	}
+	if ok {
+		cond, args := cur.condition("start_at", "id", true)
+		query += " AND " + cond
+		params = append(params, args...)
+	}
+	query += " ORDER BY start_at DESC, id DESC"
+	limit := -1
+	if c.QueryParam("limit") != "" {
+		limit, err = strconv.Atoi(c.QueryParam("limit"))
+		if err != nil || limit < 0 {
+			return apperror.BadRequest("limit query parameter must be non-negative integer")
 		}
-		livestreams[i] = livestream
+		query += fmt.Sprintf(" LIMIT %d", limit)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+	var livestreamModels []*LivestreamModel
+	if err := db.SelectContext(ctx, &livestreamModels, query, params...); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestreams: "+err.Error())
 	}
+	setNextCursor(c, len(livestreamModels), limit, func() cursor {
+		last := livestreamModels[len(livestreamModels)-1]
+		return cursor{sortKey: last.StartAt, id: last.ID}
+	})
 
-	return c.JSON(http.StatusOK, livestreams)
+	livestreams, err := fillLivestreamResponses(ctx, db, livestreamModels)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livestream: "+err.Error())
+	}
+	userLivestreams := make([]UserLivestream, len(livestreams))
+	for i, livestream := range livestreams {
+		userLivestreams[i] = UserLivestream{Livestream: livestream, Status: livestreamStatus(livestream, now)}
+	}
+
+	return c.JSON(http.StatusOK, userLivestreams)
 }
 
 // viewerテーブルの廃止
 func enterLivestreamHandler(c echo.Context) error {
-	ctx := c.Request().Context()
 	if err := verifyUserSession(c); err != nil {
 		// echo.NewHTTPErrorが返っているのでそのまま出力
 		return err
@@ -332,34 +992,136 @@ func enterLivestreamHandler(c echo.Context) error {
 
 	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "livestream_id must be integer")
+		return apperror.BadRequest("livestream_id must be integer")
 	}
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
+	// 書き込みはまとめて後から行う
+	viewerEventWriter.add(viewerEvent{
+		viewer: LivestreamViewerModel{
+			UserID:       int64(userID),
+			LivestreamID: int64(livestreamID),
+			CreatedAt:    time.Now().Unix(),
+		},
+		enter: true,
+	})
+
+	return c.NoContent(http.StatusOK)
+}
+
+// 視聴履歴のデフォルトの件数
+const defaultWatchHistoryLimit = 20
+
+type WatchHistoryEntry struct {
+	Livestream Livestream `json:"livestream"`
+	WatchedAt  int64      `json:"watched_at"`
+}
+
+// 視聴履歴 (配信ごとに最後に視聴した日時の新しい順)
+// GET /api/user/me/history?limit=&offset=
+func getMyWatchHistoryHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+	if err := verifyUserSession(c); err != nil {
+		// echo.NewHTTPErrorが返っているのでそのまま出力
+		return err
 	}
-	defer tx.Rollback()
 
-	viewer := LivestreamViewerModel{
-		UserID:       int64(userID),
-		LivestreamID: int64(livestreamID),
-		CreatedAt:    time.Now().Unix(),
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	limit := defaultWatchHistoryLimit
+	if c.QueryParam("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(c.QueryParam("limit"))
+		if err != nil || limit < 0 {
+			return apperror.BadRequest("limit query parameter must be non-negative integer")
+		}
+	}
+	offset := 0
+	if c.QueryParam("offset") != "" {
+		var err error
+		offset, err = strconv.Atoi(c.QueryParam("offset"))
+		if err != nil || offset < 0 {
+			return apperror.BadRequest("offset query parameter must be non-negative integer")
+		}
 	}
 
-	if _, err := tx.NamedExecContext(ctx, "INSERT INTO livestream_viewers_history (user_id, livestream_id, created_at) VALUES(:user_id, :livestream_id, :created_at)", viewer); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert livestream_view_history: "+err.Error())
+	db := readDB(c)
+
+	type watchedLivestream struct {
+		LivestreamID int64 `db:"livestream_id"`
+		WatchedAt    int64 `db:"watched_at"`
+	}
+	var watched []watchedLivestream
+	query := `
+	SELECT livestream_id, MAX(created_at) AS watched_at
+	FROM watch_history
+	WHERE user_id = ?
+	GROUP BY livestream_id`
+	params := []interface{}{userID}
+	// cursorを指定したときは、offsetは無視する
+	cur, ok, err := cursorParam(c)
+	if err != nil {
+		return err
+	}
+	if ok {
+		cond, args := cur.condition("watched_at", "livestream_id", true)
+		query += " HAVING " + cond
+		params = append(params, args...)
+		offset = 0
+	}
+	query += " ORDER BY watched_at DESC, livestream_id DESC LIMIT ? OFFSET ?"
+	params = append(params, limit, offset)
+	if err := db.SelectContext(ctx, &watched, query, params...); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get watch history: "+err.Error())
+	}
+	// 非公開になった配信を除く前の、取得した行の位置を返す
+	setNextCursor(c, len(watched), limit, func() cursor {
+		last := watched[len(watched)-1]
+		return cursor{sortKey: last.WatchedAt, id: last.LivestreamID}
+	})
+
+	livestreamIDs := make([]int64, len(watched))
+	for i, w := range watched {
+		livestreamIDs[i] = w.LivestreamID
+	}
+	livestreamModels, err := loadLivestreamModels(ctx, db, livestreamIDs)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
 	}
 
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+	visibleModels := make([]*LivestreamModel, 0, len(watched))
+	watchedAt := make([]int64, 0, len(watched))
+	for _, w := range watched {
+		livestreamModel, ok := livestreamModels[w.LivestreamID]
+		if !ok {
+			continue
+		}
+		// 視聴後に非公開になった配信は含めない
+		if !isLivestreamVisible(*livestreamModel, userID) {
+			continue
+		}
+		visibleModels = append(visibleModels, livestreamModel)
+		watchedAt = append(watchedAt, w.WatchedAt)
+	}
+	livestreams, err := fillLivestreamResponses(ctx, db, visibleModels)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livestream: "+err.Error())
 	}
 
-	return c.NoContent(http.StatusOK)
+	history := make([]WatchHistoryEntry, len(livestreams))
+	for i, livestream := range livestreams {
+		history[i] = WatchHistoryEntry{
+			Livestream: livestream,
+			WatchedAt:  watchedAt[i],
+		}
+	}
+
+	return c.JSON(http.StatusOK, history)
 }
 
 func exitLivestreamHandler(c echo.Context) error {
-	ctx := c.Request().Context()
 	if err := verifyUserSession(c); err != nil {
 		// echo.NewHTTPErrorが返っているのでそのまま出力
 		return err
@@ -372,24 +1134,71 @@ func exitLivestreamHandler(c echo.Context) error {
 
 	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "livestream_id in path must be integer")
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
-	}
-	defer tx.Rollback()
+	// 書き込みはまとめて後から行う
+	// 入室と同じキューに積むので、先に積んだ入室より後に反映される
+	viewerEventWriter.add(viewerEvent{
+		viewer: LivestreamViewerModel{
+			UserID:       userID,
+			LivestreamID: int64(livestreamID),
+		},
+		enter: false,
+	})
 
-	if _, err := tx.ExecContext(ctx, "DELETE FROM livestream_viewers_history WHERE user_id = ? AND livestream_id = ?", userID, livestreamID); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete livestream_view_history: "+err.Error())
-	}
+	return c.NoContent(http.StatusOK)
+}
 
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
-	}
+// viewerEvent は、書き込み待ちの入室・退室です
+type viewerEvent struct {
+	viewer LivestreamViewerModel
+	// falseなら退室
+	enter bool
+}
 
-	return c.NoContent(http.StatusOK)
+var viewerEventWriter = newBatchWriter("viewer events", writeViewerEvents)
+
+// writeViewerEvents は、入室・退室をまとめて書き込みます
+// 入室と退室の順序が入れ替わらないよう、同じ種類が続く間だけを1つのクエリにまとめます
+func writeViewerEvents(ctx context.Context, events []viewerEvent) error {
+	return withTx(ctx, func(tx *sqlx.Tx) error {
+		for start := 0; start < len(events); {
+			end := start + 1
+			for end < len(events) && events[end].enter == events[start].enter {
+				end++
+			}
+
+			viewers := make([]LivestreamViewerModel, end-start)
+			for i, event := range events[start:end] {
+				viewers[i] = event.viewer
+			}
+			if events[start].enter {
+				if _, err := tx.NamedExecContext(ctx, "INSERT INTO livestream_viewers_history (user_id, livestream_id, created_at) VALUES(:user_id, :livestream_id, :created_at)", viewers); err != nil {
+					return fmt.Errorf("failed to insert livestream_view_history: %w", err)
+				}
+				if _, err := tx.NamedExecContext(ctx, "INSERT INTO watch_history (user_id, livestream_id, created_at) VALUES(:user_id, :livestream_id, :created_at)", viewers); err != nil {
+					return fmt.Errorf("failed to insert watch_history: %w", err)
+				}
+			} else {
+				query := "DELETE FROM livestream_viewers_history WHERE (user_id, livestream_id) IN ("
+				params := make([]interface{}, 0, len(viewers)*2)
+				for i, viewer := range viewers {
+					if i > 0 {
+						query += ", "
+					}
+					query += "(?, ?)"
+					params = append(params, viewer.UserID, viewer.LivestreamID)
+				}
+				query += ")"
+				if _, err := tx.ExecContext(ctx, query, params...); err != nil {
+					return fmt.Errorf("failed to delete livestream_view_history: %w", err)
+				}
+			}
+			start = end
+		}
+		return nil
+	})
 }
 
 func getLivestreamHandler(c echo.Context) error {
@@ -401,31 +1210,61 @@ func getLivestreamHandler(c echo.Context) error {
 
 	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "livestream_id in path must be integer")
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
-	}
-	defer tx.Rollback()
+	db := readDB(c)
 
 	livestreamModel := LivestreamModel{}
-	err = tx.GetContext(ctx, &livestreamModel, "SELECT * FROM livestreams WHERE id = ?", livestreamID)
+	err = preparedGet(ctx, db, &livestreamModel, queryLivestreamByID, livestreamID)
 	if errors.Is(err, sql.ErrNoRows) {
-		return echo.NewHTTPError(http.StatusNotFound, "not found livestream that has the given id")
+		return apperror.NotFound("not found livestream that has the given id")
 	}
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
 	}
 
-	livestream, err := fillLivestreamResponse(ctx, tx, livestreamModel)
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	// 非公開配信は存在自体を隠す
+	if !isLivestreamVisible(livestreamModel, userID) {
+		return apperror.NotFound("not found livestream that has the given id")
+	}
+
+	livestream, err := fillLivestreamResponse(ctx, db, livestreamModel)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livestream: "+err.Error())
 	}
 
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+	// 削除済みのライブコメントがピン留めされていても返さない
+	var pinnedLivecommentModel LivecommentModel
+	err = db.GetContext(ctx, &pinnedLivecommentModel, "SELECT l.* FROM livestream_pins p INNER JOIN livecomments l ON l.id = p.livecomment_id WHERE p.livestream_id = ? AND l.deleted_at IS NULL", livestreamID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get pinned livecomment: "+err.Error())
+	}
+	if err == nil {
+		pinnedLivecomment, err := fillLivecommentResponse(ctx, db, pinnedLivecommentModel)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill pinned livecomment: "+err.Error())
+		}
+		livestream.PinnedLivecomment = &pinnedLivecomment
+	}
+
+	var collaboratorIDs []int64
+	if err := db.SelectContext(ctx, &collaboratorIDs, excludeDeleted("SELECT lc.user_id FROM livestream_collaborators lc INNER JOIN users u ON u.id = lc.user_id WHERE lc.livestream_id = ? ORDER BY lc.created_at ASC, u.id ASC", "u"), livestreamID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get collaborators: "+err.Error())
+	}
+	collaborators, err := loadUsers(ctx, db, collaboratorIDs)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill collaborator: "+err.Error())
+	}
+	for _, collaboratorID := range collaboratorIDs {
+		if collaborator, ok := collaborators[collaboratorID]; ok {
+			livestream.Collaborators = append(livestream.Collaborators, collaborator)
+		}
 	}
 
 	return c.JSON(http.StatusOK, livestream)
@@ -440,17 +1279,13 @@ func getLivecommentReportsHandler(c echo.Context) error {
 
 	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "livestream_id in path must be integer")
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
-	}
-	defer tx.Rollback()
+	db := readDB(c)
 
 	var livestreamModel LivestreamModel
-	if err := tx.GetContext(ctx, &livestreamModel, "SELECT * FROM livestreams WHERE id = ?", livestreamID); err != nil {
+	if err := preparedGet(ctx, db, &livestreamModel, queryLivestreamByID, livestreamID); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
 	}
 
@@ -459,69 +1294,260 @@ func getLivecommentReportsHandler(c echo.Context) error {
 	// existence already check
 	userID := sess.Values[defaultUserIDKey].(int64)
 
-	if livestreamModel.UserID != userID {
-		return echo.NewHTTPError(http.StatusForbidden, "can't get other streamer's livecomment reports")
+	canModerate, err := canModerateLivestream(ctx, db, livestreamModel, userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get collaborators: "+err.Error())
+	}
+	if !canModerate {
+		return apperror.Forbidden("can't get other streamer's livecomment reports")
 	}
 
 	var reportModels []*LivecommentReportModel
-	if err := tx.SelectContext(ctx, &reportModels, "SELECT * FROM livecomment_reports WHERE livestream_id = ?", livestreamID); err != nil {
+	if err := db.SelectContext(ctx, &reportModels, "SELECT * FROM livecomment_reports WHERE livestream_id = ?", livestreamID); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livecomment reports: "+err.Error())
 	}
 
-	reports := make([]LivecommentReport, len(reportModels))
-	for i := range reportModels {
-		report, err := fillLivecommentReportResponse(ctx, tx, *reportModels[i])
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livecomment report: "+err.Error())
-		}
-		reports[i] = report
+	reports, err := fillLivecommentReportResponses(ctx, db, reportModels)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill livecomment report: "+err.Error())
 	}
 
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+	return c.JSON(http.StatusOK, reports)
+}
+
+// isLivestreamVisible は、ユーザがライブ配信の詳細を閲覧できるかを返します
+// unlistedは一覧に出ないだけなので、IDを指定すれば誰でも閲覧できます
+func isLivestreamVisible(livestreamModel LivestreamModel, userID int64) bool {
+	if livestreamModel.PrivacyStatus == livestreamPrivacyPrivate {
+		return livestreamModel.UserID == userID
 	}
+	return true
+}
 
-	return c.JSON(http.StatusOK, reports)
+// canModerateLivestream は、ユーザがライブ配信をモデレーションできる (配信者か共同配信者である) かを返します
+func canModerateLivestream(ctx context.Context, q sqlx.QueryerContext, livestreamModel LivestreamModel, userID int64) (bool, error) {
+	if livestreamModel.UserID == userID {
+		return true, nil
+	}
+	var count int
+	if err := sqlx.GetContext(ctx, q, &count, "SELECT COUNT(*) FROM livestream_collaborators WHERE livestream_id = ? AND user_id = ?", livestreamModel.ID, userID); err != nil {
+		return false, err
+	}
+	return count > 0, nil
 }
 
-func fillLivestreamResponse(ctx context.Context, tx *sqlx.Tx, livestreamModel LivestreamModel) (Livestream, error) {
-	ownerModel := UserModel{}
-	if err := tx.GetContext(ctx, &ownerModel, "SELECT * FROM users WHERE id = ?", livestreamModel.UserID); err != nil {
-		return Livestream{}, err
+type PostLivestreamCollaboratorRequest struct {
+	UserID int64 `json:"user_id"`
+}
+
+// 配信者による共同配信者の追加
+// POST /api/livestream/:livestream_id/collaborator
+func postLivestreamCollaboratorHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+	defer c.Request().Body.Close()
+
+	if err := verifyUserSession(c); err != nil {
+		return err
 	}
-	owner, err := fillUserResponse(ctx, tx, ownerModel)
+
+	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return Livestream{}, err
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 
-	var livestreamTagModels []*LivestreamTagModel
-	if err := tx.SelectContext(ctx, &livestreamTagModels, "SELECT * FROM livestream_tags WHERE livestream_id = ?", livestreamModel.ID); err != nil {
-		return Livestream{}, err
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	var req *PostLivestreamCollaboratorRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return apperror.BadRequest("failed to decode the request body as json")
+	}
+
+	var collaborator User
+	err = withTx(ctx, func(tx *sqlx.Tx) error {
+		var livestreamModel LivestreamModel
+		if err := preparedGet(ctx, tx, &livestreamModel, queryLivestreamByID, livestreamID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return apperror.NotFound("livestream not found")
+			} else {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
+			}
+		}
+
+		// 共同配信者が更に共同配信者を追加することはできない
+		if livestreamModel.UserID != userID {
+			return apperror.Forbidden("can't add collaborators to other streamer's livestream")
+		}
+		if req.UserID == livestreamModel.UserID {
+			return apperror.BadRequest("the owner can't be a collaborator")
+		}
+
+		var collaboratorModel UserModel
+		if err := preparedGet(ctx, tx, &collaboratorModel, queryUserByID, req.UserID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return apperror.NotFound("user not found")
+			} else {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user: "+err.Error())
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, "INSERT IGNORE INTO livestream_collaborators (livestream_id, user_id, created_at) VALUES (?, ?, ?)", livestreamID, req.UserID, time.Now().Unix()); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert collaborator: "+err.Error())
+		}
+
+		var err error
+		collaborator, err = userProfiles.fill(ctx, tx, collaboratorModel)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill user: "+err.Error())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, collaborator)
+}
+
+type LivestreamIngest struct {
+	LivestreamID int64  `json:"livestream_id"`
+	IngestKey    string `json:"ingest_key"`
+}
+
+// 配信者によるストリームキーの取得
+// GET /api/livestream/:livestream_id/ingest
+func getLivestreamIngestHandler(c echo.Context) error {
+	return handleLivestreamIngest(c, false)
+}
+
+// 配信者によるストリームキーの再発行
+// POST /api/livestream/:livestream_id/ingest/rotate
+func rotateLivestreamIngestHandler(c echo.Context) error {
+	return handleLivestreamIngest(c, true)
+}
+
+func handleLivestreamIngest(c echo.Context, rotate bool) error {
+	ctx := c.Request().Context()
+
+	if err := verifyUserSession(c); err != nil {
+		return err
+	}
+
+	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
+	if err != nil {
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 
-	tags := make([]Tag, len(livestreamTagModels))
-	for i := range livestreamTagModels {
-		tagModel := TagModel{}
-		if err := tx.GetContext(ctx, &tagModel, "SELECT * FROM tags WHERE id = ?", livestreamTagModels[i].TagID); err != nil {
-			return Livestream{}, err
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	var ingestKey string
+	err = withTx(ctx, func(tx *sqlx.Tx) error {
+		var livestreamModel LivestreamModel
+		if err := preparedGet(ctx, tx, &livestreamModel, queryLivestreamByID, livestreamID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return apperror.NotFound("livestream not found")
+			} else {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
+			}
 		}
 
-		tags[i] = Tag{
-			ID:   tagModel.ID,
-			Name: tagModel.Name,
+		// 共同配信者であってもストリームキーは渡さない
+		if livestreamModel.UserID != userID {
+			return apperror.Forbidden("can't get ingest key of other streamer's livestream")
 		}
+
+		if !rotate {
+			err := tx.GetContext(ctx, &ingestKey, "SELECT ingest_key FROM livestream_ingest_keys WHERE livestream_id = ?", livestreamID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get ingest key: "+err.Error())
+			}
+		}
+		// 初期データの配信などキーがまだない場合も、ここで発行する
+		if ingestKey == "" {
+			var err error
+			ingestKey, err = rotateIngestKey(ctx, tx, int64(livestreamID))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate ingest key: "+err.Error())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	livestream := Livestream{
-		ID:           livestreamModel.ID,
-		Owner:        owner,
-		Title:        livestreamModel.Title,
-		Tags:         tags,
-		Description:  livestreamModel.Description,
-		PlaylistUrl:  livestreamModel.PlaylistUrl,
-		ThumbnailUrl: livestreamModel.ThumbnailUrl,
-		StartAt:      livestreamModel.StartAt,
-		EndAt:        livestreamModel.EndAt,
+	return c.JSON(http.StatusOK, &LivestreamIngest{
+		LivestreamID: int64(livestreamID),
+		IngestKey:    ingestKey,
+	})
+}
+
+// rotateIngestKey は、ライブ配信のストリームキーを新たに発行し、古いものを無効にします
+func rotateIngestKey(ctx context.Context, tx *sqlx.Tx, livestreamID int64) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	ingestKey := "live_" + hex.EncodeToString(b)
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO livestream_ingest_keys (livestream_id, ingest_key, updated_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE ingest_key = VALUES(ingest_key), updated_at = VALUES(updated_at)", livestreamID, ingestKey, time.Now().Unix()); err != nil {
+		return "", err
+	}
+	return ingestKey, nil
+}
+
+func fillLivestreamResponse(ctx context.Context, q sqlx.QueryerContext, livestreamModel LivestreamModel) (Livestream, error) {
+	livestreams, err := fillLivestreamResponses(ctx, q, []*LivestreamModel{&livestreamModel})
+	if err != nil {
+		return Livestream{}, err
+	}
+	return livestreams[0], nil
+}
+
+// fillLivestreamResponses は、配信者とタグをまとめて引いて、配信の一覧をレスポンスの形にします
+func fillLivestreamResponses(ctx context.Context, q sqlx.QueryerContext, livestreamModels []*LivestreamModel) ([]Livestream, error) {
+	ownerIDs := make([]int64, len(livestreamModels))
+	livestreamIDs := make([]int64, len(livestreamModels))
+	for i, livestreamModel := range livestreamModels {
+		ownerIDs[i] = livestreamModel.UserID
+		livestreamIDs[i] = livestreamModel.ID
+	}
+	owners, err := loadUsers(ctx, q, ownerIDs)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := loadLivestreamTags(ctx, q, livestreamIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	livestreams := make([]Livestream, len(livestreamModels))
+	for i, livestreamModel := range livestreamModels {
+		owner, ok := owners[livestreamModel.UserID]
+		if !ok {
+			return nil, sql.ErrNoRows
+		}
+		livestreamTags := tags[livestreamModel.ID]
+		if livestreamTags == nil {
+			livestreamTags = []Tag{}
+		}
+		livestreams[i] = Livestream{
+			ID:            livestreamModel.ID,
+			Owner:         owner,
+			Title:         livestreamModel.Title,
+			Tags:          livestreamTags,
+			Description:   livestreamModel.Description,
+			PlaylistUrl:   livestreamModel.PlaylistUrl,
+			ThumbnailUrl:  livestreamModel.ThumbnailUrl,
+			StartAt:       livestreamModel.StartAt,
+			EndAt:         livestreamModel.EndAt,
+			PrivacyStatus: livestreamModel.PrivacyStatus,
+		}
 	}
-	return livestream, nil
+	return livestreams, nil
 }
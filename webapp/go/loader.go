@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 一覧系のレスポンスを組み立てるときに、埋め込むユーザや配信をIN句でまとめて引くためのローダー
+// 件数によらずクエリの回数が一定になるようにします
+// いずれも、存在しないIDは結果のmapに含めません
+
+// uniqueIDs は、重複を除いたIDを出現順に返します
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]struct{}, len(ids))
+	unique := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		unique = append(unique, id)
+	}
+	return unique
+}
+
+// loadUsers は、ユーザIDに対応するユーザをまとめて返します
+// キャッシュにないユーザだけ、ユーザ・テーマ・アイコンをそれぞれ1回ずつ引いてキャッシュに載せます
+func loadUsers(ctx context.Context, q sqlx.QueryerContext, userIDs []int64) (map[int64]User, error) {
+	users := make(map[int64]User, len(userIDs))
+	var missing []int64
+	for _, userID := range uniqueIDs(userIDs) {
+		if user, ok := userProfiles.lookup(ctx, userID); ok {
+			users[userID] = user
+		} else {
+			missing = append(missing, userID)
+		}
+	}
+	if len(missing) == 0 {
+		return users, nil
+	}
+
+	query, params, err := sqlx.In(excludeDeleted("SELECT * FROM users WHERE id IN (?)", ""), missing)
+	if err != nil {
+		return nil, err
+	}
+	var userModels []UserModel
+	if err := sqlx.SelectContext(ctx, q, &userModels, query, params...); err != nil {
+		return nil, err
+	}
+
+	query, params, err = sqlx.In("SELECT * FROM themes WHERE user_id IN (?)", missing)
+	if err != nil {
+		return nil, err
+	}
+	var themeModels []ThemeModel
+	if err := sqlx.SelectContext(ctx, q, &themeModels, query, params...); err != nil {
+		return nil, err
+	}
+	themes := make(map[int64]ThemeModel, len(themeModels))
+	for _, themeModel := range themeModels {
+		themes[themeModel.UserID] = themeModel
+	}
+
+	query, params, err = sqlx.In("SELECT user_id, "+iconHashColumn+" AS hash FROM icons WHERE user_id IN (?)", missing)
+	if err != nil {
+		return nil, err
+	}
+	var iconModels []IconModel
+	if err := sqlx.SelectContext(ctx, q, &iconModels, query, params...); err != nil {
+		return nil, err
+	}
+	iconHashes := make(map[int64]string, len(iconModels))
+	for _, iconModel := range iconModels {
+		iconHashes[iconModel.UserID] = iconModel.Hash
+	}
+
+	for _, userModel := range userModels {
+		themeModel, ok := themes[userModel.ID]
+		if !ok {
+			return nil, fmt.Errorf("theme not found: user_id=%d", userModel.ID)
+		}
+		iconHash, ok := iconHashes[userModel.ID]
+		if !ok {
+			iconHash, err = fallbackIconHash()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		user := newUserResponse(userModel, themeModel, iconHash)
+		userProfiles.store(ctx, user)
+		users[user.ID] = user
+	}
+	return users, nil
+}
+
+// loadLivestreamModels は、配信IDに対応する配信をまとめて返します
+func loadLivestreamModels(ctx context.Context, q sqlx.QueryerContext, livestreamIDs []int64) (map[int64]*LivestreamModel, error) {
+	livestreams := make(map[int64]*LivestreamModel, len(livestreamIDs))
+	if len(livestreamIDs) == 0 {
+		return livestreams, nil
+	}
+
+	query, params, err := sqlx.In("SELECT * FROM livestreams WHERE id IN (?)", uniqueIDs(livestreamIDs))
+	if err != nil {
+		return nil, err
+	}
+	var livestreamModels []*LivestreamModel
+	if err := sqlx.SelectContext(ctx, q, &livestreamModels, query, params...); err != nil {
+		return nil, err
+	}
+	for _, livestreamModel := range livestreamModels {
+		livestreams[livestreamModel.ID] = livestreamModel
+	}
+	return livestreams, nil
+}
+
+// loadLivestreams は、配信IDに対応する配信をレスポンスの形でまとめて返します
+func loadLivestreams(ctx context.Context, q sqlx.QueryerContext, livestreamIDs []int64) (map[int64]Livestream, error) {
+	models, err := loadLivestreamModels(ctx, q, livestreamIDs)
+	if err != nil {
+		return nil, err
+	}
+	livestreamModels := make([]*LivestreamModel, 0, len(models))
+	for _, livestreamModel := range models {
+		livestreamModels = append(livestreamModels, livestreamModel)
+	}
+	filled, err := fillLivestreamResponses(ctx, q, livestreamModels)
+	if err != nil {
+		return nil, err
+	}
+
+	livestreams := make(map[int64]Livestream, len(filled))
+	for _, livestream := range filled {
+		livestreams[livestream.ID] = livestream
+	}
+	return livestreams, nil
+}
+
+// loadLivestreamTags は、配信IDごとに付いているタグをまとめて返します
+// タグそのものはtagMasterから引きます
+func loadLivestreamTags(ctx context.Context, q sqlx.QueryerContext, livestreamIDs []int64) (map[int64][]Tag, error) {
+	tags := make(map[int64][]Tag, len(livestreamIDs))
+	if len(livestreamIDs) == 0 {
+		return tags, nil
+	}
+
+	query, params, err := sqlx.In("SELECT * FROM livestream_tags WHERE livestream_id IN (?) ORDER BY id", uniqueIDs(livestreamIDs))
+	if err != nil {
+		return nil, err
+	}
+	var livestreamTagModels []*LivestreamTagModel
+	if err := sqlx.SelectContext(ctx, q, &livestreamTagModels, query, params...); err != nil {
+		return nil, err
+	}
+	for _, livestreamTagModel := range livestreamTagModels {
+		tag, err := tagMaster.getByID(ctx, q, livestreamTagModel.TagID)
+		if err != nil {
+			return nil, err
+		}
+		tags[livestreamTagModel.LivestreamID] = append(tags[livestreamTagModel.LivestreamID], tag)
+	}
+	return tags, nil
+}
+
+// loadLivecomments は、ライブコメントIDに対応するライブコメントをレスポンスの形でまとめて返します
+func loadLivecomments(ctx context.Context, q sqlx.QueryerContext, livecommentIDs []int64) (map[int64]Livecomment, error) {
+	livecomments := make(map[int64]Livecomment, len(livecommentIDs))
+	if len(livecommentIDs) == 0 {
+		return livecomments, nil
+	}
+
+	query, params, err := sqlx.In("SELECT * FROM livecomments WHERE id IN (?)", uniqueIDs(livecommentIDs))
+	if err != nil {
+		return nil, err
+	}
+	var livecommentModels []LivecommentModel
+	if err := sqlx.SelectContext(ctx, q, &livecommentModels, query, params...); err != nil {
+		return nil, err
+	}
+	filled, err := fillLivecommentResponses(ctx, q, livecommentModels)
+	if err != nil {
+		return nil, err
+	}
+	for _, livecomment := range filled {
+		livecomments[livecomment.ID] = livecomment
+	}
+	return livecomments, nil
+}
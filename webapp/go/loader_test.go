@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/isucon/isucon13/webapp/go/internal/querystats"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/mattn/go-sqlite3"
+)
+
+// 一覧系のハンドラが、件数によらず一定の回数しかクエリを発行しないことを確かめるテスト
+// MySQLを立てずに済むよう、SQLiteのインメモリDBで同じ形のテーブルを作り、MySQLの関数 (IF, SHA2) だけ登録して使います
+
+var loaderTestDriver = &sqlite3.SQLiteDriver{
+	ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+		if err := conn.RegisterFunc("if", func(cond bool, then, otherwise string) string {
+			if cond {
+				return then
+			}
+			return otherwise
+		}, true); err != nil {
+			return err
+		}
+		return conn.RegisterFunc("sha2", func(b []byte, _ int) string {
+			sum := sha256.Sum256(b)
+			return hex.EncodeToString(sum[:])
+		}, true)
+	},
+}
+
+var loaderTestSchema = []string{
+	`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, display_name TEXT NOT NULL, description TEXT NOT NULL, password TEXT NOT NULL,
+		total_tip INTEGER NOT NULL DEFAULT 0, reaction_count INTEGER NOT NULL DEFAULT 0, comment_count INTEGER NOT NULL DEFAULT 0, deleted_at INTEGER)`,
+	`CREATE TABLE themes (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL, dark_mode BOOLEAN NOT NULL)`,
+	`CREATE TABLE icons (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL, image BLOB NOT NULL, hash TEXT NOT NULL DEFAULT '')`,
+	`CREATE TABLE tags (id INTEGER PRIMARY KEY, name TEXT NOT NULL, livestream_count INTEGER NOT NULL DEFAULT 0)`,
+	`CREATE TABLE livestreams (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL, title TEXT NOT NULL, description TEXT NOT NULL,
+		playlist_url TEXT NOT NULL, thumbnail_url TEXT NOT NULL, start_at INTEGER NOT NULL, end_at INTEGER NOT NULL, privacy_status TEXT NOT NULL,
+		total_tip INTEGER NOT NULL DEFAULT 0, reaction_count INTEGER NOT NULL DEFAULT 0, comment_count INTEGER NOT NULL DEFAULT 0)`,
+	`CREATE TABLE livestream_tags (id INTEGER PRIMARY KEY, livestream_id INTEGER NOT NULL, tag_id INTEGER NOT NULL)`,
+	`CREATE TABLE livecomments (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL, livestream_id INTEGER NOT NULL, comment TEXT NOT NULL,
+		tip INTEGER NOT NULL, created_at INTEGER NOT NULL, deleted_at INTEGER, spam_score REAL NOT NULL DEFAULT 0, hidden_at INTEGER)`,
+	`CREATE TABLE reactions (id INTEGER PRIMARY KEY, emoji_name TEXT NOT NULL, user_id INTEGER NOT NULL, livestream_id INTEGER NOT NULL, created_at INTEGER NOT NULL)`,
+}
+
+// 用意する行の数。いずれも一覧の件数の上限に使う最大の件数以上にします
+const (
+	loaderTestRows = 50
+	loaderTestTags = 5
+)
+
+// sqliteConnector は、loaderTestDriverでインメモリDBを開くdriver.Connectorです
+type sqliteConnector struct{}
+
+func (sqliteConnector) Connect(context.Context) (driver.Conn, error) {
+	return loaderTestDriver.Open(":memory:")
+}
+
+func (sqliteConnector) Driver() driver.Driver {
+	return loaderTestDriver
+}
+
+// setupLoaderTestDB は、テスト用のDBをdbConnにし、そのDBに発行したクエリを数えるRecorderを返します
+// loaderTestRows人のユーザがそれぞれ配信を1つ持ち、配信ごとにタグが2つ付いています
+// 最初の配信には、配信者以外のloaderTestRows人のユーザから1件ずつライブコメントとリアクションがあります
+func setupLoaderTestDB(t *testing.T) *querystats.Recorder {
+	t.Helper()
+
+	recorder := querystats.NewRecorder(0, nil)
+	conn := sqlx.NewDb(sql.OpenDB(querystats.WrapConnector(sqliteConnector{}, recorder)), "mysql")
+	// インメモリDBは接続ごとに別のDBになるため、接続を1本に絞る
+	conn.SetMaxOpenConns(1)
+	t.Cleanup(func() { conn.Close() })
+
+	for _, stmt := range loaderTestSchema {
+		conn.MustExec(stmt)
+	}
+	for i := 1; i <= loaderTestTags; i++ {
+		conn.MustExec("INSERT INTO tags (id, name) VALUES (?, ?)", i, fmt.Sprintf("tag%d", i))
+	}
+	now := time.Now().Unix()
+	for i := 1; i <= loaderTestRows+1; i++ {
+		conn.MustExec("INSERT INTO users (id, name, display_name, description, password) VALUES (?, ?, ?, '', '')", i, fmt.Sprintf("user%d", i), fmt.Sprintf("User %d", i))
+		conn.MustExec("INSERT INTO themes (user_id, dark_mode) VALUES (?, ?)", i, i%2 == 0)
+		conn.MustExec("INSERT INTO icons (user_id, image) VALUES (?, ?)", i, []byte(fmt.Sprintf("icon%d", i)))
+	}
+	for i := 1; i <= loaderTestRows; i++ {
+		conn.MustExec("INSERT INTO livestreams (id, user_id, title, description, playlist_url, thumbnail_url, start_at, end_at, privacy_status) VALUES (?, ?, ?, '', '', '', ?, ?, ?)",
+			i, i, fmt.Sprintf("livestream%d", i), now, now+3600, livestreamPrivacyPublic)
+		conn.MustExec("INSERT INTO livestream_tags (livestream_id, tag_id) VALUES (?, ?), (?, ?)", i, i%loaderTestTags+1, i, (i+1)%loaderTestTags+1)
+		// 投稿者に配信者を含めると、配信者を引くときにユーザのキャッシュが効いて回数が変わるため、配信者以外のユーザが投稿する
+		conn.MustExec("INSERT INTO livecomments (user_id, livestream_id, comment, tip, created_at) VALUES (?, 1, ?, 0, ?)", i+1, fmt.Sprintf("comment%d", i), now+int64(i))
+		conn.MustExec("INSERT INTO reactions (emoji_name, user_id, livestream_id, created_at) VALUES ('+1', ?, 1, ?)", i+1, now+int64(i))
+	}
+
+	prevDB, prevProfiles := dbConn, userProfiles
+	dbConn = conn
+	t.Cleanup(func() {
+		dbConn, userProfiles = prevDB, prevProfiles
+	})
+	if err := tagMaster.load(context.Background(), dbConn); err != nil {
+		t.Fatal(err)
+	}
+	return recorder
+}
+
+// loginCookie は、userIDでログインしたセッションのクッキーを返します
+func loginCookie(t *testing.T, store sessions.Store, userID int64) *http.Cookie {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	sess, err := store.Get(req, defaultSessionIDKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess.Values[defaultUserIDKey] = userID
+	sess.Values[defaultSessionExpiresKey] = time.Now().Add(time.Hour).Unix()
+	if err := sess.Save(req, rec); err != nil {
+		t.Fatal(err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatal("session cookie is not set")
+	}
+	return cookies[0]
+}
+
+func countQueries(recorder *querystats.Recorder) int64 {
+	var count int64
+	for _, stat := range recorder.Top(0, querystats.SortByCount) {
+		count += stat.Count
+	}
+	return count
+}
+
+// assertConstantQueries は、件数の上限を変えてpathにリクエストし、どの件数でもクエリの回数が同じであることを確かめます
+// ユーザのキャッシュが効くと回数が変わるため、リクエストのたびにキャッシュを空にします
+func assertConstantQueries(t *testing.T, recorder *querystats.Recorder, route string, handler echo.HandlerFunc, path string) {
+	t.Helper()
+
+	store := sessions.NewCookieStore([]byte("loader-test-secret"))
+	e := echo.New()
+	e.Use(session.Middleware(store))
+	e.GET(route, handler)
+	cookie := loginCookie(t, store, 1)
+
+	var want int64
+	for i, limit := range []int{1, 10, loaderTestRows} {
+		userProfiles = &userCache{}
+		recorder.Reset()
+
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s?limit=%d", path, limit), nil)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s?limit=%d: status = %d, body = %s", path, limit, rec.Code, rec.Body.String())
+		}
+		var items []json.RawMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &items); err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != limit {
+			t.Fatalf("GET %s?limit=%d: %d items, want %d", path, limit, len(items), limit)
+		}
+
+		got := countQueries(recorder)
+		if i == 0 {
+			want = got
+			continue
+		}
+		if got != want {
+			t.Errorf("GET %s?limit=%d: %d queries, want %d (same as limit=1)\n%+v", path, limit, got, want, recorder.Top(0, querystats.SortByCount))
+		}
+	}
+}
+
+func TestSearchLivestreamsQueryCount(t *testing.T) {
+	recorder := setupLoaderTestDB(t)
+	assertConstantQueries(t, recorder, "/api/livestream/search", searchLivestreamsHandler, "/api/livestream/search")
+}
+
+func TestGetLivecommentsQueryCount(t *testing.T) {
+	recorder := setupLoaderTestDB(t)
+	assertConstantQueries(t, recorder, "/api/livestream/:livestream_id/livecomment", getLivecommentsHandler, "/api/livestream/1/livecomment")
+}
+
+func TestGetReactionsQueryCount(t *testing.T) {
+	recorder := setupLoaderTestDB(t)
+	assertConstantQueries(t, recorder, "/api/livestream/:livestream_id/reaction", getReactionsHandler, "/api/livestream/1/reaction")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/isucon/isucon13/webapp/go/internal/config"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	echolog "github.com/labstack/gommon/log"
+)
+
+const (
+	requestIDHeader = "X-Request-Id"
+	requestIDKey    = "request_id"
+)
+
+// logLevelOff は、すべてのログを出力しないためのレベルです
+const logLevelOff = slog.Level(100)
+
+// appLogger は、リクエストに紐づかないログにも使うロガーです
+var appLogger = slog.Default()
+
+// newLogger は、設定に従ってロガーを作ります
+// レベルと形式の値は、設定の読み込み時に検証済みです
+func newLogger(w io.Writer, c config.Log) (*slog.Logger, slog.Level) {
+	level := slog.LevelInfo
+	switch c.Level {
+	case "debug":
+		level = slog.LevelDebug
+	case "warn":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	case "off":
+		level = logLevelOff
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	if c.Format == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts)), level
+	}
+	return slog.New(slog.NewTextHandler(w, opts)), level
+}
+
+// echoLogLevel は、slogのレベルに対応するechoのロガーのレベルを返します
+// バックグラウンド処理などechoのロガーを使う箇所も、同じレベルで出力を絞ります
+func echoLogLevel(level slog.Level) echolog.Lvl {
+	switch {
+	case level >= logLevelOff:
+		return echolog.OFF
+	case level >= slog.LevelError:
+		return echolog.ERROR
+	case level >= slog.LevelWarn:
+		return echolog.WARN
+	case level >= slog.LevelInfo:
+		return echolog.INFO
+	default:
+		return echolog.DEBUG
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestIDMiddleware は、リクエストにIDを割り当ててレスポンスのX-Request-Idヘッダで返します
+// nginxなどの前段が付けたIDがあれば、それをそのまま使います
+func requestIDMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		requestID := c.Request().Header.Get(requestIDHeader)
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		c.Set(requestIDKey, requestID)
+		c.Response().Header().Set(requestIDHeader, requestID)
+		return next(c)
+	}
+}
+
+// requestLogger は、リクエストID・ルート・ログイン中のユーザIDを付けたロガーを返します
+func requestLogger(c echo.Context) *slog.Logger {
+	attrs := []any{
+		slog.String("method", c.Request().Method),
+		slog.String("route", c.Path()),
+	}
+	if requestID, ok := c.Get(requestIDKey).(string); ok {
+		attrs = append(attrs, slog.String(requestIDKey, requestID))
+	}
+	if sess, err := session.Get(defaultSessionIDKey, c); err == nil {
+		if userID, ok := sess.Values[defaultUserIDKey].(int64); ok {
+			attrs = append(attrs, slog.Int64("user_id", userID))
+		}
+	}
+	return appLogger.With(attrs...)
+}
+
+// accessLogMiddleware は、リクエストごとにアクセスログを出力します
+// ステータスコードを確定させるため、エラーはここでレスポンスに変換します
+func accessLogMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		if err := next(c); err != nil {
+			c.Error(err)
+		}
+		if !appLogger.Enabled(c.Request().Context(), slog.LevelInfo) {
+			return nil
+		}
+		requestLogger(c).Info("access",
+			slog.String("uri", c.Request().RequestURI),
+			slog.Int("status", c.Response().Status),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int64("bytes_out", c.Response().Size),
+		)
+		return nil
+	}
+}
@@ -4,100 +4,80 @@ package main
 // sqlx的な参考: https://jmoiron.github.io/sqlx/
 
 import (
-	"fmt"
+	"context"
+	"io"
 	"log"
-	"net"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
-	"strconv"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/isucon/isucon13/webapp/go/internal/config"
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
 
-	"github.com/gorilla/sessions"
 	"github.com/labstack/echo-contrib/session"
-	echolog "github.com/labstack/gommon/log"
-)
-
-const (
-	listenPort                     = 8080
-	powerDNSSubdomainAddressEnvKey = "ISUCON13_POWERDNS_SUBDOMAIN_ADDRESS"
 )
 
 var (
-	powerDNSSubdomainAddress string
-	dbConn                   *sqlx.DB
-	secret                   = []byte("isucon13_session_cookiestore_defaultsecret")
+	dbConn       *sqlx.DB
+	secret       = []byte("isucon13_session_cookiestore_defaultsecret")
+	adminUserIDs = map[int64]struct{}{}
 )
 
 func init() {
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
-	if secretKey, ok := os.LookupEnv("ISUCON13_SESSION_SECRETKEY"); ok {
-		secret = []byte(secretKey)
-	}
 }
 
 type InitializeResponse struct {
 	Language string `json:"language"`
 }
 
-func connectDB(logger echo.Logger) (*sqlx.DB, error) {
-	const (
-		networkTypeEnvKey = "ISUCON13_MYSQL_DIALCONFIG_NET"
-		addrEnvKey        = "ISUCON13_MYSQL_DIALCONFIG_ADDRESS"
-		portEnvKey        = "ISUCON13_MYSQL_DIALCONFIG_PORT"
-		userEnvKey        = "ISUCON13_MYSQL_DIALCONFIG_USER"
-		passwordEnvKey    = "ISUCON13_MYSQL_DIALCONFIG_PASSWORD"
-		dbNameEnvKey      = "ISUCON13_MYSQL_DIALCONFIG_DATABASE"
-		parseTimeEnvKey   = "ISUCON13_MYSQL_DIALCONFIG_PARSETIME"
-	)
-
+// newDBConfig は、プライマリのDBの接続設定を組み立てます
+func newDBConfig(c config.DB) *mysql.Config {
 	conf := mysql.NewConfig()
+	conf.Net = c.Net
+	conf.Addr = c.Addr
+	conf.User = c.User
+	conf.Passwd = c.Password
+	conf.DBName = c.Name
+	conf.ParseTime = c.ParseTime
+	conf.InterpolateParams = c.InterpolateParams
+	return conf
+}
 
-	// 環境変数がセットされていなかった場合でも一旦動かせるように、デフォルト値を入れておく
-	// この挙動を変更して、エラーを出すようにしてもいいかもしれない
-	conf.Net = "tcp"
-	conf.Addr = net.JoinHostPort("127.0.0.1", "3306")
-	conf.User = "isucon"
-	conf.Passwd = "isucon"
-	conf.DBName = "isupipe"
-	conf.ParseTime = true
-
-	if v, ok := os.LookupEnv(networkTypeEnvKey); ok {
-		conf.Net = v
-	}
-	if addr, ok := os.LookupEnv(addrEnvKey); ok {
-		if port, ok2 := os.LookupEnv(portEnvKey); ok2 {
-			conf.Addr = net.JoinHostPort(addr, port)
-		} else {
-			conf.Addr = net.JoinHostPort(addr, "3306")
-		}
-	}
-	if v, ok := os.LookupEnv(userEnvKey); ok {
-		conf.User = v
-	}
-	if v, ok := os.LookupEnv(passwordEnvKey); ok {
-		conf.Passwd = v
-	}
-	if v, ok := os.LookupEnv(dbNameEnvKey); ok {
-		conf.DBName = v
-	}
-	if v, ok := os.LookupEnv(parseTimeEnvKey); ok {
-		parseTime, err := strconv.ParseBool(v)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse environment variable '%s' as bool: %+v", parseTimeEnvKey, err)
-		}
-		conf.ParseTime = parseTime
+// dbPoolConfig は、DB接続のコネクションプールの設定です
+type dbPoolConfig struct {
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+}
+
+// newDBPoolConfig は、コネクションプールの設定を返します
+// プライマリとレプリカで同じ設定を使います
+func newDBPoolConfig(c config.DB) *dbPoolConfig {
+	return &dbPoolConfig{
+		maxOpenConns:    c.MaxOpenConns,
+		maxIdleConns:    c.MaxIdleConns,
+		connMaxLifetime: c.ConnMaxLifetime,
 	}
+}
 
-	db, err := sqlx.Open("mysql", conf.FormatDSN())
+func (p *dbPoolConfig) apply(db *sqlx.DB) {
+	db.SetMaxOpenConns(p.maxOpenConns)
+	db.SetMaxIdleConns(p.maxIdleConns)
+	db.SetConnMaxLifetime(p.connMaxLifetime)
+}
+
+func connectDB(conf *mysql.Config, pool *dbPoolConfig) (*sqlx.DB, error) {
+	db, err := openDB(conf)
 	if err != nil {
 		return nil, err
 	}
-	db.SetMaxOpenConns(10)
+	pool.apply(db)
 
 	if err := db.Ping(); err != nil {
 		return nil, err
@@ -106,11 +86,68 @@ func connectDB(logger echo.Logger) (*sqlx.DB, error) {
 	return db, nil
 }
 
+// resetInMemoryState は、アプリケーションがメモリ上に持つキャッシュや集計を初期データに合わせて作り直します
+// メモリ上に状態を持つ機能を追加した場合は、ここで初期化してください
+func resetInMemoryState(ctx context.Context) error {
+	slowMode.reset()
+	channelBans.reset()
+	rateLimits.reset()
+	iconCache.reset()
+	trending.reset()
+	routeStats.reset()
+	activeSessions.reset()
+	recentErrors.reset()
+	liveHubs.reset()
+	queryStats.Reset()
+	ngWordMatchers.reset()
+	userProfiles.reset()
+	resetResponseCaches()
+	if err := sharedCache.Flush(ctx); err != nil {
+		return err
+	}
+	if err := tagMaster.load(ctx, dbConn); err != nil {
+		return err
+	}
+	if err := reactionEmojis.load(ctx, dbConn); err != nil {
+		return err
+	}
+	if err := livestreamRanking.refresh(ctx, dbConn); err != nil {
+		return err
+	}
+	if err := tipLeaderboard.rebuild(ctx, dbConn); err != nil {
+		return err
+	}
+	return nil
+}
+
+// 初期化API
+// POST /api/initialize
 func initializeHandler(c echo.Context) error {
+	// 初期化中のデータを読まれないよう、終わるまでは準備中として扱う
+	// 失敗したときは、次に初期化に成功するまで準備中のままにする
+	setNotReady("initializing")
+
+	// 溜まっている書き込みが初期化後のデータに混ざらないよう、先に書き出しておく
+	if err := flushBatchWriters(c.Request().Context()); err != nil {
+		setNotReady("initialize failed")
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to flush pending writes: "+err.Error())
+	}
 	if out, err := exec.Command("../sql/init.sh").CombinedOutput(); err != nil {
-		c.Logger().Warnf("init.sh failed with err=%s", string(out))
+		setNotReady("initialize failed")
+		requestLogger(c).Warn("init.sh failed", slog.String("output", string(out)), slog.Any("error", err))
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to initialize: "+err.Error())
 	}
+	// jobsテーブルは他の言語の実装にはないので、init.sqlではなくここで空にする
+	if err := jobs.clear(c.Request().Context()); err != nil {
+		setNotReady("initialize failed")
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to clear jobs: "+err.Error())
+	}
+	if err := resetInMemoryState(c.Request().Context()); err != nil {
+		setNotReady("initialize failed")
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to reset in-memory state: "+err.Error())
+	}
+	warmUp(c.Request().Context(), requestLogger(c))
+	setReady()
 
 	c.Request().Header.Add("Content-Type", "application/json;charset=utf-8")
 	return c.JSON(http.StatusOK, InitializeResponse{
@@ -119,93 +156,373 @@ func initializeHandler(c echo.Context) error {
 }
 
 func main() {
+	// 集計カラムの検査は、サーバを起動せずに実行して終了する
+	if len(os.Args) > 1 && os.Args[1] == "check-scores" {
+		os.Exit(runCheckScoresCommand(os.Stdout, os.Stderr))
+	}
+	// アイコン画像のファイルへの移行も同様
+	if len(os.Args) > 1 && os.Args[1] == "migrate-icons" {
+		os.Exit(runMigrateIconsCommand(os.Stdout, os.Stderr))
+	}
+
 	e := echo.New()
 	e.Debug = true
-	e.Logger.SetLevel(echolog.DEBUG)
-	e.Use(middleware.Logger())
-	cookieStore := sessions.NewCookieStore(secret)
-	cookieStore.Options.Domain = "*.u.isucon.dev"
-	e.Use(session.Middleware(cookieStore))
+	e.JSONSerializer = newJSONSerializer()
+	// 設定は起動時に一度だけ読み込む
+	cfg, err := config.Load()
+	if err != nil {
+		e.Logger.Errorf("invalid configuration:\n%v", err)
+		os.Exit(1)
+	}
+	secret = cfg.Session.Secret
+	for _, id := range cfg.Session.AdminUserIDs {
+		adminUserIDs[id] = struct{}{}
+	}
+	logger, logLevel := newLogger(os.Stdout, cfg.Log)
+	appLogger = logger
+	e.Logger.SetLevel(echoLogLevel(logLevel))
+	for _, line := range cfg.Summary() {
+		e.Logger.Infof("config: %s", line)
+	}
+	e.Use(requestIDMiddleware)
+	e.Use(tracingMiddleware)
+	e.Use(accessLogMiddleware)
+	e.Use(routeStatsMiddleware)
+	// キャッシュはセッションの保存にも使うので、最初に用意する
+	sharedCache = newSharedCache(cfg.Cache)
+	// 複数台でセッションを共有できるときのみ、セッションの中身をキャッシュに置く
+	flagServerSessions.set(cfg.Cache.Backend == config.CacheBackendRedis)
+	if err := setFeatureFlags(cfg.FeatureFlags); err != nil {
+		e.Logger.Errorf("invalid configuration: %v", err)
+		os.Exit(1)
+	}
+	// プリフライトはセッションを読まずに返す
+	if cors := newCORSMiddleware(cfg.CORS); cors != nil {
+		e.Use(cors)
+	}
+	// セッションの読み込みも含めて打ち切れるよう、セッションより前に置く
+	if timeout := newTimeoutMiddleware(cfg.Timeouts); timeout != nil {
+		e.Use(timeout)
+	}
+	e.Use(session.Middleware(newSessionStore()))
+	// ログインしていればユーザごとに数えるので、セッションより後に置く
+	if rateLimit := newRateLimitMiddleware(cfg.RateLimits); rateLimit != nil {
+		e.Use(rateLimit)
+	}
+	if compress := newCompressMiddleware(cfg.Gzip); compress != nil {
+		e.Use(compress)
+	}
+	// 大きすぎるリクエストはDBに届く前に断る
+	e.Use(newBodyLimitMiddleware(cfg.Limits))
+	iconMaxDimension = cfg.Limits.IconMaxDimension
 	// e.Use(middleware.Recover())
 
+	// APIのエンドポイントは、OpenAPIのドキュメントを作るためにリクエスト・レスポンスの型とともに登録する
+	api := newAPIRegistry(e)
+	e.GET("/openapi.json", api.getOpenAPIHandler)
+
 	// 初期化
-	e.POST("/api/initialize", initializeHandler)
+	api.POST("/api/initialize", initializeHandler).returns(http.StatusOK, InitializeResponse{})
 
 	// top
-	e.GET("/api/tag", getTagHandler)
-	e.GET("/api/user/:username/theme", getStreamerThemeHandler)
+	api.GET("/api/tag", getTagHandler, tagsResponseCache.middleware).returns(http.StatusOK, TagsResponse{})
+	// 管理者によるタグ追加
+	api.POST("/api/tag", postTagHandler).accepts(PostTagRequest{}).returns(http.StatusCreated, TagWithCount{})
+	api.GET("/api/user/:username/theme", getStreamerThemeHandler, themeResponseCache.middleware).returns(http.StatusOK, Theme{})
 
 	// livestream
 	// reserve livestream
-	e.POST("/api/livestream/reservation", reserveLivestreamHandler)
+	api.POST("/api/livestream/reservation", reserveLivestreamHandler).accepts(ReserveLivestreamRequest{}).returns(http.StatusCreated, Livestream{})
 	// list livestream
-	e.GET("/api/livestream/search", searchLivestreamsHandler)
-	e.GET("/api/livestream", getMyLivestreamsHandler)
-	e.GET("/api/user/:username/livestream", getUserLivestreamsHandler)
+	api.GET("/api/livestream/search", searchLivestreamsHandler).returns(http.StatusOK, []Livestream{})
+	api.GET("/api/livestream/upcoming", getUpcomingLivestreamsHandler).returns(http.StatusOK, []Livestream{})
+	api.GET("/api/livestream/trending", getTrendingLivestreamsHandler).returns(http.StatusOK, []TrendingLivestream{})
+	api.GET("/api/livestream/ranking", getLivestreamRankingHandler, rankingResponseCache.middleware).returns(http.StatusOK, []LivestreamRankingResponseEntry{})
+	api.GET("/api/livestream", getMyLivestreamsHandler).returns(http.StatusOK, []Livestream{})
+	api.GET("/api/user/:username/livestream", getUserLivestreamsHandler).returns(http.StatusOK, []UserLivestream{})
 	// get livestream
-	e.GET("/api/livestream/:livestream_id", getLivestreamHandler)
+	api.GET("/api/livestream/:livestream_id", getLivestreamHandler).returns(http.StatusOK, Livestream{})
+	// 配信者によるタグの付け替え
+	api.PUT("/api/livestream/:livestream_id/tags", putLivestreamTagsHandler).accepts(PutLivestreamTagsRequest{}).returns(http.StatusOK, Livestream{})
+	// 配信者による配信設定 (低速モードなど) の更新
+	api.PUT("/api/livestream/:livestream_id/settings", putLivestreamSettingsHandler).accepts(PutLivestreamSettingsRequest{}).returns(http.StatusOK, LivestreamSettings{})
+	// 配信者による共同配信者の追加
+	api.POST("/api/livestream/:livestream_id/collaborator", postLivestreamCollaboratorHandler).accepts(PostLivestreamCollaboratorRequest{}).returns(http.StatusCreated, User{})
+	// 配信者によるストリームキーの取得・再発行
+	api.GET("/api/livestream/:livestream_id/ingest", getLivestreamIngestHandler).returns(http.StatusOK, LivestreamIngest{})
+	api.POST("/api/livestream/:livestream_id/ingest/rotate", rotateLivestreamIngestHandler).returns(http.StatusOK, LivestreamIngest{})
 	// get polling livecomment timeline
-	e.GET("/api/livestream/:livestream_id/livecomment", getLivecommentsHandler)
+	api.GET("/api/livestream/:livestream_id/livecomment", getLivecommentsHandler).returns(http.StatusOK, []Livecomment{})
+	// ライブコメントなどをWebSocketで受け取る
+	api.GET("/api/livestream/:livestream_id/ws", getLivestreamWebSocketHandler).returns(http.StatusSwitchingProtocols, nil)
+	// WebSocketを使えないクライアント向けに、同じものをSSEで受け取る
+	api.GET("/api/livestream/:livestream_id/events", getLivestreamEventsHandler).returns(http.StatusOK, nil)
 	// ライブコメント投稿
-	e.POST("/api/livestream/:livestream_id/livecomment", postLivecommentHandler)
-	e.POST("/api/livestream/:livestream_id/reaction", postReactionHandler)
-	e.GET("/api/livestream/:livestream_id/reaction", getReactionsHandler)
+	api.POST("/api/livestream/:livestream_id/livecomment", postLivecommentHandler).accepts(PostLivecommentRequest{}).returns(http.StatusCreated, Livecomment{})
+	api.POST("/api/livestream/:livestream_id/reaction", postReactionHandler).accepts(PostReactionRequest{}).returns(http.StatusCreated, Reaction{})
+	api.GET("/api/livestream/:livestream_id/reaction", getReactionsHandler).returns(http.StatusOK, []Reaction{})
+	api.GET("/api/livestream/:livestream_id/reaction/summary", getReactionSummaryHandler).returns(http.StatusOK, []ReactionCount{})
+	// リアクションに使える絵文字
+	api.GET("/api/reaction/emojis", getReactionEmojisHandler).returns(http.StatusOK, ReactionEmojisResponse{})
 
 	// (配信者向け)ライブコメントの報告一覧取得API
-	e.GET("/api/livestream/:livestream_id/report", getLivecommentReportsHandler)
-	e.GET("/api/livestream/:livestream_id/ngwords", getNgwords)
+	api.GET("/api/livestream/:livestream_id/report", getLivecommentReportsHandler).returns(http.StatusOK, []LivecommentReport{})
+	api.GET("/api/livestream/:livestream_id/ngwords", getNgwords).returns(http.StatusOK, []*NGWord{})
+	// スパムらしさの高い順のライブコメント一覧
+	api.GET("/api/livestream/:livestream_id/moderation/livecomment", getModerationLivecommentsHandler).returns(http.StatusOK, []ModerationLivecomment{})
 	// ライブコメント報告
-	e.POST("/api/livestream/:livestream_id/livecomment/:livecomment_id/report", reportLivecommentHandler)
+	api.POST("/api/livestream/:livestream_id/livecomment/:livecomment_id/report", reportLivecommentHandler).returns(http.StatusCreated, LivecommentReport{})
+	// ライブコメントのピン留め
+	api.POST("/api/livestream/:livestream_id/livecomment/:livecomment_id/pin", pinLivecommentHandler).returns(http.StatusOK, Livecomment{})
 	// 配信者によるモデレーション (NGワード登録)
-	e.POST("/api/livestream/:livestream_id/moderate", moderateHandler)
+	api.POST("/api/livestream/:livestream_id/moderate", moderateHandler).accepts(ModerateRequest{}).returns(http.StatusCreated, ModerateResponse{})
+	// 配信者によるスーパーチャット削除
+	api.DELETE("/api/livestream/:livestream_id/superchat/:superchat_id", deleteSuperchatHandler).returns(http.StatusNoContent, nil)
+	// 配信者による、自分のチャンネル (すべての配信) からのユーザの締め出し
+	api.POST("/api/channel/:channel_id/ban/:user_id", postChannelBanHandler).returns(http.StatusCreated, ChannelBan{})
+	api.DELETE("/api/channel/:channel_id/ban/:user_id", deleteChannelBanHandler).returns(http.StatusNoContent, nil)
 
 	// livestream_viewersにINSERTするため必要
 	// ユーザ視聴開始 (viewer)
-	e.POST("/api/livestream/:livestream_id/enter", enterLivestreamHandler)
+	api.POST("/api/livestream/:livestream_id/enter", enterLivestreamHandler).returns(http.StatusOK, nil)
 	// ユーザ視聴終了 (viewer)
-	e.DELETE("/api/livestream/:livestream_id/exit", exitLivestreamHandler)
+	api.DELETE("/api/livestream/:livestream_id/exit", exitLivestreamHandler).returns(http.StatusOK, nil)
 
 	// user
-	e.POST("/api/register", registerHandler)
-	e.POST("/api/login", loginHandler)
-	e.GET("/api/user/me", getMeHandler)
+	api.POST("/api/register", registerHandler).accepts(PostUserRequest{}).returns(http.StatusCreated, User{})
+	api.POST("/api/login", loginHandler).accepts(LoginRequest{}).returns(http.StatusOK, nil)
+	api.GET("/api/user/me", getMeHandler).returns(http.StatusOK, User{})
+	api.DELETE("/api/user/me", deleteMeHandler).returns(http.StatusNoContent, nil)
+	// 配信者向け収益レポート
+	api.GET("/api/user/me/earnings", getMyEarningsHandler).returns(http.StatusOK, EarningsReport{})
+	// 視聴履歴
+	api.GET("/api/user/me/history", getMyWatchHistoryHandler).returns(http.StatusOK, []WatchHistoryEntry{})
+	// 通知
+	api.GET("/api/user/me/notifications", getMyNotificationsHandler).returns(http.StatusOK, NotificationsResponse{})
+	api.POST("/api/user/me/notifications/read", postNotificationsReadHandler).accepts(PostNotificationsReadRequest{}).returns(http.StatusOK, NotificationsReadResponse{})
+	// ホーム画面で使う情報をまとめて返す
+	api.GET("/api/home", getHomeHandler).returns(http.StatusOK, HomeResponse{})
 	// フロントエンドで、配信予約のコラボレーターを指定する際に必要
-	e.GET("/api/user/:username", getUserHandler)
-	e.GET("/api/user/:username/statistics", getUserStatisticsHandler)
-	e.GET("/api/user/:username/icon", getIconHandler)
-	e.POST("/api/icon", postIconHandler)
+	api.GET("/api/user/:username", getUserHandler).returns(http.StatusOK, User{})
+	api.GET("/api/user/:username/statistics", getUserStatisticsHandler).returns(http.StatusOK, UserStatistics{})
+	api.GET("/api/user/:username/icon", getIconHandler).returns(http.StatusOK, nil).returns(http.StatusNotModified, nil)
+	api.POST(iconUploadPath, postIconHandler).accepts(PostIconRequest{}).returns(http.StatusCreated, PostIconResponse{})
 
 	// stats
 	// ライブ配信統計情報
-	e.GET("/api/livestream/:livestream_id/statistics", getLivestreamStatisticsHandler)
+	api.GET("/api/livestream/:livestream_id/statistics", getLivestreamStatisticsHandler).returns(http.StatusOK, LivestreamStatistics{})
+	// スーパーチャットのチップを多く送ったユーザ
+	api.GET("/api/livestream/:livestream_id/leaderboard", getLivestreamTipLeaderboardHandler).returns(http.StatusOK, []TipperLeaderboardEntry{})
+	api.GET("/api/leaderboard/tippers", getTipperLeaderboardHandler).returns(http.StatusOK, []TipperLeaderboardEntry{})
 
 	// 課金情報
-	e.GET("/api/payment", GetPaymentResult)
+	api.GET("/api/payment", GetPaymentResult).returns(http.StatusOK, PaymentResult{})
+
+	// 生存確認・準備完了の確認
+	e.GET("/healthz", getHealthzHandler)
+	e.GET("/readyz", getReadyzHandler)
+
+	// 性能比較のための機能フラグの切り替え (管理者のみ)
+	api.GET("/admin/flags", getFeatureFlagsHandler).returns(http.StatusOK, []FeatureFlag{})
+	api.PUT("/admin/flags", putFeatureFlagsHandler).accepts(map[string]bool{}).returns(http.StatusOK, []FeatureFlag{})
+	// 競技中の状態の確認 (管理者のみ)
+	api.GET("/admin/dashboard", getAdminDashboardHandler).returns(http.StatusOK, AdminDashboard{})
+	// 管理・モデレーションの操作の監査ログ (管理者のみ)
+	api.GET("/admin/audit_logs", getAuditLogsHandler).returns(http.StatusOK, []AuditLog{})
+	// リアクションに使える絵文字の追加・削除 (管理者のみ)
+	api.POST("/admin/reaction/emojis", postReactionEmojiHandler).accepts(PostReactionEmojiRequest{}).returns(http.StatusCreated, ReactionEmojisResponse{})
+	api.DELETE("/admin/reaction/emojis/:name", deleteReactionEmojiHandler).returns(http.StatusNoContent, nil)
+
+	// 他のアプリケーションサーバからのキャッシュの無効化の通知
+	// ISUCON13_PEERSを指定したときのみ受け付ける
+	if len(cfg.Peers.Addrs) > 0 {
+		e.POST(peerInvalidatePath, postPeerInvalidateHandler)
+	}
 
 	e.HTTPErrorHandler = errorResponseHandler
 
+	// トレースはDBに接続する前に用意し、クエリも記録できるようにする
+	appTracer, traceExporter := newTracer(cfg.Tracing)
+	tracer = appTracer
+	if traceExporter != nil {
+		go traceExporter.Run(e.Logger.Errorf)
+	}
+
+	// クエリの集計も、DBに接続する前に用意する
+	queryStats = newQueryStats(cfg.Debug)
+
 	// DB接続
-	conn, err := connectDB(e.Logger)
+	dbConf := newDBConfig(cfg.DB)
+	dbPool := newDBPoolConfig(cfg.DB)
+	conn, err := connectDB(dbConf, dbPool)
 	if err != nil {
 		e.Logger.Errorf("failed to connect db: %v", err)
 		os.Exit(1)
 	}
-	defer conn.Close()
 	dbConn = conn
 
-	subdomainAddr, ok := os.LookupEnv(powerDNSSubdomainAddressEnvKey)
-	if !ok {
-		e.Logger.Errorf("environ %s must be provided", powerDNSSubdomainAddressEnvKey)
+	// レプリカにはプライマリから複製されるので、プライマリにのみ適用する
+	if cfg.DB.Migrate {
+		if err := applyMigrations(context.Background(), dbConn, e.Logger); err != nil {
+			e.Logger.Errorf("failed to apply migrations: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	// 一覧や統計情報などの読み取りはレプリカに振り分ける
+	replicaSet, err := connectReplicas(dbConf, dbPool, cfg.DB.ReplicaHosts, e.Logger)
+	if err != nil {
+		e.Logger.Errorf("failed to connect replica db: %v", err)
 		os.Exit(1)
 	}
-	powerDNSSubdomainAddress = subdomainAddr
+	replicas = replicaSet
+	go replicas.runHealthChecker(e.Logger)
+
+	// ホットパスのクエリをプリペアしておく
+	if cfg.DB.StmtCache {
+		if err := prepareHotStatements(context.Background(), dbConn); err != nil {
+			e.Logger.Errorf("failed to prepare statements: %v", err)
+			os.Exit(1)
+		}
+		// 起動時に到達できないレプリカは、プリペアせずに使う
+		for _, r := range replicas.replicas {
+			if err := prepareHotStatements(context.Background(), r.db); err != nil {
+				e.Logger.Warnf("failed to prepare statements on replica %s: %v", r.name, err)
+			}
+		}
+	}
 
-	// HTTPサーバ起動
-	listenAddr := net.JoinHostPort("", strconv.Itoa(listenPort))
-	if err := e.Start(listenAddr); err != nil {
-		e.Logger.Errorf("failed to start HTTP server: %v", err)
+	// タグのマスタをメモリに読み込んでおく
+	if err := tagMaster.load(context.Background(), dbConn); err != nil {
+		e.Logger.Errorf("failed to load tags: %v", err)
 		os.Exit(1)
 	}
+
+	// 低速モードの状態を復元し、定期的に書き出す
+	if err := slowMode.load(context.Background(), dbConn); err != nil {
+		e.Logger.Errorf("failed to load slow mode state: %v", err)
+		os.Exit(1)
+	}
+	// webとworkerのどちらの処理を行うか
+	// メモリ上の状態を使う処理はリクエストを受けるサーバで、DBだけで完結する重い処理はworkerで行う
+	runsWeb := cfg.Server.Role != config.RoleWorker
+	runsWorker := cfg.Server.Role != config.RoleWeb
+	if runsWeb {
+		go slowMode.runPersister(dbConn, e.Logger)
+	}
+
+	// チップのリーダーボードを復元し、定期的に書き出す
+	if err := tipLeaderboard.load(context.Background(), dbConn); err != nil {
+		e.Logger.Errorf("failed to load tipper totals: %v", err)
+		os.Exit(1)
+	}
+	if runsWeb {
+		go tipLeaderboard.runPersister(dbConn, e.Logger)
+	}
+
+	// チャンネルからの締め出しをメモリに読み込んでおく
+	if err := channelBans.load(context.Background(), dbConn); err != nil {
+		e.Logger.Errorf("failed to load channel bans: %v", err)
+		os.Exit(1)
+	}
+	// リアクションに使える絵文字をメモリに読み込んでおく
+	if err := reactionEmojis.load(context.Background(), dbConn); err != nil {
+		e.Logger.Errorf("failed to load reaction emojis: %v", err)
+		os.Exit(1)
+	}
+
+	// ライブ配信ランキングはバックグラウンドで再計算する
+	if err := livestreamRanking.refresh(context.Background(), dbConn); err != nil {
+		e.Logger.Warnf("failed to refresh livestream ranking: %v", err)
+	}
+	if runsWeb {
+		go livestreamRanking.run(dbConn, e.Logger)
+	}
+
+	if runsWorker {
+		// 統計情報の集計 (機能フラグprecomputed_statisticsが有効なときのみ)
+		// workerは管理者による機能フラグの切り替えを受け取らないので、起動時のISUCON13_FEATURE_FLAGSに従う
+		go runStatisticsAggregator(dbConn, e.Logger)
+
+		// 保持期間を過ぎた論理削除済みの行を物理削除する
+		go runSoftDeletePurger(dbConn, cfg.SoftDelete, e.Logger)
+	}
+
+	subdomains = newSubdomainProvisioner(cfg.PowerDNS)
+
+	icons, err = newIconStorage(cfg.Icons)
+	if err != nil {
+		e.Logger.Errorf("failed to initialize icon storage: %v", err)
+		os.Exit(1)
+	}
+	iconCache = newIconLRU(cfg.Icons.CacheBytes)
+	warmUpSettings.Warmup = cfg.Warmup
+	warmUpSettings.conns = cfg.DB.MaxIdleConns
+
+	// 書き込みのイベントを各機能に届ける
+	registerJobHandlers(jobs)
+	subscribeEventHandlers(events)
+	go events.run(e.Logger)
+	if runsWorker {
+		// 配信の開始はリクエストを伴わないので、定期的に探して通知する
+		go runLivestreamStartWatcher(dbConn, e.Logger)
+		// DNSのレコードのやり直しや通知の書き込みのジョブを実行する
+		go jobs.run(dbConn, e.Logger)
+	}
+
+	// メモリ上のキャッシュの無効化を他のアプリケーションサーバに伝える
+	peers = newPeerNotifier(cfg.Peers.Addrs, cfg.Peers.Secret)
+	peers.run(e.Logger)
+
+	// リアクションや入退室の書き込みはまとめて行う
+	if runsWeb {
+		runBatchWriters(e.Logger)
+	}
+
+	// DEBUG=1 のときは、ベンチマーク中にプロファイルを取れるようにする
+	runDebugServer(cfg.Debug, e.Logger)
+
+	drainTimeout := cfg.Server.ShutdownTimeout
+	exitCode := 0
+	if runsWeb {
+		// キャッシュの読み込みが済んだので、リクエストを受け付けられる
+		setReady()
+
+		// HTTPサーバ起動
+		listener, listenAddr, err := newListener(cfg.Server)
+		if err != nil {
+			e.Logger.Errorf("failed to listen: %v", err)
+			os.Exit(1)
+		}
+		e.Logger.Infof("listening on %s", listenAddr)
+		if err := serve(e, listener, cfg.Server); err != nil {
+			e.Logger.Errorf("HTTP server stopped: %v", err)
+			exitCode = 1
+		}
+	} else {
+		e.Logger.Infof("running as %s", cfg.Server.Role)
+		waitForShutdownSignal()
+	}
+
+	// 終了するときは、リクエストを受け付けるのをやめてから溜まっている書き込みを書き出し、最後にDBとの接続を閉じる
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	if err := flushOnShutdown(shutdownCtx); err != nil {
+		e.Logger.Errorf("%v", err)
+		exitCode = 1
+	}
+	if err := shutdownTracer(shutdownCtx, traceExporter); err != nil {
+		e.Logger.Errorf("failed to export pending spans: %v", err)
+	}
+	cancel()
+	closePreparedStatements()
+	replicas.close()
+	if err := dbConn.Close(); err != nil {
+		e.Logger.Errorf("failed to close db: %v", err)
+	}
+	if closer, ok := sharedCache.(io.Closer); ok {
+		closer.Close()
+	}
+	os.Exit(exitCode)
 }
 
 type ErrorResponse struct {
@@ -213,15 +530,29 @@ type ErrorResponse struct {
 }
 
 func errorResponseHandler(err error, c echo.Context) {
-	c.Logger().Errorf("error at %s: %+v", c.Path(), err)
+	// ドメインエラーは対応するステータスコードのHTTPエラーとして扱う
+	if code, ok := apperror.HTTPStatus(err); ok {
+		err = echo.NewHTTPError(code, err.Error())
+	}
+
+	logger := requestLogger(c)
 	if he, ok := err.(*echo.HTTPError); ok {
+		// クライアント側の誤りは、サーバの異常と区別できるよう警告として出力する
+		if he.Code < http.StatusInternalServerError {
+			logger.Warn("request failed", slog.Int("status", he.Code), slog.Any("error", err))
+		} else {
+			logger.Error("request failed", slog.Int("status", he.Code), slog.Any("error", err))
+			recentErrors.record(c, he.Code, err)
+		}
 		if e := c.JSON(he.Code, &ErrorResponse{Error: err.Error()}); e != nil {
-			c.Logger().Errorf("%+v", e)
+			logger.Error("failed to write error response", slog.Any("error", e))
 		}
 		return
 	}
 
+	logger.Error("request failed", slog.Int("status", http.StatusInternalServerError), slog.Any("error", err))
+	recentErrors.record(c, http.StatusInternalServerError, err)
 	if e := c.JSON(http.StatusInternalServerError, &ErrorResponse{Error: err.Error()}); e != nil {
-		c.Logger().Errorf("%+v", e)
+		logger.Error("failed to write error response", slog.Any("error", e))
 	}
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// memo は、キーごとの重い計算を同時に1回だけ実行し、結果を短い間使い回します
+// 同じキーで同時に来たリクエストはsingleflightで1回の計算を待ち合わせ、
+// 計算が済んでからttlの間は、DBを引かずに同じ結果を返します
+// 結果はsharedCacheにJSONで保存するので、アプリケーションサーバ間でも使い回せます
+// エラーになった計算の結果は使い回しません
+type memo[T any] struct {
+	// sharedCacheのキーの接頭辞
+	name  string
+	ttl   time.Duration
+	group singleflight.Group
+	stats *cacheStats
+}
+
+func newMemo[T any](name string, ttl time.Duration) *memo[T] {
+	return &memo[T]{
+		name:  name,
+		ttl:   ttl,
+		stats: newCacheStats("memo:" + name),
+	}
+}
+
+// do は、keyに対する計算結果を返します
+// 計算は最初のリクエストのctxのキャンセルに巻き込まれないよう、キャンセルを切り離したctxで実行します
+func (m *memo[T]) do(ctx context.Context, key string, fn func(ctx context.Context) (T, error)) (T, error) {
+	value, ok := m.load(ctx, key)
+	m.stats.record(ok)
+	if ok {
+		return value, nil
+	}
+
+	v, err, _ := m.group.Do(key, func() (interface{}, error) {
+		ctx := context.WithoutCancel(ctx)
+		value, err := fn(ctx)
+		if err != nil {
+			return value, err
+		}
+		m.store(ctx, key, value)
+		return value, nil
+	})
+	return v.(T), err
+}
+
+// load は、保存済みの結果を返します
+// キャッシュが使えなくても計算し直せばよいので、キャッシュのエラーはなかったものとして扱います
+func (m *memo[T]) load(ctx context.Context, key string) (T, bool) {
+	var value T
+	b, ok, err := sharedCache.Get(ctx, m.name+":"+key)
+	if err != nil || !ok {
+		return value, false
+	}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return value, false
+	}
+	return value, true
+}
+
+func (m *memo[T]) store(ctx context.Context, key string, value T) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	_ = sharedCache.Set(ctx, m.name+":"+key, b, m.ttl)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"embed"
+
+	"github.com/isucon/isucon13/webapp/go/internal/migrate"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// 10_schema.sqlで作った後に加えるテーブルやインデックスは、migrationsにSQLファイルとして追加する
+// 起動時に適用するので、複数台のサーバで.sqlファイルを揃えて流し直さなくて済む
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// applyMigrations は、埋め込んだSQLファイルのうち、まだ適用していないものをdbに適用します
+func applyMigrations(ctx context.Context, db *sqlx.DB, logger echo.Logger) error {
+	migrations, err := migrate.Load(migrationFiles, "migrations")
+	if err != nil {
+		return err
+	}
+	applied, err := migrate.Apply(ctx, db.DB, migrations)
+	for _, m := range applied {
+		logger.Infof("applied migration %s", m.Name)
+	}
+	return err
+}
@@ -0,0 +1,21 @@
+-- 10_schema.sqlより前に作ったDBにも、チャンネルからの締め出しと監査ログのテーブルを用意する
+-- 10_schema.sqlから作ったDBには既にあるので、IF NOT EXISTSで何もしない
+
+CREATE TABLE IF NOT EXISTS `channel_bans` (
+  `streamer_id` BIGINT NOT NULL,
+  `user_id` BIGINT NOT NULL,
+  `created_at` BIGINT NOT NULL,
+  PRIMARY KEY (`streamer_id`, `user_id`)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+
+CREATE TABLE IF NOT EXISTS `audit_logs` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  `kind` VARCHAR(64) NOT NULL,
+  `actor_id` BIGINT NOT NULL,
+  `livestream_id` BIGINT NOT NULL DEFAULT 0,
+  `livecomment_id` BIGINT NOT NULL DEFAULT 0,
+  `target_user_id` BIGINT NOT NULL DEFAULT 0,
+  `detail` TEXT NOT NULL,
+  `created_at` BIGINT NOT NULL,
+  INDEX `idx_created_at` (`created_at`)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
@@ -0,0 +1,15 @@
+-- アプリケーションの中で後から実行する副作用 (DNSのレコード、通知の書き込み) のジョブ
+-- 時刻はUNIX時間 (ミリ秒) で、failed_atのみ秒。failed_atが入った行は諦めたジョブとして残す
+
+CREATE TABLE IF NOT EXISTS `jobs` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  `kind` VARCHAR(64) NOT NULL,
+  `payload` BLOB NOT NULL,
+  `attempts` BIGINT NOT NULL DEFAULT 0,
+  `run_at` BIGINT NOT NULL,
+  `locked_until` BIGINT NOT NULL DEFAULT 0,
+  `last_error` TEXT NOT NULL,
+  `failed_at` BIGINT NULL,
+  `created_at` BIGINT NOT NULL,
+  INDEX `idx_failed_at_run_at` (`failed_at`, `run_at`)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
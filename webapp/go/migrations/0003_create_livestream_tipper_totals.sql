@@ -0,0 +1,14 @@
+-- 配信ごと・ユーザごとのスーパーチャットのチップの合計。チップのリーダーボードで使う
+-- 作ったときに、既にあるライブコメントから数えておく
+
+CREATE TABLE IF NOT EXISTS `livestream_tipper_totals` (
+  `livestream_id` BIGINT NOT NULL,
+  `user_id` BIGINT NOT NULL,
+  `total_tip` BIGINT NOT NULL,
+  PRIMARY KEY (`livestream_id`, `user_id`)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+
+INSERT IGNORE INTO `livestream_tipper_totals` (`livestream_id`, `user_id`, `total_tip`)
+SELECT `livestream_id`, `user_id`, SUM(`tip`) FROM `livecomments`
+WHERE `tip` > 0 AND `deleted_at` IS NULL
+GROUP BY `livestream_id`, `user_id`;
@@ -0,0 +1,8 @@
+-- リアクションに使える絵文字。POST /admin/reaction/emojis と DELETE /admin/reaction/emojis/:name で変更する
+-- 初期値は sql/initial_reaction_emojis.sql にあり、初期化 (init.sh) のたびに入れ直す
+-- 10_schema.sqlから作ったDBには既にあるので、IF NOT EXISTSで何もしない
+
+CREATE TABLE IF NOT EXISTS `reaction_emojis` (
+  `name` VARCHAR(255) NOT NULL PRIMARY KEY,
+  `created_at` BIGINT NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
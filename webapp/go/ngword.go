@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ngWordMatcher は、NGワードの集合に対するAho–Corasick法の照合器です
+// NGワード中の % や _ はワイルドカードとして扱いません
+// 英単語のように空白で区切る文字が前後に続く一致は、別の語の一部なので一致とみなしません ("class" 中の "ass" など)
+// 日本語の文には単語の区切りがないため、漢字・ひらがな・カタカナの前後はどこでも語の境界として扱います
+type ngWordMatcher struct {
+	nodes []ngWordMatcherNode
+	// スパムらしさの計算に使う、空文字列を除いたNGワード
+	words []string
+}
+
+type ngWordMatcherNode struct {
+	next map[rune]int
+	fail int
+	// このノードまでの文字列の接尾辞と一致するNGワードの文字数 (長い順)
+	hitLens []int
+}
+
+// 伏せ字に使う文字
+const ngWordMaskRune = '*'
+
+// newNGWordMatcher は、wordsから照合器を構築します。空文字列のNGワードは無視します
+func newNGWordMatcher(words []string) *ngWordMatcher {
+	m := &ngWordMatcher{
+		nodes: []ngWordMatcherNode{{next: map[rune]int{}}},
+	}
+
+	// トライ木を構築
+	for _, word := range words {
+		if word == "" {
+			continue
+		}
+		m.words = append(m.words, word)
+		cur := 0
+		length := 0
+		for _, r := range word {
+			length++
+			next, ok := m.nodes[cur].next[r]
+			if !ok {
+				next = len(m.nodes)
+				m.nodes = append(m.nodes, ngWordMatcherNode{next: map[rune]int{}})
+				m.nodes[cur].next[r] = next
+			}
+			cur = next
+		}
+		m.nodes[cur].hitLens = mergeHitLens(m.nodes[cur].hitLens, []int{length})
+	}
+
+	// 幅優先で失敗遷移を張る
+	queue := make([]int, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[cur].next {
+			fail := m.nodes[cur].fail
+			for {
+				if next, ok := m.nodes[fail].next[r]; ok {
+					m.nodes[child].fail = next
+					break
+				}
+				if fail == 0 {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			m.nodes[child].hitLens = mergeHitLens(m.nodes[child].hitLens, m.nodes[m.nodes[child].fail].hitLens)
+			queue = append(queue, child)
+		}
+	}
+
+	return m
+}
+
+// mergeHitLens は、長い順に並んだ文字数の列a, bを、重複を除いて長い順に並べた列にします
+func mergeHitLens(a, b []int) []int {
+	merged := make([]int, 0, len(a)+len(b))
+	for len(a) > 0 || len(b) > 0 {
+		var n int
+		switch {
+		case len(b) == 0 || (len(a) > 0 && a[0] >= b[0]):
+			n, a = a[0], a[1:]
+		default:
+			n, b = b[0], b[1:]
+		}
+		if len(merged) == 0 || merged[len(merged)-1] != n {
+			merged = append(merged, n)
+		}
+	}
+	return merged
+}
+
+// isWordRune は、空白で区切る言語で、前後に続けて書くと1つの語になる文字かを返します
+func isWordRune(r rune) bool {
+	// 長音符 (ー) と踊り字 (々) は、文字種としては漢字・カタカナに含まれない
+	if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) || r == 'ー' || r == '々' {
+		return false
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// atWordBoundary は、runes[start:end+1]の一致の前後が語の境界であるかを返します
+func atWordBoundary(runes []rune, start, end int) bool {
+	if start > 0 && isWordRune(runes[start-1]) && isWordRune(runes[start]) {
+		return false
+	}
+	if end+1 < len(runes) && isWordRune(runes[end]) && isWordRune(runes[end+1]) {
+		return false
+	}
+	return true
+}
+
+// step は、curの状態からrを読んだ後の状態を返します
+func (m *ngWordMatcher) step(cur int, r rune) int {
+	for {
+		if next, ok := m.nodes[cur].next[r]; ok {
+			return next
+		}
+		if cur == 0 {
+			return 0
+		}
+		cur = m.nodes[cur].fail
+	}
+}
+
+// Match は、textがいずれかのNGワードを含むかを返します
+func (m *ngWordMatcher) Match(text string) bool {
+	runes := []rune(text)
+	cur := 0
+	for i, r := range runes {
+		cur = m.step(cur, r)
+		for _, hitLen := range m.nodes[cur].hitLens {
+			if atWordBoundary(runes, i-hitLen+1, i) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Mask は、text中のNGワードに一致する部分を伏せ字にした文字列を返します
+func (m *ngWordMatcher) Mask(text string) string {
+	runes := []rune(text)
+	masked := make([]bool, len(runes))
+	cur := 0
+	for i, r := range runes {
+		cur = m.step(cur, r)
+		// 境界を満たす一致のうち最長のものを伏せれば、短いものも含まれる
+		for _, hitLen := range m.nodes[cur].hitLens {
+			if atWordBoundary(runes, i-hitLen+1, i) {
+				for j := i - hitLen + 1; j <= i; j++ {
+					masked[j] = true
+				}
+				break
+			}
+		}
+	}
+
+	var sb strings.Builder
+	for i, r := range runes {
+		if masked[i] {
+			r = ngWordMaskRune
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// ngWordMatcherCache は、ライブ配信ごとに構築した照合器を保持します
+// NGワードが追加された際はinvalidateで破棄し、次回の照合時に構築し直します
+type ngWordMatcherCache struct {
+	mu       sync.RWMutex
+	matchers map[int64]*ngWordMatcher
+	// invalidateのたびに増やし、読み込み中に破棄された古い照合器を保存しないようにする
+	generation uint64
+}
+
+var (
+	ngWordMatchers = &ngWordMatcherCache{
+		matchers: make(map[int64]*ngWordMatcher),
+	}
+	ngWordCacheStats = newCacheStats("ngwords")
+)
+
+// get は、ライブ配信の照合器を返します。キャッシュになければqから読み込んで構築します
+func (c *ngWordMatcherCache) get(ctx context.Context, q sqlx.QueryerContext, livestreamID int64) (*ngWordMatcher, error) {
+	c.mu.RLock()
+	m, ok := c.matchers[livestreamID]
+	generation := c.generation
+	c.mu.RUnlock()
+	ngWordCacheStats.record(ok)
+	if ok {
+		return m, nil
+	}
+
+	m, err := loadNGWordMatcher(ctx, q, livestreamID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		c.matchers[livestreamID] = m
+	}
+	c.mu.Unlock()
+
+	return m, nil
+}
+
+func (c *ngWordMatcherCache) invalidate(livestreamID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.matchers, livestreamID)
+	c.generation++
+}
+
+func (c *ngWordMatcherCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.matchers = make(map[int64]*ngWordMatcher)
+	c.generation++
+}
+
+// loadNGWordMatcher は、キャッシュを介さずにライブ配信の照合器を構築します
+func loadNGWordMatcher(ctx context.Context, q sqlx.QueryerContext, livestreamID int64) (*ngWordMatcher, error) {
+	var words []string
+	if err := sqlx.SelectContext(ctx, q, &words, "SELECT word FROM ng_words WHERE livestream_id = ?", livestreamID); err != nil {
+		return nil, err
+	}
+	return newNGWordMatcher(words), nil
+}
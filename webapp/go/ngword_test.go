@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestNGWordMatcherMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		text  string
+		want  bool
+	}{
+		{name: "単独の英単語", words: []string{"ass"}, text: "you ass!", want: true},
+		{name: "文頭・文末の英単語", words: []string{"spam"}, text: "spam", want: true},
+		{name: "英単語の一部", words: []string{"ass"}, text: "a classic class", want: false},
+		{name: "英単語の先頭", words: []string{"spam"}, text: "spammer", want: false},
+		{name: "数字が続く", words: []string{"isu"}, text: "isu13", want: false},
+		{name: "一部になっている箇所と単独の箇所がある", words: []string{"ass"}, text: "class ass", want: true},
+		{name: "短いNGワードだけが境界を満たす", words: []string{"bad", "badge"}, text: "badges are bad", want: true},
+		{name: "長いNGワードは境界を満たさないが短いものは満たす", words: []string{"x", "ax"}, text: "bax x", want: true},
+		{name: "日本語は部分一致", words: []string{"ステートフル"}, text: "数のステートフルインスタンス", want: true},
+		{name: "漢字の熟語の途中", words: []string{"政治学"}, text: "全政治学に影響", want: true},
+		{name: "日本語に挟まれた英字", words: []string{"MACアドレス"}, text: "このMACアドレス、どういう理由で？", want: true},
+		{name: "英字に続くカタカナ", words: []string{"MAC"}, text: "MACアドレス", want: true},
+		{name: "一致しない", words: []string{"ass"}, text: "hello", want: false},
+		{name: "空文字列のNGワードは無視する", words: []string{""}, text: "hello", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newNGWordMatcher(tt.words).Match(tt.text); got != tt.want {
+				t.Errorf("Match(%q) with %q = %v, want %v", tt.text, tt.words, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNGWordMatcherMask(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		text  string
+		want  string
+	}{
+		{name: "単独の英単語だけを伏せる", words: []string{"ass"}, text: "class ass", want: "class ***"},
+		{name: "重なるNGワード", words: []string{"bad", "badge"}, text: "badge bad badges", want: "***** *** badges"},
+		{name: "日本語は部分一致で伏せる", words: []string{"政治学"}, text: "全政治学に影響", want: "全***に影響"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newNGWordMatcher(tt.words).Mask(tt.text); got != tt.want {
+				t.Errorf("Mask(%q) with %q = %q, want %q", tt.text, tt.words, got, tt.want)
+			}
+		})
+	}
+}
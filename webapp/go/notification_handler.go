@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	notificationKindLivestreamStarted = "livestream.started"
+	notificationKindLivecommentPinned = "livecomment.pinned"
+
+	// 通知一覧のデフォルトの件数
+	defaultNotificationsLimit = 20
+	// 配信の開始を探す間隔
+	livestreamStartCheckInterval = time.Second
+)
+
+type NotificationModel struct {
+	ID           int64  `db:"id"`
+	UserID       int64  `db:"user_id"`
+	Kind         string `db:"kind"`
+	LivestreamID int64  `db:"livestream_id"`
+	// ライブコメントに関する通知でなければ0
+	LivecommentID int64         `db:"livecomment_id"`
+	CreatedAt     int64         `db:"created_at"`
+	ReadAt        sql.NullInt64 `db:"read_at"`
+}
+
+type Notification struct {
+	ID            int64      `json:"id"`
+	Kind          string     `json:"kind"`
+	Livestream    Livestream `json:"livestream"`
+	LivecommentID int64      `json:"livecomment_id,omitempty"`
+	CreatedAt     int64      `json:"created_at"`
+	Read          bool       `json:"read"`
+}
+
+type NotificationsResponse struct {
+	UnreadCount   int64          `json:"unread_count"`
+	Notifications []Notification `json:"notifications"`
+}
+
+type PostNotificationsReadRequest struct {
+	// 既読にする通知のID。空のときはすべて既読にする
+	IDs []int64 `json:"ids"`
+}
+
+type NotificationsReadResponse struct {
+	UnreadCount int64 `json:"unread_count"`
+}
+
+// 通知の一覧と未読の件数
+// GET /api/user/me/notifications
+func getMyNotificationsHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := verifyUserSession(c); err != nil {
+		// echo.NewHTTPErrorが返っているのでそのまま出力
+		return err
+	}
+
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	limit := defaultNotificationsLimit
+	if c.QueryParam("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(c.QueryParam("limit"))
+		if err != nil || limit < 0 {
+			return apperror.BadRequest("limit query parameter must be non-negative integer")
+		}
+	}
+
+	db := readDB(c)
+
+	query := "SELECT * FROM notifications WHERE user_id = ?"
+	params := []interface{}{userID}
+	cur, ok, err := cursorParam(c)
+	if err != nil {
+		return err
+	}
+	if ok {
+		cond, args := cur.condition("", "id", true)
+		query += " AND " + cond
+		params = append(params, args...)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	params = append(params, limit)
+	var notificationModels []NotificationModel
+	if err := db.SelectContext(ctx, &notificationModels, query, params...); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get notifications: "+err.Error())
+	}
+	setNextCursor(c, len(notificationModels), limit, func() cursor {
+		return cursor{id: notificationModels[len(notificationModels)-1].ID}
+	})
+
+	livestreamIDs := make([]int64, len(notificationModels))
+	for i, n := range notificationModels {
+		livestreamIDs[i] = n.LivestreamID
+	}
+	livestreams, err := loadLivestreams(ctx, db, livestreamIDs)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestreams: "+err.Error())
+	}
+
+	res := NotificationsResponse{Notifications: make([]Notification, 0, len(notificationModels))}
+	for _, n := range notificationModels {
+		livestream, ok := livestreams[n.LivestreamID]
+		if !ok {
+			// 配信が見つからない通知は返さない
+			continue
+		}
+		res.Notifications = append(res.Notifications, Notification{
+			ID:            n.ID,
+			Kind:          n.Kind,
+			Livestream:    livestream,
+			LivecommentID: n.LivecommentID,
+			CreatedAt:     n.CreatedAt,
+			Read:          n.ReadAt.Valid,
+		})
+	}
+	res.UnreadCount, err = countUnreadNotifications(ctx, db, userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count unread notifications: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, &res)
+}
+
+// 通知を既読にする
+// POST /api/user/me/notifications/read
+func postNotificationsReadHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+	defer c.Request().Body.Close()
+
+	if err := verifyUserSession(c); err != nil {
+		// echo.NewHTTPErrorが返っているのでそのまま出力
+		return err
+	}
+
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	var req PostNotificationsReadRequest
+	// ボディを省略したときは、すべて既読にする
+	if c.Request().ContentLength != 0 {
+		if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+			return apperror.BadRequest("failed to decode the request body as json")
+		}
+	}
+
+	query := "UPDATE notifications SET read_at = ? WHERE user_id = ? AND read_at IS NULL"
+	params := []interface{}{time.Now().Unix(), userID}
+	if len(req.IDs) > 0 {
+		var err error
+		query, params, err = sqlx.In(query+" AND id IN (?)", append(params, req.IDs)...)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to construct IN query: "+err.Error())
+		}
+	}
+	if _, err := dbConn.ExecContext(ctx, query, params...); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to mark notifications as read: "+err.Error())
+	}
+
+	// 既読にした直後の件数を返すので、プライマリから読む
+	unread, err := countUnreadNotifications(ctx, dbConn, userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count unread notifications: "+err.Error())
+	}
+	return c.JSON(http.StatusOK, &NotificationsReadResponse{UnreadCount: unread})
+}
+
+func countUnreadNotifications(ctx context.Context, q sqlx.QueryerContext, userID int64) (int64, error) {
+	var n int64
+	err := sqlx.GetContext(ctx, q, &n, "SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read_at IS NULL", userID)
+	return n, err
+}
+
+// notifyLivestreamStarted は、配信が始まったことを、その配信者の配信を視聴したことのあるユーザに通知します
+// 配信者の購読の仕組みはないので、視聴履歴のあるユーザを購読者として扱います
+// 購読者の数だけINSERTを発行しないよう、INSERT ... SELECTの1文で書き込みます
+// 複数のアプリケーションサーバが同じ配信の開始を見つけても、一意制約により1件しか残りません
+func notifyLivestreamStarted(ctx context.Context, userID, livestreamID int64, at time.Time) error {
+	_, err := dbConn.ExecContext(ctx, `
+	INSERT IGNORE INTO notifications (user_id, kind, livestream_id, livecomment_id, created_at)
+	SELECT DISTINCT h.user_id, ?, ?, 0, ?
+	FROM watch_history h
+	INNER JOIN livestreams l ON l.id = h.livestream_id
+	WHERE l.user_id = ? AND h.user_id <> ?`,
+		notificationKindLivestreamStarted, livestreamID, at.Unix(), userID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to notify livestream start: %w", err)
+	}
+	return nil
+}
+
+// notifyLivecommentPinned は、ライブコメントがピン留めされたことを、その投稿者に通知します
+func notifyLivecommentPinned(ctx context.Context, userID, livestreamID, livecommentID int64, at time.Time) error {
+	_, err := dbConn.ExecContext(ctx, "INSERT IGNORE INTO notifications (user_id, kind, livestream_id, livecomment_id, created_at) VALUES (?, ?, ?, ?, ?)",
+		userID, notificationKindLivecommentPinned, livestreamID, livecommentID, at.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to notify pinned livecomment: %w", err)
+	}
+	return nil
+}
+
+// runLivestreamStartWatcher は、開始時刻を過ぎた公開配信を定期的に探し、配信の開始のイベントを発行します
+// 配信の開始はリクエストを伴わないので、前回探した時刻から今までに始まった配信を探します
+// 起動する前に始まった配信は通知しません
+func runLivestreamStartWatcher(db *sqlx.DB, logger echo.Logger) {
+	ticker := time.NewTicker(livestreamStartCheckInterval)
+	defer ticker.Stop()
+
+	checkedAt := time.Now().Unix()
+	for now := range ticker.C {
+		var livestreamModels []*LivestreamModel
+		if err := db.SelectContext(context.Background(), &livestreamModels, "SELECT * FROM livestreams WHERE start_at > ? AND start_at <= ? AND privacy_status = ?", checkedAt, now.Unix(), livestreamPrivacyPublic); err != nil {
+			logger.Warnf("failed to find started livestreams: %v", err)
+			continue
+		}
+		checkedAt = now.Unix()
+		for _, livestreamModel := range livestreamModels {
+			events.publish(event{
+				Kind:         eventLivestreamStarted,
+				At:           time.Unix(livestreamModel.StartAt, 0),
+				UserID:       livestreamModel.UserID,
+				LivestreamID: livestreamModel.ID,
+			})
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// 前のページの最後の行の位置。指定すると、offsetの代わりにその行より後ろを返す
+	// 深いページでもOFFSETのように読み飛ばす行を数えずに済む
+	cursorQueryParam = "cursor"
+	// limit件ちょうど返したときに、続きを取得するためのcursorを返すヘッダ
+	// レスポンスの形を変えないよう、ボディではなくヘッダで返す
+	nextCursorHeader = "X-Next-Cursor"
+)
+
+// cursor は、一覧の並び順における行の位置です
+// 並び替えのキー (作成日時など) と、同じキーの行を区別するためのIDからなります
+type cursor struct {
+	sortKey int64
+	id      int64
+}
+
+func (cur cursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(cur.sortKey, 10) + ":" + strconv.FormatInt(cur.id, 10)))
+}
+
+func decodeCursor(s string) (cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, err
+	}
+	sortKey, id, ok := strings.Cut(string(b), ":")
+	if !ok {
+		return cursor{}, errors.New("missing separator")
+	}
+	var cur cursor
+	if cur.sortKey, err = strconv.ParseInt(sortKey, 10, 64); err != nil {
+		return cursor{}, err
+	}
+	if cur.id, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return cursor{}, err
+	}
+	return cur, nil
+}
+
+// cursorParam は、cursorクエリパラメータを返します。指定されていなければfalseを返します
+func cursorParam(c echo.Context) (cursor, bool, error) {
+	v := c.QueryParam(cursorQueryParam)
+	if v == "" {
+		return cursor{}, false, nil
+	}
+	cur, err := decodeCursor(v)
+	if err != nil {
+		return cursor{}, false, apperror.BadRequest("cursor query parameter is invalid")
+	}
+	return cur, true, nil
+}
+
+// condition は、sortColumn, idColumnの順に並べたときに、curより後ろの行に絞り込むWHERE (HAVING) 句の条件を返します
+// descがtrueなら降順として扱います
+// IDのみで並べる一覧では、sortColumnを空にしてください
+func (cur cursor) condition(sortColumn, idColumn string, desc bool) (string, []interface{}) {
+	op := ">"
+	if desc {
+		op = "<"
+	}
+	if sortColumn == "" {
+		return fmt.Sprintf("%s %s ?", idColumn, op), []interface{}{cur.id}
+	}
+	return fmt.Sprintf("(%[1]s %[3]s ? OR (%[1]s = ? AND %[2]s %[3]s ?))", sortColumn, idColumn, op),
+		[]interface{}{cur.sortKey, cur.sortKey, cur.id}
+}
+
+// setNextCursor は、limit件ちょうど返すときに、最後の行の位置をnextCursorHeaderに載せます
+// 件数がlimitに満たなければ続きはないので、何もしません
+func setNextCursor(c echo.Context, n, limit int, last func() cursor) {
+	if limit <= 0 || n < limit {
+		return
+	}
+	c.Response().Header().Set(nextCursorHeader, last().encode())
+}
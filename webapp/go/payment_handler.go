@@ -1,34 +1,149 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
 )
 
+// payment_totalsは1行のみ持つ
+const paymentTotalsID = 1
+
+// 売上の集計結果を使い回す期間
+const paymentMemoTTL = 500 * time.Millisecond
+
+var (
+	paymentResultMemo = newMemo[PaymentResult]("payment_result", paymentMemoTTL)
+	// ユーザID:期間 => 配信ごとの売上
+	earningsMemo = newMemo[[]LivestreamEarning]("earnings", paymentMemoTTL)
+)
+
 type PaymentResult struct {
 	TotalTip int64 `json:"total_tip"`
 }
 
+const (
+	earningsPeriodDay = "day"
+	earningsPeriodAll = "all"
+)
+
+type LivestreamEarning struct {
+	LivestreamID int64  `json:"livestream_id" db:"livestream_id"`
+	Title        string `json:"title" db:"title"`
+	TotalTip     int64  `json:"total_tip" db:"total_tip"`
+}
+
+type EarningsReport struct {
+	Period      string              `json:"period"`
+	TotalTip    int64               `json:"total_tip"`
+	Livestreams []LivestreamEarning `json:"livestreams"`
+}
+
 func GetPaymentResult(c echo.Context) error {
 	ctx := c.Request().Context()
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
+	db := readDB(c)
+
+	// 全体で1つの値なので、キーは固定
+	result, err := paymentResultMemo.do(ctx, "", func(ctx context.Context) (PaymentResult, error) {
+		var totalTip int64
+		if err := db.GetContext(ctx, &totalTip, "SELECT total_tip FROM payment_totals WHERE id = ?", paymentTotalsID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return PaymentResult{}, echo.NewHTTPError(http.StatusInternalServerError, "failed to get total tip: "+err.Error())
+		}
+		return PaymentResult{TotalTip: totalTip}, nil
+	})
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
+		return err
 	}
-	defer tx.Rollback()
 
-	var totalTip int64
-	if err := tx.GetContext(ctx, &totalTip, "SELECT IFNULL(SUM(tip), 0) FROM livecomments"); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count total tip: "+err.Error())
+	return c.JSON(http.StatusOK, &result)
+}
+
+// 配信者向け収益レポートAPI
+// GET /api/user/me/earnings?period=day|all
+func getMyEarningsHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := verifyUserSession(c); err != nil {
+		// echo.NewHTTPErrorが返っているのでそのまま出力
+		return err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	period := c.QueryParam("period")
+	if period == "" {
+		period = earningsPeriodAll
 	}
 
-	return c.JSON(http.StatusOK, &PaymentResult{
-		TotalTip: totalTip,
+	query := `
+	SELECT d.livestream_id, l.title, SUM(d.total_tip) AS total_tip
+	FROM livestream_daily_tips d
+	INNER JOIN livestreams l ON l.id = d.livestream_id
+	WHERE d.user_id = ?`
+	params := []interface{}{userID}
+	switch period {
+	case earningsPeriodDay:
+		query += " AND d.day = ?"
+		params = append(params, tipDay(time.Now().Unix()))
+	case earningsPeriodAll:
+	default:
+		return apperror.BadRequest("period query parameter must be day or all")
+	}
+	query += " GROUP BY d.livestream_id, l.title ORDER BY d.livestream_id ASC"
+
+	db := readDB(c)
+
+	earnings, err := earningsMemo.do(ctx, fmt.Sprintf("%d:%s", userID, period), func(ctx context.Context) ([]LivestreamEarning, error) {
+		earnings := []LivestreamEarning{}
+		if err := db.SelectContext(ctx, &earnings, query, params...); err != nil {
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to get earnings: "+err.Error())
+		}
+		return earnings, nil
 	})
+	if err != nil {
+		return err
+	}
+
+	var totalTip int64
+	for _, earning := range earnings {
+		totalTip += earning.TotalTip
+	}
+
+	return c.JSON(http.StatusOK, &EarningsReport{
+		Period:      period,
+		TotalTip:    totalTip,
+		Livestreams: earnings,
+	})
+}
+
+// tipDay は、UNIX時間をUTCでのその日の0時に丸めます
+func tipDay(unixTime int64) int64 {
+	return unixTime - unixTime%int64(24*time.Hour/time.Second)
+}
+
+// addTip は、スーパーチャットの投稿・削除に合わせてチップの集計テーブルをdeltaだけ増減させます
+// 元となるライブコメントの投稿・削除と同じトランザクションで呼び出してください
+func addTip(ctx context.Context, tx *sqlx.Tx, streamerID int64, livecommentModel LivecommentModel, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO payment_totals (id, total_tip) VALUES (?, ?) ON DUPLICATE KEY UPDATE total_tip = total_tip + VALUES(total_tip)", paymentTotalsID, delta); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO livestream_daily_tips (livestream_id, user_id, day, total_tip) VALUES (?, ?, ?, ?) ON DUPLICATE KEY UPDATE total_tip = total_tip + VALUES(total_tip)", livecommentModel.LivestreamID, streamerID, tipDay(livecommentModel.CreatedAt), delta); err != nil {
+		return err
+	}
+	return nil
 }
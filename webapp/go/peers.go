@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// 他のアプリケーションサーバへの通知を受け取るパス
+	peerInvalidatePath = "/internal/invalidate"
+	// 通知の送り元がアプリケーションサーバであることを示すヘッダ
+	// 値はISUCON13_PEER_SECRETから作るので、同じ秘密鍵を使っているサーバ同士でのみ受け付ける
+	peerTokenHeader = "X-Isupipe-Peer-Token"
+
+	peerRequestTimeout = 500 * time.Millisecond
+	// 送信が追いつかないときに溜めておく通知の上限。超えた分は捨てる
+	peerQueueSize = 1024
+)
+
+const (
+	peerMessageUser           = "user"
+	peerMessageIcon           = "icon"
+	peerMessageNGWords        = "ngwords"
+	peerMessageFeatureFlags   = "feature_flags"
+	peerMessageTags           = "tags"
+	peerMessageChannelBan     = "channel_ban"
+	peerMessageReactionEmojis = "reaction_emojis"
+)
+
+// peerMessage は、メモリ上の状態の変更を他のアプリケーションサーバに伝える通知です
+type peerMessage struct {
+	Kind string `json:"kind"`
+	// peerMessageUser: 削除されたユーザ
+	// peerMessageChannelBan: 締め出し、または締め出しを解除したユーザ
+	// peerMessageIcon: アイコンを変更したユーザ
+	UserID   int64  `json:"user_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	// peerMessageIcon: 変更後のアイコンのハッシュ
+	IconHash string `json:"icon_hash,omitempty"`
+	// peerMessageNGWords: NGワードが変更されたライブ配信
+	LivestreamID int64 `json:"livestream_id,omitempty"`
+	// peerMessageTags: 追加されたタグを読み直す (フィールドはない)
+	// peerMessageReactionEmojis: リアクションに使える絵文字を読み直す (フィールドはない)
+	// peerMessageFeatureFlags: 切り替えたフラグ
+	FeatureFlags map[string]bool `json:"feature_flags,omitempty"`
+	// peerMessageChannelBan: 締め出しを変更したチャンネルと、締め出したか解除したか
+	ChannelID int64 `json:"channel_id,omitempty"`
+	Banned    bool  `json:"banned,omitempty"`
+}
+
+// peer は、通知を送る先のアプリケーションサーバです
+// 送信は1つのgoroutineで順番に行うので、送った順に反映されます
+type peer struct {
+	addr  string
+	queue chan peerMessage
+}
+
+// peerNotifier は、nginxの後ろに並べた他のアプリケーションサーバに、メモリ上のキャッシュの無効化を伝えます
+// 通知はベストエフォートで、届かなかった分は再送しません
+type peerNotifier struct {
+	peers  []*peer
+	token  string
+	client *http.Client
+}
+
+// peers は、ISUCON13_PEERSを指定していなければ誰にも送らず、通知も受け付けません
+var peers = &peerNotifier{}
+
+func peerToken(secret []byte) string {
+	sum := sha256.Sum256(append([]byte("peer:"), secret...))
+	return hex.EncodeToString(sum[:])
+}
+
+func newPeerNotifier(addrs []string, secret []byte) *peerNotifier {
+	n := &peerNotifier{
+		token:  peerToken(secret),
+		client: &http.Client{Timeout: peerRequestTimeout},
+	}
+	for _, addr := range addrs {
+		n.peers = append(n.peers, &peer{addr: addr, queue: make(chan peerMessage, peerQueueSize)})
+	}
+	return n
+}
+
+// run は、各アプリケーションサーバへの送信を始めます
+func (n *peerNotifier) run(logger echo.Logger) {
+	for _, p := range n.peers {
+		go func(p *peer) {
+			for msg := range p.queue {
+				if err := n.send(p.addr, msg); err != nil {
+					logger.Warnf("failed to notify %s to peer %s: %v", msg.Kind, p.addr, err)
+				}
+			}
+		}(p)
+	}
+}
+
+// broadcast は、すべてのアプリケーションサーバにmsgを送ります
+// 送信は後から行うので、リクエストの処理は待たせません
+func (n *peerNotifier) broadcast(msg peerMessage) {
+	for _, p := range n.peers {
+		select {
+		case p.queue <- msg:
+		default:
+			appLogger.Warn("dropped peer notification because the queue is full", slog.String("peer", p.addr), slog.String("kind", msg.Kind))
+		}
+	}
+}
+
+func (n *peerNotifier) send(addr string, msg peerMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), peerRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+addr+peerInvalidatePath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(peerTokenHeader, n.token)
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// applyPeerMessage は、他のアプリケーションサーバから届いた変更をこのサーバのメモリ上に反映します
+// 受け取った変更は送り返さないので、通知がサーバ間で巡回することはありません
+func applyPeerMessage(msg peerMessage) error {
+	switch msg.Kind {
+	case peerMessageUser:
+		userProfiles.forget(msg.UserID, msg.Username)
+		themeResponseCache.invalidatePath(themePath(msg.Username))
+	case peerMessageIcon:
+		return userProfiles.updateIcon(context.Background(), msg.UserID, msg.IconHash)
+	case peerMessageNGWords:
+		ngWordMatchers.invalidate(msg.LivestreamID)
+	case peerMessageTags:
+		if err := InvalidateTagCache(context.Background()); err != nil {
+			return err
+		}
+		tagsResponseCache.invalidate()
+	case peerMessageFeatureFlags:
+		return setFeatureFlags(msg.FeatureFlags)
+	case peerMessageChannelBan:
+		channelBans.set(msg.ChannelID, msg.UserID, msg.Banned)
+	case peerMessageReactionEmojis:
+		return reactionEmojis.load(context.Background(), dbConn)
+	default:
+		return fmt.Errorf("unknown peer message kind: %s", msg.Kind)
+	}
+	return nil
+}
+
+// 他のアプリケーションサーバからの変更の通知
+// POST /internal/invalidate
+func postPeerInvalidateHandler(c echo.Context) error {
+	token := c.Request().Header.Get(peerTokenHeader)
+	if peers.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(peers.token)) != 1 {
+		return apperror.Forbidden("invalid peer token")
+	}
+
+	var msg peerMessage
+	if err := json.NewDecoder(c.Request().Body).Decode(&msg); err != nil {
+		return apperror.BadRequest("failed to decode the request body as json")
+	}
+	if err := applyPeerMessage(msg); err != nil {
+		return apperror.BadRequest(err.Error())
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
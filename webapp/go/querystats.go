@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/isucon/isucon13/webapp/go/internal/config"
+	"github.com/isucon/isucon13/webapp/go/internal/querystats"
+	"github.com/labstack/echo/v4"
+)
+
+// 件数を指定しなかったときに返すクエリの数
+const defaultQueryStatsLimit = 20
+
+// queryStats は、クエリごとの実行時間の集計です
+// 集計を無効にしているときはnilで、何も記録しません
+var queryStats *querystats.Recorder
+
+// newQueryStats は、設定に応じてクエリの集計を用意します
+// 遅いクエリをログに出力するときは、そのために集計も行います
+func newQueryStats(c config.Debug) *querystats.Recorder {
+	if !c.QueryStats && c.SlowQueryThreshold == 0 {
+		return nil
+	}
+	return querystats.NewRecorder(c.SlowQueryThreshold, func(query string, elapsed time.Duration) {
+		appLogger.Warn("slow query", slog.String("query", query), slog.Duration("elapsed", elapsed))
+	})
+}
+
+// クエリごとの実行時間の集計
+// GET /debug/queries?limit=20&sort=total (DEBUG=1 のときのデバッグ用のポート)
+// sortはtotal (合計時間) またはcount (実行回数)
+func getQueryStatsHandler(c echo.Context) error {
+	limit := defaultQueryStatsLimit
+	if v := c.QueryParam("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return apperror.BadRequest("limit query parameter must be non-negative integer")
+		}
+	}
+	by := c.QueryParam("sort")
+	if by == "" {
+		by = querystats.SortByTotal
+	}
+	if by != querystats.SortByTotal && by != querystats.SortByCount {
+		return apperror.BadRequest("sort query parameter must be total or count")
+	}
+	return c.JSON(http.StatusOK, queryStats.Top(limit, by))
+}
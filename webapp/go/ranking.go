@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// ライブ配信ランキングを再計算する間隔
+const livestreamRankingRefreshInterval = time.Second
+
+type rankedLivestream struct {
+	Rank         int64
+	LivestreamID int64
+	Score        int64
+}
+
+// livestreamRankingCache は、定期的に再計算したライブ配信ランキングを保持します
+// 順位はライブ配信統計情報APIと同じく、スコア (リアクション数 + チップ合計) の降順で、同点の場合はIDの大きい方を上位とします
+type livestreamRankingCache struct {
+	mu sync.RWMutex
+	// 一覧に表示できる (公開された) 配信のみを順位順に保持する
+	entries []rankedLivestream
+}
+
+var livestreamRanking = &livestreamRankingCache{}
+
+// refresh は、ランキングを再計算して置き換えます
+func (r *livestreamRankingCache) refresh(ctx context.Context, db *sqlx.DB) error {
+	var livestreams []*LivestreamModel
+	if err := db.SelectContext(ctx, &livestreams, "SELECT * FROM livestreams"); err != nil {
+		return err
+	}
+	if err := recountLivestreamScores(ctx, db, livestreams); err != nil {
+		return err
+	}
+
+	ranking := make(LivestreamRanking, 0, len(livestreams))
+	privacyStatuses := make(map[int64]string, len(livestreams))
+	for _, livestream := range livestreams {
+		ranking = append(ranking, LivestreamRankingEntry{
+			LivestreamID: livestream.ID,
+			Score:        livestream.ReactionCount + livestream.TotalTip,
+		})
+		privacyStatuses[livestream.ID] = livestream.PrivacyStatus
+	}
+	sort.Sort(ranking)
+
+	// 順位は全配信で算出し、一覧には公開配信のみを載せる
+	entries := make([]rankedLivestream, 0, len(ranking))
+	var rank int64 = 1
+	for i := len(ranking) - 1; i >= 0; i-- {
+		entry := ranking[i]
+		if privacyStatuses[entry.LivestreamID] == livestreamPrivacyPublic {
+			entries = append(entries, rankedLivestream{
+				Rank:         rank,
+				LivestreamID: entry.LivestreamID,
+				Score:        entry.Score,
+			})
+		}
+		rank++
+	}
+
+	r.mu.Lock()
+	r.entries = entries
+	r.mu.Unlock()
+	rankingResponseCache.invalidate()
+
+	return nil
+}
+
+// page は、offset件目からlimit件のランキングを返します
+func (r *livestreamRankingCache) page(offset int, limit int) []rankedLivestream {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if offset >= len(r.entries) {
+		return nil
+	}
+	end := len(r.entries)
+	if limit >= 0 && offset+limit < end {
+		end = offset + limit
+	}
+	page := make([]rankedLivestream, end-offset)
+	copy(page, r.entries[offset:end])
+	return page
+}
+
+// run は、refreshを定期的に実行します
+func (r *livestreamRankingCache) run(db *sqlx.DB, logger echo.Logger) {
+	ticker := time.NewTicker(livestreamRankingRefreshInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := r.refresh(context.Background(), db); err != nil {
+			logger.Warnf("failed to refresh livestream ranking: %v", err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/isucon/isucon13/webapp/go/internal/config"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+// 使われなくなったバケットを破棄する間隔
+const rateLimitSweepInterval = time.Minute
+
+type rateLimitKey struct {
+	route string
+	// user:ユーザID または ip:IPアドレス
+	subject string
+}
+
+// tokenBucket は、最後に数えた時点のトークンの数です
+// 補充は、次に数えるときに経過時間からまとめて行います
+type tokenBucket struct {
+	limit     config.RateLimit
+	tokens    float64
+	updatedAt time.Time
+}
+
+// refill は、nowまでに補充されるトークンを足します
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+now.Sub(b.updatedAt).Seconds()*b.limit.Rate)
+	b.updatedAt = now
+}
+
+type rateLimitStat struct {
+	allowed int64
+	limited int64
+}
+
+// rateLimiter は、ルートと利用者ごとのトークンバケットをメモリ上に持ちます
+// アプリケーションサーバを複数台にしたときは、それぞれのサーバで別々に数えます
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[rateLimitKey]*tokenBucket
+	stats   map[string]*rateLimitStat
+	sweptAt time.Time
+}
+
+var rateLimits = newRateLimiter()
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[rateLimitKey]*tokenBucket),
+		stats:   make(map[string]*rateLimitStat),
+	}
+}
+
+// allow は、バケットからトークンを1つ取れればtrueを返します
+// 取れなければ、次にトークンが貯まるまでの時間を返します
+func (l *rateLimiter) allow(route, subject string, limit config.RateLimit, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.sweptAt) >= rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	stat, ok := l.stats[route]
+	if !ok {
+		stat = &rateLimitStat{}
+		l.stats[route] = stat
+	}
+
+	key := rateLimitKey{route: route, subject: subject}
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{limit: limit, tokens: float64(limit.Burst), updatedAt: now}
+		l.buckets[key] = bucket
+	}
+	bucket.refill(now)
+
+	if bucket.tokens < 1 {
+		stat.limited++
+		return false, time.Duration((1 - bucket.tokens) / limit.Rate * float64(time.Second))
+	}
+	bucket.tokens--
+	stat.allowed++
+	return true, 0
+}
+
+// sweep は、満杯まで補充されたバケットを破棄します
+// 満杯のバケットは新しく作ったものと同じなので、破棄しても制限は変わりません
+func (l *rateLimiter) sweep(now time.Time) {
+	l.sweptAt = now
+	for key, bucket := range l.buckets {
+		bucket.refill(now)
+		if bucket.tokens >= float64(bucket.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *rateLimiter) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buckets = make(map[rateLimitKey]*tokenBucket)
+	l.stats = make(map[string]*rateLimitStat)
+	l.sweptAt = time.Time{}
+}
+
+type rateLimitStatsSnapshot struct {
+	Allowed int64 `json:"allowed"`
+	Limited int64 `json:"limited"`
+}
+
+// snapshot は、ルートごとの受け付けた件数と制限した件数を返します
+func (l *rateLimiter) snapshot() map[string]rateLimitStatsSnapshot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	snapshots := make(map[string]rateLimitStatsSnapshot, len(l.stats))
+	for route, stat := range l.stats {
+		snapshots[route] = rateLimitStatsSnapshot{Allowed: stat.allowed, Limited: stat.limited}
+	}
+	return snapshots
+}
+
+// newRateLimitMiddleware は、ルートごとの上限を超えたリクエストに429を返すミドルウェアを返します
+// ログインしていればユーザごと、していなければIPアドレスごとに数えます
+// 制限しない設定のときはnilを返します
+func newRateLimitMiddleware(c config.RateLimits) echo.MiddlewareFunc {
+	if !c.Enabled || len(c.Routes) == 0 {
+		return nil
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			route := ctx.Request().Method + " " + ctx.Path()
+			limit, ok := c.Routes[route]
+			if !ok {
+				return next(ctx)
+			}
+			if ok, retryAfter := rateLimits.allow(route, rateLimitSubject(ctx), limit, time.Now()); !ok {
+				ctx.Response().Header().Set(echo.HeaderRetryAfter, strconv.FormatInt(int64(math.Ceil(retryAfter.Seconds())), 10))
+				return echo.NewHTTPError(http.StatusTooManyRequests, "rate limit exceeded")
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// rateLimitSubject は、リクエストを数える単位を返します
+// セッションの有効期限はハンドラで確かめるので、ここではユーザIDがあるかだけを見ます
+func rateLimitSubject(c echo.Context) string {
+	if sess, err := session.Get(defaultSessionIDKey, c); err == nil {
+		if userID, ok := sess.Values[defaultUserIDKey].(int64); ok {
+			return "user:" + strconv.FormatInt(userID, 10)
+		}
+	}
+	return "ip:" + c.RealIP()
+}
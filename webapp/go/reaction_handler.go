@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
@@ -33,6 +37,11 @@ type PostReactionRequest struct {
 	EmojiName string `json:"emoji_name"`
 }
 
+type ReactionCount struct {
+	EmojiName string `json:"emoji_name" db:"emoji_name"`
+	Count     int64  `json:"count" db:"count"`
+}
+
 func getReactionsHandler(c echo.Context) error {
 	ctx := c.Request().Context()
 
@@ -43,42 +52,45 @@ func getReactionsHandler(c echo.Context) error {
 
 	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "livestream_id in path must be integer")
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
+	db := readDB(c)
+
+	query := "SELECT * FROM reactions WHERE livestream_id = ?"
+	params := []interface{}{livestreamID}
+	cur, ok, err := cursorParam(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
+		return err
 	}
-	defer tx.Rollback()
-
-	query := "SELECT * FROM reactions WHERE livestream_id = ? ORDER BY created_at DESC"
+	if ok {
+		cond, args := cur.condition("created_at", "id", true)
+		query += " AND " + cond
+		params = append(params, args...)
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	limit := -1
 	if c.QueryParam("limit") != "" {
-		limit, err := strconv.Atoi(c.QueryParam("limit"))
+		limit, err = strconv.Atoi(c.QueryParam("limit"))
 		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, "limit query parameter must be integer")
+			return apperror.BadRequest("limit query parameter must be integer")
 		}
 		query += fmt.Sprintf(" LIMIT %d", limit)
 	}
 
 	reactionModels := []ReactionModel{}
-	if err := tx.SelectContext(ctx, &reactionModels, query, livestreamID); err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, "failed to get reactions")
-	}
-
-	reactions := make([]Reaction, len(reactionModels))
-	for i := range reactionModels {
-		reaction, err := fillReactionResponse(ctx, tx, reactionModels[i])
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill reaction: "+err.Error())
-		}
-
-		reactions[i] = reaction
+	if err := db.SelectContext(ctx, &reactionModels, query, params...); err != nil {
+		return apperror.NotFound("failed to get reactions")
 	}
 
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+	reactions, err := fillReactionResponses(ctx, db, reactionModels)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill reaction: "+err.Error())
 	}
+	setNextCursor(c, len(reactionModels), limit, func() cursor {
+		last := reactionModels[len(reactionModels)-1]
+		return cursor{sortKey: last.CreatedAt, id: last.ID}
+	})
 
 	return c.JSON(http.StatusOK, reactions)
 }
@@ -87,7 +99,7 @@ func postReactionHandler(c echo.Context) error {
 	ctx := c.Request().Context()
 	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "livestream_id in path must be integer")
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 
 	if err := verifyUserSession(c); err != nil {
@@ -102,14 +114,41 @@ func postReactionHandler(c echo.Context) error {
 
 	var req *PostReactionRequest
 	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "failed to decode the request body as json")
+		return apperror.BadRequest("failed to decode the request body as json")
+	}
+	if !reactionEmojis.allowed(req.EmojiName) {
+		return apperror.BadRequest("the emoji is not allowed for reactions")
 	}
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
+	// 再送されたリクエストでは、最初に付けたリアクションを返す
+	idem, err := beginIdempotentRequest(c, "reaction:"+strconv.Itoa(livestreamID), userID, req)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
+		return err
+	}
+	defer idem.end()
+	if id, ok := idem.replayed(); ok {
+		var reactionModel ReactionModel
+		if err := dbConn.GetContext(ctx, &reactionModel, "SELECT * FROM reactions WHERE id = ?", id); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get reaction: "+err.Error())
+		}
+		reaction, err := fillReactionResponse(ctx, dbConn, reactionModel)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill reaction: "+err.Error())
+		}
+		return c.JSON(http.StatusCreated, reaction)
+	}
+
+	var livestreamModel LivestreamModel
+	if err := preparedGet(ctx, readDB(c), &livestreamModel, queryLivestreamByID, livestreamID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperror.NotFound("livestream not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
+	}
+	// チャンネルから締め出されたユーザは、リアクションも付けられない
+	if err := checkChannelBan(livestreamModel, userID); err != nil {
+		return err
 	}
-	defer tx.Rollback()
 
 	reactionModel := ReactionModel{
 		UserID:       int64(userID),
@@ -118,55 +157,184 @@ func postReactionHandler(c echo.Context) error {
 		CreatedAt:    time.Now().Unix(),
 	}
 
-	result, err := tx.NamedExecContext(ctx, "INSERT INTO reactions (user_id, livestream_id, emoji_name, created_at) VALUES (:user_id, :livestream_id, :emoji_name, :created_at)", reactionModel)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert reaction: "+err.Error())
+	// INSERTは他のリクエストの分とまとめて行うので、IDが採番されるまで待つ
+	done := make(chan reactionWriteResult, 1)
+	reactionWriter.add(pendingReaction{model: reactionModel, done: done})
+	var result reactionWriteResult
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert reaction: "+ctx.Err().Error())
 	}
+	if result.err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert reaction: "+result.err.Error())
+	}
+	reactionModel.ID = result.id
+	idem.complete(ctx, reactionModel.ID)
 
-	reactionID, err := result.LastInsertId()
+	// 書き込んだ直後なのでプライマリから読む
+	reaction, err := fillReactionResponse(ctx, dbConn, reactionModel)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get last inserted reaction id: "+err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill reaction: "+err.Error())
+	}
+	trending.addReaction(reactionModel.LivestreamID, time.Unix(reactionModel.CreatedAt, 0))
+	liveHubs.publishReaction(reaction)
+
+	return c.JSON(http.StatusCreated, reaction)
+}
+
+// 配信の絵文字ごとのリアクション数
+// GET /api/livestream/:livestream_id/reaction/summary
+func getReactionSummaryHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := verifyUserSession(c); err != nil {
+		// echo.NewHTTPErrorが返っているのでそのまま出力
+		return err
 	}
-	reactionModel.ID = reactionID
 
-	reaction, err := fillReactionResponse(ctx, tx, reactionModel)
+	livestreamID, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill reaction: "+err.Error())
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+	reactionCounts := []ReactionCount{}
+	if err := readDB(c).SelectContext(ctx, &reactionCounts, "SELECT emoji_name, count FROM reaction_counts WHERE livestream_id = ? ORDER BY count DESC, emoji_name ASC", livestreamID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get reaction counts: "+err.Error())
 	}
 
-	return c.JSON(http.StatusCreated, reaction)
+	return c.JSON(http.StatusOK, reactionCounts)
 }
 
-func fillReactionResponse(ctx context.Context, tx *sqlx.Tx, reactionModel ReactionModel) (Reaction, error) {
-	userModel := UserModel{}
-	if err := tx.GetContext(ctx, &userModel, "SELECT * FROM users WHERE id = ?", reactionModel.UserID); err != nil {
-		return Reaction{}, err
-	}
-	user, err := fillUserResponse(ctx, tx, userModel)
+func fillReactionResponse(ctx context.Context, q sqlx.QueryerContext, reactionModel ReactionModel) (Reaction, error) {
+	reactions, err := fillReactionResponses(ctx, q, []ReactionModel{reactionModel})
 	if err != nil {
 		return Reaction{}, err
 	}
+	return reactions[0], nil
+}
 
-	livestreamModel := LivestreamModel{}
-	if err := tx.GetContext(ctx, &livestreamModel, "SELECT * FROM livestreams WHERE id = ?", reactionModel.LivestreamID); err != nil {
-		return Reaction{}, err
+// fillReactionResponses は、ユーザと配信をまとめて引いて、リアクションの一覧をレスポンスの形にします
+func fillReactionResponses(ctx context.Context, q sqlx.QueryerContext, reactionModels []ReactionModel) ([]Reaction, error) {
+	userIDs := make([]int64, len(reactionModels))
+	livestreamIDs := make([]int64, len(reactionModels))
+	for i, reactionModel := range reactionModels {
+		userIDs[i] = reactionModel.UserID
+		livestreamIDs[i] = reactionModel.LivestreamID
 	}
-	livestream, err := fillLivestreamResponse(ctx, tx, livestreamModel)
+	users, err := loadUsers(ctx, q, userIDs)
 	if err != nil {
-		return Reaction{}, err
+		return nil, err
+	}
+	livestreams, err := loadLivestreams(ctx, q, livestreamIDs)
+	if err != nil {
+		return nil, err
 	}
 
-	reaction := Reaction{
-		ID:         reactionModel.ID,
-		EmojiName:  reactionModel.EmojiName,
-		User:       user,
-		Livestream: livestream,
-		CreatedAt:  reactionModel.CreatedAt,
+	reactions := make([]Reaction, len(reactionModels))
+	for i, reactionModel := range reactionModels {
+		user, ok := users[reactionModel.UserID]
+		if !ok {
+			return nil, sql.ErrNoRows
+		}
+		livestream, ok := livestreams[reactionModel.LivestreamID]
+		if !ok {
+			return nil, sql.ErrNoRows
+		}
+		reactions[i] = Reaction{
+			ID:         reactionModel.ID,
+			EmojiName:  reactionModel.EmojiName,
+			User:       user,
+			Livestream: livestream,
+			CreatedAt:  reactionModel.CreatedAt,
+		}
 	}
+	return reactions, nil
+}
+
+// pendingReaction は、書き込み待ちのリアクションです
+// 書き込みが済むと、採番されたIDかエラーがdoneに届きます
+type pendingReaction struct {
+	model ReactionModel
+	done  chan reactionWriteResult
+}
+
+type reactionWriteResult struct {
+	id  int64
+	err error
+}
+
+var reactionWriter = newBatchWriter("reactions", writeReactions)
 
-	return reaction, nil
+// writeReactions は、リアクションを複数行のINSERTでまとめて書き込み、配信ごと・絵文字ごとの数を増やします
+func writeReactions(ctx context.Context, items []pendingReaction) error {
+	type countKey struct {
+		livestreamID int64
+		emojiName    string
+	}
+
+	var firstID int64
+	err := withTx(ctx, func(tx *sqlx.Tx) error {
+		reactionModels := make([]ReactionModel, len(items))
+		var keys []countKey
+		counts := map[countKey]int64{}
+		for i, item := range items {
+			reactionModels[i] = item.model
+			key := countKey{livestreamID: item.model.LivestreamID, emojiName: item.model.EmojiName}
+			if _, ok := counts[key]; !ok {
+				keys = append(keys, key)
+			}
+			counts[key]++
+		}
+
+		result, err := tx.NamedExecContext(ctx, "INSERT INTO reactions (user_id, livestream_id, emoji_name, created_at) VALUES (:user_id, :livestream_id, :emoji_name, :created_at)", reactionModels)
+		if err != nil {
+			return err
+		}
+		// 1つのINSERTで追加した行のIDは連番になるので、先頭のIDから順に割り当てる
+		firstID, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		query := "INSERT INTO reaction_counts (livestream_id, emoji_name, count) VALUES "
+		params := make([]interface{}, 0, len(keys)*3)
+		for i, key := range keys {
+			if i > 0 {
+				query += ", "
+			}
+			query += "(?, ?, ?)"
+			params = append(params, key.livestreamID, key.emojiName, counts[key])
+		}
+		query += " ON DUPLICATE KEY UPDATE count = count + VALUES(count)"
+		if _, err := tx.ExecContext(ctx, query, params...); err != nil {
+			return err
+		}
+
+		// デッドロックしにくいよう、配信のスコアは配信IDの順に更新する
+		livestreamCounts := map[int64]int64{}
+		for _, item := range items {
+			livestreamCounts[item.model.LivestreamID]++
+		}
+		livestreamIDs := make([]int64, 0, len(livestreamCounts))
+		for livestreamID := range livestreamCounts {
+			livestreamIDs = append(livestreamIDs, livestreamID)
+		}
+		sort.Slice(livestreamIDs, func(i, j int) bool { return livestreamIDs[i] < livestreamIDs[j] })
+		for _, livestreamID := range livestreamIDs {
+			if err := addLivestreamScore(ctx, tx, livestreamID, scoreDelta{reactionCount: livestreamCounts[livestreamID]}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	for i, item := range items {
+		if err != nil {
+			item.done <- reactionWriteResult{err: err}
+		} else {
+			item.done <- reactionWriteResult{id: firstID + int64(i)}
+		}
+	}
+	return err
 }
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 保存するURLの数の上限。超えたら一度すべて捨てる
+const responseCacheMaxEntries = 10000
+
+var (
+	// タグの一覧。タグの追加で無効にする
+	tagsResponseCache = newResponseCache("tags", 10*time.Second, false)
+	// ライブ配信ランキング。ランキングを計算し直すたびに無効にする
+	rankingResponseCache = newResponseCache("livestream_ranking", livestreamRankingMemoTTL, false)
+	// 配信者のテーマ。ユーザの削除で無効にする
+	themeResponseCache = newResponseCache("streamer_theme", 30*time.Second, true)
+)
+
+// responseCaches は、名前 => キャッシュです
+var responseCaches = map[string]*responseCache{}
+
+// responseCache は、あまり変わらない一覧などのレスポンスをURLごとにメモリ上に保存し、ttlの間使い回します
+// JSONのエンコードも含めて省けるので、memoより速く返せます
+// 保存するのは200のレスポンスのみです
+// ログインが必要なルートではrequireSessionを指定すると、キャッシュから返す前にもセッションを確かめます
+// 中身がセッションのユーザによって変わるルートには使わないでください
+type responseCache struct {
+	name           string
+	ttl            time.Duration
+	requireSession bool
+	stats          *cacheStats
+
+	mu sync.RWMutex
+	// パス => 正規化したクエリ文字列 => レスポンス
+	entries map[string]map[string]*cachedResponse
+	size    int
+}
+
+type cachedResponse struct {
+	contentType string
+	body        []byte
+	expiresAt   time.Time
+}
+
+func newResponseCache(name string, ttl time.Duration, requireSession bool) *responseCache {
+	rc := &responseCache{
+		name:           name,
+		ttl:            ttl,
+		requireSession: requireSession,
+		stats:          newCacheStats("response:" + name),
+		entries:        map[string]map[string]*cachedResponse{},
+	}
+	responseCaches[name] = rc
+	return rc
+}
+
+// middleware は、ルートに指定するミドルウェアです
+func (rc *responseCache) middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if rc.requireSession {
+			if err := verifyUserSession(c); err != nil {
+				return err
+			}
+		}
+
+		// クエリパラメータの順番が違っても同じURLとして扱う
+		u := c.Request().URL
+		path, query := u.Path, normalizeQuery(u.Query())
+		if entry, ok := rc.get(path, query); ok {
+			rc.stats.record(true)
+			return c.Blob(http.StatusOK, entry.contentType, entry.body)
+		}
+		rc.stats.record(false)
+
+		res := c.Response()
+		recorder := &responseRecorder{ResponseWriter: res.Writer}
+		res.Writer = recorder
+		err := next(c)
+		res.Writer = recorder.ResponseWriter
+		if err == nil && res.Status == http.StatusOK {
+			rc.set(path, query, res.Header().Get(echo.HeaderContentType), recorder.body.Bytes())
+		}
+		return err
+	}
+}
+
+func normalizeQuery(values url.Values) string {
+	// Encodeはキーの順に並べる
+	return values.Encode()
+}
+
+func (rc *responseCache) get(path, query string) (*cachedResponse, bool) {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	entry, ok := rc.entries[path][query]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry, true
+}
+
+func (rc *responseCache) set(path, query, contentType string, body []byte) {
+	entry := &cachedResponse{
+		contentType: contentType,
+		body:        body,
+		expiresAt:   time.Now().Add(rc.ttl),
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.size >= responseCacheMaxEntries {
+		rc.entries = map[string]map[string]*cachedResponse{}
+		rc.size = 0
+	}
+	queries, ok := rc.entries[path]
+	if !ok {
+		queries = map[string]*cachedResponse{}
+		rc.entries[path] = queries
+	}
+	if _, ok := queries[query]; !ok {
+		rc.size++
+	}
+	queries[query] = entry
+}
+
+// invalidate は、保存したすべてのレスポンスを捨てます
+func (rc *responseCache) invalidate() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.entries = map[string]map[string]*cachedResponse{}
+	rc.size = 0
+}
+
+// invalidatePath は、pathのレスポンスをクエリパラメータによらず捨てます
+func (rc *responseCache) invalidatePath(path string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.size -= len(rc.entries[path])
+	delete(rc.entries, path)
+}
+
+func resetResponseCaches() {
+	for _, rc := range responseCaches {
+		rc.invalidate()
+	}
+}
+
+// responseRecorder は、クライアントに書き込みながら、保存するためにボディを記録します
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/isucon/isucon13/webapp/go/internal/openapi"
+	"github.com/labstack/echo/v4"
+)
+
+// apiRoute は、APIのエンドポイント1つと、そのリクエスト・レスポンスの型です
+type apiRoute struct {
+	method  string
+	path    string
+	handler string
+
+	request reflect.Type
+	// ステータスコードごとのレスポンスの型。本文がなければnil
+	responses map[int]reflect.Type
+}
+
+// accepts は、リクエストの本文がvの型のJSONであることを宣言します
+func (r *apiRoute) accepts(v interface{}) *apiRoute {
+	r.request = reflect.TypeOf(v)
+	return r
+}
+
+// returns は、statusのレスポンスの本文がvの型のJSONであることを宣言します
+// 本文がなければvにnilを指定してください
+func (r *apiRoute) returns(status int, v interface{}) *apiRoute {
+	var t reflect.Type
+	if v != nil {
+		t = reflect.TypeOf(v)
+	}
+	r.responses[status] = t
+	return r
+}
+
+// apiRegistry は、echoにエンドポイントを登録し、宣言された型からOpenAPIのドキュメントを作ります
+// ハンドラの中身とドキュメントがずれないよう、エンドポイントはここからのみ登録してください
+type apiRegistry struct {
+	e      *echo.Echo
+	routes []*apiRoute
+
+	once     sync.Once
+	document []byte
+	err      error
+}
+
+func newAPIRegistry(e *echo.Echo) *apiRegistry {
+	return &apiRegistry{e: e}
+}
+
+func (a *apiRegistry) add(method, path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *apiRoute {
+	a.e.Add(method, path, h, m...)
+	// ドキュメントのoperationIdには、パッケージ名を除いたハンドラの関数名を使う
+	name := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+	route := &apiRoute{
+		method:    method,
+		path:      path,
+		handler:   name[strings.LastIndex(name, ".")+1:],
+		responses: map[int]reflect.Type{},
+	}
+	a.routes = append(a.routes, route)
+	return route
+}
+
+func (a *apiRegistry) GET(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *apiRoute {
+	return a.add(http.MethodGet, path, h, m...)
+}
+
+func (a *apiRegistry) POST(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *apiRoute {
+	return a.add(http.MethodPost, path, h, m...)
+}
+
+func (a *apiRegistry) PUT(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *apiRoute {
+	return a.add(http.MethodPut, path, h, m...)
+}
+
+func (a *apiRegistry) DELETE(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *apiRoute {
+	return a.add(http.MethodDelete, path, h, m...)
+}
+
+// openAPIDocument は、登録されたエンドポイントのOpenAPIのドキュメントを作ります
+func (a *apiRegistry) openAPIDocument() *openapi.Document {
+	doc := openapi.New("ISUPipe", "1.0.0")
+	errorContent := doc.JSONContent(reflect.TypeOf(ErrorResponse{}))
+	for _, route := range a.routes {
+		op := &openapi.Operation{
+			OperationID: route.handler,
+			Responses: map[string]*openapi.Response{
+				// エラーはerrorResponseHandlerが同じ形で返す
+				"default": {Description: "error", Content: errorContent},
+			},
+		}
+		if route.request != nil {
+			op.RequestBody = &openapi.RequestBody{Required: true, Content: doc.JSONContent(route.request)}
+		}
+		for status, t := range route.responses {
+			res := &openapi.Response{Description: http.StatusText(status)}
+			if t != nil {
+				res.Content = doc.JSONContent(t)
+			}
+			op.Responses[strconv.Itoa(status)] = res
+		}
+		doc.AddOperation(route.method, route.path, op)
+	}
+	return doc
+}
+
+// OpenAPIのドキュメント
+// 起動した後にエンドポイントは増えないので、初めて要求されたときに一度だけ作る
+// GET /openapi.json
+func (a *apiRegistry) getOpenAPIHandler(c echo.Context) error {
+	a.once.Do(func() {
+		a.document, a.err = json.Marshal(a.openAPIDocument())
+	})
+	if a.err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to encode openapi document: "+a.err.Error())
+	}
+	return c.JSONBlob(http.StatusOK, a.document)
+}
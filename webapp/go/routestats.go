@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// レイテンシのヒストグラムのバケットの上限 (ミリ秒)
+// 最後のバケットはこれより遅いものすべてを数える
+var routeLatencyBucketsMs = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000}
+
+type routeKey struct {
+	method string
+	route  string
+}
+
+// routeStat は、1つのルートのレイテンシの分布とステータスコードごとの件数です
+type routeStat struct {
+	count    int64
+	total    time.Duration
+	max      time.Duration
+	buckets  []int64
+	statuses map[int]int64
+}
+
+// routeStatsRecorder は、ルートごとのレイテンシとステータスコードをメモリ上に集計します
+// ルートはパスそのものではなく、echoに登録したパターン (/api/livestream/:livestream_id など) でまとめます
+type routeStatsRecorder struct {
+	mu    sync.Mutex
+	stats map[routeKey]*routeStat
+}
+
+var routeStats = &routeStatsRecorder{
+	stats: make(map[routeKey]*routeStat),
+}
+
+func (r *routeStatsRecorder) record(method, route string, status int, latency time.Duration) {
+	key := routeKey{method: method, route: route}
+	ms := float64(latency) / float64(time.Millisecond)
+	bucket := sort.SearchFloat64s(routeLatencyBucketsMs, ms)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stat, ok := r.stats[key]
+	if !ok {
+		stat = &routeStat{
+			buckets:  make([]int64, len(routeLatencyBucketsMs)+1),
+			statuses: make(map[int]int64),
+		}
+		r.stats[key] = stat
+	}
+	stat.count++
+	stat.total += latency
+	if latency > stat.max {
+		stat.max = latency
+	}
+	stat.buckets[bucket]++
+	stat.statuses[status]++
+}
+
+func (r *routeStatsRecorder) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats = make(map[routeKey]*routeStat)
+}
+
+type RouteLatencyBucket struct {
+	// 空のときは上限なし
+	LeMs  string `json:"le_ms"`
+	Count int64  `json:"count"`
+}
+
+type RouteStats struct {
+	Method   string               `json:"method"`
+	Route    string               `json:"route"`
+	Count    int64                `json:"count"`
+	TotalMs  float64              `json:"total_ms"`
+	AvgMs    float64              `json:"avg_ms"`
+	MaxMs    float64              `json:"max_ms"`
+	Statuses map[string]int64     `json:"statuses"`
+	Buckets  []RouteLatencyBucket `json:"buckets"`
+}
+
+// snapshot は、合計時間の長い順にルートごとの集計を返します
+func (r *routeStatsRecorder) snapshot() []RouteStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]RouteStats, 0, len(r.stats))
+	for key, stat := range r.stats {
+		totalMs := float64(stat.total) / float64(time.Millisecond)
+		s := RouteStats{
+			Method:   key.method,
+			Route:    key.route,
+			Count:    stat.count,
+			TotalMs:  totalMs,
+			AvgMs:    totalMs / float64(stat.count),
+			MaxMs:    float64(stat.max) / float64(time.Millisecond),
+			Statuses: make(map[string]int64, len(stat.statuses)),
+			Buckets:  make([]RouteLatencyBucket, len(stat.buckets)),
+		}
+		for status, count := range stat.statuses {
+			s.Statuses[strconv.Itoa(status)] = count
+		}
+		for i, count := range stat.buckets {
+			var le string
+			if i < len(routeLatencyBucketsMs) {
+				le = strconv.FormatFloat(routeLatencyBucketsMs[i], 'f', -1, 64)
+			}
+			s.Buckets[i] = RouteLatencyBucket{LeMs: le, Count: count}
+		}
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TotalMs != result[j].TotalMs {
+			return result[i].TotalMs > result[j].TotalMs
+		}
+		if result[i].Route != result[j].Route {
+			return result[i].Route < result[j].Route
+		}
+		return result[i].Method < result[j].Method
+	})
+	return result
+}
+
+// routeStatsMiddleware は、リクエストごとのレイテンシとステータスコードをrouteStatsに記録します
+// ステータスコードを確定させるため、エラーはここでレスポンスに変換します
+func routeStatsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		if err := next(c); err != nil {
+			c.Error(err)
+		}
+		route := c.Path()
+		if route == "" {
+			// どのルートにも一致しなかったリクエストはまとめて数える
+			route = "(unmatched)"
+		}
+		routeStats.record(c.Request().Method, route, c.Response().Status, time.Since(start))
+		return nil
+	}
+}
+
+// ルートごとのレイテンシの集計
+// GET /debug/route_stats (DEBUG=1 のときのデバッグ用のポート)
+func getRouteStatsHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, routeStats.snapshot())
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/isucon/isucon13/webapp/go/internal/config"
+	"github.com/jmoiron/sqlx"
+)
+
+// scoreDelta は、配信と配信者のスコアの集計カラムの増減です
+type scoreDelta struct {
+	totalTip      int64
+	reactionCount int64
+	commentCount  int64
+}
+
+// addLivestreamScore は、配信とその配信者のスコアの集計カラムをdeltaだけ増減させます
+// 元になるライブコメント・リアクションの追加・削除と同じトランザクションで呼び出してください
+func addLivestreamScore(ctx context.Context, tx *sqlx.Tx, livestreamID int64, delta scoreDelta) error {
+	if delta == (scoreDelta{}) {
+		return nil
+	}
+	query := `
+	UPDATE livestreams l
+	INNER JOIN users u ON u.id = l.user_id
+	SET
+		l.total_tip = l.total_tip + ?, l.reaction_count = l.reaction_count + ?, l.comment_count = l.comment_count + ?,
+		u.total_tip = u.total_tip + ?, u.reaction_count = u.reaction_count + ?, u.comment_count = u.comment_count + ?
+	WHERE l.id = ?`
+	_, err := tx.ExecContext(ctx, query,
+		delta.totalTip, delta.reactionCount, delta.commentCount,
+		delta.totalTip, delta.reactionCount, delta.commentCount,
+		livestreamID)
+	return err
+}
+
+// scoreMismatch は、集計カラムと、元のテーブルから算出し直した値の食い違いです
+type scoreMismatch struct {
+	Table  string
+	ID     int64
+	Column string
+	Stored int64
+	Actual int64
+}
+
+// scoreRow は、集計カラムの値と、元のテーブルから算出し直した値です
+type scoreRow struct {
+	ID                  int64 `db:"id"`
+	TotalTip            int64 `db:"total_tip"`
+	ReactionCount       int64 `db:"reaction_count"`
+	CommentCount        int64 `db:"comment_count"`
+	ActualTotalTip      int64 `db:"actual_total_tip"`
+	ActualReactionCount int64 `db:"actual_reaction_count"`
+	ActualCommentCount  int64 `db:"actual_comment_count"`
+}
+
+const (
+	livestreamScoresQuery = `
+	SELECT
+		l.id, l.total_tip, l.reaction_count, l.comment_count,
+		IFNULL((SELECT SUM(lc.tip) FROM livecomments lc WHERE lc.livestream_id = l.id AND lc.deleted_at IS NULL), 0) AS actual_total_tip,
+		(SELECT COUNT(*) FROM reactions r WHERE r.livestream_id = l.id) AS actual_reaction_count,
+		(SELECT COUNT(*) FROM livecomments lc WHERE lc.livestream_id = l.id AND lc.deleted_at IS NULL) AS actual_comment_count
+	FROM livestreams l
+	ORDER BY l.id`
+	userScoresQuery = `
+	SELECT
+		u.id, u.total_tip, u.reaction_count, u.comment_count,
+		IFNULL((SELECT SUM(lc.tip) FROM livestreams l INNER JOIN livecomments lc ON lc.livestream_id = l.id WHERE l.user_id = u.id AND lc.deleted_at IS NULL), 0) AS actual_total_tip,
+		(SELECT COUNT(*) FROM livestreams l INNER JOIN reactions r ON r.livestream_id = l.id WHERE l.user_id = u.id) AS actual_reaction_count,
+		(SELECT COUNT(*) FROM livestreams l INNER JOIN livecomments lc ON lc.livestream_id = l.id WHERE l.user_id = u.id AND lc.deleted_at IS NULL) AS actual_comment_count
+	FROM users u
+	ORDER BY u.id`
+)
+
+// recountLivestreamScores は、flagDenormalizedScoresが無効なとき、livestreamsの集計カラムの値を元のテーブルから算出し直した値で置き換えます
+func recountLivestreamScores(ctx context.Context, q sqlx.QueryerContext, livestreams []*LivestreamModel) error {
+	if flagDenormalizedScores.enabled() {
+		return nil
+	}
+	actual, err := selectActualScores(ctx, q, livestreamScoresQuery)
+	if err != nil {
+		return err
+	}
+	for _, livestream := range livestreams {
+		row := actual[livestream.ID]
+		livestream.TotalTip, livestream.ReactionCount, livestream.CommentCount = row.ActualTotalTip, row.ActualReactionCount, row.ActualCommentCount
+	}
+	return nil
+}
+
+// recountUserScores は、recountLivestreamScoresの配信者版です
+func recountUserScores(ctx context.Context, q sqlx.QueryerContext, users []*UserModel) error {
+	if flagDenormalizedScores.enabled() {
+		return nil
+	}
+	actual, err := selectActualScores(ctx, q, userScoresQuery)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		row := actual[user.ID]
+		user.TotalTip, user.ReactionCount, user.CommentCount = row.ActualTotalTip, row.ActualReactionCount, row.ActualCommentCount
+	}
+	return nil
+}
+
+func selectActualScores(ctx context.Context, q sqlx.QueryerContext, query string) (map[int64]scoreRow, error) {
+	var rows []scoreRow
+	if err := sqlx.SelectContext(ctx, q, &rows, query); err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]scoreRow, len(rows))
+	for _, row := range rows {
+		byID[row.ID] = row
+	}
+	return byID, nil
+}
+
+// checkScores は、配信と配信者のスコアの集計カラムを元のテーブルから算出し直し、食い違いを返します
+func checkScores(ctx context.Context, db *sqlx.DB) ([]scoreMismatch, error) {
+	queries := []struct {
+		table string
+		query string
+	}{
+		{table: "livestreams", query: livestreamScoresQuery},
+		{table: "users", query: userScoresQuery},
+	}
+
+	var mismatches []scoreMismatch
+	for _, q := range queries {
+		var rows []scoreRow
+		if err := db.SelectContext(ctx, &rows, q.query); err != nil {
+			return nil, fmt.Errorf("failed to recompute scores of %s: %w", q.table, err)
+		}
+		for _, row := range rows {
+			for _, column := range []struct {
+				name           string
+				stored, actual int64
+			}{
+				{"total_tip", row.TotalTip, row.ActualTotalTip},
+				{"reaction_count", row.ReactionCount, row.ActualReactionCount},
+				{"comment_count", row.CommentCount, row.ActualCommentCount},
+			} {
+				if column.stored != column.actual {
+					mismatches = append(mismatches, scoreMismatch{
+						Table:  q.table,
+						ID:     row.ID,
+						Column: column.name,
+						Stored: column.stored,
+						Actual: column.actual,
+					})
+				}
+			}
+		}
+	}
+	return mismatches, nil
+}
+
+// runCheckScoresCommand は、check-scoresサブコマンドの本体です
+// アプリケーションと同じ環境変数でDBに接続して集計カラムを検査し、食い違いがあれば一覧を出力します
+// 終了コードは、食い違いがなければ0、あれば1、検査できなければ2です
+func runCheckScoresCommand(stdout, stderr io.Writer) int {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid configuration:\n%v\n", err)
+		return 2
+	}
+	db, err := connectDB(newDBConfig(cfg.DB), newDBPoolConfig(cfg.DB))
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to connect db: %v\n", err)
+		return 2
+	}
+	defer db.Close()
+
+	mismatches, err := checkScores(context.Background(), db)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 2
+	}
+	for _, m := range mismatches {
+		fmt.Fprintf(stdout, "%s id=%d %s: stored=%d actual=%d (diff=%+d)\n", m.Table, m.ID, m.Column, m.Stored, m.Actual, m.Stored-m.Actual)
+	}
+	if len(mismatches) > 0 {
+		fmt.Fprintf(stdout, "%d score mismatches found\n", len(mismatches))
+		return 1
+	}
+	fmt.Fprintln(stdout, "all scores are consistent")
+	return 0
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/isucon/isucon13/webapp/go/internal/config"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/http2"
+)
+
+const (
+	// systemdのソケットアクティベーション (sd_listen_fds) で渡される環境変数
+	systemdListenPIDEnvKey = "LISTEN_PID"
+	systemdListenFDsEnvKey = "LISTEN_FDS"
+	// systemdが渡すファイルディスクリプタは3から始まる
+	systemdListenFDsStart = 3
+)
+
+// newListener は、HTTPサーバが待ち受けるソケットを返します
+// systemdのソケットアクティベーションや受け継いだファイルディスクリプタがあればそれを使い、
+// なければUnixドメインソケット、それも指定されていなければTCPのポートで待ち受けます
+func newListener(c config.Server) (net.Listener, string, error) {
+	if fd, ok := inheritedListenFD(c); ok {
+		f := os.NewFile(uintptr(fd), "listener")
+		l, err := net.FileListener(f)
+		// FileListenerはファイルディスクリプタを複製するので、元は閉じてよい
+		f.Close()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to listen on inherited fd %d: %w", fd, err)
+		}
+		return l, fmt.Sprintf("fd %d (%s)", fd, l.Addr()), nil
+	}
+
+	if c.ListenSock != "" {
+		l, err := listenUnix(c.ListenSock, c.ListenSockMode)
+		if err != nil {
+			return nil, "", err
+		}
+		return l, "unix:" + c.ListenSock, nil
+	}
+
+	addr := net.JoinHostPort("", strconv.Itoa(c.Port))
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, "", err
+	}
+	return l, l.Addr().String(), nil
+}
+
+// listenUnix は、pathのUnixドメインソケットで待ち受けます
+// 前回の起動で残ったソケットファイルは消してから作り直します
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Closeしたときにソケットファイルも消える
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// inheritedListenFD は、受け継いだ待ち受け用のファイルディスクリプタを返します
+// systemdの環境変数は子プロセスに渡さないよう消す必要があるので、設定には含めずここで読みます
+func inheritedListenFD(c config.Server) (int, bool) {
+	if c.ListenFD >= 0 {
+		return c.ListenFD, true
+	}
+
+	// LISTEN_PIDが自分宛てでなければ、別のプロセスに渡されたものなので使わない
+	pid, err := strconv.Atoi(os.Getenv(systemdListenPIDEnvKey))
+	if err != nil || pid != os.Getpid() {
+		return 0, false
+	}
+	n, err := strconv.Atoi(os.Getenv(systemdListenFDsEnvKey))
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	// 子プロセスに受け継がれないようにする
+	os.Unsetenv(systemdListenPIDEnvKey)
+	os.Unsetenv(systemdListenFDsEnvKey)
+	return systemdListenFDsStart, true
+}
+
+// serve は、SIGINTかSIGTERMを受け取るまでlistenerでリクエストを処理します
+// シグナルを受け取ったら新しい接続の受け付けをやめ、処理中のリクエストが終わるまでtimeoutを上限に待ってから戻ります
+// h2cを有効にしたときは、HTTP/2の接続はhttp.Serverの管理から外れるため、その接続で処理中のリクエストは待ちません
+func serve(e *echo.Echo, listener net.Listener, c config.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	e.Listener = listener
+	serveErr := make(chan error, 1)
+	go func() {
+		if c.H2C {
+			// HTTP/1.1のリクエストはそのまま、h2cへのアップグレードと事前知識によるHTTP/2の接続はHTTP/2として処理する
+			serveErr <- e.StartH2CServer("", &http2.Server{})
+			return
+		}
+		serveErr <- e.Start("")
+	}()
+
+	select {
+	case err := <-serveErr:
+		// シグナルを受け取る前に止まったのは、起動や待ち受けの失敗
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	e.Logger.Infof("shutting down: waiting up to %s for in-flight requests", c.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), c.ShutdownTimeout)
+	defer cancel()
+	// Shutdownは処理中のリクエストがすべて終わるまで戻らない
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to drain in-flight requests: %w", err)
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// waitForShutdownSignal は、SIGINTかSIGTERMを受け取るまで待ちます
+// HTTPのリクエストを処理しないworkerで、serveの代わりに使います
+func waitForShutdownSignal() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+}
+
+// flushOnShutdown は、終了前にメモリ上に溜まっている書き込みをDBへ書き出します
+func flushOnShutdown(ctx context.Context) error {
+	if err := flushBatchWriters(ctx); err != nil {
+		return fmt.Errorf("failed to flush pending writes: %w", err)
+	}
+	if err := slowMode.persist(ctx, dbConn); err != nil {
+		return fmt.Errorf("failed to persist livecomment cooldowns: %w", err)
+	}
+	return nil
+}
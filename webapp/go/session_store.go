@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/gob"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// newSessionStore は、セッションの保存先を返します
+// flagServerSessionsが有効ならsharedCacheに、無効ならクッキーにセッションの中身を保存します
+// キャッシュがプロセス内にしかないときは、再起動しても消えないようにクッキーに載せるのが既定です
+func newSessionStore() sessions.Store {
+	cookieStore := sessions.NewCookieStore(secret)
+	cookieStore.Options.Domain = "*.u.isucon.dev"
+	cacheStore := newCacheSessionStore(secret)
+	cacheStore.options.Domain = "*.u.isucon.dev"
+	return &flaggedSessionStore{cookie: cookieStore, cache: cacheStore}
+}
+
+// flaggedSessionStore は、flagServerSessionsに応じて保存先を切り替えるsessions.Storeです
+// 切り替える前に発行したセッションは、もう一方の保存先からは読めないので、ログインし直しになります
+type flaggedSessionStore struct {
+	cookie sessions.Store
+	cache  sessions.Store
+}
+
+func (s *flaggedSessionStore) store() sessions.Store {
+	if flagServerSessions.enabled() {
+		return s.cache
+	}
+	return s.cookie
+}
+
+func (s *flaggedSessionStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	// リクエストの中で保存先が変わらないよう、自身ではなく選んだ保存先をレジストリに渡す
+	return sessions.GetRegistry(r).Get(s.store(), name)
+}
+
+func (s *flaggedSessionStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	return s.store().New(r, name)
+}
+
+func (s *flaggedSessionStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	return s.store().Save(r, w, session)
+}
+
+// cacheSessionStore は、セッションの中身をsharedCacheに保存するsessions.Storeです
+// クッキーには署名したセッションIDのみを載せます
+// キャッシュをRedisで共有すれば、どのアプリケーションサーバでも同じセッションを読めます
+type cacheSessionStore struct {
+	codecs  []securecookie.Codec
+	options *sessions.Options
+}
+
+func newCacheSessionStore(keyPairs ...[]byte) *cacheSessionStore {
+	return &cacheSessionStore{
+		codecs: securecookie.CodecsFromPairs(keyPairs...),
+		options: &sessions.Options{
+			Path:   "/",
+			MaxAge: 86400 * 30,
+		},
+	}
+}
+
+func sessionCacheKey(id string) string {
+	return "session:" + id
+}
+
+func (s *cacheSessionStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+func (s *cacheSessionStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	options := *s.options
+	session.Options = &options
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	if err := securecookie.DecodeMulti(name, cookie.Value, &session.ID, s.codecs...); err != nil {
+		return session, err
+	}
+
+	b, ok, err := sharedCache.Get(r.Context(), sessionCacheKey(session.ID))
+	if err != nil {
+		return session, err
+	}
+	// 期限切れなどで消えていれば、新しいセッションとして扱う
+	if !ok {
+		return session, nil
+	}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&session.Values); err != nil {
+		return session, err
+	}
+	session.IsNew = false
+	return session, nil
+}
+
+func (s *cacheSessionStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	// MaxAgeが負のときはセッションを破棄する
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := sharedCache.Delete(r.Context(), sessionCacheKey(session.ID)); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(session.Values); err != nil {
+		return err
+	}
+	ttl := time.Duration(session.Options.MaxAge) * time.Second
+	if err := sharedCache.Set(r.Context(), sessionCacheKey(session.ID), buf.Bytes(), ttl); err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"github.com/isucon/isucon13/webapp/go/internal/cache"
+	"github.com/isucon/isucon13/webapp/go/internal/config"
+)
+
+const (
+	// 同じRedisを他の用途と共有しても衝突しないように付ける
+	cacheKeyPrefix    = "isupipe:"
+	redisMaxIdleConns = 64
+)
+
+// sharedCache は、アプリケーションサーバ間で共有したいキャッシュです
+// アイコンのハッシュ、統計情報などの計算結果、セッションの保存に使います
+var sharedCache cache.Cache = cache.NewMemory()
+
+// newSharedCache は、設定に応じたキャッシュのバックエンドを返します
+func newSharedCache(c config.Cache) cache.Cache {
+	if c.Backend == config.CacheBackendRedis {
+		return cache.NewRedis(c.RedisAddr, cacheKeyPrefix, redisMaxIdleConns)
+	}
+	return cache.NewMemory()
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// 低速モードの最終投稿時刻をDBへ書き出す間隔
+const slowModePersistInterval = 5 * time.Second
+
+type LivestreamSettingModel struct {
+	LivestreamID      int64           `db:"livestream_id"`
+	SlowModeSeconds   int64           `db:"slow_mode_seconds"`
+	SuperchatNGPolicy string          `db:"superchat_ng_policy"`
+	SpamHideThreshold sql.NullFloat64 `db:"spam_hide_threshold"`
+	Version           int64           `db:"version"`
+}
+
+type LivecommentCooldownModel struct {
+	LivestreamID    int64 `db:"livestream_id"`
+	UserID          int64 `db:"user_id"`
+	LastCommentedAt int64 `db:"last_commented_at"`
+}
+
+type slowModeKey struct {
+	livestreamID int64
+	userID       int64
+}
+
+// slowModeTracker は、配信ごとの低速モード設定と、ユーザごとの最終投稿時刻をメモリ上で管理します
+// 最終投稿時刻は定期的にDBへ書き出し、再起動しても制限が外れないようにします
+type slowModeTracker struct {
+	mu sync.Mutex
+	// livestream_id => 投稿間隔の秒数
+	intervals       map[int64]int64
+	lastCommentedAt map[slowModeKey]int64
+	dirty           map[slowModeKey]struct{}
+}
+
+var slowMode = newSlowModeTracker()
+
+func newSlowModeTracker() *slowModeTracker {
+	return &slowModeTracker{
+		intervals:       make(map[int64]int64),
+		lastCommentedAt: make(map[slowModeKey]int64),
+		dirty:           make(map[slowModeKey]struct{}),
+	}
+}
+
+// reset は、メモリ上の状態をすべて破棄します
+func (t *slowModeTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.intervals = make(map[int64]int64)
+	t.lastCommentedAt = make(map[slowModeKey]int64)
+	t.dirty = make(map[slowModeKey]struct{})
+}
+
+// load は、DBに保存された設定と最終投稿時刻でメモリ上の状態を置き換えます
+func (t *slowModeTracker) load(ctx context.Context, db *sqlx.DB) error {
+	var settings []LivestreamSettingModel
+	if err := db.SelectContext(ctx, &settings, "SELECT * FROM livestream_settings WHERE slow_mode_seconds > 0"); err != nil {
+		return err
+	}
+	var cooldowns []LivecommentCooldownModel
+	if err := db.SelectContext(ctx, &cooldowns, "SELECT * FROM livecomment_cooldowns"); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.intervals = make(map[int64]int64, len(settings))
+	for _, setting := range settings {
+		t.intervals[setting.LivestreamID] = setting.SlowModeSeconds
+	}
+	t.lastCommentedAt = make(map[slowModeKey]int64, len(cooldowns))
+	for _, cooldown := range cooldowns {
+		t.lastCommentedAt[slowModeKey{livestreamID: cooldown.LivestreamID, userID: cooldown.UserID}] = cooldown.LastCommentedAt
+	}
+	t.dirty = make(map[slowModeKey]struct{})
+
+	return nil
+}
+
+// setInterval は、配信の投稿間隔を設定します。0以下で低速モードを解除します
+func (t *slowModeTracker) setInterval(livestreamID int64, seconds int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if seconds <= 0 {
+		delete(t.intervals, livestreamID)
+		return
+	}
+	t.intervals[livestreamID] = seconds
+}
+
+// allow は、低速モードの制限内であれば投稿時刻を記録してtrueを返します
+// 制限に掛かる場合は、次に投稿できるまでの秒数を返します
+func (t *slowModeTracker) allow(livestreamID int64, userID int64, now int64) (bool, int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	interval, ok := t.intervals[livestreamID]
+	if !ok {
+		return true, 0
+	}
+
+	key := slowModeKey{livestreamID: livestreamID, userID: userID}
+	if last, ok := t.lastCommentedAt[key]; ok && now < last+interval {
+		return false, last + interval - now
+	}
+	t.lastCommentedAt[key] = now
+	t.dirty[key] = struct{}{}
+	return true, 0
+}
+
+// persist は、前回の書き出し以降に更新された最終投稿時刻をDBへ書き出します
+func (t *slowModeTracker) persist(ctx context.Context, db *sqlx.DB) error {
+	t.mu.Lock()
+	cooldowns := make([]LivecommentCooldownModel, 0, len(t.dirty))
+	for key := range t.dirty {
+		cooldowns = append(cooldowns, LivecommentCooldownModel{
+			LivestreamID:    key.livestreamID,
+			UserID:          key.userID,
+			LastCommentedAt: t.lastCommentedAt[key],
+		})
+	}
+	t.dirty = make(map[slowModeKey]struct{})
+	t.mu.Unlock()
+
+	if len(cooldowns) == 0 {
+		return nil
+	}
+	_, err := db.NamedExecContext(ctx, "INSERT INTO livecomment_cooldowns (livestream_id, user_id, last_commented_at) VALUES (:livestream_id, :user_id, :last_commented_at) ON DUPLICATE KEY UPDATE last_commented_at = VALUES(last_commented_at)", cooldowns)
+	return err
+}
+
+// runPersister は、persistを定期的に実行します
+func (t *slowModeTracker) runPersister(db *sqlx.DB, logger echo.Logger) {
+	ticker := time.NewTicker(slowModePersistInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := t.persist(context.Background(), db); err != nil {
+			logger.Warnf("failed to persist livecomment cooldowns: %v", err)
+		}
+	}
+}
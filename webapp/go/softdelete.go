@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/isucon/isucon13/webapp/go/internal/config"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// users, livecommentsは、削除した日時をdeleted_atに記録する論理削除とする
+// 削除の記録を残し、統計情報や売上の検証で消えた行と食い違わないようにする
+// これらのテーブルを読むクエリは、excludeDeletedを通して論理削除した行を除く
+
+// 一度の物理削除で消す行の数の上限
+// ロックを長く取らないよう、上限に達したら続きを次のDELETEで消す
+const purgeBatchSize = 1000
+
+var (
+	whereClausePattern = regexp.MustCompile(`\sWHERE\s`)
+	// WHERE句の後に続く句
+	trailingClausePattern = regexp.MustCompile(`\s(?:GROUP BY|HAVING|ORDER BY|LIMIT|FOR UPDATE)\b`)
+)
+
+// excludeDeleted は、queryのWHERE句に、aliasのテーブルの論理削除していない行のみに絞り込む条件を加えます
+// aliasが空のときは、テーブル名を付けずに条件を書きます
+// 既存の条件は括弧で囲むので、ORを含む条件にも使えます
+// 句の位置は文字列から探すので、WHEREやORDER BYを含むサブクエリを持つクエリには使わないでください
+func excludeDeleted(query, alias string) string {
+	column := "deleted_at"
+	if alias != "" {
+		column = alias + "." + column
+	}
+	cond := column + " IS NULL"
+
+	head, tail := query, ""
+	if loc := trailingClausePattern.FindStringIndex(query); loc != nil {
+		head, tail = query[:loc[0]], query[loc[0]:]
+	}
+	if loc := whereClausePattern.FindStringIndex(head); loc != nil {
+		return head[:loc[1]] + "(" + head[loc[1]:] + ") AND " + cond + tail
+	}
+	return head + " WHERE " + cond + tail
+}
+
+// runSoftDeletePurger は、保持期間を過ぎた論理削除済みの行を定期的に物理削除します
+// 保持期間が0のときは何もしません
+func runSoftDeletePurger(db *sqlx.DB, c config.SoftDelete, logger echo.Logger) {
+	if c.Retention <= 0 {
+		return
+	}
+	ticker := time.NewTicker(c.PurgeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n, err := purgeSoftDeleted(context.Background(), db, time.Now().Add(-c.Retention).Unix())
+		if err != nil {
+			logger.Warnf("failed to purge soft-deleted rows: %v", err)
+			continue
+		}
+		if n > 0 {
+			logger.Infof("purged %d soft-deleted rows", n)
+		}
+	}
+}
+
+// purgeSoftDeleted は、cutoffより前に論理削除した行を物理削除し、消した行の数を返します
+// ライブコメントは、参照するスパム報告も合わせて消します
+func purgeSoftDeleted(ctx context.Context, db *sqlx.DB, cutoff int64) (int64, error) {
+	var total int64
+	result, err := db.ExecContext(ctx, "DELETE r FROM livecomment_reports r INNER JOIN livecomments l ON l.id = r.livecomment_id WHERE l.deleted_at < ?", cutoff)
+	if err != nil {
+		return total, fmt.Errorf("failed to purge livecomment reports: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	total += n
+
+	for _, query := range []string{
+		"DELETE FROM livecomments WHERE deleted_at < ? LIMIT ?",
+		"DELETE FROM users WHERE deleted_at < ? LIMIT ?",
+	} {
+		for {
+			result, err := db.ExecContext(ctx, query, cutoff, purgeBatchSize)
+			if err != nil {
+				return total, fmt.Errorf("failed to purge: %w", err)
+			}
+			n, _ := result.RowsAffected()
+			total += n
+			if n < purgeBatchSize {
+				break
+			}
+		}
+	}
+	return total, nil
+}
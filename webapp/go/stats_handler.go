@@ -1,21 +1,39 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"net/http"
 	"sort"
 	"strconv"
+	"time"
 
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
 )
 
+// 統計情報を使い回す期間
+// 算出に時間がかかるため、同時に来たリクエストをまとめつつ、ずれが目立たない程度に短くする
+const statisticsMemoTTL = 500 * time.Millisecond
+
+var (
+	userStatisticsMemo       = newMemo[UserStatistics]("user_statistics", statisticsMemoTTL)
+	livestreamStatisticsMemo = newMemo[LivestreamStatistics]("livestream_statistics", statisticsMemoTTL)
+)
+
 type LivestreamStatistics struct {
 	Rank           int64 `json:"rank"`
 	ViewersCount   int64 `json:"viewers_count"`
 	TotalReactions int64 `json:"total_reactions"`
 	TotalReports   int64 `json:"total_reports"`
 	MaxTip         int64 `json:"max_tip"`
+	// 算出した時刻 (UNIXミリ秒) と、レスポンスを返すまでの経過時間
+	// 集計済みの結果やmemoを使うと、その分だけ古い値を返す
+	ComputedAt  int64 `json:"computed_at"`
+	StalenessMs int64 `json:"staleness_ms"`
 }
 
 type LivestreamRankingEntry struct {
@@ -41,6 +59,9 @@ type UserStatistics struct {
 	TotalLivecomments int64  `json:"total_livecomments"`
 	TotalTip          int64  `json:"total_tip"`
 	FavoriteEmoji     string `json:"favorite_emoji"`
+	// LivestreamStatisticsと同じ
+	ComputedAt  int64 `json:"computed_at"`
+	StalenessMs int64 `json:"staleness_ms"`
 }
 
 type UserRankingEntry struct {
@@ -70,55 +91,86 @@ func getUserStatisticsHandler(c echo.Context) error {
 	username := c.Param("username")
 	// ユーザごとに、紐づく配信について、累計リアクション数、累計ライブコメント数、累計売上金額を算出
 	// また、現在の合計視聴者数もだす
+	fresh, err := freshParam(c)
+	if err != nil {
+		return err
+	}
+
+	db := readDB(c)
+
+	stats, ok, err := getPrecomputedUserStatistics(ctx, db, username, fresh)
+	if err != nil {
+		return err
+	}
+	if !ok && fresh {
+		stats, err = computeUserStatistics(ctx, db, username)
+	} else if !ok {
+		// 同じユーザの統計情報は、同時に来たリクエストや直後のリクエストで使い回す
+		stats, err = userStatisticsMemo.do(ctx, username, func(ctx context.Context) (UserStatistics, error) {
+			return computeUserStatistics(ctx, db, username)
+		})
+	}
+	if err != nil {
+		return err
+	}
+	stats.StalenessMs = max(time.Now().UnixMilli()-stats.ComputedAt, 0)
+
+	return c.JSON(http.StatusOK, stats)
+}
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
+// getPrecomputedUserStatistics は、集計済みの統計情報を使えるときに返します
+func getPrecomputedUserStatistics(ctx context.Context, db *sqlx.DB, username string, fresh bool) (UserStatistics, bool, error) {
+	if fresh || !flagPrecomputedStatistics.enabled() {
+		return UserStatistics{}, false, nil
+	}
+	user, err := userProfiles.getByName(ctx, db, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return UserStatistics{}, false, apperror.BadRequest("not found user that has the given username")
+	}
+	if err != nil {
+		return UserStatistics{}, false, echo.NewHTTPError(http.StatusInternalServerError, "failed to get user: "+err.Error())
+	}
+	stats, ok, err := loadUserStatistics(ctx, db, user.ID)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
+		return UserStatistics{}, false, echo.NewHTTPError(http.StatusInternalServerError, "failed to get user statistics: "+err.Error())
 	}
-	defer tx.Rollback()
+	return stats, ok, nil
+}
 
-	var user UserModel
-	if err := tx.GetContext(ctx, &user, "SELECT * FROM users WHERE name = ?", username); err != nil {
+// computeUserStatistics は、ユーザの統計情報を算出します
+func computeUserStatistics(ctx context.Context, db *sqlx.DB, username string) (UserStatistics, error) {
+	computedAt := time.Now().UnixMilli()
+	user, err := userProfiles.getByName(ctx, db, username)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return echo.NewHTTPError(http.StatusBadRequest, "not found user that has the given username")
+			return UserStatistics{}, apperror.BadRequest("not found user that has the given username")
 		} else {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user: "+err.Error())
+			return UserStatistics{}, echo.NewHTTPError(http.StatusInternalServerError, "failed to get user: "+err.Error())
 		}
 	}
 
 	// ランク算出
 	var users []*UserModel
-	if err := tx.SelectContext(ctx, &users, "SELECT * FROM users"); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get users: "+err.Error())
+	if err := db.SelectContext(ctx, &users, excludeDeleted("SELECT * FROM users", "")); err != nil {
+		return UserStatistics{}, echo.NewHTTPError(http.StatusInternalServerError, "failed to get users: "+err.Error())
+	}
+	if err := recountUserScores(ctx, db, users); err != nil {
+		return UserStatistics{}, echo.NewHTTPError(http.StatusInternalServerError, "failed to count scores: "+err.Error())
 	}
 
+	// スコアや合計値は、配信者ごとの集計カラムから求める
 	var ranking UserRanking
-	for _, user := range users {
-		var reactions int64
-		query := `
-		SELECT COUNT(*) FROM users u
-		INNER JOIN livestreams l ON l.user_id = u.id
-		INNER JOIN reactions r ON r.livestream_id = l.id
-		WHERE u.id = ?`
-		if err := tx.GetContext(ctx, &reactions, query, user.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to count reactions: "+err.Error())
-		}
-
-		var tips int64
-		query = `
-		SELECT IFNULL(SUM(l2.tip), 0) FROM users u
-		INNER JOIN livestreams l ON l.user_id = u.id	
-		INNER JOIN livecomments l2 ON l2.livestream_id = l.id
-		WHERE u.id = ?`
-		if err := tx.GetContext(ctx, &tips, query, user.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to count tips: "+err.Error())
-		}
-
-		score := reactions + tips
+	var totalReactions, totalLivecomments, totalTip int64
+	for _, userModel := range users {
 		ranking = append(ranking, UserRankingEntry{
-			Username: user.Name,
-			Score:    score,
+			Username: userModel.Name,
+			Score:    userModel.ReactionCount + userModel.TotalTip,
 		})
+		if userModel.ID == user.ID {
+			totalReactions = userModel.ReactionCount
+			totalLivecomments = userModel.CommentCount
+			totalTip = userModel.TotalTip
+		}
 	}
 	sort.Sort(ranking)
 
@@ -131,50 +183,24 @@ func getUserStatisticsHandler(c echo.Context) error {
 		rank++
 	}
 
-	// リアクション数
-	var totalReactions int64
-	query := `SELECT COUNT(*) FROM users u 
-    INNER JOIN livestreams l ON l.user_id = u.id 
-    INNER JOIN reactions r ON r.livestream_id = l.id
-    WHERE u.name = ?
-	`
-	if err := tx.GetContext(ctx, &totalReactions, query, username); err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count total reactions: "+err.Error())
-	}
-
-	// ライブコメント数、チップ合計
-	var totalLivecomments int64
-	var totalTip int64
 	var livestreams []*LivestreamModel
-	if err := tx.SelectContext(ctx, &livestreams, "SELECT * FROM livestreams WHERE user_id = ?", user.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestreams: "+err.Error())
-	}
-
-	for _, livestream := range livestreams {
-		var livecomments []*LivecommentModel
-		if err := tx.SelectContext(ctx, &livecomments, "SELECT * FROM livecomments WHERE livestream_id = ?", livestream.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livecomments: "+err.Error())
-		}
-
-		for _, livecomment := range livecomments {
-			totalTip += livecomment.Tip
-			totalLivecomments++
-		}
+	if err := db.SelectContext(ctx, &livestreams, "SELECT * FROM livestreams WHERE user_id = ?", user.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return UserStatistics{}, echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestreams: "+err.Error())
 	}
 
 	// 合計視聴者数
 	var viewersCount int64
 	for _, livestream := range livestreams {
 		var cnt int64
-		if err := tx.GetContext(ctx, &cnt, "SELECT COUNT(*) FROM livestream_viewers_history WHERE livestream_id = ?", livestream.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream_view_history: "+err.Error())
+		if err := db.GetContext(ctx, &cnt, "SELECT COUNT(*) FROM livestream_viewers_history WHERE livestream_id = ?", livestream.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return UserStatistics{}, echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream_view_history: "+err.Error())
 		}
 		viewersCount += cnt
 	}
 
 	// お気に入り絵文字
 	var favoriteEmoji string
-	query = `
+	query := `
 	SELECT r.emoji_name
 	FROM users u
 	INNER JOIN livestreams l ON l.user_id = u.id
@@ -184,8 +210,8 @@ func getUserStatisticsHandler(c echo.Context) error {
 	ORDER BY COUNT(*) DESC, emoji_name DESC
 	LIMIT 1
 	`
-	if err := tx.GetContext(ctx, &favoriteEmoji, query, username); err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to find favorite emoji: "+err.Error())
+	if err := db.GetContext(ctx, &favoriteEmoji, excludeDeleted(query, "u"), username); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return UserStatistics{}, echo.NewHTTPError(http.StatusInternalServerError, "failed to find favorite emoji: "+err.Error())
 	}
 
 	stats := UserStatistics{
@@ -195,8 +221,10 @@ func getUserStatisticsHandler(c echo.Context) error {
 		TotalLivecomments: totalLivecomments,
 		TotalTip:          totalTip,
 		FavoriteEmoji:     favoriteEmoji,
+		ComputedAt:        computedAt,
 	}
-	return c.JSON(http.StatusOK, stats)
+
+	return stats, nil
 }
 
 func getLivestreamStatisticsHandler(c echo.Context) error {
@@ -208,48 +236,83 @@ func getLivestreamStatisticsHandler(c echo.Context) error {
 
 	id, err := strconv.Atoi(c.Param("livestream_id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "livestream_id in path must be integer")
+		return apperror.BadRequest("livestream_id in path must be integer")
 	}
 	livestreamID := int64(id)
-
-	tx, err := dbConn.BeginTxx(ctx, nil)
+	fresh, err := freshParam(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
+		return err
 	}
-	defer tx.Rollback()
+
+	db := readDB(c)
 
 	var livestream LivestreamModel
-	if err := tx.GetContext(ctx, &livestream, "SELECT * FROM livestreams WHERE id = ?", livestreamID); err != nil {
+	if err := preparedGet(ctx, db, &livestream, queryLivestreamByID, livestreamID); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return echo.NewHTTPError(http.StatusBadRequest, "cannot get stats of not found livestream")
+			return apperror.BadRequest("cannot get stats of not found livestream")
 		} else {
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream: "+err.Error())
 		}
 	}
 
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	if !isLivestreamVisible(livestream, userID) {
+		return apperror.BadRequest("cannot get stats of not found livestream")
+	}
+
+	var (
+		stats LivestreamStatistics
+		ok    bool
+	)
+	if !fresh && flagPrecomputedStatistics.enabled() {
+		stats, ok, err = loadLivestreamStatistics(ctx, db, livestreamID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestream statistics: "+err.Error())
+		}
+	}
+	if !ok && fresh {
+		stats, err = computeLivestreamStatistics(ctx, db, livestreamID)
+	} else if !ok {
+		// 閲覧できるかの確認はユーザごとに行い、統計情報そのものは配信ごとに使い回す
+		stats, err = livestreamStatisticsMemo.do(ctx, strconv.FormatInt(livestreamID, 10), func(ctx context.Context) (LivestreamStatistics, error) {
+			return computeLivestreamStatistics(ctx, db, livestreamID)
+		})
+	}
+	if err != nil {
+		return err
+	}
+	stats.StalenessMs = max(time.Now().UnixMilli()-stats.ComputedAt, 0)
+
+	return c.JSON(http.StatusOK, stats)
+}
+
+// computeLivestreamStatistics は、ライブ配信の統計情報を算出します
+func computeLivestreamStatistics(ctx context.Context, db *sqlx.DB, livestreamID int64) (LivestreamStatistics, error) {
+	computedAt := time.Now().UnixMilli()
 	var livestreams []*LivestreamModel
-	if err := tx.SelectContext(ctx, &livestreams, "SELECT * FROM livestreams"); err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestreams: "+err.Error())
+	if err := db.SelectContext(ctx, &livestreams, "SELECT * FROM livestreams"); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return LivestreamStatistics{}, echo.NewHTTPError(http.StatusInternalServerError, "failed to get livestreams: "+err.Error())
+	}
+	if err := recountLivestreamScores(ctx, db, livestreams); err != nil {
+		return LivestreamStatistics{}, echo.NewHTTPError(http.StatusInternalServerError, "failed to count scores: "+err.Error())
 	}
 
 	// ランク算出
+	// スコアやリアクション数は、配信ごとの集計カラムから求める
 	var ranking LivestreamRanking
+	var totalReactions int64
 	for _, livestream := range livestreams {
-		var reactions int64
-		if err := tx.GetContext(ctx, &reactions, "SELECT COUNT(*) FROM livestreams l INNER JOIN reactions r ON l.id = r.livestream_id WHERE l.id = ?", livestream.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to count reactions: "+err.Error())
-		}
-
-		var totalTips int64
-		if err := tx.GetContext(ctx, &totalTips, "SELECT IFNULL(SUM(l2.tip), 0) FROM livestreams l INNER JOIN livecomments l2 ON l.id = l2.livestream_id WHERE l.id = ?", livestream.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to count tips: "+err.Error())
-		}
-
-		score := reactions + totalTips
 		ranking = append(ranking, LivestreamRankingEntry{
 			LivestreamID: livestream.ID,
-			Score:        score,
+			Score:        livestream.ReactionCount + livestream.TotalTip,
 		})
+		if livestream.ID == livestreamID {
+			totalReactions = livestream.ReactionCount
+		}
 	}
 	sort.Sort(ranking)
 
@@ -264,37 +327,28 @@ func getLivestreamStatisticsHandler(c echo.Context) error {
 
 	// 視聴者数算出
 	var viewersCount int64
-	if err := tx.GetContext(ctx, &viewersCount, `SELECT COUNT(*) FROM livestreams l INNER JOIN livestream_viewers_history h ON h.livestream_id = l.id WHERE l.id = ?`, livestreamID); err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count livestream viewers: "+err.Error())
+	if err := db.GetContext(ctx, &viewersCount, `SELECT COUNT(*) FROM livestreams l INNER JOIN livestream_viewers_history h ON h.livestream_id = l.id WHERE l.id = ?`, livestreamID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return LivestreamStatistics{}, echo.NewHTTPError(http.StatusInternalServerError, "failed to count livestream viewers: "+err.Error())
 	}
 
 	// 最大チップ額
 	var maxTip int64
-	if err := tx.GetContext(ctx, &maxTip, `SELECT IFNULL(MAX(tip), 0) FROM livestreams l INNER JOIN livecomments l2 ON l2.livestream_id = l.id WHERE l.id = ?`, livestreamID); err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to find maximum tip livecomment: "+err.Error())
-	}
-
-	// リアクション数
-	var totalReactions int64
-	if err := tx.GetContext(ctx, &totalReactions, "SELECT COUNT(*) FROM livestreams l INNER JOIN reactions r ON r.livestream_id = l.id WHERE l.id = ?", livestreamID); err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count total reactions: "+err.Error())
+	if err := db.GetContext(ctx, &maxTip, `SELECT IFNULL(MAX(tip), 0) FROM livestreams l INNER JOIN livecomments l2 ON l2.livestream_id = l.id WHERE l.id = ? AND l2.deleted_at IS NULL`, livestreamID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return LivestreamStatistics{}, echo.NewHTTPError(http.StatusInternalServerError, "failed to find maximum tip livecomment: "+err.Error())
 	}
 
 	// スパム報告数
 	var totalReports int64
-	if err := tx.GetContext(ctx, &totalReports, `SELECT COUNT(*) FROM livestreams l INNER JOIN livecomment_reports r ON r.livestream_id = l.id WHERE l.id = ?`, livestreamID); err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count total spam reports: "+err.Error())
-	}
-
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+	if err := db.GetContext(ctx, &totalReports, `SELECT COUNT(*) FROM livestreams l INNER JOIN livecomment_reports r ON r.livestream_id = l.id WHERE l.id = ?`, livestreamID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return LivestreamStatistics{}, echo.NewHTTPError(http.StatusInternalServerError, "failed to count total spam reports: "+err.Error())
 	}
 
-	return c.JSON(http.StatusOK, LivestreamStatistics{
+	return LivestreamStatistics{
 		Rank:           rank,
 		ViewersCount:   viewersCount,
 		MaxTip:         maxTip,
 		TotalReactions: totalReactions,
 		TotalReports:   totalReports,
-	})
+		ComputedAt:     computedAt,
+	}, nil
 }
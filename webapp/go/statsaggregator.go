@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// 統計情報を集計し直す間隔
+	statisticsAggregateInterval = time.Second
+	// これより古い集計結果は使わずに、都度算出する
+	// フラグを一度無効にしてから有効に戻したときに、古い結果を返さないようにする
+	statisticsMaxStaleness = 5 * statisticsAggregateInterval
+	// 集計結果を1回のINSERTで書き込む行数
+	statisticsUpsertBatchSize = 500
+	// 書き込みのイベントで集計し直すときも、前回の集計からこれだけは間を空ける
+	statisticsMinAggregateGap = 200 * time.Millisecond
+
+	// 指定すると、集計結果を使わずにその場で算出する
+	freshQueryParam = "fresh"
+)
+
+// freshParam は、freshクエリパラメータを返します
+func freshParam(c echo.Context) (bool, error) {
+	v := c.QueryParam(freshQueryParam)
+	if v == "" {
+		return false, nil
+	}
+	fresh, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, apperror.BadRequest("fresh query parameter must be boolean")
+	}
+	return fresh, nil
+}
+
+// 次の定期集計を待たずに集計し直す要求。溜まった要求は1回の集計にまとめる
+var statisticsAggregateRequests = make(chan struct{}, 1)
+
+// requestStatisticsAggregation は、スーパーチャットの投稿や配信の予約のイベントを受けて、集計し直すよう要求します
+func requestStatisticsAggregation(ctx context.Context, ev event) error {
+	select {
+	case statisticsAggregateRequests <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// runStatisticsAggregator は、統計情報を定期的に全件集計し、user_statistics, livestream_statisticsに書き込みます
+// requestStatisticsAggregationで要求されたときも、次の定期集計を待たずに集計します
+// 機能フラグprecomputed_statisticsが無効な間は何もしません
+// アプリケーションサーバごとに動くので、複数台で有効にすると同じ集計を重ねて行います
+func runStatisticsAggregator(db *sqlx.DB, logger echo.Logger) {
+	ticker := time.NewTicker(statisticsAggregateInterval)
+	defer ticker.Stop()
+
+	var last time.Time
+	for {
+		select {
+		case <-ticker.C:
+		case <-statisticsAggregateRequests:
+			// 書き込みが続いても集計し続けないよう、前回から間を空ける
+			if wait := statisticsMinAggregateGap - time.Since(last); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		if !flagPrecomputedStatistics.enabled() {
+			continue
+		}
+		ctx := context.Background()
+		if err := aggregateUserStatistics(ctx, db); err != nil {
+			logger.Warnf("failed to aggregate user statistics: %v", err)
+		}
+		if err := aggregateLivestreamStatistics(ctx, db); err != nil {
+			logger.Warnf("failed to aggregate livestream statistics: %v", err)
+		}
+		last = time.Now()
+	}
+}
+
+type userStatisticsModel struct {
+	UserID            int64  `db:"user_id"`
+	Rank              int64  `db:"rank"`
+	ViewersCount      int64  `db:"viewers_count"`
+	TotalReactions    int64  `db:"total_reactions"`
+	TotalLivecomments int64  `db:"total_livecomments"`
+	TotalTip          int64  `db:"total_tip"`
+	FavoriteEmoji     string `db:"favorite_emoji"`
+	ComputedAt        int64  `db:"computed_at"`
+}
+
+type livestreamStatisticsModel struct {
+	LivestreamID   int64 `db:"livestream_id"`
+	Rank           int64 `db:"rank"`
+	ViewersCount   int64 `db:"viewers_count"`
+	TotalReactions int64 `db:"total_reactions"`
+	TotalReports   int64 `db:"total_reports"`
+	MaxTip         int64 `db:"max_tip"`
+	ComputedAt     int64 `db:"computed_at"`
+}
+
+// loadUserStatistics は、集計済みのユーザの統計情報を返します
+// まだ集計されていないか、集計結果が古ければfalseを返します
+func loadUserStatistics(ctx context.Context, db *sqlx.DB, userID int64) (UserStatistics, bool, error) {
+	var m userStatisticsModel
+	if err := db.GetContext(ctx, &m, "SELECT * FROM user_statistics WHERE user_id = ?", userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return UserStatistics{}, false, nil
+		}
+		return UserStatistics{}, false, err
+	}
+	if time.Since(time.UnixMilli(m.ComputedAt)) > statisticsMaxStaleness {
+		return UserStatistics{}, false, nil
+	}
+	return UserStatistics{
+		Rank:              m.Rank,
+		ViewersCount:      m.ViewersCount,
+		TotalReactions:    m.TotalReactions,
+		TotalLivecomments: m.TotalLivecomments,
+		TotalTip:          m.TotalTip,
+		FavoriteEmoji:     m.FavoriteEmoji,
+		ComputedAt:        m.ComputedAt,
+	}, true, nil
+}
+
+// loadLivestreamStatistics は、集計済みのライブ配信の統計情報を返します
+// まだ集計されていないか、集計結果が古ければfalseを返します
+func loadLivestreamStatistics(ctx context.Context, db *sqlx.DB, livestreamID int64) (LivestreamStatistics, bool, error) {
+	var m livestreamStatisticsModel
+	if err := db.GetContext(ctx, &m, "SELECT * FROM livestream_statistics WHERE livestream_id = ?", livestreamID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return LivestreamStatistics{}, false, nil
+		}
+		return LivestreamStatistics{}, false, err
+	}
+	if time.Since(time.UnixMilli(m.ComputedAt)) > statisticsMaxStaleness {
+		return LivestreamStatistics{}, false, nil
+	}
+	return LivestreamStatistics{
+		Rank:           m.Rank,
+		ViewersCount:   m.ViewersCount,
+		TotalReactions: m.TotalReactions,
+		TotalReports:   m.TotalReports,
+		MaxTip:         m.MaxTip,
+		ComputedAt:     m.ComputedAt,
+	}, true, nil
+}
+
+// aggregateUserStatistics は、すべてのユーザの統計情報をcomputeUserStatisticsと同じ規則で集計します
+// ユーザごとにクエリを発行せず、GROUP BYでまとめて求めます
+func aggregateUserStatistics(ctx context.Context, db *sqlx.DB) error {
+	computedAt := time.Now().UnixMilli()
+
+	var users []*UserModel
+	if err := db.SelectContext(ctx, &users, excludeDeleted("SELECT * FROM users", "")); err != nil {
+		return err
+	}
+	if err := recountUserScores(ctx, db, users); err != nil {
+		return err
+	}
+	ranking := make(UserRanking, 0, len(users))
+	for _, user := range users {
+		ranking = append(ranking, UserRankingEntry{Username: user.Name, Score: user.ReactionCount + user.TotalTip})
+	}
+	sort.Sort(ranking)
+	ranks := make(map[string]int64, len(ranking))
+	for i, entry := range ranking {
+		ranks[entry.Username] = int64(len(ranking) - i)
+	}
+
+	viewers, err := selectCountsByID(ctx, db, `
+	SELECT l.user_id AS id, COUNT(*) AS n
+	FROM livestream_viewers_history h
+	INNER JOIN livestreams l ON l.id = h.livestream_id
+	GROUP BY l.user_id`)
+	if err != nil {
+		return err
+	}
+
+	// お気に入り絵文字は、回数が同じなら名前の大きい方を選ぶ
+	var emojiCounts []struct {
+		UserID    int64  `db:"user_id"`
+		EmojiName string `db:"emoji_name"`
+		Count     int64  `db:"n"`
+	}
+	if err := db.SelectContext(ctx, &emojiCounts, `
+	SELECT l.user_id, r.emoji_name, COUNT(*) AS n
+	FROM reactions r
+	INNER JOIN livestreams l ON l.id = r.livestream_id
+	GROUP BY l.user_id, r.emoji_name`); err != nil {
+		return err
+	}
+	type favorite struct {
+		name  string
+		count int64
+	}
+	favorites := make(map[int64]favorite, len(users))
+	for _, ec := range emojiCounts {
+		best, ok := favorites[ec.UserID]
+		if !ok || ec.Count > best.count || (ec.Count == best.count && ec.EmojiName > best.name) {
+			favorites[ec.UserID] = favorite{name: ec.EmojiName, count: ec.Count}
+		}
+	}
+
+	rows := make([][]interface{}, 0, len(users))
+	for _, user := range users {
+		rows = append(rows, []interface{}{
+			user.ID, ranks[user.Name], viewers[user.ID], user.ReactionCount, user.CommentCount, user.TotalTip, favorites[user.ID].name, computedAt,
+		})
+	}
+	columns := []string{"user_id", "rank", "viewers_count", "total_reactions", "total_livecomments", "total_tip", "favorite_emoji", "computed_at"}
+	if err := upsertStatistics(ctx, db, "user_statistics", columns, rows); err != nil {
+		return err
+	}
+	// 削除されたユーザの集計結果を消す
+	_, err = db.ExecContext(ctx, "DELETE FROM user_statistics WHERE computed_at < ?", computedAt)
+	return err
+}
+
+// aggregateLivestreamStatistics は、すべてのライブ配信の統計情報をcomputeLivestreamStatisticsと同じ規則で集計します
+func aggregateLivestreamStatistics(ctx context.Context, db *sqlx.DB) error {
+	computedAt := time.Now().UnixMilli()
+
+	var livestreams []*LivestreamModel
+	if err := db.SelectContext(ctx, &livestreams, "SELECT * FROM livestreams"); err != nil {
+		return err
+	}
+	if err := recountLivestreamScores(ctx, db, livestreams); err != nil {
+		return err
+	}
+	ranking := make(LivestreamRanking, 0, len(livestreams))
+	for _, livestream := range livestreams {
+		ranking = append(ranking, LivestreamRankingEntry{LivestreamID: livestream.ID, Score: livestream.ReactionCount + livestream.TotalTip})
+	}
+	sort.Sort(ranking)
+	ranks := make(map[int64]int64, len(ranking))
+	for i, entry := range ranking {
+		ranks[entry.LivestreamID] = int64(len(ranking) - i)
+	}
+
+	viewers, err := selectCountsByID(ctx, db, "SELECT livestream_id AS id, COUNT(*) AS n FROM livestream_viewers_history GROUP BY livestream_id")
+	if err != nil {
+		return err
+	}
+	maxTips, err := selectCountsByID(ctx, db, "SELECT livestream_id AS id, MAX(tip) AS n FROM livecomments WHERE deleted_at IS NULL GROUP BY livestream_id")
+	if err != nil {
+		return err
+	}
+	reports, err := selectCountsByID(ctx, db, "SELECT livestream_id AS id, COUNT(*) AS n FROM livecomment_reports GROUP BY livestream_id")
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]interface{}, 0, len(livestreams))
+	for _, livestream := range livestreams {
+		rows = append(rows, []interface{}{
+			livestream.ID, ranks[livestream.ID], viewers[livestream.ID], livestream.ReactionCount, reports[livestream.ID], maxTips[livestream.ID], computedAt,
+		})
+	}
+	columns := []string{"livestream_id", "rank", "viewers_count", "total_reactions", "total_reports", "max_tip", "computed_at"}
+	if err := upsertStatistics(ctx, db, "livestream_statistics", columns, rows); err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, "DELETE FROM livestream_statistics WHERE computed_at < ?", computedAt)
+	return err
+}
+
+// selectCountsByID は、id, nの2列を返すqueryの結果をid => nにします
+func selectCountsByID(ctx context.Context, db *sqlx.DB, query string) (map[int64]int64, error) {
+	var rows []struct {
+		ID int64 `db:"id"`
+		N  int64 `db:"n"`
+	}
+	if err := db.SelectContext(ctx, &rows, query); err != nil {
+		return nil, err
+	}
+	counts := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ID] = row.N
+	}
+	return counts, nil
+}
+
+// upsertStatistics は、rowsをstatisticsUpsertBatchSize行ずつtableに書き込みます
+// 最初の列を主キーとし、既にある行は他の列を上書きします
+func upsertStatistics(ctx context.Context, db *sqlx.DB, table string, columns []string, rows [][]interface{}) error {
+	quoted := make([]string, len(columns))
+	updates := make([]string, 0, len(columns)-1)
+	for i, column := range columns {
+		// rankは予約語なので、列名はすべてバッククォートで囲む
+		quoted[i] = "`" + column + "`"
+		if i > 0 {
+			updates = append(updates, quoted[i]+" = VALUES("+quoted[i]+")")
+		}
+	}
+	placeholder := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+
+	for start := 0; start < len(rows); start += statisticsUpsertBatchSize {
+		end := min(start+statisticsUpsertBatchSize, len(rows))
+		batch := rows[start:end]
+		placeholders := make([]string, len(batch))
+		args := make([]interface{}, 0, len(batch)*len(columns))
+		for i, row := range batch {
+			placeholders[i] = placeholder
+			args = append(args, row...)
+		}
+		query := "INSERT INTO " + table + " (" + strings.Join(quoted, ", ") + ") VALUES " + strings.Join(placeholders, ", ") +
+			" ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+		if _, err := db.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 何度も発行されるクエリ
+// 起動時にプリペアしておき、パースし直さずに使い回す
+const (
+	queryLivestreamByID    = "SELECT * FROM livestreams WHERE id = ?"
+	queryInsertLivecomment = "INSERT INTO livecomments (user_id, livestream_id, comment, tip, created_at, spam_score, hidden_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+)
+
+var (
+	queryUserByID   = excludeDeleted("SELECT * FROM users WHERE id = ?", "")
+	queryUserByName = excludeDeleted("SELECT * FROM users WHERE name = ?", "")
+)
+
+var hotQueries = []string{
+	queryLivestreamByID,
+	queryUserByID,
+	queryUserByName,
+	queryInsertLivecomment,
+}
+
+// preparedStatements は、DB接続ごとにプリペアしたホットパスのステートメントです
+// 起動時に作った後は読み取りのみなので、ロックは取りません
+// database/sqlが接続ごとのプリペアを管理するので、接続が作り直されても使い続けられます
+var preparedStatements = map[*sqlx.DB]map[string]*sqlx.Stmt{}
+
+// prepareHotStatements は、dbについてホットパスのクエリをプリペアします
+// リクエストを受け付ける前に呼び出してください
+func prepareHotStatements(ctx context.Context, db *sqlx.DB) error {
+	stmts := make(map[string]*sqlx.Stmt, len(hotQueries))
+	for _, query := range hotQueries {
+		stmt, err := db.PreparexContext(ctx, query)
+		if err != nil {
+			for _, stmt := range stmts {
+				stmt.Close()
+			}
+			return fmt.Errorf("failed to prepare %q: %w", query, err)
+		}
+		stmts[query] = stmt
+	}
+	preparedStatements[db] = stmts
+	return nil
+}
+
+func closePreparedStatements() {
+	for _, stmts := range preparedStatements {
+		for _, stmt := range stmts {
+			stmt.Close()
+		}
+	}
+}
+
+// preparedStatement は、qで使えるプリペア済みのステートメントを返します
+// トランザクションはdbConnから始めるので、dbConnのステートメントをトランザクションに結びつけて返します
+func preparedStatement(ctx context.Context, q interface{}, query string) (*sqlx.Stmt, bool) {
+	switch q := q.(type) {
+	case *sqlx.DB:
+		stmt, ok := preparedStatements[q][query]
+		return stmt, ok
+	case *sqlx.Tx:
+		stmt, ok := preparedStatements[dbConn][query]
+		if !ok {
+			return nil, false
+		}
+		// トランザクションの終了とともに閉じられる
+		return q.StmtxContext(ctx, stmt), true
+	}
+	return nil, false
+}
+
+// preparedGet は、sqlx.GetContextと同じですが、プリペア済みのステートメントがあればそれを使います
+func preparedGet(ctx context.Context, q sqlx.QueryerContext, dest interface{}, query string, args ...interface{}) error {
+	if stmt, ok := preparedStatement(ctx, q, query); ok {
+		return stmt.GetContext(ctx, dest, args...)
+	}
+	return sqlx.GetContext(ctx, q, dest, query, args...)
+}
+
+// preparedExec は、ExecContextと同じですが、プリペア済みのステートメントがあればそれを使います
+func preparedExec(ctx context.Context, e sqlx.ExecerContext, query string, args ...interface{}) (sql.Result, error) {
+	if stmt, ok := preparedStatement(ctx, e, query); ok {
+		return stmt.ExecContext(ctx, args...)
+	}
+	return e.ExecContext(ctx, query, args...)
+}
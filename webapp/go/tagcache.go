@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// tagCache は、タグのマスタをメモリ上に保持します
+// タグは管理者が追加するときにしか変わらないため、起動時・初期化時・タグ追加時に読み込み直します
+// 配信数 (livestream_count) は、タグの付け替えがコミットされた後にaddLivestreamCountsで反映します
+type tagCache struct {
+	mu     sync.RWMutex
+	byID   map[int64]*TagModel
+	byName map[string]*TagModel
+}
+
+var tagCacheStats = newCacheStats("tags")
+
+var tagMaster = &tagCache{
+	byID:   map[int64]*TagModel{},
+	byName: map[string]*TagModel{},
+}
+
+// InvalidateTagCache は、タグのマスタをDBから読み込み直します
+// 他のプロセスでタグが追加された場合など、キャッシュが古くなったときに呼び出してください
+func InvalidateTagCache(ctx context.Context) error {
+	return tagMaster.load(ctx, dbConn)
+}
+
+func (t *tagCache) load(ctx context.Context, db *sqlx.DB) error {
+	var tagModels []*TagModel
+	if err := db.SelectContext(ctx, &tagModels, "SELECT * FROM tags"); err != nil {
+		return err
+	}
+
+	byID := make(map[int64]*TagModel, len(tagModels))
+	byName := make(map[string]*TagModel, len(tagModels))
+	for _, tagModel := range tagModels {
+		byID[tagModel.ID] = tagModel
+		byName[tagModel.Name] = tagModel
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.byID = byID
+	t.byName = byName
+	return nil
+}
+
+func (t *tagCache) store(tagModel TagModel) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stored := tagModel
+	t.byID[stored.ID] = &stored
+	t.byName[stored.Name] = &stored
+}
+
+// list は、すべてのタグをID順に返します
+func (t *tagCache) list() []*TagWithCount {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	tags := make([]*TagWithCount, 0, len(t.byID))
+	for _, tagModel := range t.byID {
+		tags = append(tags, &TagWithCount{
+			Tag: Tag{
+				ID:   tagModel.ID,
+				Name: tagModel.Name,
+			},
+			LivestreamCount: tagModel.LivestreamCount,
+		})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].ID < tags[j].ID
+	})
+	return tags
+}
+
+// getByID は、IDに対応するタグを返します
+// キャッシュになければDBを引き、存在しなければsql.ErrNoRowsを返します
+func (t *tagCache) getByID(ctx context.Context, q sqlx.QueryerContext, id int64) (Tag, error) {
+	t.mu.RLock()
+	tagModel, ok := t.byID[id]
+	t.mu.RUnlock()
+	tagCacheStats.record(ok)
+	if ok {
+		return Tag{ID: tagModel.ID, Name: tagModel.Name}, nil
+	}
+
+	var found TagModel
+	if err := sqlx.GetContext(ctx, q, &found, "SELECT * FROM tags WHERE id = ?", id); err != nil {
+		return Tag{}, err
+	}
+	t.store(found)
+	return Tag{ID: found.ID, Name: found.Name}, nil
+}
+
+// getByName は、名前に対応するタグを返します
+// キャッシュになければDBを引き、存在しなければfalseを返します
+func (t *tagCache) getByName(ctx context.Context, q sqlx.QueryerContext, name string) (Tag, bool, error) {
+	t.mu.RLock()
+	tagModel, ok := t.byName[name]
+	t.mu.RUnlock()
+	tagCacheStats.record(ok)
+	if ok {
+		return Tag{ID: tagModel.ID, Name: tagModel.Name}, true, nil
+	}
+
+	var found TagModel
+	if err := sqlx.GetContext(ctx, q, &found, "SELECT * FROM tags WHERE name = ?", name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Tag{}, false, nil
+		}
+		return Tag{}, false, err
+	}
+	t.store(found)
+	return Tag{ID: found.ID, Name: found.Name}, true, nil
+}
+
+// addLivestreamCounts は、タグID => 増減数 の分だけ配信数を増減させます
+func (t *tagCache) addLivestreamCounts(deltas map[int64]int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for tagID, delta := range deltas {
+		if tagModel, ok := t.byID[tagID]; ok {
+			tagModel.LivestreamCount += delta
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/isucon/isucon13/webapp/go/internal/config"
+	"github.com/labstack/echo/v4"
+)
+
+// newTimeoutMiddleware は、ルートごとの時間を過ぎたリクエストを打ち切り、503を返すミドルウェアを返します
+// リクエストのcontextを打ち切るので、実行中のクエリも取り消され、遅い集計がワーカーとDBの接続を占有し続けません
+// 打ち切るルートがない設定のときはnilを返します
+func newTimeoutMiddleware(c config.Timeouts) echo.MiddlewareFunc {
+	enabled := c.Default > 0
+	for _, timeout := range c.Routes {
+		if timeout > 0 {
+			enabled = true
+		}
+	}
+	if !enabled {
+		return nil
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			timeout := routeTimeout(c, ctx.Path())
+			if timeout <= 0 {
+				return next(ctx)
+			}
+
+			reqCtx, cancel := context.WithTimeout(ctx.Request().Context(), timeout)
+			defer cancel()
+			ctx.SetRequest(ctx.Request().WithContext(reqCtx))
+
+			err := next(ctx)
+			// 打ち切られたハンドラはクエリの失敗として500を返すので、503に置き換える
+			// 書き出し始めていれば、そのまま返す
+			if errors.Is(reqCtx.Err(), context.DeadlineExceeded) && !ctx.Response().Committed {
+				ctx.Response().Header().Set(echo.HeaderRetryAfter, "1")
+				return echo.NewHTTPError(http.StatusServiceUnavailable, "request timed out after "+timeout.String())
+			}
+			return err
+		}
+	}
+}
+
+// routeTimeout は、pathのルートのリクエストを打ち切るまでの時間を返します。0以下のときは打ち切りません
+func routeTimeout(c config.Timeouts, path string) time.Duration {
+	if timeout, ok := c.Routes[path]; ok {
+		return timeout
+	}
+	if c.Default <= 0 || (!strings.HasPrefix(path, "/api/") && !strings.HasPrefix(path, "/admin/")) {
+		return 0
+	}
+	// 接続し続けるエンドポイントは、ルートごとに指定しない限り打ち切らない
+	switch path {
+	case "/api/livestream/:livestream_id/ws", "/api/livestream/:livestream_id/events":
+		return 0
+	case "/api/livestream/:livestream_id/livecomment":
+		// waitを指定した取得は、投稿されるまで待つ
+		return max(c.Default, livecommentMaxWait+time.Second)
+	}
+	return c.Default
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"log/slog"
 	"net/http"
 
+	"github.com/go-sql-driver/mysql"
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
+	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 )
 
@@ -14,44 +20,94 @@ type Tag struct {
 }
 
 type TagModel struct {
-	ID   int64  `db:"id"`
-	Name string `db:"name"`
+	ID              int64  `db:"id"`
+	Name            string `db:"name"`
+	LivestreamCount int64  `db:"livestream_count"`
+}
+
+type TagWithCount struct {
+	Tag
+	LivestreamCount int64 `json:"livestream_count"`
 }
 
 type TagsResponse struct {
-	Tags []*Tag `json:"tags"`
+	Tags []*TagWithCount `json:"tags"`
+}
+
+type PostTagRequest struct {
+	Name string `json:"name"`
 }
 
 func getTagHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, &TagsResponse{
+		Tags: tagMaster.list(),
+	})
+}
+
+// 管理者によるタグ追加API
+// POST /api/tag
+func postTagHandler(c echo.Context) error {
 	ctx := c.Request().Context()
+	defer c.Request().Body.Close()
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin new transaction: : "+err.Error()+err.Error())
+	if err := verifyAdminSession(c); err != nil {
+		// echo.NewHTTPErrorが返っているのでそのまま出力
+		return err
 	}
-	defer tx.Rollback()
 
-	var tagModels []*TagModel
-	if err := tx.SelectContext(ctx, &tagModels, "SELECT * FROM tags"); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get tags: "+err.Error())
+	var req *PostTagRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return apperror.BadRequest("failed to decode the request body as json")
 	}
-
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+	if req.Name == "" {
+		return apperror.BadRequest("name must not be empty")
 	}
 
-	tags := make([]*Tag, len(tagModels))
-	for i := range tagModels {
-		tags[i] = &Tag{
-			ID:   tagModels[i].ID,
-			Name: tagModels[i].Name,
+	var tagID int64
+	err := withTx(ctx, func(tx *sqlx.Tx) error {
+		rs, err := tx.ExecContext(ctx, "INSERT INTO tags (name) VALUES (?)", req.Name)
+		if err != nil {
+			var mysqlErr *mysql.MySQLError
+			if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+				return apperror.Conflict("the tag already exists")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert tag: "+err.Error())
+		}
+
+		tagID, err = rs.LastInsertId()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get last inserted tag id: "+err.Error())
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-	return c.JSON(http.StatusOK, &TagsResponse{
-		Tags: tags,
+	if err := InvalidateTagCache(ctx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to reload tags: "+err.Error())
+	}
+	tagsResponseCache.invalidate()
+	peers.broadcast(peerMessage{Kind: peerMessageTags})
+
+	return c.JSON(http.StatusCreated, &TagWithCount{
+		Tag: Tag{
+			ID:   tagID,
+			Name: req.Name,
+		},
 	})
 }
 
+// addTagLivestreamCount は、タグが付けられているライブ配信の数をdeltaだけ増減させます
+// livestream_tagsの更新と同じトランザクションで呼び出してください
+func addTagLivestreamCount(ctx context.Context, tx *sqlx.Tx, tagID int64, delta int64) error {
+	_, err := tx.ExecContext(ctx, "UPDATE tags SET livestream_count = livestream_count + ? WHERE id = ?", delta, tagID)
+	return err
+}
+
+func themePath(username string) string {
+	return "/api/user/" + username + "/theme"
+}
+
 // 配信者のテーマ取得API
 // GET /api/user/:username/theme
 func getStreamerThemeHandler(c echo.Context) error {
@@ -59,40 +115,22 @@ func getStreamerThemeHandler(c echo.Context) error {
 
 	if err := verifyUserSession(c); err != nil {
 		// echo.NewHTTPErrorが返っているのでそのまま出力
-		c.Logger().Printf("verifyUserSession: %+v\n", err)
+		requestLogger(c).Debug("verifyUserSession failed", slog.Any("error", err))
 		return err
 	}
 
 	username := c.Param("username")
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
-	}
-	defer tx.Rollback()
+	db := readDB(c)
 
-	userModel := UserModel{}
-	err = tx.GetContext(ctx, &userModel, "SELECT id FROM users WHERE name = ?", username)
+	// テーマはユーザと一緒にキャッシュしている
+	user, err := userProfiles.getByName(ctx, db, username)
 	if errors.Is(err, sql.ErrNoRows) {
-		return echo.NewHTTPError(http.StatusNotFound, "not found user that has the given username")
+		return apperror.NotFound("not found user that has the given username")
 	}
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user: "+err.Error())
 	}
 
-	themeModel := ThemeModel{}
-	if err := tx.GetContext(ctx, &themeModel, "SELECT * FROM themes WHERE user_id = ?", userModel.ID); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user theme: "+err.Error())
-	}
-
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
-	}
-
-	theme := Theme{
-		ID:       themeModel.ID,
-		DarkMode: themeModel.DarkMode,
-	}
-
-	return c.JSON(http.StatusOK, theme)
+	return c.JSON(http.StatusOK, user.Theme)
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/isucon/isucon13/webapp/go/internal/config"
+	"github.com/isucon/isucon13/webapp/go/internal/querystats"
+	"github.com/isucon/isucon13/webapp/go/internal/tracing"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// tracer は、リクエストとSQLのスパンを記録するトレーサです
+// トレースを無効にしているときはnilで、何も記録しません
+var tracer *tracing.Tracer
+
+// newTracer は、設定に応じてトレーサとエクスポータを作ります
+// 送信先 (例: http://localhost:4318) が指定されていなければ、どちらもnilを返します
+func newTracer(c config.Tracing) (*tracing.Tracer, *tracing.Exporter) {
+	if c.OTLPEndpoint == "" {
+		return nil, nil
+	}
+	exporter := tracing.NewExporter(c.OTLPEndpoint, c.ServiceName)
+	return tracing.NewTracer(exporter, c.SampleRatio), exporter
+}
+
+// openDB は、confのDBを開きます
+// トレースが有効なときは、クエリごとにスパンを記録します
+// クエリの集計が有効なときは、実行時間をqueryStatsに記録します
+func openDB(conf *mysql.Config) (*sqlx.DB, error) {
+	connector, err := mysql.NewConnector(conf)
+	if err != nil {
+		return nil, err
+	}
+	recorded := querystats.WrapConnector(connector, queryStats)
+	return sqlx.NewDb(sql.OpenDB(tracing.WrapConnector(recorded, tracer, "mysql")), "mysql"), nil
+}
+
+// tracingMiddleware は、リクエストごとにスパンを記録します
+// 前段からtraceparentヘッダが渡されていれば、そのトレースの続きとして記録します
+func tracingMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if tracer == nil {
+			return next(c)
+		}
+
+		req := c.Request()
+		ctx := tracing.ContextWithTraceParent(req.Context(), req.Header.Get("traceparent"))
+		ctx, span := tracer.Start(ctx, req.Method+" "+c.Path(), tracing.SpanKindServer,
+			tracing.String("http.method", req.Method),
+			tracing.String("http.route", c.Path()),
+			tracing.String("http.target", req.RequestURI),
+		)
+		defer span.End()
+		c.SetRequest(req.WithContext(ctx))
+
+		if err := next(c); err != nil {
+			c.Error(err)
+		}
+		status := c.Response().Status
+		span.SetAttributes(tracing.Int("http.status_code", status))
+		if status >= 500 {
+			span.RecordError(fmt.Errorf("%d %s", status, http.StatusText(status)))
+		}
+		return nil
+	}
+}
+
+// shutdownTracer は、送信待ちのスパンを送信します
+func shutdownTracer(ctx context.Context, exporter *tracing.Exporter) error {
+	if exporter == nil {
+		return nil
+	}
+	return exporter.Shutdown(ctx)
+}
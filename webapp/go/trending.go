@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// 直近何分間の反応をトレンドの計算に使うか
+	trendingWindowMinutes = 10
+	// 1分経過するごとに、その分の反応の重みを何倍にするか
+	trendingDecayPerMinute = 0.8
+
+	trendingReactionScore    = 1.0
+	trendingLivecommentScore = 1.0
+	// チップはこの額ごとにリアクション1回分として扱う
+	trendingTipUnit = 100.0
+)
+
+type trendingBucket struct {
+	// UNIX時間を分単位にしたもの
+	minute int64
+	// livestream_id => その分のスコア
+	scores map[int64]float64
+}
+
+// trendingTracker は、ライブ配信ごとの直近の盛り上がりを1分単位のリングバッファで保持します
+// 古いバケットは書き込み時に再利用されるため、保持するデータ量は直近に反応があった配信の数に比例します
+type trendingTracker struct {
+	mu      sync.Mutex
+	buckets [trendingWindowMinutes]trendingBucket
+}
+
+var trending = &trendingTracker{}
+
+// reset は、保持しているスコアをすべて破棄します
+func (t *trendingTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buckets = [trendingWindowMinutes]trendingBucket{}
+}
+
+func (t *trendingTracker) add(livestreamID int64, now time.Time, score float64) {
+	minute := now.Unix() / 60
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	bucket := &t.buckets[minute%trendingWindowMinutes]
+	if bucket.minute != minute || bucket.scores == nil {
+		bucket.minute = minute
+		bucket.scores = make(map[int64]float64)
+	}
+	bucket.scores[livestreamID] += score
+}
+
+func (t *trendingTracker) addReaction(livestreamID int64, now time.Time) {
+	t.add(livestreamID, now, trendingReactionScore)
+}
+
+func (t *trendingTracker) addLivecomment(livestreamID int64, tip int64, now time.Time) {
+	t.add(livestreamID, now, trendingLivecommentScore+float64(tip)/trendingTipUnit)
+}
+
+// scores は、直近trendingWindowMinutes分の反応を経過時間で減衰させて合算したスコアを返します
+func (t *trendingTracker) scores(now time.Time) map[int64]float64 {
+	minute := now.Unix() / 60
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	scores := make(map[int64]float64)
+	for _, bucket := range t.buckets {
+		age := minute - bucket.minute
+		if age < 0 || age >= trendingWindowMinutes {
+			continue
+		}
+		weight := math.Pow(trendingDecayPerMinute, float64(age))
+		for livestreamID, score := range bucket.scores {
+			scores[livestreamID] += score * weight
+		}
+	}
+	return scores
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// デッドロックやロック待ちタイムアウトで失敗したトランザクションを試行する最大回数
+	maxTxAttempts = 3
+
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+)
+
+// withTx は、トランザクション内でfnを実行し、fnが成功すればコミットします
+// fnがエラーを返した場合は必ずロールバックし、デッドロックやロック待ちタイムアウトであればfnを最初からやり直します
+// やり直しに備え、fnの中ではレスポンスの書き込みやメモリ上の状態の更新を行わないでください
+func withTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	var err error
+	for attempt := 0; attempt < maxTxAttempts; attempt++ {
+		err = runTx(ctx, fn)
+		if err == nil || !isRetryableTxError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func runTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := dbConn.BeginTxx(ctx, nil)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+	}
+	return nil
+}
+
+// isRetryableTxError は、errがトランザクションのやり直しで解消しうるエラーかを返します
+func isRetryableTxError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrDeadlock || mysqlErr.Number == mysqlErrLockWaitTimeout
+	}
+
+	// ハンドラはドライバのエラーをメッセージに埋め込んだHTTPエラーを返すため、メッセージからも判定する
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		msg, ok := he.Message.(string)
+		if !ok {
+			return false
+		}
+		for _, number := range []int{mysqlErrDeadlock, mysqlErrLockWaitTimeout} {
+			if strings.Contains(msg, "Error "+strconv.Itoa(number)+" (") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -8,12 +8,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
-	"os/exec"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
 	"github.com/gorilla/sessions"
+	"github.com/isucon/isucon13/webapp/go/internal/apperror"
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
@@ -36,6 +36,12 @@ type UserModel struct {
 	DisplayName    string `db:"display_name"`
 	Description    string `db:"description"`
 	HashedPassword string `db:"password"`
+	// 配信者として持つ全配信についてのスコアの集計
+	TotalTip      int64 `db:"total_tip"`
+	ReactionCount int64 `db:"reaction_count"`
+	CommentCount  int64 `db:"comment_count"`
+	// アカウントを削除した日時
+	DeletedAt sql.NullInt64 `db:"deleted_at"`
 }
 
 type User struct {
@@ -90,30 +96,39 @@ func getIconHandler(c echo.Context) error {
 
 	username := c.Param("username")
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
-	}
-	defer tx.Rollback()
+	db := readDB(c)
 
-	var user UserModel
-	if err := tx.GetContext(ctx, &user, "SELECT * FROM users WHERE name = ?", username); err != nil {
+	user, err := userProfiles.getByName(ctx, db, username)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return echo.NewHTTPError(http.StatusNotFound, "not found user that has the given username")
+			return apperror.NotFound("not found user that has the given username")
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user: "+err.Error())
 	}
 
-	var image []byte
-	if err := tx.GetContext(ctx, &image, "SELECT image FROM icons WHERE user_id = ?", user.ID); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return c.File(fallbackImage)
-		} else {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user icon: "+err.Error())
-		}
+	// ユーザに埋め込んだicon_hashをETagとし、手元の画像と同じであれば画像を読まずに返す
+	etag := `"` + user.IconHash + `"`
+	c.Response().Header().Set("ETag", etag)
+	if ifNoneMatch(c.Request().Header.Get("If-None-Match"), etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	// 最近返した画像であれば、DBもファイルも読まずに返す
+	if image, ok := iconCache.get(user.IconHash); ok {
+		return c.Blob(http.StatusOK, "image/jpeg", image)
 	}
 
-	return c.Blob(http.StatusOK, "image/jpeg", image)
+	fetched, err := fetchIcon(ctx, db, user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user icon: "+err.Error())
+	}
+	if !fetched.found {
+		return c.File(fallbackImage)
+	}
+	if fetched.image != nil {
+		return c.Blob(http.StatusOK, "image/jpeg", fetched.image)
+	}
+	return icons.serve(c, fetched.icon)
 }
 
 func postIconHandler(c echo.Context) error {
@@ -131,32 +146,42 @@ func postIconHandler(c echo.Context) error {
 
 	var req *PostIconRequest
 	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "failed to decode the request body as json")
+		return apperror.BadRequest("failed to decode the request body as json")
 	}
-
-	tx, err := dbConn.BeginTxx(ctx, nil)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
+	if err := validateIconImage(req.Image); err != nil {
+		return err
 	}
-	defer tx.Rollback()
 
-	if _, err := tx.ExecContext(ctx, "DELETE FROM icons WHERE user_id = ?", userID); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete old user icon: "+err.Error())
+	iconHash := fmt.Sprintf("%x", sha256.Sum256(req.Image))
+	if err := icons.put(iconHash, req.Image); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to store user icon: "+err.Error())
 	}
 
-	rs, err := tx.ExecContext(ctx, "INSERT INTO icons (user_id, image) VALUES (?, ?)", userID, req.Image)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert new user icon: "+err.Error())
-	}
+	var iconID int64
+	err := withTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM icons WHERE user_id = ?", userID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete old user icon: "+err.Error())
+		}
+
+		rs, err := tx.ExecContext(ctx, "INSERT INTO icons (user_id, image, hash) VALUES (?, ?, ?)", userID, icons.column(req.Image), iconHash)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert new user icon: "+err.Error())
+		}
 
-	iconID, err := rs.LastInsertId()
+		iconID, err = rs.LastInsertId()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get last inserted icon id: "+err.Error())
+		}
+		return nil
+	})
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get last inserted icon id: "+err.Error())
+		return err
 	}
-
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+	if err := userProfiles.updateIcon(ctx, userID, iconHash); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update icon cache: "+err.Error())
 	}
+	// sharedCacheをRedisで共有していなければ、他のアプリケーションサーバは古いハッシュを返し続けるので伝える
+	peers.broadcast(peerMessage{Kind: peerMessageIcon, UserID: userID, IconHash: iconHash})
 
 	return c.JSON(http.StatusCreated, &PostIconResponse{
 		ID: iconID,
@@ -176,31 +201,100 @@ func getMeHandler(c echo.Context) error {
 	// existence already checked
 	userID := sess.Values[defaultUserIDKey].(int64)
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
-	}
-	defer tx.Rollback()
+	db := readDB(c)
 
-	userModel := UserModel{}
-	err = tx.GetContext(ctx, &userModel, "SELECT * FROM users WHERE id = ?", userID)
+	user, err := userProfiles.getByID(ctx, db, userID)
 	if errors.Is(err, sql.ErrNoRows) {
-		return echo.NewHTTPError(http.StatusNotFound, "not found user that has the userid in session")
+		return apperror.NotFound("not found user that has the userid in session")
 	}
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user: "+err.Error())
 	}
 
-	user, err := fillUserResponse(ctx, tx, userModel)
+	return c.JSON(http.StatusOK, user)
+}
+
+// アカウント削除API
+// DELETE /api/user/me
+// NOTE: ライブ配信やライブコメント、リアクションは他のユーザからも参照されるため、それらを持つユーザは削除できません
+func deleteMeHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if err := verifyUserSession(c); err != nil {
+		// echo.NewHTTPErrorが返っているのでそのまま出力
+		return err
+	}
+
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	// existence already checked
+	userID := sess.Values[defaultUserIDKey].(int64)
+
+	userModel := UserModel{}
+	err := withTx(ctx, func(tx *sqlx.Tx) error {
+		err := tx.GetContext(ctx, &userModel, excludeDeleted("SELECT * FROM users WHERE id = ? FOR UPDATE", ""), userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperror.NotFound("not found user that has the userid in session")
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user: "+err.Error())
+		}
+
+		var referenced bool
+		if err := tx.GetContext(ctx, &referenced, `SELECT EXISTS(SELECT 1 FROM livestreams WHERE user_id = ?)
+			OR EXISTS(SELECT 1 FROM livecomments WHERE user_id = ?)
+			OR EXISTS(SELECT 1 FROM reactions WHERE user_id = ?)`, userID, userID, userID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to check user activities: "+err.Error())
+		}
+		if referenced {
+			return apperror.Conflict("cannot delete a user who has livestreams, livecomments or reactions")
+		}
+
+		for _, query := range []string{
+			"DELETE FROM themes WHERE user_id = ?",
+			"DELETE FROM icons WHERE user_id = ?",
+			"DELETE FROM livecomment_reports WHERE user_id = ?",
+			"DELETE FROM livestream_viewers_history WHERE user_id = ?",
+			"DELETE FROM watch_history WHERE user_id = ?",
+			"DELETE FROM livestream_collaborators WHERE user_id = ?",
+			"DELETE FROM notifications WHERE user_id = ?",
+		} {
+			if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete user: "+err.Error())
+			}
+		}
+		// ユーザの行は論理削除とし、保持期間を過ぎてから物理削除する
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET deleted_at = ? WHERE id = ?", time.Now().Unix(), userID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete user: "+err.Error())
+		}
+
+		// DNSのレコードは巻き戻せないため、失敗しうるDBの操作をすべて終えてから削除する
+		if err := events.publishInTx(ctx, event{Kind: eventUserDeleted, UserID: userModel.ID, Username: userModel.Name, Tx: tx}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete subdomain record: "+err.Error())
+		}
+		return nil
+	})
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill user: "+err.Error())
+		return err
 	}
+	if err := userProfiles.delete(ctx, userModel.ID, userModel.Name); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete user cache: "+err.Error())
+	}
+	// 同じ名前で登録し直されても古いユーザを返さないよう、他のアプリケーションサーバのキャッシュからも消す
+	themeResponseCache.invalidatePath(themePath(userModel.Name))
+	peers.broadcast(peerMessage{Kind: peerMessageUser, UserID: userModel.ID, Username: userModel.Name})
+	events.publish(event{Kind: eventUserDeleted, UserID: userModel.ID, Username: userModel.Name})
 
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+	sess.Options = &sessions.Options{
+		Domain: "u.isucon.dev",
+		MaxAge: -1,
+		Path:   "/",
+	}
+	if err := sess.Save(c.Request(), c.Response()); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save session: "+err.Error())
 	}
 
-	return c.JSON(http.StatusOK, user)
+	return c.NoContent(http.StatusNoContent)
 }
 
 // ユーザ登録API
@@ -211,11 +305,11 @@ func registerHandler(c echo.Context) error {
 
 	req := PostUserRequest{}
 	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "failed to decode the request body as json")
+		return apperror.BadRequest("failed to decode the request body as json")
 	}
 
 	if req.Name == "pipe" {
-		return echo.NewHTTPError(http.StatusBadRequest, "the username 'pipe' is reserved")
+		return apperror.BadRequest("the username 'pipe' is reserved")
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcryptDefaultCost)
@@ -223,51 +317,55 @@ func registerHandler(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate hashed password: "+err.Error())
 	}
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
-	}
-	defer tx.Rollback()
-
-	userModel := UserModel{
-		Name:           req.Name,
-		DisplayName:    req.DisplayName,
-		Description:    req.Description,
-		HashedPassword: string(hashedPassword),
-	}
+	var user User
+	err = withTx(ctx, func(tx *sqlx.Tx) error {
+		userModel := UserModel{
+			Name:           req.Name,
+			DisplayName:    req.DisplayName,
+			Description:    req.Description,
+			HashedPassword: string(hashedPassword),
+		}
 
-	result, err := tx.NamedExecContext(ctx, "INSERT INTO users (name, display_name, description, password) VALUES(:name, :display_name, :description, :password)", userModel)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert user: "+err.Error())
-	}
+		result, err := tx.NamedExecContext(ctx, "INSERT INTO users (name, display_name, description, password) VALUES(:name, :display_name, :description, :password)", userModel)
+		if err != nil {
+			var mysqlErr *mysql.MySQLError
+			if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+				return apperror.Conflict("the username is already taken")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert user: "+err.Error())
+		}
 
-	userID, err := result.LastInsertId()
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get last inserted user id: "+err.Error())
-	}
+		userID, err := result.LastInsertId()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get last inserted user id: "+err.Error())
+		}
 
-	userModel.ID = userID
+		userModel.ID = userID
 
-	themeModel := ThemeModel{
-		UserID:   userID,
-		DarkMode: req.Theme.DarkMode,
-	}
-	if _, err := tx.NamedExecContext(ctx, "INSERT INTO themes (user_id, dark_mode) VALUES(:user_id, :dark_mode)", themeModel); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert user theme: "+err.Error())
-	}
+		themeModel := ThemeModel{
+			UserID:   userID,
+			DarkMode: req.Theme.DarkMode,
+		}
+		if _, err := tx.NamedExecContext(ctx, "INSERT INTO themes (user_id, dark_mode) VALUES(:user_id, :dark_mode)", themeModel); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert user theme: "+err.Error())
+		}
 
-	if out, err := exec.Command("pdnsutil", "add-record", "u.isucon.dev", req.Name, "A", "0", powerDNSSubdomainAddress).CombinedOutput(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, string(out)+": "+err.Error())
-	}
+		user, err = fillUserResponse(ctx, tx, userModel)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill user: "+err.Error())
+		}
 
-	user, err := fillUserResponse(ctx, tx, userModel)
+		// DNSのレコードは巻き戻せないため、失敗しうるDBの操作をすべて終えてから登録する
+		if err := events.publishInTx(ctx, event{Kind: eventUserRegistered, UserID: userID, Username: req.Name, Tx: tx}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to add subdomain record: "+err.Error())
+		}
+		return nil
+	})
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill user: "+err.Error())
-	}
-
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
+		return err
 	}
+	userProfiles.store(ctx, user)
+	events.publish(event{Kind: eventUserRegistered, UserID: user.ID, Username: user.Name})
 
 	return c.JSON(http.StatusCreated, user)
 }
@@ -280,18 +378,13 @@ func loginHandler(c echo.Context) error {
 
 	req := LoginRequest{}
 	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "failed to decode the request body as json")
-	}
-
-	tx, err := dbConn.BeginTxx(ctx, nil)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
+		return apperror.BadRequest("failed to decode the request body as json")
 	}
-	defer tx.Rollback()
 
-	userModel := UserModel{}
+	// 登録直後のログインでも確実に読めるよう、レプリカではなくプライマリから読む
 	// usernameはUNIQUEなので、whereで一意に特定できる
-	err = tx.GetContext(ctx, &userModel, "SELECT * FROM users WHERE name = ?", req.Username)
+	userModel := UserModel{}
+	err := preparedGet(ctx, dbConn, &userModel, queryUserByName, req.Username)
 	if errors.Is(err, sql.ErrNoRows) {
 		return echo.NewHTTPError(http.StatusUnauthorized, "invalid username or password")
 	}
@@ -299,10 +392,6 @@ func loginHandler(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user: "+err.Error())
 	}
 
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
-	}
-
 	err = bcrypt.CompareHashAndPassword([]byte(userModel.HashedPassword), []byte(req.Password))
 	if err == bcrypt.ErrMismatchedHashAndPassword {
 		return echo.NewHTTPError(http.StatusUnauthorized, "invalid username or password")
@@ -348,29 +437,16 @@ func getUserHandler(c echo.Context) error {
 
 	username := c.Param("username")
 
-	tx, err := dbConn.BeginTxx(ctx, nil)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin transaction: "+err.Error())
-	}
-	defer tx.Rollback()
+	db := readDB(c)
 
-	userModel := UserModel{}
-	if err := tx.GetContext(ctx, &userModel, "SELECT * FROM users WHERE name = ?", username); err != nil {
+	user, err := userProfiles.getByName(ctx, db, username)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return echo.NewHTTPError(http.StatusNotFound, "not found user that has the given username")
+			return apperror.NotFound("not found user that has the given username")
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user: "+err.Error())
 	}
 
-	user, err := fillUserResponse(ctx, tx, userModel)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fill user: "+err.Error())
-	}
-
-	if err := tx.Commit(); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit: "+err.Error())
-	}
-
 	return c.JSON(http.StatusOK, user)
 }
 
@@ -382,10 +458,10 @@ func verifyUserSession(c echo.Context) error {
 
 	sessionExpires, ok := sess.Values[defaultSessionExpiresKey]
 	if !ok {
-		return echo.NewHTTPError(http.StatusForbidden, "failed to get EXPIRES value from session")
+		return apperror.Forbidden("failed to get EXPIRES value from session")
 	}
 
-	_, ok = sess.Values[defaultUserIDKey].(int64)
+	userID, ok := sess.Values[defaultUserIDKey].(int64)
 	if !ok {
 		return echo.NewHTTPError(http.StatusUnauthorized, "failed to get USERID value from session")
 	}
@@ -394,29 +470,55 @@ func verifyUserSession(c echo.Context) error {
 	if now.Unix() > sessionExpires.(int64) {
 		return echo.NewHTTPError(http.StatusUnauthorized, "session has expired")
 	}
+	activeSessions.touch(userID, now)
 
 	return nil
 }
 
-func fillUserResponse(ctx context.Context, tx *sqlx.Tx, userModel UserModel) (User, error) {
+// verifyAdminSession は、セッションのユーザが管理者であるかを検証します
+// 管理者はISUCON13_ADMIN_USER_IDSにカンマ区切りで列挙したユーザIDで指定します
+// 削除されたユーザと同じ名前で登録し直したユーザが管理者を引き継がないよう、名前ではなくIDで判定します
+func verifyAdminSession(c echo.Context) error {
+	if err := verifyUserSession(c); err != nil {
+		return err
+	}
+
+	// error already checked
+	sess, _ := session.Get(defaultSessionIDKey, c)
+	userID, ok := sess.Values[defaultUserIDKey].(int64)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "failed to get USERID value from session")
+	}
+
+	if _, ok := adminUserIDs[userID]; !ok {
+		return apperror.Forbidden("admin only")
+	}
+
+	return nil
+}
+
+func fillUserResponse(ctx context.Context, q sqlx.QueryerContext, userModel UserModel) (User, error) {
 	themeModel := ThemeModel{}
-	if err := tx.GetContext(ctx, &themeModel, "SELECT * FROM themes WHERE user_id = ?", userModel.ID); err != nil {
+	if err := sqlx.GetContext(ctx, q, &themeModel, "SELECT * FROM themes WHERE user_id = ?", userModel.ID); err != nil {
 		return User{}, err
 	}
 
-	var image []byte
-	if err := tx.GetContext(ctx, &image, "SELECT image FROM icons WHERE user_id = ?", userModel.ID); err != nil {
+	var iconHash string
+	if err := sqlx.GetContext(ctx, q, &iconHash, selectIconHashes+" WHERE user_id = ?", userModel.ID); err != nil {
 		if !errors.Is(err, sql.ErrNoRows) {
 			return User{}, err
 		}
-		image, err = os.ReadFile(fallbackImage)
+		iconHash, err = fallbackIconHash()
 		if err != nil {
 			return User{}, err
 		}
 	}
-	iconHash := sha256.Sum256(image)
 
-	user := User{
+	return newUserResponse(userModel, themeModel, iconHash), nil
+}
+
+func newUserResponse(userModel UserModel, themeModel ThemeModel, iconHash string) User {
+	return User{
 		ID:          userModel.ID,
 		Name:        userModel.Name,
 		DisplayName: userModel.DisplayName,
@@ -425,8 +527,6 @@ func fillUserResponse(ctx context.Context, tx *sqlx.Tx, userModel UserModel) (Us
 			ID:       themeModel.ID,
 			DarkMode: themeModel.DarkMode,
 		},
-		IconHash: fmt.Sprintf("%x", iconHash),
+		IconHash: iconHash,
 	}
-
-	return user, nil
 }
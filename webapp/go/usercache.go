@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// userCache は、レスポンスに埋め込むユーザ (テーマ・アイコンのハッシュを含む) をメモリ上に保持します
+// ライブコメントやリアクション、配信の一覧ではユーザを何度も引くため、ID・名前のどちらからでも引けるようにします
+// ユーザの情報を変更する処理では、コミット後に必ずこのキャッシュも更新してください
+// 他のアプリケーションサーバからも変更されるアイコンのハッシュは、sharedCacheに置いて共有します
+type userCache struct {
+	byID   sync.Map // int64 => User
+	byName sync.Map // string => int64
+}
+
+var (
+	userProfiles   = &userCache{}
+	userCacheStats = newCacheStats("users")
+)
+
+func iconHashCacheKey(userID int64) string {
+	return "icon_hash:" + strconv.FormatInt(userID, 10)
+}
+
+// lookup は、キャッシュに載っているユーザを返します
+// アイコンのハッシュがsharedCacheになければ、載っていないものとして扱います
+func (u *userCache) lookup(ctx context.Context, id int64) (User, bool) {
+	v, ok := u.byID.Load(id)
+	if !ok {
+		userCacheStats.record(false)
+		return User{}, false
+	}
+	iconHash, ok, err := sharedCache.Get(ctx, iconHashCacheKey(id))
+	if err != nil || !ok {
+		userCacheStats.record(false)
+		return User{}, false
+	}
+	userCacheStats.record(true)
+	user := v.(User)
+	user.IconHash = string(iconHash)
+	return user, true
+}
+
+// getByID は、IDに対応するユーザを返します
+// キャッシュになければDBを引き、存在しなければsql.ErrNoRowsを返します
+func (u *userCache) getByID(ctx context.Context, q sqlx.QueryerContext, id int64) (User, error) {
+	if user, ok := u.lookup(ctx, id); ok {
+		return user, nil
+	}
+
+	var userModel UserModel
+	if err := preparedGet(ctx, q, &userModel, queryUserByID, id); err != nil {
+		return User{}, err
+	}
+	return u.fill(ctx, q, userModel)
+}
+
+// getByName は、名前に対応するユーザを返します
+// キャッシュになければDBを引き、存在しなければsql.ErrNoRowsを返します
+func (u *userCache) getByName(ctx context.Context, q sqlx.QueryerContext, name string) (User, error) {
+	if v, ok := u.byName.Load(name); ok {
+		if user, ok := u.lookup(ctx, v.(int64)); ok {
+			return user, nil
+		}
+	}
+
+	var userModel UserModel
+	if err := preparedGet(ctx, q, &userModel, queryUserByName, name); err != nil {
+		return User{}, err
+	}
+	return u.fill(ctx, q, userModel)
+}
+
+// fill は、取得済みのUserModelからレスポンス用のユーザを返します
+// キャッシュになければテーマとアイコンを引いて組み立て、キャッシュに載せます
+func (u *userCache) fill(ctx context.Context, q sqlx.QueryerContext, userModel UserModel) (User, error) {
+	if user, ok := u.lookup(ctx, userModel.ID); ok {
+		return user, nil
+	}
+
+	user, err := fillUserResponse(ctx, q, userModel)
+	if err != nil {
+		return User{}, err
+	}
+	u.store(ctx, user)
+	return user, nil
+}
+
+func (u *userCache) store(ctx context.Context, user User) {
+	u.byID.Store(user.ID, user)
+	u.byName.Store(user.Name, user.ID)
+	// 失敗しても、次に引いたときにDBから読み直すだけなので無視する
+	_ = sharedCache.Set(ctx, iconHashCacheKey(user.ID), []byte(user.IconHash), 0)
+}
+
+// updateIcon は、アイコンの変更をキャッシュに反映します
+func (u *userCache) updateIcon(ctx context.Context, userID int64, iconHash string) error {
+	return sharedCache.Set(ctx, iconHashCacheKey(userID), []byte(iconHash), 0)
+}
+
+func (u *userCache) delete(ctx context.Context, userID int64, name string) error {
+	u.forget(userID, name)
+	return sharedCache.Delete(ctx, iconHashCacheKey(userID))
+}
+
+// forget は、このアプリケーションサーバのメモリ上からのみユーザを消します
+// 他のアプリケーションサーバで削除されたユーザを消すときに使います
+func (u *userCache) forget(userID int64, name string) {
+	u.byID.Delete(userID)
+	u.byName.Delete(name)
+}
+
+func (u *userCache) reset() {
+	u.byID.Range(func(key, _ any) bool {
+		u.byID.Delete(key)
+		return true
+	})
+	u.byName.Range(func(key, _ any) bool {
+		u.byName.Delete(key)
+		return true
+	})
+}
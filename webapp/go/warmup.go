@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/isucon/isucon13/webapp/go/internal/config"
+	"github.com/jmoiron/sqlx"
+)
+
+// loadUsersで一度に引くユーザの数
+const warmUpUsersChunk = 500
+
+// warmUpSettings は、初期化の後に読み込んでおくものの設定です。起動時に設定から決めます
+var warmUpSettings struct {
+	config.Warmup
+	// 開いておくDBの接続の数
+	conns int
+}
+
+// warmUp は、初期化の直後のリクエストが冷えたキャッシュで遅くならないよう、よく使うものを読み込んでおきます
+// タグのマスタとランキングはresetInMemoryStateで読み込み済みなので、
+// DBの接続とプリペア、スコアの高い配信者のユーザ (アイコンのハッシュを含む) とアイコン画像を読み込みます
+// 予算の時間を過ぎたら途中でやめます。読み込みは最適化なので、失敗しても初期化は失敗させません
+func warmUp(ctx context.Context, logger *slog.Logger) {
+	if warmUpSettings.Budget <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, warmUpSettings.Budget)
+	defer cancel()
+
+	start := time.Now()
+	steps := []struct {
+		name string
+		fn   func(ctx context.Context) error
+	}{
+		{"connections", func(ctx context.Context) error { return warmUpConnections(ctx, dbConn, warmUpSettings.conns) }},
+		{"users", warmUpTopUsers},
+	}
+	for _, step := range steps {
+		stepStart := time.Now()
+		if err := step.fn(ctx); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				logger.Warn("warm-up exceeded its budget", slog.String("step", step.name), slog.Duration("budget", warmUpSettings.Budget))
+				return
+			}
+			logger.Warn("warm-up failed", slog.String("step", step.name), slog.Any("error", err))
+			continue
+		}
+		logger.Info("warmed up", slog.String("step", step.name), slog.Duration("elapsed", time.Since(stepStart)))
+	}
+	logger.Info("warm-up finished", slog.Duration("elapsed", time.Since(start)))
+}
+
+// warmUpConnections は、dbの接続をn本開き、それぞれでホットパスの読み取りのクエリを実行しておきます
+// プリペア済みのステートメントは接続ごとに初めて使うときにプリペアし直されるので、その分も済ませておきます
+func warmUpConnections(ctx context.Context, db *sqlx.DB, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	// 同時に取り出して、n本が別々の接続になるようにする
+	// 閉じると接続はプールに戻り、以降のクエリで使い回される
+	conns := make([]*sqlx.Conn, 0, n)
+	var err error
+	for i := 0; i < n; i++ {
+		var conn *sqlx.Conn
+		conn, err = db.Connx(ctx)
+		if err != nil {
+			break
+		}
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for _, query := range hotQueries {
+				if !strings.HasPrefix(query, "SELECT") {
+					continue
+				}
+				// 存在しないIDで引き、結果は捨てる
+				var rows *sqlx.Rows
+				var err error
+				if stmt, ok := preparedStatement(ctx, db, query); ok {
+					rows, err = stmt.QueryxContext(ctx, 0)
+				} else {
+					rows, err = db.QueryxContext(ctx, query, 0)
+				}
+				if err != nil {
+					errs[i] = fmt.Errorf("failed to run %q: %w", query, err)
+					return
+				}
+				rows.Close()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// warmUpTopUsers は、スコアの高い配信者のユーザとアイコン画像を読み込んでおきます
+// 人気の配信者は、配信の一覧やライブコメントで何度も埋め込まれ、アイコンも多くの視聴者から要求されます
+func warmUpTopUsers(ctx context.Context) error {
+	if warmUpSettings.TopUsers <= 0 {
+		return nil
+	}
+	var userIDs []int64
+	query := excludeDeleted("SELECT id FROM users ORDER BY total_tip + reaction_count DESC, id DESC LIMIT ?", "")
+	if err := dbConn.SelectContext(ctx, &userIDs, query, warmUpSettings.TopUsers); err != nil {
+		return fmt.Errorf("failed to get top users: %w", err)
+	}
+	for len(userIDs) > 0 {
+		chunk := userIDs[:min(len(userIDs), warmUpUsersChunk)]
+		userIDs = userIDs[len(chunk):]
+		if _, err := loadUsers(ctx, dbConn, chunk); err != nil {
+			return fmt.Errorf("failed to load users: %w", err)
+		}
+		for _, userID := range chunk {
+			if _, err := fetchIcon(ctx, dbConn, userID); err != nil {
+				return fmt.Errorf("failed to fetch icon: %w", err)
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
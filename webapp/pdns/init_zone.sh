@@ -8,9 +8,11 @@ if test -f /home/isucon/env.sh; then
 fi
 
 ISUCON_SUBDOMAIN_ADDRESS=${ISUCON13_POWERDNS_SUBDOMAIN_ADDRESS:-127.0.0.1}
+# 引数でゾーンファイルを指定できる (datagenで生成した初期データ用)
+ZONE_FILE=${1:-u.isucon.dev.zone}
 
 temp_dir=$(mktemp -d)
 trap 'rm -rf $temp_dir' EXIT
-sed 's/<ISUCON_SUBDOMAIN_ADDRESS>/'$ISUCON_SUBDOMAIN_ADDRESS'/g' u.isucon.dev.zone > ${temp_dir}/u.isucon.dev.zone
+sed 's/<ISUCON_SUBDOMAIN_ADDRESS>/'$ISUCON_SUBDOMAIN_ADDRESS'/g' "$ZONE_FILE" > ${temp_dir}/u.isucon.dev.zone
 pdnsutil load-zone u.isucon.dev ${temp_dir}/u.isucon.dev.zone
 
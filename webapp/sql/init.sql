@@ -5,11 +5,26 @@ TRUNCATE TABLE livestream_viewers_history;
 TRUNCATE TABLE livecomment_reports;
 TRUNCATE TABLE ng_words;
 TRUNCATE TABLE reactions;
+TRUNCATE TABLE reaction_counts;
 TRUNCATE TABLE tags;
 TRUNCATE TABLE livestream_tags;
 TRUNCATE TABLE livecomments;
 TRUNCATE TABLE livestreams;
 TRUNCATE TABLE users;
+TRUNCATE TABLE payment_totals;
+TRUNCATE TABLE livestream_daily_tips;
+TRUNCATE TABLE livestream_pins;
+TRUNCATE TABLE livestream_settings;
+TRUNCATE TABLE livecomment_cooldowns;
+TRUNCATE TABLE livestream_collaborators;
+TRUNCATE TABLE livestream_ingest_keys;
+TRUNCATE TABLE watch_history;
+TRUNCATE TABLE user_statistics;
+TRUNCATE TABLE livestream_statistics;
+TRUNCATE TABLE notifications;
+TRUNCATE TABLE channel_bans;
+TRUNCATE TABLE audit_logs;
+TRUNCATE TABLE reaction_emojis;
 
 ALTER TABLE `themes` auto_increment = 1;
 ALTER TABLE `icons` auto_increment = 1;
@@ -7,14 +7,27 @@ CREATE TABLE `users` (
   `display_name` VARCHAR(255) NOT NULL,
   `password` VARCHAR(255) NOT NULL,
   `description` TEXT NOT NULL,
-  UNIQUE `uniq_user_name` (`name`)
+  -- 配信者として持つ全配信についてのスコアの集計 (livecomments・reactionsの更新に合わせて増減させる)
+  `total_tip` BIGINT NOT NULL DEFAULT 0,
+  `reaction_count` BIGINT NOT NULL DEFAULT 0,
+  `comment_count` BIGINT NOT NULL DEFAULT 0,
+  -- アカウントを削除した日時。削除したユーザの行は保持期間を過ぎるまで残す
+  `deleted_at` BIGINT DEFAULT NULL,
+  -- 削除したユーザと同じ名前で登録し直せるよう、削除していないユーザの間でのみ名前を一意にする
+  -- SELECT * に含まれないよう、INVISIBLEにする
+  `active_name` VARCHAR(255) AS (IF(`deleted_at` IS NULL, `name`, NULL)) VIRTUAL INVISIBLE,
+  UNIQUE `uniq_user_name` (`active_name`),
+  INDEX `idx_name` (`name`)
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
 
 -- プロフィール画像
 CREATE TABLE `icons` (
   `id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
   `user_id` BIGINT NOT NULL,
-  `image` LONGBLOB NOT NULL
+  -- 画像をファイルに保存しているとき (ISUCON13_ICON_STORAGE=disk) は空
+  `image` LONGBLOB NOT NULL,
+  -- 画像のSHA-256 (16進数)。ファイルに保存しているときはファイル名になる
+  `hash` VARCHAR(64) NOT NULL DEFAULT ''
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
 
 -- ユーザごとのカスタムテーマ
@@ -33,7 +46,13 @@ CREATE TABLE `livestreams` (
   `playlist_url` VARCHAR(255) NOT NULL,
   `thumbnail_url` VARCHAR(255) NOT NULL,
   `start_at` BIGINT NOT NULL,
-  `end_at` BIGINT NOT NULL
+  `end_at` BIGINT NOT NULL,
+  -- public, private, unlisted のいずれか
+  `privacy_status` VARCHAR(32) NOT NULL DEFAULT 'public',
+  -- スコアの集計 (livecomments・reactionsの更新に合わせて増減させる)
+  `total_tip` BIGINT NOT NULL DEFAULT 0,
+  `reaction_count` BIGINT NOT NULL DEFAULT 0,
+  `comment_count` BIGINT NOT NULL DEFAULT 0
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
 
 -- ライブ配信予約枠
@@ -48,6 +67,8 @@ CREATE TABLE `reservation_slots` (
 CREATE TABLE `tags` (
   `id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
   `name` VARCHAR(255) NOT NULL,
+  -- このタグが付けられているライブ配信の数 (livestream_tagsの更新に合わせて増減させる)
+  `livestream_count` BIGINT NOT NULL DEFAULT 0,
   UNIQUE `uniq_tag_name` (`name`)
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
 
@@ -55,7 +76,10 @@ CREATE TABLE `tags` (
 CREATE TABLE `livestream_tags` (
   `id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
   `livestream_id` BIGINT NOT NULL,
-  `tag_id` BIGINT NOT NULL
+  `tag_id` BIGINT NOT NULL,
+  -- タグ検索でのJOIN用
+  INDEX `idx_tag_id_livestream_id` (`tag_id`, `livestream_id`),
+  INDEX `idx_livestream_id` (`livestream_id`)
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
 
 -- ライブ配信視聴履歴
@@ -66,6 +90,18 @@ CREATE TABLE `livestream_viewers_history` (
   `created_at` BIGINT NOT NULL
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
 
+-- ユーザごとの視聴履歴
+-- livestream_viewers_historyは退室時に削除されるため、別に記録する
+CREATE TABLE `watch_history` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  `user_id` BIGINT NOT NULL,
+  `livestream_id` BIGINT NOT NULL,
+  `created_at` BIGINT NOT NULL,
+  INDEX `idx_user_id_created_at` (`user_id`, `created_at`),
+  -- 配信の開始を、配信者の配信を視聴したことのあるユーザに通知するため
+  INDEX `idx_livestream_id` (`livestream_id`)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+
 -- ライブ配信に対するライブコメント
 CREATE TABLE `livecomments` (
   `id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
@@ -73,7 +109,13 @@ CREATE TABLE `livecomments` (
   `livestream_id` BIGINT NOT NULL,
   `comment` VARCHAR(255) NOT NULL,
   `tip` BIGINT NOT NULL DEFAULT 0,
-  `created_at` BIGINT NOT NULL
+  `created_at` BIGINT NOT NULL,
+  -- 配信者によって削除されたスーパーチャットは論理削除される
+  `deleted_at` BIGINT DEFAULT NULL,
+  -- 投稿したときに計算したスパムらしさ (0から1)
+  `spam_score` DOUBLE NOT NULL DEFAULT 0,
+  -- スパムらしさが配信の閾値を超えて、自動で非表示にした日時。配信者のモデレーション画面にのみ表示する
+  `hidden_at` BIGINT DEFAULT NULL
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
 
 -- ユーザからのライブコメントのスパム報告
@@ -103,4 +145,148 @@ CREATE TABLE `reactions` (
   -- :innocent:, :tada:, etc...
   `emoji_name` VARCHAR(255) NOT NULL,
   `created_at` BIGINT NOT NULL
-) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
\ No newline at end of file
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+
+-- 配信ごと・絵文字ごとのリアクション数 (reactionsへのINSERTに合わせて増やす)
+CREATE TABLE `reaction_counts` (
+  `livestream_id` BIGINT NOT NULL,
+  `emoji_name` VARCHAR(255) NOT NULL,
+  `count` BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (`livestream_id`, `emoji_name`)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+
+-- サービス全体のチップ合計
+-- GET /api/payment でlivecommentsを全件集計しないよう、投稿・削除時に増減させる
+CREATE TABLE `payment_totals` (
+  `id` BIGINT NOT NULL PRIMARY KEY,
+  `total_tip` BIGINT NOT NULL DEFAULT 0
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+
+-- 配信ごと・日ごとのチップ合計 (配信者向け収益レポート用)
+-- day はUTCでのその日の0時のUNIX時間
+CREATE TABLE `livestream_daily_tips` (
+  `livestream_id` BIGINT NOT NULL,
+  `user_id` BIGINT NOT NULL,
+  `day` BIGINT NOT NULL,
+  `total_tip` BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (`livestream_id`, `day`),
+  INDEX `idx_user_id_day` (`user_id`, `day`)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+
+-- 配信者によってピン留めされたライブコメント
+-- 1配信につき1件までのため、livestream_idを主キーとする
+CREATE TABLE `livestream_pins` (
+  `livestream_id` BIGINT NOT NULL PRIMARY KEY,
+  `livecomment_id` BIGINT NOT NULL,
+  `created_at` BIGINT NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+
+-- 配信ごとの設定
+-- slow_mode_seconds が正の場合、同じユーザは その秒数が経過するまで次のライブコメントを投稿できない
+CREATE TABLE `livestream_settings` (
+  `livestream_id` BIGINT NOT NULL PRIMARY KEY,
+  `slow_mode_seconds` BIGINT NOT NULL DEFAULT 0,
+  -- NGワードを含むスーパーチャットの扱い (reject: 投稿を拒否, mask: 伏せ字にして受け付ける)
+  `superchat_ng_policy` VARCHAR(16) NOT NULL DEFAULT 'reject',
+  -- スパムらしさがこれ以上のライブコメントを自動で非表示にする。NULLなら非表示にしない
+  `spam_hide_threshold` DOUBLE DEFAULT NULL,
+  -- 楽観的排他制御のための版。更新するたびに1増やす
+  `version` BIGINT NOT NULL DEFAULT 0
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+
+-- 低速モード判定用の、ユーザごとの最終投稿時刻
+-- アプリケーションのメモリ上で管理し、定期的に書き出す
+CREATE TABLE `livecomment_cooldowns` (
+  `livestream_id` BIGINT NOT NULL,
+  `user_id` BIGINT NOT NULL,
+  `last_commented_at` BIGINT NOT NULL,
+  PRIMARY KEY (`livestream_id`, `user_id`)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+
+-- ライブ配信の共同配信者 (コラボレーター)
+-- 配信者と同様にライブコメントのモデレーションができる
+CREATE TABLE `livestream_collaborators` (
+  `livestream_id` BIGINT NOT NULL,
+  `user_id` BIGINT NOT NULL,
+  `created_at` BIGINT NOT NULL,
+  PRIMARY KEY (`livestream_id`, `user_id`)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+
+-- 配信ソフトウェアからの映像送信に使う秘密のストリームキー
+-- 配信者本人にのみ返す
+CREATE TABLE `livestream_ingest_keys` (
+  `livestream_id` BIGINT NOT NULL PRIMARY KEY,
+  `ingest_key` VARCHAR(64) NOT NULL,
+  `updated_at` BIGINT NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+-- 統計情報の集計結果
+-- 機能フラグprecomputed_statisticsが有効なとき、アプリケーションが毎秒全件を集計し直して書き込む
+CREATE TABLE `user_statistics` (
+  `user_id` BIGINT NOT NULL PRIMARY KEY,
+  `rank` BIGINT NOT NULL,
+  `viewers_count` BIGINT NOT NULL,
+  `total_reactions` BIGINT NOT NULL,
+  `total_livecomments` BIGINT NOT NULL,
+  `total_tip` BIGINT NOT NULL,
+  `favorite_emoji` VARCHAR(255) NOT NULL,
+  -- 集計した時刻 (UNIXミリ秒)
+  `computed_at` BIGINT NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+
+CREATE TABLE `livestream_statistics` (
+  `livestream_id` BIGINT NOT NULL PRIMARY KEY,
+  `rank` BIGINT NOT NULL,
+  `viewers_count` BIGINT NOT NULL,
+  `total_reactions` BIGINT NOT NULL,
+  `total_reports` BIGINT NOT NULL,
+  `max_tip` BIGINT NOT NULL,
+  `computed_at` BIGINT NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+
+-- ユーザへの通知
+-- 配信の開始 (livestream.started) と、ライブコメントのピン留め (livecomment.pinned)
+CREATE TABLE `notifications` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  `user_id` BIGINT NOT NULL,
+  `kind` VARCHAR(32) NOT NULL,
+  `livestream_id` BIGINT NOT NULL,
+  -- ライブコメントに関する通知でなければ0
+  `livecomment_id` BIGINT NOT NULL DEFAULT 0,
+  `created_at` BIGINT NOT NULL,
+  -- 既読にした日時。未読ならNULL
+  `read_at` BIGINT DEFAULT NULL,
+  -- 複数のアプリケーションサーバが同じ通知を作っても1件にする
+  UNIQUE `uniq_notification` (`user_id`, `kind`, `livestream_id`, `livecomment_id`),
+  INDEX `idx_user_id_read_at` (`user_id`, `read_at`)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+
+-- 配信者のチャンネル (すべての配信) から締め出したユーザ
+CREATE TABLE `channel_bans` (
+  `streamer_id` BIGINT NOT NULL,
+  `user_id` BIGINT NOT NULL,
+  `created_at` BIGINT NOT NULL,
+  PRIMARY KEY (`streamer_id`, `user_id`)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+
+-- 管理・モデレーションの操作の監査ログ
+-- 追記のみで、更新・削除はしない
+CREATE TABLE `audit_logs` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  `kind` VARCHAR(64) NOT NULL,
+  -- 操作したユーザ
+  `actor_id` BIGINT NOT NULL,
+  -- 対象がなければ0
+  `livestream_id` BIGINT NOT NULL DEFAULT 0,
+  `livecomment_id` BIGINT NOT NULL DEFAULT 0,
+  `target_user_id` BIGINT NOT NULL DEFAULT 0,
+  `detail` TEXT NOT NULL,
+  `created_at` BIGINT NOT NULL,
+  INDEX `idx_created_at` (`created_at`)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+
+-- リアクションに使える絵文字
+-- 初期値は sql/initial_reaction_emojis.sql にあり、初期化 (init.sh) のたびに入れ直す
+CREATE TABLE `reaction_emojis` (
+  `name` VARCHAR(255) NOT NULL PRIMARY KEY,
+  `created_at` BIGINT NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
@@ -0,0 +1,11 @@
+-- 初期データのライブコメントからチップ合計を算出
+INSERT INTO payment_totals (id, total_tip)
+SELECT 1, IFNULL(SUM(tip), 0) FROM livecomments WHERE deleted_at IS NULL;
+
+-- 初期データのライブコメントから配信ごと・日ごとのチップ合計を算出
+INSERT INTO livestream_daily_tips (livestream_id, user_id, day, total_tip)
+SELECT lc.livestream_id, l.user_id, lc.created_at - MOD(lc.created_at, 86400), SUM(lc.tip)
+FROM livecomments lc
+INNER JOIN livestreams l ON l.id = lc.livestream_id
+WHERE lc.deleted_at IS NULL AND lc.tip > 0
+GROUP BY lc.livestream_id, l.user_id, lc.created_at - MOD(lc.created_at, 86400);
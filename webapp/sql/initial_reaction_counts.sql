@@ -0,0 +1,3 @@
+-- 初期データのリアクションから配信ごと・絵文字ごとのリアクション数を算出
+INSERT INTO reaction_counts (livestream_id, emoji_name, count)
+SELECT livestream_id, emoji_name, COUNT(*) FROM reactions GROUP BY livestream_id, emoji_name;
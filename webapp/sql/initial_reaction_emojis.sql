@@ -0,0 +1,1818 @@
+-- リアクションに使える絵文字の初期値。ベンチマーカーと初期データで使われる絵文字を登録しておく
+INSERT INTO `reaction_emojis` (`name`, `created_at`)
+VALUES
+  ('+1', UNIX_TIMESTAMP()),
+  ('-1', UNIX_TIMESTAMP()),
+  ('100', UNIX_TIMESTAMP()),
+  ('1234', UNIX_TIMESTAMP()),
+  ('8ball', UNIX_TIMESTAMP()),
+  ('a', UNIX_TIMESTAMP()),
+  ('ab', UNIX_TIMESTAMP()),
+  ('abacus', UNIX_TIMESTAMP()),
+  ('abc', UNIX_TIMESTAMP()),
+  ('abcd', UNIX_TIMESTAMP()),
+  ('accept', UNIX_TIMESTAMP()),
+  ('accordion', UNIX_TIMESTAMP()),
+  ('adhesive_bandage', UNIX_TIMESTAMP()),
+  ('admission_tickets', UNIX_TIMESTAMP()),
+  ('adult', UNIX_TIMESTAMP()),
+  ('aerial_tramway', UNIX_TIMESTAMP()),
+  ('airplane', UNIX_TIMESTAMP()),
+  ('airplane_arriving', UNIX_TIMESTAMP()),
+  ('airplane_departure', UNIX_TIMESTAMP()),
+  ('alarm_clock', UNIX_TIMESTAMP()),
+  ('alembic', UNIX_TIMESTAMP()),
+  ('alien', UNIX_TIMESTAMP()),
+  ('ambulance', UNIX_TIMESTAMP()),
+  ('amphora', UNIX_TIMESTAMP()),
+  ('anatomical_heart', UNIX_TIMESTAMP()),
+  ('anchor', UNIX_TIMESTAMP()),
+  ('angel', UNIX_TIMESTAMP()),
+  ('anger', UNIX_TIMESTAMP()),
+  ('angry', UNIX_TIMESTAMP()),
+  ('anguished', UNIX_TIMESTAMP()),
+  ('ant', UNIX_TIMESTAMP()),
+  ('apple', UNIX_TIMESTAMP()),
+  ('aquarius', UNIX_TIMESTAMP()),
+  ('aries', UNIX_TIMESTAMP()),
+  ('arrow_backward', UNIX_TIMESTAMP()),
+  ('arrow_double_down', UNIX_TIMESTAMP()),
+  ('arrow_double_up', UNIX_TIMESTAMP()),
+  ('arrow_down', UNIX_TIMESTAMP()),
+  ('arrow_down_small', UNIX_TIMESTAMP()),
+  ('arrow_forward', UNIX_TIMESTAMP()),
+  ('arrow_heading_down', UNIX_TIMESTAMP()),
+  ('arrow_heading_up', UNIX_TIMESTAMP()),
+  ('arrow_left', UNIX_TIMESTAMP()),
+  ('arrow_lower_left', UNIX_TIMESTAMP()),
+  ('arrow_lower_right', UNIX_TIMESTAMP()),
+  ('arrow_right', UNIX_TIMESTAMP()),
+  ('arrow_right_hook', UNIX_TIMESTAMP()),
+  ('arrow_up', UNIX_TIMESTAMP()),
+  ('arrow_up_down', UNIX_TIMESTAMP()),
+  ('arrow_up_small', UNIX_TIMESTAMP()),
+  ('arrow_upper_left', UNIX_TIMESTAMP()),
+  ('arrow_upper_right', UNIX_TIMESTAMP()),
+  ('arrows_clockwise', UNIX_TIMESTAMP()),
+  ('arrows_counterclockwise', UNIX_TIMESTAMP()),
+  ('art', UNIX_TIMESTAMP()),
+  ('articulated_lorry', UNIX_TIMESTAMP()),
+  ('artist', UNIX_TIMESTAMP()),
+  ('astonished', UNIX_TIMESTAMP()),
+  ('astronaut', UNIX_TIMESTAMP()),
+  ('athletic_shoe', UNIX_TIMESTAMP()),
+  ('atm', UNIX_TIMESTAMP()),
+  ('atom_symbol', UNIX_TIMESTAMP()),
+  ('auto_rickshaw', UNIX_TIMESTAMP()),
+  ('avocado', UNIX_TIMESTAMP()),
+  ('axe', UNIX_TIMESTAMP()),
+  ('b', UNIX_TIMESTAMP()),
+  ('baby', UNIX_TIMESTAMP()),
+  ('baby_bottle', UNIX_TIMESTAMP()),
+  ('baby_chick', UNIX_TIMESTAMP()),
+  ('baby_symbol', UNIX_TIMESTAMP()),
+  ('back', UNIX_TIMESTAMP()),
+  ('bacon', UNIX_TIMESTAMP()),
+  ('badger', UNIX_TIMESTAMP()),
+  ('badminton_racquet_and_shuttlecock', UNIX_TIMESTAMP()),
+  ('bagel', UNIX_TIMESTAMP()),
+  ('baggage_claim', UNIX_TIMESTAMP()),
+  ('baguette_bread', UNIX_TIMESTAMP()),
+  ('bald_man', UNIX_TIMESTAMP()),
+  ('bald_person', UNIX_TIMESTAMP()),
+  ('bald_woman', UNIX_TIMESTAMP()),
+  ('ballet_shoes', UNIX_TIMESTAMP()),
+  ('balloon', UNIX_TIMESTAMP()),
+  ('ballot_box_with_ballot', UNIX_TIMESTAMP()),
+  ('ballot_box_with_check', UNIX_TIMESTAMP()),
+  ('bamboo', UNIX_TIMESTAMP()),
+  ('banana', UNIX_TIMESTAMP()),
+  ('bangbang', UNIX_TIMESTAMP()),
+  ('banjo', UNIX_TIMESTAMP()),
+  ('bank', UNIX_TIMESTAMP()),
+  ('bar_chart', UNIX_TIMESTAMP()),
+  ('barber', UNIX_TIMESTAMP()),
+  ('barely_sunny', UNIX_TIMESTAMP()),
+  ('baseball', UNIX_TIMESTAMP()),
+  ('basket', UNIX_TIMESTAMP()),
+  ('basketball', UNIX_TIMESTAMP()),
+  ('bat', UNIX_TIMESTAMP()),
+  ('bath', UNIX_TIMESTAMP()),
+  ('bathtub', UNIX_TIMESTAMP()),
+  ('battery', UNIX_TIMESTAMP()),
+  ('beach_with_umbrella', UNIX_TIMESTAMP()),
+  ('bear', UNIX_TIMESTAMP()),
+  ('bearded_person', UNIX_TIMESTAMP()),
+  ('beaver', UNIX_TIMESTAMP()),
+  ('bed', UNIX_TIMESTAMP()),
+  ('bee', UNIX_TIMESTAMP()),
+  ('beer', UNIX_TIMESTAMP()),
+  ('beers', UNIX_TIMESTAMP()),
+  ('beetle', UNIX_TIMESTAMP()),
+  ('beginner', UNIX_TIMESTAMP()),
+  ('bell', UNIX_TIMESTAMP()),
+  ('bell_pepper', UNIX_TIMESTAMP()),
+  ('bellhop_bell', UNIX_TIMESTAMP()),
+  ('bento', UNIX_TIMESTAMP()),
+  ('beverage_box', UNIX_TIMESTAMP()),
+  ('bicyclist', UNIX_TIMESTAMP()),
+  ('bike', UNIX_TIMESTAMP()),
+  ('bikini', UNIX_TIMESTAMP()),
+  ('billed_cap', UNIX_TIMESTAMP()),
+  ('biohazard_sign', UNIX_TIMESTAMP()),
+  ('bird', UNIX_TIMESTAMP()),
+  ('birthday', UNIX_TIMESTAMP()),
+  ('bison', UNIX_TIMESTAMP()),
+  ('black_cat', UNIX_TIMESTAMP()),
+  ('black_circle', UNIX_TIMESTAMP()),
+  ('black_circle_for_record', UNIX_TIMESTAMP()),
+  ('black_heart', UNIX_TIMESTAMP()),
+  ('black_joker', UNIX_TIMESTAMP()),
+  ('black_large_square', UNIX_TIMESTAMP()),
+  ('black_left_pointing_double_triangle_with_vertical_bar', UNIX_TIMESTAMP()),
+  ('black_medium_small_square', UNIX_TIMESTAMP()),
+  ('black_medium_square', UNIX_TIMESTAMP()),
+  ('black_nib', UNIX_TIMESTAMP()),
+  ('black_right_pointing_double_triangle_with_vertical_bar', UNIX_TIMESTAMP()),
+  ('black_right_pointing_triangle_with_double_vertical_bar', UNIX_TIMESTAMP()),
+  ('black_small_square', UNIX_TIMESTAMP()),
+  ('black_square_button', UNIX_TIMESTAMP()),
+  ('black_square_for_stop', UNIX_TIMESTAMP()),
+  ('blond-haired-man', UNIX_TIMESTAMP()),
+  ('blond-haired-woman', UNIX_TIMESTAMP()),
+  ('blossom', UNIX_TIMESTAMP()),
+  ('blowfish', UNIX_TIMESTAMP()),
+  ('blue_book', UNIX_TIMESTAMP()),
+  ('blue_car', UNIX_TIMESTAMP()),
+  ('blue_heart', UNIX_TIMESTAMP()),
+  ('blueberries', UNIX_TIMESTAMP()),
+  ('blush', UNIX_TIMESTAMP()),
+  ('boar', UNIX_TIMESTAMP()),
+  ('boat', UNIX_TIMESTAMP()),
+  ('bomb', UNIX_TIMESTAMP()),
+  ('bone', UNIX_TIMESTAMP()),
+  ('book', UNIX_TIMESTAMP()),
+  ('bookmark', UNIX_TIMESTAMP()),
+  ('bookmark_tabs', UNIX_TIMESTAMP()),
+  ('books', UNIX_TIMESTAMP()),
+  ('boom', UNIX_TIMESTAMP()),
+  ('boomerang', UNIX_TIMESTAMP()),
+  ('boot', UNIX_TIMESTAMP()),
+  ('bouquet', UNIX_TIMESTAMP()),
+  ('bow', UNIX_TIMESTAMP()),
+  ('bow_and_arrow', UNIX_TIMESTAMP()),
+  ('bowl_with_spoon', UNIX_TIMESTAMP()),
+  ('bowling', UNIX_TIMESTAMP()),
+  ('boxing_glove', UNIX_TIMESTAMP()),
+  ('boy', UNIX_TIMESTAMP()),
+  ('brain', UNIX_TIMESTAMP()),
+  ('bread', UNIX_TIMESTAMP()),
+  ('breast-feeding', UNIX_TIMESTAMP()),
+  ('bricks', UNIX_TIMESTAMP()),
+  ('bride_with_veil', UNIX_TIMESTAMP()),
+  ('bridge_at_night', UNIX_TIMESTAMP()),
+  ('briefcase', UNIX_TIMESTAMP()),
+  ('briefs', UNIX_TIMESTAMP()),
+  ('broccoli', UNIX_TIMESTAMP()),
+  ('broken_heart', UNIX_TIMESTAMP()),
+  ('broom', UNIX_TIMESTAMP()),
+  ('brown_heart', UNIX_TIMESTAMP()),
+  ('bubble_tea', UNIX_TIMESTAMP()),
+  ('bucket', UNIX_TIMESTAMP()),
+  ('bug', UNIX_TIMESTAMP()),
+  ('building_construction', UNIX_TIMESTAMP()),
+  ('bulb', UNIX_TIMESTAMP()),
+  ('bullettrain_front', UNIX_TIMESTAMP()),
+  ('bullettrain_side', UNIX_TIMESTAMP()),
+  ('burrito', UNIX_TIMESTAMP()),
+  ('bus', UNIX_TIMESTAMP()),
+  ('busstop', UNIX_TIMESTAMP()),
+  ('bust_in_silhouette', UNIX_TIMESTAMP()),
+  ('busts_in_silhouette', UNIX_TIMESTAMP()),
+  ('butter', UNIX_TIMESTAMP()),
+  ('butterfly', UNIX_TIMESTAMP()),
+  ('cactus', UNIX_TIMESTAMP()),
+  ('cake', UNIX_TIMESTAMP()),
+  ('calendar', UNIX_TIMESTAMP()),
+  ('call_me_hand', UNIX_TIMESTAMP()),
+  ('calling', UNIX_TIMESTAMP()),
+  ('camel', UNIX_TIMESTAMP()),
+  ('camera', UNIX_TIMESTAMP()),
+  ('camera_with_flash', UNIX_TIMESTAMP()),
+  ('camping', UNIX_TIMESTAMP()),
+  ('cancer', UNIX_TIMESTAMP()),
+  ('candle', UNIX_TIMESTAMP()),
+  ('candy', UNIX_TIMESTAMP()),
+  ('canned_food', UNIX_TIMESTAMP()),
+  ('canoe', UNIX_TIMESTAMP()),
+  ('capital_abcd', UNIX_TIMESTAMP()),
+  ('capricorn', UNIX_TIMESTAMP()),
+  ('car', UNIX_TIMESTAMP()),
+  ('card_file_box', UNIX_TIMESTAMP()),
+  ('card_index', UNIX_TIMESTAMP()),
+  ('card_index_dividers', UNIX_TIMESTAMP()),
+  ('carousel_horse', UNIX_TIMESTAMP()),
+  ('carpentry_saw', UNIX_TIMESTAMP()),
+  ('carrot', UNIX_TIMESTAMP()),
+  ('cat', UNIX_TIMESTAMP()),
+  ('cat2', UNIX_TIMESTAMP()),
+  ('cd', UNIX_TIMESTAMP()),
+  ('chains', UNIX_TIMESTAMP()),
+  ('chair', UNIX_TIMESTAMP()),
+  ('champagne', UNIX_TIMESTAMP()),
+  ('chart', UNIX_TIMESTAMP()),
+  ('chart_with_downwards_trend', UNIX_TIMESTAMP()),
+  ('chart_with_upwards_trend', UNIX_TIMESTAMP()),
+  ('checkered_flag', UNIX_TIMESTAMP()),
+  ('cheese_wedge', UNIX_TIMESTAMP()),
+  ('cherries', UNIX_TIMESTAMP()),
+  ('cherry_blossom', UNIX_TIMESTAMP()),
+  ('chess_pawn', UNIX_TIMESTAMP()),
+  ('chestnut', UNIX_TIMESTAMP()),
+  ('chicken', UNIX_TIMESTAMP()),
+  ('child', UNIX_TIMESTAMP()),
+  ('children_crossing', UNIX_TIMESTAMP()),
+  ('chipmunk', UNIX_TIMESTAMP()),
+  ('chocolate_bar', UNIX_TIMESTAMP()),
+  ('chopsticks', UNIX_TIMESTAMP()),
+  ('christmas_tree', UNIX_TIMESTAMP()),
+  ('church', UNIX_TIMESTAMP()),
+  ('cinema', UNIX_TIMESTAMP()),
+  ('circus_tent', UNIX_TIMESTAMP()),
+  ('city_sunrise', UNIX_TIMESTAMP()),
+  ('city_sunset', UNIX_TIMESTAMP()),
+  ('cityscape', UNIX_TIMESTAMP()),
+  ('cl', UNIX_TIMESTAMP()),
+  ('clap', UNIX_TIMESTAMP()),
+  ('clapper', UNIX_TIMESTAMP()),
+  ('classical_building', UNIX_TIMESTAMP()),
+  ('clinking_glasses', UNIX_TIMESTAMP()),
+  ('clipboard', UNIX_TIMESTAMP()),
+  ('clock1', UNIX_TIMESTAMP()),
+  ('clock10', UNIX_TIMESTAMP()),
+  ('clock1030', UNIX_TIMESTAMP()),
+  ('clock11', UNIX_TIMESTAMP()),
+  ('clock1130', UNIX_TIMESTAMP()),
+  ('clock12', UNIX_TIMESTAMP()),
+  ('clock1230', UNIX_TIMESTAMP()),
+  ('clock130', UNIX_TIMESTAMP()),
+  ('clock2', UNIX_TIMESTAMP()),
+  ('clock230', UNIX_TIMESTAMP()),
+  ('clock3', UNIX_TIMESTAMP()),
+  ('clock330', UNIX_TIMESTAMP()),
+  ('clock4', UNIX_TIMESTAMP()),
+  ('clock430', UNIX_TIMESTAMP()),
+  ('clock5', UNIX_TIMESTAMP()),
+  ('clock530', UNIX_TIMESTAMP()),
+  ('clock6', UNIX_TIMESTAMP()),
+  ('clock630', UNIX_TIMESTAMP()),
+  ('clock7', UNIX_TIMESTAMP()),
+  ('clock730', UNIX_TIMESTAMP()),
+  ('clock8', UNIX_TIMESTAMP()),
+  ('clock830', UNIX_TIMESTAMP()),
+  ('clock9', UNIX_TIMESTAMP()),
+  ('clock930', UNIX_TIMESTAMP()),
+  ('closed_book', UNIX_TIMESTAMP()),
+  ('closed_lock_with_key', UNIX_TIMESTAMP()),
+  ('closed_umbrella', UNIX_TIMESTAMP()),
+  ('cloud', UNIX_TIMESTAMP()),
+  ('clown_face', UNIX_TIMESTAMP()),
+  ('clubs', UNIX_TIMESTAMP()),
+  ('cn', UNIX_TIMESTAMP()),
+  ('coat', UNIX_TIMESTAMP()),
+  ('cockroach', UNIX_TIMESTAMP()),
+  ('cocktail', UNIX_TIMESTAMP()),
+  ('coconut', UNIX_TIMESTAMP()),
+  ('coffee', UNIX_TIMESTAMP()),
+  ('coffin', UNIX_TIMESTAMP()),
+  ('coin', UNIX_TIMESTAMP()),
+  ('cold_face', UNIX_TIMESTAMP()),
+  ('cold_sweat', UNIX_TIMESTAMP()),
+  ('comet', UNIX_TIMESTAMP()),
+  ('compass', UNIX_TIMESTAMP()),
+  ('compression', UNIX_TIMESTAMP()),
+  ('computer', UNIX_TIMESTAMP()),
+  ('confetti_ball', UNIX_TIMESTAMP()),
+  ('confounded', UNIX_TIMESTAMP()),
+  ('confused', UNIX_TIMESTAMP()),
+  ('congratulations', UNIX_TIMESTAMP()),
+  ('construction', UNIX_TIMESTAMP()),
+  ('construction_worker', UNIX_TIMESTAMP()),
+  ('control_knobs', UNIX_TIMESTAMP()),
+  ('convenience_store', UNIX_TIMESTAMP()),
+  ('cook', UNIX_TIMESTAMP()),
+  ('cookie', UNIX_TIMESTAMP()),
+  ('cool', UNIX_TIMESTAMP()),
+  ('cop', UNIX_TIMESTAMP()),
+  ('copyright', UNIX_TIMESTAMP()),
+  ('corn', UNIX_TIMESTAMP()),
+  ('couch_and_lamp', UNIX_TIMESTAMP()),
+  ('couple_with_heart', UNIX_TIMESTAMP()),
+  ('couplekiss', UNIX_TIMESTAMP()),
+  ('cow', UNIX_TIMESTAMP()),
+  ('cow2', UNIX_TIMESTAMP()),
+  ('crab', UNIX_TIMESTAMP()),
+  ('credit_card', UNIX_TIMESTAMP()),
+  ('crescent_moon', UNIX_TIMESTAMP()),
+  ('cricket', UNIX_TIMESTAMP()),
+  ('cricket_bat_and_ball', UNIX_TIMESTAMP()),
+  ('crocodile', UNIX_TIMESTAMP()),
+  ('croissant', UNIX_TIMESTAMP()),
+  ('crossed_fingers', UNIX_TIMESTAMP()),
+  ('crossed_flags', UNIX_TIMESTAMP()),
+  ('crossed_swords', UNIX_TIMESTAMP()),
+  ('crown', UNIX_TIMESTAMP()),
+  ('cry', UNIX_TIMESTAMP()),
+  ('crying_cat_face', UNIX_TIMESTAMP()),
+  ('crystal_ball', UNIX_TIMESTAMP()),
+  ('cucumber', UNIX_TIMESTAMP()),
+  ('cup_with_straw', UNIX_TIMESTAMP()),
+  ('cupcake', UNIX_TIMESTAMP()),
+  ('cupid', UNIX_TIMESTAMP()),
+  ('curling_stone', UNIX_TIMESTAMP()),
+  ('curly_haired_man', UNIX_TIMESTAMP()),
+  ('curly_haired_person', UNIX_TIMESTAMP()),
+  ('curly_haired_woman', UNIX_TIMESTAMP()),
+  ('curly_loop', UNIX_TIMESTAMP()),
+  ('currency_exchange', UNIX_TIMESTAMP()),
+  ('curry', UNIX_TIMESTAMP()),
+  ('custard', UNIX_TIMESTAMP()),
+  ('customs', UNIX_TIMESTAMP()),
+  ('cut_of_meat', UNIX_TIMESTAMP()),
+  ('cyclone', UNIX_TIMESTAMP()),
+  ('dagger_knife', UNIX_TIMESTAMP()),
+  ('dancer', UNIX_TIMESTAMP()),
+  ('dancers', UNIX_TIMESTAMP()),
+  ('dango', UNIX_TIMESTAMP()),
+  ('dark_sunglasses', UNIX_TIMESTAMP()),
+  ('dart', UNIX_TIMESTAMP()),
+  ('dash', UNIX_TIMESTAMP()),
+  ('date', UNIX_TIMESTAMP()),
+  ('de', UNIX_TIMESTAMP()),
+  ('deaf_man', UNIX_TIMESTAMP()),
+  ('deaf_person', UNIX_TIMESTAMP()),
+  ('deaf_woman', UNIX_TIMESTAMP()),
+  ('deciduous_tree', UNIX_TIMESTAMP()),
+  ('deer', UNIX_TIMESTAMP()),
+  ('department_store', UNIX_TIMESTAMP()),
+  ('derelict_house_building', UNIX_TIMESTAMP()),
+  ('desert', UNIX_TIMESTAMP()),
+  ('desert_island', UNIX_TIMESTAMP()),
+  ('desktop_computer', UNIX_TIMESTAMP()),
+  ('diamond_shape_with_a_dot_inside', UNIX_TIMESTAMP()),
+  ('diamonds', UNIX_TIMESTAMP()),
+  ('disappointed', UNIX_TIMESTAMP()),
+  ('disappointed_relieved', UNIX_TIMESTAMP()),
+  ('disguised_face', UNIX_TIMESTAMP()),
+  ('diving_mask', UNIX_TIMESTAMP()),
+  ('diya_lamp', UNIX_TIMESTAMP()),
+  ('dizzy', UNIX_TIMESTAMP()),
+  ('dizzy_face', UNIX_TIMESTAMP()),
+  ('dna', UNIX_TIMESTAMP()),
+  ('do_not_litter', UNIX_TIMESTAMP()),
+  ('dodo', UNIX_TIMESTAMP()),
+  ('dog', UNIX_TIMESTAMP()),
+  ('dog2', UNIX_TIMESTAMP()),
+  ('dollar', UNIX_TIMESTAMP()),
+  ('dolls', UNIX_TIMESTAMP()),
+  ('dolphin', UNIX_TIMESTAMP()),
+  ('door', UNIX_TIMESTAMP()),
+  ('double_vertical_bar', UNIX_TIMESTAMP()),
+  ('doughnut', UNIX_TIMESTAMP()),
+  ('dove_of_peace', UNIX_TIMESTAMP()),
+  ('dragon', UNIX_TIMESTAMP()),
+  ('dragon_face', UNIX_TIMESTAMP()),
+  ('dress', UNIX_TIMESTAMP()),
+  ('dromedary_camel', UNIX_TIMESTAMP()),
+  ('drooling_face', UNIX_TIMESTAMP()),
+  ('drop_of_blood', UNIX_TIMESTAMP()),
+  ('droplet', UNIX_TIMESTAMP()),
+  ('drum_with_drumsticks', UNIX_TIMESTAMP()),
+  ('duck', UNIX_TIMESTAMP()),
+  ('dumpling', UNIX_TIMESTAMP()),
+  ('dvd', UNIX_TIMESTAMP()),
+  ('e-mail', UNIX_TIMESTAMP()),
+  ('eagle', UNIX_TIMESTAMP()),
+  ('ear', UNIX_TIMESTAMP()),
+  ('ear_of_rice', UNIX_TIMESTAMP()),
+  ('ear_with_hearing_aid', UNIX_TIMESTAMP()),
+  ('earth_africa', UNIX_TIMESTAMP()),
+  ('earth_americas', UNIX_TIMESTAMP()),
+  ('earth_asia', UNIX_TIMESTAMP()),
+  ('egg', UNIX_TIMESTAMP()),
+  ('eggplant', UNIX_TIMESTAMP()),
+  ('eight', UNIX_TIMESTAMP()),
+  ('eight_pointed_black_star', UNIX_TIMESTAMP()),
+  ('eight_spoked_asterisk', UNIX_TIMESTAMP()),
+  ('eject', UNIX_TIMESTAMP()),
+  ('electric_plug', UNIX_TIMESTAMP()),
+  ('elephant', UNIX_TIMESTAMP()),
+  ('elevator', UNIX_TIMESTAMP()),
+  ('elf', UNIX_TIMESTAMP()),
+  ('email', UNIX_TIMESTAMP()),
+  ('end', UNIX_TIMESTAMP()),
+  ('envelope_with_arrow', UNIX_TIMESTAMP()),
+  ('es', UNIX_TIMESTAMP()),
+  ('euro', UNIX_TIMESTAMP()),
+  ('european_castle', UNIX_TIMESTAMP()),
+  ('european_post_office', UNIX_TIMESTAMP()),
+  ('evergreen_tree', UNIX_TIMESTAMP()),
+  ('exclamation', UNIX_TIMESTAMP()),
+  ('exploding_head', UNIX_TIMESTAMP()),
+  ('expressionless', UNIX_TIMESTAMP()),
+  ('eye', UNIX_TIMESTAMP()),
+  ('eye-in-speech-bubble', UNIX_TIMESTAMP()),
+  ('eyeglasses', UNIX_TIMESTAMP()),
+  ('eyes', UNIX_TIMESTAMP()),
+  ('face_exhaling', UNIX_TIMESTAMP()),
+  ('face_in_clouds', UNIX_TIMESTAMP()),
+  ('face_palm', UNIX_TIMESTAMP()),
+  ('face_vomiting', UNIX_TIMESTAMP()),
+  ('face_with_cowboy_hat', UNIX_TIMESTAMP()),
+  ('face_with_hand_over_mouth', UNIX_TIMESTAMP()),
+  ('face_with_head_bandage', UNIX_TIMESTAMP()),
+  ('face_with_monocle', UNIX_TIMESTAMP()),
+  ('face_with_raised_eyebrow', UNIX_TIMESTAMP()),
+  ('face_with_rolling_eyes', UNIX_TIMESTAMP()),
+  ('face_with_spiral_eyes', UNIX_TIMESTAMP()),
+  ('face_with_symbols_on_mouth', UNIX_TIMESTAMP()),
+  ('face_with_thermometer', UNIX_TIMESTAMP()),
+  ('facepunch', UNIX_TIMESTAMP()),
+  ('factory', UNIX_TIMESTAMP()),
+  ('factory_worker', UNIX_TIMESTAMP()),
+  ('fairy', UNIX_TIMESTAMP()),
+  ('falafel', UNIX_TIMESTAMP()),
+  ('fallen_leaf', UNIX_TIMESTAMP()),
+  ('family', UNIX_TIMESTAMP()),
+  ('farmer', UNIX_TIMESTAMP()),
+  ('fast_forward', UNIX_TIMESTAMP()),
+  ('fax', UNIX_TIMESTAMP()),
+  ('fearful', UNIX_TIMESTAMP()),
+  ('feather', UNIX_TIMESTAMP()),
+  ('feet', UNIX_TIMESTAMP()),
+  ('female-artist', UNIX_TIMESTAMP()),
+  ('female-astronaut', UNIX_TIMESTAMP()),
+  ('female-construction-worker', UNIX_TIMESTAMP()),
+  ('female-cook', UNIX_TIMESTAMP()),
+  ('female-detective', UNIX_TIMESTAMP()),
+  ('female-doctor', UNIX_TIMESTAMP()),
+  ('female-factory-worker', UNIX_TIMESTAMP()),
+  ('female-farmer', UNIX_TIMESTAMP()),
+  ('female-firefighter', UNIX_TIMESTAMP()),
+  ('female-guard', UNIX_TIMESTAMP()),
+  ('female-judge', UNIX_TIMESTAMP()),
+  ('female-mechanic', UNIX_TIMESTAMP()),
+  ('female-office-worker', UNIX_TIMESTAMP()),
+  ('female-pilot', UNIX_TIMESTAMP()),
+  ('female-police-officer', UNIX_TIMESTAMP()),
+  ('female-scientist', UNIX_TIMESTAMP()),
+  ('female-singer', UNIX_TIMESTAMP()),
+  ('female-student', UNIX_TIMESTAMP()),
+  ('female-teacher', UNIX_TIMESTAMP()),
+  ('female-technologist', UNIX_TIMESTAMP()),
+  ('female_elf', UNIX_TIMESTAMP()),
+  ('female_fairy', UNIX_TIMESTAMP()),
+  ('female_genie', UNIX_TIMESTAMP()),
+  ('female_mage', UNIX_TIMESTAMP()),
+  ('female_sign', UNIX_TIMESTAMP()),
+  ('female_superhero', UNIX_TIMESTAMP()),
+  ('female_supervillain', UNIX_TIMESTAMP()),
+  ('female_vampire', UNIX_TIMESTAMP()),
+  ('female_zombie', UNIX_TIMESTAMP()),
+  ('fencer', UNIX_TIMESTAMP()),
+  ('ferris_wheel', UNIX_TIMESTAMP()),
+  ('ferry', UNIX_TIMESTAMP()),
+  ('field_hockey_stick_and_ball', UNIX_TIMESTAMP()),
+  ('file_cabinet', UNIX_TIMESTAMP()),
+  ('file_folder', UNIX_TIMESTAMP()),
+  ('film_frames', UNIX_TIMESTAMP()),
+  ('film_projector', UNIX_TIMESTAMP()),
+  ('fire', UNIX_TIMESTAMP()),
+  ('fire_engine', UNIX_TIMESTAMP()),
+  ('fire_extinguisher', UNIX_TIMESTAMP()),
+  ('firecracker', UNIX_TIMESTAMP()),
+  ('firefighter', UNIX_TIMESTAMP()),
+  ('fireworks', UNIX_TIMESTAMP()),
+  ('first_place_medal', UNIX_TIMESTAMP()),
+  ('first_quarter_moon', UNIX_TIMESTAMP()),
+  ('first_quarter_moon_with_face', UNIX_TIMESTAMP()),
+  ('fish', UNIX_TIMESTAMP()),
+  ('fish_cake', UNIX_TIMESTAMP()),
+  ('fishing_pole_and_fish', UNIX_TIMESTAMP()),
+  ('fist', UNIX_TIMESTAMP()),
+  ('five', UNIX_TIMESTAMP()),
+  ('flag-ac', UNIX_TIMESTAMP()),
+  ('flag-ad', UNIX_TIMESTAMP()),
+  ('flag-ae', UNIX_TIMESTAMP()),
+  ('flag-af', UNIX_TIMESTAMP()),
+  ('flag-ag', UNIX_TIMESTAMP()),
+  ('flag-ai', UNIX_TIMESTAMP()),
+  ('flag-al', UNIX_TIMESTAMP()),
+  ('flag-am', UNIX_TIMESTAMP()),
+  ('flag-ao', UNIX_TIMESTAMP()),
+  ('flag-aq', UNIX_TIMESTAMP()),
+  ('flag-ar', UNIX_TIMESTAMP()),
+  ('flag-as', UNIX_TIMESTAMP()),
+  ('flag-at', UNIX_TIMESTAMP()),
+  ('flag-au', UNIX_TIMESTAMP()),
+  ('flag-aw', UNIX_TIMESTAMP()),
+  ('flag-ax', UNIX_TIMESTAMP()),
+  ('flag-az', UNIX_TIMESTAMP()),
+  ('flag-ba', UNIX_TIMESTAMP()),
+  ('flag-bb', UNIX_TIMESTAMP()),
+  ('flag-bd', UNIX_TIMESTAMP()),
+  ('flag-be', UNIX_TIMESTAMP()),
+  ('flag-bf', UNIX_TIMESTAMP()),
+  ('flag-bg', UNIX_TIMESTAMP()),
+  ('flag-bh', UNIX_TIMESTAMP()),
+  ('flag-bi', UNIX_TIMESTAMP()),
+  ('flag-bj', UNIX_TIMESTAMP()),
+  ('flag-bl', UNIX_TIMESTAMP()),
+  ('flag-bm', UNIX_TIMESTAMP()),
+  ('flag-bn', UNIX_TIMESTAMP()),
+  ('flag-bo', UNIX_TIMESTAMP()),
+  ('flag-bq', UNIX_TIMESTAMP()),
+  ('flag-br', UNIX_TIMESTAMP()),
+  ('flag-bs', UNIX_TIMESTAMP()),
+  ('flag-bt', UNIX_TIMESTAMP()),
+  ('flag-bv', UNIX_TIMESTAMP()),
+  ('flag-bw', UNIX_TIMESTAMP()),
+  ('flag-by', UNIX_TIMESTAMP()),
+  ('flag-bz', UNIX_TIMESTAMP()),
+  ('flag-ca', UNIX_TIMESTAMP()),
+  ('flag-cc', UNIX_TIMESTAMP()),
+  ('flag-cd', UNIX_TIMESTAMP()),
+  ('flag-cf', UNIX_TIMESTAMP()),
+  ('flag-cg', UNIX_TIMESTAMP()),
+  ('flag-ch', UNIX_TIMESTAMP()),
+  ('flag-ci', UNIX_TIMESTAMP()),
+  ('flag-ck', UNIX_TIMESTAMP()),
+  ('flag-cl', UNIX_TIMESTAMP()),
+  ('flag-cm', UNIX_TIMESTAMP()),
+  ('flag-co', UNIX_TIMESTAMP()),
+  ('flag-cp', UNIX_TIMESTAMP()),
+  ('flag-cr', UNIX_TIMESTAMP()),
+  ('flag-cu', UNIX_TIMESTAMP()),
+  ('flag-cv', UNIX_TIMESTAMP()),
+  ('flag-cw', UNIX_TIMESTAMP()),
+  ('flag-cx', UNIX_TIMESTAMP()),
+  ('flag-cy', UNIX_TIMESTAMP()),
+  ('flag-cz', UNIX_TIMESTAMP()),
+  ('flag-dg', UNIX_TIMESTAMP()),
+  ('flag-dj', UNIX_TIMESTAMP()),
+  ('flag-dk', UNIX_TIMESTAMP()),
+  ('flag-dm', UNIX_TIMESTAMP()),
+  ('flag-do', UNIX_TIMESTAMP()),
+  ('flag-dz', UNIX_TIMESTAMP()),
+  ('flag-ea', UNIX_TIMESTAMP()),
+  ('flag-ec', UNIX_TIMESTAMP()),
+  ('flag-ee', UNIX_TIMESTAMP()),
+  ('flag-eg', UNIX_TIMESTAMP()),
+  ('flag-eh', UNIX_TIMESTAMP()),
+  ('flag-england', UNIX_TIMESTAMP()),
+  ('flag-er', UNIX_TIMESTAMP()),
+  ('flag-et', UNIX_TIMESTAMP()),
+  ('flag-eu', UNIX_TIMESTAMP()),
+  ('flag-fi', UNIX_TIMESTAMP()),
+  ('flag-fj', UNIX_TIMESTAMP()),
+  ('flag-fk', UNIX_TIMESTAMP()),
+  ('flag-fm', UNIX_TIMESTAMP()),
+  ('flag-fo', UNIX_TIMESTAMP()),
+  ('flag-ga', UNIX_TIMESTAMP()),
+  ('flag-gd', UNIX_TIMESTAMP()),
+  ('flag-ge', UNIX_TIMESTAMP()),
+  ('flag-gf', UNIX_TIMESTAMP()),
+  ('flag-gg', UNIX_TIMESTAMP()),
+  ('flag-gh', UNIX_TIMESTAMP()),
+  ('flag-gi', UNIX_TIMESTAMP()),
+  ('flag-gl', UNIX_TIMESTAMP()),
+  ('flag-gm', UNIX_TIMESTAMP()),
+  ('flag-gn', UNIX_TIMESTAMP()),
+  ('flag-gp', UNIX_TIMESTAMP()),
+  ('flag-gq', UNIX_TIMESTAMP()),
+  ('flag-gr', UNIX_TIMESTAMP()),
+  ('flag-gs', UNIX_TIMESTAMP()),
+  ('flag-gt', UNIX_TIMESTAMP()),
+  ('flag-gu', UNIX_TIMESTAMP()),
+  ('flag-gw', UNIX_TIMESTAMP()),
+  ('flag-gy', UNIX_TIMESTAMP()),
+  ('flag-hk', UNIX_TIMESTAMP()),
+  ('flag-hm', UNIX_TIMESTAMP()),
+  ('flag-hn', UNIX_TIMESTAMP()),
+  ('flag-hr', UNIX_TIMESTAMP()),
+  ('flag-ht', UNIX_TIMESTAMP()),
+  ('flag-hu', UNIX_TIMESTAMP()),
+  ('flag-ic', UNIX_TIMESTAMP()),
+  ('flag-id', UNIX_TIMESTAMP()),
+  ('flag-ie', UNIX_TIMESTAMP()),
+  ('flag-il', UNIX_TIMESTAMP()),
+  ('flag-im', UNIX_TIMESTAMP()),
+  ('flag-in', UNIX_TIMESTAMP()),
+  ('flag-io', UNIX_TIMESTAMP()),
+  ('flag-iq', UNIX_TIMESTAMP()),
+  ('flag-ir', UNIX_TIMESTAMP()),
+  ('flag-is', UNIX_TIMESTAMP()),
+  ('flag-je', UNIX_TIMESTAMP()),
+  ('flag-jm', UNIX_TIMESTAMP()),
+  ('flag-jo', UNIX_TIMESTAMP()),
+  ('flag-ke', UNIX_TIMESTAMP()),
+  ('flag-kg', UNIX_TIMESTAMP()),
+  ('flag-kh', UNIX_TIMESTAMP()),
+  ('flag-ki', UNIX_TIMESTAMP()),
+  ('flag-km', UNIX_TIMESTAMP()),
+  ('flag-kn', UNIX_TIMESTAMP()),
+  ('flag-kp', UNIX_TIMESTAMP()),
+  ('flag-kw', UNIX_TIMESTAMP()),
+  ('flag-ky', UNIX_TIMESTAMP()),
+  ('flag-kz', UNIX_TIMESTAMP()),
+  ('flag-la', UNIX_TIMESTAMP()),
+  ('flag-lb', UNIX_TIMESTAMP()),
+  ('flag-lc', UNIX_TIMESTAMP()),
+  ('flag-li', UNIX_TIMESTAMP()),
+  ('flag-lk', UNIX_TIMESTAMP()),
+  ('flag-lr', UNIX_TIMESTAMP()),
+  ('flag-ls', UNIX_TIMESTAMP()),
+  ('flag-lt', UNIX_TIMESTAMP()),
+  ('flag-lu', UNIX_TIMESTAMP()),
+  ('flag-lv', UNIX_TIMESTAMP()),
+  ('flag-ly', UNIX_TIMESTAMP()),
+  ('flag-ma', UNIX_TIMESTAMP()),
+  ('flag-mc', UNIX_TIMESTAMP()),
+  ('flag-md', UNIX_TIMESTAMP()),
+  ('flag-me', UNIX_TIMESTAMP()),
+  ('flag-mf', UNIX_TIMESTAMP()),
+  ('flag-mg', UNIX_TIMESTAMP()),
+  ('flag-mh', UNIX_TIMESTAMP()),
+  ('flag-mk', UNIX_TIMESTAMP()),
+  ('flag-ml', UNIX_TIMESTAMP()),
+  ('flag-mm', UNIX_TIMESTAMP()),
+  ('flag-mn', UNIX_TIMESTAMP()),
+  ('flag-mo', UNIX_TIMESTAMP()),
+  ('flag-mp', UNIX_TIMESTAMP()),
+  ('flag-mq', UNIX_TIMESTAMP()),
+  ('flag-mr', UNIX_TIMESTAMP()),
+  ('flag-ms', UNIX_TIMESTAMP()),
+  ('flag-mt', UNIX_TIMESTAMP()),
+  ('flag-mu', UNIX_TIMESTAMP()),
+  ('flag-mv', UNIX_TIMESTAMP()),
+  ('flag-mw', UNIX_TIMESTAMP()),
+  ('flag-mx', UNIX_TIMESTAMP()),
+  ('flag-my', UNIX_TIMESTAMP()),
+  ('flag-mz', UNIX_TIMESTAMP()),
+  ('flag-na', UNIX_TIMESTAMP()),
+  ('flag-nc', UNIX_TIMESTAMP()),
+  ('flag-ne', UNIX_TIMESTAMP()),
+  ('flag-nf', UNIX_TIMESTAMP()),
+  ('flag-ng', UNIX_TIMESTAMP()),
+  ('flag-ni', UNIX_TIMESTAMP()),
+  ('flag-nl', UNIX_TIMESTAMP()),
+  ('flag-no', UNIX_TIMESTAMP()),
+  ('flag-np', UNIX_TIMESTAMP()),
+  ('flag-nr', UNIX_TIMESTAMP()),
+  ('flag-nu', UNIX_TIMESTAMP()),
+  ('flag-nz', UNIX_TIMESTAMP()),
+  ('flag-om', UNIX_TIMESTAMP()),
+  ('flag-pa', UNIX_TIMESTAMP()),
+  ('flag-pe', UNIX_TIMESTAMP()),
+  ('flag-pf', UNIX_TIMESTAMP()),
+  ('flag-pg', UNIX_TIMESTAMP()),
+  ('flag-ph', UNIX_TIMESTAMP()),
+  ('flag-pk', UNIX_TIMESTAMP()),
+  ('flag-pl', UNIX_TIMESTAMP()),
+  ('flag-pm', UNIX_TIMESTAMP()),
+  ('flag-pn', UNIX_TIMESTAMP()),
+  ('flag-pr', UNIX_TIMESTAMP()),
+  ('flag-ps', UNIX_TIMESTAMP()),
+  ('flag-pt', UNIX_TIMESTAMP()),
+  ('flag-pw', UNIX_TIMESTAMP()),
+  ('flag-py', UNIX_TIMESTAMP()),
+  ('flag-qa', UNIX_TIMESTAMP()),
+  ('flag-re', UNIX_TIMESTAMP()),
+  ('flag-ro', UNIX_TIMESTAMP()),
+  ('flag-rs', UNIX_TIMESTAMP()),
+  ('flag-rw', UNIX_TIMESTAMP()),
+  ('flag-sa', UNIX_TIMESTAMP()),
+  ('flag-sb', UNIX_TIMESTAMP()),
+  ('flag-sc', UNIX_TIMESTAMP()),
+  ('flag-scotland', UNIX_TIMESTAMP()),
+  ('flag-sd', UNIX_TIMESTAMP()),
+  ('flag-se', UNIX_TIMESTAMP()),
+  ('flag-sg', UNIX_TIMESTAMP()),
+  ('flag-sh', UNIX_TIMESTAMP()),
+  ('flag-si', UNIX_TIMESTAMP()),
+  ('flag-sj', UNIX_TIMESTAMP()),
+  ('flag-sk', UNIX_TIMESTAMP()),
+  ('flag-sl', UNIX_TIMESTAMP()),
+  ('flag-sm', UNIX_TIMESTAMP()),
+  ('flag-sn', UNIX_TIMESTAMP()),
+  ('flag-so', UNIX_TIMESTAMP()),
+  ('flag-sr', UNIX_TIMESTAMP()),
+  ('flag-ss', UNIX_TIMESTAMP()),
+  ('flag-st', UNIX_TIMESTAMP()),
+  ('flag-sv', UNIX_TIMESTAMP()),
+  ('flag-sx', UNIX_TIMESTAMP()),
+  ('flag-sy', UNIX_TIMESTAMP()),
+  ('flag-sz', UNIX_TIMESTAMP()),
+  ('flag-ta', UNIX_TIMESTAMP()),
+  ('flag-tc', UNIX_TIMESTAMP()),
+  ('flag-td', UNIX_TIMESTAMP()),
+  ('flag-tf', UNIX_TIMESTAMP()),
+  ('flag-tg', UNIX_TIMESTAMP()),
+  ('flag-th', UNIX_TIMESTAMP()),
+  ('flag-tj', UNIX_TIMESTAMP()),
+  ('flag-tk', UNIX_TIMESTAMP()),
+  ('flag-tl', UNIX_TIMESTAMP()),
+  ('flag-tm', UNIX_TIMESTAMP()),
+  ('flag-tn', UNIX_TIMESTAMP()),
+  ('flag-to', UNIX_TIMESTAMP()),
+  ('flag-tr', UNIX_TIMESTAMP()),
+  ('flag-tt', UNIX_TIMESTAMP()),
+  ('flag-tv', UNIX_TIMESTAMP()),
+  ('flag-tw', UNIX_TIMESTAMP()),
+  ('flag-tz', UNIX_TIMESTAMP()),
+  ('flag-ua', UNIX_TIMESTAMP()),
+  ('flag-ug', UNIX_TIMESTAMP()),
+  ('flag-um', UNIX_TIMESTAMP()),
+  ('flag-un', UNIX_TIMESTAMP()),
+  ('flag-uy', UNIX_TIMESTAMP()),
+  ('flag-uz', UNIX_TIMESTAMP()),
+  ('flag-va', UNIX_TIMESTAMP()),
+  ('flag-vc', UNIX_TIMESTAMP()),
+  ('flag-ve', UNIX_TIMESTAMP()),
+  ('flag-vg', UNIX_TIMESTAMP()),
+  ('flag-vi', UNIX_TIMESTAMP()),
+  ('flag-vn', UNIX_TIMESTAMP()),
+  ('flag-vu', UNIX_TIMESTAMP()),
+  ('flag-wales', UNIX_TIMESTAMP()),
+  ('flag-wf', UNIX_TIMESTAMP()),
+  ('flag-ws', UNIX_TIMESTAMP()),
+  ('flag-xk', UNIX_TIMESTAMP()),
+  ('flag-ye', UNIX_TIMESTAMP()),
+  ('flag-yt', UNIX_TIMESTAMP()),
+  ('flag-za', UNIX_TIMESTAMP()),
+  ('flag-zm', UNIX_TIMESTAMP()),
+  ('flag-zw', UNIX_TIMESTAMP()),
+  ('flags', UNIX_TIMESTAMP()),
+  ('flamingo', UNIX_TIMESTAMP()),
+  ('flashlight', UNIX_TIMESTAMP()),
+  ('flatbread', UNIX_TIMESTAMP()),
+  ('fleur_de_lis', UNIX_TIMESTAMP()),
+  ('floppy_disk', UNIX_TIMESTAMP()),
+  ('flower_playing_cards', UNIX_TIMESTAMP()),
+  ('flushed', UNIX_TIMESTAMP()),
+  ('fly', UNIX_TIMESTAMP()),
+  ('flying_disc', UNIX_TIMESTAMP()),
+  ('flying_saucer', UNIX_TIMESTAMP()),
+  ('fog', UNIX_TIMESTAMP()),
+  ('foggy', UNIX_TIMESTAMP()),
+  ('fondue', UNIX_TIMESTAMP()),
+  ('foot', UNIX_TIMESTAMP()),
+  ('football', UNIX_TIMESTAMP()),
+  ('footprints', UNIX_TIMESTAMP()),
+  ('fork_and_knife', UNIX_TIMESTAMP()),
+  ('fortune_cookie', UNIX_TIMESTAMP()),
+  ('fountain', UNIX_TIMESTAMP()),
+  ('four', UNIX_TIMESTAMP()),
+  ('four_leaf_clover', UNIX_TIMESTAMP()),
+  ('fox_face', UNIX_TIMESTAMP()),
+  ('fr', UNIX_TIMESTAMP()),
+  ('frame_with_picture', UNIX_TIMESTAMP()),
+  ('free', UNIX_TIMESTAMP()),
+  ('fried_egg', UNIX_TIMESTAMP()),
+  ('fried_shrimp', UNIX_TIMESTAMP()),
+  ('fries', UNIX_TIMESTAMP()),
+  ('frog', UNIX_TIMESTAMP()),
+  ('frowning', UNIX_TIMESTAMP()),
+  ('fuelpump', UNIX_TIMESTAMP()),
+  ('full_moon', UNIX_TIMESTAMP()),
+  ('full_moon_with_face', UNIX_TIMESTAMP()),
+  ('funeral_urn', UNIX_TIMESTAMP()),
+  ('game_die', UNIX_TIMESTAMP()),
+  ('garlic', UNIX_TIMESTAMP()),
+  ('gb', UNIX_TIMESTAMP()),
+  ('gear', UNIX_TIMESTAMP()),
+  ('gem', UNIX_TIMESTAMP()),
+  ('gemini', UNIX_TIMESTAMP()),
+  ('genie', UNIX_TIMESTAMP()),
+  ('ghost', UNIX_TIMESTAMP()),
+  ('gift', UNIX_TIMESTAMP()),
+  ('gift_heart', UNIX_TIMESTAMP()),
+  ('giraffe_face', UNIX_TIMESTAMP()),
+  ('girl', UNIX_TIMESTAMP()),
+  ('glass_of_milk', UNIX_TIMESTAMP()),
+  ('globe_with_meridians', UNIX_TIMESTAMP()),
+  ('gloves', UNIX_TIMESTAMP()),
+  ('goal_net', UNIX_TIMESTAMP()),
+  ('goat', UNIX_TIMESTAMP()),
+  ('goggles', UNIX_TIMESTAMP()),
+  ('golf', UNIX_TIMESTAMP()),
+  ('golfer', UNIX_TIMESTAMP()),
+  ('gorilla', UNIX_TIMESTAMP()),
+  ('grapes', UNIX_TIMESTAMP()),
+  ('green_apple', UNIX_TIMESTAMP()),
+  ('green_book', UNIX_TIMESTAMP()),
+  ('green_heart', UNIX_TIMESTAMP()),
+  ('green_salad', UNIX_TIMESTAMP()),
+  ('grey_exclamation', UNIX_TIMESTAMP()),
+  ('grey_question', UNIX_TIMESTAMP()),
+  ('grimacing', UNIX_TIMESTAMP()),
+  ('grin', UNIX_TIMESTAMP()),
+  ('grinning', UNIX_TIMESTAMP()),
+  ('guardsman', UNIX_TIMESTAMP()),
+  ('guide_dog', UNIX_TIMESTAMP()),
+  ('guitar', UNIX_TIMESTAMP()),
+  ('gun', UNIX_TIMESTAMP()),
+  ('haircut', UNIX_TIMESTAMP()),
+  ('hamburger', UNIX_TIMESTAMP()),
+  ('hammer', UNIX_TIMESTAMP()),
+  ('hammer_and_pick', UNIX_TIMESTAMP()),
+  ('hammer_and_wrench', UNIX_TIMESTAMP()),
+  ('hamster', UNIX_TIMESTAMP()),
+  ('hand', UNIX_TIMESTAMP()),
+  ('handbag', UNIX_TIMESTAMP()),
+  ('handball', UNIX_TIMESTAMP()),
+  ('handshake', UNIX_TIMESTAMP()),
+  ('hankey', UNIX_TIMESTAMP()),
+  ('hash', UNIX_TIMESTAMP()),
+  ('hatched_chick', UNIX_TIMESTAMP()),
+  ('hatching_chick', UNIX_TIMESTAMP()),
+  ('headphones', UNIX_TIMESTAMP()),
+  ('headstone', UNIX_TIMESTAMP()),
+  ('health_worker', UNIX_TIMESTAMP()),
+  ('hear_no_evil', UNIX_TIMESTAMP()),
+  ('heart', UNIX_TIMESTAMP()),
+  ('heart_decoration', UNIX_TIMESTAMP()),
+  ('heart_eyes', UNIX_TIMESTAMP()),
+  ('heart_eyes_cat', UNIX_TIMESTAMP()),
+  ('heart_on_fire', UNIX_TIMESTAMP()),
+  ('heartbeat', UNIX_TIMESTAMP()),
+  ('heartpulse', UNIX_TIMESTAMP()),
+  ('hearts', UNIX_TIMESTAMP()),
+  ('heavy_check_mark', UNIX_TIMESTAMP()),
+  ('heavy_division_sign', UNIX_TIMESTAMP()),
+  ('heavy_dollar_sign', UNIX_TIMESTAMP()),
+  ('heavy_heart_exclamation_mark_ornament', UNIX_TIMESTAMP()),
+  ('heavy_minus_sign', UNIX_TIMESTAMP()),
+  ('heavy_multiplication_x', UNIX_TIMESTAMP()),
+  ('heavy_plus_sign', UNIX_TIMESTAMP()),
+  ('hedgehog', UNIX_TIMESTAMP()),
+  ('helicopter', UNIX_TIMESTAMP()),
+  ('helmet_with_white_cross', UNIX_TIMESTAMP()),
+  ('herb', UNIX_TIMESTAMP()),
+  ('hibiscus', UNIX_TIMESTAMP()),
+  ('high_brightness', UNIX_TIMESTAMP()),
+  ('high_heel', UNIX_TIMESTAMP()),
+  ('hiking_boot', UNIX_TIMESTAMP()),
+  ('hindu_temple', UNIX_TIMESTAMP()),
+  ('hippopotamus', UNIX_TIMESTAMP()),
+  ('hocho', UNIX_TIMESTAMP()),
+  ('hole', UNIX_TIMESTAMP()),
+  ('honey_pot', UNIX_TIMESTAMP()),
+  ('hook', UNIX_TIMESTAMP()),
+  ('horse', UNIX_TIMESTAMP()),
+  ('horse_racing', UNIX_TIMESTAMP()),
+  ('hospital', UNIX_TIMESTAMP()),
+  ('hot_face', UNIX_TIMESTAMP()),
+  ('hot_pepper', UNIX_TIMESTAMP()),
+  ('hotdog', UNIX_TIMESTAMP()),
+  ('hotel', UNIX_TIMESTAMP()),
+  ('hotsprings', UNIX_TIMESTAMP()),
+  ('hourglass', UNIX_TIMESTAMP()),
+  ('hourglass_flowing_sand', UNIX_TIMESTAMP()),
+  ('house', UNIX_TIMESTAMP()),
+  ('house_buildings', UNIX_TIMESTAMP()),
+  ('house_with_garden', UNIX_TIMESTAMP()),
+  ('hugging_face', UNIX_TIMESTAMP()),
+  ('hushed', UNIX_TIMESTAMP()),
+  ('hut', UNIX_TIMESTAMP()),
+  ('i_love_you_hand_sign', UNIX_TIMESTAMP()),
+  ('ice_cream', UNIX_TIMESTAMP()),
+  ('ice_cube', UNIX_TIMESTAMP()),
+  ('ice_hockey_stick_and_puck', UNIX_TIMESTAMP()),
+  ('ice_skate', UNIX_TIMESTAMP()),
+  ('icecream', UNIX_TIMESTAMP()),
+  ('id', UNIX_TIMESTAMP()),
+  ('ideograph_advantage', UNIX_TIMESTAMP()),
+  ('imp', UNIX_TIMESTAMP()),
+  ('inbox_tray', UNIX_TIMESTAMP()),
+  ('incoming_envelope', UNIX_TIMESTAMP()),
+  ('infinity', UNIX_TIMESTAMP()),
+  ('information_desk_person', UNIX_TIMESTAMP()),
+  ('information_source', UNIX_TIMESTAMP()),
+  ('innocent', UNIX_TIMESTAMP()),
+  ('interrobang', UNIX_TIMESTAMP()),
+  ('iphone', UNIX_TIMESTAMP()),
+  ('isu', UNIX_TIMESTAMP()),
+  ('it', UNIX_TIMESTAMP()),
+  ('izakaya_lantern', UNIX_TIMESTAMP()),
+  ('jack_o_lantern', UNIX_TIMESTAMP()),
+  ('japan', UNIX_TIMESTAMP()),
+  ('japanese_castle', UNIX_TIMESTAMP()),
+  ('japanese_goblin', UNIX_TIMESTAMP()),
+  ('japanese_ogre', UNIX_TIMESTAMP()),
+  ('jeans', UNIX_TIMESTAMP()),
+  ('jigsaw', UNIX_TIMESTAMP()),
+  ('joy', UNIX_TIMESTAMP()),
+  ('joy_cat', UNIX_TIMESTAMP()),
+  ('joystick', UNIX_TIMESTAMP()),
+  ('jp', UNIX_TIMESTAMP()),
+  ('judge', UNIX_TIMESTAMP()),
+  ('juggling', UNIX_TIMESTAMP()),
+  ('kaaba', UNIX_TIMESTAMP()),
+  ('kangaroo', UNIX_TIMESTAMP()),
+  ('key', UNIX_TIMESTAMP()),
+  ('keyboard', UNIX_TIMESTAMP()),
+  ('keycap_star', UNIX_TIMESTAMP()),
+  ('keycap_ten', UNIX_TIMESTAMP()),
+  ('kimono', UNIX_TIMESTAMP()),
+  ('kiss', UNIX_TIMESTAMP()),
+  ('kissing', UNIX_TIMESTAMP()),
+  ('kissing_cat', UNIX_TIMESTAMP()),
+  ('kissing_closed_eyes', UNIX_TIMESTAMP()),
+  ('kissing_heart', UNIX_TIMESTAMP()),
+  ('kissing_smiling_eyes', UNIX_TIMESTAMP()),
+  ('kite', UNIX_TIMESTAMP()),
+  ('kiwifruit', UNIX_TIMESTAMP()),
+  ('kneeling_person', UNIX_TIMESTAMP()),
+  ('knife_fork_plate', UNIX_TIMESTAMP()),
+  ('knot', UNIX_TIMESTAMP()),
+  ('koala', UNIX_TIMESTAMP()),
+  ('koko', UNIX_TIMESTAMP()),
+  ('kr', UNIX_TIMESTAMP()),
+  ('lab_coat', UNIX_TIMESTAMP()),
+  ('label', UNIX_TIMESTAMP()),
+  ('lacrosse', UNIX_TIMESTAMP()),
+  ('ladder', UNIX_TIMESTAMP()),
+  ('ladybug', UNIX_TIMESTAMP()),
+  ('large_blue_circle', UNIX_TIMESTAMP()),
+  ('large_blue_diamond', UNIX_TIMESTAMP()),
+  ('large_blue_square', UNIX_TIMESTAMP()),
+  ('large_brown_circle', UNIX_TIMESTAMP()),
+  ('large_brown_square', UNIX_TIMESTAMP()),
+  ('large_green_circle', UNIX_TIMESTAMP()),
+  ('large_green_square', UNIX_TIMESTAMP()),
+  ('large_orange_circle', UNIX_TIMESTAMP()),
+  ('large_orange_diamond', UNIX_TIMESTAMP()),
+  ('large_orange_square', UNIX_TIMESTAMP()),
+  ('large_purple_circle', UNIX_TIMESTAMP()),
+  ('large_purple_square', UNIX_TIMESTAMP()),
+  ('large_red_square', UNIX_TIMESTAMP()),
+  ('large_yellow_circle', UNIX_TIMESTAMP()),
+  ('large_yellow_square', UNIX_TIMESTAMP()),
+  ('last_quarter_moon', UNIX_TIMESTAMP()),
+  ('last_quarter_moon_with_face', UNIX_TIMESTAMP()),
+  ('latin_cross', UNIX_TIMESTAMP()),
+  ('laughing', UNIX_TIMESTAMP()),
+  ('leafy_green', UNIX_TIMESTAMP()),
+  ('leaves', UNIX_TIMESTAMP()),
+  ('ledger', UNIX_TIMESTAMP()),
+  ('left-facing_fist', UNIX_TIMESTAMP()),
+  ('left_luggage', UNIX_TIMESTAMP()),
+  ('left_right_arrow', UNIX_TIMESTAMP()),
+  ('left_speech_bubble', UNIX_TIMESTAMP()),
+  ('leftwards_arrow_with_hook', UNIX_TIMESTAMP()),
+  ('leg', UNIX_TIMESTAMP()),
+  ('lemon', UNIX_TIMESTAMP()),
+  ('leo', UNIX_TIMESTAMP()),
+  ('leopard', UNIX_TIMESTAMP()),
+  ('level_slider', UNIX_TIMESTAMP()),
+  ('libra', UNIX_TIMESTAMP()),
+  ('light_rail', UNIX_TIMESTAMP()),
+  ('lightning', UNIX_TIMESTAMP()),
+  ('link', UNIX_TIMESTAMP()),
+  ('linked_paperclips', UNIX_TIMESTAMP()),
+  ('lion_face', UNIX_TIMESTAMP()),
+  ('lips', UNIX_TIMESTAMP()),
+  ('lipstick', UNIX_TIMESTAMP()),
+  ('lizard', UNIX_TIMESTAMP()),
+  ('llama', UNIX_TIMESTAMP()),
+  ('lobster', UNIX_TIMESTAMP()),
+  ('lock', UNIX_TIMESTAMP()),
+  ('lock_with_ink_pen', UNIX_TIMESTAMP()),
+  ('lollipop', UNIX_TIMESTAMP()),
+  ('long_drum', UNIX_TIMESTAMP()),
+  ('loop', UNIX_TIMESTAMP()),
+  ('lotion_bottle', UNIX_TIMESTAMP()),
+  ('loud_sound', UNIX_TIMESTAMP()),
+  ('loudspeaker', UNIX_TIMESTAMP()),
+  ('love_hotel', UNIX_TIMESTAMP()),
+  ('love_letter', UNIX_TIMESTAMP()),
+  ('low_brightness', UNIX_TIMESTAMP()),
+  ('lower_left_ballpoint_pen', UNIX_TIMESTAMP()),
+  ('lower_left_crayon', UNIX_TIMESTAMP()),
+  ('lower_left_fountain_pen', UNIX_TIMESTAMP()),
+  ('lower_left_paintbrush', UNIX_TIMESTAMP()),
+  ('luggage', UNIX_TIMESTAMP()),
+  ('lungs', UNIX_TIMESTAMP()),
+  ('lying_face', UNIX_TIMESTAMP()),
+  ('m', UNIX_TIMESTAMP()),
+  ('mag', UNIX_TIMESTAMP()),
+  ('mag_right', UNIX_TIMESTAMP()),
+  ('mage', UNIX_TIMESTAMP()),
+  ('magic_wand', UNIX_TIMESTAMP()),
+  ('magnet', UNIX_TIMESTAMP()),
+  ('mahjong', UNIX_TIMESTAMP()),
+  ('mailbox', UNIX_TIMESTAMP()),
+  ('mailbox_closed', UNIX_TIMESTAMP()),
+  ('mailbox_with_mail', UNIX_TIMESTAMP()),
+  ('mailbox_with_no_mail', UNIX_TIMESTAMP()),
+  ('male-artist', UNIX_TIMESTAMP()),
+  ('male-astronaut', UNIX_TIMESTAMP()),
+  ('male-construction-worker', UNIX_TIMESTAMP()),
+  ('male-cook', UNIX_TIMESTAMP()),
+  ('male-detective', UNIX_TIMESTAMP()),
+  ('male-doctor', UNIX_TIMESTAMP()),
+  ('male-factory-worker', UNIX_TIMESTAMP()),
+  ('male-farmer', UNIX_TIMESTAMP()),
+  ('male-firefighter', UNIX_TIMESTAMP()),
+  ('male-guard', UNIX_TIMESTAMP()),
+  ('male-judge', UNIX_TIMESTAMP()),
+  ('male-mechanic', UNIX_TIMESTAMP()),
+  ('male-office-worker', UNIX_TIMESTAMP()),
+  ('male-pilot', UNIX_TIMESTAMP()),
+  ('male-police-officer', UNIX_TIMESTAMP()),
+  ('male-scientist', UNIX_TIMESTAMP()),
+  ('male-singer', UNIX_TIMESTAMP()),
+  ('male-student', UNIX_TIMESTAMP()),
+  ('male-teacher', UNIX_TIMESTAMP()),
+  ('male-technologist', UNIX_TIMESTAMP()),
+  ('male_elf', UNIX_TIMESTAMP()),
+  ('male_fairy', UNIX_TIMESTAMP()),
+  ('male_genie', UNIX_TIMESTAMP()),
+  ('male_mage', UNIX_TIMESTAMP()),
+  ('male_sign', UNIX_TIMESTAMP()),
+  ('male_superhero', UNIX_TIMESTAMP()),
+  ('male_supervillain', UNIX_TIMESTAMP()),
+  ('male_vampire', UNIX_TIMESTAMP()),
+  ('male_zombie', UNIX_TIMESTAMP()),
+  ('mammoth', UNIX_TIMESTAMP()),
+  ('man', UNIX_TIMESTAMP()),
+  ('man-biking', UNIX_TIMESTAMP()),
+  ('man-bouncing-ball', UNIX_TIMESTAMP()),
+  ('man-bowing', UNIX_TIMESTAMP()),
+  ('man-boy', UNIX_TIMESTAMP()),
+  ('man-boy-boy', UNIX_TIMESTAMP()),
+  ('man-cartwheeling', UNIX_TIMESTAMP()),
+  ('man-facepalming', UNIX_TIMESTAMP()),
+  ('man-frowning', UNIX_TIMESTAMP()),
+  ('man-gesturing-no', UNIX_TIMESTAMP()),
+  ('man-gesturing-ok', UNIX_TIMESTAMP()),
+  ('man-getting-haircut', UNIX_TIMESTAMP()),
+  ('man-getting-massage', UNIX_TIMESTAMP()),
+  ('man-girl', UNIX_TIMESTAMP()),
+  ('man-girl-boy', UNIX_TIMESTAMP()),
+  ('man-girl-girl', UNIX_TIMESTAMP()),
+  ('man-golfing', UNIX_TIMESTAMP()),
+  ('man-heart-man', UNIX_TIMESTAMP()),
+  ('man-juggling', UNIX_TIMESTAMP()),
+  ('man-kiss-man', UNIX_TIMESTAMP()),
+  ('man-lifting-weights', UNIX_TIMESTAMP()),
+  ('man-man-boy', UNIX_TIMESTAMP()),
+  ('man-man-boy-boy', UNIX_TIMESTAMP()),
+  ('man-man-girl', UNIX_TIMESTAMP()),
+  ('man-man-girl-boy', UNIX_TIMESTAMP()),
+  ('man-man-girl-girl', UNIX_TIMESTAMP()),
+  ('man-mountain-biking', UNIX_TIMESTAMP()),
+  ('man-playing-handball', UNIX_TIMESTAMP()),
+  ('man-playing-water-polo', UNIX_TIMESTAMP()),
+  ('man-pouting', UNIX_TIMESTAMP()),
+  ('man-raising-hand', UNIX_TIMESTAMP()),
+  ('man-rowing-boat', UNIX_TIMESTAMP()),
+  ('man-running', UNIX_TIMESTAMP()),
+  ('man-shrugging', UNIX_TIMESTAMP()),
+  ('man-surfing', UNIX_TIMESTAMP()),
+  ('man-swimming', UNIX_TIMESTAMP()),
+  ('man-tipping-hand', UNIX_TIMESTAMP()),
+  ('man-walking', UNIX_TIMESTAMP()),
+  ('man-wearing-turban', UNIX_TIMESTAMP()),
+  ('man-woman-boy', UNIX_TIMESTAMP()),
+  ('man-woman-boy-boy', UNIX_TIMESTAMP()),
+  ('man-woman-girl', UNIX_TIMESTAMP()),
+  ('man-woman-girl-boy', UNIX_TIMESTAMP()),
+  ('man-woman-girl-girl', UNIX_TIMESTAMP()),
+  ('man-wrestling', UNIX_TIMESTAMP()),
+  ('man_and_woman_holding_hands', UNIX_TIMESTAMP()),
+  ('man_climbing', UNIX_TIMESTAMP()),
+  ('man_dancing', UNIX_TIMESTAMP()),
+  ('man_feeding_baby', UNIX_TIMESTAMP()),
+  ('man_in_business_suit_levitating', UNIX_TIMESTAMP()),
+  ('man_in_lotus_position', UNIX_TIMESTAMP()),
+  ('man_in_manual_wheelchair', UNIX_TIMESTAMP()),
+  ('man_in_motorized_wheelchair', UNIX_TIMESTAMP()),
+  ('man_in_steamy_room', UNIX_TIMESTAMP()),
+  ('man_in_tuxedo', UNIX_TIMESTAMP()),
+  ('man_kneeling', UNIX_TIMESTAMP()),
+  ('man_standing', UNIX_TIMESTAMP()),
+  ('man_with_beard', UNIX_TIMESTAMP()),
+  ('man_with_gua_pi_mao', UNIX_TIMESTAMP()),
+  ('man_with_probing_cane', UNIX_TIMESTAMP()),
+  ('man_with_turban', UNIX_TIMESTAMP()),
+  ('man_with_veil', UNIX_TIMESTAMP()),
+  ('mango', UNIX_TIMESTAMP()),
+  ('mans_shoe', UNIX_TIMESTAMP()),
+  ('mantelpiece_clock', UNIX_TIMESTAMP()),
+  ('manual_wheelchair', UNIX_TIMESTAMP()),
+  ('maple_leaf', UNIX_TIMESTAMP()),
+  ('martial_arts_uniform', UNIX_TIMESTAMP()),
+  ('mask', UNIX_TIMESTAMP()),
+  ('massage', UNIX_TIMESTAMP()),
+  ('mate_drink', UNIX_TIMESTAMP()),
+  ('meat_on_bone', UNIX_TIMESTAMP()),
+  ('mechanic', UNIX_TIMESTAMP()),
+  ('mechanical_arm', UNIX_TIMESTAMP()),
+  ('mechanical_leg', UNIX_TIMESTAMP()),
+  ('medal', UNIX_TIMESTAMP()),
+  ('medical_symbol', UNIX_TIMESTAMP()),
+  ('mega', UNIX_TIMESTAMP()),
+  ('melon', UNIX_TIMESTAMP()),
+  ('memo', UNIX_TIMESTAMP()),
+  ('men-with-bunny-ears-partying', UNIX_TIMESTAMP()),
+  ('mending_heart', UNIX_TIMESTAMP()),
+  ('menorah_with_nine_branches', UNIX_TIMESTAMP()),
+  ('mens', UNIX_TIMESTAMP()),
+  ('mermaid', UNIX_TIMESTAMP()),
+  ('merman', UNIX_TIMESTAMP()),
+  ('merperson', UNIX_TIMESTAMP()),
+  ('metro', UNIX_TIMESTAMP()),
+  ('microbe', UNIX_TIMESTAMP()),
+  ('microphone', UNIX_TIMESTAMP()),
+  ('microscope', UNIX_TIMESTAMP()),
+  ('middle_finger', UNIX_TIMESTAMP()),
+  ('military_helmet', UNIX_TIMESTAMP()),
+  ('milky_way', UNIX_TIMESTAMP()),
+  ('minibus', UNIX_TIMESTAMP()),
+  ('minidisc', UNIX_TIMESTAMP()),
+  ('mirror', UNIX_TIMESTAMP()),
+  ('mobile_phone_off', UNIX_TIMESTAMP()),
+  ('money_mouth_face', UNIX_TIMESTAMP()),
+  ('money_with_wings', UNIX_TIMESTAMP()),
+  ('moneybag', UNIX_TIMESTAMP()),
+  ('monkey', UNIX_TIMESTAMP()),
+  ('monkey_face', UNIX_TIMESTAMP()),
+  ('monorail', UNIX_TIMESTAMP()),
+  ('moon', UNIX_TIMESTAMP()),
+  ('moon_cake', UNIX_TIMESTAMP()),
+  ('mortar_board', UNIX_TIMESTAMP()),
+  ('mosque', UNIX_TIMESTAMP()),
+  ('mosquito', UNIX_TIMESTAMP()),
+  ('mostly_sunny', UNIX_TIMESTAMP()),
+  ('motor_boat', UNIX_TIMESTAMP()),
+  ('motor_scooter', UNIX_TIMESTAMP()),
+  ('motorized_wheelchair', UNIX_TIMESTAMP()),
+  ('motorway', UNIX_TIMESTAMP()),
+  ('mount_fuji', UNIX_TIMESTAMP()),
+  ('mountain', UNIX_TIMESTAMP()),
+  ('mountain_bicyclist', UNIX_TIMESTAMP()),
+  ('mountain_cableway', UNIX_TIMESTAMP()),
+  ('mountain_railway', UNIX_TIMESTAMP()),
+  ('mouse', UNIX_TIMESTAMP()),
+  ('mouse2', UNIX_TIMESTAMP()),
+  ('mouse_trap', UNIX_TIMESTAMP()),
+  ('movie_camera', UNIX_TIMESTAMP()),
+  ('moyai', UNIX_TIMESTAMP()),
+  ('mrs_claus', UNIX_TIMESTAMP()),
+  ('muscle', UNIX_TIMESTAMP()),
+  ('mushroom', UNIX_TIMESTAMP()),
+  ('musical_keyboard', UNIX_TIMESTAMP()),
+  ('musical_note', UNIX_TIMESTAMP()),
+  ('musical_score', UNIX_TIMESTAMP()),
+  ('mute', UNIX_TIMESTAMP()),
+  ('mx_claus', UNIX_TIMESTAMP()),
+  ('nail_care', UNIX_TIMESTAMP()),
+  ('name_badge', UNIX_TIMESTAMP()),
+  ('national_park', UNIX_TIMESTAMP()),
+  ('nauseated_face', UNIX_TIMESTAMP()),
+  ('nazar_amulet', UNIX_TIMESTAMP()),
+  ('necktie', UNIX_TIMESTAMP()),
+  ('negative_squared_cross_mark', UNIX_TIMESTAMP()),
+  ('nerd_face', UNIX_TIMESTAMP()),
+  ('nesting_dolls', UNIX_TIMESTAMP()),
+  ('neutral_face', UNIX_TIMESTAMP()),
+  ('new', UNIX_TIMESTAMP()),
+  ('new_moon', UNIX_TIMESTAMP()),
+  ('new_moon_with_face', UNIX_TIMESTAMP()),
+  ('newspaper', UNIX_TIMESTAMP()),
+  ('ng', UNIX_TIMESTAMP()),
+  ('night_with_stars', UNIX_TIMESTAMP()),
+  ('nine', UNIX_TIMESTAMP()),
+  ('ninja', UNIX_TIMESTAMP()),
+  ('no_bell', UNIX_TIMESTAMP()),
+  ('no_bicycles', UNIX_TIMESTAMP()),
+  ('no_entry', UNIX_TIMESTAMP()),
+  ('no_entry_sign', UNIX_TIMESTAMP()),
+  ('no_good', UNIX_TIMESTAMP()),
+  ('no_mobile_phones', UNIX_TIMESTAMP()),
+  ('no_mouth', UNIX_TIMESTAMP()),
+  ('no_pedestrians', UNIX_TIMESTAMP()),
+  ('no_smoking', UNIX_TIMESTAMP()),
+  ('non-potable_water', UNIX_TIMESTAMP()),
+  ('nose', UNIX_TIMESTAMP()),
+  ('notebook', UNIX_TIMESTAMP()),
+  ('notebook_with_decorative_cover', UNIX_TIMESTAMP()),
+  ('notes', UNIX_TIMESTAMP()),
+  ('nut_and_bolt', UNIX_TIMESTAMP()),
+  ('o', UNIX_TIMESTAMP()),
+  ('o2', UNIX_TIMESTAMP()),
+  ('ocean', UNIX_TIMESTAMP()),
+  ('octagonal_sign', UNIX_TIMESTAMP()),
+  ('octopus', UNIX_TIMESTAMP()),
+  ('oden', UNIX_TIMESTAMP()),
+  ('office', UNIX_TIMESTAMP()),
+  ('office_worker', UNIX_TIMESTAMP()),
+  ('oil_drum', UNIX_TIMESTAMP()),
+  ('ok', UNIX_TIMESTAMP()),
+  ('ok_hand', UNIX_TIMESTAMP()),
+  ('ok_woman', UNIX_TIMESTAMP()),
+  ('old_key', UNIX_TIMESTAMP()),
+  ('older_adult', UNIX_TIMESTAMP()),
+  ('older_man', UNIX_TIMESTAMP()),
+  ('older_woman', UNIX_TIMESTAMP()),
+  ('olive', UNIX_TIMESTAMP()),
+  ('om_symbol', UNIX_TIMESTAMP()),
+  ('on', UNIX_TIMESTAMP()),
+  ('oncoming_automobile', UNIX_TIMESTAMP()),
+  ('oncoming_bus', UNIX_TIMESTAMP()),
+  ('oncoming_police_car', UNIX_TIMESTAMP()),
+  ('oncoming_taxi', UNIX_TIMESTAMP()),
+  ('one', UNIX_TIMESTAMP()),
+  ('one-piece_swimsuit', UNIX_TIMESTAMP()),
+  ('onion', UNIX_TIMESTAMP()),
+  ('open_file_folder', UNIX_TIMESTAMP()),
+  ('open_hands', UNIX_TIMESTAMP()),
+  ('open_mouth', UNIX_TIMESTAMP()),
+  ('ophiuchus', UNIX_TIMESTAMP()),
+  ('orange_book', UNIX_TIMESTAMP()),
+  ('orange_heart', UNIX_TIMESTAMP()),
+  ('orangutan', UNIX_TIMESTAMP()),
+  ('orthodox_cross', UNIX_TIMESTAMP()),
+  ('otter', UNIX_TIMESTAMP()),
+  ('outbox_tray', UNIX_TIMESTAMP()),
+  ('owl', UNIX_TIMESTAMP()),
+  ('ox', UNIX_TIMESTAMP()),
+  ('oyster', UNIX_TIMESTAMP()),
+  ('package', UNIX_TIMESTAMP()),
+  ('page_facing_up', UNIX_TIMESTAMP()),
+  ('page_with_curl', UNIX_TIMESTAMP()),
+  ('pager', UNIX_TIMESTAMP()),
+  ('palm_tree', UNIX_TIMESTAMP()),
+  ('palms_up_together', UNIX_TIMESTAMP()),
+  ('pancakes', UNIX_TIMESTAMP()),
+  ('panda_face', UNIX_TIMESTAMP()),
+  ('paperclip', UNIX_TIMESTAMP()),
+  ('parachute', UNIX_TIMESTAMP()),
+  ('parking', UNIX_TIMESTAMP()),
+  ('parrot', UNIX_TIMESTAMP()),
+  ('part_alternation_mark', UNIX_TIMESTAMP()),
+  ('partly_sunny', UNIX_TIMESTAMP()),
+  ('partly_sunny_rain', UNIX_TIMESTAMP()),
+  ('partying_face', UNIX_TIMESTAMP()),
+  ('passenger_ship', UNIX_TIMESTAMP()),
+  ('passport_control', UNIX_TIMESTAMP()),
+  ('peace_symbol', UNIX_TIMESTAMP()),
+  ('peach', UNIX_TIMESTAMP()),
+  ('peacock', UNIX_TIMESTAMP()),
+  ('peanuts', UNIX_TIMESTAMP()),
+  ('pear', UNIX_TIMESTAMP()),
+  ('pencil2', UNIX_TIMESTAMP()),
+  ('penguin', UNIX_TIMESTAMP()),
+  ('pensive', UNIX_TIMESTAMP()),
+  ('people_holding_hands', UNIX_TIMESTAMP()),
+  ('people_hugging', UNIX_TIMESTAMP()),
+  ('performing_arts', UNIX_TIMESTAMP()),
+  ('persevere', UNIX_TIMESTAMP()),
+  ('person_climbing', UNIX_TIMESTAMP()),
+  ('person_doing_cartwheel', UNIX_TIMESTAMP()),
+  ('person_feeding_baby', UNIX_TIMESTAMP()),
+  ('person_frowning', UNIX_TIMESTAMP()),
+  ('person_in_lotus_position', UNIX_TIMESTAMP()),
+  ('person_in_manual_wheelchair', UNIX_TIMESTAMP()),
+  ('person_in_motorized_wheelchair', UNIX_TIMESTAMP()),
+  ('person_in_steamy_room', UNIX_TIMESTAMP()),
+  ('person_in_tuxedo', UNIX_TIMESTAMP()),
+  ('person_with_ball', UNIX_TIMESTAMP()),
+  ('person_with_blond_hair', UNIX_TIMESTAMP()),
+  ('person_with_headscarf', UNIX_TIMESTAMP()),
+  ('person_with_pouting_face', UNIX_TIMESTAMP()),
+  ('person_with_probing_cane', UNIX_TIMESTAMP()),
+  ('petri_dish', UNIX_TIMESTAMP()),
+  ('phone', UNIX_TIMESTAMP()),
+  ('pick', UNIX_TIMESTAMP()),
+  ('pickup_truck', UNIX_TIMESTAMP()),
+  ('pie', UNIX_TIMESTAMP()),
+  ('pig', UNIX_TIMESTAMP()),
+  ('pig2', UNIX_TIMESTAMP()),
+  ('pig_nose', UNIX_TIMESTAMP()),
+  ('pill', UNIX_TIMESTAMP()),
+  ('pilot', UNIX_TIMESTAMP()),
+  ('pinata', UNIX_TIMESTAMP()),
+  ('pinched_fingers', UNIX_TIMESTAMP()),
+  ('pinching_hand', UNIX_TIMESTAMP()),
+  ('pineapple', UNIX_TIMESTAMP()),
+  ('pirate_flag', UNIX_TIMESTAMP()),
+  ('pisces', UNIX_TIMESTAMP()),
+  ('pizza', UNIX_TIMESTAMP()),
+  ('placard', UNIX_TIMESTAMP()),
+  ('place_of_worship', UNIX_TIMESTAMP()),
+  ('pleading_face', UNIX_TIMESTAMP()),
+  ('plunger', UNIX_TIMESTAMP()),
+  ('point_down', UNIX_TIMESTAMP()),
+  ('point_left', UNIX_TIMESTAMP()),
+  ('point_right', UNIX_TIMESTAMP()),
+  ('point_up', UNIX_TIMESTAMP()),
+  ('point_up_2', UNIX_TIMESTAMP()),
+  ('polar_bear', UNIX_TIMESTAMP()),
+  ('police_car', UNIX_TIMESTAMP()),
+  ('poodle', UNIX_TIMESTAMP()),
+  ('popcorn', UNIX_TIMESTAMP()),
+  ('post_office', UNIX_TIMESTAMP()),
+  ('postal_horn', UNIX_TIMESTAMP()),
+  ('postbox', UNIX_TIMESTAMP()),
+  ('potable_water', UNIX_TIMESTAMP()),
+  ('potato', UNIX_TIMESTAMP()),
+  ('potted_plant', UNIX_TIMESTAMP()),
+  ('pouch', UNIX_TIMESTAMP()),
+  ('poultry_leg', UNIX_TIMESTAMP()),
+  ('pound', UNIX_TIMESTAMP()),
+  ('pouting_cat', UNIX_TIMESTAMP()),
+  ('pray', UNIX_TIMESTAMP()),
+  ('prayer_beads', UNIX_TIMESTAMP()),
+  ('pregnant_woman', UNIX_TIMESTAMP()),
+  ('pretzel', UNIX_TIMESTAMP()),
+  ('prince', UNIX_TIMESTAMP()),
+  ('princess', UNIX_TIMESTAMP()),
+  ('printer', UNIX_TIMESTAMP()),
+  ('probing_cane', UNIX_TIMESTAMP()),
+  ('purple_heart', UNIX_TIMESTAMP()),
+  ('purse', UNIX_TIMESTAMP()),
+  ('pushpin', UNIX_TIMESTAMP()),
+  ('put_litter_in_its_place', UNIX_TIMESTAMP()),
+  ('question', UNIX_TIMESTAMP()),
+  ('rabbit', UNIX_TIMESTAMP()),
+  ('rabbit2', UNIX_TIMESTAMP()),
+  ('raccoon', UNIX_TIMESTAMP()),
+  ('racehorse', UNIX_TIMESTAMP()),
+  ('racing_car', UNIX_TIMESTAMP()),
+  ('racing_motorcycle', UNIX_TIMESTAMP()),
+  ('radio', UNIX_TIMESTAMP()),
+  ('radio_button', UNIX_TIMESTAMP()),
+  ('radioactive_sign', UNIX_TIMESTAMP()),
+  ('rage', UNIX_TIMESTAMP()),
+  ('railway_car', UNIX_TIMESTAMP()),
+  ('railway_track', UNIX_TIMESTAMP()),
+  ('rain_cloud', UNIX_TIMESTAMP()),
+  ('rainbow', UNIX_TIMESTAMP()),
+  ('rainbow-flag', UNIX_TIMESTAMP()),
+  ('raised_back_of_hand', UNIX_TIMESTAMP()),
+  ('raised_hand_with_fingers_splayed', UNIX_TIMESTAMP()),
+  ('raised_hands', UNIX_TIMESTAMP()),
+  ('raising_hand', UNIX_TIMESTAMP()),
+  ('ram', UNIX_TIMESTAMP()),
+  ('ramen', UNIX_TIMESTAMP()),
+  ('rat', UNIX_TIMESTAMP()),
+  ('razor', UNIX_TIMESTAMP()),
+  ('receipt', UNIX_TIMESTAMP()),
+  ('recycle', UNIX_TIMESTAMP()),
+  ('red_circle', UNIX_TIMESTAMP()),
+  ('red_envelope', UNIX_TIMESTAMP()),
+  ('red_haired_man', UNIX_TIMESTAMP()),
+  ('red_haired_person', UNIX_TIMESTAMP()),
+  ('red_haired_woman', UNIX_TIMESTAMP()),
+  ('registered', UNIX_TIMESTAMP()),
+  ('relaxed', UNIX_TIMESTAMP()),
+  ('relieved', UNIX_TIMESTAMP()),
+  ('reminder_ribbon', UNIX_TIMESTAMP()),
+  ('repeat', UNIX_TIMESTAMP()),
+  ('repeat_one', UNIX_TIMESTAMP()),
+  ('restroom', UNIX_TIMESTAMP()),
+  ('revolving_hearts', UNIX_TIMESTAMP()),
+  ('rewind', UNIX_TIMESTAMP()),
+  ('rhinoceros', UNIX_TIMESTAMP()),
+  ('ribbon', UNIX_TIMESTAMP()),
+  ('rice', UNIX_TIMESTAMP()),
+  ('rice_ball', UNIX_TIMESTAMP()),
+  ('rice_cracker', UNIX_TIMESTAMP()),
+  ('rice_scene', UNIX_TIMESTAMP()),
+  ('right-facing_fist', UNIX_TIMESTAMP()),
+  ('right_anger_bubble', UNIX_TIMESTAMP()),
+  ('ring', UNIX_TIMESTAMP()),
+  ('ringed_planet', UNIX_TIMESTAMP()),
+  ('robot_face', UNIX_TIMESTAMP()),
+  ('rock', UNIX_TIMESTAMP()),
+  ('rocket', UNIX_TIMESTAMP()),
+  ('roll_of_paper', UNIX_TIMESTAMP()),
+  ('rolled_up_newspaper', UNIX_TIMESTAMP()),
+  ('roller_coaster', UNIX_TIMESTAMP()),
+  ('roller_skate', UNIX_TIMESTAMP()),
+  ('rolling_on_the_floor_laughing', UNIX_TIMESTAMP()),
+  ('rooster', UNIX_TIMESTAMP()),
+  ('rose', UNIX_TIMESTAMP()),
+  ('rosette', UNIX_TIMESTAMP()),
+  ('rotating_light', UNIX_TIMESTAMP()),
+  ('round_pushpin', UNIX_TIMESTAMP()),
+  ('rowboat', UNIX_TIMESTAMP()),
+  ('ru', UNIX_TIMESTAMP()),
+  ('rugby_football', UNIX_TIMESTAMP()),
+  ('runner', UNIX_TIMESTAMP()),
+  ('running_shirt_with_sash', UNIX_TIMESTAMP()),
+  ('sa', UNIX_TIMESTAMP()),
+  ('safety_pin', UNIX_TIMESTAMP()),
+  ('safety_vest', UNIX_TIMESTAMP()),
+  ('sagittarius', UNIX_TIMESTAMP()),
+  ('sake', UNIX_TIMESTAMP()),
+  ('salt', UNIX_TIMESTAMP()),
+  ('sandal', UNIX_TIMESTAMP()),
+  ('sandwich', UNIX_TIMESTAMP()),
+  ('santa', UNIX_TIMESTAMP()),
+  ('sari', UNIX_TIMESTAMP()),
+  ('satellite', UNIX_TIMESTAMP()),
+  ('satellite_antenna', UNIX_TIMESTAMP()),
+  ('sauropod', UNIX_TIMESTAMP()),
+  ('saxophone', UNIX_TIMESTAMP()),
+  ('scales', UNIX_TIMESTAMP()),
+  ('scarf', UNIX_TIMESTAMP()),
+  ('school', UNIX_TIMESTAMP()),
+  ('school_satchel', UNIX_TIMESTAMP()),
+  ('scientist', UNIX_TIMESTAMP()),
+  ('scissors', UNIX_TIMESTAMP()),
+  ('scooter', UNIX_TIMESTAMP()),
+  ('scorpion', UNIX_TIMESTAMP()),
+  ('scorpius', UNIX_TIMESTAMP()),
+  ('scream', UNIX_TIMESTAMP()),
+  ('scream_cat', UNIX_TIMESTAMP()),
+  ('screwdriver', UNIX_TIMESTAMP()),
+  ('scroll', UNIX_TIMESTAMP()),
+  ('seal', UNIX_TIMESTAMP()),
+  ('seat', UNIX_TIMESTAMP()),
+  ('second_place_medal', UNIX_TIMESTAMP()),
+  ('secret', UNIX_TIMESTAMP()),
+  ('see_no_evil', UNIX_TIMESTAMP()),
+  ('seedling', UNIX_TIMESTAMP()),
+  ('selfie', UNIX_TIMESTAMP()),
+  ('service_dog', UNIX_TIMESTAMP()),
+  ('seven', UNIX_TIMESTAMP()),
+  ('sewing_needle', UNIX_TIMESTAMP()),
+  ('shallow_pan_of_food', UNIX_TIMESTAMP()),
+  ('shamrock', UNIX_TIMESTAMP()),
+  ('shark', UNIX_TIMESTAMP()),
+  ('shaved_ice', UNIX_TIMESTAMP()),
+  ('sheep', UNIX_TIMESTAMP()),
+  ('shell', UNIX_TIMESTAMP()),
+  ('shield', UNIX_TIMESTAMP()),
+  ('shinto_shrine', UNIX_TIMESTAMP()),
+  ('ship', UNIX_TIMESTAMP()),
+  ('shirt', UNIX_TIMESTAMP()),
+  ('shopping_bags', UNIX_TIMESTAMP()),
+  ('shopping_trolley', UNIX_TIMESTAMP()),
+  ('shorts', UNIX_TIMESTAMP()),
+  ('shower', UNIX_TIMESTAMP()),
+  ('shrimp', UNIX_TIMESTAMP()),
+  ('shrug', UNIX_TIMESTAMP()),
+  ('shushing_face', UNIX_TIMESTAMP()),
+  ('signal_strength', UNIX_TIMESTAMP()),
+  ('singer', UNIX_TIMESTAMP()),
+  ('six', UNIX_TIMESTAMP()),
+  ('six_pointed_star', UNIX_TIMESTAMP()),
+  ('skateboard', UNIX_TIMESTAMP()),
+  ('ski', UNIX_TIMESTAMP()),
+  ('skier', UNIX_TIMESTAMP()),
+  ('skull', UNIX_TIMESTAMP()),
+  ('skull_and_crossbones', UNIX_TIMESTAMP()),
+  ('skunk', UNIX_TIMESTAMP()),
+  ('sled', UNIX_TIMESTAMP()),
+  ('sleeping', UNIX_TIMESTAMP()),
+  ('sleeping_accommodation', UNIX_TIMESTAMP()),
+  ('sleepy', UNIX_TIMESTAMP()),
+  ('sleuth_or_spy', UNIX_TIMESTAMP()),
+  ('slightly_frowning_face', UNIX_TIMESTAMP()),
+  ('slightly_smiling_face', UNIX_TIMESTAMP()),
+  ('slot_machine', UNIX_TIMESTAMP()),
+  ('sloth', UNIX_TIMESTAMP()),
+  ('small_airplane', UNIX_TIMESTAMP()),
+  ('small_blue_diamond', UNIX_TIMESTAMP()),
+  ('small_orange_diamond', UNIX_TIMESTAMP()),
+  ('small_red_triangle', UNIX_TIMESTAMP()),
+  ('small_red_triangle_down', UNIX_TIMESTAMP()),
+  ('smile', UNIX_TIMESTAMP()),
+  ('smile_cat', UNIX_TIMESTAMP()),
+  ('smiley', UNIX_TIMESTAMP()),
+  ('smiley_cat', UNIX_TIMESTAMP()),
+  ('smiling_face_with_3_hearts', UNIX_TIMESTAMP()),
+  ('smiling_face_with_tear', UNIX_TIMESTAMP()),
+  ('smiling_imp', UNIX_TIMESTAMP()),
+  ('smirk', UNIX_TIMESTAMP()),
+  ('smirk_cat', UNIX_TIMESTAMP()),
+  ('smoking', UNIX_TIMESTAMP()),
+  ('snail', UNIX_TIMESTAMP()),
+  ('snake', UNIX_TIMESTAMP()),
+  ('sneezing_face', UNIX_TIMESTAMP()),
+  ('snow_capped_mountain', UNIX_TIMESTAMP()),
+  ('snow_cloud', UNIX_TIMESTAMP()),
+  ('snowboarder', UNIX_TIMESTAMP()),
+  ('snowflake', UNIX_TIMESTAMP()),
+  ('snowman', UNIX_TIMESTAMP()),
+  ('snowman_without_snow', UNIX_TIMESTAMP()),
+  ('soap', UNIX_TIMESTAMP()),
+  ('sob', UNIX_TIMESTAMP()),
+  ('soccer', UNIX_TIMESTAMP()),
+  ('socks', UNIX_TIMESTAMP()),
+  ('softball', UNIX_TIMESTAMP()),
+  ('soon', UNIX_TIMESTAMP()),
+  ('sos', UNIX_TIMESTAMP()),
+  ('sound', UNIX_TIMESTAMP()),
+  ('space_invader', UNIX_TIMESTAMP()),
+  ('spades', UNIX_TIMESTAMP()),
+  ('spaghetti', UNIX_TIMESTAMP()),
+  ('sparkle', UNIX_TIMESTAMP()),
+  ('sparkler', UNIX_TIMESTAMP()),
+  ('sparkles', UNIX_TIMESTAMP()),
+  ('sparkling_heart', UNIX_TIMESTAMP()),
+  ('speak_no_evil', UNIX_TIMESTAMP()),
+  ('speaker', UNIX_TIMESTAMP()),
+  ('speaking_head_in_silhouette', UNIX_TIMESTAMP()),
+  ('speech_balloon', UNIX_TIMESTAMP()),
+  ('speedboat', UNIX_TIMESTAMP()),
+  ('spider', UNIX_TIMESTAMP()),
+  ('spider_web', UNIX_TIMESTAMP()),
+  ('spiral_calendar_pad', UNIX_TIMESTAMP()),
+  ('spiral_note_pad', UNIX_TIMESTAMP()),
+  ('spock-hand', UNIX_TIMESTAMP()),
+  ('sponge', UNIX_TIMESTAMP()),
+  ('spoon', UNIX_TIMESTAMP()),
+  ('sports_medal', UNIX_TIMESTAMP()),
+  ('squid', UNIX_TIMESTAMP()),
+  ('stadium', UNIX_TIMESTAMP()),
+  ('standing_person', UNIX_TIMESTAMP()),
+  ('star', UNIX_TIMESTAMP()),
+  ('star-struck', UNIX_TIMESTAMP()),
+  ('star2', UNIX_TIMESTAMP()),
+  ('star_and_crescent', UNIX_TIMESTAMP()),
+  ('star_of_david', UNIX_TIMESTAMP()),
+  ('stars', UNIX_TIMESTAMP()),
+  ('station', UNIX_TIMESTAMP()),
+  ('statue_of_liberty', UNIX_TIMESTAMP()),
+  ('steam_locomotive', UNIX_TIMESTAMP()),
+  ('stethoscope', UNIX_TIMESTAMP()),
+  ('stew', UNIX_TIMESTAMP()),
+  ('stopwatch', UNIX_TIMESTAMP()),
+  ('straight_ruler', UNIX_TIMESTAMP()),
+  ('strawberry', UNIX_TIMESTAMP()),
+  ('stuck_out_tongue', UNIX_TIMESTAMP()),
+  ('stuck_out_tongue_closed_eyes', UNIX_TIMESTAMP()),
+  ('stuck_out_tongue_winking_eye', UNIX_TIMESTAMP()),
+  ('student', UNIX_TIMESTAMP()),
+  ('studio_microphone', UNIX_TIMESTAMP()),
+  ('stuffed_flatbread', UNIX_TIMESTAMP()),
+  ('sun_with_face', UNIX_TIMESTAMP()),
+  ('sunflower', UNIX_TIMESTAMP()),
+  ('sunglasses', UNIX_TIMESTAMP()),
+  ('sunny', UNIX_TIMESTAMP()),
+  ('sunrise', UNIX_TIMESTAMP()),
+  ('sunrise_over_mountains', UNIX_TIMESTAMP()),
+  ('superhero', UNIX_TIMESTAMP()),
+  ('supervillain', UNIX_TIMESTAMP()),
+  ('surfer', UNIX_TIMESTAMP()),
+  ('sushi', UNIX_TIMESTAMP()),
+  ('suspension_railway', UNIX_TIMESTAMP()),
+  ('swan', UNIX_TIMESTAMP()),
+  ('sweat', UNIX_TIMESTAMP()),
+  ('sweat_drops', UNIX_TIMESTAMP()),
+  ('sweat_smile', UNIX_TIMESTAMP()),
+  ('sweet_potato', UNIX_TIMESTAMP()),
+  ('swimmer', UNIX_TIMESTAMP()),
+  ('symbols', UNIX_TIMESTAMP()),
+  ('synagogue', UNIX_TIMESTAMP()),
+  ('sync', UNIX_TIMESTAMP()),
+  ('syringe', UNIX_TIMESTAMP()),
+  ('t-rex', UNIX_TIMESTAMP()),
+  ('table_tennis_paddle_and_ball', UNIX_TIMESTAMP()),
+  ('taco', UNIX_TIMESTAMP()),
+  ('tada', UNIX_TIMESTAMP()),
+  ('takeout_box', UNIX_TIMESTAMP()),
+  ('tamale', UNIX_TIMESTAMP()),
+  ('tanabata_tree', UNIX_TIMESTAMP()),
+  ('tangerine', UNIX_TIMESTAMP()),
+  ('taurus', UNIX_TIMESTAMP()),
+  ('taxi', UNIX_TIMESTAMP()),
+  ('tea', UNIX_TIMESTAMP()),
+  ('teacher', UNIX_TIMESTAMP()),
+  ('teapot', UNIX_TIMESTAMP()),
+  ('technologist', UNIX_TIMESTAMP()),
+  ('teddy_bear', UNIX_TIMESTAMP()),
+  ('telephone_receiver', UNIX_TIMESTAMP()),
+  ('telescope', UNIX_TIMESTAMP()),
+  ('tennis', UNIX_TIMESTAMP()),
+  ('tent', UNIX_TIMESTAMP()),
+  ('test_tube', UNIX_TIMESTAMP()),
+  ('the_horns', UNIX_TIMESTAMP()),
+  ('thermometer', UNIX_TIMESTAMP()),
+  ('thinking_face', UNIX_TIMESTAMP()),
+  ('third_place_medal', UNIX_TIMESTAMP()),
+  ('thong_sandal', UNIX_TIMESTAMP()),
+  ('thought_balloon', UNIX_TIMESTAMP()),
+  ('thread', UNIX_TIMESTAMP()),
+  ('three', UNIX_TIMESTAMP()),
+  ('three_button_mouse', UNIX_TIMESTAMP()),
+  ('thumbsup', UNIX_TIMESTAMP()),
+  ('thunder_cloud_and_rain', UNIX_TIMESTAMP()),
+  ('ticket', UNIX_TIMESTAMP()),
+  ('tiger', UNIX_TIMESTAMP()),
+  ('tiger2', UNIX_TIMESTAMP()),
+  ('timer_clock', UNIX_TIMESTAMP()),
+  ('tired_face', UNIX_TIMESTAMP()),
+  ('tm', UNIX_TIMESTAMP()),
+  ('toilet', UNIX_TIMESTAMP()),
+  ('tokyo_tower', UNIX_TIMESTAMP()),
+  ('tomato', UNIX_TIMESTAMP()),
+  ('tongue', UNIX_TIMESTAMP()),
+  ('toolbox', UNIX_TIMESTAMP()),
+  ('tooth', UNIX_TIMESTAMP()),
+  ('toothbrush', UNIX_TIMESTAMP()),
+  ('top', UNIX_TIMESTAMP()),
+  ('tophat', UNIX_TIMESTAMP()),
+  ('tornado', UNIX_TIMESTAMP()),
+  ('trackball', UNIX_TIMESTAMP()),
+  ('tractor', UNIX_TIMESTAMP()),
+  ('traffic_light', UNIX_TIMESTAMP()),
+  ('train', UNIX_TIMESTAMP()),
+  ('train2', UNIX_TIMESTAMP()),
+  ('tram', UNIX_TIMESTAMP()),
+  ('transgender_flag', UNIX_TIMESTAMP()),
+  ('transgender_symbol', UNIX_TIMESTAMP()),
+  ('triangular_flag_on_post', UNIX_TIMESTAMP()),
+  ('triangular_ruler', UNIX_TIMESTAMP()),
+  ('trident', UNIX_TIMESTAMP()),
+  ('triumph', UNIX_TIMESTAMP()),
+  ('trolleybus', UNIX_TIMESTAMP()),
+  ('trophy', UNIX_TIMESTAMP()),
+  ('tropical_drink', UNIX_TIMESTAMP()),
+  ('tropical_fish', UNIX_TIMESTAMP()),
+  ('truck', UNIX_TIMESTAMP()),
+  ('trumpet', UNIX_TIMESTAMP()),
+  ('tulip', UNIX_TIMESTAMP()),
+  ('tumbler_glass', UNIX_TIMESTAMP()),
+  ('turkey', UNIX_TIMESTAMP()),
+  ('turtle', UNIX_TIMESTAMP()),
+  ('tv', UNIX_TIMESTAMP()),
+  ('twisted_rightwards_arrows', UNIX_TIMESTAMP()),
+  ('two', UNIX_TIMESTAMP()),
+  ('two_hearts', UNIX_TIMESTAMP()),
+  ('two_men_holding_hands', UNIX_TIMESTAMP()),
+  ('two_women_holding_hands', UNIX_TIMESTAMP()),
+  ('u5272', UNIX_TIMESTAMP()),
+  ('u5408', UNIX_TIMESTAMP()),
+  ('u55b6', UNIX_TIMESTAMP()),
+  ('u6307', UNIX_TIMESTAMP()),
+  ('u6708', UNIX_TIMESTAMP()),
+  ('u6709', UNIX_TIMESTAMP()),
+  ('u6e80', UNIX_TIMESTAMP()),
+  ('u7121', UNIX_TIMESTAMP()),
+  ('u7533', UNIX_TIMESTAMP()),
+  ('u7981', UNIX_TIMESTAMP()),
+  ('u7a7a', UNIX_TIMESTAMP()),
+  ('umbrella', UNIX_TIMESTAMP()),
+  ('umbrella_on_ground', UNIX_TIMESTAMP()),
+  ('umbrella_with_rain_drops', UNIX_TIMESTAMP()),
+  ('unamused', UNIX_TIMESTAMP()),
+  ('underage', UNIX_TIMESTAMP()),
+  ('unicorn_face', UNIX_TIMESTAMP()),
+  ('unlock', UNIX_TIMESTAMP()),
+  ('up', UNIX_TIMESTAMP()),
+  ('upside_down_face', UNIX_TIMESTAMP()),
+  ('us', UNIX_TIMESTAMP()),
+  ('v', UNIX_TIMESTAMP()),
+  ('vampire', UNIX_TIMESTAMP()),
+  ('vertical_traffic_light', UNIX_TIMESTAMP()),
+  ('vhs', UNIX_TIMESTAMP()),
+  ('vibration_mode', UNIX_TIMESTAMP()),
+  ('video_camera', UNIX_TIMESTAMP()),
+  ('video_game', UNIX_TIMESTAMP()),
+  ('violin', UNIX_TIMESTAMP()),
+  ('virgo', UNIX_TIMESTAMP()),
+  ('volcano', UNIX_TIMESTAMP()),
+  ('volleyball', UNIX_TIMESTAMP()),
+  ('vs', UNIX_TIMESTAMP()),
+  ('waffle', UNIX_TIMESTAMP()),
+  ('walking', UNIX_TIMESTAMP()),
+  ('waning_crescent_moon', UNIX_TIMESTAMP()),
+  ('waning_gibbous_moon', UNIX_TIMESTAMP()),
+  ('warning', UNIX_TIMESTAMP()),
+  ('wastebasket', UNIX_TIMESTAMP()),
+  ('watch', UNIX_TIMESTAMP()),
+  ('water_buffalo', UNIX_TIMESTAMP()),
+  ('water_polo', UNIX_TIMESTAMP()),
+  ('watermelon', UNIX_TIMESTAMP()),
+  ('wave', UNIX_TIMESTAMP()),
+  ('waving_black_flag', UNIX_TIMESTAMP()),
+  ('waving_white_flag', UNIX_TIMESTAMP()),
+  ('wavy_dash', UNIX_TIMESTAMP()),
+  ('waxing_crescent_moon', UNIX_TIMESTAMP()),
+  ('wc', UNIX_TIMESTAMP()),
+  ('weary', UNIX_TIMESTAMP()),
+  ('wedding', UNIX_TIMESTAMP()),
+  ('weight_lifter', UNIX_TIMESTAMP()),
+  ('whale', UNIX_TIMESTAMP()),
+  ('whale2', UNIX_TIMESTAMP()),
+  ('wheel_of_dharma', UNIX_TIMESTAMP()),
+  ('wheelchair', UNIX_TIMESTAMP()),
+  ('white_check_mark', UNIX_TIMESTAMP()),
+  ('white_circle', UNIX_TIMESTAMP()),
+  ('white_flower', UNIX_TIMESTAMP()),
+  ('white_frowning_face', UNIX_TIMESTAMP()),
+  ('white_haired_man', UNIX_TIMESTAMP()),
+  ('white_haired_person', UNIX_TIMESTAMP()),
+  ('white_haired_woman', UNIX_TIMESTAMP()),
+  ('white_heart', UNIX_TIMESTAMP()),
+  ('white_large_square', UNIX_TIMESTAMP()),
+  ('white_medium_small_square', UNIX_TIMESTAMP()),
+  ('white_medium_square', UNIX_TIMESTAMP()),
+  ('white_small_square', UNIX_TIMESTAMP()),
+  ('white_square_button', UNIX_TIMESTAMP()),
+  ('wilted_flower', UNIX_TIMESTAMP()),
+  ('wind_blowing_face', UNIX_TIMESTAMP()),
+  ('wind_chime', UNIX_TIMESTAMP()),
+  ('window', UNIX_TIMESTAMP()),
+  ('wine_glass', UNIX_TIMESTAMP()),
+  ('wink', UNIX_TIMESTAMP()),
+  ('wolf', UNIX_TIMESTAMP()),
+  ('woman', UNIX_TIMESTAMP()),
+  ('woman-biking', UNIX_TIMESTAMP()),
+  ('woman-bouncing-ball', UNIX_TIMESTAMP()),
+  ('woman-bowing', UNIX_TIMESTAMP()),
+  ('woman-boy', UNIX_TIMESTAMP()),
+  ('woman-boy-boy', UNIX_TIMESTAMP()),
+  ('woman-cartwheeling', UNIX_TIMESTAMP()),
+  ('woman-facepalming', UNIX_TIMESTAMP()),
+  ('woman-frowning', UNIX_TIMESTAMP()),
+  ('woman-gesturing-no', UNIX_TIMESTAMP()),
+  ('woman-gesturing-ok', UNIX_TIMESTAMP()),
+  ('woman-getting-haircut', UNIX_TIMESTAMP()),
+  ('woman-getting-massage', UNIX_TIMESTAMP()),
+  ('woman-girl', UNIX_TIMESTAMP()),
+  ('woman-girl-boy', UNIX_TIMESTAMP()),
+  ('woman-girl-girl', UNIX_TIMESTAMP()),
+  ('woman-golfing', UNIX_TIMESTAMP()),
+  ('woman-heart-man', UNIX_TIMESTAMP()),
+  ('woman-heart-woman', UNIX_TIMESTAMP()),
+  ('woman-juggling', UNIX_TIMESTAMP()),
+  ('woman-kiss-man', UNIX_TIMESTAMP()),
+  ('woman-kiss-woman', UNIX_TIMESTAMP()),
+  ('woman-lifting-weights', UNIX_TIMESTAMP()),
+  ('woman-mountain-biking', UNIX_TIMESTAMP()),
+  ('woman-playing-handball', UNIX_TIMESTAMP()),
+  ('woman-playing-water-polo', UNIX_TIMESTAMP()),
+  ('woman-pouting', UNIX_TIMESTAMP()),
+  ('woman-raising-hand', UNIX_TIMESTAMP()),
+  ('woman-rowing-boat', UNIX_TIMESTAMP()),
+  ('woman-running', UNIX_TIMESTAMP()),
+  ('woman-shrugging', UNIX_TIMESTAMP()),
+  ('woman-surfing', UNIX_TIMESTAMP()),
+  ('woman-swimming', UNIX_TIMESTAMP()),
+  ('woman-tipping-hand', UNIX_TIMESTAMP()),
+  ('woman-walking', UNIX_TIMESTAMP()),
+  ('woman-wearing-turban', UNIX_TIMESTAMP()),
+  ('woman-woman-boy', UNIX_TIMESTAMP()),
+  ('woman-woman-boy-boy', UNIX_TIMESTAMP()),
+  ('woman-woman-girl', UNIX_TIMESTAMP()),
+  ('woman-woman-girl-boy', UNIX_TIMESTAMP()),
+  ('woman-woman-girl-girl', UNIX_TIMESTAMP()),
+  ('woman-wrestling', UNIX_TIMESTAMP()),
+  ('woman_climbing', UNIX_TIMESTAMP()),
+  ('woman_feeding_baby', UNIX_TIMESTAMP()),
+  ('woman_in_lotus_position', UNIX_TIMESTAMP()),
+  ('woman_in_manual_wheelchair', UNIX_TIMESTAMP()),
+  ('woman_in_motorized_wheelchair', UNIX_TIMESTAMP()),
+  ('woman_in_steamy_room', UNIX_TIMESTAMP()),
+  ('woman_in_tuxedo', UNIX_TIMESTAMP()),
+  ('woman_kneeling', UNIX_TIMESTAMP()),
+  ('woman_standing', UNIX_TIMESTAMP()),
+  ('woman_with_beard', UNIX_TIMESTAMP()),
+  ('woman_with_probing_cane', UNIX_TIMESTAMP()),
+  ('woman_with_veil', UNIX_TIMESTAMP()),
+  ('womans_clothes', UNIX_TIMESTAMP()),
+  ('womans_flat_shoe', UNIX_TIMESTAMP()),
+  ('womans_hat', UNIX_TIMESTAMP()),
+  ('women-with-bunny-ears-partying', UNIX_TIMESTAMP()),
+  ('womens', UNIX_TIMESTAMP()),
+  ('wood', UNIX_TIMESTAMP()),
+  ('woozy_face', UNIX_TIMESTAMP()),
+  ('world_map', UNIX_TIMESTAMP()),
+  ('worm', UNIX_TIMESTAMP()),
+  ('worried', UNIX_TIMESTAMP()),
+  ('wrench', UNIX_TIMESTAMP()),
+  ('wrestlers', UNIX_TIMESTAMP()),
+  ('writing_hand', UNIX_TIMESTAMP()),
+  ('x', UNIX_TIMESTAMP()),
+  ('yarn', UNIX_TIMESTAMP()),
+  ('yawning_face', UNIX_TIMESTAMP()),
+  ('yellow_heart', UNIX_TIMESTAMP()),
+  ('yen', UNIX_TIMESTAMP()),
+  ('yin_yang', UNIX_TIMESTAMP()),
+  ('yo-yo', UNIX_TIMESTAMP()),
+  ('yum', UNIX_TIMESTAMP()),
+  ('zany_face', UNIX_TIMESTAMP()),
+  ('zap', UNIX_TIMESTAMP()),
+  ('zebra_face', UNIX_TIMESTAMP()),
+  ('zero', UNIX_TIMESTAMP()),
+  ('zipper_mouth_face', UNIX_TIMESTAMP()),
+  ('zombie', UNIX_TIMESTAMP()),
+  ('zzz', UNIX_TIMESTAMP());
@@ -0,0 +1,22 @@
+-- 初期データのライブコメント・リアクションから配信ごとのスコアを算出
+UPDATE livestreams l
+INNER JOIN (
+  SELECT livestream_id, SUM(tip) AS total_tip, COUNT(*) AS comment_count
+  FROM livecomments
+  WHERE deleted_at IS NULL
+  GROUP BY livestream_id
+) lc ON lc.livestream_id = l.id
+SET l.total_tip = lc.total_tip, l.comment_count = lc.comment_count;
+
+UPDATE livestreams l
+INNER JOIN (SELECT livestream_id, COUNT(*) AS c FROM reactions GROUP BY livestream_id) r ON r.livestream_id = l.id
+SET l.reaction_count = r.c;
+
+-- 配信者ごとのスコアは、配信ごとのスコアの合計
+UPDATE users u
+INNER JOIN (
+  SELECT user_id, SUM(total_tip) AS total_tip, SUM(reaction_count) AS reaction_count, SUM(comment_count) AS comment_count
+  FROM livestreams
+  GROUP BY user_id
+) l ON l.user_id = u.id
+SET u.total_tip = l.total_tip, u.reaction_count = l.reaction_count, u.comment_count = l.comment_count;
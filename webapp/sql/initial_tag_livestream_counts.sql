@@ -0,0 +1,4 @@
+-- 初期データのlivestream_tagsからタグごとのライブ配信数を算出
+UPDATE tags t
+INNER JOIN (SELECT tag_id, COUNT(*) AS c FROM livestream_tags GROUP BY tag_id) lt ON lt.tag_id = t.id
+SET t.livestream_count = lt.c;